@@ -32,6 +32,7 @@ type Messages struct {
 	CmdBuild   string
 	CmdJvm     string
 	CmdCheck   string
+	CmdDebug   string
 	CmdFormat  string
 	CmdEnv     string
 	CmdVersion string
@@ -63,7 +64,16 @@ type Messages struct {
 	OptTokens   string
 	OptAST      string
 	OptBytecode string
+	OptStats    string
+	OptSandbox  string
+	OptDeterministic string
+	OptSeed          string
+	OptRecord        string
+	OptReplay        string
+	OptPort          string
+	OptStdio         string
 	OptOutput   string
+	OptImage    string
 	OptVerbose  string
 	OptLang     string
 
@@ -87,7 +97,17 @@ type Messages struct {
 	ErrWriteFile       string
 	ErrInvalidCompiledFile string
 	ErrDeserializeFailed   string
+	ErrLoadImage           string
 	ErrFormatFailed        string
+
+	// 执行统计 (sola run -stats)
+	StatsReport string
+	ErrSandbox  string
+	ErrRecord   string
+	ErrReplay   string
+	ErrDeterminismFlags string
+	ErrDebugFlags       string
+	ErrDebug            string
 	ErrFormatNotFormatted  string
 	ErrJvmGenFailed        string
 
@@ -122,6 +142,7 @@ var messagesEN = Messages{
 	CmdBuild:   "Compile to bytecode",
 	CmdJvm:     "Compile to JVM bytecode (.class file)",
 	CmdCheck:   "Check syntax without running",
+	CmdDebug:   "Debug a Sola source file over the Debug Adapter Protocol",
 	CmdFormat:  "Format source code",
 	CmdEnv:     "Show environment info (package directory, etc.)",
 	CmdVersion: "Show version information",
@@ -150,7 +171,16 @@ var messagesEN = Messages{
 	OptTokens:   "Show lexer tokens",
 	OptAST:      "Show AST structure",
 	OptBytecode: "Show compiled bytecode",
+	OptStats:    "Print execution statistics after the program exits",
+	OptSandbox:  "Run under the capability policy in the given JSON file",
+	OptDeterministic: "Use a virtual clock and seeded randomness and coroutine scheduling",
+	OptSeed:          "Seed for -deterministic",
+	OptRecord:        "Record time, randomness and scheduling inputs to the given file",
+	OptReplay:        "Replay the inputs recorded in the given file",
+	OptPort:          "Serve DAP on the given TCP port (localhost)",
+	OptStdio:         "Serve DAP on standard input and output (default)",
 	OptOutput:   "Output file path",
+	OptImage:    "Build a heap snapshot image with all classes initialized (.image)",
 	OptVerbose:  "Verbose output",
 	OptLang:     "Set language (en/zh)",
 
@@ -172,7 +202,27 @@ var messagesEN = Messages{
 	ErrWriteFile:          "Error writing file",
 	ErrInvalidCompiledFile: "Error: %s is not a valid compiled file (expected %s)",
 	ErrDeserializeFailed:   "Failed to load compiled file",
+	ErrLoadImage:           "Failed to load image",
 	ErrFormatFailed:        "Formatting failed",
+
+	StatsReport: `Execution statistics:
+  function calls       %d
+  tail calls           %d
+  inline cache hits    %d
+  inline cache misses  %d
+  megamorphic sites    %d
+  quickened sites      %d
+  deoptimized sites    %d
+  allocations          %d
+  allocated bytes      %d
+  collections          %d
+`,
+	ErrSandbox: "Error loading sandbox policy: %v",
+	ErrRecord:  "Error creating recording: %v",
+	ErrReplay:  "Error loading recording: %v",
+	ErrDeterminismFlags: "-deterministic, -record and -replay cannot be combined",
+	ErrDebugFlags:       "--port and --stdio cannot be combined",
+	ErrDebug:            "Debug session error: %v",
 	ErrFormatNotFormatted:  "%s: not formatted",
 	ErrJvmGenFailed:        "JVM bytecode generation failed",
 
@@ -205,6 +255,7 @@ var messagesZH = Messages{
 	CmdBuild:   "编译为字节码",
 	CmdJvm:     "编译为 JVM 字节码（.class 文件）",
 	CmdCheck:   "检查语法，不运行",
+	CmdDebug:   "通过调试适配器协议 (DAP) 调试 Sola 源文件",
 	CmdFormat:  "格式化源代码",
 	CmdEnv:     "显示环境信息（包目录等）",
 	CmdVersion: "显示版本信息",
@@ -233,7 +284,16 @@ var messagesZH = Messages{
 	OptTokens:   "显示词法分析结果",
 	OptAST:      "显示抽象语法树",
 	OptBytecode: "显示编译后的字节码",
+	OptStats:    "程序结束后打印执行统计信息",
+	OptSandbox:  "按指定 JSON 文件中的能力策略运行",
+	OptDeterministic: "使用虚拟时钟，随机数和协程调度顺序由种子决定",
+	OptSeed:          "-deterministic 使用的种子",
+	OptRecord:        "把时间、随机数和调度输入记录到指定文件",
+	OptReplay:        "回放指定文件中记录的输入",
+	OptPort:          "在指定的 TCP 端口 (localhost) 上提供 DAP 服务",
+	OptStdio:         "通过标准输入输出提供 DAP 服务 (默认)",
 	OptOutput:   "输出文件路径",
+	OptImage:    "构建所有类已初始化的堆快照映像 (.image)",
 	OptVerbose:  "详细输出",
 	OptLang:     "设置语言 (en/zh)",
 
@@ -255,7 +315,27 @@ var messagesZH = Messages{
 	ErrWriteFile:          "写入文件错误",
 	ErrInvalidCompiledFile: "错误: %s 不是有效的编译文件（应为 %s）",
 	ErrDeserializeFailed:   "加载编译文件失败",
+	ErrLoadImage:           "加载映像失败",
 	ErrFormatFailed:        "格式化失败",
+
+	StatsReport: `执行统计:
+  函数调用次数        %d
+  尾调用次数          %d
+  内联缓存命中        %d
+  内联缓存未命中      %d
+  超多态调用点        %d
+  quickening 改写位置 %d
+  去优化位置          %d
+  分配次数            %d
+  分配字节数          %d
+  回收次数            %d
+`,
+	ErrSandbox: "加载沙箱策略失败: %v",
+	ErrRecord:  "创建记录文件失败: %v",
+	ErrReplay:  "加载记录文件失败: %v",
+	ErrDeterminismFlags: "-deterministic、-record 和 -replay 不能同时使用",
+	ErrDebugFlags:       "--port 和 --stdio 不能同时使用",
+	ErrDebug:            "调试会话错误: %v",
 	ErrFormatNotFormatted:  "%s: 未格式化",
 	ErrJvmGenFailed:        "JVM 字节码生成失败",
 
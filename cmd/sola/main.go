@@ -9,6 +9,8 @@ import (
 
 	"github.com/tangzhangming/nova/internal/ast"
 	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/debug"
+	"github.com/tangzhangming/nova/internal/debug/dap"
 	"github.com/tangzhangming/nova/internal/formatter"
 	"github.com/tangzhangming/nova/internal/i18n"
 	"github.com/tangzhangming/nova/internal/jvmgen"
@@ -17,6 +19,7 @@ import (
 	"github.com/tangzhangming/nova/internal/parser"
 	"github.com/tangzhangming/nova/internal/repl"
 	"github.com/tangzhangming/nova/internal/runtime"
+	"github.com/tangzhangming/nova/internal/vm"
 )
 
 const (
@@ -57,6 +60,8 @@ func main() {
 		cmdJvm(args[1:])
 	case "check":
 		cmdCheck(args[1:])
+	case "debug":
+		cmdDebug(args[1:])
 	case "init":
 		cmdInit(args[1:])
 	case "format", "fmt":
@@ -120,6 +125,7 @@ func printUsage() {
 	fmt.Printf("  build <file>    %s\n", m.CmdBuild)
 	fmt.Printf("  jvm <file>      %s\n", m.CmdJvm)
 	fmt.Printf("  check <file>    %s\n", m.CmdCheck)
+	fmt.Printf("  debug <file>    %s\n", m.CmdDebug)
 	fmt.Printf("  format <file>   %s\n", m.CmdFormat)
 	fmt.Printf("  repl            %s\n", "Start interactive REPL")
 	fmt.Printf("  env             %s\n", m.CmdEnv)
@@ -130,12 +136,20 @@ func printUsage() {
 	fmt.Printf("  -tokens         %s\n", m.OptTokens)
 	fmt.Printf("  -ast            %s\n", m.OptAST)
 	fmt.Printf("  -bytecode       %s\n", m.OptBytecode)
+	fmt.Printf("  -stats          %s\n", m.OptStats)
+	fmt.Printf("  -sandbox <file> %s\n", m.OptSandbox)
+	fmt.Printf("  -deterministic  %s\n", m.OptDeterministic)
+	fmt.Printf("  -seed <n>       %s\n", m.OptSeed)
+	fmt.Printf("  -record <file>  %s\n", m.OptRecord)
+	fmt.Printf("  -replay <file>  %s\n", m.OptReplay)
 	fmt.Printf("  --lang <en|zh>  %s\n", m.OptLang)
 	fmt.Println()
 	fmt.Println(m.HelpExamples)
 	fmt.Printf("  sola run main%s\n", loader.SourceFileExtension)
 	fmt.Printf("  sola run -ast main%s\n", loader.SourceFileExtension)
+	fmt.Printf("  sola run -deterministic -seed=42 main%s\n", loader.SourceFileExtension)
 	fmt.Printf("  sola check main%s\n", loader.SourceFileExtension)
+	fmt.Printf("  sola debug --port 4711 main%s\n", loader.SourceFileExtension)
 	fmt.Printf("  sola format -w main%s\n", loader.SourceFileExtension)
 	fmt.Printf("  sola repl\n")
 	fmt.Printf("  sola --lang zh help\n")
@@ -170,6 +184,12 @@ func cmdRun(args []string) {
 	showTokens := fs.Bool("tokens", false, m.OptTokens)
 	showAST := fs.Bool("ast", false, m.OptAST)
 	showBytecode := fs.Bool("bytecode", false, m.OptBytecode)
+	showStats := fs.Bool("stats", false, m.OptStats)
+	sandboxFile := fs.String("sandbox", "", m.OptSandbox)
+	deterministic := fs.Bool("deterministic", false, m.OptDeterministic)
+	seed := fs.Int64("seed", 0, m.OptSeed)
+	recordFile := fs.String("record", "", m.OptRecord)
+	replayFile := fs.String("replay", "", m.OptReplay)
 
 	fs.Usage = func() {
 		fmt.Println(m.HelpUsage + " sola run [options] <file>")
@@ -191,9 +211,24 @@ func cmdRun(args []string) {
 
 	filename := fs.Arg(0)
 
-	// 检查是否是编译后的文件
+	var opts runtime.Options
+	if *sandboxFile != "" {
+		sandbox, err := runtime.LoadSandbox(*sandboxFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, m.ErrSandbox+"\n", err)
+			os.Exit(1)
+		}
+		opts.Sandbox = sandbox
+	}
+	opts.Determinism = determinismOption(*deterministic, *seed, *recordFile, *replayFile)
+
+	// 检查是否是编译后的文件或映像
 	if strings.HasSuffix(filename, bytecode.CompiledFileExtension) {
-		runCompiled(filename)
+		runCompiled(filename, opts, *showStats)
+		return
+	}
+	if strings.HasSuffix(filename, bytecode.ImageFileExtension) {
+		runImage(filename, opts, *showStats)
 		return
 	}
 
@@ -222,8 +257,12 @@ func cmdRun(args []string) {
 	}
 
 	// 正常运行
-	r := runtime.New()
-	if err := r.Run(string(source), filename); err != nil {
+	r := runtime.NewWithOptions(opts)
+	err = r.Run(string(source), filename)
+	if *showStats {
+		printStats(r.Stats(), r.MemoryStats())
+	}
+	if err != nil {
 		// 如果有非空错误消息则打印（VM 的异常信息已经打印过了）
 		if err.Error() != "" {
 			fmt.Fprintf(os.Stderr, m.ErrRuntime+"\n", err)
@@ -232,8 +271,110 @@ func cmdRun(args []string) {
 	}
 }
 
+// cmdDebug 在 DAP 服务器下调试源文件
+func cmdDebug(args []string) {
+	m := Msg()
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	port := fs.Int("port", 0, m.OptPort)
+	stdio := fs.Bool("stdio", false, m.OptStdio)
+
+	fs.Usage = func() {
+		fmt.Println(m.HelpUsage + " sola debug [--port N | --stdio] <file>")
+		fmt.Println()
+		fmt.Println(m.HelpOptions)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, m.ErrNoInput)
+		os.Exit(1)
+	}
+	if *port != 0 && *stdio {
+		fmt.Fprintln(os.Stderr, m.ErrDebugFlags)
+		os.Exit(1)
+	}
+
+	filename, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrReadFile+"\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrReadFile+"\n", err)
+		os.Exit(1)
+	}
+
+	server := dap.NewServerWithConfig(debug.NewDebugger(), dap.ServerConfig{Program: filename})
+	if *port != 0 {
+		err = server.ServeTCP(fmt.Sprintf("127.0.0.1:%d", *port))
+	} else {
+		err = server.ServeStdio()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrDebug+"\n", err)
+		os.Exit(1)
+	}
+}
+
+// determinismOption 按 -deterministic、-record 和 -replay 返回时间、随机数和调度顺序的来源
+// 记录在每次运行结束时写入文件
+func determinismOption(deterministic bool, seed int64, recordFile, replayFile string) runtime.Determinism {
+	m := Msg()
+	modes := 0
+	for _, on := range []bool{deterministic, recordFile != "", replayFile != ""} {
+		if on {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fmt.Fprintln(os.Stderr, m.ErrDeterminismFlags)
+		os.Exit(1)
+	}
+
+	switch {
+	case deterministic:
+		return runtime.Seeded(seed)
+	case recordFile != "":
+		f, err := os.Create(recordFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, m.ErrRecord+"\n", err)
+			os.Exit(1)
+		}
+		return runtime.Record(f)
+	case replayFile != "":
+		f, err := os.Open(replayFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, m.ErrReplay+"\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		det, err := runtime.Replay(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, m.ErrReplay+"\n", err)
+			os.Exit(1)
+		}
+		return det
+	}
+	return nil
+}
+
+// printStats 向标准错误输出虚拟机的执行和内存统计信息
+func printStats(stats vm.VMStats, mem vm.MemoryStats) {
+	fmt.Fprintf(os.Stderr, Msg().StatsReport,
+		stats.FunctionCalls, stats.TailCalls,
+		stats.InlineCacheHits, stats.InlineCacheMisses, stats.MegamorphicSites,
+		stats.QuickenedSites, stats.DeoptimizedSites,
+		mem.Allocations, mem.AllocatedBytes, mem.Collections)
+}
+
 // runCompiled 运行编译后的字节码文件
-func runCompiled(filename string) {
+func runCompiled(filename string, opts runtime.Options, showStats bool) {
 	m := Msg()
 
 	// 读取编译后的文件
@@ -260,8 +401,12 @@ func runCompiled(filename string) {
 	cf.SourceFile = filepath.Base(filename)
 
 	// 运行
-	r := runtime.New()
-	if err := r.RunCompiled(cf); err != nil {
+	r := runtime.NewWithOptions(opts)
+	err = r.RunCompiled(cf)
+	if showStats {
+		printStats(r.Stats(), r.MemoryStats())
+	}
+	if err != nil {
 		if err.Error() != "" {
 			fmt.Fprintf(os.Stderr, m.ErrRuntime+"\n", err)
 		}
@@ -269,11 +414,41 @@ func runCompiled(filename string) {
 	}
 }
 
-// cmdBuild 编译为字节码
+// runImage 运行堆快照映像
+func runImage(filename string, opts runtime.Options, showStats bool) {
+	m := Msg()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrReadFile+"\n", err)
+		os.Exit(1)
+	}
+	if err := bytecode.ValidateImageHeader(data); err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrLoadImage+": %s\n", err)
+		os.Exit(1)
+	}
+
+	r := runtime.NewWithOptions(opts)
+	err = r.RunImageFile(data)
+	if showStats {
+		printStats(r.Stats(), r.MemoryStats())
+	}
+	if err != nil {
+		if _, ok := err.(*bytecode.FormatError); ok {
+			fmt.Fprintf(os.Stderr, m.ErrLoadImage+": %s\n", err)
+		} else if err.Error() != "" {
+			fmt.Fprintf(os.Stderr, m.ErrRuntime+"\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// cmdBuild 编译为字节码 (-image 时构建堆快照映像)
 func cmdBuild(args []string) {
 	m := Msg()
 	fs := flag.NewFlagSet("build", flag.ExitOnError)
 	output := fs.String("o", "", m.OptOutput)
+	image := fs.Bool("image", false, m.OptImage)
 
 	fs.Usage = func() {
 		fmt.Println(m.HelpUsage + " sola build [options] <file>")
@@ -308,6 +483,11 @@ func cmdBuild(args []string) {
 		os.Exit(1)
 	}
 
+	if *image {
+		buildImage(string(source), filename, *output)
+		return
+	}
+
 	// 编译
 	r := runtime.New()
 	cf, err := r.CompileToCompiledFile(string(source), filename)
@@ -342,6 +522,27 @@ func cmdBuild(args []string) {
 	fmt.Printf(m.SuccessBuildComplete+"\n", outputFile, fi.Size())
 }
 
+// buildImage 构建堆快照映像：编译并执行所有类的静态初始化后保存程序状态
+func buildImage(source, filename, outputFile string) {
+	m := Msg()
+
+	data, err := runtime.BuildImageFile(source, filename, runtime.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrCompileFailed+": %s\n", err)
+		os.Exit(1)
+	}
+
+	// 默认：将 .sola 替换为 .image
+	if outputFile == "" {
+		outputFile = strings.TrimSuffix(filename, loader.SourceFileExtension) + bytecode.ImageFileExtension
+	}
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, m.ErrWriteFile+": %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(m.SuccessBuildComplete+"\n", outputFile, len(data))
+}
+
 // cmdJvm 编译为 JVM 字节码
 func cmdJvm(args []string) {
 	m := Msg()
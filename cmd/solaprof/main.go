@@ -14,7 +14,13 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/profiler"
+	"github.com/tangzhangming/nova/internal/runtime"
+	"github.com/tangzhangming/nova/internal/vm"
 )
 
 // 版本信息
@@ -161,6 +167,39 @@ func runProfile(args []string) error {
 		}
 	}
 	
+	source, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err
+	}
+	
+	// 通过 VM 的执行钩子收集函数调用和分配
+	config := profiler.DefaultConfig()
+	if *sampleRate > 0 {
+		config.CPUSampleInterval = time.Second / time.Duration(*sampleRate)
+	}
+	prof := profiler.NewProfilerWithConfig(config)
+	prof.Enable()
+	var calls vm.CallRecorder
+	var allocs vm.AllocationRecorder
+	var types []profiler.ProfileType
+	if *cpuFlag {
+		calls = prof
+		types = append(types, profiler.ProfileCPU)
+	}
+	if *memFlag {
+		allocs = prof
+		types = append(types, profiler.ProfileMemory)
+	}
+	if err := prof.Start(types...); err != nil {
+		return err
+	}
+	
+	r := runtime.NewWithOptions(runtime.Options{
+		Hooks: []vm.Hook{vm.NewProfilerHook(calls, allocs)},
+	})
+	runErr := r.Run(string(source), scriptPath)
+	prof.Stop()
+	
 	// 创建性能数据
 	profile := &ProfileData{
 		Version:    Version,
@@ -171,24 +210,113 @@ func runProfile(args []string) error {
 			SampleRate:  *sampleRate,
 		},
 	}
+	if *cpuFlag {
+		profile.CPUData = cpuProfileData(prof.GetCPUProfile(), prof.GetStats().Duration, config.CPUSampleInterval)
+	}
+	if *memFlag {
+		profile.MemData = memProfileData(prof.GetMemoryProfile())
+	}
 	
-	// TODO: 实际运行脚本并收集性能数据
-	// 这需要集成到 Sola 运行时中
-	
-	fmt.Println("警告: solaprof run 命令尚未完全实现")
-	fmt.Println("请使用 sola 命令运行脚本，并通过 --profile 选项收集性能数据")
-	
-	// 保存性能数据
+	// 保存性能数据，未指定输出文件时打印摘要
 	if *outputFlag != "" {
 		if err := saveProfile(profile, *outputFlag); err != nil {
 			return err
 		}
 		fmt.Printf("性能数据已保存到: %s\n", *outputFlag)
+	} else {
+		if profile.CPUData != nil {
+			printCPUSummary(profile.CPUData)
+		}
+		if profile.MemData != nil {
+			printMemorySummary(profile.MemData)
+		}
 	}
 	
+	if runErr != nil {
+		return fmt.Errorf("脚本执行失败: %w", runErr)
+	}
 	return nil
 }
 
+// cpuProfileData 由 CPU 分析结果生成性能数据
+// 自身时间和占比按采样估计：采样栈的最内层计入自身，栈中出现的函数计入总计
+func cpuProfileData(p *profiler.CPUProfile, duration, interval time.Duration) *CPUProfileData {
+	data := &CPUProfileData{
+		TotalSamples: int64(len(p.Samples)),
+		Duration:     duration.Nanoseconds(),
+	}
+	
+	self := make(map[string]int64)
+	total := make(map[string]int64)
+	stacks := make(map[string]*StackSample)
+	for _, sample := range p.Samples {
+		if len(sample.Stack) == 0 {
+			continue
+		}
+		self[sample.Stack[len(sample.Stack)-1]]++
+		seen := make(map[string]bool, len(sample.Stack))
+		for _, name := range sample.Stack {
+			if !seen[name] {
+				seen[name] = true
+				total[name]++
+			}
+		}
+		key := strings.Join(sample.Stack, ";")
+		if st, ok := stacks[key]; ok {
+			st.Count++
+		} else {
+			st = &StackSample{Frames: sample.Stack, Count: 1}
+			stacks[key] = st
+		}
+	}
+	for _, st := range stacks {
+		data.Stacks = append(data.Stacks, *st)
+	}
+	sort.Slice(data.Stacks, func(i, j int) bool {
+		return data.Stacks[i].Count > data.Stacks[j].Count
+	})
+	
+	for _, f := range p.Functions {
+		stat := FunctionStat{
+			Name:      f.Name,
+			TotalTime: f.TotalTime.Nanoseconds(),
+			SelfTime:  (time.Duration(self[f.Name]) * interval).Nanoseconds(),
+			CallCount: f.CallCount,
+		}
+		switch {
+		case data.TotalSamples > 0:
+			stat.TotalPct = float64(total[f.Name]) / float64(data.TotalSamples) * 100
+			stat.SelfPct = float64(self[f.Name]) / float64(data.TotalSamples) * 100
+		case duration > 0:
+			stat.TotalPct = float64(f.TotalTime) / float64(duration) * 100
+		}
+		data.Functions = append(data.Functions, stat)
+	}
+	return data
+}
+
+// memProfileData 由内存分析结果生成性能数据
+func memProfileData(p *profiler.MemoryProfile) *MemProfileData {
+	data := &MemProfileData{
+		TotalAllocated: p.TotalBytes,
+		CurrentLive:    p.LiveBytes,
+	}
+	for _, t := range p.TypeStats {
+		data.TotalFreed += t.FreedBytes
+		data.Allocations = append(data.Allocations, AllocationStat{
+			Type:       t.TypeName,
+			TotalBytes: t.TotalBytes,
+			LiveBytes:  t.LiveBytes,
+			TotalCount: t.AllocCount,
+			LiveCount:  t.LiveCount,
+		})
+	}
+	sort.Slice(data.Allocations, func(i, j int) bool {
+		return data.Allocations[i].TotalBytes > data.Allocations[j].TotalBytes
+	})
+	return data
+}
+
 // analyzeProfile 分析性能数据
 func analyzeProfile(args []string) error {
 	if len(args) == 0 {
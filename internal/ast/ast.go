@@ -1274,6 +1274,20 @@ func (s *TryStmt) End() token.Position {
 func (s *TryStmt) String() string { return "try {...} catch (...) {...}" }
 func (s *TryStmt) stmtNode()      {}
 
+// UsingStmt using 语句 (自动关闭资源)
+// using (Type $a = expr, $b := expr) { ... }
+// 离开语句块时 (包括抛出异常) 按声明的逆序调用每个非 null 资源的 close()
+type UsingStmt struct {
+	UsingToken token.Token
+	Resources  []*VarDeclStmt
+	Body       *BlockStmt
+}
+
+func (s *UsingStmt) Pos() token.Position { return s.UsingToken.Pos }
+func (s *UsingStmt) End() token.Position { return s.Body.End() }
+func (s *UsingStmt) String() string      { return "using (...) {...}" }
+func (s *UsingStmt) stmtNode()           {}
+
 // ThrowStmt throw 语句
 type ThrowStmt struct {
 	ThrowToken token.Token
@@ -2016,6 +2030,12 @@ func Walk(node Node, visitor Visitor) {
 			Walk(n.Finally.Body, visitor)
 		}
 
+	case *UsingStmt:
+		for _, res := range n.Resources {
+			Walk(res, visitor)
+		}
+		Walk(n.Body, visitor)
+
 	case *SwitchStmt:
 		Walk(n.Expr, visitor)
 		for _, switchCase := range n.Cases {
@@ -162,7 +162,7 @@ const (
 	// =========================================================================
 
 	// 协程创建和控制
-	OpGo    // 启动协程 [stack: closure -> goroutine_id] (语法糖, 不返回 Coroutine 对象)
+	OpGo    // 启动协程执行随后的调用表达式 (skip: u16) [stack: -> coroutine]
 	OpYield // 让出执行权（协作式调度）
 
 	OpCoroutineSpawn  // 创建协程 [stack: closure -> coroutine] (返回 Coroutine 对象)
@@ -188,28 +188,122 @@ const (
 	// 通道操作 (OOP 风格)
 	// =========================================================================
 
-	OpChanMake    // 创建通道 (capacity: u16) [stack: -> channel]
+	OpChanMake    // 创建通道 [stack: capacity -> channel]
 	OpChanSend    // 发送到通道 [stack: channel, value -> ]
 	OpChanRecv    // 从通道接收 [stack: channel -> value]
 	OpChanClose   // 关闭通道 [stack: channel -> ]
 	OpChanTrySend // 非阻塞发送 [stack: channel, value -> bool]
-	OpChanTryRecv // 非阻塞接收 [stack: channel -> value, bool]
+	OpChanTryRecv // 非阻塞接收 [stack: channel -> value|null]
 
 	// 通道状态查询
 	OpChanLen      // 获取通道缓冲区长度 [stack: channel -> int]
 	OpChanCap      // 获取通道容量 [stack: channel -> int]
 	OpChanIsClosed // 检查通道是否已关闭 [stack: channel -> bool]
 
-	// select 操作 (OOP 风格: Channel::select())
-	OpSelectStart   // 开始 select (caseCount: u8)
-	OpSelectCase    // 添加 case (isRecv: u8, jumpOffset: i16)
-	OpSelectDefault // 添加 default (jumpOffset: i16)
-	OpSelectWait    // 等待 select 完成，返回匹配的 case 索引
+	// select 语句
+	OpSelectStart   // 执行 select (caseCount: u8)，随后是 select 表 [stack: case 操作数 -> (接收值)]
+	OpSelectCase    // select 表: case (isRecv: u8, jumpOffset: i16)
+	OpSelectDefault // select 表: default (jumpOffset: i16)
+	OpSelectWait    // select 表结束，跳转偏移相对于此指令之后
+
+	// =========================================================================
+	// 尾调用 (无法复用栈帧时退化为普通调用，由随后的 OpReturn 返回结果)
+	// =========================================================================
+
+	OpTailCallStatic // 静态方法尾调用 (classIndex: u16, nameIndex: u16, argCount: u8)
+	OpTailCallMethod // 方法尾调用 (nameIndex: u16, argCount: u8)
+
+	// =========================================================================
+	// 超级指令
+	// 由优化器融合常见指令序列得到 (见 optimizer.go)。只改写序列第一条指令的
+	// 操作码，序列其余字节保持不变：跳转偏移、行号不受影响，跳入序列内部的
+	// 跳转仍然逐条执行原指令。操作数从原指令的位置读取。
+	// =========================================================================
+
+	OpAddLocals        // LOAD_LOCAL a; LOAD_LOCAL b; ADD
+	OpLtLocalConstJump // LOAD_LOCAL a; PUSH k; LT; JUMP_IF_FALSE offset
+	OpGetLocalField    // LOAD_LOCAL a; GET_FIELD name
+	OpIncLocal         // LOAD_LOCAL a; ONE|PUSH k; ADD; STORE_LOCAL a; POP
+	OpIncLocalPost     // LOAD_LOCAL a; DUP; ONE; ADD; STORE_LOCAL a; POP; POP
 
 	// 终止
 	OpHalt // 停止执行
+
+	// =========================================================================
+	// 快速指令
+	// 运行时由 quickening 按类型 profile 就地改写通用指令得到，操作数格式与对应的
+	// 通用指令相同。执行前检查类型守卫，失败时改写回通用指令 (去优化)。
+	// 快速指令不属于字节码文件格式，编译器不会生成，验证器拒绝它们。
+	// =========================================================================
+
+	OpAddInt    // OpAdd: int + int
+	OpAddFloat  // OpAdd: float + float
+	OpAddString // OpAdd: string + string
+	OpSubInt    // OpSub: int - int
+	OpSubFloat  // OpSub: float - float
+	OpMulInt    // OpMul: int * int
+	OpMulFloat  // OpMul: float * float
+	OpLtInt     // OpLt: int < int
+	OpLtFloat   // OpLt: float < float
+	OpLeInt     // OpLe: int <= int
+	OpLeFloat   // OpLe: float <= float
+	OpGtInt     // OpGt: int > int
+	OpGtFloat   // OpGt: float > float
+	OpGeInt     // OpGe: int >= int
+	OpGeFloat   // OpGe: float >= float
+
+	OpArrayGetList // OpArrayGet: 动态数组 + int 索引
+	OpGetFieldMono // OpGetField: 单态接收者，直接读取内联缓存中的字段槽位 (nameIndex: u16)
 )
 
+// genericOps 快速指令 -> 对应的通用指令
+var genericOps = map[OpCode]OpCode{
+	OpAddInt:    OpAdd,
+	OpAddFloat:  OpAdd,
+	OpAddString: OpAdd,
+	OpSubInt:    OpSub,
+	OpSubFloat:  OpSub,
+	OpMulInt:    OpMul,
+	OpMulFloat:  OpMul,
+	OpLtInt:     OpLt,
+	OpLtFloat:   OpLt,
+	OpLeInt:     OpLe,
+	OpLeFloat:   OpLe,
+	OpGtInt:     OpGt,
+	OpGtFloat:   OpGt,
+	OpGeInt:     OpGe,
+	OpGeFloat:   OpGe,
+
+	OpArrayGetList: OpArrayGet,
+	OpGetFieldMono: OpGetField,
+
+	// 超级指令 -> 序列的第一条指令
+	OpAddLocals:        OpLoadLocal,
+	OpLtLocalConstJump: OpLoadLocal,
+	OpGetLocalField:    OpLoadLocal,
+	OpIncLocal:         OpLoadLocal,
+	OpIncLocalPost:     OpLoadLocal,
+}
+
+// IsQuickened 是否是运行时改写得到的快速指令
+func (op OpCode) IsQuickened() bool {
+	return op > OpHalt
+}
+
+// IsFused 是否是优化器融合得到的超级指令
+func (op OpCode) IsFused() bool {
+	return op >= OpAddLocals && op <= OpIncLocalPost
+}
+
+// Generic 返回快速指令对应的通用指令、超级指令融合序列的第一条指令，其他指令返回自身
+// 超级指令之后的字节仍是原序列，按第一条指令解析即可得到原指令流
+func (op OpCode) Generic() OpCode {
+	if generic, ok := genericOps[op]; ok {
+		return generic
+	}
+	return op
+}
+
 var opNames = map[OpCode]string{
 	OpPush:        "PUSH",
 	OpPop:         "POP",
@@ -341,7 +435,37 @@ var opNames = map[OpCode]string{
 	OpSelectDefault: "SELECT_DEFAULT",
 	OpSelectWait:    "SELECT_WAIT",
 
+	// 尾调用
+	OpTailCallStatic: "TAIL_CALL_STATIC",
+	OpTailCallMethod: "TAIL_CALL_METHOD",
+
+	// 超级指令
+	OpAddLocals:        "ADD_LOCALS",
+	OpLtLocalConstJump: "LT_LOCAL_CONST_JUMP",
+	OpGetLocalField:    "GET_LOCAL_FIELD",
+	OpIncLocal:         "INC_LOCAL",
+	OpIncLocalPost:     "INC_LOCAL_POST",
+
 	OpHalt: "HALT",
+
+	// 快速指令
+	OpAddInt:       "ADD_INT",
+	OpAddFloat:     "ADD_FLOAT",
+	OpAddString:    "ADD_STRING",
+	OpSubInt:       "SUB_INT",
+	OpSubFloat:     "SUB_FLOAT",
+	OpMulInt:       "MUL_INT",
+	OpMulFloat:     "MUL_FLOAT",
+	OpLtInt:        "LT_INT",
+	OpLtFloat:      "LT_FLOAT",
+	OpLeInt:        "LE_INT",
+	OpLeFloat:      "LE_FLOAT",
+	OpGtInt:        "GT_INT",
+	OpGtFloat:      "GT_FLOAT",
+	OpGeInt:        "GE_INT",
+	OpGeFloat:      "GE_FLOAT",
+	OpArrayGetList: "ARRAY_GET_LIST",
+	OpGetFieldMono: "GET_FIELD_MONO",
 }
 
 func (op OpCode) String() string {
@@ -363,6 +487,7 @@ type Chunk struct {
 	Code      []byte     // 字节码
 	Constants []Value    // 常量池
 	Lines     []int      // 行号信息 (用于错误报告)
+	Debug     *DebugInfo // 调试信息 (由编译器生成，反序列化得到的字节码块为 nil)
 }
 
 // ============================================================================
@@ -389,6 +514,7 @@ func NewChunk() *Chunk {
 	chunk.Code = chunk.Code[:0]
 	chunk.Constants = chunk.Constants[:0]
 	chunk.Lines = chunk.Lines[:0]
+	chunk.Debug = nil
 	return chunk
 }
 
@@ -403,6 +529,7 @@ func (c *Chunk) Release() {
 	c.Code = c.Code[:0]
 	c.Constants = c.Constants[:0]
 	c.Lines = c.Lines[:0]
+	c.Debug = nil
 	chunkPool.Put(c)
 }
 
@@ -413,6 +540,7 @@ func (c *Chunk) Clone() *Chunk {
 	clone.Code = append(clone.Code, c.Code...)
 	clone.Constants = append(clone.Constants, c.Constants...)
 	clone.Lines = append(clone.Lines, c.Lines...)
+	clone.Debug = c.Debug
 	return clone
 }
 
@@ -507,19 +635,40 @@ func (c *Chunk) disassembleInstruction(sb *strings.Builder, offset int) int {
 	
 	switch op {
 	case OpPush, OpLoadLocal, OpStoreLocal, OpLoadGlobal, OpStoreGlobal,
-		OpNewObject, OpGetField, OpSetField, OpNewArray, OpNewFixedArray, OpNewMap,
+		OpNewObject, OpGetField, OpGetFieldMono, OpSetField, OpNewArray, OpNewMap,
 		OpCheckType, OpCast, OpCastSafe, OpSuperArrayNew:
 		return c.constantInstruction(sb, op, offset)
+	case OpNewFixedArray:
+		fmt.Fprintf(sb, "%-16s cap=%d init=%d\n", op, c.ReadU16(offset+1), c.ReadU16(offset+3))
+		return offset + 5
+	case OpNativeArrayNew, OpNativeArrayInit:
+		fmt.Fprintf(sb, "%-16s elem=%d n=%d\n", op, c.Code[offset+1], c.ReadU16(offset+2))
+		return offset + 4
+	case OpNewBytes:
+		fmt.Fprintf(sb, "%-16s %4d\n", op, c.ReadU16(offset+1))
+		return offset + 3
 	case OpJump, OpJumpIfFalse, OpJumpIfTrue:
 		return c.jumpInstruction(sb, op, 1, offset)
 	case OpLoop:
 		return c.jumpInstruction(sb, op, -1, offset)
-	case OpCall, OpTailCall:
+	case OpGo:
+		return c.jumpInstruction(sb, op, 1, offset)
+	case OpCall, OpTailCall, OpCoroutineAwait, OpSelectStart:
 		return c.byteInstruction(sb, op, offset)
-	case OpCallMethod, OpCallStatic:
+	case OpSelectCase:
+		fmt.Fprintf(sb, "%-16s recv=%d -> +%d\n", op, c.Code[offset+1], c.ReadI16(offset+2))
+		return offset + 4
+	case OpSelectDefault:
+		fmt.Fprintf(sb, "%-16s -> +%d\n", op, c.ReadI16(offset+1))
+		return offset + 3
+	case OpCallMethod, OpTailCallMethod:
 		return c.invokeInstruction(sb, op, offset)
+	case OpGetStatic, OpSetStatic, OpCallStatic, OpTailCallStatic:
+		return c.staticInstruction(sb, op, offset)
 	case OpEnterTry:
 		return c.enterTryInstruction(sb, offset)
+	case OpAddLocals, OpLtLocalConstJump, OpGetLocalField, OpIncLocal, OpIncLocalPost:
+		return c.fusedInstruction(sb, op, offset)
 	case OpEnterCatch:
 		return c.constantInstruction(sb, op, offset)
 	case OpClosure:
@@ -560,6 +709,27 @@ func (c *Chunk) invokeInstruction(sb *strings.Builder, op OpCode, offset int) in
 	return offset + 4
 }
 
+// staticInstruction 反汇编静态成员指令: classIdx(u16) + nameIdx(u16) [+ argCount(u8)]
+func (c *Chunk) staticInstruction(sb *strings.Builder, op OpCode, offset int) int {
+	classIdx := c.ReadU16(offset + 1)
+	nameIdx := c.ReadU16(offset + 3)
+	fmt.Fprintf(sb, "%-16s %4d %4d '%s::%s'", op, classIdx, nameIdx,
+		c.Constants[classIdx].String(), c.Constants[nameIdx].String())
+	if op == OpCallStatic || op == OpTailCallStatic {
+		fmt.Fprintf(sb, " (%d args)\n", c.Code[offset+5])
+		return offset + 6
+	}
+	sb.WriteString("\n")
+	return offset + 5
+}
+
+// fusedInstruction 反汇编超级指令: 显示第一条指令的局部变量和序列长度，
+// 序列其余指令保持原样，随后逐条反汇编
+func (c *Chunk) fusedInstruction(sb *strings.Builder, op OpCode, offset int) int {
+	fmt.Fprintf(sb, "%-16s %4d (%d bytes)\n", op, c.ReadU16(offset+1), c.FusedLength(offset))
+	return offset + 3
+}
+
 func (c *Chunk) enterTryInstruction(sb *strings.Builder, offset int) int {
 	catchCount := c.Code[offset+1]
 	finallyOffset := c.ReadI16(offset + 2)
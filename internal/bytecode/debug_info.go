@@ -2,6 +2,7 @@ package bytecode
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -31,6 +32,10 @@ type DebugInfo struct {
 	
 	// 断点信息
 	Breakpoints []int // 可设置断点的字节码偏移
+
+	// 按偏移递增添加的行号映射位置，GetLine 在其中二分查找
+	// 映射不是按偏移递增添加的 (或 LineMap 被直接修改) 时退化为线性查找
+	lineStarts []int
 }
 
 // VariableInfo 变量调试信息
@@ -38,6 +43,7 @@ type VariableInfo struct {
 	Name       string // 变量名（不含 $）
 	Type       string // 变量类型
 	Slot       int    // 局部变量槽位（-1 表示全局变量）
+	Global     int    // 全局变量在虚拟机全局表中的索引（Slot 为 -1 时有效）
 	ScopeID    int    // 所属作用域 ID
 	StartPC    int    // 变量生命周期开始的字节码偏移
 	EndPC      int    // 变量生命周期结束的字节码偏移
@@ -80,8 +86,28 @@ func NewDebugInfo(sourceFile string) *DebugInfo {
 	}
 }
 
+// NewLineDebugInfo 由字节码块的行号信息创建调试信息
+// 每个行号变化的位置记录一个映射，并作为可设置断点的位置
+func NewLineDebugInfo(sourceFile string, chunk *Chunk) *DebugInfo {
+	d := NewDebugInfo(sourceFile)
+	prev := 0
+	for pc, line := range chunk.Lines {
+		if line > 0 && line != prev {
+			d.AddLineMapping(pc, line)
+			d.AddBreakpoint(pc)
+		}
+		prev = line
+	}
+	return d
+}
+
 // AddLineMapping 添加行号映射
 func (d *DebugInfo) AddLineMapping(pc, line int) {
+	if _, ok := d.LineMap[pc]; !ok && len(d.lineStarts) == len(d.LineMap) {
+		if n := len(d.lineStarts); n == 0 || pc > d.lineStarts[n-1] {
+			d.lineStarts = append(d.lineStarts, pc)
+		}
+	}
 	d.LineMap[pc] = line
 }
 
@@ -115,6 +141,13 @@ func (d *DebugInfo) GetLine(pc int) int {
 	if line, ok := d.LineMap[pc]; ok {
 		return line
 	}
+	if len(d.lineStarts) == len(d.LineMap) {
+		i := sort.SearchInts(d.lineStarts, pc+1)
+		if i == 0 {
+			return 0
+		}
+		return d.LineMap[d.lineStarts[i-1]]
+	}
 	// 查找最近的前一个映射
 	nearestPC := -1
 	nearestLine := 0
@@ -308,6 +308,24 @@ func (d *Deserializer) readValue() (Value, error) {
 			return NullValue, err
 		}
 		return NewString(d.getString(idx)), nil
+	case ConstArray:
+		n, err := d.readU32()
+		if err != nil {
+			return NullValue, err
+		}
+		arr := make([]Value, n)
+		for i := range arr {
+			if arr[i], err = d.readValue(); err != nil {
+				return NullValue, err
+			}
+		}
+		return NewArray(arr), nil
+	case ConstFunc:
+		fn, err := d.readFunction()
+		if err != nil {
+			return NullValue, err
+		}
+		return NewFunc(fn), nil
 	default:
 		return NullValue, &FormatError{fmt.Sprintf("unknown constant type: %d", typ)}
 	}
@@ -499,6 +517,14 @@ func (d *Deserializer) readClass() (*Class, error) {
 		class.AddMethod(method)
 	}
 
+	// 静态初始化器
+	if flags&ClassFlagStaticInit != 0 {
+		class.StaticInit, err = d.readMethod()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return class, nil
 }
 
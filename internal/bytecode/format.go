@@ -13,7 +13,7 @@ const (
 
 	// 版本号
 	MajorVersion uint8 = 1
-	MinorVersion uint8 = 0
+	MinorVersion uint8 = 4 // 1.1: 类静态初始化器; 1.2: 尾调用指令; 1.3: 超级指令; 1.4: 函数常量
 )
 
 // 常量池类型标记
@@ -23,6 +23,8 @@ const (
 	ConstInt    uint8 = 2
 	ConstFloat  uint8 = 3
 	ConstString uint8 = 4
+	ConstArray  uint8 = 5 // 元素数量 (u32) + 元素
+	ConstFunc   uint8 = 6 // 嵌套函数 (闭包原型)，紧随完整的函数定义
 )
 
 // 函数标志位
@@ -33,10 +35,11 @@ const (
 
 // 类标志位
 const (
-	ClassFlagAbstract  uint8 = 1 << 0 // 抽象类
-	ClassFlagInterface uint8 = 1 << 1 // 接口
-	ClassFlagFinal     uint8 = 1 << 2 // final 类
-	ClassFlagAttribute uint8 = 1 << 3 // 注解类（有 @Attribute 标记）
+	ClassFlagAbstract   uint8 = 1 << 0 // 抽象类
+	ClassFlagInterface  uint8 = 1 << 1 // 接口
+	ClassFlagFinal      uint8 = 1 << 2 // final 类
+	ClassFlagAttribute  uint8 = 1 << 3 // 注解类（有 @Attribute 标记）
+	ClassFlagStaticInit uint8 = 1 << 4 // 有静态初始化器（紧随方法表之后）
 )
 
 // 方法标志位
@@ -57,9 +60,42 @@ const (
 // 文件头结构大小
 const HeaderSize = 24
 
+// ============================================================================
+// 堆快照映像文件格式
+// ============================================================================
+//
+// 映像 (sola build --image) 保存构建时初始化完毕的程序状态：类 (含父类链接、
+// 接口虚表)、枚举、静态变量及其引用的堆对象。文件头记录字节码版本、映像格式
+// 版本和指令集指纹，任何一项与当前 VM 不一致的映像都会被拒绝，需要重新构建。
 
+const (
+	// ImageFileExtension 映像文件后缀
+	ImageFileExtension = ".image"
 
+	// ImageMagicNumber 映像文件魔数 "SOLI" in ASCII
+	ImageMagicNumber uint32 = 0x534F4C49
 
+	// ImageVersion 映像格式版本，映像布局变化时递增
+	ImageVersion uint16 = 1
 
+	// ImageHeaderSize 映像文件头大小
+	// magic(4) + major(1) + minor(1) + imageVersion(2) + opcodeFingerprint(4) + bodyOffset(4)
+	ImageHeaderSize = 16
+)
 
+// 映像中的值标记 (与常量池类型标记共用编号空间)
+const (
+	ConstHeapRef   uint8 = 0x10 // 堆对象引用：堆表下标 (u32)
+	ConstEnumValue uint8 = 0x11 // 枚举值：枚举名 + 成员名 + 值
+)
 
+// 映像堆对象类型
+const (
+	HeapArray       uint8 = 1
+	HeapMap         uint8 = 2
+	HeapSuperArray  uint8 = 3
+	HeapObject      uint8 = 4
+	HeapBytes       uint8 = 5
+	HeapFixedArray  uint8 = 6
+	HeapNativeArray uint8 = 7
+)
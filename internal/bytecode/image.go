@@ -0,0 +1,1018 @@
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"unsafe"
+)
+
+// ============================================================================
+// 堆快照映像
+// ============================================================================
+//
+// 映像在文件头之后依次保存：
+//   - 字符串池：名称、字符串常量和字符串值
+//   - 类：按完整名称排序，编码与 .solac 相同，之后是名称 -> 类下标的注册表
+//   - 链接：每个类的父类下标、final 属性和方法、接口虚表
+//   - 枚举及其注册表、入口类下标
+//   - 堆：静态变量可达的数组、Map、对象等，先写所有对象的外壳 (类型和大小)
+//     再写内容，读取时先分配外壳，共享引用和循环引用得以保留
+//   - 静态变量：构建时已初始化的类 -> 变量名 -> 值
+//
+// 字符串池在正文写完后才能确定，写在正文之前。
+// 闭包、通道、协程、迭代器和宿主对象引用了进程内状态，不能保存到映像中。
+
+// noIndex 表示不存在的类下标 (没有父类、没有入口类)
+const noIndex = ^uint32(0)
+
+// ImageFile 堆快照映像的内容
+type ImageFile struct {
+	SourceFile string
+	Classes    map[string]*Class           // 名称 (完整名称和短名) -> 类
+	Enums      map[string]*Enum            // 名称 -> 枚举
+	Entry      *Class                      // 含静态 main() 方法的入口类 (可为 nil)
+	Statics    map[*Class]map[string]Value // 构建时已初始化的类 -> 静态存储
+}
+
+// OpcodeFingerprint 返回指令集指纹：字节码文件中可出现的所有指令编号与名称的 CRC32
+// 指令的增删或重新编号都会改变指纹，使旧映像失效
+func OpcodeFingerprint() uint32 {
+	h := crc32.NewIEEE()
+	for op := OpCode(0); op <= OpHalt; op++ {
+		fmt.Fprintf(h, "%d:%s;", op, op)
+	}
+	return h.Sum32()
+}
+
+// ============================================================================
+// 编码
+// ============================================================================
+
+// imageEncoder 映像编码器，字符串池复用 Serializer
+type imageEncoder struct {
+	s          *Serializer
+	classes    []*Class
+	classIndex map[*Class]uint32
+	methodRefs map[*Method][2]uint32 // 方法 -> (类下标, 在 classMethods 中的序号)
+	heap       []Value
+	heapIndex  map[unsafe.Pointer]uint32
+}
+
+// EncodeImage 把映像编码为字节数组
+// 静态变量引用了不能保存的值 (闭包、通道、宿主对象等) 时返回错误
+func EncodeImage(img *ImageFile) ([]byte, error) {
+	e := &imageEncoder{
+		s:          NewSerializer(),
+		classIndex: make(map[*Class]uint32),
+		methodRefs: make(map[*Method][2]uint32),
+		heapIndex:  make(map[unsafe.Pointer]uint32),
+	}
+	body := new(bytes.Buffer)
+	if err := e.writeBody(body, img); err != nil {
+		return nil, err
+	}
+	pool := e.s.serializeStringPool()
+
+	out := new(bytes.Buffer)
+	binary.Write(out, binary.BigEndian, ImageMagicNumber)
+	out.WriteByte(MajorVersion)
+	out.WriteByte(MinorVersion)
+	binary.Write(out, binary.BigEndian, ImageVersion)
+	binary.Write(out, binary.BigEndian, OpcodeFingerprint())
+	binary.Write(out, binary.BigEndian, uint32(ImageHeaderSize+len(pool)))
+	out.Write(pool)
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// writeBody 写入映像正文
+func (e *imageEncoder) writeBody(buf *bytes.Buffer, img *ImageFile) error {
+	e.writeString(buf, img.SourceFile)
+
+	// 类 (含父类)，按完整名称排序
+	for _, class := range img.Classes {
+		e.addClass(class)
+	}
+	if img.Entry != nil {
+		e.addClass(img.Entry)
+	}
+	sort.SliceStable(e.classes, func(i, j int) bool {
+		return e.classes[i].FullName() < e.classes[j].FullName()
+	})
+	for i, class := range e.classes {
+		e.classIndex[class] = uint32(i)
+		for j, method := range classMethods(class) {
+			e.methodRefs[method] = [2]uint32{uint32(i), uint32(j)}
+		}
+	}
+
+	// 静态变量可达的堆对象
+	owners := make([]*Class, 0, len(img.Statics))
+	for class := range img.Statics {
+		if _, ok := e.classIndex[class]; !ok {
+			return fmt.Errorf("class %s is not part of the image", class.FullName())
+		}
+		owners = append(owners, class)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return e.classIndex[owners[i]] < e.classIndex[owners[j]]
+	})
+	for _, class := range owners {
+		for _, name := range sortedKeys(img.Statics[class]) {
+			if err := e.collect(img.Statics[class][name]); err != nil {
+				return fmt.Errorf("static %s::$%s: %w", class.FullName(), name, err)
+			}
+		}
+	}
+
+	binary.Write(buf, binary.BigEndian, uint32(len(e.classes)))
+	for _, class := range e.classes {
+		e.s.writeClassTo(buf, class)
+	}
+	names := sortedKeys(img.Classes)
+	binary.Write(buf, binary.BigEndian, uint32(len(names)))
+	for _, name := range names {
+		e.writeString(buf, name)
+		binary.Write(buf, binary.BigEndian, e.classIndex[img.Classes[name]])
+	}
+	for _, class := range e.classes {
+		if err := e.writeLinks(buf, class); err != nil {
+			return err
+		}
+	}
+
+	// 枚举
+	var enums []*Enum
+	enumIndex := make(map[*Enum]uint32)
+	for _, enum := range img.Enums {
+		if _, ok := enumIndex[enum]; !ok {
+			enumIndex[enum] = 0
+			enums = append(enums, enum)
+		}
+	}
+	sort.SliceStable(enums, func(i, j int) bool { return enums[i].Name < enums[j].Name })
+	binary.Write(buf, binary.BigEndian, uint32(len(enums)))
+	for i, enum := range enums {
+		enumIndex[enum] = uint32(i)
+		e.s.writeEnumTo(buf, enum)
+	}
+	names = sortedKeys(img.Enums)
+	binary.Write(buf, binary.BigEndian, uint32(len(names)))
+	for _, name := range names {
+		e.writeString(buf, name)
+		binary.Write(buf, binary.BigEndian, enumIndex[img.Enums[name]])
+	}
+
+	// 入口类
+	e.writeClassRef(buf, img.Entry)
+
+	e.writeHeap(buf)
+
+	// 静态变量
+	binary.Write(buf, binary.BigEndian, uint32(len(owners)))
+	for _, class := range owners {
+		values := img.Statics[class]
+		binary.Write(buf, binary.BigEndian, e.classIndex[class])
+		binary.Write(buf, binary.BigEndian, uint16(len(values)))
+		for _, name := range sortedKeys(values) {
+			e.writeString(buf, name)
+			e.writeValue(buf, values[name])
+		}
+	}
+	return nil
+}
+
+// addClass 把类及其父类加入类表
+func (e *imageEncoder) addClass(class *Class) {
+	for ; class != nil; class = class.Parent {
+		if _, ok := e.classIndex[class]; ok {
+			return
+		}
+		e.classIndex[class] = 0 // 下标在排序后确定
+		e.classes = append(e.classes, class)
+	}
+}
+
+// writeLinks 写入类的链接信息：父类、final 属性和方法、接口虚表
+func (e *imageEncoder) writeLinks(buf *bytes.Buffer, class *Class) error {
+	e.writeClassRef(buf, class.Parent)
+
+	var finalProps []string
+	for _, name := range sortedKeys(class.PropFinal) {
+		if class.PropFinal[name] {
+			finalProps = append(finalProps, name)
+		}
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(finalProps)))
+	for _, name := range finalProps {
+		e.writeString(buf, name)
+	}
+
+	var finalMethods []uint16
+	for i, method := range classMethods(class) {
+		if method.IsFinal {
+			finalMethods = append(finalMethods, uint16(i))
+		}
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(finalMethods)))
+	for _, i := range finalMethods {
+		binary.Write(buf, binary.BigEndian, i)
+	}
+
+	ifaces := sortedKeys(class.VTables)
+	binary.Write(buf, binary.BigEndian, uint16(len(ifaces)))
+	for _, name := range ifaces {
+		vt := class.VTables[name]
+		e.writeString(buf, name)
+		e.writeString(buf, vt.InterfaceName)
+		e.writeString(buf, vt.ClassName)
+		binary.Write(buf, binary.BigEndian, uint16(len(vt.Methods)))
+		for _, entry := range vt.Methods {
+			binary.Write(buf, binary.BigEndian, uint16(entry.MethodIndex))
+			e.writeString(buf, entry.MethodName)
+			if entry.ImplMethod == nil {
+				binary.Write(buf, binary.BigEndian, noIndex)
+				continue
+			}
+			ref, ok := e.methodRefs[entry.ImplMethod]
+			if !ok {
+				return fmt.Errorf("vtable %s of class %s refers to method %s outside the image", name, class.FullName(), entry.MethodName)
+			}
+			binary.Write(buf, binary.BigEndian, ref[0])
+			binary.Write(buf, binary.BigEndian, ref[1])
+		}
+	}
+	return nil
+}
+
+// collect 把值可达的所有堆对象加入堆表，遇到不能保存的值时返回错误
+func (e *imageEncoder) collect(v Value) error {
+	work := []Value{v}
+	for len(work) > 0 {
+		v := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		switch v.Type() {
+		case ValNull, ValBool, ValInt, ValFloat, ValString, ValFunc:
+			continue
+		case ValEnum:
+			if ev := v.AsEnumValue(); ev != nil {
+				work = append(work, ev.Value)
+			}
+			continue
+		}
+		if !isHeapValue(v) {
+			return fmt.Errorf("%s cannot be stored in an image", unsupportedValueName(v))
+		}
+		if _, ok := e.heapIndex[v.ptr]; ok {
+			continue
+		}
+		e.heapIndex[v.ptr] = uint32(len(e.heap))
+		e.heap = append(e.heap, v)
+
+		switch v.Type() {
+		case ValArray:
+			work = append(work, v.AsArray()...)
+		case ValMap:
+			for k, val := range v.AsMap() {
+				work = append(work, k.Value(), val)
+			}
+		case ValSuperArray:
+			sa := v.AsSuperArray()
+			work = append(work, sa.Keys()...)
+			work = append(work, sa.Values()...)
+		case ValObject:
+			obj := v.AsObject()
+			if obj.Native != nil {
+				return fmt.Errorf("object of class %s wraps a host value and cannot be stored in an image", obj.Class.FullName())
+			}
+			if _, ok := e.classIndex[obj.Class]; !ok {
+				return fmt.Errorf("class %s is not part of the image", obj.Class.FullName())
+			}
+			obj.RangeFields(func(_ string, val Value) bool {
+				work = append(work, val)
+				return true
+			})
+		case ValFixedArray:
+			work = append(work, v.AsFixedArray().Elements...)
+		case ValNativeArray:
+			arr := v.AsNativeArray()
+			for i := 0; i < arr.Len(); i++ {
+				work = append(work, arr.Get(i))
+			}
+		}
+	}
+	return nil
+}
+
+// isHeapValue 是否是保存在堆表中、按引用共享的值
+func isHeapValue(v Value) bool {
+	if v.ptr == nil {
+		return false
+	}
+	switch v.Type() {
+	case ValArray, ValMap, ValSuperArray, ValBytes, ValFixedArray, ValNativeArray:
+		return true
+	case ValObject:
+		return v.AsObject().Class != nil
+	}
+	return false
+}
+
+// unsupportedValueName 不能保存到映像中的值的描述
+func unsupportedValueName(v Value) string {
+	switch v.Type() {
+	case ValClosure:
+		return "closure"
+	case ValClass:
+		return "class reference"
+	case ValMethod:
+		return "bound method"
+	case ValIterator:
+		return "iterator"
+	case ValException:
+		return "exception"
+	case ValStringBuilder:
+		return "string builder"
+	case ValChannel:
+		return "channel"
+	case ValGoroutine:
+		return "coroutine"
+	case ValWeakRef:
+		return "weak reference"
+	case ValWeakMap:
+		return "weak map"
+	case ValObject:
+		return "object without class"
+	}
+	return fmt.Sprintf("value of type %d", v.Type())
+}
+
+// writeHeap 写入堆表：先写所有对象的外壳，再写内容
+func (e *imageEncoder) writeHeap(buf *bytes.Buffer) {
+	binary.Write(buf, binary.BigEndian, uint32(len(e.heap)))
+	for _, v := range e.heap {
+		switch v.Type() {
+		case ValArray:
+			buf.WriteByte(HeapArray)
+			binary.Write(buf, binary.BigEndian, uint32(len(v.AsArray())))
+		case ValMap:
+			buf.WriteByte(HeapMap)
+		case ValSuperArray:
+			buf.WriteByte(HeapSuperArray)
+		case ValObject:
+			buf.WriteByte(HeapObject)
+			binary.Write(buf, binary.BigEndian, e.classIndex[v.AsObject().Class])
+		case ValBytes:
+			data := v.AsBytes()
+			buf.WriteByte(HeapBytes)
+			binary.Write(buf, binary.BigEndian, uint32(len(data)))
+			buf.Write(data)
+		case ValFixedArray:
+			fa := v.AsFixedArray()
+			buf.WriteByte(HeapFixedArray)
+			binary.Write(buf, binary.BigEndian, uint32(fa.Capacity))
+			binary.Write(buf, binary.BigEndian, uint32(len(fa.Elements)))
+		case ValNativeArray:
+			arr := v.AsNativeArray()
+			buf.WriteByte(HeapNativeArray)
+			buf.WriteByte(uint8(arr.ElementType))
+			binary.Write(buf, binary.BigEndian, uint32(arr.Length))
+		}
+	}
+
+	for _, v := range e.heap {
+		switch v.Type() {
+		case ValArray:
+			for _, elem := range v.AsArray() {
+				e.writeValue(buf, elem)
+			}
+		case ValMap:
+			m := v.AsMap()
+			binary.Write(buf, binary.BigEndian, uint32(len(m)))
+			for k, val := range m {
+				e.writeValue(buf, k.Value())
+				e.writeValue(buf, val)
+			}
+		case ValSuperArray:
+			sa := v.AsSuperArray()
+			keys, values := sa.Keys(), sa.Values()
+			binary.Write(buf, binary.BigEndian, sa.NextInt)
+			binary.Write(buf, binary.BigEndian, uint32(len(keys)))
+			for i := range keys {
+				e.writeValue(buf, keys[i])
+				e.writeValue(buf, values[i])
+			}
+		case ValObject:
+			obj := v.AsObject()
+			binary.Write(buf, binary.BigEndian, uint16(len(obj.TypeArgs)))
+			for _, arg := range obj.TypeArgs {
+				e.writeString(buf, arg)
+			}
+			var names []string
+			var values []Value
+			obj.RangeFields(func(name string, val Value) bool {
+				names = append(names, name)
+				values = append(values, val)
+				return true
+			})
+			binary.Write(buf, binary.BigEndian, uint32(len(names)))
+			for i, name := range names {
+				e.writeString(buf, name)
+				e.writeValue(buf, values[i])
+			}
+		case ValFixedArray:
+			for _, elem := range v.AsFixedArray().Elements {
+				e.writeValue(buf, elem)
+			}
+		case ValNativeArray:
+			arr := v.AsNativeArray()
+			for i := 0; i < arr.Len(); i++ {
+				e.writeValue(buf, arr.Get(i))
+			}
+		}
+	}
+}
+
+// writeValue 写入值：堆对象写为堆表引用，枚举值内联，其余按常量编码
+func (e *imageEncoder) writeValue(buf *bytes.Buffer, v Value) {
+	if isHeapValue(v) {
+		buf.WriteByte(ConstHeapRef)
+		binary.Write(buf, binary.BigEndian, e.heapIndex[v.ptr])
+		return
+	}
+	if ev := v.AsEnumValue(); ev != nil {
+		buf.WriteByte(ConstEnumValue)
+		e.writeString(buf, ev.EnumName)
+		e.writeString(buf, ev.CaseName)
+		e.writeValue(buf, ev.Value)
+		return
+	}
+	e.s.writeValue(buf, v)
+}
+
+// writeClassRef 写入类下标，nil 写为 noIndex
+func (e *imageEncoder) writeClassRef(buf *bytes.Buffer, class *Class) {
+	if class == nil {
+		binary.Write(buf, binary.BigEndian, noIndex)
+		return
+	}
+	binary.Write(buf, binary.BigEndian, e.classIndex[class])
+}
+
+// writeString 写入字符串池下标
+func (e *imageEncoder) writeString(buf *bytes.Buffer, str string) {
+	binary.Write(buf, binary.BigEndian, e.s.addString(str))
+}
+
+// sortedKeys 返回按字典序排序的 map 键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ============================================================================
+// 解码
+// ============================================================================
+
+// imageDecoder 映像解码器，类、方法和常量的读取复用 Deserializer
+type imageDecoder struct {
+	*Deserializer
+	classes []*Class
+	heap    []Value
+}
+
+// ValidateImageHeader 验证映像文件头
+// 映像由不同字节码版本、映像格式或指令集的 VM 构建时返回 *FormatError，需要重新构建
+func ValidateImageHeader(data []byte) error {
+	if len(data) < ImageHeaderSize {
+		return &FormatError{"file too small"}
+	}
+	if binary.BigEndian.Uint32(data[0:4]) != ImageMagicNumber {
+		return &FormatError{"invalid magic number, not a Sola image"}
+	}
+	major, minor := data[4], data[5]
+	version := binary.BigEndian.Uint16(data[6:8])
+	if major != MajorVersion || minor != MinorVersion || version != ImageVersion {
+		return &FormatError{fmt.Sprintf("stale image: built by v%d.%d (image format %d), VM is v%d.%d (image format %d); rebuild it with sola build --image",
+			major, minor, version, MajorVersion, MinorVersion, ImageVersion)}
+	}
+	if binary.BigEndian.Uint32(data[8:12]) != OpcodeFingerprint() {
+		return &FormatError{"stale image: instruction set has changed since the image was built; rebuild it with sola build --image"}
+	}
+	return nil
+}
+
+// DecodeImage 解码映像，类已链接 (父类、虚表)，静态存储可直接交给 VM
+func DecodeImage(data []byte) (*ImageFile, error) {
+	if err := ValidateImageHeader(data); err != nil {
+		return nil, err
+	}
+	d := &imageDecoder{Deserializer: NewDeserializer(data)}
+	d.pos = ImageHeaderSize
+	if err := d.readStringPool(); err != nil {
+		return nil, err
+	}
+	if int(binary.BigEndian.Uint32(data[12:16])) != d.pos {
+		return nil, &FormatError{"image corrupted"}
+	}
+	return d.readBody()
+}
+
+// readBody 读取映像正文
+func (d *imageDecoder) readBody() (*ImageFile, error) {
+	img := &ImageFile{
+		Classes: make(map[string]*Class),
+		Enums:   make(map[string]*Enum),
+		Statics: make(map[*Class]map[string]Value),
+	}
+	var err error
+	if img.SourceFile, err = d.readString(); err != nil {
+		return nil, err
+	}
+
+	// 类
+	count, err := d.readU32()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < count; i++ {
+		class, err := d.readClass()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read classes: %w", err)
+		}
+		if err := verifyClass(class); err != nil {
+			return nil, err
+		}
+		d.classes = append(d.classes, class)
+	}
+	if count, err = d.readU32(); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < count; i++ {
+		name, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		class, err := d.readClassRef()
+		if err != nil || class == nil {
+			return nil, &FormatError{"class table corrupted"}
+		}
+		img.Classes[name] = class
+	}
+	for _, class := range d.classes {
+		if err := d.readLinks(class); err != nil {
+			return nil, err
+		}
+	}
+
+	// 枚举
+	if count, err = d.readU32(); err != nil {
+		return nil, err
+	}
+	enums := make([]*Enum, count)
+	for i := range enums {
+		if enums[i], err = d.readEnum(); err != nil {
+			return nil, fmt.Errorf("failed to read enums: %w", err)
+		}
+	}
+	if count, err = d.readU32(); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < count; i++ {
+		name, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		idx, err := d.readU32()
+		if err != nil {
+			return nil, err
+		}
+		if int(idx) >= len(enums) {
+			return nil, &FormatError{"enum table corrupted"}
+		}
+		img.Enums[name] = enums[idx]
+	}
+
+	if img.Entry, err = d.readClassRef(); err != nil {
+		return nil, err
+	}
+
+	if err := d.readHeap(); err != nil {
+		return nil, err
+	}
+
+	// 静态变量
+	if count, err = d.readU32(); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < count; i++ {
+		class, err := d.readClassRef()
+		if err != nil || class == nil {
+			return nil, &FormatError{"statics corrupted"}
+		}
+		n, err := d.readU16()
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]Value, n)
+		for j := uint16(0); j < n; j++ {
+			name, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			if values[name], err = d.readImageValue(); err != nil {
+				return nil, err
+			}
+		}
+		img.Statics[class] = values
+	}
+	return img, nil
+}
+
+// verifyClass 检查类中所有方法和静态初始化器的字节码结构
+func verifyClass(class *Class) error {
+	methods := classMethods(class)
+	if class.StaticInit != nil {
+		methods = append(methods, class.StaticInit)
+	}
+	for _, method := range methods {
+		if err := verifyImageCode(method.Chunk); err != nil {
+			return fmt.Errorf("类 %s 的方法 %s 字节码验证失败: %w", class.Name, method.Name, err)
+		}
+	}
+	return nil
+}
+
+// verifyImageCode 检查字节码结构：每条指令都是已知的通用指令或超级指令，操作数不越界，
+// 常量池中的嵌套函数同样检查
+// 映像只能由同一版本的 VM 构建 (见 ValidateImageHeader)，这里防止损坏的文件越界执行；
+// 栈平衡由构建映像的编译器保证
+func verifyImageCode(chunk *Chunk) error {
+	if chunk == nil {
+		return nil
+	}
+	sizes := NewOptimizer(chunk)
+	for ip := 0; ip < len(chunk.Code); {
+		op := OpCode(chunk.Code[ip])
+		if _, known := opNames[op]; !known || op.IsQuickened() {
+			return &VerificationError{Offset: ip, Message: fmt.Sprintf("无效的指令 %s", op)}
+		}
+		if op.IsFused() {
+			if chunk.FusedLength(ip) == 0 {
+				return &VerificationError{Offset: ip, Message: fmt.Sprintf("超级指令 %s 与其后的指令序列不匹配", op)}
+			}
+			op = op.Generic()
+		}
+		size := sizes.instructionSize(op, ip)
+		if ip+size > len(chunk.Code) {
+			return &VerificationError{Offset: ip, Message: fmt.Sprintf("指令 %s 的操作数超出字节码末尾", op)}
+		}
+		ip += size
+	}
+	for _, c := range chunk.Constants {
+		if fn := c.AsFunc(); fn != nil {
+			if err := verifyImageCode(fn.Chunk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readLinks 读取类的链接信息
+func (d *imageDecoder) readLinks(class *Class) error {
+	var err error
+	if class.Parent, err = d.readClassRef(); err != nil {
+		return err
+	}
+
+	n, err := d.readU16()
+	if err != nil {
+		return err
+	}
+	for i := uint16(0); i < n; i++ {
+		name, err := d.readString()
+		if err != nil {
+			return err
+		}
+		class.PropFinal[name] = true
+	}
+
+	methods := classMethods(class)
+	if n, err = d.readU16(); err != nil {
+		return err
+	}
+	for i := uint16(0); i < n; i++ {
+		idx, err := d.readU16()
+		if err != nil {
+			return err
+		}
+		if int(idx) >= len(methods) {
+			return &FormatError{"method table corrupted"}
+		}
+		methods[idx].IsFinal = true
+	}
+
+	if n, err = d.readU16(); err != nil {
+		return err
+	}
+	for i := uint16(0); i < n; i++ {
+		key, err := d.readString()
+		if err != nil {
+			return err
+		}
+		vt := &VTable{}
+		if vt.InterfaceName, err = d.readString(); err != nil {
+			return err
+		}
+		if vt.ClassName, err = d.readString(); err != nil {
+			return err
+		}
+		entries, err := d.readU16()
+		if err != nil {
+			return err
+		}
+		vt.Methods = make([]VTableEntry, entries)
+		for j := range vt.Methods {
+			entry := &vt.Methods[j]
+			idx, err := d.readU16()
+			if err != nil {
+				return err
+			}
+			entry.MethodIndex = int(idx)
+			if entry.MethodName, err = d.readString(); err != nil {
+				return err
+			}
+			owner, err := d.readClassRef()
+			if err != nil {
+				return err
+			}
+			if owner == nil {
+				continue
+			}
+			ordinal, err := d.readU32()
+			if err != nil {
+				return err
+			}
+			ownerMethods := classMethods(owner)
+			if int(ordinal) >= len(ownerMethods) {
+				return &FormatError{"vtable corrupted"}
+			}
+			entry.ImplMethod = ownerMethods[ordinal]
+		}
+		class.VTables[key] = vt
+	}
+	return nil
+}
+
+// readHeap 读取堆表：先分配所有对象的外壳，再填充内容
+func (d *imageDecoder) readHeap() error {
+	count, err := d.readU32()
+	if err != nil {
+		return err
+	}
+	if int(count) > len(d.data)-d.pos {
+		return &FormatError{"heap corrupted"}
+	}
+	d.heap = make([]Value, count)
+	for i := range d.heap {
+		kind, err := d.readU8()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case HeapArray:
+			n, err := d.readLength()
+			if err != nil {
+				return err
+			}
+			d.heap[i] = NewArray(make([]Value, n))
+		case HeapMap:
+			d.heap[i] = NewMap(make(map[Key]Value))
+		case HeapSuperArray:
+			d.heap[i] = NewSuperArrayValue(NewSuperArray())
+		case HeapObject:
+			class, err := d.readClassRef()
+			if err != nil || class == nil {
+				return &FormatError{"heap object without class"}
+			}
+			d.heap[i] = NewObject(NewObjectInstance(class))
+		case HeapBytes:
+			n, err := d.readLength()
+			if err != nil {
+				return err
+			}
+			data := make([]byte, n)
+			d.pos += copy(data, d.data[d.pos:])
+			d.heap[i] = NewBytes(data)
+		case HeapFixedArray:
+			capacity, err := d.readU32()
+			if err != nil {
+				return err
+			}
+			n, err := d.readLength()
+			if err != nil {
+				return err
+			}
+			fa := &FixedArray{Elements: make([]Value, n), Capacity: int(capacity)}
+			d.heap[i] = Value{typ: uint8(ValFixedArray), ptr: unsafe.Pointer(fa)}
+		case HeapNativeArray:
+			elemType, err := d.readU8()
+			if err != nil {
+				return err
+			}
+			n, err := d.readLength()
+			if err != nil {
+				return err
+			}
+			d.heap[i] = NewNativeArrayValue(NewNativeArray(ValueType(elemType), n))
+		default:
+			return &FormatError{fmt.Sprintf("unknown heap object type: %d", kind)}
+		}
+	}
+
+	for _, v := range d.heap {
+		if err := d.readHeapContents(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHeapContents 填充堆对象的内容
+func (d *imageDecoder) readHeapContents(v Value) error {
+	var err error
+	switch v.Type() {
+	case ValArray:
+		arr := v.AsArray()
+		for i := range arr {
+			if arr[i], err = d.readImageValue(); err != nil {
+				return err
+			}
+		}
+	case ValMap:
+		m := v.AsMap()
+		n, err := d.readU32()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < n; i++ {
+			k, err := d.readImageValue()
+			if err != nil {
+				return err
+			}
+			if m[MapKey(k)], err = d.readImageValue(); err != nil {
+				return err
+			}
+		}
+	case ValSuperArray:
+		sa := v.AsSuperArray()
+		next, err := d.readI64()
+		if err != nil {
+			return err
+		}
+		n, err := d.readU32()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < n; i++ {
+			k, err := d.readImageValue()
+			if err != nil {
+				return err
+			}
+			val, err := d.readImageValue()
+			if err != nil {
+				return err
+			}
+			sa.Set(k, val)
+		}
+		sa.NextInt = next
+	case ValObject:
+		obj := v.AsObject()
+		n, err := d.readU16()
+		if err != nil {
+			return err
+		}
+		for i := uint16(0); i < n; i++ {
+			arg, err := d.readString()
+			if err != nil {
+				return err
+			}
+			obj.TypeArgs = append(obj.TypeArgs, arg)
+		}
+		fields, err := d.readU32()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < fields; i++ {
+			name, err := d.readString()
+			if err != nil {
+				return err
+			}
+			val, err := d.readImageValue()
+			if err != nil {
+				return err
+			}
+			obj.SetField(name, val)
+		}
+	case ValFixedArray:
+		elems := v.AsFixedArray().Elements
+		for i := range elems {
+			if elems[i], err = d.readImageValue(); err != nil {
+				return err
+			}
+		}
+	case ValNativeArray:
+		arr := v.AsNativeArray()
+		for i := 0; i < arr.Len(); i++ {
+			val, err := d.readImageValue()
+			if err != nil {
+				return err
+			}
+			arr.Set(i, val)
+		}
+	}
+	return nil
+}
+
+// readImageValue 读取值：堆表引用、枚举值或常量
+func (d *imageDecoder) readImageValue() (Value, error) {
+	if d.pos >= len(d.data) {
+		return NullValue, &FormatError{"unexpected end of file"}
+	}
+	switch d.data[d.pos] {
+	case ConstHeapRef:
+		d.pos++
+		idx, err := d.readU32()
+		if err != nil {
+			return NullValue, err
+		}
+		if int(idx) >= len(d.heap) {
+			return NullValue, &FormatError{"heap reference out of range"}
+		}
+		return d.heap[idx], nil
+	case ConstEnumValue:
+		d.pos++
+		enumName, err := d.readString()
+		if err != nil {
+			return NullValue, err
+		}
+		caseName, err := d.readString()
+		if err != nil {
+			return NullValue, err
+		}
+		val, err := d.readImageValue()
+		if err != nil {
+			return NullValue, err
+		}
+		return NewEnumValue(enumName, caseName, val), nil
+	}
+	return d.readValue()
+}
+
+// readClassRef 读取类下标，noIndex 读为 nil
+func (d *imageDecoder) readClassRef() (*Class, error) {
+	idx, err := d.readU32()
+	if err != nil {
+		return nil, err
+	}
+	if idx == noIndex {
+		return nil, nil
+	}
+	if int(idx) >= len(d.classes) {
+		return nil, &FormatError{"class reference out of range"}
+	}
+	return d.classes[idx], nil
+}
+
+// readString 读取字符串池下标对应的字符串
+func (d *imageDecoder) readString() (string, error) {
+	idx, err := d.readU32()
+	if err != nil {
+		return "", err
+	}
+	if int(idx) >= len(d.stringPool) {
+		return "", &FormatError{"string index out of range"}
+	}
+	return d.stringPool[idx], nil
+}
+
+// readLength 读取元素数量，超出剩余数据长度时视为损坏 (每个元素至少占一个字节)
+func (d *imageDecoder) readLength() (int, error) {
+	n, err := d.readU32()
+	if err != nil {
+		return 0, err
+	}
+	if int(n) > len(d.data)-d.pos {
+		return 0, &FormatError{"image corrupted"}
+	}
+	return int(n), nil
+}
@@ -362,11 +362,12 @@ func ToJITObject(obj *Object) *JITObject {
 	jitObj := NewJITObject(layout.ClassID, layout.VTablePtr, layout.FieldCount)
 
 	// 复制字段
-	for name, value := range obj.Fields {
+	obj.RangeFields(func(name string, value Value) bool {
 		if field, ok := layout.Fields[name]; ok {
 			jitObj.Fields[field.Index] = ValueToInt64(value)
 		}
-	}
+		return true
+	})
 
 	return jitObj
 }
@@ -385,14 +386,12 @@ func FromJITObject(jitObj *JITObject) *Object {
 
 	// 需要查找 Class 定义
 	// 这里简化处理，实际需要从某处获取 Class 定义
-	obj := &Object{
-		Fields: make(map[string]Value),
-	}
+	obj := &Object{}
 
 	// 复制字段
 	for _, field := range layout.FieldsByIndex {
 		value := Int64ToValue(jitObj.Fields[field.Index], field.Type)
-		obj.Fields[field.Name] = value
+		obj.SetDynamicField(field.Name, value)
 	}
 
 	return obj
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sort"
 )
 
 // Serializer 字节码序列化器
@@ -95,20 +96,24 @@ func (s *Serializer) collectStrings(fn *Function, classes map[string]*Class, enu
 		for _, iface := range class.Implements {
 			s.addString(iface)
 		}
-		for propName := range class.Properties {
+		for propName, val := range class.Properties {
 			s.addString(propName)
+			s.collectValueStrings(val)
 		}
-		for constName := range class.Constants {
+		for constName, val := range class.Constants {
 			s.addString(constName)
+			s.collectValueStrings(val)
 		}
-		for staticName := range class.StaticVars {
+		for staticName, val := range class.StaticVars {
 			s.addString(staticName)
+			s.collectValueStrings(val)
 		}
 		for _, methods := range class.Methods {
 			for _, method := range methods {
 				s.collectMethodStrings(method)
 			}
 		}
+		s.collectMethodStrings(class.StaticInit)
 		// 收集类型参数
 		for _, tp := range class.TypeParams {
 			s.addString(tp.Name)
@@ -163,9 +168,21 @@ func (s *Serializer) collectChunkStrings(chunk *Chunk) {
 		return
 	}
 	for _, val := range chunk.Constants {
-		if val.Type() == ValString {
-			s.addString(val.AsString())
+		s.collectValueStrings(val)
+	}
+}
+
+// collectValueStrings 收集常量值（含数组元素）中的字符串
+func (s *Serializer) collectValueStrings(val Value) {
+	switch val.Type() {
+	case ValString:
+		s.addString(val.AsString())
+	case ValArray:
+		for _, elem := range val.AsArray() {
+			s.collectValueStrings(elem)
 		}
+	case ValFunc:
+		s.collectFunctionStrings(val.AsFunc())
 	}
 }
 
@@ -297,6 +314,16 @@ func (s *Serializer) writeValue(buf *bytes.Buffer, val Value) {
 	case ValString:
 		buf.WriteByte(ConstString)
 		binary.Write(buf, binary.BigEndian, s.addString(val.AsString()))
+	case ValArray:
+		arr := val.AsArray()
+		buf.WriteByte(ConstArray)
+		binary.Write(buf, binary.BigEndian, uint32(len(arr)))
+		for _, elem := range arr {
+			s.writeValue(buf, elem)
+		}
+	case ValFunc:
+		buf.WriteByte(ConstFunc)
+		s.writeFunctionTo(buf, val.AsFunc())
 	default:
 		// 不支持的类型，写入 null
 		buf.WriteByte(ConstNull)
@@ -336,6 +363,9 @@ func (s *Serializer) writeClassTo(buf *bytes.Buffer, class *Class) {
 	if class.IsAttribute {
 		flags |= ClassFlagAttribute
 	}
+	if class.StaticInit != nil {
+		flags |= ClassFlagStaticInit
+	}
 	buf.WriteByte(flags)
 
 	// 实现的接口
@@ -359,11 +389,11 @@ func (s *Serializer) writeClassTo(buf *bytes.Buffer, class *Class) {
 	// 类注解
 	s.writeAnnotations(buf, class.Annotations)
 
-	// 属性
+	// 属性、常量和静态变量按名称排序，输出与 map 的遍历顺序无关
 	binary.Write(buf, binary.BigEndian, uint16(len(class.Properties)))
-	for name, val := range class.Properties {
+	for _, name := range sortedKeys(class.Properties) {
 		binary.Write(buf, binary.BigEndian, s.addString(name))
-		s.writeValue(buf, val)
+		s.writeValue(buf, class.Properties[name])
 		// 可见性
 		vis := class.PropVisibility[name]
 		buf.WriteByte(uint8(vis))
@@ -373,32 +403,45 @@ func (s *Serializer) writeClassTo(buf *bytes.Buffer, class *Class) {
 
 	// 常量
 	binary.Write(buf, binary.BigEndian, uint16(len(class.Constants)))
-	for name, val := range class.Constants {
+	for _, name := range sortedKeys(class.Constants) {
 		binary.Write(buf, binary.BigEndian, s.addString(name))
-		s.writeValue(buf, val)
+		s.writeValue(buf, class.Constants[name])
 	}
 
 	// 静态变量
 	binary.Write(buf, binary.BigEndian, uint16(len(class.StaticVars)))
-	for name, val := range class.StaticVars {
+	for _, name := range sortedKeys(class.StaticVars) {
 		binary.Write(buf, binary.BigEndian, s.addString(name))
-		s.writeValue(buf, val)
+		s.writeValue(buf, class.StaticVars[name])
 	}
 
-	// 方法
-	// 先统计总方法数
-	totalMethods := 0
-	for _, methods := range class.Methods {
-		totalMethods += len(methods)
+	// 方法 (按方法名排序，输出与 map 的遍历顺序无关)
+	methods := classMethods(class)
+	binary.Write(buf, binary.BigEndian, uint16(len(methods)))
+	for _, method := range methods {
+		s.writeMethodTo(buf, method)
 	}
-	binary.Write(buf, binary.BigEndian, uint16(totalMethods))
-	for _, methods := range class.Methods {
-		for _, method := range methods {
-			s.writeMethodTo(buf, method)
-		}
+
+	// 静态初始化器
+	if class.StaticInit != nil {
+		s.writeMethodTo(buf, class.StaticInit)
 	}
 }
 
+// classMethods 按方法名排序返回类的所有方法，同名重载保持定义顺序
+func classMethods(class *Class) []*Method {
+	names := make([]string, 0, len(class.Methods))
+	for name := range class.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var methods []*Method
+	for _, name := range names {
+		methods = append(methods, class.Methods[name]...)
+	}
+	return methods
+}
+
 // writeMethodTo 写入方法
 func (s *Serializer) writeMethodTo(buf *bytes.Buffer, method *Method) {
 	// 方法名
@@ -460,9 +503,9 @@ func (s *Serializer) writeAnnotations(buf *bytes.Buffer, annotations []*Annotati
 		binary.Write(buf, binary.BigEndian, s.addString(ann.Name))
 		// 写入参数数量和参数（map 格式：key-value 对）
 		binary.Write(buf, binary.BigEndian, uint16(len(ann.Args)))
-		for key, arg := range ann.Args {
+		for _, key := range sortedKeys(ann.Args) {
 			binary.Write(buf, binary.BigEndian, s.addString(key))
-			s.writeValue(buf, arg)
+			s.writeValue(buf, ann.Args[key])
 		}
 	}
 }
@@ -485,9 +528,9 @@ func (s *Serializer) writeEnumTo(buf *bytes.Buffer, enum *Enum) {
 	// 成员数量
 	binary.Write(buf, binary.BigEndian, uint16(len(enum.Cases)))
 	// 枚举成员
-	for caseName, val := range enum.Cases {
+	for _, caseName := range sortedKeys(enum.Cases) {
 		binary.Write(buf, binary.BigEndian, s.addString(caseName))
-		s.writeValue(buf, val)
+		s.writeValue(buf, enum.Cases[caseName])
 	}
 }
 
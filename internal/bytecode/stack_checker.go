@@ -84,7 +84,7 @@ func (sc *StackChecker) Check(maxAllowed int) StackCheckResult {
 
 			depths[pos] = depth
 
-			op := OpCode(code[pos])
+			op := OpCode(code[pos]).Generic() // 快速指令与通用指令相同；超级指令按原序列逐条分析
 			effect := sc.stackEffect(op, pos)
 			size := sc.instructionSize(op, pos)
 
@@ -227,7 +227,7 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 	case OpSetField:
 		return -2 // 弹出对象和值
 
-	case OpCallMethod:
+	case OpCallMethod, OpTailCallMethod:
 		if offset+3 < len(sc.chunk.Code) {
 			argCount := int(sc.chunk.Code[offset+3])
 			return -argCount // 弹出接收者和参数，压入返回值
@@ -239,9 +239,9 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 		return 1 // 压入静态成员值
 
 	case OpSetStatic:
-		return -1 // 弹出值
+		return 0 // 弹出值并压回（赋值表达式的结果）
 
-	case OpCallStatic:
+	case OpCallStatic, OpTailCallStatic:
 		if offset+5 < len(sc.chunk.Code) {
 			argCount := int(sc.chunk.Code[offset+5])
 			return 1 - argCount // 压入返回值，弹出参数
@@ -267,13 +267,13 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 		return -1 // 弹出数组和索引，压入值
 
 	case OpArraySet, OpArraySetUnchecked:
-		return -3 // 弹出数组、索引和值
+		return -2 // 弹出数组、索引和值，压入值
 
 	case OpArrayLen:
 		return 0 // 弹出数组，压入长度
 
 	case OpArrayPush:
-		return -2 // 弹出数组和值
+		return -1 // 弹出数组和值，压入新长度
 
 	case OpArrayHas:
 		return -1 // 弹出数组和值，压入布尔
@@ -290,7 +290,7 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 		return -1 // 弹出 map 和 key，压入值
 
 	case OpMapSet:
-		return -3 // 弹出 map、key 和值
+		return -2 // 弹出 map、key 和值，压入值
 
 	case OpMapHas:
 		return -1 // 弹出 map 和 key，压入布尔
@@ -298,6 +298,26 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 	case OpMapLen:
 		return 0 // 弹出 map，压入长度
 
+	// 原生数组
+	case OpNativeArrayNew:
+		return 1
+
+	case OpNativeArrayInit:
+		if offset+3 < len(sc.chunk.Code) {
+			count := int(sc.chunk.Code[offset+2])<<8 | int(sc.chunk.Code[offset+3])
+			return 1 - count // 弹出元素，压入数组
+		}
+		return 1
+
+	case OpNativeArrayGet:
+		return -1 // 弹出数组和索引，压入值
+
+	case OpNativeArraySet:
+		return -2 // 弹出数组、索引和值，压入值
+
+	case OpNativeArrayLen:
+		return 0 // 弹出数组，压入长度
+
 	// SuperArray
 	case OpSuperArrayNew:
 		// 可变长度
@@ -332,7 +352,7 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 		return -1
 
 	case OpBytesSet:
-		return -3
+		return -2 // 弹出 bytes、索引和值，压入值
 
 	case OpBytesLen:
 		return 0
@@ -373,7 +393,7 @@ func (sc *StackChecker) stackEffect(op OpCode, offset int) int {
 
 	// 销毁
 	case OpUnset:
-		return -1
+		return 0 // 弹出值，压入析构函数的返回值或 null
 
 	case OpHalt:
 		return 0
@@ -395,27 +415,32 @@ func (sc *StackChecker) instructionSize(op OpCode, offset int) int {
 	case OpNewFixedArray:
 		return 5
 
-	case OpJump, OpJumpIfFalse, OpJumpIfTrue, OpLoop:
+	case OpNativeArrayNew, OpNativeArrayInit:
+		return 4
+
+	case OpJump, OpJumpIfFalse, OpJumpIfTrue, OpLoop, OpGo:
 		return 3
 
-	case OpCall, OpTailCall:
+	case OpCall, OpTailCall, OpCoroutineAwait, OpSelectStart:
 		return 2
 
-	case OpCallMethod:
+	case OpSelectCase:
+		return 4
+
+	case OpSelectDefault:
+		return 3
+
+	case OpCallMethod, OpTailCallMethod:
 		return 4
 
 	case OpGetStatic, OpSetStatic:
 		return 5
 
-	case OpCallStatic:
+	case OpCallStatic, OpTailCallStatic:
 		return 6
 
 	case OpSuperArrayNew:
-		if offset+2 < len(sc.chunk.Code) {
-			count := int(sc.chunk.Code[offset+1])<<8 | int(sc.chunk.Code[offset+2])
-			return 3 + count
-		}
-		return 3
+		return 3 // 元素和键值标志都在栈上，操作数只有元素数量
 
 	case OpEnterTry:
 		if offset+1 < len(sc.chunk.Code) {
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -35,6 +36,8 @@ const (
 	ValStringBuilder // 字符串构建器（用于高效拼接）
 	ValChannel       // 通道
 	ValGoroutine     // 协程引用
+	ValWeakRef       // 弱引用
+	ValWeakMap       // 弱键映射
 )
 
 // FixedArray 定长数组（旧版，兼容）
@@ -935,6 +938,20 @@ type StackFrame struct {
 	FileName     string // 源文件名
 	LineNumber   int    // 行号
 	ClassName    string // 所属类名（可选，方法调用时有效）
+	Repeated     int    // 紧随其后、与此帧相同而被合并的帧数
+	Omitted      int    // 调用栈截断时此帧之后省略的帧数
+}
+
+// Elisions 返回调用栈压缩时附加在此帧之后的说明行
+func (f StackFrame) Elisions() []string {
+	var notes []string
+	if f.Repeated > 0 {
+		notes = append(notes, fmt.Sprintf("... repeated %d more times", f.Repeated))
+	}
+	if f.Omitted > 0 {
+		notes = append(notes, fmt.Sprintf("... %d more frames", f.Omitted))
+	}
+	return notes
 }
 
 // Exception 异常对象
@@ -973,6 +990,11 @@ func NewExceptionWithCause(typeName, message string, code int64, cause *Exceptio
 	return Value{typ: uint8(ValException), ptr: unsafe.Pointer(ex)}
 }
 
+// NewExceptionValue 包装已有的异常（保留调用栈等信息）
+func NewExceptionValue(ex *Exception) Value {
+	return Value{typ: uint8(ValException), ptr: unsafe.Pointer(ex)}
+}
+
 // NewExceptionFromObject 从 Sola 对象创建异常值
 // 对象必须是 Throwable 或其子类的实例
 func NewExceptionFromObject(obj *Object) Value {
@@ -981,13 +1003,13 @@ func NewExceptionFromObject(obj *Object) Value {
 	code := int64(0)
 	var cause *Exception
 
-	if msgVal, ok := obj.Fields["message"]; ok {
+	if msgVal, ok := obj.GetField("message"); ok {
 		message = msgVal.AsString()
 	}
-	if codeVal, ok := obj.Fields["code"]; ok {
+	if codeVal, ok := obj.GetField("code"); ok {
 		code = codeVal.AsInt()
 	}
-	if prevVal, ok := obj.Fields["previous"]; ok && prevVal.Type() == ValException {
+	if prevVal, ok := obj.GetField("previous"); ok && prevVal.Type() == ValException {
 		cause = prevVal.AsException()
 	}
 
@@ -1023,8 +1045,8 @@ func (e *Exception) SetStackFrames(frames []StackFrame) {
 	// 如果有关联的 Sola 对象，同步更新其 stackTrace 字段
 	if e.Object != nil {
 		// 将 StackFrames 转换为 Sola 字符串数组
-		arr := make([]Value, len(frames))
-		for i, f := range frames {
+		arr := make([]Value, 0, len(frames))
+		for _, f := range frames {
 			var frameStr string
 			if f.ClassName != "" {
 				frameStr = fmt.Sprintf("%s.%s (%s:%d)", f.ClassName, f.FunctionName, f.FileName, f.LineNumber)
@@ -1033,14 +1055,17 @@ func (e *Exception) SetStackFrames(frames []StackFrame) {
 			} else {
 				frameStr = fmt.Sprintf("%s (line %d)", f.FunctionName, f.LineNumber)
 			}
-			arr[i] = NewString(frameStr)
+			arr = append(arr, NewString(frameStr))
+			for _, note := range f.Elisions() {
+				arr = append(arr, NewString(note))
+			}
 		}
-		e.Object.Fields["stackTrace"] = NewArray(arr)
+		e.Object.SetField("stackTrace", NewArray(arr))
 		
 		// 同时设置 file 和 line 字段（如果存在）
 		if len(frames) > 0 {
-			e.Object.Fields["file"] = NewString(frames[0].FileName)
-			e.Object.Fields["line"] = NewInt(int64(frames[0].LineNumber))
+			e.Object.SetField("file", NewString(frames[0].FileName))
+			e.Object.SetField("line", NewInt(int64(frames[0].LineNumber)))
 		}
 	}
 }
@@ -1058,7 +1083,7 @@ func (e *Exception) GetFullMessage() string {
 		// 如果是对象异常，尝试从对象获取最新的 message
 		message := current.Message
 		if current.Object != nil {
-			if msgVal, ok := current.Object.Fields["message"]; ok {
+			if msgVal, ok := current.Object.GetField("message"); ok {
 				message = msgVal.AsString()
 			}
 		}
@@ -1073,6 +1098,9 @@ func (e *Exception) GetFullMessage() string {
 					result += fmt.Sprintf("\n    at %s (%s:%d)", 
 						frame.FunctionName, frame.FileName, frame.LineNumber)
 				}
+				for _, note := range frame.Elisions() {
+					result += "\n    " + note
+				}
 			}
 		}
 		current = current.Cause
@@ -1100,6 +1128,9 @@ func (e *Exception) GetStackTraceAsString() string {
 			result += fmt.Sprintf("    at %s (%s:%d)", 
 				frame.FunctionName, frame.FileName, frame.LineNumber)
 		}
+		for _, note := range frame.Elisions() {
+			result += "\n    " + note
+		}
 	}
 	return result
 }
@@ -1125,6 +1156,12 @@ func (v Value) Raw() uint64 {
 	return v.num
 }
 
+// Identity 返回值引用的底层数据的地址，引用同一数据的值 (同一数组、对象、字符串等)
+// 地址相同；int/float/bool/null 返回 nil。用于遍历堆时识别共享和循环引用
+func (v Value) Identity() unsafe.Pointer {
+	return v.ptr
+}
+
 // Data 返回值的数据 (兼容旧 API，用于类型断言)
 // 注意：这是为了向后兼容而保留的，新代码应使用 As*() 方法
 func (v Value) Data() interface{} {
@@ -1159,9 +1196,9 @@ func (v Value) Data() interface{} {
 		return (*NativeArray)(v.ptr)
 	case ValMap:
 		if v.ptr == nil {
-			return (map[Value]Value)(nil)
+			return (map[Key]Value)(nil)
 		}
-		return *(*map[Value]Value)(v.ptr)
+		return *(*map[Key]Value)(v.ptr)
 	case ValSuperArray:
 		if v.ptr == nil {
 			return (*SuperArray)(nil)
@@ -1217,6 +1254,16 @@ func (v Value) Data() interface{} {
 			return nil
 		}
 		return *(*interface{})(v.ptr)
+	case ValWeakRef:
+		if v.ptr == nil {
+			return (*WeakRef)(nil)
+		}
+		return (*WeakRef)(v.ptr)
+	case ValWeakMap:
+		if v.ptr == nil {
+			return (*WeakMap)(nil)
+		}
+		return (*WeakMap)(v.ptr)
 	default:
 		return nil
 	}
@@ -1561,7 +1608,7 @@ func NewNativeArrayFromValueSlice(elemType ValueType, values []Value) Value {
 }
 
 // NewMap 创建 Map 值
-func NewMap(m map[Value]Value) Value {
+func NewMap(m map[Key]Value) Value {
 	return Value{typ: uint8(ValMap), ptr: unsafe.Pointer(&m)}
 }
 
@@ -1676,7 +1723,7 @@ func (v Value) IsTruthy() bool {
 		if v.ptr == nil {
 			return false
 		}
-		return len(*(*map[Value]Value)(v.ptr)) > 0
+		return len(*(*map[Key]Value)(v.ptr)) > 0
 	case ValSuperArray:
 		if v.ptr == nil {
 			return false
@@ -1750,6 +1797,17 @@ func (v Value) AsArray() []Value {
 	return nil
 }
 
+// AppendArray 向数组原地追加元素，所有引用同一数组的值都能看到新元素
+// 返回追加后的长度，非数组值返回 -1
+func (v Value) AppendArray(elem Value) int {
+	if v.typ != uint8(ValArray) || v.ptr == nil {
+		return -1
+	}
+	arr := (*[]Value)(v.ptr)
+	*arr = append(*arr, elem)
+	return len(*arr)
+}
+
 // AsFunc 获取函数
 func (v Value) AsFunc() *Function {
 	if v.typ == uint8(ValFunc) && v.ptr != nil {
@@ -1788,9 +1846,9 @@ func (v Value) IsNativeArray() bool {
 }
 
 // AsMap 获取 Map
-func (v Value) AsMap() map[Value]Value {
+func (v Value) AsMap() map[Key]Value {
 	if v.typ == uint8(ValMap) && v.ptr != nil {
-		return *(*map[Value]Value)(v.ptr)
+		return *(*map[Key]Value)(v.ptr)
 	}
 	return nil
 }
@@ -1879,7 +1937,7 @@ func (v Value) String() string {
 		if v.ptr == nil {
 			return "[]"
 		}
-		m := *(*map[Value]Value)(v.ptr)
+		m := *(*map[Key]Value)(v.ptr)
 		var parts []string
 		for k, val := range m {
 			parts = append(parts, k.String()+" => "+val.String())
@@ -1933,7 +1991,7 @@ func (v Value) String() string {
 		// 如果是对象异常，获取最新的 message
 		message := ex.Message
 		if ex.Object != nil {
-			if msgVal, ok := ex.Object.Fields["message"]; ok {
+			if msgVal, ok := ex.Object.GetField("message"); ok {
 				message = msgVal.AsString()
 			}
 		}
@@ -1949,6 +2007,10 @@ func (v Value) String() string {
 		return fmt.Sprintf("<Coroutine#%d %s>", co.ID, co.Status)
 	case ValChannel:
 		return "<Channel>"
+	case ValWeakRef:
+		return "<WeakRef>"
+	case ValWeakMap:
+		return "<WeakMap>"
 	default:
 		return "<unknown>"
 	}
@@ -2077,46 +2139,123 @@ func (v Value) Hash() uint64 {
 	}
 }
 
+// Key Map 的键
+// 字符串按内容比较，其余类型与 Value 一样按类型、数值和指针比较，
+// 因此任何途径创建的内容相同的字符串都能找到同一个条目
+type Key struct {
+	typ uint8
+	num uint64
+	ptr unsafe.Pointer // 字符串键为 nil
+	str string         // 字符串键的内容
+}
+
+// MapKey 返回值作为 Map 键时的形式
+func MapKey(v Value) Key {
+	if ValueType(v.typ) == ValString {
+		return Key{typ: v.typ, str: v.AsString()}
+	}
+	return Key{typ: v.typ, num: v.num, ptr: v.ptr}
+}
+
+// Value 返回键对应的值
+func (k Key) Value() Value {
+	if ValueType(k.typ) == ValString {
+		return NewString(k.str)
+	}
+	return Value{typ: k.typ, num: k.num, ptr: k.ptr}
+}
+
+// String 返回键的字符串表示
+func (k Key) String() string {
+	if ValueType(k.typ) == ValString {
+		return k.str
+	}
+	return k.Value().String()
+}
+
 // ============================================================================
 // 运行时对象
 // ============================================================================
 
 // Object 对象实例
+// 类 (含父类) 中声明的属性按 Class.Layout() 的槽位存放在 Slots 中，
+// 运行时动态添加的属性存放在 Fields 中 (首次写入时创建)
 type Object struct {
-	Class   *Class
-	Fields  map[string]Value
-	TypeArgs []string // 泛型类型参数（用于运行时类型验证）
+	Class     *Class
+	Slots     []Value          // 声明属性的值，按槽位索引
+	Fields    map[string]Value // 动态属性
+	TypeArgs  []string         // 泛型类型参数（用于运行时类型验证）
+	Native    interface{}      // 宿主 (Go) 对象，由嵌入 API 注册的 Go 支持类使用
+	Destroyed bool             // 已被 unset() 销毁，析构函数只执行一次
 }
 
-// NewObjectInstance 创建对象实例
+// NewObjectInstance 创建对象实例，声明属性初始化为默认值
 func NewObjectInstance(class *Class) *Object {
-	return &Object{
-		Class:    class,
-		Fields:   make(map[string]Value),
-		TypeArgs: nil,
-	}
+	return NewObjectInstanceWithTypes(class, nil)
 }
 
 // NewObjectInstanceWithTypes 创建带泛型类型参数的对象实例
 func NewObjectInstanceWithTypes(class *Class, typeArgs []string) *Object {
-	return &Object{
+	obj := &Object{
 		Class:    class,
-		Fields:   make(map[string]Value),
 		TypeArgs: typeArgs,
 	}
+	if class != nil {
+		if defaults := class.Layout().Defaults; len(defaults) > 0 {
+			obj.Slots = make([]Value, len(defaults))
+			copy(obj.Slots, defaults)
+		}
+	}
+	return obj
 }
 
 // GetField 获取字段
 func (o *Object) GetField(name string) (Value, bool) {
+	if o.Class != nil {
+		if slot, ok := o.Class.Layout().Index[name]; ok && slot < len(o.Slots) {
+			return o.Slots[slot], true
+		}
+	}
 	v, ok := o.Fields[name]
 	return v, ok
 }
 
 // SetField 设置字段
 func (o *Object) SetField(name string, value Value) {
+	if o.Class != nil {
+		if slot, ok := o.Class.Layout().Index[name]; ok && slot < len(o.Slots) {
+			o.Slots[slot] = value
+			return
+		}
+	}
+	o.SetDynamicField(name, value)
+}
+
+// SetDynamicField 设置动态属性 (类中未声明的属性)
+func (o *Object) SetDynamicField(name string, value Value) {
+	if o.Fields == nil {
+		o.Fields = make(map[string]Value)
+	}
 	o.Fields[name] = value
 }
 
+// RangeFields 按槽位顺序遍历声明属性，再遍历动态属性
+// fn 返回 false 时停止遍历
+func (o *Object) RangeFields(fn func(name string, value Value) bool) {
+	if o.Class != nil {
+		for slot, name := range o.Class.Layout().Names {
+			if slot < len(o.Slots) && !fn(name, o.Slots[slot]) {
+				return
+			}
+		}
+	}
+	for name, value := range o.Fields {
+		if !fn(name, value) {
+			return
+		}
+	}
+}
+
 // Annotation 注解
 // Args 使用 map 存储参数：
 // - 位置参数使用数字字符串作为 key（如 "0", "1", "2"）
@@ -2147,13 +2286,77 @@ type Class struct {
 	IsAttribute    bool     // 是否是注解类（有 @Attribute 标记）
 	Annotations    []*Annotation         // 类注解
 	Constants      map[string]Value
-	StaticVars     map[string]Value
+	StaticVars     map[string]Value      // 静态变量默认值（编译期常量，非常量初始值由 StaticInit 计算）
+	StaticInit     *Method               // 静态初始化器，每个 VM 首次使用类时执行一次（可为 nil）
 	Methods        map[string][]*Method  // 方法重载：同名不同参数数量
 	Properties     map[string]Value      // 属性默认值
 	PropVisibility map[string]Visibility // 属性可见性
 	PropFinal      map[string]bool       // 属性是否 final（不能被重新赋值）
 	PropAnnotations map[string][]*Annotation // 属性注解
 	VTables        map[string]*VTable    // 接口 VTable 映射 (接口名 -> VTable)
+
+	layout atomic.Pointer[FieldLayout] // 实例字段布局，首次使用时计算
+}
+
+// FieldLayout 类实例的字段槽位布局
+// 继承链上声明的属性父类在前、同一类内按名称排序编号，子类重新声明的属性沿用父类的槽位
+type FieldLayout struct {
+	Names    []string       // 槽位 -> 属性名
+	Index    map[string]int // 属性名 -> 槽位
+	Defaults []Value        // 槽位 -> 默认值 (子类的默认值覆盖父类)
+}
+
+// Layout 返回类实例的字段布局，首次调用时计算并缓存
+// 布局依赖父类链接，应在类加载完成后使用
+func (c *Class) Layout() *FieldLayout {
+	if l := c.layout.Load(); l != nil {
+		return l
+	}
+	l := &FieldLayout{Index: make(map[string]int)}
+	c.buildLayout(l)
+	c.layout.Store(l)
+	return l
+}
+
+// buildLayout 先父类后子类地把属性加入布局
+func (c *Class) buildLayout(l *FieldLayout) {
+	if c.Parent != nil {
+		c.Parent.buildLayout(l)
+	}
+	names := make([]string, 0, len(c.Properties))
+	for name := range c.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if slot, ok := l.Index[name]; ok {
+			l.Defaults[slot] = c.Properties[name]
+			continue
+		}
+		l.Index[name] = len(l.Names)
+		l.Names = append(l.Names, name)
+		l.Defaults = append(l.Defaults, c.Properties[name])
+	}
+}
+
+// StaticOwner 沿继承链查找声明了静态变量 name 的类
+func (c *Class) StaticOwner(name string) *Class {
+	for cls := c; cls != nil; cls = cls.Parent {
+		if _, ok := cls.StaticVars[name]; ok {
+			return cls
+		}
+	}
+	return nil
+}
+
+// LookupConstant 沿继承链查找类常量
+func (c *Class) LookupConstant(name string) (Value, bool) {
+	for cls := c; cls != nil; cls = cls.Parent {
+		if v, ok := cls.Constants[name]; ok {
+			return v, true
+		}
+	}
+	return NullValue, false
 }
 
 // FullName 获取类的完整名称（包括命名空间）
@@ -2271,6 +2474,7 @@ type Function struct {
 	IsBuiltin     bool     // 是否是内置函数
 	BuiltinFn     BuiltinFn // 内置函数实现
 	Inlinable     bool     // 是否可内联（由编译器设置）
+	MaxStack      int      // 调用帧需要的栈空间（局部变量与操作数），由 VM 首次调用时计算
 }
 
 // NewFunction 创建函数
@@ -2298,8 +2502,8 @@ type Upvalue struct {
 type Iterator struct {
 	Type       string // "array", "map" 或 "superarray"
 	Array      []Value
-	MapKeys    []Value
-	Map        map[Value]Value
+	MapKeys    []Key
+	Map        map[Key]Value
 	SuperArray *SuperArray
 	Index      int
 	HasValue   bool
@@ -2318,10 +2522,17 @@ func NewIterator(v Value) *Iterator {
 	case ValNativeArray:
 		iter.Type = "array"
 		iter.Array = v.AsNativeArray().ToValues()
+	case ValBytes:
+		iter.Type = "array"
+		b := v.AsBytes()
+		iter.Array = make([]Value, len(b))
+		for i, c := range b {
+			iter.Array[i] = NewInt(int64(c))
+		}
 	case ValMap:
 		iter.Type = "map"
 		iter.Map = v.AsMap()
-		iter.MapKeys = make([]Value, 0, len(iter.Map))
+		iter.MapKeys = make([]Key, 0, len(iter.Map))
 		for k := range iter.Map {
 			iter.MapKeys = append(iter.MapKeys, k)
 		}
@@ -2357,7 +2568,7 @@ func (it *Iterator) Key() Value {
 	case "superarray":
 		return it.SuperArray.Entries[it.Index].Key
 	default:
-		return it.MapKeys[it.Index]
+		return it.MapKeys[it.Index].Value()
 	}
 }
 
@@ -2462,6 +2673,8 @@ func (e *Exception) IsExceptionOfType(typeName string) bool {
 		return typeName == "Throwable"
 	case "RuntimeException":
 		return typeName == "Exception" || typeName == "Throwable"
+	case "SecurityException":
+		return typeName == "RuntimeException" || typeName == "Exception" || typeName == "Throwable"
 	case "Error":
 		return typeName == "Throwable"
 	default:
@@ -137,11 +137,25 @@ func (v *Verifier) verifyStackAndJumps() error {
 		}
 		
 		op := OpCode(v.chunk.Code[ip])
+		if op.IsQuickened() {
+			return &VerificationError{Offset: ip, Message: fmt.Sprintf("快速指令 %s 不能出现在字节码文件中", op)}
+		}
+		if op.IsFused() {
+			if v.chunk.FusedLength(ip) == 0 {
+				return &VerificationError{Offset: ip, Message: fmt.Sprintf("超级指令 %s 与其后的指令序列不匹配", op)}
+			}
+			// 序列其余指令保持原样，按第一条指令继续验证
+			op = op.Generic()
+		}
 		
 		// 根据指令类型更新栈
 		switch op {
 		// 栈操作
-		case OpPush, OpNull, OpTrue, OpFalse, OpZero, OpOne:
+		case OpPush:
+			stack++
+			ip += 3
+			continue
+		case OpNull, OpTrue, OpFalse, OpZero, OpOne:
 			stack++
 		case OpPop:
 			if stack < 1 {
@@ -276,12 +290,12 @@ func (v *Verifier) verifyStackAndJumps() error {
 			continue
 		
 		// 函数调用
-		case OpCall:
+		case OpCall, OpTailCall:
 			argCount := int(v.chunk.Code[ip+1])
-			if stack < argCount {
-				return &VerificationError{Offset: ip, Message: fmt.Sprintf("OpCall 参数不足: 需要 %d 个参数，栈上只有 %d 个", argCount, stack)}
+			if stack < argCount+1 {
+				return &VerificationError{Offset: ip, Message: fmt.Sprintf("%s 参数不足: 需要 %d 个参数，栈上只有 %d 个", op, argCount, stack)}
 			}
-			stack -= argCount
+			stack -= argCount + 1 // 参数和被调用者
 			// 函数返回值不确定，假设返回 1 个值
 			stack++
 			ip += 2
@@ -295,6 +309,33 @@ func (v *Verifier) verifyStackAndJumps() error {
 			ip++
 			continue
 		
+		// 静态成员
+		case OpGetStatic, OpSetStatic, OpCallStatic, OpTailCallStatic:
+			classIdx := int(v.chunk.ReadU16(ip + 1))
+			nameIdx := int(v.chunk.ReadU16(ip + 3))
+			if classIdx >= len(v.chunk.Constants) || nameIdx >= len(v.chunk.Constants) {
+				return &VerificationError{Offset: ip, Message: fmt.Sprintf("%s 常量池索引超出范围: %d, %d", op, classIdx, nameIdx)}
+			}
+			switch op {
+			case OpGetStatic:
+				stack++
+				ip += 5
+			case OpSetStatic:
+				if stack < 1 {
+					return &VerificationError{Offset: ip, Message: "OpSetStatic 时栈为空"}
+				}
+				ip += 5
+			case OpCallStatic, OpTailCallStatic:
+				argCount := int(v.chunk.Code[ip+5])
+				if stack < argCount {
+					return &VerificationError{Offset: ip, Message: fmt.Sprintf("%s 参数不足: 需要 %d 个参数，栈上只有 %d 个", op, argCount, stack)}
+				}
+				stack -= argCount
+				stack++
+				ip += 6
+			}
+			continue
+
 		// 对象操作
 		case OpNewObject:
 			constIdx := int(v.chunk.ReadU16(ip + 1))
@@ -318,15 +359,15 @@ func (v *Verifier) verifyStackAndJumps() error {
 			// 弹出对象和值，压入值
 			ip += 3
 			continue
-		case OpCallMethod:
+		case OpCallMethod, OpTailCallMethod:
 			// OpCallMethod: [nameIdx: u16] [argCount: u8]
 			constIdx := int(v.chunk.ReadU16(ip + 1))
 			if constIdx >= len(v.chunk.Constants) {
-				return &VerificationError{Offset: ip, Message: fmt.Sprintf("OpCallMethod 常量池索引超出范围: %d", constIdx)}
+				return &VerificationError{Offset: ip, Message: fmt.Sprintf("%s 常量池索引超出范围: %d", op, constIdx)}
 			}
 			argCount := int(v.chunk.Code[ip+3])
 			if stack < argCount+1 {
-				return &VerificationError{Offset: ip, Message: fmt.Sprintf("OpCallMethod 参数不足: 需要 %d 个参数+对象，栈上只有 %d 个", argCount+1, stack)}
+				return &VerificationError{Offset: ip, Message: fmt.Sprintf("%s 参数不足: 需要 %d 个参数+对象，栈上只有 %d 个", op, argCount+1, stack)}
 			}
 			stack -= argCount + 1
 			stack++ // 假设返回 1 个值
@@ -348,6 +389,7 @@ func (v *Verifier) verifyStackAndJumps() error {
 				return &VerificationError{Offset: ip, Message: "OpArrayGet 时栈元素少于 2 个（需要数组和索引）"}
 			}
 			// 弹出数组和索引，压入元素
+			stack--
 			ip++
 			continue
 		case OpArraySet:
@@ -355,6 +397,7 @@ func (v *Verifier) verifyStackAndJumps() error {
 				return &VerificationError{Offset: ip, Message: "OpArraySet 时栈元素少于 3 个（需要数组、索引和值）"}
 			}
 			// 弹出数组、索引和值，压入值
+			stack -= 2
 			ip++
 			continue
 		
@@ -382,19 +425,30 @@ func (v *Verifier) getOpSize(ip int) int {
 	if ip >= v.chunk.Len() {
 		return 1
 	}
-	op := OpCode(v.chunk.Code[ip])
+	op := OpCode(v.chunk.Code[ip]).Generic()
 	
 	switch op {
 	case OpPush, OpLoadLocal, OpStoreLocal, OpLoadGlobal, OpStoreGlobal,
-		OpNewObject, OpGetField, OpSetField, OpGetStatic, OpSetStatic,
-		OpCallStatic, OpNewArray, OpNewFixedArray, OpNewMap, OpSuperArrayNew,
-		OpNewBytes, OpCheckType, OpCast, OpCastSafe:
+		OpNewObject, OpGetField, OpSetField, OpNewArray,
+		OpNewMap, OpSuperArrayNew, OpNewBytes, OpCheckType, OpCast, OpCastSafe:
 		return 3 // op (1) + u16 (2)
-	case OpJump, OpJumpIfFalse, OpJumpIfTrue, OpLoop:
+	case OpNewFixedArray:
+		return 5 // op (1) + capacity (u16, 2) + initLength (u16, 2)
+	case OpNativeArrayNew, OpNativeArrayInit:
+		return 4 // op (1) + elemType (u8, 1) + length/count (u16, 2)
+	case OpGetStatic, OpSetStatic:
+		return 5 // op (1) + classIdx (u16, 2) + nameIdx (u16, 2)
+	case OpCallStatic, OpTailCallStatic:
+		return 6 // op (1) + classIdx (u16, 2) + methodIdx (u16, 2) + argCount (u8, 1)
+	case OpJump, OpJumpIfFalse, OpJumpIfTrue, OpLoop, OpGo:
 		return 3 // op (1) + i16/u16 (2)
-	case OpCall:
+	case OpCall, OpTailCall, OpCoroutineAwait, OpSelectStart:
 		return 2 // op (1) + u8 (1)
-	case OpCallMethod:
+	case OpSelectCase:
+		return 4 // op (1) + isRecv (u8, 1) + offset (i16, 2)
+	case OpSelectDefault:
+		return 3 // op (1) + offset (i16, 2)
+	case OpCallMethod, OpTailCallMethod:
 		return 4 // op (1) + nameIdx (u16, 2) + argCount (u8, 1)
 	case OpEnterTry:
 		// OpEnterTry: [catchCount: u8] [finallyOffset: i16] [typeIdx: u16, catchOffset: i16]*
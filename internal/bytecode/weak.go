@@ -0,0 +1,200 @@
+package bytecode
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+	"weak"
+)
+
+// ============================================================================
+// 弱引用与弱键映射
+// ============================================================================
+//
+// WeakRef 和 WeakMap 基于 Go 的 weak 包：弱指针不阻止 Go 垃圾回收器回收对象，
+// 对象被回收后弱引用返回 nil，弱键映射中以它为键的条目随之删除。
+// 只有对象可以被弱引用。
+//
+// 弱键映射的值是强引用：值引用了自己的键时，键永远不会被回收 (没有 ephemeron 语义)。
+
+// WeakRef 对象的弱引用
+type WeakRef struct {
+	target weak.Pointer[Object]
+}
+
+// NewWeakRef 创建对象的弱引用
+func NewWeakRef(obj *Object) *WeakRef {
+	return &WeakRef{target: weak.Make(obj)}
+}
+
+// Get 返回被引用的对象，对象已被回收时返回 nil
+func (w *WeakRef) Get() *Object {
+	return w.target.Value()
+}
+
+// WeakMap 以对象为弱键的映射 (线程安全)
+// 每个键登记一个 Go cleanup：键被回收后，cleanup 在 Go 的 cleanup goroutine 中删除它的条目，
+// 条目的值随之不再被引用，不需要脚本调用 size() 或 keys() 才能释放。
+// cleanup 只弱引用映射本身，映射先于键被回收时 cleanup 什么也不做
+type WeakMap struct {
+	mu      sync.Mutex
+	entries map[weak.Pointer[Object]]weakEntry
+}
+
+// weakEntry 弱键映射的条目
+type weakEntry struct {
+	value   Value
+	cleanup runtime.Cleanup // 键被回收时删除条目，条目被删除时取消
+}
+
+// NewWeakMap 创建空的弱键映射
+func NewWeakMap() *WeakMap {
+	return &WeakMap{entries: make(map[weak.Pointer[Object]]weakEntry)}
+}
+
+// Get 返回键对应的值
+func (m *WeakMap) Get(key *Object) (Value, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[weak.Make(key)]
+	return e.value, ok
+}
+
+// Set 设置键对应的值
+func (m *WeakMap) Set(key *Object, value Value) {
+	p := weak.Make(key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[p]; ok {
+		e.value = value
+		m.entries[p] = e
+		return
+	}
+	self := weak.Make(m)
+	cleanup := runtime.AddCleanup(key, func(p weak.Pointer[Object]) {
+		if m := self.Value(); m != nil {
+			m.mu.Lock()
+			delete(m.entries, p)
+			m.mu.Unlock()
+		}
+	}, p)
+	m.entries[p] = weakEntry{value: value, cleanup: cleanup}
+}
+
+// Delete 删除键，键存在时返回 true
+func (m *WeakMap) Delete(key *Object) bool {
+	p := weak.Make(key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[p]
+	if !ok {
+		return false
+	}
+	e.cleanup.Stop()
+	delete(m.entries, p)
+	return true
+}
+
+// Len 返回存活的条目数
+func (m *WeakMap) Len() int {
+	m.Purge()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// Purge 清除键已被回收、cleanup 尚未运行的条目
+func (m *WeakMap) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for p := range m.entries {
+		if p.Value() == nil {
+			delete(m.entries, p)
+		}
+	}
+}
+
+// Clear 删除所有条目
+func (m *WeakMap) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.entries {
+		e.cleanup.Stop()
+	}
+	clear(m.entries)
+}
+
+// Range 遍历存活的条目，fn 返回 false 时停止
+// 键已被回收的条目在遍历前清除，fn 中可以修改映射
+func (m *WeakMap) Range(fn func(key *Object, value Value) bool) {
+	type live struct {
+		key   *Object
+		value Value
+	}
+	m.mu.Lock()
+	entries := make([]live, 0, len(m.entries))
+	for p, e := range m.entries {
+		key := p.Value()
+		if key == nil {
+			delete(m.entries, p)
+			continue
+		}
+		entries = append(entries, live{key, e.value})
+	}
+	m.mu.Unlock()
+
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// Values 遍历所有条目的值 (不清除键已被回收的条目)，供内存统计使用
+func (m *WeakMap) Values(fn func(Value)) {
+	m.mu.Lock()
+	values := make([]Value, 0, len(m.entries))
+	for _, e := range m.entries {
+		values = append(values, e.value)
+	}
+	m.mu.Unlock()
+	for _, v := range values {
+		fn(v)
+	}
+}
+
+// NewWeakRefValue 创建弱引用值
+func NewWeakRefValue(w *WeakRef) Value {
+	return Value{typ: uint8(ValWeakRef), ptr: unsafe.Pointer(w)}
+}
+
+// AsWeakRef 获取弱引用
+func (v Value) AsWeakRef() *WeakRef {
+	if v.typ == uint8(ValWeakRef) && v.ptr != nil {
+		return (*WeakRef)(v.ptr)
+	}
+	return nil
+}
+
+// IsWeakRef 检查是否为弱引用
+func (v Value) IsWeakRef() bool {
+	return v.typ == uint8(ValWeakRef)
+}
+
+// NewWeakMapValue 创建弱键映射值
+func NewWeakMapValue(m *WeakMap) Value {
+	return Value{typ: uint8(ValWeakMap), ptr: unsafe.Pointer(m)}
+}
+
+// AsWeakMap 获取弱键映射
+func (v Value) AsWeakMap() *WeakMap {
+	if v.typ == uint8(ValWeakMap) && v.ptr != nil {
+		return (*WeakMap)(v.ptr)
+	}
+	return nil
+}
+
+// IsWeakMap 检查是否为弱键映射
+func (v Value) IsWeakMap() bool {
+	return v.typ == uint8(ValWeakMap)
+}
@@ -274,6 +274,12 @@ func (cb *CFGBuilder) buildStatement(stmt ast.Statement) {
 		// return 后创建新块（不可达代码）
 		cb.currentBlock = cb.cfg.NewBlock()
 		
+	case *ast.ThrowStmt:
+		cb.currentBlock.AddStatement(s)
+		// throw 与 return 一样终止当前路径
+		cb.currentBlock.HasReturn = true
+		cb.currentBlock = cb.cfg.NewBlock()
+		
 	case *ast.BreakStmt:
 		if len(cb.loopStack) > 0 {
 			ctx := cb.loopStack[len(cb.loopStack)-1]
@@ -291,6 +297,9 @@ func (cb *CFGBuilder) buildStatement(stmt ast.Statement) {
 	case *ast.TryStmt:
 		cb.buildTryStmt(s)
 		
+	case *ast.UsingStmt:
+		cb.buildStatement(lowerUsingStmt(s))
+		
 	default:
 		// 简单语句直接添加到当前块
 		cb.currentBlock.AddStatement(s)
@@ -585,6 +594,14 @@ func (cb *CFGBuilder) buildTryStmt(stmt *ast.TryStmt) {
 		catchBlock := cb.cfg.NewBlock()
 		tryBlock.AddSuccessor(catchBlock) // try 可能跳到任何 catch
 		
+		// catch 变量在进入 catch 块时由 VM 绑定，单独建块使其在块体入口处已定义
+		if catchClause.Variable != nil {
+			catchBlock.VarsDefined[catchClause.Variable.Name] = true
+			bodyBlock := cb.cfg.NewBlock()
+			catchBlock.AddSuccessor(bodyBlock)
+			catchBlock = bodyBlock
+		}
+		
 		cb.currentBlock = catchBlock
 		cb.buildStatement(catchClause.Body)
 		catchExits = append(catchExits, cb.currentBlock)
@@ -595,6 +612,8 @@ func (cb *CFGBuilder) buildTryStmt(stmt *ast.TryStmt) {
 	if stmt.Finally != nil {
 		finallyBlock := cb.cfg.NewBlock()
 		
+		// try 块中任意位置抛出的异常或 return 都会进入 finally
+		tryBlock.AddSuccessor(finallyBlock)
 		// try 和所有 catch 都到 finally
 		if tryExit != nil {
 			tryExit.AddSuccessor(finallyBlock)
@@ -99,6 +99,7 @@ func (c *Compiler) CompileClass(decl *ast.ClassDecl) *bytecode.Class {
 	}
 
 	// 编译属性
+	var staticInits []*ast.PropertyDecl // 需要在运行时计算初始值的静态属性
 	for _, prop := range decl.Properties {
 		// 处理有访问器的属性（自动属性、完整属性、表达式体属性）
 		if prop.Accessor != nil {
@@ -121,6 +122,9 @@ func (c *Compiler) CompileClass(decl *ast.ClassDecl) *bytecode.Class {
 			
 			if prop.Static {
 				class.StaticVars[prop.Name.Name] = value
+				if prop.Value != nil && c.needsStaticInit(prop.Value) {
+					staticInits = append(staticInits, prop)
+				}
 			} else {
 				class.Properties[prop.Name.Name] = value
 				class.PropVisibility[prop.Name.Name] = vis
@@ -144,6 +148,11 @@ func (c *Compiler) CompileClass(decl *ast.ClassDecl) *bytecode.Class {
 		class.AddMethod(m)
 	}
 
+	// 编译静态初始化器
+	if len(staticInits) > 0 {
+		class.StaticInit = c.compileStaticInit(class, staticInits)
+	}
+
 	// 验证接口实现
 	if len(decl.Implements) > 0 {
 		c.validateInterfaceImplementations(decl)
@@ -309,6 +318,7 @@ func (c *Compiler) compileMethod(class *bytecode.Class, decl *ast.MethodDecl) *b
 
 	// 添加默认返回
 	c.emit(bytecode.OpReturnNull)
+	c.finishChunk()
 
 	method.LocalCount = c.maxLocalCount // 使用 maxLocalCount 确保包含所有作用域内声明的变量
 	method.Chunk = c.function.Chunk
@@ -326,6 +336,82 @@ func (c *Compiler) compileMethod(class *bytecode.Class, decl *ast.MethodDecl) *b
 	return method
 }
 
+// needsStaticInit 检查静态属性初始值是否需要在运行时计算
+func (c *Compiler) needsStaticInit(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.BoolLiteral, *ast.NullLiteral:
+		return false
+	case *ast.ArrayLiteral:
+		for _, elem := range e.Elements {
+			if c.needsStaticInit(elem) {
+				return true
+			}
+		}
+		return false
+	case *ast.BinaryExpr, *ast.UnaryExpr:
+		// 无法折叠的表达式返回 null 标记
+		return c.evaluateConstExpr(e).IsNull()
+	default:
+		return true
+	}
+}
+
+// compileStaticInit 编译静态初始化器
+// 按声明顺序计算非常量的静态属性初始值并写入静态存储，由 VM 在首次使用类时执行一次
+func (c *Compiler) compileStaticInit(class *bytecode.Class, props []*ast.PropertyDecl) *bytecode.Method {
+	method := &bytecode.Method{
+		Name:       "<clinit>",
+		ClassName:  class.Name,
+		SourceFile: c.sourceFile,
+		IsStatic:   true,
+		Visibility: bytecode.VisPrivate,
+	}
+
+	// 保存当前状态
+	prevFn := c.function
+	prevLocals := c.locals
+	prevLocalCount := c.localCount
+	prevMaxLocalCount := c.maxLocalCount
+	prevScopeDepth := c.scopeDepth
+
+	c.function = bytecode.NewFunction(method.Name)
+	c.function.SourceFile = c.sourceFile
+	c.locals = make([]Local, 256)
+	c.localCount = 0
+	c.maxLocalCount = 0
+	c.scopeDepth = 0
+
+	classIdx := c.makeConstant(bytecode.NewString(c.currentClassName))
+	for _, prop := range props {
+		c.currentLine = prop.Name.Pos().Line
+		if prop.Type != nil {
+			declaredType := c.getTypeName(prop.Type)
+			actualType := c.inferExprType(prop.Value)
+			if actualType != "error" && !c.isTypeCompatible(actualType, declaredType) {
+				c.error(prop.Value.Pos(), i18n.T(i18n.ErrCannotAssign, actualType, declaredType))
+			}
+		}
+		c.compileExpr(prop.Value)
+		nameIdx := c.makeConstant(bytecode.NewString(prop.Name.Name))
+		c.emitU16(bytecode.OpSetStatic, classIdx)
+		c.currentChunk().WriteU16(nameIdx, c.currentLine)
+		c.emit(bytecode.OpPop)
+	}
+	c.emit(bytecode.OpReturnNull)
+
+	method.LocalCount = c.maxLocalCount
+	method.Chunk = c.function.Chunk
+
+	// 恢复状态
+	c.function = prevFn
+	c.locals = prevLocals
+	c.localCount = prevLocalCount
+	c.maxLocalCount = prevMaxLocalCount
+	c.scopeDepth = prevScopeDepth
+
+	return method
+}
+
 // compilePropertyWithAccessor 编译带访问器的属性（自动属性或完整属性）
 func (c *Compiler) compilePropertyWithAccessor(class *bytecode.Class, prop *ast.PropertyDecl) {
 	// 保存属性可见性
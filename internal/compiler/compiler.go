@@ -72,15 +72,43 @@ type Compiler struct {
 	// 函数内联相关字段
 	compiledFunctions map[string]*bytecode.Function // 已编译函数缓存（用于内联）
 	currentFuncName   string                        // 当前编译的函数名（防止自递归内联）
+	hasTailCall       bool                          // 当前函数是否包含尾调用（尾调用会复用调用方栈帧，不能内联）
+
+	// 尾调用相关字段：最近一次发出的调用指令，位于尾位置时改写为尾调用指令
+	lastCallChunk  *bytecode.Chunk
+	lastCallOffset int
 
 	// 数组访问边界检查优化相关字段
 	boundsCheckedArrays map[string]bool // 在当前循环中已验证边界的数组变量
 	loopBoundVar        string          // 当前循环的边界变量（用于 for 循环优化）
 	inOptimizedLoop     bool            // 是否在可优化边界检查的循环中
 
+	// 异常处理上下文：break/continue 跳出 try 时需要注销处理器并执行 finally
+	tryStack []tryContext
+
+	// 各函数体声明的局部变量和全局变量的调试信息，finishChunk 时写入字节码块
+	debugVars map[*bytecode.Chunk][]bytecode.VariableInfo
+
 	errors []Error
 }
 
+// tryRegion try 语句中当前所处的区域
+type tryRegion int
+
+const (
+	regionTry     tryRegion = iota // try 体
+	regionCatch                    // catch 体
+	regionFinally                  // finally 体
+)
+
+// tryContext 正在编译的 try 语句
+type tryContext struct {
+	loopDepth   int             // 进入 try 时的循环深度
+	switchDepth int             // 进入 try 时的 switch 深度
+	finally     *ast.BlockStmt  // finally 体 (可为 nil)
+	region      tryRegion       // 当前所处区域
+}
+
 // Local 局部变量
 type Local struct {
 	Name     string
@@ -119,6 +147,8 @@ func New() *Compiler {
 		exprCache:       make(map[string]int),
 		loopModifiedVars: make(map[string]bool),
 		loopHoistedExprs: make([]hoistedExpr, 0),
+		compiledFunctions: make(map[string]*bytecode.Function),
+		boundsCheckedArrays: make(map[string]bool),
 	}
 }
 
@@ -222,6 +252,7 @@ func (c *Compiler) Compile(file *ast.File) (*bytecode.Function, []Error) {
 
 	// 添加返回指令
 	c.emit(bytecode.OpReturnNull)
+	c.finishChunk()
 
 	// 使用 maxLocalCount 确保包含所有作用域内声明的变量
 	c.function.LocalCount = c.maxLocalCount
@@ -236,13 +267,16 @@ func (c *Compiler) CompileFunction(name string, params []*ast.Parameter, body *a
 	prevLocals := c.locals
 	prevLocalCount := c.localCount
 	prevMaxLocalCount := c.maxLocalCount
+	prevScopeDepth := c.scopeDepth
 	prevReturnType := c.returnType
 	prevExpectedReturns := c.expectedReturns
 	prevFuncName := c.currentFuncName
+	prevHasTailCall := c.hasTailCall
 
 	// 创建新函数
 	c.function = bytecode.NewFunction(name)
 	c.currentFuncName = name
+	c.hasTailCall = false
 	c.function.Arity = len(params)
 	c.function.SourceFile = c.sourceFile // 继承源文件信息
 	c.locals = make([]Local, 256)
@@ -306,6 +340,7 @@ func (c *Compiler) CompileFunction(name string, params []*ast.Parameter, body *a
 
 	// 添加默认返回
 	c.emit(bytecode.OpReturnNull)
+	c.finishChunk()
 
 	fn := c.function
 	fn.LocalCount = c.maxLocalCount // 使用 maxLocalCount 确保包含所有作用域内声明的变量
@@ -321,9 +356,11 @@ func (c *Compiler) CompileFunction(name string, params []*ast.Parameter, body *a
 	c.locals = prevLocals
 	c.localCount = prevLocalCount
 	c.maxLocalCount = prevMaxLocalCount
+	c.scopeDepth = prevScopeDepth
 	c.returnType = prevReturnType
 	c.expectedReturns = prevExpectedReturns
 	c.currentFuncName = prevFuncName
+	c.hasTailCall = prevHasTailCall
 
 	return fn
 }
@@ -721,6 +758,7 @@ func (c *Compiler) CompileClosureWithReturnType(name string, params []*ast.Param
 	prevLocalCount := c.localCount
 	prevMaxLocalCount := c.maxLocalCount
 	prevInClosure := c.inClosure
+	prevTryStack := c.tryStack
 	prevReturnType := c.returnType
 	prevExpectedReturns := c.expectedReturns
 
@@ -734,6 +772,7 @@ func (c *Compiler) CompileClosureWithReturnType(name string, params []*ast.Param
 	c.maxLocalCount = 0
 	c.scopeDepth = 0
 	c.inClosure = true // 标记在闭包中，禁止访问全局变量
+	c.tryStack = nil
 	
 	// 设置返回类型检查
 	c.returnType = returnType
@@ -795,6 +834,7 @@ func (c *Compiler) CompileClosureWithReturnType(name string, params []*ast.Param
 
 	// 添加默认返回
 	c.emit(bytecode.OpReturnNull)
+	c.finishChunk()
 
 	fn := c.function
 	fn.LocalCount = c.maxLocalCount // 使用 maxLocalCount 确保包含所有作用域内声明的变量
@@ -805,6 +845,7 @@ func (c *Compiler) CompileClosureWithReturnType(name string, params []*ast.Param
 	c.localCount = prevLocalCount
 	c.maxLocalCount = prevMaxLocalCount
 	c.inClosure = prevInClosure
+	c.tryStack = prevTryStack
 	c.returnType = prevReturnType
 	c.expectedReturns = prevExpectedReturns
 
@@ -877,6 +918,9 @@ func (c *Compiler) compileStmt(stmt ast.Statement) {
 	case *ast.TryStmt:
 		c.compileTryStmt(s)
 
+	case *ast.UsingStmt:
+		c.compileUsingStmt(s)
+
 	case *ast.ThrowStmt:
 		c.compileExpr(s.Exception)
 		c.emit(bytecode.OpThrow)
@@ -936,7 +980,10 @@ func (c *Compiler) compileVarDecl(s *ast.VarDeclStmt) {
 			return
 		}
 		
-		if s.Value != nil {
+		if elemType, ok := nativeElementType(c.getTypeName(arrType.ElementType)); ok {
+			// 基本类型元素使用原生数组存储
+			c.compileNativeFixedArray(elemType, capacity, s.Value)
+		} else if s.Value != nil {
 			// 有初始值
 			if arr, ok := s.Value.(*ast.ArrayLiteral); ok {
 				// 数组字面量初始化
@@ -998,6 +1045,12 @@ func (c *Compiler) compileVarDecl(s *ast.VarDeclStmt) {
 		c.globalTypes[s.Name.Name] = declaredType
 		idx := c.makeConstant(bytecode.NewString(s.Name.Name))
 		c.emitU16(bytecode.OpStoreGlobal, idx)
+		c.declareDebugVariable(bytecode.VariableInfo{
+			Name:   s.Name.Name,
+			Type:   declaredType,
+			Slot:   -1,
+			Global: int(idx),
+		})
 		c.emit(bytecode.OpPop) // 弹出值
 	}
 }
@@ -1884,10 +1937,13 @@ func (c *Compiler) compileBreakStmt() {
 		return
 	}
 	
+	// 如果在switch中但不在循环中，或者switch比循环更近，跳到switch结束
+	toSwitch := c.switchDepth > 0 && c.loopDepth == 0
+	c.exitTryBlocks(toSwitch)
+
 	jump := c.emitJump(bytecode.OpJump)
 	
-	// 如果在switch中但不在循环中，或者switch比循环更近，跳到switch结束
-	if c.switchDepth > 0 && c.loopDepth == 0 {
+	if toSwitch {
 		c.breakJumpsSwitch = append(c.breakJumpsSwitch, jump)
 	} else {
 		// 在循环中
@@ -1900,9 +1956,45 @@ func (c *Compiler) compileContinueStmt() {
 		c.error(token.Position{}, i18n.T(i18n.ErrContinueOutsideLoop))
 		return
 	}
+	c.exitTryBlocks(false)
 	c.emitLoop(c.loopStart)
 }
 
+// exitTryBlocks 为 break/continue 跳出的 try 语句注销处理器并内联执行 finally
+// toSwitch 为 true 时跳转目标是 switch，否则是最内层循环
+func (c *Compiler) exitTryBlocks(toSwitch bool) {
+	for i := len(c.tryStack) - 1; i >= 0; i-- {
+		t := c.tryStack[i]
+		if t.loopDepth != c.loopDepth || (toSwitch && t.switchDepth != c.switchDepth) {
+			// 跳转目标在这个 try 内部
+			break
+		}
+
+		switch t.region {
+		case regionTry:
+			c.emit(bytecode.OpLeaveTry)
+		case regionCatch:
+			// 没有 finally 的 try 在进入 catch 时处理器已被移除
+			if t.finally == nil {
+				continue
+			}
+			c.emit(bytecode.OpLeaveTry)
+		case regionFinally:
+			// 弹出 finally 记录，放弃挂起的动作
+			c.emit(bytecode.OpLeaveTry)
+			continue
+		}
+
+		if t.finally != nil {
+			// finally 体内的 break/continue 只对外层 try 生效
+			saved := c.tryStack
+			c.tryStack = c.tryStack[:i]
+			c.compileStmt(t.finally)
+			c.tryStack = saved
+		}
+	}
+}
+
 func (c *Compiler) compileReturnStmt(s *ast.ReturnStmt) {
 	actualReturns := len(s.Values)
 	
@@ -1941,17 +2033,8 @@ func (c *Compiler) compileReturnStmt(s *ast.ReturnStmt) {
 	if actualReturns == 0 {
 		c.emit(bytecode.OpReturnNull)
 	} else if actualReturns == 1 {
-		// 单返回值 - 检查是否是尾调用
-		if callExpr, ok := s.Values[0].(*ast.CallExpr); ok {
-			// 检测尾调用：return fn(...)
-			if c.isTailCallable(callExpr) {
-				c.compileTailCall(callExpr)
-				return
-			}
-		}
-		// 非尾调用，正常编译
-		c.compileExpr(s.Values[0])
-		c.emit(bytecode.OpReturn)
+		// 单返回值处于尾位置，其中的调用编译为尾调用
+		c.compileTailExpr(s.Values[0])
 	} else {
 		// 多返回值：用数组包装
 		for _, val := range s.Values {
@@ -1967,104 +2050,134 @@ func (c *Compiler) compileReturnStmt(s *ast.ReturnStmt) {
 // ============================================================================
 
 // compileGoStmt 编译 go 语句
+// 调用表达式内联在 OpGo 之后，由新协程在当前局部变量的快照上执行：
+//   OpGo skip -> <调用表达式> OpReturn -> OpPop
+// 当前协程跳过这段代码继续执行
 func (c *Compiler) compileGoStmt(s *ast.GoStmt) {
-	// 编译调用表达式
-	// go 语句需要将调用转换为闭包创建 + OpGo
-	
 	switch call := s.Call.(type) {
-	case *ast.CallExpr:
-		// 编译函数表达式
-		c.compileExpr(call.Function)
-		
-		// 编译参数
-		for _, arg := range call.Arguments {
-			c.compileExpr(arg)
-		}
-		
-		// 创建闭包并启动协程
-		// 注意：这是简化实现，实际需要创建一个包装闭包
-		c.emit(bytecode.OpGo)
-		c.emit(bytecode.OpPop) // 丢弃协程 ID
-		
-	case *ast.MethodCall:
-		// 编译对象
-		c.compileExpr(call.Object)
-		
-		// 编译参数
-		for _, arg := range call.Arguments {
-			c.compileExpr(arg)
+	case *ast.CallExpr, *ast.MethodCall:
+	case *ast.StaticAccess:
+		if _, ok := call.Member.(*ast.CallExpr); !ok {
+			c.error(s.Pos(), "go statement requires a function call")
+			return
 		}
-		
-		// 这里也需要创建闭包
-		c.emit(bytecode.OpGo)
-		c.emit(bytecode.OpPop) // 丢弃协程 ID
-		
 	default:
 		c.error(s.Pos(), "go statement requires a function call")
+		return
 	}
+
+	skip := c.emitJump(bytecode.OpGo)
+	c.compileExpr(s.Call)
+	c.emit(bytecode.OpReturn)
+	c.patchJump(skip)
+	c.emit(bytecode.OpPop) // 丢弃协程对象
 }
 
 // compileSelectStmt 编译 select 语句
+//
+//	<各 case 的操作数>
+//	OpSelectStart n
+//	OpSelectCase isRecv offset    (n 条)
+//	OpSelectDefault offset        (可选)
+//	OpSelectWait
+//	<case 体: 接收分支以接收到的值开始> OpJump end ...
+//
+// VM 选中分支后跳转到对应的 case 体，offset 相对 OpSelectWait 之后
 func (c *Compiler) compileSelectStmt(s *ast.SelectStmt) {
-	// 简化实现：编译 select 为一系列条件检查
-	// 完整实现需要更复杂的调度器支持
-	
-	// 发出 select 开始指令
-	caseCount := len(s.Cases)
-	if s.Default != nil {
-		caseCount++
+	if len(s.Cases) > 255 {
+		c.error(s.Pos(), "too many cases in select")
+		return
 	}
-	c.emit(bytecode.OpSelectStart)
-	c.currentChunk().WriteU8(uint8(caseCount), c.currentLine)
-	
-	// 编译每个 case 的通道操作
-	for _, selectCase := range s.Cases {
-		// 编译通道操作表达式
-		c.compileExpr(selectCase.Comm)
-		
-		// 发出 case 指令
-		isRecv := byte(0)
-		if selectCase.Var != nil {
-			isRecv = 1 // 接收操作
+
+	// 编译各 case 的通道和发送值
+	isRecv := make([]bool, len(s.Cases))
+	for i, sc := range s.Cases {
+		call, ok := sc.Comm.(*ast.MethodCall)
+		if !ok || (call.Method.Name != "receive" && call.Method.Name != "send") {
+			c.error(sc.Pos(), "select case must be a channel send or receive")
+			return
 		}
+		isRecv[i] = call.Method.Name == "receive"
+		if !isRecv[i] && len(call.Arguments) != 1 {
+			c.error(sc.Pos(), "send() requires exactly 1 argument")
+			return
+		}
+		if isRecv[i] && len(call.Arguments) != 0 {
+			c.error(sc.Pos(), "receive() takes no arguments")
+			return
+		}
+		if sc.Var != nil && !isRecv[i] {
+			c.error(sc.Pos(), "cannot assign the result of send()")
+			return
+		}
+
+		c.compileExpr(call.Object)
+		if !isRecv[i] {
+			c.compileExpr(call.Arguments[0])
+		}
+	}
+
+	// select 表
+	chunk := c.currentChunk()
+	c.emit(bytecode.OpSelectStart)
+	chunk.WriteU8(uint8(len(s.Cases)), c.currentLine)
+	casePatches := make([]int, len(s.Cases))
+	for i := range s.Cases {
 		c.emit(bytecode.OpSelectCase)
-		c.currentChunk().WriteU8(isRecv, c.currentLine)
-		c.currentChunk().WriteI16(0, c.currentLine) // 占位
+		if isRecv[i] {
+			chunk.WriteU8(1, c.currentLine)
+		} else {
+			chunk.WriteU8(0, c.currentLine)
+		}
+		casePatches[i] = chunk.Len()
+		chunk.WriteI16(0, c.currentLine)
 	}
-	
-	// 编译 default
+	defaultPatch := -1
 	if s.Default != nil {
 		c.emit(bytecode.OpSelectDefault)
-		c.currentChunk().WriteI16(0, c.currentLine) // 占位
+		defaultPatch = chunk.Len()
+		chunk.WriteI16(0, c.currentLine)
 	}
-	
-	// 发出等待指令
 	c.emit(bytecode.OpSelectWait)
-	
-	// 编译各个 case 的 body（简化：顺序执行第一个）
-	for _, selectCase := range s.Cases {
-		// 如果是接收操作，需要存储接收的值
-		if selectCase.Var != nil {
-			// 声明变量
-			c.declareVariable(selectCase.Var.Name)
-			slot := c.resolveLocal(selectCase.Var.Name)
-			if slot != -1 {
-				c.emitU16(bytecode.OpStoreLocal, uint16(slot))
+	tableEnd := chunk.Len()
+
+	patchOffset := func(pos int) {
+		offset := chunk.Len() - tableEnd
+		chunk.Code[pos] = byte(offset >> 8)
+		chunk.Code[pos+1] = byte(offset)
+	}
+
+	// case 体
+	var endJumps []int
+	for i, sc := range s.Cases {
+		patchOffset(casePatches[i])
+		c.beginScope()
+		if isRecv[i] {
+			if sc.Var != nil {
+				// 接收到的值已在栈上，直接作为局部变量
+				c.declareVariable(sc.Var.Name)
+			} else {
+				c.emit(bytecode.OpPop)
 			}
 		}
-		
-		// 编译 case body
-		for _, stmt := range selectCase.Body {
+		for _, stmt := range sc.Body {
 			c.compileStmt(stmt)
 		}
-		break // 简化：只执行第一个 case
+		c.endScope()
+		endJumps = append(endJumps, c.emitJump(bytecode.OpJump))
 	}
-	
-	// 编译 default body
-	if s.Default != nil && len(s.Cases) == 0 {
+
+	if s.Default != nil {
+		patchOffset(defaultPatch)
+		c.beginScope()
 		for _, stmt := range s.Default.Body {
 			c.compileStmt(stmt)
 		}
+		c.endScope()
+	}
+
+	for _, jump := range endJumps {
+		c.patchJump(jump)
 	}
 }
 
@@ -2134,17 +2247,9 @@ func (c *Compiler) compileCoroutineDelayExpr(e *ast.CoroutineDelayExpr) {
 }
 
 // compileChannelSelectExpr 编译 Channel::select(cases)
+// 基于 SelectCase 回调的 select 尚未实现，请使用 select 语句
 func (c *Compiler) compileChannelSelectExpr(e *ast.ChannelSelectExpr) {
-	// 编译 cases 数组
-	c.compileExpr(e.Cases)
-
-	// 发出 select 开始指令（OOP 版本）
-	// 注意：这里的实现与 select 语句不同，是基于回调的
-	c.emit(bytecode.OpSelectStart)
-	c.currentChunk().WriteU8(0, c.currentLine) // 占位，实际 case 数量在运行时确定
-
-	// 发出等待指令
-	c.emit(bytecode.OpSelectWait)
+	c.error(e.Pos(), "Channel::select() is not supported, use the select statement")
 }
 
 func (c *Compiler) compileTryStmt(s *ast.TryStmt) {
@@ -2170,11 +2275,18 @@ func (c *Compiler) compileTryStmt(s *ast.TryStmt) {
 		c.currentChunk().WriteI16(0, c.currentLine) // catchOffset 占位
 	}
 	
+	ctx := tryContext{loopDepth: c.loopDepth, switchDepth: c.switchDepth, region: regionTry}
+	if hasFinally {
+		ctx.finally = s.Finally.Body
+	}
+	c.tryStack = append(c.tryStack, ctx)
+
 	// 编译 try 块
 	c.compileStmt(s.Try)
 	
 	// 离开 try 块（正常流程）
 	c.emit(bytecode.OpLeaveTry)
+	c.tryStack[len(c.tryStack)-1].region = regionCatch
 	
 	// 如果有 finally，正常流程需要跳转到 finally；否则跳过所有 catch 块
 	var normalExitJump int
@@ -2212,7 +2324,7 @@ func (c *Compiler) compileTryStmt(s *ast.TryStmt) {
 		
 		// 异常值已经在栈上
 		if catch.Variable != nil {
-			c.addLocal(catch.Variable.Name)
+			c.addLocalWithType(catch.Variable.Name, typeName)
 		} else {
 			c.emit(bytecode.OpPop)
 		}
@@ -2249,6 +2361,7 @@ func (c *Compiler) compileTryStmt(s *ast.TryStmt) {
 		c.currentChunk().Code[enterTryPos+2] = byte(finallyOffset)
 		
 		c.emit(bytecode.OpEnterFinally)
+		c.tryStack[len(c.tryStack)-1].region = regionFinally
 		
 		// 编译 finally 块
 		c.compileStmt(s.Finally.Body)
@@ -2268,6 +2381,115 @@ func (c *Compiler) compileTryStmt(s *ast.TryStmt) {
 		c.currentChunk().Code[enterTryPos+1] = 0xFF
 		c.currentChunk().Code[enterTryPos+2] = 0xFF
 	}
+
+	c.tryStack = c.tryStack[:len(c.tryStack)-1]
+}
+
+// compileUsingStmt 编译 using 语句
+// 资源的类型必须实现 sola.lang.AutoCloseable，语句按 lowerUsingStmt 展开为 try/finally 后编译
+// 没有声明类型的资源 ($r := expr) 按初始化表达式推导的类型检查
+func (c *Compiler) compileUsingStmt(s *ast.UsingStmt) {
+	for _, res := range s.Resources {
+		typeName := ""
+		if res.Type != nil {
+			typeName = closeableTypeName(res.Type)
+		} else if res.Value != nil {
+			errCount := len(c.errors)
+			typeName = c.inferExprType(res.Value)
+			if len(c.errors) > errCount {
+				continue // 初始化表达式本身有错误
+			}
+		}
+		if typeName == "" || !c.symbolTable.ImplementsInterface(typeName, autoCloseableInterface, c.currentNamespace) {
+			shown := typeName
+			if shown == "" {
+				shown = "dynamic"
+			}
+			if res.Type != nil {
+				shown = c.getTypeName(res.Type)
+			}
+			c.error(res.Pos(), i18n.T(i18n.ErrUsingNotCloseable, res.Name.Name, shown))
+		}
+	}
+	c.compileStmt(lowerUsingStmt(s))
+}
+
+// autoCloseableInterface using 语句要求资源实现的接口
+const autoCloseableInterface = "sola.lang.AutoCloseable"
+
+// closeableTypeName 返回资源类型中的类名 (去掉可空标记)，不是类类型时返回空串
+func closeableTypeName(t ast.TypeNode) string {
+	switch t := t.(type) {
+	case *ast.ClassType, *ast.GenericType:
+		return typeNodeToString(t)
+	case *ast.NullableType:
+		return closeableTypeName(t.Inner)
+	case *ast.UnionType:
+		// Type|null
+		name := ""
+		for _, member := range t.Types {
+			if _, isNull := member.(*ast.NullType); isNull {
+				continue
+			}
+			if name != "" {
+				return ""
+			}
+			name = closeableTypeName(member)
+		}
+		return name
+	}
+	return ""
+}
+
+// lowerUsingStmt 把 using 语句展开为嵌套的 try/finally:
+//
+//	using (A $a = x, B $b = y) { body }
+//
+// 展开为
+//
+//	{ A $a = x; try { { B $b = y; try { body } finally { if ($b != null) { $b->close(); } } } }
+//	  finally { if ($a != null) { $a->close(); } } }
+//
+// 后声明的资源先关闭；某个资源的初始化抛出异常时，已经初始化的资源仍会被关闭
+func lowerUsingStmt(s *ast.UsingStmt) *ast.BlockStmt {
+	tok := func(t token.TokenType, lit string) token.Token {
+		return token.Token{Type: t, Literal: lit, Pos: s.UsingToken.Pos}
+	}
+	block := func(stmts ...ast.Statement) *ast.BlockStmt {
+		return &ast.BlockStmt{LBrace: tok(token.LBRACE, "{"), Statements: stmts, RBrace: tok(token.RBRACE, "}")}
+	}
+
+	body := s.Body
+	for i := len(s.Resources) - 1; i >= 0; i-- {
+		res := s.Resources[i]
+		ref := func() *ast.Variable {
+			return &ast.Variable{Token: res.Name.Token, Name: res.Name.Name}
+		}
+		closeStmt := &ast.IfStmt{
+			IfToken: tok(token.IF, "if"),
+			Condition: &ast.BinaryExpr{
+				Left:     ref(),
+				Operator: tok(token.NE, "!="),
+				Right:    &ast.NullLiteral{Token: tok(token.NULL, "null")},
+			},
+			Then: block(&ast.ExprStmt{
+				Expr: &ast.MethodCall{
+					Object: ref(),
+					Arrow:  tok(token.ARROW, "->"),
+					Method: &ast.Identifier{Token: tok(token.IDENT, "close"), Name: "close"},
+					LParen: tok(token.LPAREN, "("),
+					RParen: tok(token.RPAREN, ")"),
+				},
+				Semicolon: tok(token.SEMICOLON, ";"),
+			}),
+		}
+		body = block(res, &ast.TryStmt{
+			TryToken: tok(token.TRY, "try"),
+			Try:      body,
+			Finally:  &ast.FinallyClause{FinallyToken: tok(token.FINALLY, "finally"), Body: block(closeStmt)},
+		})
+	}
+	return body
 }
 
 // ============================================================================
@@ -2504,7 +2726,7 @@ func (c *Compiler) compileExpr(expr ast.Expression) {
 		c.compileIsExpr(e)
 
 	case *ast.MatchExpr:
-		c.compileMatchExpr(e)
+		c.compileMatchExpr(e, false)
 
 	case *ast.SwitchExpr:
 		c.compileSwitchExpr(e)
@@ -2812,8 +3034,8 @@ func (c *Compiler) compileAssignExpr(e *ast.AssignExpr) {
 			return
 		}
 		
-		// OpArraySet 会弹出 value, idx, array，然后 push value 作为表达式结果
-		c.emit(bytecode.OpArraySet)
+		// 赋值指令会弹出 value, idx, array，然后 push value 作为表达式结果
+		c.emit(c.containerOpsFor(idx.Object).set)
 		return
 	}
 	
@@ -2880,13 +3102,9 @@ func (c *Compiler) compileAssignTarget(target ast.Expression) {
 		c.emitU16(bytecode.OpSetField, idx)
 	case *ast.StaticAccess:
 		// 静态变量赋值
-		var className string
-		switch cls := t.Class.(type) {
-		case *ast.Identifier:
-			className = cls.Name
-		case *ast.SelfExpr:
-			className = "self"
-		default:
+		className, ok := c.resolveStaticClassName(t.Class)
+		if !ok {
+			c.error(t.Pos(), i18n.T(i18n.ErrInvalidStaticAccessC))
 			return
 		}
 		classIdx := c.makeConstant(bytecode.NewString(className))
@@ -2898,15 +3116,37 @@ func (c *Compiler) compileAssignTarget(target ast.Expression) {
 	}
 }
 
+// isUnsetTarget unset() 的参数能否在析构后置为 null (重复求值没有副作用)
+func isUnsetTarget(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.Variable:
+		return true
+	case *ast.PropertyAccess:
+		switch e.Object.(type) {
+		case *ast.Variable, *ast.ThisExpr:
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Compiler) compileCallExpr(e *ast.CallExpr) {
 	// 特殊处理 unset() 函数
+	// OpUnset 调用对象的析构函数；参数是变量或 $var->prop / $this->prop 时随后将其置为 null
 	if ident, ok := e.Function.(*ast.Identifier); ok && ident.Name == "unset" {
-		if len(e.Arguments) != 1 && len(e.NamedArguments) != 0 {
+		if len(e.Arguments) != 1 || len(e.NamedArguments) != 0 {
 			c.error(e.Pos(), "unset() requires exactly 1 argument")
 			return
 		}
-		c.compileExpr(e.Arguments[0])
+		target := e.Arguments[0]
+		c.compileExpr(target)
 		c.emit(bytecode.OpUnset)
+		if isUnsetTarget(target) {
+			// 直接存储 null，不做可空性检查：unset() 之后变量不应再被使用
+			c.emit(bytecode.OpPop)
+			c.emit(bytecode.OpNull)
+			c.compileAssignTarget(target)
+		}
 		return
 	}
 	
@@ -2944,53 +3184,84 @@ func (c *Compiler) compileCallExpr(e *ast.CallExpr) {
 	for _, arg := range args {
 		c.compileExpr(arg)
 	}
+	c.markCall()
 	c.emitByte(bytecode.OpCall, byte(len(args)))
 }
 
-// isTailCallable 检查调用表达式是否可以作为尾调用
-// 尾调用条件：
-// 1. 函数调用（Identifier 或 Variable）
-// 2. 不是方法调用（PropertyAccess）
-// 3. 不是闭包调用（ClosureExpr）
-// 4. 不是原生函数调用（native_ 开头）
-func (c *Compiler) isTailCallable(e *ast.CallExpr) bool {
-	// 必须是函数调用，不能是方法调用
-	switch fn := e.Function.(type) {
-	case *ast.Identifier:
-		// 排除原生函数调用 - 原生函数不支持尾调用优化
-		if strings.HasPrefix(fn.Name, "native_") {
-			return false
+// ============================================================================
+// 尾调用
+// ============================================================================
+
+// compileTailExpr 编译处于尾位置的返回值表达式，所有路径都以返回指令结束
+// 以调用结束的表达式改写为尾调用；三元表达式和 match 的各个分支同样处于尾位置
+func (c *Compiler) compileTailExpr(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.TernaryExpr:
+		if c.isConstExpr(e.Condition) {
+			if c.evaluateConstExpr(e.Condition).IsTruthy() {
+				c.compileTailExpr(e.Then)
+			} else {
+				c.compileTailExpr(e.Else)
+			}
+			return
 		}
-		// 普通函数调用，可以尾调用
-		return true
-	case *ast.Variable:
-		// 变量函数调用，可以尾调用
-		return true
-	case *ast.PropertyAccess:
-		// 方法调用，不支持尾调用（需要 this 上下文）
-		return false
-	case *ast.ClosureExpr:
-		// 闭包调用，不支持尾调用（可能有 upvalues）
-		return false
+		c.compileExpr(e.Condition)
+		elseJump := c.emitJump(bytecode.OpJumpIfFalse)
+		c.emit(bytecode.OpPop)
+		c.compileTailExpr(e.Then)
+		c.patchJump(elseJump)
+		c.emit(bytecode.OpPop)
+		c.compileTailExpr(e.Else)
+		return
+
+	case *ast.MatchExpr:
+		// 匹配成功的分支在各自的尾位置返回，这里只剩所有分支都不匹配的路径
+		c.compileMatchExpr(e, true)
+
 	default:
-		return false
+		start := c.currentChunk().Len()
+		c.compileExpr(expr)
+		c.markTailCall(start)
 	}
+	c.emit(bytecode.OpReturn)
 }
 
-// compileTailCall 编译尾调用
-// 尾调用使用 OpTailCall 指令，复用当前栈帧
-func (c *Compiler) compileTailCall(e *ast.CallExpr) {
-	// 处理命名参数
-	args := c.resolveNamedArguments(e)
-	
-	// 编译函数和参数（与普通调用相同）
-	c.compileExpr(e.Function)
-	for _, arg := range args {
-		c.compileExpr(arg)
+// markCall 记录即将发出的调用指令位置
+func (c *Compiler) markCall() {
+	c.lastCallChunk = c.currentChunk()
+	c.lastCallOffset = c.currentChunk().Len()
+}
+
+// markTailCall 从 start 开始编译的表达式以调用指令结束时，将其改写为对应的尾调用指令
+// 尾调用指令之后仍然发出 OpReturn：运行时无法复用栈帧 (如内置函数) 时退化为普通调用
+func (c *Compiler) markTailCall(start int) {
+	// try/catch/finally 内的调用返回后还需要执行处理器，不能复用帧
+	if len(c.tryStack) > 0 {
+		return
 	}
-	
-	// 使用 OpTailCall 而非 OpCall
-	c.emitByte(bytecode.OpTailCall, byte(len(args)))
+	chunk := c.currentChunk()
+	if c.lastCallChunk != chunk || c.lastCallOffset < start {
+		return
+	}
+
+	var tailOp bytecode.OpCode
+	var size int
+	switch bytecode.OpCode(chunk.Code[c.lastCallOffset]) {
+	case bytecode.OpCall:
+		tailOp, size = bytecode.OpTailCall, 2
+	case bytecode.OpCallMethod:
+		tailOp, size = bytecode.OpTailCallMethod, 4
+	case bytecode.OpCallStatic:
+		tailOp, size = bytecode.OpTailCallStatic, 6
+	default:
+		return
+	}
+	// 调用之后还有其他指令 (如 ?. 的跳转) 时不在尾位置
+	if c.lastCallOffset+size != chunk.Len() {
+		return
+	}
+	chunk.Code[c.lastCallOffset] = byte(tailOp)
+	c.hasTailCall = true
 }
 
 // isInlinable 检查函数是否可内联
@@ -3014,6 +3285,11 @@ func (c *Compiler) isInlinable(fn *bytecode.Function) bool {
 	if fn.UpvalueCount > 0 {
 		return false
 	}
+
+	// 含尾调用的函数不能内联（尾调用会复用调用方的栈帧）
+	if c.hasTailCall {
+		return false
+	}
 	
 	// 函数体指令数限制（<= 20）
 	if fn.Chunk.Len() > 20 {
@@ -3058,7 +3334,8 @@ func (c *Compiler) inlineFunction(targetFn *bytecode.Function, args []ast.Expres
 	// 复制字节码，调整局部变量引用
 	offset := 0
 	for offset < len(sourceChunk.Code) {
-		op := bytecode.OpCode(sourceChunk.Code[offset])
+		// 超级指令之后仍是原序列，按第一条指令 (LOAD_LOCAL) 复制即可
+		op := bytecode.OpCode(sourceChunk.Code[offset]).Generic()
 		
 		// 跳过最后的 OpReturnNull（内联时不需要返回）
 		if op == bytecode.OpReturnNull && offset == len(sourceChunk.Code)-1 {
@@ -3268,12 +3545,80 @@ func (c *Compiler) compileIndexExpr(e *ast.IndexExpr) {
 	c.compileExpr(e.Object)
 	c.compileExpr(e.Index)
 
-	// 判断是数组还是 Map (运行时处理)
 	// 数组访问边界检查优化：在已验证边界的循环中使用无检查版本
 	if c.inOptimizedLoop && c.canSkipBoundsCheck(e) {
 		c.emit(bytecode.OpArrayGetUnchecked)
 	} else {
-		c.emit(bytecode.OpArrayGet)
+		c.emit(c.containerOpsFor(e.Object).get)
+	}
+}
+
+// containerOps 容器的索引、赋值和长度指令
+type containerOps struct {
+	get, set, length bytecode.OpCode
+}
+
+var (
+	genericContainerOps     = containerOps{bytecode.OpArrayGet, bytecode.OpArraySet, bytecode.OpArrayLen}
+	mapContainerOps         = containerOps{bytecode.OpMapGet, bytecode.OpMapSet, bytecode.OpMapLen}
+	bytesContainerOps       = containerOps{bytecode.OpBytesGet, bytecode.OpBytesSet, bytecode.OpBytesLen}
+	nativeArrayContainerOps = containerOps{bytecode.OpNativeArrayGet, bytecode.OpNativeArraySet, bytecode.OpNativeArrayLen}
+)
+
+// containerOpsFor 根据表达式的静态类型选择容器指令
+// 静态类型未知时使用通用指令，由 VM 按运行时表示分派
+func (c *Compiler) containerOpsFor(expr ast.Expression) containerOps {
+	typ := c.inferExprType(expr)
+	switch {
+	case strings.HasPrefix(typ, "map["):
+		return mapContainerOps
+	case isBytesTypeName(typ):
+		return bytesContainerOps
+	case strings.HasSuffix(typ, "[]"):
+		if _, ok := nativeElementType(strings.TrimSuffix(typ, "[]")); ok {
+			return nativeArrayContainerOps
+		}
+	}
+	return genericContainerOps
+}
+
+// isBytesTypeName 检查类型名是否为 byte[]
+func isBytesTypeName(typ string) bool {
+	return typ == "byte[]" || typ == "u8[]"
+}
+
+// nativeElementType 返回基本类型元素的原生存储类型
+// 对象、数组等元素类型不能使用原生数组存储
+func nativeElementType(elemTypeName string) (bytecode.ValueType, bool) {
+	switch elemTypeName {
+	case "int", "i8", "i16", "i32", "i64", "uint", "u16", "u32", "u64",
+		"float", "f32", "f64", "bool", "string":
+		return valueTypeFromName(elemTypeName), true
+	}
+	return bytecode.ValNull, false
+}
+
+// compileNativeFixedArray 编译基本类型的定长数组声明
+// 先按容量创建默认值数组，再逐个写入字面量中的初始元素
+func (c *Compiler) compileNativeFixedArray(elemType bytecode.ValueType, capacity int, value ast.Expression) {
+	var elements []ast.Expression
+	if arr, ok := value.(*ast.ArrayLiteral); ok {
+		if len(arr.Elements) > capacity {
+			c.error(arr.Pos(), i18n.T(i18n.ErrArrayTooManyElements, capacity, len(arr.Elements)))
+			return
+		}
+		elements = arr.Elements
+	}
+
+	c.emit(bytecode.OpNativeArrayNew)
+	c.currentChunk().WriteU8(uint8(elemType), c.currentLine)
+	c.currentChunk().WriteU16(uint16(capacity), c.currentLine)
+	for i, elem := range elements {
+		c.emit(bytecode.OpDup)
+		c.emitConstant(bytecode.NewInt(int64(i)))
+		c.compileExpr(elem)
+		c.emit(bytecode.OpNativeArraySet)
+		c.emit(bytecode.OpPop)
 	}
 }
 
@@ -3299,7 +3644,7 @@ func (c *Compiler) compilePropertyAccess(e *ast.PropertyAccess) {
 
 	// 特殊属性处理
 	if e.Property.Name == "length" {
-		c.emit(bytecode.OpArrayLen)
+		c.emit(c.containerOpsFor(e.Object).length)
 		return
 	}
 
@@ -3461,10 +3806,20 @@ func (c *Compiler) compileMethodCall(e *ast.MethodCall) {
 		c.emit(bytecode.OpArrayPush)
 		return
 	case "length", "len":
-		// 获取长度
+		// 获取长度（通道为缓冲区中的元素数量）
 		c.compileExpr(e.Object)
-		c.emit(bytecode.OpArrayLen)
+		if c.extractBaseTypeName(c.inferExprType(e.Object)) == "Channel" {
+			c.emit(bytecode.OpChanLen)
+		} else {
+			c.emit(c.containerOpsFor(e.Object).length)
+		}
 		return
+	case "slice", "concat":
+		// byte[] 的切片与拼接
+		if isBytesTypeName(c.inferExprType(e.Object)) {
+			c.compileBytesMethod(e)
+			return
+		}
 
 	// =========================================================================
 	// 协程方法 (Coroutine<T>)
@@ -3537,11 +3892,13 @@ func (c *Compiler) compileMethodCall(e *ast.MethodCall) {
 		c.emit(bytecode.OpChanTryRecv)
 		return
 	case "close":
-		// $ch->close()
-		c.compileExpr(e.Object)
-		c.emit(bytecode.OpChanClose)
-		c.emit(bytecode.OpNull) // close 返回 void
-		return
+		// $ch->close()；已知是其他类的对象时按普通方法调用 (如 AutoCloseable 资源)
+		if t := c.extractBaseTypeName(c.inferExprType(e.Object)); t == "Channel" || t == "" {
+			c.compileExpr(e.Object)
+			c.emit(bytecode.OpChanClose)
+			c.emit(bytecode.OpNull) // close 返回 void
+			return
+		}
 	case "isClosed":
 		// $ch->isClosed()
 		c.compileExpr(e.Object)
@@ -3565,10 +3922,37 @@ func (c *Compiler) compileMethodCall(e *ast.MethodCall) {
 		c.compileExpr(arg)
 	}
 	idx := c.makeConstant(bytecode.NewString(e.Method.Name))
+	c.markCall()
 	c.emitU16(bytecode.OpCallMethod, idx)
 	c.currentChunk().WriteU8(byte(len(args)), c.currentLine) // 参数数量
 }
 
+// compileBytesMethod 编译 byte[] 的 slice(start[, end]) 与 concat(other)
+func (c *Compiler) compileBytesMethod(e *ast.MethodCall) {
+	c.compileExpr(e.Object)
+	switch e.Method.Name {
+	case "slice":
+		if len(e.Arguments) > 0 {
+			c.compileExpr(e.Arguments[0])
+		} else {
+			c.emit(bytecode.OpZero)
+		}
+		if len(e.Arguments) > 1 {
+			c.compileExpr(e.Arguments[1])
+		} else {
+			c.emit(bytecode.OpNull) // 截取到末尾
+		}
+		c.emit(bytecode.OpBytesSlice)
+	case "concat":
+		if len(e.Arguments) > 0 {
+			c.compileExpr(e.Arguments[0])
+		} else {
+			c.emitU16(bytecode.OpNewBytes, 0)
+		}
+		c.emit(bytecode.OpBytesConcat)
+	}
+}
+
 // resolveMethodCallNamedArguments 解析方法调用的命名参数
 func (c *Compiler) resolveMethodCallNamedArguments(e *ast.MethodCall) []ast.Expression {
 	// 如果没有命名参数，直接返回位置参数
@@ -3696,10 +4080,10 @@ func (c *Compiler) checkMethodCallArgTypes(e *ast.MethodCall) {
 	}
 }
 
-func (c *Compiler) compileStaticAccess(e *ast.StaticAccess) {
-	// 获取类名
+// resolveStaticClassName 解析静态访问的类名（Class、self、parent）
+func (c *Compiler) resolveStaticClassName(classExpr ast.Expression) (string, bool) {
 	var className string
-	switch cls := e.Class.(type) {
+	switch cls := classExpr.(type) {
 	case *ast.Identifier:
 		className = cls.Name
 		// 如果类名不包含命名空间，且当前有命名空间，尝试添加命名空间前缀
@@ -3731,6 +4115,15 @@ func (c *Compiler) compileStaticAccess(e *ast.StaticAccess) {
 			className = "parent"
 		}
 	default:
+		return "", false
+	}
+	return className, true
+}
+
+func (c *Compiler) compileStaticAccess(e *ast.StaticAccess) {
+	// 获取类名
+	className, ok := c.resolveStaticClassName(e.Class)
+	if !ok {
 		c.error(e.Pos(), i18n.T(i18n.ErrInvalidStaticAccessC))
 		return
 	}
@@ -3813,15 +4206,8 @@ func (c *Compiler) compileStaticAccess(e *ast.StaticAccess) {
 			} else if className == "Channel" {
 				switch fn.Name {
 				case "select":
-					// Channel::select(cases)
-					if len(member.Arguments) != 1 {
-						c.error(e.Pos(), "Channel::select() requires exactly 1 argument")
-						return
-					}
-					c.compileExpr(member.Arguments[0])
-					c.emit(bytecode.OpSelectStart)
-					c.currentChunk().WriteU8(0, c.currentLine)
-					c.emit(bytecode.OpSelectWait)
+					// Channel::select(cases) 基于 SelectCase 回调，尚未实现
+					c.error(e.Pos(), "Channel::select() is not supported, use the select statement")
 					return
 				}
 			}
@@ -3838,6 +4224,7 @@ func (c *Compiler) compileStaticAccess(e *ast.StaticAccess) {
 			for _, arg := range args {
 				c.compileExpr(arg)
 			}
+			c.markCall()
 			c.emitU16(bytecode.OpCallStatic, classIdx)
 			c.currentChunk().WriteU16(nameIdx, c.currentLine)
 			c.currentChunk().WriteU8(byte(len(args)), c.currentLine)
@@ -3958,6 +4345,21 @@ func (c *Compiler) checkStaticMethodArgTypes(className, methodName string, args
 }
 
 func (c *Compiler) compileNewExpr(e *ast.NewExpr) {
+	// new Channel<T>(capacity) 由 VM 直接创建通道
+	if e.ClassName.Name == "Channel" {
+		if len(e.Arguments) > 1 {
+			c.error(e.Pos(), "Channel constructor takes at most 1 argument")
+			return
+		}
+		if len(e.Arguments) == 1 {
+			c.compileExpr(e.Arguments[0])
+		} else {
+			c.emitConstant(bytecode.NewInt(0))
+		}
+		c.emit(bytecode.OpChanMake)
+		return
+	}
+
 	// 静态类型检查：检查构造函数参数类型
 	c.checkConstructorArgTypes(e)
 	
@@ -4085,9 +4487,6 @@ func (c *Compiler) compileNewArrayExpr(e *ast.NewArrayExpr) {
 	
 	if e.Size != nil {
 		// new int[5] - 创建指定大小的数组
-		// 先编译 size 表达式
-		c.compileExpr(e.Size)
-		
 		// 如果 size 是常量，直接使用
 		if intLit, ok := e.Size.(*ast.IntegerLiteral); ok {
 			// 使用 OpNativeArrayNew 指令
@@ -4095,8 +4494,6 @@ func (c *Compiler) compileNewArrayExpr(e *ast.NewArrayExpr) {
 			c.emit(bytecode.OpNativeArrayNew)
 			c.currentChunk().WriteU8(uint8(elemType), c.currentLine)
 			c.currentChunk().WriteU16(uint16(intLit.Value), c.currentLine)
-			c.emit(bytecode.OpSwap) // 交换栈顶，弹出 size
-			c.emit(bytecode.OpPop)  // 弹出 size
 		} else {
 			// 运行时确定大小 - 需要使用栈上的值
 			// 但目前 OpNativeArrayNew 只支持常量大小
@@ -4125,7 +4522,11 @@ func (c *Compiler) compileNewArrayExpr(e *ast.NewArrayExpr) {
 
 // getValueTypeFromTypeNode 从类型节点获取 ValueType
 func (c *Compiler) getValueTypeFromTypeNode(typeNode ast.TypeNode) bytecode.ValueType {
-	typeName := c.getTypeName(typeNode)
+	return valueTypeFromName(c.getTypeName(typeNode))
+}
+
+// valueTypeFromName 将元素类型名映射为原生数组的存储类型
+func valueTypeFromName(typeName string) bytecode.ValueType {
 	switch typeName {
 	case "int", "i8", "i16", "i32", "i64", "uint", "u8", "u16", "u32", "u64":
 		return bytecode.ValInt
@@ -4257,7 +4658,8 @@ func (c *Compiler) compileTypeCastExpr(e *ast.TypeCastExpr) {
 }
 
 // compileMatchExpr 编译模式匹配表达式
-func (c *Compiler) compileMatchExpr(e *ast.MatchExpr) {
+// tail 为 true 时 match 处于尾位置：各分支体编译为尾表达式并直接返回
+func (c *Compiler) compileMatchExpr(e *ast.MatchExpr, tail bool) {
 	// 编译被匹配的表达式
 	c.compileExpr(e.Expr)
 	
@@ -4341,23 +4743,28 @@ func (c *Compiler) compileMatchExpr(e *ast.MatchExpr) {
 			
 			// ====== 守卫成功路径 ======
 			// 编译 body
-			c.compileExpr(case_.Body)
-			
-			// 如果有变量绑定，手动弹出绑定的变量
-			if hasBinding {
+			if tail {
+				// 尾位置的分支直接返回，不需要清理被匹配的值和绑定变量
+				c.compileTailExpr(case_.Body)
+			} else {
+				c.compileExpr(case_.Body)
+				
+				// 如果有变量绑定，手动弹出绑定的变量
+				if hasBinding {
+					c.emit(bytecode.OpSwap)
+					c.emit(bytecode.OpPop)
+					c.truncateLocals(prevLocalCount)
+				}
+				
+				// 交换并弹出被匹配的值
 				c.emit(bytecode.OpSwap)
 				c.emit(bytecode.OpPop)
-				c.localCount = prevLocalCount
+				
+				// 跳转到 match 结束
+				bodyEndJump := c.emitJump(bytecode.OpJump)
+				endJumps = append(endJumps, bodyEndJump)
 			}
 			
-			// 交换并弹出被匹配的值
-			c.emit(bytecode.OpSwap)
-			c.emit(bytecode.OpPop)
-			
-			// 跳转到 match 结束
-			bodyEndJump := c.emitJump(bytecode.OpJump)
-			endJumps = append(endJumps, bodyEndJump)
-			
 			// ====== 守卫失败路径 ======
 			c.patchJump(guardJump)
 			// 注意：此时栈上有 [matched_value, bound_var (如果有), false]
@@ -4368,7 +4775,7 @@ func (c *Compiler) compileMatchExpr(e *ast.MatchExpr) {
 				// 栈: [matched_value, bound_var, false]
 				c.emit(bytecode.OpSwap) // [matched_value, false, bound_var]
 				c.emit(bytecode.OpPop)  // [matched_value, false]
-				c.localCount = prevLocalCount
+				c.truncateLocals(prevLocalCount)
 			}
 			// 现在栈: [matched_value, false]
 			// 跳转到下一个 case
@@ -4379,13 +4786,20 @@ func (c *Compiler) compileMatchExpr(e *ast.MatchExpr) {
 		
 		// ====== 没有守卫条件的情况 ======
 		// 编译 body
+		if tail {
+			c.compileTailExpr(case_.Body)
+			if hasBinding {
+				c.truncateLocals(prevLocalCount)
+			}
+			continue
+		}
 		c.compileExpr(case_.Body)
 		
 		// 如果有变量绑定，手动弹出绑定的变量
 		if hasBinding {
 			c.emit(bytecode.OpSwap)
 			c.emit(bytecode.OpPop)
-			c.localCount = prevLocalCount
+			c.truncateLocals(prevLocalCount)
 		}
 		
 		// 交换栈顶：现在栈上是 [被匹配的值, body结果]
@@ -4558,6 +4972,13 @@ func (c *Compiler) endScope() {
 		c.emit(bytecode.OpPop)
 		c.localCount--
 	}
+	c.endVariables(c.localCount)
+}
+
+// truncateLocals 丢弃槽位 n 及以上的局部变量 (值已由调用方弹出)
+func (c *Compiler) truncateLocals(n int) {
+	c.localCount = n
+	c.endVariables(n)
 }
 
 // cleanupCSECache 清理当前作用域结束时的 CSE 缓存
@@ -4654,6 +5075,35 @@ func (c *Compiler) addLocalWithType(name string, typeName string) {
 	if c.localCount > c.maxLocalCount {
 		c.maxLocalCount = c.localCount
 	}
+	// 保留槽位和编译器生成的临时变量 ($__iter__ 等) 不出现在调试信息中
+	if name != "" && !strings.HasPrefix(name, "$") {
+		c.declareDebugVariable(bytecode.VariableInfo{
+			Name:  name,
+			Type:  typeName,
+			Slot:  c.localCount - 1,
+		})
+	}
+}
+
+// declareDebugVariable 记录从当前位置开始可见的变量
+func (c *Compiler) declareDebugVariable(info bytecode.VariableInfo) {
+	if c.debugVars == nil {
+		c.debugVars = make(map[*bytecode.Chunk][]bytecode.VariableInfo)
+	}
+	chunk := c.currentChunk()
+	info.StartPC = len(chunk.Code)
+	c.debugVars[chunk] = append(c.debugVars[chunk], info)
+}
+
+// endVariables 结束槽位 n 及以上的局部变量的生命周期
+func (c *Compiler) endVariables(n int) {
+	chunk := c.currentChunk()
+	vars := c.debugVars[chunk]
+	for i := range vars {
+		if vars[i].Slot >= n && vars[i].EndPC == 0 {
+			vars[i].EndPC = len(chunk.Code)
+		}
+	}
 }
 
 // getLocalType 获取局部变量的类型
@@ -4729,6 +5179,16 @@ func (c *Compiler) emit(op bytecode.OpCode) {
 	c.currentChunk().WriteOp(op, c.currentLine)
 }
 
+// finishChunk 函数体生成完毕：融合超级指令 (不改变指令偏移) 并生成调试信息
+func (c *Compiler) finishChunk() {
+	chunk := c.currentChunk()
+	bytecode.FuseSuperinstructions(chunk)
+	chunk.Debug = bytecode.NewLineDebugInfo(c.sourceFile, chunk)
+	c.endVariables(-1)
+	chunk.Debug.Variables = append(chunk.Debug.Variables, c.debugVars[chunk]...)
+	delete(c.debugVars, chunk)
+}
+
 func (c *Compiler) emitByte(op bytecode.OpCode, b byte) {
 	c.emit(op)
 	c.currentChunk().WriteU8(b, c.currentLine)
@@ -5820,7 +6280,10 @@ func (c *Compiler) inferStaticAccessType(e *ast.StaticAccess) string {
 		c.error(e.Pos(), i18n.T(i18n.ErrStaticMemberNotFound, className, "$"+member.Name))
 		return "error"
 	case *ast.Identifier:
-		// 类常量 - 暂时返回 dynamic，后续可以增强常量类型追踪
+		// 类常量（枚举成员等未登记的常量返回 dynamic）
+		if sig := c.symbolTable.GetProperty(className, member.Name); sig != nil {
+			return sig.Type
+		}
 		return "dynamic"
 	case *ast.CallExpr:
 		// 静态方法调用
@@ -6067,6 +6530,9 @@ func (c *Compiler) getTypeName(t ast.TypeNode) string {
 	case *ast.TypeParameter:
 		// 类型参数直接返回其名称
 		return typ.Name.Name
+	case *ast.FuncType:
+		// 函数类型: func(int): int
+		return typ.String()
 	default:
 		return "unknown"
 	}
@@ -0,0 +1,43 @@
+package compiler
+
+import (
+	"github.com/tangzhangming/nova/internal/ast"
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// EvalVar 调试器求值时表达式可以访问的变量
+type EvalVar struct {
+	Name string // 变量名（不含 $）
+	Type string // 声明的类型，未知时为空
+}
+
+// CompileEval 编译调试器在暂停的帧中求值的表达式
+//
+// 生成的函数依次以 vars 为参数，slot 0 与方法相同，调用时传入 $this；
+// 函数返回数组 [表达式的值, 执行后各变量的值...]，调用方据此把表达式中的赋值写回暂停的帧。
+// className 为 $this 所属的类，用于方法内的类型推导，不在方法中时为空。
+func (c *Compiler) CompileEval(expr ast.Expression, className string, vars []EvalVar) (*bytecode.Function, []Error) {
+	c.sourceFile = "<eval>"
+	c.function = bytecode.NewFunction("<eval>")
+	c.function.SourceFile = c.sourceFile
+	c.function.Arity = len(vars)
+	c.function.MinArity = len(vars)
+	c.currentClassName = className
+	c.currentLine = expr.Pos().Line
+
+	c.addLocal("")
+	for _, v := range vars {
+		c.addLocalWithType(v.Name, v.Type)
+	}
+
+	c.compileExpr(expr)
+	for i := range vars {
+		c.emitU16(bytecode.OpLoadLocal, uint16(i+1))
+	}
+	c.emitU16(bytecode.OpNewArray, uint16(len(vars)+1))
+	c.emit(bytecode.OpReturn)
+	c.finishChunk()
+
+	c.function.LocalCount = c.maxLocalCount
+	return c.function, c.errors
+}
@@ -158,6 +158,13 @@ func (st *SymbolTable) registerBuiltinFunctions() {
 	st.Functions["die"] = &FunctionSignature{Name: "die", ParamTypes: []string{"string"}, ReturnType: "void", MinArity: 0}
 	st.Functions["exit"] = &FunctionSignature{Name: "exit", ParamTypes: []string{"int"}, ReturnType: "void", MinArity: 0}
 
+	// GC 与内存统计函数
+	st.Functions["gc_collect"] = &FunctionSignature{Name: "gc_collect", ParamTypes: []string{}, ReturnType: "int"}
+	st.Functions["gc_enable"] = &FunctionSignature{Name: "gc_enable", ParamTypes: []string{}, ReturnType: "void"}
+	st.Functions["gc_disable"] = &FunctionSignature{Name: "gc_disable", ParamTypes: []string{}, ReturnType: "void"}
+	st.Functions["gc_stats"] = &FunctionSignature{Name: "gc_stats", ParamTypes: []string{}, ReturnType: "map[string]any"}
+	st.Functions["gc_set_threshold"] = &FunctionSignature{Name: "gc_set_threshold", ParamTypes: []string{"int"}, ReturnType: "void"}
+
 	// 数学函数
 	st.Functions["abs"] = &FunctionSignature{Name: "abs", ParamTypes: []string{"float"}, ReturnType: "float"}
 	st.Functions["ceil"] = &FunctionSignature{Name: "ceil", ParamTypes: []string{"float"}, ReturnType: "float"}
@@ -394,14 +401,28 @@ func (st *SymbolTable) registerBuiltinFunctions() {
 
 	// 流操作函数 (native_stream_*)
 	st.Functions["native_stream_open"] = &FunctionSignature{Name: "native_stream_open", ParamTypes: []string{"string", "string"}, ReturnType: "int"}
-	st.Functions["native_stream_close"] = &FunctionSignature{Name: "native_stream_close", ParamTypes: []string{"int"}, ReturnType: "void"}
-	st.Functions["native_stream_read"] = &FunctionSignature{Name: "native_stream_read", ParamTypes: []string{"int", "int"}, ReturnType: "byte[]"}
-	st.Functions["native_stream_write"] = &FunctionSignature{Name: "native_stream_write", ParamTypes: []string{"int", "byte[]"}, ReturnType: "int"}
-	st.Functions["native_stream_seek"] = &FunctionSignature{Name: "native_stream_seek", ParamTypes: []string{"int", "int", "int"}, ReturnType: "int"}
+	st.Functions["native_stream_close"] = &FunctionSignature{Name: "native_stream_close", ParamTypes: []string{"int"}, ReturnType: "bool"}
+	st.Functions["native_resource_track"] = &FunctionSignature{Name: "native_resource_track", ParamTypes: []string{"dynamic", "string", "int"}, ReturnType: "void"}
+	st.Functions["native_stream_read"] = &FunctionSignature{Name: "native_stream_read", ParamTypes: []string{"int", "int"}, ReturnType: "string"}
+	st.Functions["native_stream_read_line"] = &FunctionSignature{Name: "native_stream_read_line", ParamTypes: []string{"int"}, ReturnType: "string"}
+	st.Functions["native_stream_write"] = &FunctionSignature{Name: "native_stream_write", ParamTypes: []string{"int", "string"}, ReturnType: "int"}
+	st.Functions["native_stream_seek"] = &FunctionSignature{Name: "native_stream_seek", ParamTypes: []string{"int", "int", "int"}, ReturnType: "bool"}
 	st.Functions["native_stream_tell"] = &FunctionSignature{Name: "native_stream_tell", ParamTypes: []string{"int"}, ReturnType: "int"}
-	st.Functions["native_stream_flush"] = &FunctionSignature{Name: "native_stream_flush", ParamTypes: []string{"int"}, ReturnType: "void"}
+	st.Functions["native_stream_flush"] = &FunctionSignature{Name: "native_stream_flush", ParamTypes: []string{"int"}, ReturnType: "bool"}
 	st.Functions["native_stream_eof"] = &FunctionSignature{Name: "native_stream_eof", ParamTypes: []string{"int"}, ReturnType: "bool"}
 
+	// 弱引用函数 (native_weakref_*, native_weakmap_*)
+	st.Functions["native_weakref_new"] = &FunctionSignature{Name: "native_weakref_new", ParamTypes: []string{"dynamic"}, ReturnType: "dynamic"}
+	st.Functions["native_weakref_get"] = &FunctionSignature{Name: "native_weakref_get", ParamTypes: []string{"dynamic"}, ReturnType: "dynamic"}
+	st.Functions["native_weakmap_new"] = &FunctionSignature{Name: "native_weakmap_new", ParamTypes: []string{}, ReturnType: "dynamic"}
+	st.Functions["native_weakmap_set"] = &FunctionSignature{Name: "native_weakmap_set", ParamTypes: []string{"dynamic", "dynamic", "dynamic"}, ReturnType: "void"}
+	st.Functions["native_weakmap_get"] = &FunctionSignature{Name: "native_weakmap_get", ParamTypes: []string{"dynamic", "dynamic"}, ReturnType: "dynamic"}
+	st.Functions["native_weakmap_has"] = &FunctionSignature{Name: "native_weakmap_has", ParamTypes: []string{"dynamic", "dynamic"}, ReturnType: "bool"}
+	st.Functions["native_weakmap_remove"] = &FunctionSignature{Name: "native_weakmap_remove", ParamTypes: []string{"dynamic", "dynamic"}, ReturnType: "bool"}
+	st.Functions["native_weakmap_size"] = &FunctionSignature{Name: "native_weakmap_size", ParamTypes: []string{"dynamic"}, ReturnType: "int"}
+	st.Functions["native_weakmap_keys"] = &FunctionSignature{Name: "native_weakmap_keys", ParamTypes: []string{"dynamic"}, ReturnType: "dynamic"}
+	st.Functions["native_weakmap_clear"] = &FunctionSignature{Name: "native_weakmap_clear", ParamTypes: []string{"dynamic"}, ReturnType: "void"}
+
 	// 反射函数 (native_reflect_*)
 	st.Functions["native_reflect_get_class"] = &FunctionSignature{Name: "native_reflect_get_class", ParamTypes: []string{"unknown"}, ReturnType: "string"}
 	st.Functions["native_reflect_get_methods"] = &FunctionSignature{Name: "native_reflect_get_methods", ParamTypes: []string{"unknown"}, ReturnType: "string[]"}
@@ -450,6 +471,14 @@ func (st *SymbolTable) registerBuiltinTypeMethods() {
 	st.RegisterMethod(&MethodSignature{ClassName: "SuperArray", MethodName: "clear", ParamTypes: []string{}, ReturnType: "void"})
 	st.RegisterMethod(&MethodSignature{ClassName: "SuperArray", MethodName: "copy", ParamTypes: []string{}, ReturnType: "SuperArray"})
 
+	// byte[] 字节数组方法
+	st.RegisterMethod(&MethodSignature{ClassName: "byte[]", MethodName: "length", ParamTypes: []string{}, ReturnType: "int"})
+	st.RegisterMethod(&MethodSignature{ClassName: "byte[]", MethodName: "len", ParamTypes: []string{}, ReturnType: "int"})
+	st.RegisterMethod(&MethodSignature{ClassName: "byte[]", MethodName: "has", ParamTypes: []string{"int"}, ReturnType: "bool"})
+	st.RegisterMethod(&MethodSignature{ClassName: "byte[]", MethodName: "slice", ParamTypes: []string{"int"}, ReturnType: "byte[]"})
+	st.RegisterMethod(&MethodSignature{ClassName: "byte[]", MethodName: "slice", ParamTypes: []string{"int", "int"}, ReturnType: "byte[]"})
+	st.RegisterMethod(&MethodSignature{ClassName: "byte[]", MethodName: "concat", ParamTypes: []string{"byte[]"}, ReturnType: "byte[]"})
+
 	// NativeArray 原生数组方法（使用 "Array" 作为类型名，通用于所有 T[]）
 	// 属性
 	st.RegisterMethod(&MethodSignature{ClassName: "Array", MethodName: "length", ParamTypes: []string{}, ReturnType: "int"})
@@ -478,6 +507,39 @@ func (st *SymbolTable) registerBuiltinTypeMethods() {
 	st.RegisterMethod(&MethodSignature{ClassName: "Array", MethodName: "max", ParamTypes: []string{}, ReturnType: "dynamic"})
 	st.RegisterMethod(&MethodSignature{ClassName: "Array", MethodName: "min", ParamTypes: []string{}, ReturnType: "dynamic"})
 	st.RegisterMethod(&MethodSignature{ClassName: "Array", MethodName: "average", ParamTypes: []string{}, ReturnType: "float"})
+
+	// Coroutine<T> 协程（VM 内置实现）
+	st.ClassSignatures["Coroutine"] = &ClassSignature{Name: "Coroutine", TypeParams: []*TypeParamInfo{{Name: "T"}}}
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "spawn", ParamTypes: []string{"dynamic"}, ReturnType: "Coroutine", IsStatic: true})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "all", ParamTypes: []string{"dynamic"}, ReturnType: "Coroutine", IsStatic: true})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "any", ParamTypes: []string{"dynamic"}, ReturnType: "Coroutine", IsStatic: true})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "race", ParamTypes: []string{"dynamic"}, ReturnType: "Coroutine", IsStatic: true})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "delay", ParamTypes: []string{"int"}, ReturnType: "Coroutine<void>", IsStatic: true})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "yield", ParamTypes: []string{}, ReturnType: "void", IsStatic: true})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "await", ParamTypes: []string{}, ReturnType: "T"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "await", ParamTypes: []string{"int"}, ReturnType: "T"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "join", ParamTypes: []string{}, ReturnType: "void"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "cancel", ParamTypes: []string{}, ReturnType: "bool"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Coroutine", MethodName: "tryGetResult", ParamTypes: []string{}, ReturnType: "?T"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "id", Type: "int"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "isCompleted", Type: "bool"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "isSucceeded", Type: "bool"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "isFailed", Type: "bool"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "isCancelled", Type: "bool"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "isRunning", Type: "bool"})
+	st.RegisterProperty(&PropertySignature{ClassName: "Coroutine", PropName: "exception", Type: "dynamic"})
+
+	// Channel<T> 通道（VM 内置实现）
+	st.ClassSignatures["Channel"] = &ClassSignature{Name: "Channel", TypeParams: []*TypeParamInfo{{Name: "T"}}}
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "__construct", ParamTypes: []string{"int"}, ReturnType: "void", MinArity: 0})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "send", ParamTypes: []string{"T"}, ReturnType: "void"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "receive", ParamTypes: []string{}, ReturnType: "T"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "trySend", ParamTypes: []string{"T"}, ReturnType: "bool"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "tryReceive", ParamTypes: []string{}, ReturnType: "?T"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "close", ParamTypes: []string{}, ReturnType: "void"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "isClosed", ParamTypes: []string{}, ReturnType: "bool"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "capacity", ParamTypes: []string{}, ReturnType: "int"})
+	st.RegisterMethod(&MethodSignature{ClassName: "Channel", MethodName: "length", ParamTypes: []string{}, ReturnType: "int"})
 }
 
 // RegisterFunction 注册函数签名
@@ -840,6 +902,20 @@ func (st *SymbolTable) collectFromClass(decl *ast.ClassDecl, namespace string) {
 		})
 	}
 	
+	// 收集类常量（按静态成员登记，Class::CONST 的类型推导与静态属性一致）
+	for _, constDecl := range decl.Constants {
+		constType := "dynamic"
+		if constDecl.Type != nil {
+			constType = typeNodeToString(constDecl.Type)
+		}
+		st.RegisterProperty(&PropertySignature{
+			ClassName: className,
+			PropName:  constDecl.Name.Name,
+			Type:      constType,
+			IsStatic:  true,
+		})
+	}
+	
 	// 收集方法
 	for _, method := range decl.Methods {
 		// 收集方法的泛型类型参数
@@ -962,6 +1038,15 @@ func (st *SymbolTable) collectFromInterface(decl *ast.InterfaceDecl, namespace s
 			IsStatic:   method.Static,
 		})
 	}
+	
+	// 接口继承的接口与类的 implements 一样记录
+	if len(decl.Extends) > 0 {
+		interfaces := make([]string, len(decl.Extends))
+		for i, iface := range decl.Extends {
+			interfaces[i] = extractBaseTypeName(typeNodeToString(iface))
+		}
+		st.ClassInterfaces[interfaceName] = interfaces
+	}
 }
 
 // typeNodeToString 将类型节点转换为字符串
@@ -1082,7 +1167,7 @@ func (st *SymbolTable) ValidateImplements(typeName, interfaceName string) error
 	}
 	
 	// 获取接口的所有方法签名
-	interfaceMethods, ok := st.ClassMethods[baseInterfaceName]
+	interfaceMethods, ok := st.lookupClassMethods(baseInterfaceName)
 	if !ok {
 		// 接口不存在，返回错误
 		return fmt.Errorf("interface '%s' not found", baseInterfaceName)
@@ -1120,6 +1205,23 @@ func (st *SymbolTable) ValidateImplements(typeName, interfaceName string) error
 	return nil
 }
 
+// lookupClassMethods 按名称查找类或接口的方法表
+// 短名 (如通过 use 导入的 AutoCloseable) 匹配任意命名空间中同名的类型
+func (st *SymbolTable) lookupClassMethods(name string) (map[string][]*MethodSignature, bool) {
+	if methods, ok := st.ClassMethods[name]; ok {
+		return methods, true
+	}
+	if !strings.Contains(name, ".") {
+		suffix := "." + name
+		for fullName, methods := range st.ClassMethods {
+			if strings.HasSuffix(fullName, suffix) {
+				return methods, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // compareMethodSignatures 比较两个方法签名是否兼容
 // 用于验证类方法是否满足接口方法的签名要求
 func (st *SymbolTable) compareMethodSignatures(interfaceMethod, classMethod *MethodSignature) bool {
@@ -1181,7 +1283,15 @@ func (st *SymbolTable) compareMethodSignatures(interfaceMethod, classMethod *Met
 // GetClassSignature 获取类的泛型签名
 func (st *SymbolTable) GetClassSignature(className string) *ClassSignature {
 	baseName := extractBaseTypeName(className)
-	return st.ClassSignatures[baseName]
+	if sig, ok := st.ClassSignatures[baseName]; ok {
+		return sig
+	}
+	// 回退到全局内置符号表
+	global := getGlobalBuiltinSymbols()
+	if global != nil && global != st {
+		return global.ClassSignatures[baseName]
+	}
+	return nil
 }
 
 // IsTypeCompatible 检查 actualType 是否与 targetType 兼容
@@ -1229,6 +1339,71 @@ func (st *SymbolTable) IsTypeCompatible(actualType, targetType string) bool {
 	return false
 }
 
+// ImplementsInterface 检查类或接口 typeName 是否实现了接口 iface (完整名称)
+// 沿父类链和接口的 extends 查找 implements 声明；声明中的短名按 iface 的最后一段匹配。
+// typeName 为短名时优先解析为命名空间 namespace 中的类型，符号表中没有的类型返回 false
+func (st *SymbolTable) ImplementsInterface(typeName, iface, namespace string) bool {
+	short := iface[strings.LastIndex(iface, ".")+1:]
+	seen := make(map[string]bool)
+	var implements func(name string) bool
+	implements = func(name string) bool {
+		full := st.resolveTypeName(extractBaseTypeName(name), namespace)
+		if full == "" || seen[full] {
+			return false
+		}
+		seen[full] = true
+		if full == iface || full == short {
+			return true
+		}
+		for _, declared := range st.ClassInterfaces[full] {
+			if declared == iface || declared == short || implements(declared) {
+				return true
+			}
+		}
+		if parent := st.ClassParents[full]; parent != "" {
+			return implements(parent)
+		}
+		return false
+	}
+	return implements(typeName)
+}
+
+// resolveTypeName 把类型名解析为符号表中类或接口的完整名称，未知类型返回空串
+// 短名依次尝试命名空间 namespace 中的同名类型、同名的无命名空间类型和任意命名空间中的同名类型
+func (st *SymbolTable) resolveTypeName(name, namespace string) string {
+	known := func(n string) bool {
+		_, hasMethods := st.ClassMethods[n]
+		_, hasProps := st.ClassProperties[n]
+		_, hasParent := st.ClassParents[n]
+		_, hasIfaces := st.ClassInterfaces[n]
+		return hasMethods || hasProps || hasParent || hasIfaces
+	}
+	if strings.Contains(name, ".") {
+		if known(name) {
+			return name
+		}
+		return ""
+	}
+	if namespace != "" && known(namespace+"."+name) {
+		return namespace + "." + name
+	}
+	if known(name) {
+		return name
+	}
+	suffix := "." + name
+	for fullName := range st.ClassMethods {
+		if strings.HasSuffix(fullName, suffix) {
+			return fullName
+		}
+	}
+	for fullName := range st.ClassInterfaces {
+		if strings.HasSuffix(fullName, suffix) {
+			return fullName
+		}
+	}
+	return ""
+}
+
 // GetClassInterfaces 获取类实现的接口列表
 func (st *SymbolTable) GetClassInterfaces(className string) []string {
 	baseName := extractBaseTypeName(className)
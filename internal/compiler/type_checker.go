@@ -243,6 +243,8 @@ func (tc *TypeChecker) checkStatement(stmt ast.Statement) {
 		tc.checkReturnStmt(s)
 	case *ast.TryStmt:
 		tc.checkTryStmt(s)
+	case *ast.UsingStmt:
+		tc.checkStatement(lowerUsingStmt(s))
 	case *ast.ThrowStmt:
 		tc.checkExpression(s.Exception)
 	case *ast.BreakStmt, *ast.ContinueStmt:
@@ -854,6 +856,16 @@ func (tc *TypeChecker) checkCallExpr(expr *ast.CallExpr) string {
 		}
 	}
 	
+	// 调用保存在变量中的闭包：从函数类型中取返回类型
+	if v, ok := expr.Function.(*ast.Variable); ok {
+		if varInfo := tc.lookupVariable(v.Name); varInfo != nil && strings.HasPrefix(varInfo.DeclaredType, "func(") {
+			if idx := strings.LastIndex(varInfo.DeclaredType, "): "); idx != -1 {
+				return varInfo.DeclaredType[idx+3:]
+			}
+			return "void"
+		}
+	}
+	
 	return "dynamic"
 }
 
@@ -895,15 +907,41 @@ func (tc *TypeChecker) checkMethodCall(expr *ast.MethodCall) string {
 		tc.checkExpression(arg)
 	}
 	
-	// 查找方法签名
+	// 查找方法签名（泛型类型 Box<int> 按 Box 查找，并替换返回类型中的类型参数）
 	baseType := tc.extractBaseType(objectType)
+	var typeArgs []string
+	if className, ok := tc.splitGenericType(baseType); ok {
+		for _, arg := range strings.Split(baseType[len(className)+1:len(baseType)-1], ",") {
+			typeArgs = append(typeArgs, strings.TrimSpace(arg))
+		}
+		baseType = className
+	}
 	if method := tc.symbolTable.GetMethod(baseType, expr.Method.Name, len(expr.Arguments)); method != nil {
-		return method.ReturnType
+		return tc.substituteTypeParams(method.ReturnType, baseType, typeArgs)
 	}
 	
 	return "dynamic"
 }
 
+// substituteTypeParams 将返回类型中的类型参数替换为实际类型参数
+func (tc *TypeChecker) substituteTypeParams(returnType, className string, typeArgs []string) string {
+	classSig := tc.symbolTable.GetClassSignature(className)
+	if classSig == nil || len(typeArgs) != len(classSig.TypeParams) {
+		return returnType
+	}
+	for i, tp := range classSig.TypeParams {
+		switch returnType {
+		case tp.Name:
+			return typeArgs[i]
+		case "?" + tp.Name:
+			return typeArgs[i] + "|null"
+		case tp.Name + "[]":
+			return typeArgs[i] + "[]"
+		}
+	}
+	return returnType
+}
+
 // checkIndexExpr 检查索引表达式
 func (tc *TypeChecker) checkIndexExpr(expr *ast.IndexExpr) string {
 	objectType := tc.checkExpression(expr.Object)
@@ -1030,8 +1068,7 @@ func (tc *TypeChecker) checkStaticAccess(expr *ast.StaticAccess) string {
 	case *ast.SelfExpr:
 		className = tc.currentClassName
 	case *ast.ParentExpr:
-		// parent 访问暂不支持，返回 any
-		return "dynamic"
+		className = tc.symbolTable.ClassParents[tc.extractBaseType(tc.currentClassName)]
 	}
 	
 	if className == "" {
@@ -1331,6 +1368,16 @@ func (tc *TypeChecker) getTypeName(typeNode ast.TypeNode) string {
 		return strings.Join(types, "|")
 	case *ast.ClassType:
 		return t.Name.Literal
+	case *ast.GenericType:
+		// 泛型类型: List<int> -> List<int>（与 compiler.go 中的 getTypeName 格式一致）
+		var args []string
+		for _, arg := range t.TypeArgs {
+			args = append(args, tc.getTypeName(arg))
+		}
+		return tc.getTypeName(t.BaseType) + "<" + strings.Join(args, ", ") + ">"
+	case *ast.FuncType:
+		// 函数类型: func(int): int（与 compiler.go 中的 getTypeName 格式一致）
+		return t.String()
 	default:
 		return "dynamic"
 	}
@@ -1391,6 +1438,13 @@ func (tc *TypeChecker) isTypeCompatible(actual, expected string) bool {
 		return tc.isTypeCompatible(actualElem, expectedElem)
 	}
 	
+	// 泛型类型与其原始类型兼容（new Box() 可赋给 Box<int>）
+	actualBase, actualGeneric := tc.splitGenericType(actual)
+	expectedBase, expectedGeneric := tc.splitGenericType(expected)
+	if (actualGeneric || expectedGeneric) && !(actualGeneric && expectedGeneric) {
+		return tc.isSubclassOf(actualBase, expectedBase)
+	}
+	
 	// 检查子类关系
 	if tc.isSubclassOf(actual, expected) {
 		return true
@@ -1399,6 +1453,14 @@ func (tc *TypeChecker) isTypeCompatible(actual, expected string) bool {
 	return false
 }
 
+// splitGenericType 拆分泛型类型名，返回基础类名以及是否带类型参数
+func (tc *TypeChecker) splitGenericType(typeName string) (string, bool) {
+	if idx := strings.Index(typeName, "<"); idx > 0 && strings.HasSuffix(typeName, ">") {
+		return typeName[:idx], true
+	}
+	return typeName, false
+}
+
 // isSubclassOf 检查是否是子类
 func (tc *TypeChecker) isSubclassOf(child, parent string) bool {
 	current := child
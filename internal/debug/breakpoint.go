@@ -5,13 +5,29 @@
 // 2. 条件断点
 // 3. 命中计数断点
 // 4. 日志断点
+// 5. 异常断点（所有异常、未捕获的异常、指定类型及其子类的异常）
+// 6. 数据断点（读写指定对象的字段或全局变量时中断）
+//
+// 断点按源码行设置，程序加载后映射到字节码偏移：取该文件的函数中
+// 不小于请求行号的第一个有代码的行，该行在各字节码块中的起始偏移即断点位置。
+// 映射之前断点未验证 (Verified 为 false)。
+//
+// 数据断点不对应源码位置，由虚拟机的访问钩子在字段或全局变量读写之后检查。
+// 监视的位置用数据 ID 表示 ("global:<索引>:<变量名>" 或 "field:<对象编号>:<字段名>")，
+// 对象编号由管理器分配，被监视的对象在调试期间保持可达。
 
 package debug
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/vm"
 )
 
 // BreakpointType 断点类型
@@ -26,8 +42,51 @@ const (
 	BreakpointHitCount
 	// BreakpointLog 日志断点
 	BreakpointLog
+	// BreakpointData 数据断点
+	BreakpointData
 )
 
+// DataAccess 数据断点的访问类型
+type DataAccess int
+
+const (
+	// AccessWrite 写入时中断
+	AccessWrite DataAccess = 1 << iota
+	// AccessRead 读取时中断
+	AccessRead
+	// AccessReadWrite 读取或写入时中断
+	AccessReadWrite = AccessRead | AccessWrite
+)
+
+// ParseDataAccess 解析访问类型 (read、write、readWrite)，空字符串为 write
+func ParseDataAccess(s string) (DataAccess, bool) {
+	switch s {
+	case "", "write":
+		return AccessWrite, true
+	case "read":
+		return AccessRead, true
+	case "readWrite":
+		return AccessReadWrite, true
+	}
+	return 0, false
+}
+
+// DataTarget 数据断点监视的位置：对象的字段或全局变量
+type DataTarget struct {
+	Object *bytecode.Object // 字段所属的对象，全局变量为 nil
+	Field  string           // 字段名
+	Global int              // 全局变量的索引
+	Name   string           // 全局变量名
+}
+
+// String 返回监视位置的显示名称 (类名.字段名 或 全局变量名)
+func (t DataTarget) String() string {
+	if t.Object != nil {
+		return t.Object.Class.Name + "." + t.Field
+	}
+	return t.Name
+}
+
 // Breakpoint 断点
 type Breakpoint struct {
 	// ID 唯一标识
@@ -51,12 +110,36 @@ type Breakpoint struct {
 	// 日志消息（用于日志断点）
 	LogMessage string
 	
+	// 监视的位置和访问类型（用于数据断点）
+	Data   DataTarget
+	Access DataAccess
+	
 	// 状态
 	Enabled  bool
 	Verified bool
 	
 	// 源码信息
 	Source string
+	
+	// 字节码位置（程序加载后映射）
+	Locations []Location
+	
+	requested int // 请求的行号（映射后 Line 可能移到下一个有代码的行）
+	
+	cond atomic.Pointer[condition] // 编译好的条件，条件修改时清除
+}
+
+// Location 断点在字节码中的位置
+type Location struct {
+	Chunk *bytecode.Chunk
+	PC    int
+}
+
+// ExceptionBreakpoints 异常断点：抛出的异常满足任一条件时在抛出处暂停
+type ExceptionBreakpoints struct {
+	All      bool     // 所有抛出的异常
+	Uncaught bool     // 调用栈上没有 catch 处理的异常
+	Types    []string // 指定类型及其子类的异常（类名或完整限定名）
 }
 
 // BreakpointManager 断点管理器
@@ -66,9 +149,25 @@ type BreakpointManager struct {
 	// 断点存储
 	breakpoints map[int]*Breakpoint
 	
-	// 按文件索引
+	// 按文件索引（规范化的路径）
 	byFile map[string]map[int]*Breakpoint
 	
+	// 按字节码块索引已映射的断点
+	byChunk map[*bytecode.Chunk][]*Breakpoint
+	
+	// 已加载的函数及其源文件（规范化的路径），程序加载前为 nil
+	functions []*bytecode.Function
+	files     map[*bytecode.Function]string
+	
+	// 异常断点
+	exceptions ExceptionBreakpoints
+	
+	// 数据断点，及数据 ID 中的对象编号
+	data      []*Breakpoint
+	dataCount atomic.Int32
+	objects   []*bytecode.Object
+	objectIDs map[*bytecode.Object]int
+	
 	// 下一个 ID
 	nextID int32
 }
@@ -78,18 +177,29 @@ func NewBreakpointManager() *BreakpointManager {
 	return &BreakpointManager{
 		breakpoints: make(map[int]*Breakpoint),
 		byFile:      make(map[string]map[int]*Breakpoint),
+		byChunk:     make(map[*bytecode.Chunk][]*Breakpoint),
 	}
 }
 
+// normalizePath 返回用于比较的文件路径
+func normalizePath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
+
 // Add 添加行断点
 func (m *BreakpointManager) Add(file string, line int) (*Breakpoint, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	key := normalizePath(file)
+	
 	// 检查是否已存在
-	if fileBreakpoints, ok := m.byFile[file]; ok {
+	if fileBreakpoints, ok := m.byFile[key]; ok {
 		for _, bp := range fileBreakpoints {
-			if bp.Line == line {
+			if bp.requested == line {
 				return nil, fmt.Errorf("breakpoint already exists at %s:%d", file, line)
 			}
 		}
@@ -97,22 +207,27 @@ func (m *BreakpointManager) Add(file string, line int) (*Breakpoint, error) {
 	
 	// 创建断点
 	bp := &Breakpoint{
-		ID:       int(atomic.AddInt32(&m.nextID, 1)),
-		Type:     BreakpointLine,
-		File:     file,
-		Line:     line,
-		Enabled:  true,
-		Verified: true,
+		ID:        int(atomic.AddInt32(&m.nextID, 1)),
+		Type:      BreakpointLine,
+		File:      file,
+		Line:      line,
+		Enabled:   true,
+		requested: line,
 	}
 	
 	// 添加到存储
 	m.breakpoints[bp.ID] = bp
 	
 	// 添加到文件索引
-	if m.byFile[file] == nil {
-		m.byFile[file] = make(map[int]*Breakpoint)
+	if m.byFile[key] == nil {
+		m.byFile[key] = make(map[int]*Breakpoint)
+	}
+	m.byFile[key][bp.ID] = bp
+	
+	// 程序已加载时立即映射
+	if m.functions != nil {
+		m.resolve(bp)
 	}
-	m.byFile[file][bp.ID] = bp
 	
 	return bp, nil
 }
@@ -178,13 +293,20 @@ func (m *BreakpointManager) Remove(id int) error {
 	// 从存储移除
 	delete(m.breakpoints, id)
 	
+	if bp.Type == BreakpointData {
+		m.removeData(bp)
+		return nil
+	}
+	
 	// 从文件索引移除
-	if fileBreakpoints, ok := m.byFile[bp.File]; ok {
+	key := normalizePath(bp.File)
+	if fileBreakpoints, ok := m.byFile[key]; ok {
 		delete(fileBreakpoints, id)
 		if len(fileBreakpoints) == 0 {
-			delete(m.byFile, bp.File)
+			delete(m.byFile, key)
 		}
 	}
+	m.unindex(bp)
 	
 	return nil
 }
@@ -196,6 +318,9 @@ func (m *BreakpointManager) RemoveAll() {
 	
 	m.breakpoints = make(map[int]*Breakpoint)
 	m.byFile = make(map[string]map[int]*Breakpoint)
+	m.byChunk = make(map[*bytecode.Chunk][]*Breakpoint)
+	m.data = nil
+	m.dataCount.Store(0)
 }
 
 // Get 获取断点
@@ -224,7 +349,7 @@ func (m *BreakpointManager) GetForFile(file string) []*Breakpoint {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
-	fileBreakpoints, ok := m.byFile[file]
+	fileBreakpoints, ok := m.byFile[normalizePath(file)]
 	if !ok {
 		return nil
 	}
@@ -264,17 +389,12 @@ func (m *BreakpointManager) Disable(id int) error {
 	return nil
 }
 
-// ShouldBreak 检查是否应该在指定位置中断
-func (m *BreakpointManager) ShouldBreak(file string, line int) bool {
+// ShouldBreak 检查执行到字节码块中的某一行时是否应该中断
+func (m *BreakpointManager) ShouldBreak(chunk *bytecode.Chunk, line int) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
-	fileBreakpoints, ok := m.byFile[file]
-	if !ok {
-		return false
-	}
-	
-	for _, bp := range fileBreakpoints {
+	for _, bp := range m.byChunk[chunk] {
 		if bp.Line == line && bp.Enabled {
 			// 增加命中计数
 			atomic.AddInt64(&bp.HitCount, 1)
@@ -303,6 +423,251 @@ func (m *BreakpointManager) ShouldBreak(file string, line int) bool {
 	return false
 }
 
+// SetExceptionBreakpoints 设置异常断点，取代之前的设置
+func (m *BreakpointManager) SetExceptionBreakpoints(eb ExceptionBreakpoints) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.exceptions = eb
+}
+
+// Loaded 程序是否已加载 (断点已映射到字节码)
+func (m *BreakpointManager) Loaded() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	return m.functions != nil
+}
+
+// ShouldBreakOnException 检查抛出异常时是否应该中断
+// caught 报告调用栈上是否有 catch 处理该异常，只在需要时调用
+func (m *BreakpointManager) ShouldBreakOnException(ex *bytecode.Exception, caught func() bool) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	if m.exceptions.All {
+		return true
+	}
+	for _, typeName := range m.exceptions.Types {
+		if vm.ExceptionIs(ex, typeName) {
+			return true
+		}
+	}
+	return m.exceptions.Uncaught && !caught()
+}
+
+// DataID 返回监视位置的数据 ID
+func (m *BreakpointManager) DataID(target DataTarget) string {
+	if target.Object == nil {
+		return fmt.Sprintf("global:%d:%s", target.Global, target.Name)
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	id, ok := m.objectIDs[target.Object]
+	if !ok {
+		if m.objectIDs == nil {
+			m.objectIDs = make(map[*bytecode.Object]int)
+		}
+		m.objects = append(m.objects, target.Object)
+		id = len(m.objects)
+		m.objectIDs[target.Object] = id
+	}
+	return fmt.Sprintf("field:%d:%s", id, target.Field)
+}
+
+// AddData 添加数据断点，dataID 为 DataID 返回的数据 ID
+func (m *BreakpointManager) AddData(dataID string, access DataAccess, condition, hitCondition string) (*Breakpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	target, err := m.parseDataID(dataID)
+	if err != nil {
+		return nil, err
+	}
+	if access&AccessReadWrite == 0 {
+		return nil, fmt.Errorf("invalid access type")
+	}
+	bp := &Breakpoint{
+		ID:           int(atomic.AddInt32(&m.nextID, 1)),
+		Type:         BreakpointData,
+		Condition:    condition,
+		HitCondition: hitCondition,
+		Enabled:      true,
+		Verified:     true,
+		Data:         target,
+		Access:       access,
+	}
+	m.breakpoints[bp.ID] = bp
+	m.data = append(m.data, bp)
+	m.dataCount.Add(1)
+	return bp, nil
+}
+
+// parseDataID 解析数据 ID (调用方持有锁)
+func (m *BreakpointManager) parseDataID(dataID string) (DataTarget, error) {
+	kind, rest, _ := strings.Cut(dataID, ":")
+	switch kind {
+	case "global":
+		index, name, ok := strings.Cut(rest, ":")
+		if n, err := strconv.Atoi(index); ok && err == nil && n >= 0 {
+			return DataTarget{Global: n, Name: name}, nil
+		}
+	case "field":
+		id, field, ok := strings.Cut(rest, ":")
+		if n, err := strconv.Atoi(id); ok && err == nil && n >= 1 && n <= len(m.objects) {
+			return DataTarget{Object: m.objects[n-1], Field: field}, nil
+		}
+	}
+	return DataTarget{}, fmt.Errorf("invalid data breakpoint: %s", dataID)
+}
+
+// HasData 是否设置了数据断点
+func (m *BreakpointManager) HasData() bool {
+	return m.dataCount.Load() > 0
+}
+
+// GetData 获取所有数据断点
+func (m *BreakpointManager) GetData() []*Breakpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	return append([]*Breakpoint(nil), m.data...)
+}
+
+// removeData 移除数据断点 (调用方持有写锁)
+func (m *BreakpointManager) removeData(bp *Breakpoint) {
+	for i, other := range m.data {
+		if other == bp {
+			m.data = append(m.data[:i:i], m.data[i+1:]...)
+			m.dataCount.Add(-1)
+			return
+		}
+	}
+}
+
+// ShouldBreakOnAccess 检查字段或全局变量访问之后是否应该中断，返回命中的数据断点
+// condition 在访问处求值断点的条件，只在断点有条件时调用，调用时不持有锁
+func (m *BreakpointManager) ShouldBreakOnAccess(a vm.Access, condition func(bp *Breakpoint) bool) *Breakpoint {
+	access := AccessRead
+	if a.Write {
+		access = AccessWrite
+	}
+	m.mu.RLock()
+	var matched []*Breakpoint
+	for _, bp := range m.data {
+		t := bp.Data
+		if !bp.Enabled || bp.Access&access == 0 || t.Object != a.Object {
+			continue
+		}
+		if (t.Object == nil && t.Global == a.Global) || (t.Object != nil && t.Field == a.Field) {
+			matched = append(matched, bp)
+		}
+	}
+	m.mu.RUnlock()
+	
+	// 与行断点相同：满足条件的访问计入命中次数，再检查命中条件
+	for _, bp := range matched {
+		if bp.Condition != "" && !condition(bp) {
+			continue
+		}
+		atomic.AddInt64(&bp.HitCount, 1)
+		if bp.HitCondition == "" || m.checkHitCondition(bp) {
+			return bp
+		}
+	}
+	return nil
+}
+
+// Resolve 程序加载后把所有断点映射到 functions 的字节码中
+// 返回验证状态或行号发生变化的断点
+func (m *BreakpointManager) Resolve(functions []*bytecode.Function) []*Breakpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.functions = functions
+	m.files = make(map[*bytecode.Function]string, len(functions))
+	for _, fn := range functions {
+		m.files[fn] = normalizePath(fn.SourceFile)
+	}
+	
+	var changed []*Breakpoint
+	for _, bp := range m.breakpoints {
+		if bp.Type == BreakpointData {
+			continue
+		}
+		verified, line := bp.Verified, bp.Line
+		m.resolve(bp)
+		if bp.Verified != verified || bp.Line != line {
+			changed = append(changed, bp)
+		}
+	}
+	return changed
+}
+
+// resolve 把断点映射到已加载函数的字节码 (调用方持有写锁)
+func (m *BreakpointManager) resolve(bp *Breakpoint) {
+	m.unindex(bp)
+	key := normalizePath(bp.File)
+	
+	// 不小于请求行号的第一个有代码的行
+	line := 0
+	for _, fn := range m.functions {
+		if fn.Chunk.Debug == nil || m.files[fn] != key {
+			continue
+		}
+		for _, l := range fn.Chunk.Debug.LineMap {
+			if l >= bp.requested && (line == 0 || l < line) {
+				line = l
+			}
+		}
+	}
+	
+	bp.Locations = nil
+	if line > 0 {
+		for _, fn := range m.functions {
+			if fn.Chunk.Debug == nil || m.files[fn] != key {
+				continue
+			}
+			for _, pc := range fn.Chunk.Debug.Breakpoints {
+				if fn.Chunk.Debug.LineMap[pc] == line {
+					bp.Locations = append(bp.Locations, Location{Chunk: fn.Chunk, PC: pc})
+				}
+			}
+		}
+	}
+	
+	bp.Verified = len(bp.Locations) > 0
+	bp.Line = bp.requested
+	if bp.Verified {
+		bp.Line = line
+	}
+	for _, loc := range bp.Locations {
+		if bps := m.byChunk[loc.Chunk]; len(bps) == 0 || bps[len(bps)-1] != bp {
+			m.byChunk[loc.Chunk] = append(bps, bp)
+		}
+	}
+}
+
+// unindex 从字节码块索引中移除断点 (调用方持有写锁)
+func (m *BreakpointManager) unindex(bp *Breakpoint) {
+	for _, loc := range bp.Locations {
+		bps := m.byChunk[loc.Chunk]
+		for i, other := range bps {
+			if other == bp {
+				bps = append(bps[:i:i], bps[i+1:]...)
+				break
+			}
+		}
+		if len(bps) == 0 {
+			delete(m.byChunk, loc.Chunk)
+		} else {
+			m.byChunk[loc.Chunk] = bps
+		}
+	}
+}
+
 // checkHitCondition 检查命中条件
 func (m *BreakpointManager) checkHitCondition(bp *Breakpoint) bool {
 	// 简单实现：解析 ">=N" 或 "==N" 或 "%N" 格式
@@ -355,6 +720,7 @@ func (m *BreakpointManager) SetCondition(id int, condition string) error {
 	}
 	
 	bp.Condition = condition
+	bp.cond.Store(nil)
 	if condition != "" {
 		bp.Type = BreakpointConditional
 	} else {
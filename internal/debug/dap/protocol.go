@@ -61,6 +61,8 @@ type LaunchRequestArguments struct {
 	Cwd        string `json:"cwd,omitempty"`
 	Env        map[string]string `json:"env,omitempty"`
 	StopOnEntry bool `json:"stopOnEntry,omitempty"`
+	// 求值表达式时允许调用方法和赋值 (默认只能读取变量)
+	AllowSideEffects bool `json:"allowSideEffects,omitempty"`
 }
 
 // AttachRequestArguments 附加请求参数
@@ -150,6 +152,57 @@ type EvaluateArguments struct {
 	Context    string `json:"context,omitempty"`
 }
 
+// SetVariableArguments 修改变量参数
+type SetVariableArguments struct {
+	VariablesReference int    `json:"variablesReference"`
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+}
+
+// SetExpressionArguments 修改表达式参数
+type SetExpressionArguments struct {
+	Expression string `json:"expression"`
+	Value      string `json:"value"`
+	FrameId    int    `json:"frameId,omitempty"`
+}
+
+// SetExceptionBreakpointsArguments 设置异常断点参数
+type SetExceptionBreakpointsArguments struct {
+	Filters       []string                 `json:"filters"`
+	FilterOptions []ExceptionFilterOptions `json:"filterOptions,omitempty"`
+}
+
+// ExceptionFilterOptions 带条件的异常过滤器
+type ExceptionFilterOptions struct {
+	FilterId  string `json:"filterId"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// ExceptionInfoArguments 异常信息参数
+type ExceptionInfoArguments struct {
+	ThreadId int `json:"threadId"`
+}
+
+// DataBreakpointInfoArguments 数据断点信息参数
+type DataBreakpointInfoArguments struct {
+	VariablesReference int    `json:"variablesReference,omitempty"`
+	Name               string `json:"name"`
+	FrameId            int    `json:"frameId,omitempty"`
+}
+
+// SetDataBreakpointsArguments 设置数据断点参数
+type SetDataBreakpointsArguments struct {
+	Breakpoints []DataBreakpoint `json:"breakpoints"`
+}
+
+// DataBreakpoint 数据断点
+type DataBreakpoint struct {
+	DataId       string `json:"dataId"`
+	AccessType   string `json:"accessType,omitempty"` // "read", "write", "readWrite"
+	Condition    string `json:"condition,omitempty"`
+	HitCondition string `json:"hitCondition,omitempty"`
+}
+
 // ============================================================================
 // 响应体
 // ============================================================================
@@ -173,6 +226,7 @@ type Capabilities struct {
 	SupportsExceptionOptions           bool `json:"supportsExceptionOptions,omitempty"`
 	SupportsValueFormattingOptions     bool `json:"supportsValueFormattingOptions,omitempty"`
 	SupportsExceptionInfoRequest       bool `json:"supportsExceptionInfoRequest,omitempty"`
+	SupportsExceptionFilterOptions     bool `json:"supportsExceptionFilterOptions,omitempty"`
 	SupportTerminateDebuggee           bool `json:"supportTerminateDebuggee,omitempty"`
 	SupportsDelayedStackTraceLoading   bool `json:"supportsDelayedStackTraceLoading,omitempty"`
 	SupportsLoadedSourcesRequest       bool `json:"supportsLoadedSourcesRequest,omitempty"`
@@ -180,6 +234,7 @@ type Capabilities struct {
 	SupportsTerminateThreadsRequest    bool `json:"supportsTerminateThreadsRequest,omitempty"`
 	SupportsSetExpression              bool `json:"supportsSetExpression,omitempty"`
 	SupportsTerminateRequest           bool `json:"supportsTerminateRequest,omitempty"`
+	SupportsDataBreakpoints            bool `json:"supportsDataBreakpoints,omitempty"`
 }
 
 // ExceptionBreakpointsFilter 异常断点过滤器
@@ -197,6 +252,15 @@ type SetBreakpointsResponseBody struct {
 	Breakpoints []Breakpoint `json:"breakpoints"`
 }
 
+// DataBreakpointInfoResponseBody 数据断点信息响应体
+// DataId 为 null 表示变量不能设置数据断点
+type DataBreakpointInfoResponseBody struct {
+	DataId      *string  `json:"dataId"`
+	Description string   `json:"description"`
+	AccessTypes []string `json:"accessTypes,omitempty"`
+	CanPersist  bool     `json:"canPersist,omitempty"`
+}
+
 // Breakpoint 断点信息
 type Breakpoint struct {
 	Id        int    `json:"id,omitempty"`
@@ -288,6 +352,42 @@ type EvaluateResponseBody struct {
 	IndexedVariables   int    `json:"indexedVariables,omitempty"`
 }
 
+// SetVariableResponseBody 修改变量响应体
+type SetVariableResponseBody struct {
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference,omitempty"`
+}
+
+// SetExpressionResponseBody 修改表达式响应体
+type SetExpressionResponseBody struct {
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference,omitempty"`
+}
+
+// SetExceptionBreakpointsResponseBody 设置异常断点响应体
+type SetExceptionBreakpointsResponseBody struct {
+	Breakpoints []Breakpoint `json:"breakpoints,omitempty"`
+}
+
+// ExceptionInfoResponseBody 异常信息响应体
+type ExceptionInfoResponseBody struct {
+	ExceptionId string            `json:"exceptionId"`
+	Description string            `json:"description,omitempty"`
+	BreakMode   string            `json:"breakMode"` // "never", "always", "unhandled", "userUnhandled"
+	Details     *ExceptionDetails `json:"details,omitempty"`
+}
+
+// ExceptionDetails 异常详情
+type ExceptionDetails struct {
+	Message        string             `json:"message,omitempty"`
+	TypeName       string             `json:"typeName,omitempty"`
+	FullTypeName   string             `json:"fullTypeName,omitempty"`
+	StackTrace     string             `json:"stackTrace,omitempty"`
+	InnerException []ExceptionDetails `json:"innerException,omitempty"`
+}
+
 // ThreadsResponseBody 线程响应体
 type ThreadsResponseBody struct {
 	Threads []Thread `json:"threads"`
@@ -299,6 +399,33 @@ type Thread struct {
 	Name string `json:"name"`
 }
 
+// CoroutinesResponseBody 协程响应体 (自定义 coroutines 请求)
+type CoroutinesResponseBody struct {
+	Coroutines []Coroutine `json:"coroutines"`
+}
+
+// Coroutine 协程及其阻塞的操作
+type Coroutine struct {
+	Id         int64              `json:"id"`
+	ThreadId   int                `json:"threadId"`
+	Name       string             `json:"name"`
+	State      string             `json:"state"`                // "running", "ready", "waiting"
+	WaitReason string             `json:"waitReason,omitempty"` // "chan send", "chan receive", "select", "await"...
+	Source     *Source            `json:"source,omitempty"`
+	Line       int                `json:"line,omitempty"`
+	Operations []ChannelOperation `json:"operations,omitempty"`
+}
+
+// ChannelOperation 阻塞的通道操作
+type ChannelOperation struct {
+	Operation string `json:"operation"` // "send", "receive"
+	Channel   int    `json:"channel"`   // 通道编号，同一响应中编号相同的是同一个通道
+	Capacity  int    `json:"capacity"`
+	Length    int    `json:"length"`
+	Closed    bool   `json:"closed,omitempty"`
+	Value     string `json:"value,omitempty"` // 待发送的值
+}
+
 // ============================================================================
 // 事件体
 // ============================================================================
@@ -308,12 +435,13 @@ type InitializedEventBody struct{}
 
 // StoppedEventBody 停止事件体
 type StoppedEventBody struct {
-	Reason            string `json:"reason"` // "step", "breakpoint", "exception", "pause", "entry"
+	Reason            string `json:"reason"` // "step", "breakpoint", "exception", "pause", "entry", "deadlock", "data breakpoint"
 	Description       string `json:"description,omitempty"`
 	ThreadId          int    `json:"threadId,omitempty"`
 	PreserveFocusHint bool   `json:"preserveFocusHint,omitempty"`
 	Text              string `json:"text,omitempty"`
 	AllThreadsStopped bool   `json:"allThreadsStopped,omitempty"`
+	HitBreakpointIds  []int  `json:"hitBreakpointIds,omitempty"`
 }
 
 // ContinuedEventBody 继续事件体
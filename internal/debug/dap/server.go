@@ -2,20 +2,32 @@
 //
 // 实现 Debug Adapter Protocol 服务器。
 // 支持通过 stdio 或 TCP 与 IDE 通信。
+//
+// launch 和 configurationDone 都收到后，在新的 goroutine 中用运行时执行程序，
+// 调试器通过执行钩子接入运行时的虚拟机。程序的标准输出转为 output 事件，
+// 程序结束时发送 exited 和 terminated 事件。
 
 package dap
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 
+	"github.com/tangzhangming/nova/internal/bytecode"
 	"github.com/tangzhangming/nova/internal/debug"
+	"github.com/tangzhangming/nova/internal/runtime"
+	"github.com/tangzhangming/nova/internal/vm"
 )
 
 // Server DAP 服务器
@@ -25,9 +37,10 @@ type Server struct {
 	// 调试器
 	debugger *debug.Debugger
 	
-	// 通信
-	reader *bufio.Reader
-	writer io.Writer
+	// 通信 (writeMu 保证消息不交错)
+	reader  *bufio.Reader
+	writer  io.Writer
+	writeMu sync.Mutex
 	
 	// 序列号
 	seq int32
@@ -35,9 +48,18 @@ type Server struct {
 	// 状态
 	initialized bool
 	launched    bool
+	configured  bool
+	started     bool
 	running     bool
 	
-	// 变量引用（用于变量查看）
+	// 求值时允许调用方法和赋值
+	allowSideEffects bool
+	
+	// 被调试的程序
+	cancel context.CancelFunc // 结束程序的执行
+	done   chan struct{}      // 程序结束后关闭
+	
+	// 变量引用（用于变量查看），程序继续执行时失效
 	variableRefs     map[int]variableRef
 	nextVariableRef  int
 	
@@ -48,7 +70,8 @@ type Server struct {
 // variableRef 变量引用
 type variableRef struct {
 	frameID  int
-	scope    string // "locals", "globals"
+	scope    string         // "locals", "globals", "value"
+	value    bytecode.Value // scope 为 "value" 时展开的值
 }
 
 // ServerConfig 服务器配置
@@ -56,30 +79,37 @@ type ServerConfig struct {
 	// 工作目录
 	WorkDir string
 	
-	// 程序路径
+	// 程序路径 (launch 请求未指定 program 时使用)
 	Program string
 	
 	// 程序参数
 	Args []string
+	
+	// 标准库目录，为空时使用运行时的默认位置
+	LibDir string
 }
 
 // NewServer 创建 DAP 服务器
 func NewServer(debugger *debug.Debugger) *Server {
+	return NewServerWithConfig(debugger, ServerConfig{})
+}
+
+// NewServerWithConfig 创建带配置的 DAP 服务器
+func NewServerWithConfig(debugger *debug.Debugger, config ServerConfig) *Server {
 	return &Server{
 		debugger:     debugger,
 		variableRefs: make(map[int]variableRef),
+		config:       config,
 	}
 }
 
 // ServeStdio 通过 stdio 提供服务
+// 程序的标准输出转为 output 事件，不会混入协议消息
 func (s *Server) ServeStdio() error {
-	s.reader = bufio.NewReader(os.Stdin)
-	s.writer = os.Stdout
-	
-	return s.serve()
+	return s.Serve(os.Stdin, os.Stdout)
 }
 
-// ServeTCP 通过 TCP 提供服务
+// ServeTCP 在 addr 上监听，为第一个连接提供服务
 func (s *Server) ServeTCP(addr string) error {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -87,23 +117,25 @@ func (s *Server) ServeTCP(addr string) error {
 	}
 	defer listener.Close()
 	
-	fmt.Fprintf(os.Stderr, "DAP server listening on %s\n", addr)
+	fmt.Fprintf(os.Stderr, "DAP server listening on %s\n", listener.Addr())
 	
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			return err
-		}
-		
-		s.reader = bufio.NewReader(conn)
-		s.writer = conn
-		
-		if err := s.serve(); err != nil {
-			fmt.Fprintf(os.Stderr, "Session error: %v\n", err)
-		}
-		
-		conn.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+	
+	return s.Serve(conn, conn)
+}
+
+// Serve 从 r 读取请求，向 w 写入响应和事件，直到客户端断开或发送 disconnect
+// 返回前结束仍在运行的程序
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.reader = bufio.NewReader(r)
+	s.writer = w
+	defer s.stopProgram()
+	
+	return s.serve()
 }
 
 // serve 主服务循环
@@ -193,6 +225,14 @@ func (s *Server) handleRequest(req *Request) {
 		s.handleSetBreakpoints(req)
 	case "setFunctionBreakpoints":
 		s.handleSetFunctionBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.handleSetExceptionBreakpoints(req)
+	case "exceptionInfo":
+		s.handleExceptionInfo(req)
+	case "dataBreakpointInfo":
+		s.handleDataBreakpointInfo(req)
+	case "setDataBreakpoints":
+		s.handleSetDataBreakpoints(req)
 	case "continue":
 		s.handleContinue(req)
 	case "next":
@@ -211,8 +251,14 @@ func (s *Server) handleRequest(req *Request) {
 		s.handleVariables(req)
 	case "evaluate":
 		s.handleEvaluate(req)
+	case "setVariable":
+		s.handleSetVariable(req)
+	case "setExpression":
+		s.handleSetExpression(req)
 	case "threads":
 		s.handleThreads(req)
+	case "coroutines":
+		s.handleCoroutines(req)
 	case "disconnect":
 		s.handleDisconnect(req)
 	case "terminate":
@@ -233,10 +279,15 @@ func (s *Server) handleInitialize(req *Request) {
 		SupportsConditionalBreakpoints:    true,
 		SupportsHitConditionalBreakpoints: true,
 		SupportsEvaluateForHovers:         true,
-		SupportsSetVariable:               false,
+		SupportsSetVariable:               true,
+		SupportsSetExpression:             true,
 		SupportsRestartRequest:            false,
 		SupportsTerminateRequest:          true,
 		SupportsLogPoints:                 true,
+		SupportsExceptionInfoRequest:      true,
+		SupportsExceptionFilterOptions:    true,
+		SupportsDataBreakpoints:           true,
+		ExceptionBreakpointFilters:        exceptionFilters,
 	}
 	
 	s.sendResponse(req, true, "", capabilities)
@@ -253,27 +304,35 @@ func (s *Server) handleLaunch(req *Request) {
 		return
 	}
 	
-	s.config.Program = args.Program
-	s.config.Args = args.Args
+	if args.Program != "" {
+		s.config.Program = args.Program
+	}
+	if args.Args != nil {
+		s.config.Args = args.Args
+	}
 	if args.Cwd != "" {
 		s.config.WorkDir = args.Cwd
 	}
+	if s.config.Program == "" {
+		s.sendErrorResponse(req, "no program to debug")
+		return
+	}
 	
+	s.debugger.SetStopOnEntry(args.StopOnEntry)
+	s.allowSideEffects = args.AllowSideEffects
 	s.launched = true
 	s.sendResponse(req, true, "", nil)
-	
-	// 如果配置了入口暂停
-	if args.StopOnEntry {
-		s.debugger.Pause()
-	}
+	s.startProgram()
 }
 
 func (s *Server) handleAttach(req *Request) {
-	s.sendResponse(req, true, "", nil)
+	s.sendErrorResponse(req, "attach is not supported, use launch")
 }
 
 func (s *Server) handleConfigurationDone(req *Request) {
+	s.configured = true
 	s.sendResponse(req, true, "", nil)
+	s.startProgram()
 }
 
 func (s *Server) handleSetBreakpoints(req *Request) {
@@ -314,12 +373,7 @@ func (s *Server) handleSetBreakpoints(req *Request) {
 				Line:     sbp.Line,
 			}
 		} else {
-			result[i] = Breakpoint{
-				Id:       bp.ID,
-				Verified: true,
-				Line:     bp.Line,
-				Source:   &Source{Path: file},
-			}
+			result[i] = breakpointInfo(bp, s.debugger.Loaded())
 		}
 	}
 	
@@ -328,6 +382,23 @@ func (s *Server) handleSetBreakpoints(req *Request) {
 	})
 }
 
+// breakpointInfo 返回断点的协议表示，loaded 表示程序已加载 (断点已映射到字节码)
+func breakpointInfo(bp *debug.Breakpoint, loaded bool) Breakpoint {
+	info := Breakpoint{
+		Id:       bp.ID,
+		Verified: bp.Verified,
+		Line:     bp.Line,
+		Source:   &Source{Path: bp.File},
+	}
+	if !bp.Verified {
+		info.Message = "no code at this line"
+		if !loaded {
+			info.Message = "pending until the program is loaded"
+		}
+	}
+	return info
+}
+
 func (s *Server) handleSetFunctionBreakpoints(req *Request) {
 	// 暂不支持函数断点
 	s.sendResponse(req, true, "", SetBreakpointsResponseBody{
@@ -335,30 +406,229 @@ func (s *Server) handleSetFunctionBreakpoints(req *Request) {
 	})
 }
 
+// 异常断点过滤器
+var exceptionFilters = []ExceptionBreakpointsFilter{
+	{
+		Filter:      "all",
+		Label:       "All Exceptions",
+		Description: "Break when any exception is thrown",
+	},
+	{
+		Filter:      "uncaught",
+		Label:       "Uncaught Exceptions",
+		Description: "Break when an exception is thrown that no catch handles",
+		Default:     true,
+	},
+	{
+		Filter:               "type",
+		Label:                "Exceptions of Type",
+		Description:          "Break when an exception of the given classes or their subclasses is thrown",
+		SupportsCondition:    true,
+		ConditionDescription: "Comma separated class names, e.g. \"IOException, sola.lang.RuntimeException\"",
+	},
+}
+
+func (s *Server) handleSetExceptionBreakpoints(req *Request) {
+	var args SetExceptionBreakpointsArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	// filters 和 filterOptions 中的过滤器都启用，类型过滤器的条件是类名列表
+	options := args.FilterOptions
+	for _, filter := range args.Filters {
+		options = append(options, ExceptionFilterOptions{FilterId: filter})
+	}
+	
+	var eb debug.ExceptionBreakpoints
+	result := make([]Breakpoint, len(options))
+	for i, opt := range options {
+		result[i] = Breakpoint{Verified: true}
+		switch opt.FilterId {
+		case "all":
+			eb.All = true
+		case "uncaught":
+			eb.Uncaught = true
+		case "type":
+			for _, name := range strings.Split(opt.Condition, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					eb.Types = append(eb.Types, name)
+				}
+			}
+		default:
+			result[i] = Breakpoint{Verified: false, Message: fmt.Sprintf("unknown exception filter: %s", opt.FilterId)}
+		}
+	}
+	s.debugger.SetExceptionBreakpoints(eb)
+	
+	s.sendResponse(req, true, "", SetExceptionBreakpointsResponseBody{Breakpoints: result})
+}
+
+func (s *Server) handleExceptionInfo(req *Request) {
+	info := s.debugger.GetException()
+	if info == nil {
+		s.sendErrorResponse(req, "not stopped on an exception")
+		return
+	}
+	
+	breakMode := "always"
+	if info.Uncaught {
+		breakMode = "unhandled"
+	}
+	s.sendResponse(req, true, "", ExceptionInfoResponseBody{
+		ExceptionId: info.Type,
+		Description: info.Message,
+		BreakMode:   breakMode,
+		Details:     exceptionDetails(info),
+	})
+}
+
+// exceptionDetails 返回异常及其原因链的协议表示，原因放在 innerException 中
+func exceptionDetails(info *debug.ExceptionInfo) *ExceptionDetails {
+	typeName := info.Type
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		typeName = typeName[i+1:]
+	}
+	details := &ExceptionDetails{
+		Message:      info.Message,
+		TypeName:     typeName,
+		FullTypeName: info.Type,
+		StackTrace:   info.StackTrace,
+	}
+	if info.Cause != nil {
+		details.InnerException = []ExceptionDetails{*exceptionDetails(info.Cause)}
+	}
+	return details
+}
+
+// 数据断点支持的访问类型
+var dataAccessTypes = []string{"read", "write", "readWrite"}
+
+// handleDataBreakpointInfo 返回变量视图中的对象字段或全局变量的数据 ID
+func (s *Server) handleDataBreakpointInfo(req *Request) {
+	var args DataBreakpointInfoArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	s.mu.RLock()
+	ref, ok := s.variableRefs[args.VariablesReference]
+	s.mu.RUnlock()
+	
+	var target *debug.DataTarget
+	switch {
+	case ok && ref.scope == "value" && ref.value.Type() == bytecode.ValObject:
+		if obj := ref.value.AsObject(); obj != nil {
+			if _, ok := obj.GetField(args.Name); ok {
+				target = &debug.DataTarget{Object: obj, Field: args.Name}
+			}
+		}
+	case ok && ref.scope == "globals", args.VariablesReference == 0:
+		for _, g := range s.debugger.GetGlobals() {
+			if g.Name == strings.TrimPrefix(args.Name, "$") {
+				target = &debug.DataTarget{Global: g.Global, Name: g.Name}
+				break
+			}
+		}
+	}
+	if target == nil {
+		s.sendResponse(req, true, "", DataBreakpointInfoResponseBody{
+			Description: "Only object fields and global variables can be watched",
+		})
+		return
+	}
+	
+	id := s.debugger.DataBreakpointID(*target)
+	s.sendResponse(req, true, "", DataBreakpointInfoResponseBody{
+		DataId:      &id,
+		Description: target.String(),
+		AccessTypes: dataAccessTypes,
+	})
+}
+
+func (s *Server) handleSetDataBreakpoints(req *Request) {
+	var args SetDataBreakpointsArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	// 无效的访问类型由 SetDataBreakpoints 报告
+	specs := make([]debug.DataBreakpointSpec, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		access, _ := debug.ParseDataAccess(bp.AccessType)
+		specs[i] = debug.DataBreakpointSpec{
+			DataID:       bp.DataId,
+			Access:       access,
+			Condition:    bp.Condition,
+			HitCondition: bp.HitCondition,
+		}
+	}
+	bps, errs := s.debugger.SetDataBreakpoints(specs)
+	
+	result := make([]Breakpoint, len(bps))
+	for i, bp := range bps {
+		if errs[i] != nil {
+			result[i] = Breakpoint{Verified: false, Message: errs[i].Error()}
+			continue
+		}
+		result[i] = Breakpoint{Id: bp.ID, Verified: true}
+	}
+	s.sendResponse(req, true, "", SetBreakpointsResponseBody{Breakpoints: result})
+}
+
 func (s *Server) handleContinue(req *Request) {
+	s.clearVariableRefs()
 	s.debugger.Continue()
 	s.sendResponse(req, true, "", ContinueResponseBody{
 		AllThreadsContinued: true,
 	})
 }
 
+// 单步只针对请求的线程，其他线程 (协程) 照常执行
+
 func (s *Server) handleNext(req *Request) {
-	s.debugger.StepOver()
+	var args NextArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	s.clearVariableRefs()
+	s.debugger.StepOver(args.ThreadId)
 	s.sendResponse(req, true, "", nil)
 }
 
 func (s *Server) handleStepIn(req *Request) {
-	s.debugger.StepIn()
+	var args StepInArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	s.clearVariableRefs()
+	s.debugger.StepIn(args.ThreadId)
 	s.sendResponse(req, true, "", nil)
 }
 
 func (s *Server) handleStepOut(req *Request) {
-	s.debugger.StepOut()
+	var args StepOutArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	s.clearVariableRefs()
+	s.debugger.StepOut(args.ThreadId)
 	s.sendResponse(req, true, "", nil)
 }
 
 func (s *Server) handlePause(req *Request) {
-	s.debugger.Pause()
+	var args PauseArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	s.debugger.Pause(args.ThreadId)
 	s.sendResponse(req, true, "", nil)
 }
 
@@ -369,25 +639,33 @@ func (s *Server) handleStackTrace(req *Request) {
 		return
 	}
 	
-	stack := s.debugger.GetCallStack()
+	stack := s.debugger.GetCallStack(args.ThreadId)
+	total := len(stack)
 	
-	frames := make([]StackFrame, len(stack))
-	for i, f := range stack {
-		frames[i] = StackFrame{
+	// 分页
+	start := min(args.StartFrame, total)
+	end := total
+	if args.Levels > 0 {
+		end = min(start+args.Levels, total)
+	}
+	
+	frames := make([]StackFrame, 0, end-start)
+	for _, f := range stack[start:end] {
+		frames = append(frames, StackFrame{
 			Id:     f.ID,
 			Name:   f.Name,
 			Line:   f.Line,
 			Column: f.Column,
 			Source: &Source{
 				Path: f.File,
-				Name: f.File,
+				Name: filepath.Base(f.File),
 			},
-		}
+		})
 	}
 	
 	s.sendResponse(req, true, "", StackTraceResponseBody{
 		StackFrames: frames,
-		TotalFrames: len(frames),
+		TotalFrames: total,
 	})
 }
 
@@ -399,17 +677,20 @@ func (s *Server) handleScopes(req *Request) {
 	}
 	
 	// 创建变量引用
-	s.mu.Lock()
-	s.nextVariableRef++
-	localsRef := s.nextVariableRef
-	s.variableRefs[localsRef] = variableRef{frameID: args.FrameId, scope: "locals"}
+	localsRef := s.newVariableRef(variableRef{frameID: args.FrameId, scope: "locals"})
+	globalsRef := s.newVariableRef(variableRef{frameID: args.FrameId, scope: "globals"})
 	
-	s.nextVariableRef++
-	globalsRef := s.nextVariableRef
-	s.variableRefs[globalsRef] = variableRef{frameID: args.FrameId, scope: "globals"}
-	s.mu.Unlock()
+	var scopes []Scope
+	
+	// 在异常处暂停时，抛出处的帧多一个异常作用域
+	if ex := s.debugger.GetException(); ex != nil && args.FrameId == 0 {
+		scopes = append(scopes, Scope{
+			Name:               "Exception",
+			VariablesReference: s.newVariableRef(variableRef{frameID: args.FrameId, scope: "exception"}),
+		})
+	}
 	
-	scopes := []Scope{
+	scopes = append(scopes, []Scope{
 		{
 			Name:               "Locals",
 			PresentationHint:   "locals",
@@ -420,7 +701,7 @@ func (s *Server) handleScopes(req *Request) {
 			PresentationHint:   "globals",
 			VariablesReference: globalsRef,
 		},
-	}
+	}...)
 	
 	s.sendResponse(req, true, "", ScopesResponseBody{Scopes: scopes})
 }
@@ -441,34 +722,122 @@ func (s *Server) handleVariables(req *Request) {
 		return
 	}
 	
-	var vars map[string]interface{}
-	if ref.scope == "locals" {
-		localVars := s.debugger.GetLocals(ref.frameID)
-		vars = make(map[string]interface{})
-		for k, v := range localVars {
-			vars[k] = v
+	var variables []Variable
+	switch ref.scope {
+	case "locals":
+		for _, v := range s.debugger.GetLocals(ref.frameID) {
+			variables = append(variables, s.variable(ref.frameID, v.Name, v.Type, v.Value))
+		}
+	case "globals":
+		for _, v := range s.debugger.GetGlobals() {
+			variables = append(variables, s.variable(ref.frameID, v.Name, v.Type, v.Value))
 		}
-	} else {
-		globalVars := s.debugger.GetGlobals()
-		vars = make(map[string]interface{})
-		for k, v := range globalVars {
-			vars[k] = v
+	case "exception":
+		if ex := s.debugger.GetException(); ex != nil {
+			variables = append(variables, s.variable(ref.frameID, "exception", ex.Type, ex.Value))
 		}
+	default:
+		variables = s.children(ref.frameID, ref.value)
 	}
-	
-	variables := make([]Variable, 0, len(vars))
-	for name, value := range vars {
-		variables = append(variables, Variable{
-			Name:               name,
-			Value:              fmt.Sprintf("%v", value),
-			Type:               fmt.Sprintf("%T", value),
-			VariablesReference: 0,
-		})
+	if variables == nil {
+		variables = []Variable{}
 	}
 	
 	s.sendResponse(req, true, "", VariablesResponseBody{Variables: variables})
 }
 
+// variable 返回变量的协议表示，对象、数组和 Map 可以展开
+// frameID 为变量所在的帧，展开和修改子变量时在该帧中求值
+func (s *Server) variable(frameID int, name, typeName string, value bytecode.Value) Variable {
+	if typeName == "" {
+		typeName = valueTypeName(value)
+	}
+	v := Variable{
+		Name:  name,
+		Value: value.String(),
+		Type:  typeName,
+	}
+	switch value.Type() {
+	case bytecode.ValObject, bytecode.ValArray, bytecode.ValMap, bytecode.ValException:
+		v.VariablesReference = s.newVariableRef(variableRef{frameID: frameID, scope: "value", value: value})
+	}
+	switch {
+	case value.IsObject():
+		v.Value = valueTypeName(value)
+	case value.IsException():
+		ex := value.AsException()
+		v.Value = ex.Type + ": " + ex.Message
+	}
+	return v
+}
+
+// children 返回对象的属性、数组的元素、Map 的键值或异常的消息和原因
+func (s *Server) children(frameID int, value bytecode.Value) []Variable {
+	var variables []Variable
+	switch value.Type() {
+	case bytecode.ValObject:
+		if obj := value.AsObject(); obj != nil {
+			obj.RangeFields(func(name string, field bytecode.Value) bool {
+				variables = append(variables, s.variable(frameID, name, "", field))
+				return true
+			})
+		}
+	case bytecode.ValArray:
+		for i, elem := range value.AsArray() {
+			variables = append(variables, s.variable(frameID, fmt.Sprintf("[%d]", i), "", elem))
+		}
+	case bytecode.ValMap:
+		m := value.AsMap()
+		keys := make([]bytecode.Key, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			variables = append(variables, s.variable(frameID, k.String(), "", m[k]))
+		}
+	case bytecode.ValException:
+		ex := value.AsException()
+		variables = append(variables,
+			s.variable(frameID, "message", "", bytecode.NewString(ex.Message)),
+			s.variable(frameID, "code", "", bytecode.NewInt(ex.Code)))
+		if ex.Cause != nil {
+			variables = append(variables, s.variable(frameID, "cause", "", bytecode.NewExceptionValue(ex.Cause)))
+		}
+	}
+	return variables
+}
+
+// valueTypeName 返回值在变量视图中显示的类型
+func valueTypeName(v bytecode.Value) string {
+	switch v.Type() {
+	case bytecode.ValNull:
+		return "null"
+	case bytecode.ValBool:
+		return "bool"
+	case bytecode.ValInt:
+		return "int"
+	case bytecode.ValFloat:
+		return "float"
+	case bytecode.ValString:
+		return "string"
+	case bytecode.ValArray:
+		return "array"
+	case bytecode.ValMap:
+		return "map"
+	case bytecode.ValObject:
+		if obj := v.AsObject(); obj != nil && obj.Class != nil {
+			return obj.Class.FullName()
+		}
+		return "object"
+	case bytecode.ValFunc, bytecode.ValClosure:
+		return "func"
+	case bytecode.ValException:
+		return v.AsException().Type
+	}
+	return ""
+}
+
 func (s *Server) handleEvaluate(req *Request) {
 	var args EvaluateArguments
 	if err := s.unmarshalArguments(req, &args); err != nil {
@@ -476,35 +845,248 @@ func (s *Server) handleEvaluate(req *Request) {
 		return
 	}
 	
-	result, err := s.debugger.EvaluateExpression(args.Expression, args.FrameId)
+	// 监视、悬停和 REPL 中的表达式只有在启用副作用时才能调用方法和赋值
+	result, err := s.debugger.Evaluate(args.Expression, args.FrameId, s.allowSideEffects)
 	if err != nil {
 		s.sendErrorResponse(req, err.Error())
 		return
 	}
 	
+	v := s.variable(args.FrameId, args.Expression, "", result)
 	s.sendResponse(req, true, "", EvaluateResponseBody{
-		Result:             fmt.Sprintf("%v", result),
-		Type:               fmt.Sprintf("%T", result),
-		VariablesReference: 0,
+		Result:             v.Value,
+		Type:               v.Type,
+		VariablesReference: v.VariablesReference,
+	})
+}
+
+func (s *Server) handleSetVariable(req *Request) {
+	var args SetVariableArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	s.mu.RLock()
+	ref, ok := s.variableRefs[args.VariablesReference]
+	s.mu.RUnlock()
+	if !ok {
+		s.sendErrorResponse(req, "invalid variablesReference")
+		return
+	}
+	
+	var result bytecode.Value
+	var err error
+	switch ref.scope {
+	case "locals", "globals":
+		result, err = s.debugger.SetVariable(args.Name, args.Value, ref.frameID)
+	case "value":
+		result, err = s.debugger.SetMember(ref.value, args.Name, args.Value, ref.frameID)
+	default:
+		err = fmt.Errorf("cannot modify %s", args.Name)
+	}
+	if err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	v := s.variable(ref.frameID, args.Name, "", result)
+	s.sendResponse(req, true, "", SetVariableResponseBody{
+		Value:              v.Value,
+		Type:               v.Type,
+		VariablesReference: v.VariablesReference,
+	})
+}
+
+func (s *Server) handleSetExpression(req *Request) {
+	var args SetExpressionArguments
+	if err := s.unmarshalArguments(req, &args); err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	result, err := s.debugger.SetExpression(args.Expression, args.Value, args.FrameId)
+	if err != nil {
+		s.sendErrorResponse(req, err.Error())
+		return
+	}
+	
+	v := s.variable(args.FrameId, args.Expression, "", result)
+	s.sendResponse(req, true, "", SetExpressionResponseBody{
+		Value:              v.Value,
+		Type:               v.Type,
+		VariablesReference: v.VariablesReference,
 	})
 }
 
 func (s *Server) handleThreads(req *Request) {
-	// 单线程实现
-	threads := []Thread{
-		{Id: 1, Name: "main"},
+	// 每个协程一个线程
+	var threads []Thread
+	for _, t := range s.debugger.GetThreads() {
+		threads = append(threads, Thread{Id: t.ID, Name: t.Name})
 	}
 	s.sendResponse(req, true, "", ThreadsResponseBody{Threads: threads})
 }
 
+// handleCoroutines 列出协程和它们阻塞的通道操作，用于查看死锁
+func (s *Server) handleCoroutines(req *Request) {
+	if s.debugger.GetState() != debug.StatePaused {
+		s.sendErrorResponse(req, "program is not paused")
+		return
+	}
+	
+	channels := make(map[*vm.Channel]int)
+	var coroutines []Coroutine
+	for _, t := range s.debugger.GetThreads() {
+		c := Coroutine{
+			Id:         t.Coroutine.ID,
+			ThreadId:   t.ID,
+			Name:       t.Name,
+			State:      t.Coroutine.State,
+			WaitReason: t.Coroutine.Wait,
+		}
+		if stack := s.debugger.GetCallStack(t.ID); len(stack) > 0 {
+			c.Source = &Source{Path: stack[0].File, Name: filepath.Base(stack[0].File)}
+			c.Line = stack[0].Line
+		}
+		for _, w := range t.Coroutine.Channels {
+			id, ok := channels[w.Channel]
+			if !ok {
+				id = len(channels) + 1
+				channels[w.Channel] = id
+			}
+			op := ChannelOperation{
+				Operation: "receive",
+				Channel:   id,
+				Capacity:  w.Channel.Cap(),
+				Length:    w.Channel.Len(),
+				Closed:    w.Channel.IsClosed(),
+			}
+			if w.Send {
+				op.Operation = "send"
+				op.Value = w.Value.String()
+			}
+			c.Operations = append(c.Operations, op)
+		}
+		coroutines = append(coroutines, c)
+	}
+	s.sendResponse(req, true, "", CoroutinesResponseBody{Coroutines: coroutines})
+}
+
 func (s *Server) handleDisconnect(req *Request) {
+	s.stopProgram()
 	s.running = false
 	s.sendResponse(req, true, "", nil)
 }
 
 func (s *Server) handleTerminate(req *Request) {
-	s.debugger.Terminate()
 	s.sendResponse(req, true, "", nil)
+	if !s.stopProgram() {
+		s.sendEvent("terminated", TerminatedEventBody{})
+	}
+}
+
+// ============================================================================
+// 程序执行
+// ============================================================================
+
+// startProgram launch 和 configurationDone 都收到后开始执行程序
+func (s *Server) startProgram() {
+	if !s.launched || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	
+	program := s.config.Program
+	if s.config.WorkDir != "" && !filepath.IsAbs(program) {
+		program = filepath.Join(s.config.WorkDir, program)
+	}
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	
+	r := runtime.NewWithOptions(runtime.Options{
+		LibDir: s.config.LibDir,
+		Limits: vm.Limits{Context: ctx},
+	})
+	s.debugger.Attach(r.VM())
+	s.debugger.SetSymbolTable(r.SymbolTable())
+	
+	go func() {
+		defer close(s.done)
+		
+		err := s.runProgram(r, program)
+		exitCode := 0
+		if err != nil {
+			exitCode = 1
+			if msg := err.Error(); msg != "" && !errors.Is(err, context.Canceled) {
+				s.sendEvent("output", OutputEventBody{Category: "stderr", Output: msg + "\n"})
+			}
+		}
+		s.sendEvent("exited", ExitedEventBody{ExitCode: exitCode})
+		s.sendEvent("terminated", TerminatedEventBody{})
+	}()
+}
+
+// runProgram 执行程序，执行期间标准输出转为 output 事件
+func (s *Server) runProgram(r *runtime.Runtime, program string) error {
+	source, err := os.ReadFile(program)
+	if err != nil {
+		return err
+	}
+	
+	restore, err := s.captureStdout()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	
+	return r.Run(string(source), program)
+}
+
+// captureStdout 把 os.Stdout 换成管道，读到的内容作为 output 事件发送
+// 返回的函数恢复 os.Stdout，并在管道中的内容都发送后返回
+func (s *Server) captureStdout() (func(), error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = pw
+	
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		buf := make([]byte, 4096)
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				s.sendEvent("output", OutputEventBody{Category: "stdout", Output: string(buf[:n])})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	
+	return func() {
+		os.Stdout = stdout
+		pw.Close()
+		<-drained
+		pr.Close()
+	}, nil
+}
+
+// stopProgram 结束正在执行的程序并等待它退出，没有程序在执行时返回 false
+func (s *Server) stopProgram() bool {
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	s.debugger.Terminate()
+	<-s.done
+	return true
 }
 
 // ============================================================================
@@ -517,7 +1099,7 @@ func (s *Server) handleDebugEvents() {
 		case debug.EventStopped:
 			s.sendEvent("stopped", StoppedEventBody{
 				Reason:            event.Reason,
-				ThreadId:          1,
+				ThreadId:          eventThread(event),
 				AllThreadsStopped: true,
 			})
 		case debug.EventContinued:
@@ -528,25 +1110,90 @@ func (s *Server) handleDebugEvents() {
 		case debug.EventBreakpoint:
 			s.sendEvent("stopped", StoppedEventBody{
 				Reason:            "breakpoint",
-				ThreadId:          1,
+				ThreadId:          eventThread(event),
 				AllThreadsStopped: true,
 			})
 		case debug.EventStep:
 			s.sendEvent("stopped", StoppedEventBody{
 				Reason:            "step",
-				ThreadId:          1,
+				ThreadId:          eventThread(event),
 				AllThreadsStopped: true,
 			})
 		case debug.EventException:
-			s.sendEvent("stopped", StoppedEventBody{
+			body := StoppedEventBody{
 				Reason:            "exception",
-				ThreadId:          1,
+				ThreadId:          eventThread(event),
+				AllThreadsStopped: true,
+			}
+			if ex := s.debugger.GetException(); ex != nil {
+				body.Description = "Paused on exception"
+				body.Text = ex.Type + ": " + ex.Message
+			}
+			s.sendEvent("stopped", body)
+		case debug.EventDataBreakpoint:
+			body := StoppedEventBody{
+				Reason:            "data breakpoint",
+				Description:       "Paused on data breakpoint",
+				ThreadId:          eventThread(event),
+				AllThreadsStopped: true,
+			}
+			if hit := s.debugger.GetDataBreakpointHit(); hit != nil {
+				body.HitBreakpointIds = []int{hit.Breakpoint.ID}
+				if hit.Write {
+					body.Text = hit.Breakpoint.Data.String() + " = " + hit.Value.String()
+				} else {
+					body.Text = "read " + hit.Breakpoint.Data.String()
+				}
+			}
+			s.sendEvent("stopped", body)
+		case debug.EventDeadlock:
+			s.sendEvent("stopped", StoppedEventBody{
+				Reason:            "deadlock",
+				Description:       "Paused on deadlock",
+				Text:              "all coroutines are asleep - deadlock!",
+				ThreadId:          eventThread(event),
 				AllThreadsStopped: true,
 			})
-		case debug.EventTerminated:
-			s.sendEvent("terminated", TerminatedEventBody{})
+		case debug.EventBreakpointChanged:
+			if bp, ok := event.Data.(*debug.Breakpoint); ok {
+				s.sendEvent("breakpoint", BreakpointEventBody{
+					Reason:     "changed",
+					Breakpoint: breakpointInfo(bp, true),
+				})
+			}
+		}
+		// 程序结束时由执行程序的 goroutine 发送 terminated 事件
+	}
+}
+
+// eventThread 返回暂停事件中暂停的线程
+func eventThread(event debug.DebugEvent) int {
+	if data, ok := event.Data.(map[string]interface{}); ok {
+		if thread, ok := data["thread"].(int); ok {
+			return thread
 		}
 	}
+	return 1
+}
+
+// ============================================================================
+// 变量引用
+// ============================================================================
+
+// newVariableRef 分配变量引用
+func (s *Server) newVariableRef(ref variableRef) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextVariableRef++
+	s.variableRefs[s.nextVariableRef] = ref
+	return s.nextVariableRef
+}
+
+// clearVariableRefs 程序继续执行，之前的变量引用失效
+func (s *Server) clearVariableRefs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.variableRefs)
 }
 
 // ============================================================================
@@ -584,12 +1231,16 @@ func (s *Server) sendEvent(event string, body interface{}) {
 	s.send(evt)
 }
 
+// send 写出消息，请求处理、调试事件和程序输出在不同的 goroutine 上发送
 func (s *Server) send(msg interface{}) {
 	body, err := json.Marshal(msg)
 	if err != nil {
 		return
 	}
 	
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	
 	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(body))
 	s.writer.Write([]byte(header))
 	s.writer.Write(body)
@@ -0,0 +1,707 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/debug"
+)
+
+const testProgram = `class main {
+    public static function add(int $a, int $b): int {
+        $sum := $a + $b;
+        return $sum;
+    }
+
+    public static function main(): void {
+        $x := 40;
+        $y := main::add($x, 2);
+        print($y);
+    }
+}
+`
+
+const exceptionProgram = `class AppException {
+    public string $message = "";
+    public dynamic $previous = null;
+
+    public function __construct(string $message, dynamic $previous = null) {
+        $this->message = $message;
+        $this->previous = $previous;
+    }
+}
+
+class ConfigException extends AppException {
+}
+
+class main {
+    public static function load(string $name): int {
+        if ($name == "") {
+            throw new ConfigException("empty name");
+        }
+        return len($name);
+    }
+
+    public static function main(): void {
+        try {
+            main::load("");
+        } catch (ConfigException $e) {
+            print("caught");
+        }
+        try {
+            main::load("x");
+        } catch (AppException $e) {
+            print("unreachable");
+        }
+        throw new AppException("load failed", new ConfigException("missing file"));
+    }
+}
+`
+
+const evalProgram = `class Point {
+    public int $x = 0;
+
+    public function __construct(int $x) {
+        $this->x = $x;
+    }
+
+    public function double(): int {
+        return $this->x * 2;
+    }
+}
+
+class main {
+    public static function main(): void {
+        $p := new Point(5);
+        $y := $p->x + 2;
+        print($y);
+        print($p->x);
+    }
+}
+`
+
+const coroutineProgram = `class W {
+    public static function work(Channel<int> $ch, int $n): void {
+        int $v = $n * 2;
+        $ch->send($v);
+    }
+}
+
+class main {
+    public static function main(): void {
+        Channel<int> $ch = new Channel<int>();
+        go W::work($ch, 21);
+        int $got = $ch->receive();
+        print($got);
+        $ch->receive();
+    }
+}
+`
+
+const dataProgram = `class Counter {
+    public int $n = 0;
+
+    public function add(): void {
+        $this->n = $this->n + 1;
+    }
+}
+
+class main {
+    public static function main(): void {
+        $c := new Counter();
+        for ($i := 0; $i < 5; $i++) {
+            $c->add();
+        }
+        print($c->n);
+    }
+}
+`
+
+// testClient 通过管道与服务器交换 DAP 消息的客户端
+type testClient struct {
+	t   *testing.T
+	w   io.Writer
+	seq int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	messages []map[string]interface{}
+	consumed []bool
+	closed   bool
+}
+
+func newTestClient(t *testing.T, program string) *testClient {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	server := NewServerWithConfig(debug.NewDebugger(), ServerConfig{Program: program})
+	serveDone := make(chan struct{})
+	go func() {
+		server.Serve(serverR, serverW)
+		serverW.Close()
+		close(serveDone)
+	}()
+
+	c := &testClient{t: t, w: clientW}
+	c.cond = sync.NewCond(&c.mu)
+	go c.readLoop(bufio.NewReader(clientR))
+
+	t.Cleanup(func() {
+		clientW.Close()
+		select {
+		case <-serveDone:
+		case <-time.After(5 * time.Second):
+			t.Error("server did not stop")
+		}
+	})
+	return c
+}
+
+func (c *testClient) readLoop(r *bufio.Reader) {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}()
+	for {
+		length := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if v, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+				length, _ = strconv.Atoi(strings.TrimSpace(v))
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.messages = append(c.messages, msg)
+		c.consumed = append(c.consumed, false)
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}
+}
+
+// request 发送请求并等待成功的响应，返回响应体
+func (c *testClient) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	resp := c.send(command, args)
+	if resp["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, resp["message"])
+	}
+	b, _ := resp["body"].(map[string]interface{})
+	return b
+}
+
+// requestError 发送请求并等待失败的响应，返回错误信息
+func (c *testClient) requestError(command string, args interface{}) string {
+	c.t.Helper()
+	resp := c.send(command, args)
+	if resp["success"] == true {
+		c.t.Fatalf("%s succeeded: %v", command, resp["body"])
+	}
+	message, _ := resp["message"].(string)
+	return message
+}
+
+// send 发送请求并等待响应
+func (c *testClient) send(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	body, _ := json.Marshal(map[string]interface{}{
+		"seq": seq, "type": "request", "command": command, "arguments": args,
+	})
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatalf("send %s: %v", command, err)
+	}
+	return c.wait("response "+command, func(msg map[string]interface{}) bool {
+		return msg["type"] == "response" && int(msg["request_seq"].(float64)) == seq
+	})
+}
+
+// event 等待指定的事件，返回事件体
+func (c *testClient) event(name string) map[string]interface{} {
+	c.t.Helper()
+	msg := c.wait("event "+name, func(msg map[string]interface{}) bool {
+		return msg["type"] == "event" && msg["event"] == name
+	})
+	b, _ := msg["body"].(map[string]interface{})
+	return b
+}
+
+// wait 返回第一条尚未取走且满足条件的消息
+func (c *testClient) wait(what string, match func(map[string]interface{}) bool) map[string]interface{} {
+	c.t.Helper()
+	timeout := time.AfterFunc(10*time.Second, func() {
+		c.mu.Lock()
+		c.closed = true
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer timeout.Stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		for i, msg := range c.messages {
+			if !c.consumed[i] && match(msg) {
+				c.consumed[i] = true
+				return msg
+			}
+		}
+		if c.closed {
+			c.t.Fatalf("timed out waiting for %s", what)
+		}
+		c.cond.Wait()
+	}
+}
+
+func TestDebugSession(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "main.sola")
+	if err := os.WriteFile(program, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, program)
+	c.request("initialize", map[string]interface{}{"adapterID": "sola"})
+	c.event("initialized")
+
+	// 程序加载前断点未验证
+	body := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []map[string]interface{}{{"line": 3}},
+	})
+	bps := body["breakpoints"].([]interface{})
+	if len(bps) != 1 || bps[0].(map[string]interface{})["verified"] != false {
+		t.Fatalf("breakpoints before launch = %v", bps)
+	}
+
+	c.request("launch", map[string]interface{}{"program": program})
+	c.request("configurationDone", nil)
+
+	changed := c.event("breakpoint")
+	if bp := changed["breakpoint"].(map[string]interface{}); bp["verified"] != true || bp["line"] != float64(3) {
+		t.Fatalf("changed breakpoint = %v", bp)
+	}
+
+	stopped := c.event("stopped")
+	if stopped["reason"] != "breakpoint" {
+		t.Fatalf("stopped reason = %v", stopped["reason"])
+	}
+
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	if len(frames) < 2 {
+		t.Fatalf("stack frames = %v", frames)
+	}
+	top := frames[0].(map[string]interface{})
+	if top["name"] != "main.add" || top["line"] != float64(3) {
+		t.Fatalf("top frame = %v", top)
+	}
+	if caller := frames[1].(map[string]interface{}); caller["name"] != "main.main" || caller["line"] != float64(9) {
+		t.Fatalf("caller frame = %v", caller)
+	}
+
+	locals := c.variables(top["id"], "Locals")
+	if locals["a"] != "40" || locals["b"] != "2" {
+		t.Fatalf("locals = %v", locals)
+	}
+	if _, ok := locals["sum"]; ok {
+		t.Fatalf("uninitialized local visible: %v", locals)
+	}
+
+	callerLocals := c.variables(frames[1].(map[string]interface{})["id"], "Locals")
+	if callerLocals["x"] != "40" {
+		t.Fatalf("caller locals = %v", callerLocals)
+	}
+
+	c.request("next", map[string]interface{}{"threadId": 1})
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Fatalf("stopped reason after next = %v", stopped["reason"])
+	}
+	frames = c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	top = frames[0].(map[string]interface{})
+	if top["line"] != float64(4) {
+		t.Fatalf("line after next = %v", top["line"])
+	}
+	if locals := c.variables(top["id"], "Locals"); locals["sum"] != "42" {
+		t.Fatalf("locals after next = %v", locals)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if output := c.event("output"); output["category"] != "stdout" || !strings.Contains(output["output"].(string), "42") {
+		t.Fatalf("output = %v", output)
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Fatalf("exit code = %v", exited["exitCode"])
+	}
+	c.event("terminated")
+	c.request("disconnect", nil)
+}
+
+// variables 返回帧中指定作用域的变量 (名称到值)
+func (c *testClient) variables(frameID interface{}, scope string) map[string]string {
+	c.t.Helper()
+	scopes := c.request("scopes", map[string]interface{}{"frameId": frameID})["scopes"].([]interface{})
+	for _, s := range scopes {
+		s := s.(map[string]interface{})
+		if s["name"] != scope {
+			continue
+		}
+		vars := c.request("variables", map[string]interface{}{"variablesReference": s["variablesReference"]})["variables"].([]interface{})
+		result := make(map[string]string)
+		for _, v := range vars {
+			v := v.(map[string]interface{})
+			result[v["name"].(string)] = v["value"].(string)
+		}
+		return result
+	}
+	c.t.Fatalf("scope %s not found in %v", scope, scopes)
+	return nil
+}
+
+func TestExceptionBreakpoints(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "main.sola")
+	if err := os.WriteFile(program, []byte(exceptionProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, program)
+	c.request("initialize", map[string]interface{}{"adapterID": "sola"})
+	c.request("setExceptionBreakpoints", map[string]interface{}{
+		"filters":       []string{"uncaught"},
+		"filterOptions": []map[string]interface{}{{"filterId": "type", "condition": "AppException"}},
+	})
+	c.request("launch", map[string]interface{}{"program": program})
+	c.request("configurationDone", nil)
+
+	// 子类的异常在抛出处暂停，即使之后被捕获
+	stopped := c.event("stopped")
+	if stopped["reason"] != "exception" || stopped["text"] != "ConfigException: empty name" {
+		t.Fatalf("stopped = %v", stopped)
+	}
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	top := frames[0].(map[string]interface{})
+	if top["name"] != "main.load" || top["line"] != float64(17) {
+		t.Fatalf("top frame = %v", top)
+	}
+	info := c.request("exceptionInfo", map[string]interface{}{"threadId": 1})
+	if info["exceptionId"] != "ConfigException" || info["breakMode"] != "always" || info["description"] != "empty name" {
+		t.Fatalf("exceptionInfo = %v", info)
+	}
+
+	scopes := c.request("scopes", map[string]interface{}{"frameId": top["id"]})["scopes"].([]interface{})
+	scope := scopes[0].(map[string]interface{})
+	if scope["name"] != "Exception" {
+		t.Fatalf("scopes = %v", scopes)
+	}
+	vars := c.request("variables", map[string]interface{}{"variablesReference": scope["variablesReference"]})["variables"].([]interface{})
+	exVar := vars[0].(map[string]interface{})
+	if exVar["name"] != "exception" || exVar["type"] != "ConfigException" {
+		t.Fatalf("exception variable = %v", exVar)
+	}
+	fields := c.request("variables", map[string]interface{}{"variablesReference": exVar["variablesReference"]})["variables"].([]interface{})
+	found := false
+	for _, f := range fields {
+		f := f.(map[string]interface{})
+		found = found || (f["name"] == "message" && f["value"] == "empty name")
+	}
+	if !found {
+		t.Fatalf("exception fields = %v", fields)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if output := c.event("output"); output["output"] != "caught\n" {
+		t.Fatalf("output = %v", output)
+	}
+
+	// 未捕获的异常：breakMode 为 unhandled，原因链放在 innerException
+	stopped = c.event("stopped")
+	if stopped["reason"] != "exception" {
+		t.Fatalf("stopped = %v", stopped)
+	}
+	info = c.request("exceptionInfo", map[string]interface{}{"threadId": 1})
+	details := info["details"].(map[string]interface{})
+	if info["exceptionId"] != "AppException" || info["breakMode"] != "unhandled" || !strings.Contains(details["stackTrace"].(string), "main.main") {
+		t.Fatalf("exceptionInfo = %v", info)
+	}
+	inner := details["innerException"].([]interface{})
+	if cause := inner[0].(map[string]interface{}); cause["typeName"] != "ConfigException" || cause["message"] != "missing file" {
+		t.Fatalf("cause = %v", cause)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if exited := c.event("exited"); exited["exitCode"] != float64(1) {
+		t.Fatalf("exit code = %v", exited["exitCode"])
+	}
+	c.event("terminated")
+}
+
+func TestEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "main.sola")
+	if err := os.WriteFile(program, []byte(evalProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, program)
+	c.request("initialize", map[string]interface{}{"adapterID": "sola"})
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []map[string]interface{}{{"line": 17}},
+	})
+	c.request("launch", map[string]interface{}{"program": program, "allowSideEffects": true})
+	c.request("configurationDone", nil)
+	c.event("stopped")
+
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	frameID := frames[0].(map[string]interface{})["id"]
+	evaluate := func(expr string) string {
+		t.Helper()
+		return c.request("evaluate", map[string]interface{}{
+			"expression": expr, "frameId": frameID, "context": "watch",
+		})["result"].(string)
+	}
+
+	if got := evaluate("$y * 2 + $p->x"); got != "19" {
+		t.Fatalf("$y * 2 + $p->x = %s", got)
+	}
+	if got := evaluate("$p->double()"); got != "10" {
+		t.Fatalf("$p->double() = %s", got)
+	}
+
+	// 修改的值只能由没有副作用的表达式给出
+	msg := c.requestError("setExpression", map[string]interface{}{
+		"expression": "$p->x", "value": "$p->double()", "frameId": frameID,
+	})
+	if !strings.Contains(msg, "side effects") {
+		t.Fatalf("setExpression error = %q", msg)
+	}
+
+	scopes := c.request("scopes", map[string]interface{}{"frameId": frameID})["scopes"].([]interface{})
+	locals := scopes[0].(map[string]interface{})["variablesReference"]
+	set := c.request("setVariable", map[string]interface{}{
+		"variablesReference": locals, "name": "y", "value": "$p->x * 10",
+	})
+	if set["value"] != "50" {
+		t.Fatalf("setVariable = %v", set)
+	}
+	set = c.request("setExpression", map[string]interface{}{
+		"expression": "$p->x", "value": "7", "frameId": frameID,
+	})
+	if set["value"] != "7" {
+		t.Fatalf("setExpression = %v", set)
+	}
+	if vars := c.variables(frameID, "Locals"); vars["y"] != "50" {
+		t.Fatalf("locals = %v", vars)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	output := ""
+	for len(output) < len("50\n7\n") {
+		output += c.event("output")["output"].(string)
+	}
+	if output != "50\n7\n" {
+		t.Fatalf("output = %q", output)
+	}
+	c.event("terminated")
+}
+
+func TestCoroutineThreads(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "main.sola")
+	if err := os.WriteFile(program, []byte(coroutineProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, program)
+	c.request("initialize", map[string]interface{}{"adapterID": "sola"})
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []map[string]interface{}{{"line": 3}},
+	})
+	c.request("launch", map[string]interface{}{"program": program})
+	c.request("configurationDone", nil)
+
+	// 断点在协程中命中，主协程阻塞在接收上
+	stopped := c.event("stopped")
+	if stopped["reason"] != "breakpoint" || stopped["threadId"] != float64(2) || stopped["allThreadsStopped"] != true {
+		t.Fatalf("stopped = %v", stopped)
+	}
+	threads := c.request("threads", nil)["threads"].([]interface{})
+	names := make(map[float64]string)
+	for _, th := range threads {
+		th := th.(map[string]interface{})
+		names[th["id"].(float64)] = th["name"].(string)
+	}
+	if len(names) != 2 || names[1] != "main" || names[2] != "coroutine 1 @ main.sola:11" {
+		t.Fatalf("threads = %v", threads)
+	}
+
+	top := func(thread int) map[string]interface{} {
+		t.Helper()
+		frames := c.request("stackTrace", map[string]interface{}{"threadId": thread})["stackFrames"].([]interface{})
+		return frames[0].(map[string]interface{})
+	}
+	worker, main := top(2), top(1)
+	if worker["name"] != "W.work" || worker["line"] != float64(3) {
+		t.Fatalf("coroutine frame = %v", worker)
+	}
+	if main["name"] != "main.main" || main["line"] != float64(12) {
+		t.Fatalf("main frame = %v", main)
+	}
+	if vars := c.variables(worker["id"], "Locals"); vars["n"] != "21" {
+		t.Fatalf("coroutine locals = %v", vars)
+	}
+	if _, ok := c.variables(main["id"], "Locals")["ch"]; !ok {
+		t.Fatal("main locals missing ch")
+	}
+
+	coroutines := c.request("coroutines", nil)["coroutines"].([]interface{})
+	blocked := coroutines[0].(map[string]interface{})
+	ops, _ := blocked["operations"].([]interface{})
+	if blocked["threadId"] != float64(1) || blocked["state"] != "waiting" || blocked["waitReason"] != "chan receive" || len(ops) != 1 {
+		t.Fatalf("main coroutine = %v", blocked)
+	}
+	if op := ops[0].(map[string]interface{}); op["operation"] != "receive" || op["channel"] != float64(1) {
+		t.Fatalf("operation = %v", op)
+	}
+	if running := coroutines[1].(map[string]interface{}); running["state"] != "running" || running["line"] != float64(3) {
+		t.Fatalf("worker coroutine = %v", running)
+	}
+
+	// 单步在协程中进行
+	c.request("next", map[string]interface{}{"threadId": 2})
+	stopped = c.event("stopped")
+	if stopped["reason"] != "step" || stopped["threadId"] != float64(2) {
+		t.Fatalf("stopped = %v", stopped)
+	}
+	if worker = top(2); worker["line"] != float64(4) {
+		t.Fatalf("coroutine frame after step = %v", worker)
+	}
+
+	// 协程结束后主协程再次接收，死锁时暂停
+	c.request("continue", map[string]interface{}{"threadId": 2})
+	if output := c.event("output"); output["output"] != "42\n" {
+		t.Fatalf("output = %v", output)
+	}
+	stopped = c.event("stopped")
+	if stopped["reason"] != "deadlock" || stopped["threadId"] != float64(1) {
+		t.Fatalf("stopped = %v", stopped)
+	}
+	coroutines = c.request("coroutines", nil)["coroutines"].([]interface{})
+	if len(coroutines) != 1 || coroutines[0].(map[string]interface{})["line"] != float64(14) {
+		t.Fatalf("coroutines = %v", coroutines)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if exited := c.event("exited"); exited["exitCode"] != float64(1) {
+		t.Fatalf("exit code = %v", exited["exitCode"])
+	}
+	c.event("terminated")
+}
+
+func TestDataBreakpoints(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "main.sola")
+	if err := os.WriteFile(program, []byte(dataProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, program)
+	c.request("initialize", map[string]interface{}{"adapterID": "sola"})
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []map[string]interface{}{{"line": 12}},
+	})
+	c.request("launch", map[string]interface{}{"program": program})
+	c.request("configurationDone", nil)
+	c.event("stopped")
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []map[string]interface{}{},
+	})
+
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	frameID := frames[0].(map[string]interface{})["id"]
+	scopes := c.request("scopes", map[string]interface{}{"frameId": frameID})["scopes"].([]interface{})
+	locals := scopes[0].(map[string]interface{})["variablesReference"]
+	var counter interface{}
+	for _, v := range c.request("variables", map[string]interface{}{"variablesReference": locals})["variables"].([]interface{}) {
+		if v := v.(map[string]interface{}); v["name"] == "c" {
+			counter = v["variablesReference"]
+		}
+	}
+
+	// 局部变量不能监视
+	info := c.request("dataBreakpointInfo", map[string]interface{}{"variablesReference": locals, "name": "c"})
+	if info["dataId"] != nil {
+		t.Fatalf("dataBreakpointInfo(c) = %v", info)
+	}
+	info = c.request("dataBreakpointInfo", map[string]interface{}{"variablesReference": counter, "name": "n"})
+	dataID, _ := info["dataId"].(string)
+	if dataID == "" || info["description"] != "Counter.n" || len(info["accessTypes"].([]interface{})) != 3 {
+		t.Fatalf("dataBreakpointInfo(n) = %v", info)
+	}
+
+	// 条件在写入处求值
+	set := c.request("setDataBreakpoints", map[string]interface{}{
+		"breakpoints": []map[string]interface{}{
+			{"dataId": dataID, "accessType": "write", "condition": "$this->n >= 3"},
+			{"dataId": dataID, "accessType": "execute"},
+		},
+	})["breakpoints"].([]interface{})
+	if set[0].(map[string]interface{})["verified"] != true || set[1].(map[string]interface{})["verified"] != false {
+		t.Fatalf("setDataBreakpoints = %v", set)
+	}
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	stopped := c.event("stopped")
+	if stopped["reason"] != "data breakpoint" || stopped["text"] != "Counter.n = 3" {
+		t.Fatalf("stopped = %v", stopped)
+	}
+	frames = c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	if top := frames[0].(map[string]interface{}); top["name"] != "Counter.add" || top["line"] != float64(5) {
+		t.Fatalf("frame = %v", top)
+	}
+
+	// 读取和写入都计入命中次数：读到 3、写入 4
+	c.request("setDataBreakpoints", map[string]interface{}{
+		"breakpoints": []map[string]interface{}{{"dataId": dataID, "accessType": "readWrite", "hitCondition": "==2"}},
+	})
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if stopped = c.event("stopped"); stopped["text"] != "Counter.n = 4" {
+		t.Fatalf("stopped = %v", stopped)
+	}
+
+	c.request("setDataBreakpoints", map[string]interface{}{"breakpoints": []map[string]interface{}{}})
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	if output := c.event("output"); output["output"] != "5\n" {
+		t.Fatalf("output = %v", output)
+	}
+	c.event("terminated")
+}
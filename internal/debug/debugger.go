@@ -6,15 +6,22 @@
 // 3. 变量查看
 // 4. 调用栈查看
 // 5. 表达式求值
+// 6. 异常断点（在抛出处暂停，查看异常对象和原因链）
+// 7. 协程（每个协程作为一个线程，有各自的调用栈和单步；死锁时暂停）
+// 8. 数据断点（读写对象的字段或全局变量之后暂停）
 
 package debug
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/compiler"
+	"github.com/tangzhangming/nova/internal/vm"
 )
 
 // DebugState 调试状态
@@ -46,36 +53,62 @@ const (
 )
 
 // Debugger 调试器
+//
+// 调试器通过执行钩子接入虚拟机 (Attach)。在断点、单步或暂停请求处，
+// 行号钩子阻塞执行脚本的 goroutine，直到 Continue、Step* 或 Terminate；
+// 暂停期间其他 goroutine 可以查看调用栈和变量，它们直接读取虚拟机的调用帧。
+//
+// 每个未结束的协程是一个线程 (ID 为协程 ID + 1，主协程为 1)。所有协程在同一个
+// goroutine 上执行，暂停时全部停下，继续时全部继续；单步和暂停请求针对指定的线程。
 type Debugger struct {
 	mu sync.RWMutex
 	
 	// 状态
-	state       DebugState
-	stepAction  StepAction
-	stepDepth   int
+	state          DebugState
+	stepAction     StepAction
+	stepDepth      int  // 开始单步时的调用深度
+	stepThread     int  // 单步的线程
+	pauseRequested bool // 下一行暂停
+	pauseThread    int  // 请求暂停的线程，0 表示任意线程
+	started        bool // 已执行第一行
 	
 	// 断点管理
 	breakpoints *BreakpointManager
 	
+	// 被调试的虚拟机
+	vm *vm.VM
+	
+	// 编译程序时的符号表，求值表达式时用于查找类的属性和方法
+	symbols *compiler.SymbolTable
+	
 	// 当前执行位置
 	currentFile  string
 	currentLine  int
 	currentFunc  string
-	callStack    []StackFrame
 	
-	// 局部变量
-	locals map[string]bytecode.Value
+	// 暂停时所有线程的调用栈 (运行时为 nil)，暂停的线程在前，帧 ID 为下标
+	frames []vm.Frame
+	
+	// 最近一次暂停时的线程
+	threads []Thread
+	thread  int // 暂停的线程
+	
+	// 暂停处抛出的异常 (在异常处暂停时有效)
+	exception *ExceptionInfo
 	
-	// 全局变量
-	globals map[string]bytecode.Value
+	// 命中的数据断点 (在数据断点处暂停时有效)
+	dataHit *DataBreakpointHit
 	
 	// 事件通道
 	eventChan chan DebugEvent
 	
-	// 暂停信号
-	pauseChan chan struct{}
+	// 继续执行信号 (暂停时发送一次)
 	resumeChan chan struct{}
 	
+	// 暂停时在执行脚本的 goroutine 上执行的命令 (表达式求值)
+	commands   chan func()
+	evaluating bool // 正在求值，忽略钩子
+	
 	// 序列号
 	sequenceID int64
 	
@@ -92,15 +125,37 @@ type DebugConfig struct {
 	MaxCallStackDepth int
 }
 
+// Thread 线程 (一个未结束的协程)
+type Thread struct {
+	ID        int    // 协程 ID + 1
+	Name      string // 主协程为 main，其他协程以创建处命名
+	Coroutine vm.CoroutineInfo
+}
+
 // StackFrame 栈帧
 type StackFrame struct {
-	ID       int
-	Name     string
-	File     string
-	Line     int
-	Column   int
-	Locals   map[string]bytecode.Value
-	ModuleID int
+	ID     int // 帧 ID，暂停的线程从最内层帧开始为 0、1、2...，其他线程的帧依次排在后面
+	Name   string
+	File   string
+	Line   int
+	Column int
+}
+
+// ExceptionInfo 异常信息
+type ExceptionInfo struct {
+	Type       string         // 异常类的完整名称
+	Message    string
+	StackTrace string         // 抛出时的 Sola 调用栈，每帧一行
+	Value      bytecode.Value // 异常对象，没有关联对象的异常为异常值
+	Uncaught   bool           // 调用栈上没有处理它的 catch
+	Cause      *ExceptionInfo // 导致此异常的异常
+}
+
+// DataBreakpointHit 命中的数据断点和触发它的访问
+type DataBreakpointHit struct {
+	Breakpoint *Breakpoint
+	Write      bool
+	Value      bytecode.Value // 读到或写入的值
 }
 
 // DebugEvent 调试事件
@@ -129,6 +184,12 @@ const (
 	EventOutput
 	// EventTerminated 终止
 	EventTerminated
+	// EventBreakpointChanged 断点映射到字节码后验证状态或行号变化 (Data 为 *Breakpoint)
+	EventBreakpointChanged
+	// EventDeadlock 所有协程都在等待
+	EventDeadlock
+	// EventDataBreakpoint 数据断点命中
+	EventDataBreakpoint
 )
 
 // DefaultDebugConfig 默认配置
@@ -149,11 +210,9 @@ func NewDebuggerWithConfig(config DebugConfig) *Debugger {
 	return &Debugger{
 		state:       StateRunning,
 		breakpoints: NewBreakpointManager(),
-		locals:      make(map[string]bytecode.Value),
-		globals:     make(map[string]bytecode.Value),
 		eventChan:   make(chan DebugEvent, 100),
-		pauseChan:   make(chan struct{}),
-		resumeChan:  make(chan struct{}),
+		resumeChan:  make(chan struct{}, 1),
+		commands:    make(chan func()),
 		config:      config,
 	}
 }
@@ -172,6 +231,13 @@ func (d *Debugger) SetState(state DebugState) {
 	d.state = state
 }
 
+// SetStopOnEntry 设置是否在执行第一行之前暂停
+func (d *Debugger) SetStopOnEntry(stop bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config.StopOnEntry = stop
+}
+
 // Events 获取事件通道
 func (d *Debugger) Events() <-chan DebugEvent {
 	return d.eventChan
@@ -216,300 +282,496 @@ func (d *Debugger) DisableBreakpoint(id int) error {
 	return d.breakpoints.Disable(id)
 }
 
+// Loaded 程序是否已加载，加载之后设置的断点立即映射到字节码
+func (d *Debugger) Loaded() bool {
+	return d.breakpoints.Loaded()
+}
+
+// SetExceptionBreakpoints 设置异常断点
+func (d *Debugger) SetExceptionBreakpoints(eb ExceptionBreakpoints) {
+	d.breakpoints.SetExceptionBreakpoints(eb)
+}
+
+// DataBreakpointID 返回监视位置的数据 ID，用于设置数据断点
+func (d *Debugger) DataBreakpointID(target DataTarget) string {
+	return d.breakpoints.DataID(target)
+}
+
+// SetDataBreakpoints 设置数据断点，取代之前的所有数据断点
+// 每个断点的错误对应地放在返回的 errs 中
+func (d *Debugger) SetDataBreakpoints(specs []DataBreakpointSpec) ([]*Breakpoint, []error) {
+	for _, bp := range d.breakpoints.GetData() {
+		d.breakpoints.Remove(bp.ID)
+	}
+	bps := make([]*Breakpoint, len(specs))
+	errs := make([]error, len(specs))
+	for i, spec := range specs {
+		bps[i], errs[i] = d.breakpoints.AddData(spec.DataID, spec.Access, spec.Condition, spec.HitCondition)
+	}
+	return bps, errs
+}
+
+// DataBreakpointSpec 数据断点的设置
+type DataBreakpointSpec struct {
+	DataID       string
+	Access       DataAccess
+	Condition    string // 在访问处求值的表达式，为真时才命中
+	HitCondition string
+}
+
 // ============================================================================
 // 执行控制
 // ============================================================================
 
-// Continue 继续执行
+// Continue 继续执行所有线程
 func (d *Debugger) Continue() {
-	d.mu.Lock()
-	d.state = StateRunning
-	d.stepAction = StepNone
-	d.mu.Unlock()
-	
-	select {
-	case d.resumeChan <- struct{}{}:
-	default:
-	}
-	
+	d.resume(StateRunning, StepNone, 0)
 	d.sendEvent(EventContinued, "continue", nil)
 }
 
-// Pause 暂停执行
-func (d *Debugger) Pause() {
+// Pause 请求暂停，线程执行到下一行时停下
+// threadID 为 0 或线程在等待时，任意线程执行到下一行时停下
+func (d *Debugger) Pause(threadID int) {
 	d.mu.Lock()
-	d.state = StatePaused
+	d.pauseRequested = true
+	d.pauseThread = threadID
 	d.mu.Unlock()
-	
-	d.sendEvent(EventStopped, "pause", nil)
 }
 
-// StepIn 步入
-func (d *Debugger) StepIn() {
-	d.mu.Lock()
-	d.state = StateStepping
-	d.stepAction = StepIn
-	d.stepDepth = len(d.callStack)
-	d.mu.Unlock()
-	
-	select {
-	case d.resumeChan <- struct{}{}:
-	default:
-	}
+// StepIn 线程步入，0 表示暂停的线程
+func (d *Debugger) StepIn(threadID int) {
+	d.resume(StateStepping, StepIn, threadID)
 }
 
-// StepOver 步过
-func (d *Debugger) StepOver() {
-	d.mu.Lock()
-	d.state = StateStepping
-	d.stepAction = StepOver
-	d.stepDepth = len(d.callStack)
-	d.mu.Unlock()
-	
-	select {
-	case d.resumeChan <- struct{}{}:
-	default:
-	}
+// StepOver 线程步过，0 表示暂停的线程
+func (d *Debugger) StepOver(threadID int) {
+	d.resume(StateStepping, StepOver, threadID)
 }
 
-// StepOut 步出
-func (d *Debugger) StepOut() {
+// StepOut 线程步出，0 表示暂停的线程
+func (d *Debugger) StepOut(threadID int) {
+	d.resume(StateStepping, StepOut, threadID)
+}
+
+// Terminate 终止调试：暂停的程序继续执行且不再停下
+// 调用方负责结束虚拟机的执行 (如取消 vm.Limits.Context)
+func (d *Debugger) Terminate() {
+	d.resume(StateTerminated, StepNone, 0)
+	d.sendEvent(EventTerminated, "terminated", nil)
+}
+
+// resume 设置之后的状态，程序暂停时让它继续执行
+// 单步时其他线程照常执行，单步的线程执行到目标行时停下
+func (d *Debugger) resume(state DebugState, action StepAction, threadID int) {
 	d.mu.Lock()
-	d.state = StateStepping
-	d.stepAction = StepOut
-	d.stepDepth = len(d.callStack)
+	paused := d.state == StatePaused
+	if d.state != StateTerminated {
+		d.state = state
+	}
+	if threadID == 0 {
+		threadID = d.thread
+	}
+	d.stepAction = action
+	d.stepThread = threadID
+	d.stepDepth = 0
+	for _, t := range d.threads {
+		if t.ID == threadID {
+			d.stepDepth = t.Coroutine.Depth
+		}
+	}
+	d.frames = nil
+	d.exception = nil
+	d.dataHit = nil
 	d.mu.Unlock()
 	
-	select {
-	case d.resumeChan <- struct{}{}:
-	default:
+	if paused {
+		d.resumeChan <- struct{}{}
 	}
 }
 
-// Terminate 终止调试
-func (d *Debugger) Terminate() {
+// ============================================================================
+// VM 钩子
+// ============================================================================
+
+// Attach 把调试器接入虚拟机：每行执行前检查断点、单步和暂停请求，抛出异常时检查异常断点
+// 返回安装的钩子，用 v.RemoveHook 断开
+func (d *Debugger) Attach(v *vm.VM) vm.Hook {
 	d.mu.Lock()
-	d.state = StateTerminated
+	d.vm = v
 	d.mu.Unlock()
 	
-	d.sendEvent(EventTerminated, "terminated", nil)
+	h := &vmHook{d: d}
+	v.AddHook(h)
+	return h
 }
 
-// ============================================================================
-// VM 钩子（供 VM 调用）
-// ============================================================================
+// vmHook 把虚拟机的执行钩子转交给调试器
+type vmHook struct {
+	vm.BaseHook
+	d *Debugger
+}
+
+func (h *vmHook) Events() vm.HookEvent {
+	return vm.HookLine | vm.HookException | vm.HookDeadlock | vm.HookAccess
+}
+
+func (h *vmHook) OnLine(fn *bytecode.Function, line int) {
+	h.d.onLine(fn, line)
+}
+
+func (h *vmHook) OnThrow(ex *bytecode.Exception) {
+	h.d.onThrow(ex)
+}
 
-// OnInstruction VM 执行指令前调用
-// 返回 true 表示应该暂停执行
-func (d *Debugger) OnInstruction(file string, line int, funcName string) bool {
+func (h *vmHook) OnDeadlock() {
+	h.d.onDeadlock()
+}
+
+func (h *vmHook) OnAccess(a vm.Access) {
+	h.d.onAccess(a)
+}
+
+// onLine 即将执行新的一行
+func (d *Debugger) onLine(fn *bytecode.Function, line int) {
 	d.mu.Lock()
-	d.currentFile = file
+	if d.evaluating {
+		d.mu.Unlock()
+		return
+	}
+	entry := false
+	if !d.started {
+		// 第一行执行时程序已经加载完毕，把断点映射到字节码
+		d.started = true
+		entry = d.config.StopOnEntry
+		for _, bp := range d.breakpoints.Resolve(d.vm.Functions()) {
+			d.sendEvent(EventBreakpointChanged, "changed", bp)
+		}
+	}
+	d.currentFile = fn.SourceFile
 	d.currentLine = line
-	d.currentFunc = funcName
+	d.currentFunc = vm.FunctionName(fn)
 	state := d.state
 	stepAction := d.stepAction
 	stepDepth := d.stepDepth
-	callStackLen := len(d.callStack)
+	stepThread := d.stepThread
+	pause, pauseThread := d.pauseRequested, d.pauseThread
 	d.mu.Unlock()
 	
-	// 检查是否终止
 	if state == StateTerminated {
-		return true
+		return
+	}
+	
+	if entry {
+		d.stop(EventStopped, "entry")
+		return
+	}
+	thread := threadID(d.vm.CurrentCoroutine())
+	if pause && (pauseThread == 0 || pauseThread == thread || d.vm.CoroutineState(coroutineID(pauseThread)) != "ready") {
+		d.stop(EventStopped, "pause")
+		return
 	}
 	
 	// 检查断点
-	if d.breakpoints.ShouldBreak(file, line) {
-		d.mu.Lock()
-		d.state = StatePaused
-		d.mu.Unlock()
-		
-		d.sendEvent(EventBreakpoint, "breakpoint", map[string]interface{}{
-			"file": file,
-			"line": line,
-		})
-		
-		// 等待继续信号
-		<-d.resumeChan
-		return false
+	if d.breakpoints.ShouldBreak(fn.Chunk, line) {
+		d.stop(EventBreakpoint, "breakpoint")
+		return
 	}
 	
 	// 检查单步
 	if state == StateStepping {
+		if thread != stepThread {
+			// 单步的线程已经结束时，在任意线程的下一行停下
+			if d.vm.CoroutineState(coroutineID(stepThread)) == "done" {
+				d.stop(EventStep, "step")
+			}
+			return
+		}
+		
+		depth := d.vm.Depth()
 		shouldStop := false
 		
 		switch stepAction {
 		case StepIn:
-			// 每条指令都停
+			// 每行都停
 			shouldStop = true
 		case StepOver:
 			// 同级或更浅的深度停
-			shouldStop = callStackLen <= stepDepth
+			shouldStop = depth <= stepDepth
 		case StepOut:
 			// 更浅的深度停
-			shouldStop = callStackLen < stepDepth
+			shouldStop = depth < stepDepth
 		}
 		
 		if shouldStop {
-			d.mu.Lock()
-			d.state = StatePaused
-			d.mu.Unlock()
-			
-			d.sendEvent(EventStep, "step", map[string]interface{}{
-				"file": file,
-				"line": line,
-			})
-			
-			// 等待继续信号
-			<-d.resumeChan
+			d.stop(EventStep, "step")
 		}
 	}
-	
-	// 检查暂停状态
-	if state == StatePaused {
-		<-d.resumeChan
-	}
-	
-	return false
 }
 
-// OnFunctionEnter VM 进入函数时调用
-func (d *Debugger) OnFunctionEnter(funcName string, file string, line int) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// onThrow 抛出异常，调用栈仍是抛出处的调用栈
+func (d *Debugger) onThrow(ex *bytecode.Exception) {
+	d.mu.RLock()
+	state, evaluating := d.state, d.evaluating
+	d.mu.RUnlock()
+	if state == StateTerminated || evaluating {
+		return
+	}
 	
-	frame := StackFrame{
-		ID:     len(d.callStack),
-		Name:   funcName,
-		File:   file,
-		Line:   line,
-		Locals: make(map[string]bytecode.Value),
+	caught := func() bool { return d.vm.IsCaught(ex) }
+	if !d.breakpoints.ShouldBreakOnException(ex, caught) {
+		return
 	}
 	
-	d.callStack = append(d.callStack, frame)
+	info := newExceptionInfo(ex, 0)
+	info.Uncaught = !caught()
+	d.mu.Lock()
+	d.exception = info
+	d.mu.Unlock()
+	d.stop(EventException, "exception")
 }
 
-// OnFunctionExit VM 退出函数时调用
-func (d *Debugger) OnFunctionExit(funcName string) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	
-	if len(d.callStack) > 0 {
-		d.callStack = d.callStack[:len(d.callStack)-1]
+// onDeadlock 所有协程都在等待，暂停以便查看各协程阻塞的位置，继续后程序以死锁错误结束
+func (d *Debugger) onDeadlock() {
+	d.mu.RLock()
+	state, evaluating := d.state, d.evaluating
+	d.mu.RUnlock()
+	if state == StateTerminated || evaluating {
+		return
 	}
+	d.stop(EventDeadlock, "deadlock")
 }
 
-// OnException VM 发生异常时调用
-func (d *Debugger) OnException(excType string, message string, file string, line int) {
-	d.sendEvent(EventException, "exception", map[string]interface{}{
-		"type":    excType,
-		"message": message,
-		"file":    file,
-		"line":    line,
-	})
+// onAccess 读写了字段或全局变量，调用栈停在访问所在的指令
+func (d *Debugger) onAccess(a vm.Access) {
+	if !d.breakpoints.HasData() {
+		return
+	}
+	d.mu.RLock()
+	state, evaluating := d.state, d.evaluating
+	d.mu.RUnlock()
+	if state == StateTerminated || evaluating {
+		return
+	}
 	
-	// 在异常处暂停
+	bp := d.breakpoints.ShouldBreakOnAccess(a, d.conditionHolds)
+	if bp == nil {
+		return
+	}
 	d.mu.Lock()
-	d.state = StatePaused
+	d.dataHit = &DataBreakpointHit{Breakpoint: bp, Write: a.Write, Value: a.Value}
 	d.mu.Unlock()
+	d.stop(EventDataBreakpoint, "data breakpoint")
 }
 
-// SetLocal 设置局部变量（供 VM 调用）
-func (d *Debugger) SetLocal(name string, value bytecode.Value) {
+// stop 暂停执行，发送事件后等待继续信号，等待期间执行求值等命令
+func (d *Debugger) stop(eventType EventType, reason string) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	d.state = StatePaused
+	d.stepAction = StepNone
+	d.pauseRequested = false
+	d.snapshotThreads()
+	file, line, thread := d.currentFile, d.currentLine, d.thread
+	d.mu.Unlock()
 	
-	d.locals[name] = value
+	d.sendEvent(eventType, reason, map[string]interface{}{
+		"file":   file,
+		"line":   line,
+		"thread": thread,
+	})
 	
-	// 更新当前栈帧的局部变量
-	if len(d.callStack) > 0 {
-		d.callStack[len(d.callStack)-1].Locals[name] = value
+	for {
+		select {
+		case <-d.resumeChan:
+			return
+		case cmd := <-d.commands:
+			cmd()
+		}
 	}
 }
 
-// SetGlobal 设置全局变量（供 VM 调用）
-func (d *Debugger) SetGlobal(name string, value bytecode.Value) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.globals[name] = value
+// snapshotThreads 记录暂停时的线程和各线程的调用栈 (调用方持有锁)
+func (d *Debugger) snapshotThreads() {
+	current := d.vm.CurrentCoroutine()
+	d.thread = threadID(current)
+	d.frames = d.vm.Frames()
+	d.threads = d.threads[:0]
+	for _, co := range d.vm.Coroutines() {
+		d.threads = append(d.threads, Thread{ID: threadID(co.ID), Name: threadName(co), Coroutine: co})
+		if co.ID != current {
+			d.frames = append(d.frames, d.vm.CoroutineFrames(co.ID)...)
+		}
+	}
+}
+
+// threadID 返回协程对应的线程 ID
+func threadID(coroutine int64) int {
+	return int(coroutine) + 1
+}
+
+// coroutineID 返回线程对应的协程 ID
+func coroutineID(threadID int) int64 {
+	return int64(threadID - 1)
+}
+
+// threadName 返回线程名：主协程为 main，其他协程以创建处命名
+func threadName(co vm.CoroutineInfo) string {
+	if co.Main {
+		return "main"
+	}
+	if co.SpawnFunc == nil {
+		return fmt.Sprintf("coroutine %d", co.ID)
+	}
+	return fmt.Sprintf("coroutine %d @ %s:%d", co.ID, filepath.Base(co.SpawnFunc.SourceFile), co.SpawnLine)
 }
 
 // ============================================================================
-// 查询操作
+// 查询操作 (程序暂停时有效)
 // ============================================================================
 
-// GetCallStack 获取调用栈
-func (d *Debugger) GetCallStack() []StackFrame {
+// GetThreads 获取最近一次暂停时的线程，还没有暂停过时只有主线程
+func (d *Debugger) GetThreads() []Thread {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	
-	// 复制调用栈
-	stack := make([]StackFrame, len(d.callStack))
-	copy(stack, d.callStack)
-	
-	// 反转顺序（最近的在前）
-	for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
-		stack[i], stack[j] = stack[j], stack[i]
+	if len(d.threads) == 0 {
+		return []Thread{{ID: 1, Name: "main", Coroutine: vm.CoroutineInfo{Main: true}}}
 	}
+	return append([]Thread(nil), d.threads...)
+}
+
+// GetCallStack 获取线程的调用栈 (最内层在前)
+func (d *Debugger) GetCallStack(threadID int) []StackFrame {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	
+	var stack []StackFrame
+	for i, f := range d.frames {
+		if coroutineID(threadID) != f.Coroutine {
+			continue
+		}
+		if d.config.MaxCallStackDepth > 0 && len(stack) >= d.config.MaxCallStackDepth {
+			break
+		}
+		stack = append(stack, StackFrame{
+			ID:   i,
+			Name: vm.FunctionName(f.Function),
+			File: f.Function.SourceFile,
+			Line: f.Line,
+		})
+	}
 	return stack
 }
 
-// GetLocals 获取局部变量
-func (d *Debugger) GetLocals(frameID int) map[string]bytecode.Value {
+// GetLocals 获取帧中可见的局部变量，方法帧的第一个变量为 this
+func (d *Debugger) GetLocals(frameID int) []vm.Variable {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	
-	// 从调用栈获取指定帧的局部变量
-	if frameID >= 0 && frameID < len(d.callStack) {
-		// 反转索引
-		idx := len(d.callStack) - 1 - frameID
-		return d.callStack[idx].Locals
+	if frameID < 0 || frameID >= len(d.frames) {
+		return nil
 	}
-	
-	return d.locals
+	f := d.frames[frameID]
+	var vars []vm.Variable
+	if this, ok := d.vm.This(f); ok {
+		vars = append(vars, vm.Variable{Name: "this", Type: f.Function.ClassName, Value: this})
+	}
+	return append(vars, d.vm.Locals(f)...)
 }
 
 // GetGlobals 获取全局变量
-func (d *Debugger) GetGlobals() map[string]bytecode.Value {
+func (d *Debugger) GetGlobals() []vm.Variable {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	
-	result := make(map[string]bytecode.Value)
-	for k, v := range d.globals {
-		result[k] = v
+	if d.frames == nil {
+		return nil
 	}
-	return result
+	return d.vm.Globals()
 }
 
-// GetCurrentPosition 获取当前位置
-func (d *Debugger) GetCurrentPosition() (file string, line int, funcName string) {
+// GetDataBreakpointHit 获取暂停处命中的数据断点，不是在数据断点处暂停时返回 nil
+func (d *Debugger) GetDataBreakpointHit() *DataBreakpointHit {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
-	return d.currentFile, d.currentLine, d.currentFunc
+	return d.dataHit
 }
 
-// EvaluateExpression 求值表达式
-func (d *Debugger) EvaluateExpression(expr string, frameID int) (bytecode.Value, error) {
-	// 简单实现：查找变量
+// GetException 获取暂停处抛出的异常，不是在异常处暂停时返回 nil
+func (d *Debugger) GetException() *ExceptionInfo {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
+	return d.exception
+}
+
+// 原因链展开的最大深度
+const maxCauseDepth = 10
+
+// newExceptionInfo 从抛出的异常提取信息
+func newExceptionInfo(ex *bytecode.Exception, depth int) *ExceptionInfo {
+	if ex.Object != nil {
+		return newObjectExceptionInfo(ex.Object, ex, depth)
+	}
+	info := &ExceptionInfo{
+		Type:       ex.Type,
+		Message:    ex.Message,
+		StackTrace: ex.GetStackTraceAsString(),
+		Value:      bytecode.NewExceptionValue(ex),
+	}
+	if ex.Cause != nil && depth < maxCauseDepth {
+		info.Cause = newExceptionInfo(ex.Cause, depth+1)
+	}
+	return info
+}
+
+// newObjectExceptionInfo 从异常对象的 message、stackTrace 和 previous 属性提取信息
+// ex 为抛出的异常，对象没有 stackTrace 属性时使用它的调用栈；原因链上的对象为 nil
+func newObjectExceptionInfo(obj *bytecode.Object, ex *bytecode.Exception, depth int) *ExceptionInfo {
+	info := &ExceptionInfo{
+		Type:  obj.Class.FullName(),
+		Value: bytecode.NewObject(obj),
+	}
+	if msg, ok := obj.GetField("message"); ok {
+		info.Message = msg.AsString()
+	} else if ex != nil {
+		info.Message = ex.Message
+	}
 	
-	// 先在局部变量中查找
-	if frameID >= 0 && frameID < len(d.callStack) {
-		idx := len(d.callStack) - 1 - frameID
-		if val, ok := d.callStack[idx].Locals[expr]; ok {
-			return val, nil
+	if trace, ok := obj.GetField("stackTrace"); ok && trace.Type() == bytecode.ValArray && len(trace.AsArray()) > 0 {
+		// 与 Throwable.getTraceAsString 相同的格式
+		lines := make([]string, 0, len(trace.AsArray()))
+		for _, frame := range trace.AsArray() {
+			if s := frame.AsString(); strings.HasPrefix(s, "...") {
+				lines = append(lines, "    "+s)
+			} else {
+				lines = append(lines, "    at "+s)
+			}
 		}
-	} else if val, ok := d.locals[expr]; ok {
-		return val, nil
+		info.StackTrace = strings.Join(lines, "\n")
+	} else if ex != nil {
+		info.StackTrace = ex.GetStackTraceAsString()
 	}
 	
-	// 再在全局变量中查找
-	if val, ok := d.globals[expr]; ok {
-		return val, nil
+	if depth >= maxCauseDepth {
+		return info
 	}
-	
-	return bytecode.NullValue, fmt.Errorf("cannot evaluate expression: %s", expr)
+	if prev, ok := obj.GetField("previous"); ok {
+		switch {
+		case prev.IsObject():
+			info.Cause = newObjectExceptionInfo(prev.AsObject(), nil, depth+1)
+		case prev.IsException():
+			info.Cause = newExceptionInfo(prev.AsException(), depth+1)
+		}
+	} else if ex != nil && ex.Cause != nil {
+		info.Cause = newExceptionInfo(ex.Cause, depth+1)
+	}
+	return info
+}
+
+// GetCurrentPosition 获取当前位置
+func (d *Debugger) GetCurrentPosition() (file string, line int, funcName string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.currentFile, d.currentLine, d.currentFunc
 }
 
 // ============================================================================
@@ -539,8 +801,13 @@ func (d *Debugger) Reset() {
 	
 	d.state = StateRunning
 	d.stepAction = StepNone
-	d.callStack = nil
-	d.locals = make(map[string]bytecode.Value)
+	d.pauseRequested = false
+	d.started = false
+	d.frames = nil
+	d.threads = nil
+	d.thread = 0
+	d.exception = nil
+	d.dataHit = nil
 	d.currentFile = ""
 	d.currentLine = 0
 	d.currentFunc = ""
@@ -0,0 +1,458 @@
+// eval.go - 表达式求值
+//
+// 表达式在暂停的帧中编译和执行：
+// 1. 帧中在暂停处可见的局部变量 (DebugInfo.GetVariablesInScope) 和全局变量
+//    作为求值函数的参数，$this 放在 slot 0
+// 2. 求值函数在执行脚本的 goroutine 上以嵌套执行运行 (暂停时它在等待命令)，
+//    返回后暂停处的栈和调用帧保持不变，嵌套执行期间调试器忽略钩子
+// 3. 允许副作用时，表达式中对变量的赋值写回暂停的帧
+//
+// 不允许副作用时只接受读取状态的表达式：不能赋值、自增自减、调用函数或方法、创建对象。
+
+package debug
+
+import (
+	"fmt"
+
+	"github.com/tangzhangming/nova/internal/ast"
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/compiler"
+	"github.com/tangzhangming/nova/internal/parser"
+	"github.com/tangzhangming/nova/internal/token"
+	"github.com/tangzhangming/nova/internal/vm"
+)
+
+// SetSymbolTable 设置编译程序时的符号表
+// 没有符号表时求值的表达式只能访问变量，不能访问类的属性和方法
+func (d *Debugger) SetSymbolTable(st *compiler.SymbolTable) {
+	d.mu.Lock()
+	d.symbols = st
+	d.mu.Unlock()
+}
+
+// Evaluate 在帧中求值表达式
+// sideEffects 为 false 时拒绝可能修改状态的表达式；为 true 时允许调用方法，赋值写回暂停的帧
+func (d *Debugger) Evaluate(expr string, frameID int, sideEffects bool) (bytecode.Value, error) {
+	e, err := parseExpression(expr)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	if !sideEffects {
+		if err := checkNoSideEffects(e); err != nil {
+			return bytecode.NullValue, err
+		}
+	}
+	return d.evaluate(e, frameID, sideEffects)
+}
+
+// SetExpression 把可赋值的表达式 (变量、属性或下标) 设为另一个表达式的值，返回新值
+// 两个表达式本身都不能有副作用
+func (d *Debugger) SetExpression(target, value string, frameID int) (bytecode.Value, error) {
+	left, err := parseExpression(target)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	switch left.(type) {
+	case *ast.Variable, *ast.PropertyAccess, *ast.IndexExpr:
+	default:
+		return bytecode.NullValue, fmt.Errorf("cannot assign to %s", target)
+	}
+	right, err := parseExpression(value)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	for _, e := range []ast.Expression{left, right} {
+		if err := checkNoSideEffects(e); err != nil {
+			return bytecode.NullValue, err
+		}
+	}
+
+	assign := &ast.AssignExpr{
+		Left:     left,
+		Operator: token.Token{Type: token.ASSIGN, Literal: "=", Pos: left.Pos()},
+		Right:    right,
+	}
+	return d.evaluate(assign, frameID, true)
+}
+
+// SetVariable 把帧中的局部变量或全局变量设为表达式的值，返回新值
+func (d *Debugger) SetVariable(name, value string, frameID int) (bytecode.Value, error) {
+	if name == "this" {
+		return bytecode.NullValue, fmt.Errorf("cannot assign to $this")
+	}
+	return d.SetExpression("$"+name, value, frameID)
+}
+
+// SetMember 把对象的属性、数组的元素或 Map 的值设为表达式的值，返回新值
+// name 为变量视图中显示的名称：属性名、"[下标]" 或 Map 键的字符串形式
+func (d *Debugger) SetMember(container bytecode.Value, name, value string, frameID int) (bytecode.Value, error) {
+	v, err := d.Evaluate(value, frameID, false)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	d.inVM(func() {
+		err = setMember(container, name, v)
+	})
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	return v, nil
+}
+
+// setMember 修改容器中的值
+func setMember(container bytecode.Value, name string, v bytecode.Value) error {
+	switch container.Type() {
+	case bytecode.ValObject:
+		if obj := container.AsObject(); obj != nil {
+			obj.SetField(name, v)
+			return nil
+		}
+	case bytecode.ValArray:
+		var i int
+		arr := container.AsArray()
+		if _, err := fmt.Sscanf(name, "[%d]", &i); err != nil || i < 0 || i >= len(arr) {
+			return fmt.Errorf("invalid index: %s", name)
+		}
+		arr[i] = v
+		return nil
+	case bytecode.ValMap:
+		m := container.AsMap()
+		for k := range m {
+			if k.String() == name {
+				m[k] = v
+				return nil
+			}
+		}
+		return fmt.Errorf("key not found: %s", name)
+	}
+	return fmt.Errorf("cannot modify %s", name)
+}
+
+// EvaluateExpression 求值表达式，不允许副作用
+func (d *Debugger) EvaluateExpression(expr string, frameID int) (bytecode.Value, error) {
+	return d.Evaluate(expr, frameID, false)
+}
+
+// parseExpression 解析求值的表达式
+func parseExpression(expr string) (ast.Expression, error) {
+	e, errs := parser.ParseExpression(expr, "<eval>")
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", errs[0].Message)
+	}
+	return e, nil
+}
+
+// evaluate 在暂停的帧中编译并执行表达式，writeBack 时把变量的新值写回帧
+func (d *Debugger) evaluate(expr ast.Expression, frameID int, writeBack bool) (bytecode.Value, error) {
+	d.mu.RLock()
+	if d.state != StatePaused {
+		d.mu.RUnlock()
+		return bytecode.NullValue, fmt.Errorf("program is not paused")
+	}
+	if frameID < 0 || frameID >= len(d.frames) {
+		d.mu.RUnlock()
+		return bytecode.NullValue, fmt.Errorf("invalid frame: %d", frameID)
+	}
+	f := d.frames[frameID]
+	d.mu.RUnlock()
+
+	var result bytecode.Value
+	var err error
+	d.inVM(func() {
+		result, err = d.evaluateInFrame(expr, f, writeBack)
+	})
+	return result, err
+}
+
+// evaluateInFrame 在执行脚本的 goroutine 上求值
+func (d *Debugger) evaluateInFrame(expr ast.Expression, f vm.Frame, writeBack bool) (bytecode.Value, error) {
+	this, className := d.receiver(f)
+
+	// 局部变量遮蔽同名的全局变量
+	vars := d.vm.Locals(f)
+	seen := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		seen[v.Name] = true
+	}
+	for _, g := range d.vm.Globals() {
+		if !seen[g.Name] {
+			vars = append(vars, g)
+		}
+	}
+
+	fn, err := d.compileEval(expr, className, vars)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	values, err := d.runEval(fn, this, vars)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+
+	if writeBack {
+		for i, v := range vars {
+			if v.Slot >= 0 {
+				d.vm.SetLocal(f, v.Slot, values[i+1])
+			} else {
+				d.vm.SetGlobal(v.Global, values[i+1])
+			}
+		}
+	}
+	return values[0], nil
+}
+
+// receiver 返回帧的 $this 和求值时所在的类，不是方法帧时类为空
+func (d *Debugger) receiver(f vm.Frame) (bytecode.Value, string) {
+	this, ok := d.vm.This(f)
+	if !ok {
+		return this, ""
+	}
+	return this, f.Function.ClassName
+}
+
+// compileEval 把表达式编译为求值函数，vars 依次作为参数
+func (d *Debugger) compileEval(expr ast.Expression, className string, vars []vm.Variable) (*bytecode.Function, error) {
+	params := make([]compiler.EvalVar, len(vars))
+	for i, v := range vars {
+		params[i] = compiler.EvalVar{Name: v.Name, Type: v.Type}
+	}
+
+	d.mu.RLock()
+	c := compiler.New()
+	if d.symbols != nil {
+		c = compiler.NewWithSymbolTable(d.symbols)
+	}
+	d.mu.RUnlock()
+	fn, errs := c.CompileEval(expr, className, params)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", errs[0].Message)
+	}
+	return fn, nil
+}
+
+// runEval 以 vars 的值为参数执行求值函数，返回表达式的值和各变量执行后的值
+func (d *Debugger) runEval(fn *bytecode.Function, this bytecode.Value, vars []vm.Variable) ([]bytecode.Value, error) {
+	args := make([]bytecode.Value, len(vars))
+	for i, v := range vars {
+		args[i] = v.Value
+	}
+
+	d.mu.Lock()
+	d.evaluating = true
+	d.mu.Unlock()
+	result, err := d.vm.Eval(fn, this, args)
+	d.mu.Lock()
+	d.evaluating = false
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return result.AsArray(), nil
+}
+
+// condition 断点条件编译后的求值函数，缓存在断点上
+// 求值函数只以条件引用的变量为参数，这些变量在访问处的绑定 (局部变量槽位或全局变量) 和所在的类
+// 与上次编译时相同时直接复用，命中时不再解析、编译表达式或遍历全局变量
+type condition struct {
+	source string         // 条件表达式，与断点的条件不同时缓存失效
+	expr   ast.Expression // 无法解析或可能有副作用时为 nil
+	names  []string       // 条件引用的变量
+
+	globals map[string]vm.Variable // 按名称索引的全局变量，第一次需要时建立
+
+	// 上次编译的结果
+	compiled  bool
+	className string
+	binds     []vm.Variable
+	fn        *bytecode.Function
+	err       error
+}
+
+// newCondition 解析断点条件
+func newCondition(source string) *condition {
+	c := &condition{source: source}
+	e, err := parseExpression(source)
+	if err != nil {
+		return c
+	}
+	seen := make(map[string]bool)
+	if !readsOnly(e, func(v *ast.Variable) {
+		if !seen[v.Name] {
+			seen[v.Name] = true
+			c.names = append(c.names, v.Name)
+		}
+	}) {
+		return c
+	}
+	c.expr = e
+	return c
+}
+
+// bind 返回条件引用的变量在帧中的绑定和当前值，局部变量遮蔽同名的全局变量
+// 有变量既不是局部变量也不是全局变量时返回 false
+func (c *condition) bind(v *vm.VM, f vm.Frame) ([]vm.Variable, bool) {
+	var locals []vm.Variable
+	if len(c.names) > 0 {
+		locals = v.Locals(f)
+	}
+	binds := make([]vm.Variable, 0, len(c.names))
+	for _, name := range c.names {
+		if l, ok := findVariable(locals, name); ok {
+			binds = append(binds, l)
+			continue
+		}
+		if c.globals == nil {
+			c.globals = make(map[string]vm.Variable)
+			for _, g := range v.Globals() {
+				c.globals[g.Name] = g
+			}
+		}
+		g, ok := c.globals[name]
+		if !ok {
+			// 之后加载的代码可能声明这个全局变量
+			c.globals = nil
+			return nil, false
+		}
+		g.Value = v.GetGlobal(g.Global)
+		binds = append(binds, g)
+	}
+	return binds, true
+}
+
+// findVariable 按名称查找变量
+func findVariable(vars []vm.Variable, name string) (vm.Variable, bool) {
+	for _, v := range vars {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return vm.Variable{}, false
+}
+
+// sameBindings 两组绑定是否对应相同的变量
+func sameBindings(a, b []vm.Variable) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type || a[i].Slot != b[i].Slot || a[i].Global != b[i].Global {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionHolds 在最内层帧中求值断点条件，在执行脚本的 goroutine 上调用
+// 条件有错误时视为成立，让用户在暂停处看到问题
+func (d *Debugger) conditionHolds(bp *Breakpoint) bool {
+	c := bp.cond.Load()
+	if c == nil || c.source != bp.Condition {
+		c = newCondition(bp.Condition)
+		bp.cond.Store(c)
+	}
+	if c.expr == nil {
+		return true
+	}
+	frames := d.vm.Frames()
+	if len(frames) == 0 {
+		return true
+	}
+	this, className := d.receiver(frames[0])
+	binds, ok := c.bind(d.vm, frames[0])
+	if !ok {
+		return true
+	}
+	if !c.compiled || c.className != className || !sameBindings(c.binds, binds) {
+		c.fn, c.err = d.compileEval(c.expr, className, binds)
+		c.compiled, c.className, c.binds = true, className, binds
+	}
+	if c.err != nil {
+		return true
+	}
+	values, err := d.runEval(c.fn, this, binds)
+	return err != nil || values[0].IsTruthy()
+}
+
+// inVM 在执行脚本的 goroutine 上执行 fn 并等待完成
+// 程序必须处于暂停状态，调用方不能同时让程序继续执行
+func (d *Debugger) inVM(fn func()) {
+	done := make(chan struct{})
+	d.commands <- func() {
+		defer close(done)
+		fn()
+	}
+	<-done
+}
+
+// checkNoSideEffects 检查表达式只读取状态
+func checkNoSideEffects(expr ast.Expression) error {
+	if !readsOnly(expr, func(*ast.Variable) {}) {
+		return fmt.Errorf("expression may have side effects: %s", expr)
+	}
+	return nil
+}
+
+// readsOnly 报告表达式是否只读取状态，并对其中读取的每个变量调用 visit
+func readsOnly(expr ast.Expression, visit func(v *ast.Variable)) bool {
+	var check func(e ast.Expression) bool
+	check = func(e ast.Expression) bool {
+		switch e := e.(type) {
+		case nil:
+			return true
+		case *ast.Variable:
+			visit(e)
+			return true
+		case *ast.ThisExpr, *ast.SelfExpr, *ast.ParentExpr, *ast.Identifier,
+			*ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.BoolLiteral, *ast.NullLiteral:
+			return true
+		case *ast.InterpStringLiteral:
+			for _, part := range e.Parts {
+				if !check(part) {
+					return false
+				}
+			}
+			return true
+		case *ast.ArrayLiteral:
+			for _, elem := range e.Elements {
+				if !check(elem) {
+					return false
+				}
+			}
+			return true
+		case *ast.MapLiteral:
+			for _, pair := range e.Pairs {
+				if !check(pair.Key) || !check(pair.Value) {
+					return false
+				}
+			}
+			return true
+		case *ast.UnaryExpr:
+			if e.Operator.Type == token.INCREMENT || e.Operator.Type == token.DECREMENT {
+				return false
+			}
+			return check(e.Operand)
+		case *ast.BinaryExpr:
+			return check(e.Left) && check(e.Right)
+		case *ast.NullCoalesceExpr:
+			return check(e.Left) && check(e.Right)
+		case *ast.TernaryExpr:
+			return check(e.Condition) && check(e.Then) && check(e.Else)
+		case *ast.IsExpr:
+			return check(e.Expr)
+		case *ast.TypeCastExpr:
+			return check(e.Expr)
+		case *ast.NonNullAssertExpr:
+			return check(e.Expr)
+		case *ast.IndexExpr:
+			return check(e.Object) && check(e.Index)
+		case *ast.PropertyAccess:
+			return check(e.Object)
+		case *ast.SafePropertyAccess:
+			return check(e.Object)
+		case *ast.StaticAccess:
+			return check(e.Class) && check(e.Member)
+		}
+		return false
+	}
+	return check(expr)
+}
@@ -0,0 +1,126 @@
+package debug
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/runtime"
+)
+
+const counterProgram = `class Counter {
+    public int $n = 0;
+
+    public function add(int $step): void {
+        $this->n = $this->n + $step;
+    }
+}
+
+class main {
+    public static function main(): void {
+        $c := new Counter();
+        for ($i := 0; $i < 6; $i++) {
+            $c->add(1);
+        }
+        print($c->n);
+    }
+}
+`
+
+// waitEvent 等待类型为 want 的事件，跳过其他事件
+func waitEvent(t *testing.T, d *Debugger, want EventType) DebugEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-d.Events():
+			if e.Type == want {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for event %d", want)
+		}
+	}
+}
+
+func TestDataBreakpointConditionIsCompiledOnce(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "main.sola")
+	d := NewDebugger()
+	r := runtime.NewWithOptions(runtime.Options{})
+	d.Attach(r.VM())
+	d.SetSymbolTable(r.SymbolTable())
+	line, err := d.SetBreakpoint(program, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(counterProgram, program) }()
+	waitEvent(t, d, EventBreakpoint)
+	d.RemoveBreakpoint(line.ID)
+
+	var counter DataTarget
+	for _, v := range d.GetLocals(0) {
+		if v.Name == "c" {
+			counter = DataTarget{Object: v.Value.AsObject(), Field: "n"}
+		}
+	}
+	if counter.Object == nil {
+		t.Fatal("local $c not found")
+	}
+	bps, errs := d.SetDataBreakpoints([]DataBreakpointSpec{{
+		DataID:    d.DataBreakpointID(counter),
+		Access:    AccessWrite,
+		Condition: "$this->n >= $step * 3",
+	}})
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	bp := bps[0]
+
+	// 条件只引用 $this 的字段和参数，之后的命中复用第一次编译的求值函数
+	d.Continue()
+	waitEvent(t, d, EventDataBreakpoint)
+	if hit := d.GetDataBreakpointHit(); hit == nil || hit.Value.AsInt() != 3 {
+		t.Fatalf("hit = %+v, want n = 3", hit)
+	}
+	first := bp.cond.Load()
+	if first == nil || first.fn == nil || first.err != nil {
+		t.Fatalf("condition was not compiled: %+v", first)
+	}
+	fn := first.fn
+	d.Continue()
+	waitEvent(t, d, EventDataBreakpoint)
+	if hit := d.GetDataBreakpointHit(); hit == nil || hit.Value.AsInt() != 4 {
+		t.Fatalf("hit = %+v, want n = 4", hit)
+	}
+	if c := bp.cond.Load(); c != first || c.fn != fn {
+		t.Fatal("condition was recompiled for an unchanged frame")
+	}
+
+	// 修改条件后缓存失效
+	if err := d.breakpoints.SetCondition(bp.ID, "$this->n == 6"); err != nil {
+		t.Fatal(err)
+	}
+	if bp.cond.Load() != nil {
+		t.Fatal("changing the condition kept the compiled one")
+	}
+	d.Continue()
+	waitEvent(t, d, EventDataBreakpoint)
+	if hit := d.GetDataBreakpointHit(); hit.Value.AsInt() != 6 {
+		t.Fatalf("hit = %+v, want n = 6", hit)
+	}
+	if c := bp.cond.Load(); c == nil || c.source != "$this->n == 6" || c.fn == fn {
+		t.Fatalf("condition was not recompiled: %+v", c)
+	}
+
+	d.Continue()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("program did not finish")
+	}
+}
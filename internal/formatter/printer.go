@@ -876,6 +876,18 @@ func (p *Printer) printStatement(stmt ast.Statement) {
 			p.printBlock(s.Finally.Body)
 		}
 		p.writeln()
+	case *ast.UsingStmt:
+		p.writeIndent()
+		p.write("using (")
+		for i, res := range s.Resources {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.printStatementInline(res)
+		}
+		p.write(")")
+		p.printBlock(s.Body)
+		p.writeln()
 	case *ast.ThrowStmt:
 		p.writeIndent()
 		p.write("throw ")
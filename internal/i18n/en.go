@@ -95,6 +95,7 @@ var messagesEN = map[string]string{
 	ErrInterfaceMethodParamMismatch: "method '%s' in class '%s' has parameter type mismatch with interface '%s' (expected %s, got %s)",
 	ErrInterfaceMethodReturnMismatch: "method '%s' in class '%s' has return type incompatible with interface '%s' (expected %s, got %s)",
 	ErrInterfaceMethodStaticMismatch: "method '%s' in class '%s' has static/instance mismatch with interface '%s'",
+	ErrUsingNotCloseable:            "using resource '$%s' of type %s does not implement sola.lang.AutoCloseable",
 	
 	// Null safety checks
 	ErrNullableAccess:          "cannot access member of nullable type '%s', check for null first or use safe call '?.'",
@@ -160,6 +161,9 @@ var messagesEN = map[string]string{
 	ErrCompileError:       "Compile error: %s",
 	ErrCompileFailed:      "compile failed",
 	ErrCompileFailedFor:   "compile failed for %s",
+	ErrImageInitFailed:    "static initializer of %s failed while building the image: %v",
+	ErrImageStaticFailed:  "cannot build image: %v",
+	WarnResourceLeaked:    "warning: %s handle %d was garbage collected without being closed, closing it",
 
 	// ========== Loader ==========
 	ErrGetExecutablePath:    "failed to get executable path: %v",
@@ -96,6 +96,7 @@ const (
 	ErrInterfaceMethodParamMismatch = "compiler.interface_method_param_mismatch"
 	ErrInterfaceMethodReturnMismatch = "compiler.interface_method_return_mismatch"
 	ErrInterfaceMethodStaticMismatch = "compiler.interface_method_static_mismatch"
+	ErrUsingNotCloseable            = "compiler.using_not_closeable"
 	
 	// 空安全检查相关
 	ErrNullableAccess               = "compiler.nullable_access"
@@ -161,6 +162,9 @@ const (
 	ErrCompileError       = "runtime.compile_error"
 	ErrCompileFailed      = "runtime.compile_failed"
 	ErrCompileFailedFor   = "runtime.compile_failed_for"
+	ErrImageInitFailed    = "runtime.image_init_failed"
+	ErrImageStaticFailed  = "runtime.image_static_failed"
+	WarnResourceLeaked    = "runtime.resource_leaked"
 
 	// ========== 包加载器 ==========
 	ErrGetExecutablePath    = "loader.get_executable_path"
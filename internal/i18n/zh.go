@@ -95,6 +95,7 @@ var messagesZH = map[string]string{
 	ErrInterfaceMethodParamMismatch: "类 '%s' 的方法 '%s' 参数类型与接口 '%s' 不匹配（期望 %s，实际 %s）",
 	ErrInterfaceMethodReturnMismatch: "类 '%s' 的方法 '%s' 返回类型与接口 '%s' 不兼容（期望 %s，实际 %s）",
 	ErrInterfaceMethodStaticMismatch: "类 '%s' 的方法 '%s' 与接口 '%s' 的静态/实例属性不匹配",
+	ErrUsingNotCloseable:            "using 资源 '$%s' 的类型 %s 没有实现 sola.lang.AutoCloseable",
 	
 	// 空安全检查相关
 	ErrNullableAccess:          "不能访问可空类型 '%s' 的成员，请先检查 null 或使用安全调用 '?.'",
@@ -160,6 +161,9 @@ var messagesZH = map[string]string{
 	ErrCompileError:       "编译错误: %s",
 	ErrCompileFailed:      "编译失败",
 	ErrCompileFailedFor:   "%s 编译失败",
+	ErrImageInitFailed:    "构建映像时 %s 的静态初始化失败: %v",
+	ErrImageStaticFailed:  "无法构建映像: %v",
+	WarnResourceLeaked:    "警告: %s 句柄 %d 未关闭即被垃圾回收，已自动关闭",
 
 	// ========== 包加载器 ==========
 	ErrGetExecutablePath:    "获取可执行文件路径失败: %v",
@@ -602,3 +602,10 @@ func (l *Loader) GetProjectNamespace() string {
 func (l *Loader) RootDir() string {
 	return l.rootDir
 }
+
+// SetLibDir 指定标准库目录，覆盖按可执行文件位置查找的结果。
+//
+// 嵌入 Sola 的 Go 程序的可执行文件通常不在 sola 安装目录中，需要显式指定。
+func (l *Loader) SetLibDir(dir string) {
+	l.libDir = dir
+}
@@ -121,6 +121,20 @@ func (p *Parser) Parse() *ast.File {
 	return file
 }
 
+// ParseExpression 解析单个表达式（可以以分号结尾），用于调试器求值等场景
+func ParseExpression(source, filename string) (ast.Expression, []Error) {
+	p := New(source, filename)
+	expr := p.parseExpression()
+	p.match(token.SEMICOLON)
+	if !p.HasErrors() && !p.isAtEnd() {
+		p.error(fmt.Sprintf("unexpected '%s' after expression", p.peek().Literal))
+	}
+	if p.HasErrors() {
+		return nil, p.errors
+	}
+	return expr, nil
+}
+
 // Errors 返回所有语法错误
 func (p *Parser) Errors() []Error {
 	return p.errors
@@ -294,7 +308,7 @@ func (p *Parser) synchronize() {
 		case token.CLASS, token.INTERFACE, token.ENUM, token.TYPE,
 			token.ABSTRACT, token.FINAL, token.PUBLIC, token.PROTECTED, token.PRIVATE,
 			token.FUNCTION, token.IF, token.FOR, token.FOREACH, token.WHILE, token.DO,
-			token.RETURN, token.TRY, token.THROW, token.BREAK, token.CONTINUE,
+			token.RETURN, token.TRY, token.USING, token.THROW, token.BREAK, token.CONTINUE,
 			token.NAMESPACE, token.USE, token.AT, token.SWITCH:
 			return
 		}
@@ -1832,6 +1846,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseReturnStmt()
 	case token.TRY:
 		return p.parseTryStmt()
+	case token.USING:
+		return p.parseUsingStmt()
 	case token.THROW:
 		return p.parseThrowStmt()
 	case token.GO:
@@ -2070,7 +2086,7 @@ func (p *Parser) parseBlock() *ast.BlockStmt {
 			// 块内错误恢复：跳到下一个语句或块结束
 			for !p.check(token.RBRACE) && !p.isAtEnd() && !p.checkAny(
 				token.IF, token.FOR, token.FOREACH, token.WHILE, token.DO,
-				token.RETURN, token.TRY, token.THROW, token.BREAK, token.CONTINUE,
+				token.RETURN, token.TRY, token.USING, token.THROW, token.BREAK, token.CONTINUE,
 				token.SWITCH, token.VARIABLE) {
 				if p.previous().Type == token.SEMICOLON || p.previous().Type == token.RBRACE {
 					break
@@ -2627,6 +2643,81 @@ func (p *Parser) parseTryStmt() *ast.TryStmt {
 	}
 }
 
+// parseUsingStmt 解析 using 语句
+//
+// 语法:
+//
+//	using (<resource> {, <resource>}) <block>
+//	resource = Type $var = expr | $var := expr
+//
+// 示例:
+//
+//	using (Stream $in = new Stream("a.txt"), $out := new Stream("b.txt", "w")) { ... }
+func (p *Parser) parseUsingStmt() *ast.UsingStmt {
+	usingToken := p.advance() // 消费 'using'
+	p.consume(token.LPAREN, "expected '(' after 'using'")
+	if p.panicMode {
+		return nil
+	}
+
+	var resources []*ast.VarDeclStmt
+	for {
+		res := p.parseUsingResource()
+		if p.panicMode {
+			return nil
+		}
+		resources = append(resources, res)
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+
+	p.consume(token.RPAREN, "expected ')' after using resources")
+	if p.panicMode {
+		return nil
+	}
+	body := p.parseBlock()
+	if p.panicMode {
+		return nil
+	}
+
+	return &ast.UsingStmt{
+		UsingToken: usingToken,
+		Resources:  resources,
+		Body:       body,
+	}
+}
+
+// parseUsingResource 解析 using 语句中的一个资源声明 (没有结尾的分号)
+func (p *Parser) parseUsingResource() *ast.VarDeclStmt {
+	var varType ast.TypeNode
+	if p.isTypeStart() {
+		varType = p.parseType()
+	}
+	varToken := p.consume(token.VARIABLE, "expected variable name in using resource")
+	if p.panicMode {
+		return nil
+	}
+	varName := &ast.Variable{Token: varToken, Name: varToken.Literal[1:]}
+
+	var op token.Token
+	if varType != nil {
+		op = p.consume(token.ASSIGN, "expected '=' after variable name")
+	} else {
+		op = p.consume(token.DECLARE, "expected ':=' after variable name")
+	}
+	if p.panicMode {
+		return nil
+	}
+
+	return &ast.VarDeclStmt{
+		Type:     varType,
+		Name:     varName,
+		Operator: op,
+		Value:    p.parseExpression(),
+	}
+}
+
 func (p *Parser) parseThrowStmt() *ast.ThrowStmt {
 	throwToken := p.advance()
 	exception := p.parseExpression()
@@ -2649,6 +2740,7 @@ func (p *Parser) parseThrowStmt() *ast.ThrowStmt {
 //
 //	go processData();
 //	go $obj->method();
+//	go Worker::run();
 //	go function() { ... }();
 func (p *Parser) parseGoStmt() *ast.GoStmt {
 	goToken := p.advance() // 消费 'go'
@@ -2657,9 +2749,14 @@ func (p *Parser) parseGoStmt() *ast.GoStmt {
 	call := p.parseExpression()
 
 	// 验证必须是函数调用
-	switch call.(type) {
+	switch c := call.(type) {
 	case *ast.CallExpr, *ast.MethodCall:
 		// 有效的函数调用
+	case *ast.StaticAccess:
+		// 静态方法调用 Class::method()
+		if _, ok := c.Member.(*ast.CallExpr); !ok {
+			p.error("go statement requires a function call")
+		}
 	default:
 		p.error("go statement requires a function call")
 	}
@@ -108,6 +108,20 @@ func TestParseExpressions(t *testing.T) {
 	}
 }
 
+func TestParseExpression(t *testing.T) {
+	expr, errs := ParseExpression(`$p->x + 2 * $y;`, "<eval>")
+	if len(errs) > 0 {
+		t.Fatalf("parser error: %v", errs[0])
+	}
+	if _, ok := expr.(*ast.BinaryExpr); !ok {
+		t.Fatalf("expected BinaryExpr, got %T", expr)
+	}
+
+	if _, errs := ParseExpression(`$a + 1 $b`, "<eval>"); len(errs) == 0 {
+		t.Fatal("expected error for trailing tokens")
+	}
+}
+
 func TestParseIfStatement(t *testing.T) {
 	input := `
 	if ($a > 0) {
@@ -612,6 +626,46 @@ func TestParseTryCatch(t *testing.T) {
 	}
 }
 
+func TestParseUsingStmt(t *testing.T) {
+	input := `
+	class main {
+		public static function main(): void {
+			using (Stream $in = open("a"), $out := open("b")) {
+				copy($in, $out);
+			}
+		}
+	}
+	`
+
+	p := New(input, "test.nova")
+	file := p.Parse()
+
+	if p.HasErrors() {
+		for _, err := range p.Errors() {
+			t.Errorf("parser error: %v", err)
+		}
+		return
+	}
+
+	method := file.Declarations[0].(*ast.ClassDecl).Methods[0]
+	usingStmt, ok := method.Body.Statements[0].(*ast.UsingStmt)
+	if !ok {
+		t.Fatalf("expected UsingStmt, got %T", method.Body.Statements[0])
+	}
+	if len(usingStmt.Resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(usingStmt.Resources))
+	}
+	if usingStmt.Resources[0].Type == nil || usingStmt.Resources[0].Name.Name != "in" {
+		t.Errorf("expected typed resource $in, got %s", usingStmt.Resources[0].String())
+	}
+	if usingStmt.Resources[1].Type != nil || usingStmt.Resources[1].Name.Name != "out" {
+		t.Errorf("expected untyped resource $out, got %s", usingStmt.Resources[1].String())
+	}
+	if len(usingStmt.Body.Statements) != 1 {
+		t.Errorf("expected 1 body statement, got %d", len(usingStmt.Body.Statements))
+	}
+}
+
 func TestParseNamespaceAndUse(t *testing.T) {
 	input := `
 	namespace company.project
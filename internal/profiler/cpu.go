@@ -76,6 +76,7 @@ type CPUProfile struct {
 	EndTime     time.Time
 	Duration    time.Duration
 	SampleCount int64
+	Samples     []CPUSample // 采样 (按时间顺序，栈从最外层到最内层)
 	Functions   []*FunctionStats
 	TopFuncs    []*FunctionStats // 按时间排序的前 N 个函数
 	CallGraph   *CallGraph
@@ -273,6 +274,7 @@ func (p *CPUProfiler) GetProfile() *CPUProfile {
 	
 	profile := &CPUProfile{
 		SampleCount: p.sampleCount,
+		Samples:     append([]CPUSample(nil), p.samples...),
 		Functions:   make([]*FunctionStats, 0, len(p.funcStats)),
 	}
 	
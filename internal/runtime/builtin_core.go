@@ -57,6 +57,10 @@ func builtinTypeof(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewString("unknown")
 	case bytecode.ValFunc, bytecode.ValClosure:
 		return bytecode.NewString("function")
+	case bytecode.ValWeakRef:
+		return bytecode.NewString("WeakRef")
+	case bytecode.ValWeakMap:
+		return bytecode.NewString("WeakMap")
 	default:
 		return bytecode.NewString("unknown")
 	}
@@ -110,14 +110,14 @@ func annotationsToArray(annotations []*bytecode.Annotation) bytecode.Value {
 	result := make([]bytecode.Value, len(annotations))
 	for i, ann := range annotations {
 		// 每个注解转换为 map: {name: "...", args: {...}}
-		annMap := make(map[bytecode.Value]bytecode.Value)
-		annMap[bytecode.NewString("name")] = bytecode.NewString(ann.Name)
+		annMap := make(map[bytecode.Key]bytecode.Value)
+		annMap[bytecode.MapKey(bytecode.NewString("name"))] = bytecode.NewString(ann.Name)
 		// args 现在是 map[string]Value 格式
-		argsMap := make(map[bytecode.Value]bytecode.Value)
+		argsMap := make(map[bytecode.Key]bytecode.Value)
 		for key, val := range ann.Args {
-			argsMap[bytecode.NewString(key)] = val
+			argsMap[bytecode.MapKey(bytecode.NewString(key))] = val
 		}
-		annMap[bytecode.NewString("args")] = bytecode.NewMap(argsMap)
+		annMap[bytecode.MapKey(bytecode.NewString("args"))] = bytecode.NewMap(argsMap)
 		result[i] = bytecode.NewMap(annMap)
 	}
 
@@ -203,14 +203,9 @@ func (r *Runtime) reflectNewInstance(args []bytecode.Value) bytecode.Value {
 		return bytecode.NullValue
 	}
 
-	// 创建对象实例
+	// 创建对象实例 (属性按继承链初始化为默认值)
 	obj := bytecode.NewObjectInstance(class)
 
-	// 初始化属性默认值
-	for propName, defaultVal := range class.Properties {
-		obj.Fields[propName] = defaultVal
-	}
-
 	return bytecode.NewObject(obj)
 }
 
@@ -0,0 +1,310 @@
+package runtime
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// 确定性运行与回放
+// ============================================================================
+//
+// 脚本能观察到的非确定性输入有三类：时间 (native_time_now*、不带参数的
+// native_time_year 等)、随机数 (native_crypto_random_*) 和协程的执行顺序
+// (定时器到期的先后和 select 在多个就绪分支中的选择)。Options.Determinism
+// 为它们指定来源：
+//   - Seeded：虚拟时钟从固定的起点开始，只被 sleep (native_time_sleep、协程定时器)
+//     推进；随机数和调度顺序由种子决定。同一种子的两次运行结果相同
+//   - Record：使用真实的时间和随机数，把每个非确定性输入按顺序写入记录
+//   - Replay：按顺序读出记录中的输入，重现被记录的运行。程序读取输入的顺序与
+//     记录不一致，或运行结束时仍有未读出的记录 (回放偏离) 时，运行结束后返回 *ReplayError
+//
+// 密钥生成和签名的随机数仍来自系统，Go 的 crypto 包不允许用确定的随机源代替。
+// 一个 Determinism 只能供一个运行时使用。
+
+// Determinism 时间、随机数和协程调度顺序的来源，由 Seeded、Record 或 Replay 创建
+type Determinism interface {
+	now() time.Time
+	sleep(d time.Duration)
+	read(p []byte)
+	intn(n int) int
+	virtual() bool // 虚拟时钟：sleep 不等待真实时间
+	shuffle() bool // 就绪协程按 intn 选择的顺序运行
+	finish() error // 运行结束：刷新记录或报告回放偏离
+}
+
+// SeededEpoch 确定性运行中虚拟时钟的起点 (2000-01-01T00:00:00Z)
+var SeededEpoch = time.Unix(946684800, 0)
+
+// applyDeterminism 把时间和调度器的随机源换成 d
+func (r *Runtime) applyDeterminism(d Determinism) {
+	r.determinism = d
+	r.vm.SetClock(d.now, d.sleep, d.virtual())
+	r.vm.SetRandom(d.intn, d.shuffle())
+}
+
+// now 返回脚本看到的当前时间
+func (r *Runtime) now() time.Time {
+	if r.determinism != nil {
+		return r.determinism.now()
+	}
+	return time.Now()
+}
+
+// readRandom 用随机字节填满 p
+func (r *Runtime) readRandom(p []byte) error {
+	if r.determinism != nil {
+		r.determinism.read(p)
+		return nil
+	}
+	_, err := rand.Read(p)
+	return err
+}
+
+// randomReader 随机数来源 (用于 rand.Int 等需要 io.Reader 的函数)
+type randomReader struct {
+	r *Runtime
+}
+
+func (rr randomReader) Read(p []byte) (int, error) {
+	if err := rr.r.readRandom(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finishDeterminism 运行结束时刷新记录，回放偏离时返回 *ReplayError
+func (r *Runtime) finishDeterminism() error {
+	if r.determinism == nil {
+		return nil
+	}
+	return r.determinism.finish()
+}
+
+// ============================================================================
+// 种子
+// ============================================================================
+
+// seeded 虚拟时钟和由种子决定的随机数
+type seeded struct {
+	clock    time.Time
+	bytes    *mathrand.ChaCha8 // 脚本读取的随机数
+	schedule *mathrand.Rand    // 调度顺序和 select 的选择
+}
+
+// Seeded 返回确定性运行的来源：虚拟时钟从 SeededEpoch 开始，随机数和调度顺序由 seed 决定
+func Seeded(seed int64) Determinism {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], uint64(seed))
+	return &seeded{
+		clock:    SeededEpoch,
+		bytes:    mathrand.NewChaCha8(key),
+		schedule: mathrand.New(mathrand.NewPCG(uint64(seed), 0x5eed)),
+	}
+}
+
+func (s *seeded) now() time.Time        { return s.clock }
+func (s *seeded) sleep(d time.Duration) { s.clock = s.clock.Add(max(d, 0)) }
+func (s *seeded) read(p []byte)         { s.bytes.Read(p) }
+func (s *seeded) intn(n int) int        { return s.schedule.IntN(n) }
+func (s *seeded) virtual() bool         { return true }
+func (s *seeded) shuffle() bool         { return true }
+func (s *seeded) finish() error         { return nil }
+
+// ============================================================================
+// 记录与回放
+// ============================================================================
+//
+// 记录是文本文件：首行为 "sola-replay 1"，之后每行一个输入：
+//   time <Unix 纳秒>
+//   bytes <十六进制>
+//   intn <n> <结果>
+
+// recordHeader 记录文件的首行
+const recordHeader = "sola-replay 1"
+
+// ReplayError 回放偏离了记录
+type ReplayError struct {
+	Entry   int // 偏离处的记录序号 (从 1 开始)
+	Message string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replay diverged at entry %d: %s", e.Entry, e.Message)
+}
+
+// recorder 使用真实的时间和随机数并记录
+type recorder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// Record 返回记录真实运行的来源，输入按读取顺序写入 w
+func Record(w io.Writer) Determinism {
+	rec := &recorder{w: bufio.NewWriter(w)}
+	rec.printf("%s\n", recordHeader)
+	return rec
+}
+
+func (rec *recorder) printf(format string, args ...any) {
+	if rec.err == nil {
+		_, rec.err = fmt.Fprintf(rec.w, format, args...)
+	}
+}
+
+func (rec *recorder) now() time.Time {
+	t := time.Now()
+	rec.printf("time %d\n", t.UnixNano())
+	return t
+}
+
+func (rec *recorder) sleep(d time.Duration) { time.Sleep(d) }
+
+func (rec *recorder) read(p []byte) {
+	rand.Read(p)
+	rec.printf("bytes %s\n", hex.EncodeToString(p))
+}
+
+func (rec *recorder) intn(n int) int {
+	i := mathrand.IntN(n)
+	rec.printf("intn %d %d\n", n, i)
+	return i
+}
+
+func (rec *recorder) virtual() bool { return false }
+func (rec *recorder) shuffle() bool { return false }
+
+func (rec *recorder) finish() error {
+	if rec.err == nil {
+		rec.err = rec.w.Flush()
+	}
+	return rec.err
+}
+
+// replayEntry 记录中的一个输入
+type replayEntry struct {
+	kind  string
+	value int64  // time 的纳秒数或 intn 的结果
+	n     int    // intn 的 n
+	bytes []byte // bytes 的内容
+}
+
+// replayer 按顺序读出记录的输入
+// 偏离后不再读取记录：时钟停在最后读出的时间，随机数为零
+type replayer struct {
+	entries  []replayEntry
+	next     int
+	clock    time.Time
+	diverged *ReplayError
+}
+
+// Replay 读取 Record 写下的记录，返回回放它的来源
+func Replay(rd io.Reader) (Determinism, error) {
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(nil, 1<<26)
+	if !sc.Scan() || sc.Text() != recordHeader {
+		return nil, errors.New("not a replay recording")
+	}
+	rep := &replayer{clock: SeededEpoch}
+	for line := 2; sc.Scan(); line++ {
+		e, err := parseReplayEntry(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("replay recording line %d: %v", line, err)
+		}
+		rep.entries = append(rep.entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// parseReplayEntry 解析记录中的一行
+func parseReplayEntry(line string) (replayEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return replayEntry{}, errors.New("empty entry")
+	}
+	e := replayEntry{kind: fields[0]}
+	var err error
+	switch {
+	case e.kind == "time" && len(fields) == 2:
+		e.value, err = strconv.ParseInt(fields[1], 10, 64)
+	case e.kind == "bytes" && len(fields) == 2:
+		e.bytes, err = hex.DecodeString(fields[1])
+	case e.kind == "bytes" && len(fields) == 1:
+		e.bytes = []byte{}
+	case e.kind == "intn" && len(fields) == 3:
+		if e.n, err = strconv.Atoi(fields[1]); err == nil {
+			e.value, err = strconv.ParseInt(fields[2], 10, 64)
+		}
+	default:
+		return e, fmt.Errorf("malformed entry %q", line)
+	}
+	return e, err
+}
+
+// take 取出下一个输入，种类或形状与记录不一致时标记偏离
+func (rep *replayer) take(kind string, match func(e *replayEntry) bool) *replayEntry {
+	if rep.diverged != nil {
+		return nil
+	}
+	if rep.next >= len(rep.entries) {
+		rep.diverged = &ReplayError{Entry: rep.next + 1, Message: "recording exhausted, program read " + kind}
+		return nil
+	}
+	e := &rep.entries[rep.next]
+	if e.kind != kind || !match(e) {
+		rep.diverged = &ReplayError{Entry: rep.next + 1, Message: fmt.Sprintf("recorded %s, program read %s", e.kind, kind)}
+		return nil
+	}
+	rep.next++
+	return e
+}
+
+func (rep *replayer) now() time.Time {
+	if e := rep.take("time", func(*replayEntry) bool { return true }); e != nil {
+		rep.clock = time.Unix(0, e.value)
+	}
+	return rep.clock
+}
+
+func (rep *replayer) sleep(time.Duration) {}
+
+func (rep *replayer) read(p []byte) {
+	if e := rep.take("bytes", func(e *replayEntry) bool { return len(e.bytes) == len(p) }); e != nil {
+		copy(p, e.bytes)
+		return
+	}
+	clear(p)
+}
+
+func (rep *replayer) intn(n int) int {
+	if e := rep.take("intn", func(e *replayEntry) bool { return e.n == n && e.value >= 0 && e.value < int64(n) }); e != nil {
+		return int(e.value)
+	}
+	return 0
+}
+
+func (rep *replayer) virtual() bool { return true }
+func (rep *replayer) shuffle() bool { return false }
+
+// finish 报告回放偏离；程序读取的输入少于记录时也算偏离
+func (rep *replayer) finish() error {
+	if rep.diverged != nil {
+		return rep.diverged
+	}
+	if left := len(rep.entries) - rep.next; left > 0 {
+		return &ReplayError{Entry: rep.next + 1, Message: fmt.Sprintf("program finished with %d recorded inputs unread, next is %s", left, rep.entries[rep.next].kind)}
+	}
+	return nil
+}
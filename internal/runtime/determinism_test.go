@@ -0,0 +1,148 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// detSource 读取时间、随机数并休眠的脚本
+// 放在 sola.* 命名空间中以便直接调用原生函数 (标准库的 Random 类包装了同样的函数)
+const detSource = `
+namespace sola.testing
+
+class Det {
+    public static function run(int $sleepMs): string {
+        $start := native_time_now_ms();
+        $again := native_time_now_ms();
+        native_time_sleep($sleepMs);
+        $elapsed := native_time_now_ms() - $start;
+        $out := "start=" + ($start as string) + " still=" + (($again - $start) as string) + " elapsed=" + ($elapsed as string);
+        for (int $i = 0; $i < 5; $i++) {
+            $out = $out + " " + (native_crypto_random_int(0, 1000000) as string);
+        }
+        return $out + " " + native_crypto_random_hex(8);
+    }
+}
+`
+
+// runDet 以 d 为来源运行 Det::run，返回结果和运行结束时的错误 (回放偏离等)
+func runDet(t *testing.T, d Determinism, sleepMs int64) (string, error) {
+	t.Helper()
+	r, classes := loadTest(t, Options{Determinism: d}, "Det.sola", detSource)
+	result, err := r.VM().CallStatic(classes["Det"], "run", []bytecode.Value{bytecode.NewInt(sleepMs)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.AsString(), r.finishDeterminism()
+}
+
+func TestSeededRunIsReproducible(t *testing.T) {
+	began := time.Now()
+	first, err := runDet(t, Seeded(42), 60_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 虚拟时钟从 SeededEpoch 开始，只被 sleep 推进，sleep 不等待真实时间
+	prefix := fmt.Sprintf("start=%d still=0 elapsed=60000 ", SeededEpoch.UnixMilli())
+	if !strings.HasPrefix(first, prefix) {
+		t.Fatalf("seeded run = %q, want prefix %q", first, prefix)
+	}
+	if waited := time.Since(began); waited > 10*time.Second {
+		t.Fatalf("seeded sleep waited %v of real time", waited)
+	}
+
+	second, err := runDet(t, Seeded(42), 60_000)
+	if err != nil || second != first {
+		t.Fatalf("second run = %q, %v; want %q", second, err, first)
+	}
+	other, err := runDet(t, Seeded(7), 60_000)
+	if err != nil || other == first {
+		t.Fatalf("run with another seed = %q, %v", other, err)
+	}
+}
+
+func TestReplayReproducesRecording(t *testing.T) {
+	var recording bytes.Buffer
+	recorded, err := runDet(t, Record(&recording), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(recording.String(), recordHeader+"\n") {
+		t.Fatalf("recording = %q", recording.String())
+	}
+
+	rep, err := Replay(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := runDet(t, rep, 5)
+	if err != nil || replayed != recorded {
+		t.Fatalf("replay = %q, %v; want %q", replayed, err, recorded)
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	var recording bytes.Buffer
+	if _, err := runDet(t, Record(&recording), 0); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(recording.String(), "\n"), "\n")
+	entries := len(lines) - 1
+
+	tests := []struct {
+		name  string
+		edit  func([]string) []string
+		entry int // 偏离处的记录序号
+	}{
+		{"truncated", func(l []string) []string { return l[:len(l)-2] }, entries - 1},
+		{"time replaced by bytes", func(l []string) []string {
+			l = append([]string(nil), l...)
+			l[1] = "bytes 00\n"
+			return l
+		}, 1},
+		{"random bytes resized", func(l []string) []string {
+			l = append([]string(nil), l...)
+			for i, line := range l {
+				if strings.HasPrefix(line, "bytes ") {
+					l[i] = "bytes 00\n"
+					return l
+				}
+			}
+			t.Fatal("recording has no bytes entry")
+			return nil
+		}, 0},
+		// 程序读取的输入少于记录：多出的记录在运行结束时报告
+		{"extra entries", func(l []string) []string {
+			return append(append([]string(nil), l...), "\ntime 1", "\nintn 10 3")
+		}, entries + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep, err := Replay(strings.NewReader(strings.Join(tt.edit(lines), "")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = runDet(t, rep, 0)
+			var re *ReplayError
+			if !errors.As(err, &re) {
+				t.Fatalf("err = %v, want *ReplayError", err)
+			}
+			if tt.entry > 0 && re.Entry != tt.entry {
+				t.Fatalf("diverged at entry %d, want %d (%v)", re.Entry, tt.entry, re)
+			}
+		})
+	}
+
+	if _, err := Replay(strings.NewReader(recordHeader + "\ntime soon\n")); err == nil {
+		t.Fatal("malformed recording was accepted")
+	}
+	if _, err := Replay(strings.NewReader("time 1\n")); err == nil {
+		t.Fatal("recording without header was accepted")
+	}
+}
@@ -0,0 +1,203 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tangzhangming/nova/internal/ast"
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/compiler"
+	"github.com/tangzhangming/nova/internal/i18n"
+	"github.com/tangzhangming/nova/internal/parser"
+	"github.com/tangzhangming/nova/internal/token"
+	"github.com/tangzhangming/nova/internal/vm"
+)
+
+// ============================================================================
+// 结构化的编译错误
+// ============================================================================
+//
+// 解析和编译失败时返回 *CompileError，其中按出现顺序记录每条诊断。
+// 命令行入口 (Run / CompileToCompiledFile / RunREPL) 通过 reportCompileError
+// 逐条输出诊断；嵌入方 (Load) 直接拿到结构化的错误，运行时不输出任何内容。
+
+// Diagnostic 单条解析或编译错误
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// CompileError 源文件解析或编译失败
+type CompileError struct {
+	File        string       // 出错的源文件
+	Import      string       // 出错的是依赖时为其导入路径
+	Parse       bool         // 是否在解析阶段失败
+	Diagnostics []Diagnostic // 按出现顺序排列的错误
+}
+
+func (e *CompileError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.summary())
+	for _, d := range e.Diagnostics {
+		sb.WriteString("\n")
+		sb.WriteString(d.Error())
+	}
+	return sb.String()
+}
+
+// summary 返回不含诊断的概要 (与命令行输出一致)
+func (e *CompileError) summary() string {
+	switch {
+	case e.Parse && e.Import != "":
+		return i18n.T(i18n.ErrParseFailedFor, e.Import)
+	case e.Parse:
+		return i18n.T(i18n.ErrParseFailed)
+	case e.Import != "":
+		return i18n.T(i18n.ErrCompileFailedFor, e.Import)
+	default:
+		return i18n.T(i18n.ErrCompileFailed)
+	}
+}
+
+// reportCompileError 输出编译错误的每条诊断，返回概要错误
+// 其他错误原样返回
+func reportCompileError(err error) error {
+	ce, ok := err.(*CompileError)
+	if !ok {
+		return err
+	}
+	key := i18n.ErrCompileError
+	if ce.Parse {
+		key = i18n.ErrParseError
+	}
+	for _, d := range ce.Diagnostics {
+		fmt.Println(i18n.T(key, d))
+	}
+	return errors.New(ce.summary())
+}
+
+// parseSource 解析源代码，失败时返回 *CompileError
+func parseSource(source, filename string) (*ast.File, error) {
+	p := parser.New(source, filename)
+	file := p.Parse()
+	if !p.HasErrors() {
+		return file, nil
+	}
+	ce := &CompileError{File: filename, Parse: true}
+	for _, e := range p.Errors() {
+		ce.Diagnostics = append(ce.Diagnostics, Diagnostic{Pos: e.Pos, Message: e.Message})
+	}
+	return nil, ce
+}
+
+// compileFile 使用共享符号表编译已解析的文件，失败时返回 *CompileError
+// importPath 为依赖的导入路径，编译入口文件时为空
+func (r *Runtime) compileFile(file *ast.File, importPath string) (*compiler.Compiler, *bytecode.Function, error) {
+	c := compiler.NewWithSymbolTable(r.symbolTable)
+	fn, errs := c.Compile(file)
+	if len(errs) == 0 {
+		return c, fn, nil
+	}
+	ce := &CompileError{File: file.Filename, Import: importPath}
+	for _, e := range errs {
+		ce.Diagnostics = append(ce.Diagnostics, Diagnostic{Pos: e.Pos, Message: e.Message})
+	}
+	return nil, nil, ce
+}
+
+// ============================================================================
+// 嵌入支持
+// ============================================================================
+
+// Load 编译源文件并注册其中的类、枚举及其依赖，不执行入口方法
+// 解析或编译失败时返回 *CompileError 且不输出任何内容；成功时返回该文件定义的类 (短名 -> 类)
+func (r *Runtime) Load(source, filename string) (map[string]*bytecode.Class, error) {
+	if r.loader == nil {
+		if err := r.newLoader(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := parseSource(source, filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, use := range file.Uses {
+		if err := r.loadDependency(use.Path); err != nil {
+			if _, ok := err.(*CompileError); ok {
+				return nil, err
+			}
+			return nil, errors.New(i18n.T(i18n.ErrLoadFailed, use.Path, err))
+		}
+	}
+
+	c, _, err := r.compileFile(file, "")
+	if err != nil {
+		return nil, err
+	}
+	r.defineCompiled(c, file)
+	r.linkClasses()
+	r.registerBuiltinsToVM()
+	return c.Classes(), nil
+}
+
+// RegisterBuiltin 注册 (或替换) 内置函数
+// 编译器按符号表检查调用，调用方需要同时在 SymbolTable 中登记函数签名
+// 宿主注册的函数随 CompileImage 进入映像，由映像创建的每个运行时都会注册，可能被并发调用
+func (r *Runtime) RegisterBuiltin(name string, fn BuiltinFunc) {
+	r.builtins[name] = fn
+	r.natives[name] = fn
+	r.vm.RegisterBuiltin(name, createBuiltinWrapper(fn))
+}
+
+// DefineClass 注册由宿主构造的类，使其可以被后续加载的源文件引用
+// 之后由 CompileImage 编译的映像包含该类
+func (r *Runtime) DefineClass(class *bytecode.Class) {
+	r.classes[class.FullName()] = class
+	r.classes[class.Name] = class
+	r.vm.DefineClass(class)
+}
+
+// GetClass 按完整名称或短名查找已注册的类
+func (r *Runtime) GetClass(name string) *bytecode.Class {
+	return r.classes[name]
+}
+
+// VM 返回运行时使用的虚拟机
+func (r *Runtime) VM() *vm.VM {
+	return r.vm
+}
+
+// SymbolTable 返回编译时共享的符号表
+func (r *Runtime) SymbolTable() *compiler.SymbolTable {
+	return r.symbolTable
+}
+
+// CompileExpression 把表达式编译为不注册到运行时的函数
+// 函数由 vm.Eval 以 null 为 $this 调用，返回数组 [表达式的值]；
+// 解析或编译失败时返回 *CompileError，列号相对于表达式
+func (r *Runtime) CompileExpression(expr string) (*bytecode.Function, error) {
+	const filename = "<eval>"
+	e, errs := parser.ParseExpression(expr, filename)
+	if len(errs) > 0 {
+		ce := &CompileError{File: filename, Parse: true}
+		for _, err := range errs {
+			ce.Diagnostics = append(ce.Diagnostics, Diagnostic{Pos: err.Pos, Message: err.Message})
+		}
+		return nil, ce
+	}
+	fn, cerrs := compiler.NewWithSymbolTable(r.symbolTable).CompileEval(e, "", nil)
+	if len(cerrs) > 0 {
+		ce := &CompileError{File: filename}
+		for _, err := range cerrs {
+			ce.Diagnostics = append(ce.Diagnostics, Diagnostic{Pos: err.Pos, Message: err.Message})
+		}
+		return nil, ce
+	}
+	return fn, nil
+}
@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"errors"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/i18n"
+	"github.com/tangzhangming/nova/internal/vm"
+)
+
+// ============================================================================
+// 程序映像与运行时池
+// ============================================================================
+//
+// Run 每次都重新编译入口文件及其依赖。需要并发执行同一程序的宿主 (如 HTTP 服务)
+// 用 CompileImage 编译一次，得到不可变的 Image，再通过 Pool 借出轻量的运行时：
+// 每个运行时有自己的 VM (栈、全局变量、类的静态存储、协程)、内置函数和执行限制，
+// 类、方法、函数和枚举的定义在所有运行时之间共享。
+//
+// 共享的定义在编译时填好执行缓存 (见 vm.PrepareClass)，由映像创建的 VM 禁用
+// quickening，执行期间不再写入共享的字节码。归还运行时时 Reset 其 VM，
+// 下一个请求看不到上一个请求留下的状态。
+
+// Image 编译好的程序：入口文件及其依赖中的类和枚举，创建后不再修改
+// Image 可以被多个 goroutine 中的运行时同时使用
+type Image struct {
+	filename string
+	classes  map[string]*bytecode.Class // 完整名称和短名 -> 类 (含宿主注册的类)
+	enums    map[string]*bytecode.Enum
+	natives  map[string]BuiltinFunc // 宿主注册的内置函数
+	entry    *bytecode.Class        // 含静态 main() 方法的入口类 (没有时为 nil)
+}
+
+// CompileImage 编译源文件及其依赖为映像，解析或编译失败时返回 *CompileError
+// 入口类是与文件同名且含有静态 main() 方法的类，没有入口类的映像只能调用其中的方法
+func CompileImage(source, filename string, opts Options) (*Image, error) {
+	return NewWithOptions(opts).CompileImage(source, filename)
+}
+
+// CompileImage 在运行时中编译源文件及其依赖为映像
+// 此前通过 RegisterBuiltin 和 DefineClass 注册的函数和类一并放入映像，
+// 源文件可以调用它们；编译后不应再使用该运行时执行脚本
+func (r *Runtime) CompileImage(source, filename string) (*Image, error) {
+	defined, err := r.Load(source, filename)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{filename: filename, classes: r.classes, enums: r.enums, natives: maps.Clone(r.natives)}
+	if class := defined[getClassNameFromFilename(filename)]; class != nil && r.findMainMethod(class) != nil {
+		img.entry = class
+	}
+	for _, class := range img.classes {
+		vm.PrepareClass(class)
+	}
+	return img, nil
+}
+
+// Filename 返回入口文件名
+func (img *Image) Filename() string {
+	return img.filename
+}
+
+// Entry 返回入口类 (没有时为 nil)
+func (img *Image) Entry() *bytecode.Class {
+	return img.entry
+}
+
+// Class 按完整名称或短名查找映像中的类
+func (img *Image) Class(name string) *bytecode.Class {
+	return img.classes[name]
+}
+
+// NewFromImage 创建执行映像的运行时
+// 运行时拥有独立的 VM 和内置函数，映像中的类、枚举和宿主函数直接注册而不重新编译
+func NewFromImage(img *Image, opts Options) *Runtime {
+	r := NewWithOptions(opts)
+	r.image = img
+	r.classes = maps.Clone(img.classes)
+	r.enums = maps.Clone(img.enums)
+	r.vm.SetQuickening(false)
+	for _, class := range img.classes {
+		r.vm.DefineClass(class)
+	}
+	for _, enum := range img.enums {
+		r.vm.DefineEnum(enum)
+	}
+	for name, fn := range img.natives {
+		r.builtins[name] = fn
+		r.natives[name] = fn
+	}
+	r.registerBuiltinsToVM()
+	return r
+}
+
+// Reset 丢弃上一次执行留下的状态 (全局变量、类的静态存储、协程、栈)，
+// 已注册的类和内置函数保持不变
+func (r *Runtime) Reset() {
+	r.vm.Reset()
+}
+
+// ============================================================================
+// 运行时池
+// ============================================================================
+
+// Pool 执行同一映像的运行时池，可以在多个 goroutine 中并发使用
+// 借出的运行时同一时刻只能在一个 goroutine 中使用
+type Pool struct {
+	image *Image
+	opts  Options
+	idle  sync.Pool
+}
+
+// NewPool 创建运行时池，每个运行时按 opts 设置执行限制和沙箱
+func NewPool(img *Image, opts Options) *Pool {
+	p := &Pool{image: img, opts: opts}
+	p.idle.New = func() any {
+		return NewFromImage(img, opts)
+	}
+	return p
+}
+
+// Image 返回池中运行时执行的映像
+func (p *Pool) Image() *Image {
+	return p.image
+}
+
+// Get 借出一个运行时，没有空闲的运行时时新建
+func (p *Pool) Get() *Runtime {
+	return p.idle.Get().(*Runtime)
+}
+
+// Put 重置运行时并归还到池中，执行限制恢复为池的设置
+// 不是由该池映像创建的运行时被丢弃
+func (p *Pool) Put(r *Runtime) {
+	if r == nil || r.image != p.image {
+		return
+	}
+	r.Reset()
+	r.vm.SetLimits(p.opts.Limits)
+	p.idle.Put(r)
+}
+
+// ============================================================================
+// 堆快照映像文件
+// ============================================================================
+//
+// sola build --image 在构建时加载程序并按类名顺序执行所有类的静态初始化器，
+// 把类 (已链接父类和虚表)、枚举和初始化完毕的静态变量 (连同其引用的对象)
+// 保存为映像文件。sola run app.image 解码映像后直接恢复这些状态并调用入口类的
+// main()，不再解析、编译或执行静态初始化器。
+//
+// 构建时禁用 quickening，映像中只有通用指令。映像文件头记录了构建它的 VM 版本，
+// 版本不一致的映像被拒绝，需要重新构建。
+
+// BuildImageFile 编译源文件及其依赖，初始化所有类后编码为映像文件
+// 静态初始化器抛出异常、或静态变量引用了不能保存的值 (闭包、通道、宿主对象等) 时返回错误
+func BuildImageFile(source, filename string, opts Options) ([]byte, error) {
+	r := NewWithOptions(opts)
+	r.vm.SetQuickening(false)
+	defined, err := r.Load(source, filename)
+	if err != nil {
+		return nil, err
+	}
+	entry := defined[getClassNameFromFilename(filename)]
+	if entry == nil || r.findMainMethod(entry) == nil {
+		return nil, errors.New(i18n.T(i18n.ErrMainMethodRequired))
+	}
+
+	names := make([]string, 0, len(r.classes))
+	for name := range r.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	statics := make(map[*bytecode.Class]map[string]bytecode.Value)
+	for _, name := range names {
+		class := r.classes[name]
+		if err := r.vm.InitClass(class); err != nil {
+			return nil, errors.New(i18n.T(i18n.ErrImageInitFailed, class.FullName(), err))
+		}
+		for c := class; c != nil; c = c.Parent {
+			statics[c] = r.vm.Statics(c)
+		}
+	}
+
+	data, err := bytecode.EncodeImage(&bytecode.ImageFile{
+		SourceFile: filename,
+		Classes:    r.classes,
+		Enums:      r.enums,
+		Entry:      entry,
+		Statics:    statics,
+	})
+	if err != nil {
+		return nil, errors.New(i18n.T(i18n.ErrImageStaticFailed, err))
+	}
+	return data, nil
+}
+
+// RunImageFile 运行映像文件：恢复类、枚举和静态变量后调用入口类的 main()
+// 映像由其他版本的 VM 构建或已损坏时返回 *bytecode.FormatError
+func (r *Runtime) RunImageFile(data []byte) error {
+	img, err := bytecode.DecodeImage(data)
+	if err != nil {
+		return err
+	}
+	for name, class := range img.Classes {
+		r.classes[name] = class
+		r.vm.DefineClass(class)
+	}
+	for name, enum := range img.Enums {
+		r.enums[name] = enum
+		r.vm.DefineEnum(enum)
+	}
+	for class, values := range img.Statics {
+		r.vm.RestoreStatics(class, values)
+	}
+	r.registerBuiltinsToVM()
+
+	if img.Entry == nil || r.findMainMethod(img.Entry) == nil {
+		return errors.New(i18n.T(i18n.ErrMainMethodRequired))
+	}
+	return r.callMain(img.Entry)
+}
@@ -1928,7 +1928,7 @@ func nativeCryptoArgon2i(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeCryptoRandomBytes 生成加密安全随机字节
-func nativeCryptoRandomBytes(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeCryptoRandomBytes(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewBytes(nil)
 	}
@@ -1938,7 +1938,7 @@ func nativeCryptoRandomBytes(args []bytecode.Value) bytecode.Value {
 	}
 
 	bytes := make([]byte, n)
-	if _, err := rand.Read(bytes); err != nil {
+	if err := r.readRandom(bytes); err != nil {
 		return bytecode.NewBytes(nil)
 	}
 
@@ -1946,7 +1946,7 @@ func nativeCryptoRandomBytes(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeCryptoRandomInt 生成加密安全随机整数 [min, max)
-func nativeCryptoRandomInt(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeCryptoRandomInt(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(0)
 	}
@@ -1961,7 +1961,7 @@ func nativeCryptoRandomInt(args []bytecode.Value) bytecode.Value {
 
 	// 生成 [0, max-min) 的随机数，然后加上 min
 	rangeVal := max - min
-	n, err := rand.Int(rand.Reader, big.NewInt(rangeVal))
+	n, err := rand.Int(randomReader{r}, big.NewInt(rangeVal))
 	if err != nil {
 		return bytecode.NewInt(0)
 	}
@@ -1970,7 +1970,7 @@ func nativeCryptoRandomInt(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeCryptoRandomHex 生成随机十六进制字符串
-func nativeCryptoRandomHex(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeCryptoRandomHex(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
@@ -1980,7 +1980,7 @@ func nativeCryptoRandomHex(args []bytecode.Value) bytecode.Value {
 	}
 
 	bytes := make([]byte, n)
-	if _, err := rand.Read(bytes); err != nil {
+	if err := r.readRandom(bytes); err != nil {
 		return bytecode.NewString("")
 	}
 
@@ -1988,9 +1988,9 @@ func nativeCryptoRandomHex(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeCryptoRandomUuid 生成UUID v4
-func nativeCryptoRandomUuid(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeCryptoRandomUuid(args []bytecode.Value) bytecode.Value {
 	uuid := make([]byte, 16)
-	if _, err := rand.Read(uuid); err != nil {
+	if err := r.readRandom(uuid); err != nil {
 		return bytecode.NewString("")
 	}
 
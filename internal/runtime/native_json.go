@@ -258,11 +258,12 @@ func objectToGo(obj *bytecode.Object) map[string]interface{} {
 		return result
 	}
 
-	for name, value := range obj.Fields {
+	obj.RangeFields(func(name string, value bytecode.Value) bool {
 		// 跳过私有属性（以_开头的视为私有，或检查类定义）
 		// 这里简单处理：所有字段都序列化
 		result[name] = solaValueToGo(value)
-	}
+		return true
+	})
 
 	return result
 }
@@ -277,7 +278,7 @@ func encodeObjectToJson(obj *bytecode.Object, namingStrategy int) map[string]int
 	// 获取类定义以读取注解
 	class := obj.Class
 
-	for fieldName, value := range obj.Fields {
+	obj.RangeFields(func(fieldName string, value bytecode.Value) bool {
 		jsonName := fieldName
 		omitEmpty := false
 		ignore := false
@@ -312,12 +313,12 @@ func encodeObjectToJson(obj *bytecode.Object, namingStrategy int) map[string]int
 
 		// 忽略字段
 		if ignore {
-			continue
+			return true
 		}
 
 		// 检查空值
 		if omitEmpty && isEmptyValue(value) {
-			continue
+			return true
 		}
 
 		// 应用命名策略（如果没有显式指定JsonProperty）
@@ -339,7 +340,8 @@ func encodeObjectToJson(obj *bytecode.Object, namingStrategy int) map[string]int
 		}
 
 		result[jsonName] = goValue
-	}
+		return true
+	})
 
 	return result
 }
@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	goruntime "runtime"
+	"sync"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/i18n"
+)
+
+// ============================================================================
+// 句柄表
+// ============================================================================
+//
+// 文件流、TCP 连接和监听器登记在打开它们的运行时的句柄表中，原生函数只在本运行时的
+// 表中查找句柄：池中的其他运行时和宿主打开的资源对脚本不可见。句柄是随机生成的
+// 正整数而不是递增的序号，脚本无法猜出其他句柄。
+
+// handleTable 一类原生句柄的表（线程安全，终结器在其他 goroutine 中关闭句柄）
+type handleTable[T any] struct {
+	mu      sync.RWMutex
+	entries map[int64]T
+}
+
+// add 登记资源，返回新的句柄
+func (t *handleTable[T]) add(v T) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[int64]T)
+	}
+	id := newHandleID()
+	for _, taken := t.entries[id]; taken; _, taken = t.entries[id] {
+		id = newHandleID()
+	}
+	t.entries[id] = v
+	return id
+}
+
+// get 查找句柄对应的资源
+func (t *handleTable[T]) get(id int64) (T, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	v, ok := t.entries[id]
+	return v, ok
+}
+
+// remove 注销句柄，返回它对应的资源
+func (t *handleTable[T]) remove(id int64) (T, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, ok := t.entries[id]
+	if ok {
+		delete(t.entries, id)
+	}
+	return v, ok
+}
+
+// newHandleID 生成随机句柄，取 53 位使句柄转换为浮点数时不丢失精度
+func newHandleID() int64 {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(err)
+		}
+		if id := int64(binary.LittleEndian.Uint64(b[:]) >> 11); id != 0 {
+			return id
+		}
+	}
+}
+
+// ============================================================================
+// 原生句柄泄漏检测
+// ============================================================================
+//
+// 持有原生句柄 (文件流、TCP 连接、监听器) 的标准库类在打开句柄后调用
+// native_resource_track($this, kind, handle) 登记句柄的所有者。所有者对象被 Go
+// 垃圾回收时句柄仍然打开，说明脚本既没有调用 close() 也没有使用 using 语句：
+// 终结器输出一条警告并关闭句柄。终结器何时运行由 Go 运行时决定，它只是安全网，
+// 不能代替显式关闭。
+
+// resourceClosers 按句柄类型关闭句柄，句柄仍然打开 (本次确实关闭了它) 时返回 true
+var resourceClosers = map[string]func(r *Runtime, id int64) bool{
+	"stream": func(r *Runtime, id int64) bool {
+		return r.nativeStreamClose([]bytecode.Value{bytecode.NewInt(id)}).AsBool()
+	},
+	"tcp": func(r *Runtime, id int64) bool {
+		return r.nativeTcpClose([]bytecode.Value{bytecode.NewInt(id)}).AsBool()
+	},
+	"tcp_listener": func(r *Runtime, id int64) bool {
+		return r.nativeTcpStopListen([]bytecode.Value{bytecode.NewInt(id)}).AsBool()
+	},
+}
+
+// nativeResourceTrack 登记原生句柄的所有者对象
+// 参数: owner, kind, handle；同一对象再次登记时替换之前登记的句柄
+func (r *Runtime) nativeResourceTrack(args []bytecode.Value) bytecode.Value {
+	if len(args) < 3 || !args[0].IsObject() {
+		return bytecode.NullValue
+	}
+	kind := args[1].AsString()
+	closer, ok := resourceClosers[kind]
+	if !ok {
+		return bytecode.NullValue
+	}
+	id := args[2].AsInt()
+	if id < 0 {
+		return bytecode.NullValue
+	}
+
+	log := r.leakLog
+	obj := args[0].AsObject()
+	goruntime.SetFinalizer(obj, nil)
+	goruntime.SetFinalizer(obj, func(*bytecode.Object) {
+		if closer(r, id) {
+			fmt.Fprintln(log, i18n.T(i18n.WarnResourceLeaked, kind, id))
+		}
+	})
+	return bytecode.NullValue
+}
+
+// leakWriter 返回句柄泄漏警告的输出位置
+func leakWriter(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stderr
+	}
+	return w
+}
@@ -0,0 +1,193 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// lockedBuffer 可以被终结器 goroutine 并发写入的缓冲区
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// loadTest 使用仓库中的标准库创建运行时并加载源代码
+func loadTest(t *testing.T, opts Options, filename, source string) (*Runtime, map[string]*bytecode.Class) {
+	t.Helper()
+	libDir, err := filepath.Abs(filepath.Join("..", "..", "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.LibDir = libDir
+	r := NewWithOptions(opts)
+	classes, err := r.Load(source, filename)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return r, classes
+}
+
+// openStreamID 返回运行时中打开 path 的流句柄，没有时返回 -1
+func openStreamID(r *Runtime, path string) int64 {
+	r.streams.mu.RLock()
+	defer r.streams.mu.RUnlock()
+	for id, s := range r.streams.entries {
+		if s.file != nil && s.file.Name() == path {
+			return id
+		}
+	}
+	return -1
+}
+
+func TestLeakedStreamIsClosedByFinalizer(t *testing.T) {
+	log := &lockedBuffer{}
+	r, classes := loadTest(t, Options{ResourceLeakLog: log}, "Leak.sola", `
+use sola.io.Stream;
+
+class Leak {
+    public static function open(string $path): void {
+        $s := new Stream($path, "w");
+        $s->write("leaked");
+    }
+}
+`)
+	path := filepath.Join(t.TempDir(), "leak.txt")
+	if _, err := r.VM().CallStatic(classes["Leak"], "open", []bytecode.Value{bytecode.NewString(path)}); err != nil {
+		t.Fatal(err)
+	}
+	id := openStreamID(r, path)
+	if id < 0 {
+		t.Fatal("stream was not opened")
+	}
+
+	// 对象已经不可达，终结器在 GC 之后异步运行
+	want := fmt.Sprintf("stream handle %d", id)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(log.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no leak warning for handle %d, log = %q", id, log.String())
+		}
+		goruntime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if openStreamID(r, path) >= 0 {
+		t.Fatal("leaked stream is still open")
+	}
+	// 终结器只警告一次，之后的关闭不再生效
+	if r.nativeStreamClose([]bytecode.Value{bytecode.NewInt(id)}).AsBool() {
+		t.Fatal("stream was closed twice")
+	}
+}
+
+func TestClosedStreamIsNotReported(t *testing.T) {
+	log := &lockedBuffer{}
+	r, classes := loadTest(t, Options{ResourceLeakLog: log}, "Closed.sola", `
+use sola.io.Stream;
+
+class Closed {
+    public static function open(string $path): void {
+        using (Stream $s = new Stream($path, "w")) {
+            $s->write("closed");
+        }
+    }
+}
+`)
+	path := filepath.Join(t.TempDir(), "closed.txt")
+	if _, err := r.VM().CallStatic(classes["Closed"], "open", []bytecode.Value{bytecode.NewString(path)}); err != nil {
+		t.Fatal(err)
+	}
+	if openStreamID(r, path) >= 0 {
+		t.Fatal("using did not close the stream")
+	}
+	for i := 0; i < 3; i++ {
+		goruntime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if log.String() != "" {
+		t.Fatalf("closed stream reported as leaked: %q", log.String())
+	}
+}
+
+func TestStreamHandlesAreIsolatedBetweenRuntimes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(path, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	libDir, err := filepath.Abs(filepath.Join("..", "..", "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{LibDir: libDir, Sandbox: &Sandbox{FileSystem: []FSRoot{{Path: dir, Read: true}}}}
+	img, err := CompileImage(`
+namespace sola.testing
+
+class Handles {
+    public static function open(string $path): int {
+        return native_stream_open($path, "r");
+    }
+    public static function read(int $handle): string {
+        return native_stream_read($handle, 100);
+    }
+}
+`, "Handles.sola", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewPool(img, opts)
+	a, b := pool.Get(), pool.Get()
+	class := img.Class("Handles")
+	call := func(r *Runtime, method string, arg bytecode.Value) bytecode.Value {
+		t.Helper()
+		v, err := r.VM().CallStatic(class, method, []bytecode.Value{arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	first := call(a, "open", bytecode.NewString(path)).AsInt()
+	second := call(a, "open", bytecode.NewString(path)).AsInt()
+	if first <= 0 || second <= 0 {
+		t.Fatalf("open = %d, %d", first, second)
+	}
+	// 句柄是随机的，不能由一个句柄推出下一个
+	if d := second - first; d >= -1 && d <= 1 {
+		t.Errorf("handles %d and %d are sequential", first, second)
+	}
+
+	// 另一个运行时看不到 A 打开的流，A 自己仍能读取
+	if got := call(b, "read", bytecode.NewInt(first)).AsString(); got != "" {
+		t.Fatalf("runtime B read %q from runtime A's stream", got)
+	}
+	if got := call(a, "read", bytecode.NewInt(first)).AsString(); got != "secret" {
+		t.Fatalf("runtime A read %q, want %q", got, "secret")
+	}
+	closed := b.nativeStreamClose([]bytecode.Value{bytecode.NewInt(second)}).AsBool()
+	if _, open := a.streams.get(second); closed || !open {
+		t.Fatal("runtime B closed runtime A's stream")
+	}
+	a.nativeStreamClose([]bytecode.Value{bytecode.NewInt(first)})
+	a.nativeStreamClose([]bytecode.Value{bytecode.NewInt(second)})
+}
@@ -5,7 +5,6 @@ import (
 	"io"
 	"os"
 	"strings"
-	"sync"
 
 	"github.com/tangzhangming/nova/internal/bytecode"
 )
@@ -20,12 +19,6 @@ type fileStream struct {
 	mode   string
 }
 
-var (
-	fileStreamPool   = make(map[int]*fileStream)
-	fileStreamNextID = 1
-	fileStreamMutex  sync.Mutex
-)
-
 // ============================================================================
 // Native 流操作函数
 // ============================================================================
@@ -61,17 +54,12 @@ func (r *Runtime) nativeStreamOpen(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 
-	fileStreamMutex.Lock()
-	id := fileStreamNextID
-	fileStreamNextID++
-	fileStreamPool[id] = &fileStream{
+	id := r.streams.add(&fileStream{
 		file:   f,
 		reader: bufio.NewReader(f),
 		mode:   mode,
-	}
-	fileStreamMutex.Unlock()
-
-	return bytecode.NewInt(int64(id))
+	})
+	return bytecode.NewInt(id)
 }
 
 // nativeStreamRead 读取指定长度
@@ -79,12 +67,10 @@ func (r *Runtime) nativeStreamRead(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewString("")
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 	length := int(args[1].AsInt())
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.NewString("")
 	}
@@ -102,11 +88,9 @@ func (r *Runtime) nativeStreamReadLine(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
 		return bytecode.NewString("")
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.NewString("")
 	}
@@ -123,12 +107,10 @@ func (r *Runtime) nativeStreamWrite(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 	content := args[1].AsString()
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.NewInt(-1)
 	}
@@ -145,13 +127,11 @@ func (r *Runtime) nativeStreamSeek(args []bytecode.Value) bytecode.Value {
 	if len(args) < 3 {
 		return bytecode.FalseValue
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 	offset := args[1].AsInt()
 	whence := int(args[2].AsInt())
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.FalseValue
 	}
@@ -170,11 +150,9 @@ func (r *Runtime) nativeStreamTell(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
 		return bytecode.NewInt(-1)
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.NewInt(-1)
 	}
@@ -191,11 +169,9 @@ func (r *Runtime) nativeStreamEof(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
 		return bytecode.TrueValue
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.TrueValue
 	}
@@ -215,11 +191,9 @@ func (r *Runtime) nativeStreamFlush(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
 		return bytecode.FalseValue
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.get(id)
 	if !ok || stream.file == nil {
 		return bytecode.FalseValue
 	}
@@ -233,14 +207,9 @@ func (r *Runtime) nativeStreamClose(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
 		return bytecode.FalseValue
 	}
-	id := int(args[0].AsInt())
+	id := args[0].AsInt()
 
-	fileStreamMutex.Lock()
-	stream, ok := fileStreamPool[id]
-	if ok {
-		delete(fileStreamPool, id)
-	}
-	fileStreamMutex.Unlock()
+	stream, ok := r.streams.remove(id)
 
 	if !ok || stream.file == nil {
 		return bytecode.FalseValue
@@ -5,7 +5,6 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
-	"sync"
 	"time"
 
 	"github.com/tangzhangming/nova/internal/bytecode"
@@ -23,12 +22,6 @@ type tcpConnection struct {
 	writeBufSize int
 }
 
-var (
-	tcpConnections = make(map[int64]*tcpConnection)
-	tcpConnMutex   sync.RWMutex
-	nextConnID     int64 = 1
-)
-
 // ============================================================================
 // TCP 监听器管理
 // ============================================================================
@@ -40,55 +33,33 @@ type tcpListener struct {
 	port     int
 }
 
-var (
-	tcpListeners     = make(map[int64]*tcpListener)
-	tcpListenerMutex sync.RWMutex
-	nextListenerID   int64 = 1
-)
-
-// getListener 获取监听器（线程安全）
-func getListener(listenerID int64) (*tcpListener, bool) {
-	tcpListenerMutex.RLock()
-	tl, ok := tcpListeners[listenerID]
-	tcpListenerMutex.RUnlock()
-	return tl, ok
+// getListener 获取运行时的监听器（线程安全）
+func (r *Runtime) getListener(listenerID int64) (*tcpListener, bool) {
+	return r.listeners.get(listenerID)
 }
 
 // registerListener 注册新监听器（线程安全）
-func registerListener(listener net.Listener, host string, port int, isTLS bool) int64 {
-	tcpListenerMutex.Lock()
-	listenerID := nextListenerID
-	nextListenerID++
-	tcpListeners[listenerID] = &tcpListener{
+func (r *Runtime) registerListener(listener net.Listener, host string, port int, isTLS bool) int64 {
+	return r.listeners.add(&tcpListener{
 		listener: listener,
 		isTLS:    isTLS,
 		host:     host,
 		port:     port,
-	}
-	tcpListenerMutex.Unlock()
-	return listenerID
+	})
 }
 
-// getConnection 获取连接（线程安全）
-func getConnection(connID int64) (*tcpConnection, bool) {
-	tcpConnMutex.RLock()
-	tc, ok := tcpConnections[connID]
-	tcpConnMutex.RUnlock()
-	return tc, ok
+// getConnection 获取运行时的连接（线程安全）
+func (r *Runtime) getConnection(connID int64) (*tcpConnection, bool) {
+	return r.conns.get(connID)
 }
 
 // registerConnection 注册新连接（线程安全）
-func registerConnection(conn net.Conn, isTLS bool) int64 {
-	tcpConnMutex.Lock()
-	connID := nextConnID
-	nextConnID++
-	tcpConnections[connID] = &tcpConnection{
+func (r *Runtime) registerConnection(conn net.Conn, isTLS bool) int64 {
+	return r.conns.add(&tcpConnection{
 		conn:   conn,
 		reader: bufio.NewReader(conn),
 		isTLS:  isTLS,
-	}
-	tcpConnMutex.Unlock()
-	return connID
+	})
 }
 
 // ============================================================================
@@ -96,7 +67,7 @@ func registerConnection(conn net.Conn, isTLS bool) int64 {
 // ============================================================================
 
 // nativeTcpConnect 连接到TCP服务器（默认10秒超时）
-func nativeTcpConnect(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpConnect(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
@@ -107,12 +78,12 @@ func nativeTcpConnect(args []bytecode.Value) bytecode.Value {
 	if err != nil {
 		return bytecode.NewInt(-1)
 	}
-	connID := registerConnection(conn, false)
+	connID := r.registerConnection(conn, false)
 	return bytecode.NewInt(connID)
 }
 
 // nativeTcpConnectTimeout 带超时的连接（毫秒）
-func nativeTcpConnectTimeout(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpConnectTimeout(args []bytecode.Value) bytecode.Value {
 	if len(args) < 3 {
 		return bytecode.NewInt(-1)
 	}
@@ -127,33 +98,30 @@ func nativeTcpConnectTimeout(args []bytecode.Value) bytecode.Value {
 	if err != nil {
 		return bytecode.NewInt(-1)
 	}
-	connID := registerConnection(conn, false)
+	connID := r.registerConnection(conn, false)
 	return bytecode.NewInt(connID)
 }
 
 // nativeTcpClose 关闭连接
-func nativeTcpClose(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpClose(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
-	tcpConnMutex.Lock()
-	tc, ok := tcpConnections[connID]
+	tc, ok := r.conns.remove(connID)
 	if ok {
 		tc.conn.Close()
-		delete(tcpConnections, connID)
 	}
-	tcpConnMutex.Unlock()
 	return bytecode.NewBool(ok)
 }
 
 // nativeTcpIsConnected 检查连接是否有效
-func nativeTcpIsConnected(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpIsConnected(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -181,13 +149,13 @@ func nativeTcpIsConnected(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTcpWrite 写入字符串数据
-func nativeTcpWrite(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpWrite(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
 	connID := args[0].AsInt()
 	data := args[1].AsString()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewInt(-1)
 	}
@@ -199,7 +167,7 @@ func nativeTcpWrite(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpWriteBytes 写入字节数组
-func nativeTcpWriteBytes(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpWriteBytes(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
@@ -208,7 +176,7 @@ func nativeTcpWriteBytes(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	data := args[1].AsBytes()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewInt(-1)
 	}
@@ -220,13 +188,13 @@ func nativeTcpWriteBytes(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpRead 读取字符串数据
-func nativeTcpRead(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpRead(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
 	length := int(args[1].AsInt())
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -239,13 +207,13 @@ func nativeTcpRead(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpReadBytes 读取字节数组
-func nativeTcpReadBytes(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpReadBytes(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewBytes([]byte{})
 	}
 	connID := args[0].AsInt()
 	length := int(args[1].AsInt())
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewBytes([]byte{})
 	}
@@ -258,13 +226,13 @@ func nativeTcpReadBytes(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpReadExact 精确读取指定长度的字节
-func nativeTcpReadExact(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpReadExact(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewBytes([]byte{})
 	}
 	connID := args[0].AsInt()
 	length := int(args[1].AsInt())
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewBytes([]byte{})
 	}
@@ -284,12 +252,12 @@ func nativeTcpReadExact(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpReadLine 读取一行
-func nativeTcpReadLine(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpReadLine(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -301,13 +269,13 @@ func nativeTcpReadLine(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpReadUntil 读取直到遇到指定分隔符
-func nativeTcpReadUntil(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpReadUntil(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
 	delimiter := args[1].AsString()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -322,12 +290,12 @@ func nativeTcpReadUntil(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpAvailable 获取缓冲区中可读的字节数
-func nativeTcpAvailable(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpAvailable(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewInt(0)
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewInt(0)
 	}
@@ -335,12 +303,12 @@ func nativeTcpAvailable(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpFlush 刷新写入缓冲区（对于带缓冲的写入器）
-func nativeTcpFlush(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpFlush(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
-	_, ok := getConnection(connID)
+	_, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -353,13 +321,13 @@ func nativeTcpFlush(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTcpSetTimeout 设置通用超时（秒）
-func nativeTcpSetTimeout(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetTimeout(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	seconds := args[1].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -369,13 +337,13 @@ func nativeTcpSetTimeout(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetTimeoutMs 设置通用超时（毫秒）
-func nativeTcpSetTimeoutMs(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetTimeoutMs(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	ms := args[1].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -385,13 +353,13 @@ func nativeTcpSetTimeoutMs(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetReadTimeout 设置读取超时（毫秒）
-func nativeTcpSetReadTimeout(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetReadTimeout(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	ms := args[1].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -406,13 +374,13 @@ func nativeTcpSetReadTimeout(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetWriteTimeout 设置写入超时（毫秒）
-func nativeTcpSetWriteTimeout(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetWriteTimeout(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	ms := args[1].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -427,12 +395,12 @@ func nativeTcpSetWriteTimeout(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpClearTimeout 清除所有超时设置
-func nativeTcpClearTimeout(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpClearTimeout(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -445,13 +413,13 @@ func nativeTcpClearTimeout(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTcpSetKeepAlive 设置 KeepAlive
-func nativeTcpSetKeepAlive(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetKeepAlive(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	enabled := args[1].AsBool()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -487,13 +455,13 @@ func nativeTcpSetKeepAlive(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetNoDelay 设置 NoDelay（禁用 Nagle 算法）
-func nativeTcpSetNoDelay(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetNoDelay(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	enabled := args[1].AsBool()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -508,13 +476,13 @@ func nativeTcpSetNoDelay(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetLinger 设置 Linger 选项
-func nativeTcpSetLinger(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetLinger(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	seconds := int(args[1].AsInt())
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -529,13 +497,13 @@ func nativeTcpSetLinger(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetReadBuffer 设置读取缓冲区大小
-func nativeTcpSetReadBuffer(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetReadBuffer(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	size := int(args[1].AsInt())
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -553,13 +521,13 @@ func nativeTcpSetReadBuffer(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpSetWriteBuffer 设置写入缓冲区大小
-func nativeTcpSetWriteBuffer(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpSetWriteBuffer(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	size := int(args[1].AsInt())
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -581,12 +549,12 @@ func nativeTcpSetWriteBuffer(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTcpGetLocalAddr 获取本地地址
-func nativeTcpGetLocalAddr(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpGetLocalAddr(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -598,12 +566,12 @@ func nativeTcpGetLocalAddr(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpGetRemoteAddr 获取远程地址
-func nativeTcpGetRemoteAddr(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpGetRemoteAddr(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -615,12 +583,12 @@ func nativeTcpGetRemoteAddr(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpGetLocalHost 获取本地主机地址
-func nativeTcpGetLocalHost(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpGetLocalHost(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -636,12 +604,12 @@ func nativeTcpGetLocalHost(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpGetLocalPort 获取本地端口
-func nativeTcpGetLocalPort(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpGetLocalPort(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewInt(0)
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewInt(0)
 	}
@@ -659,12 +627,12 @@ func nativeTcpGetLocalPort(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpGetRemoteHost 获取远程主机地址
-func nativeTcpGetRemoteHost(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpGetRemoteHost(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -680,12 +648,12 @@ func nativeTcpGetRemoteHost(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpGetRemotePort 获取远程端口
-func nativeTcpGetRemotePort(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpGetRemotePort(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewInt(0)
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.NewInt(0)
 	}
@@ -703,12 +671,12 @@ func nativeTcpGetRemotePort(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpIsTLS 检查是否为TLS连接
-func nativeTcpIsTLS(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpIsTLS(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok {
 		return bytecode.FalseValue
 	}
@@ -720,7 +688,7 @@ func nativeTcpIsTLS(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTlsConnect TLS安全连接
-func nativeTlsConnect(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsConnect(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
@@ -751,12 +719,12 @@ func nativeTlsConnect(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	
-	connID := registerConnection(conn, true)
+	connID := r.registerConnection(conn, true)
 	return bytecode.NewInt(connID)
 }
 
 // nativeTlsConnectInsecure TLS连接（跳过证书验证，仅用于测试）
-func nativeTlsConnectInsecure(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsConnectInsecure(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
@@ -787,27 +755,27 @@ func nativeTlsConnectInsecure(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	
-	connID := registerConnection(conn, true)
+	connID := r.registerConnection(conn, true)
 	return bytecode.NewInt(connID)
 }
 
 // nativeTlsUpgrade 将现有TCP连接升级为TLS
-func nativeTlsUpgrade(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsUpgrade(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.FalseValue
 	}
 	connID := args[0].AsInt()
 	serverName := args[1].AsString()
 	
-	tcpConnMutex.Lock()
-	tc, ok := tcpConnections[connID]
+	r.conns.mu.Lock()
+	tc, ok := r.conns.entries[connID]
 	if !ok {
-		tcpConnMutex.Unlock()
+		r.conns.mu.Unlock()
 		return bytecode.FalseValue
 	}
 	
 	if tc.isTLS {
-		tcpConnMutex.Unlock()
+		r.conns.mu.Unlock()
 		return bytecode.TrueValue // 已经是TLS连接
 	}
 	
@@ -825,7 +793,7 @@ func nativeTlsUpgrade(args []bytecode.Value) bytecode.Value {
 	tlsConn := tls.Client(tc.conn, tlsConfig)
 	err := tlsConn.Handshake()
 	if err != nil {
-		tcpConnMutex.Unlock()
+		r.conns.mu.Unlock()
 		return bytecode.FalseValue
 	}
 	
@@ -833,18 +801,18 @@ func nativeTlsUpgrade(args []bytecode.Value) bytecode.Value {
 	tc.conn = tlsConn
 	tc.reader = bufio.NewReader(tlsConn)
 	tc.isTLS = true
-	tcpConnMutex.Unlock()
+	r.conns.mu.Unlock()
 	
 	return bytecode.TrueValue
 }
 
 // nativeTlsGetVersion 获取TLS版本
-func nativeTlsGetVersion(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsGetVersion(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok || !tc.isTLS {
 		return bytecode.NewString("")
 	}
@@ -870,12 +838,12 @@ func nativeTlsGetVersion(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTlsGetCipherSuite 获取TLS加密套件
-func nativeTlsGetCipherSuite(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsGetCipherSuite(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok || !tc.isTLS {
 		return bytecode.NewString("")
 	}
@@ -890,12 +858,12 @@ func nativeTlsGetCipherSuite(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTlsGetServerName 获取TLS服务器名称
-func nativeTlsGetServerName(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsGetServerName(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	connID := args[0].AsInt()
-	tc, ok := getConnection(connID)
+	tc, ok := r.getConnection(connID)
 	if !ok || !tc.isTLS {
 		return bytecode.NewString("")
 	}
@@ -914,7 +882,7 @@ func nativeTlsGetServerName(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTcpListen 在指定地址和端口上监听
-func nativeTcpListen(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpListen(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
@@ -927,17 +895,17 @@ func nativeTcpListen(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	
-	listenerID := registerListener(listener, host, int(port), false)
+	listenerID := r.registerListener(listener, host, int(port), false)
 	return bytecode.NewInt(listenerID)
 }
 
 // nativeTcpAccept 接受新连接（阻塞）
-func nativeTcpAccept(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpAccept(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewInt(-1)
 	}
 	listenerID := args[0].AsInt()
-	tl, ok := getListener(listenerID)
+	tl, ok := r.getListener(listenerID)
 	if !ok {
 		return bytecode.NewInt(-1)
 	}
@@ -947,19 +915,19 @@ func nativeTcpAccept(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	
-	connID := registerConnection(conn, tl.isTLS)
+	connID := r.registerConnection(conn, tl.isTLS)
 	return bytecode.NewInt(connID)
 }
 
 // nativeTcpAcceptTimeout 带超时的接受新连接
-func nativeTcpAcceptTimeout(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpAcceptTimeout(args []bytecode.Value) bytecode.Value {
 	if len(args) < 2 {
 		return bytecode.NewInt(-1)
 	}
 	listenerID := args[0].AsInt()
 	timeoutMs := args[1].AsInt()
 	
-	tl, ok := getListener(listenerID)
+	tl, ok := r.getListener(listenerID)
 	if !ok {
 		return bytecode.NewInt(-1)
 	}
@@ -976,35 +944,32 @@ func nativeTcpAcceptTimeout(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	
-	connID := registerConnection(conn, tl.isTLS)
+	connID := r.registerConnection(conn, tl.isTLS)
 	return bytecode.NewInt(connID)
 }
 
 // nativeTcpStopListen 停止监听
-func nativeTcpStopListen(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpStopListen(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	listenerID := args[0].AsInt()
 	
-	tcpListenerMutex.Lock()
-	tl, ok := tcpListeners[listenerID]
+	tl, ok := r.listeners.remove(listenerID)
 	if ok {
 		tl.listener.Close()
-		delete(tcpListeners, listenerID)
 	}
-	tcpListenerMutex.Unlock()
 	
 	return bytecode.NewBool(ok)
 }
 
 // nativeTcpListenerAddr 获取监听器地址
-func nativeTcpListenerAddr(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpListenerAddr(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	listenerID := args[0].AsInt()
-	tl, ok := getListener(listenerID)
+	tl, ok := r.getListener(listenerID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -1012,12 +977,12 @@ func nativeTcpListenerAddr(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpListenerHost 获取监听器主机
-func nativeTcpListenerHost(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpListenerHost(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewString("")
 	}
 	listenerID := args[0].AsInt()
-	tl, ok := getListener(listenerID)
+	tl, ok := r.getListener(listenerID)
 	if !ok {
 		return bytecode.NewString("")
 	}
@@ -1029,12 +994,12 @@ func nativeTcpListenerHost(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpListenerPort 获取监听器端口
-func nativeTcpListenerPort(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpListenerPort(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.NewInt(0)
 	}
 	listenerID := args[0].AsInt()
-	tl, ok := getListener(listenerID)
+	tl, ok := r.getListener(listenerID)
 	if !ok {
 		return bytecode.NewInt(0)
 	}
@@ -1048,12 +1013,12 @@ func nativeTcpListenerPort(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTcpListenerIsListening 检查是否正在监听
-func nativeTcpListenerIsListening(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTcpListenerIsListening(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	listenerID := args[0].AsInt()
-	_, ok := getListener(listenerID)
+	_, ok := r.getListener(listenerID)
 	return bytecode.NewBool(ok)
 }
 
@@ -1062,7 +1027,7 @@ func nativeTcpListenerIsListening(args []bytecode.Value) bytecode.Value {
 // ============================================================================
 
 // nativeTlsListen 在指定地址和端口上使用TLS监听
-func nativeTlsListen(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsListen(args []bytecode.Value) bytecode.Value {
 	if len(args) < 4 {
 		return bytecode.NewInt(-1)
 	}
@@ -1087,17 +1052,17 @@ func nativeTlsListen(args []bytecode.Value) bytecode.Value {
 		return bytecode.NewInt(-1)
 	}
 	
-	listenerID := registerListener(listener, host, int(port), true)
+	listenerID := r.registerListener(listener, host, int(port), true)
 	return bytecode.NewInt(listenerID)
 }
 
 // nativeTlsListenerIsTLS 检查监听器是否为TLS
-func nativeTlsListenerIsTLS(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTlsListenerIsTLS(args []bytecode.Value) bytecode.Value {
 	if len(args) < 1 {
 		return bytecode.FalseValue
 	}
 	listenerID := args[0].AsInt()
-	tl, ok := getListener(listenerID)
+	tl, ok := r.getListener(listenerID)
 	if !ok {
 		return bytecode.FalseValue
 	}
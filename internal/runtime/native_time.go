@@ -11,27 +11,27 @@ import (
 // ============================================================================
 
 // nativeTimeNow 获取当前 Unix 时间戳（秒）
-func nativeTimeNow(args []bytecode.Value) bytecode.Value {
-	return bytecode.NewInt(time.Now().Unix())
+func (r *Runtime) nativeTimeNow(args []bytecode.Value) bytecode.Value {
+	return bytecode.NewInt(r.now().Unix())
 }
 
 // nativeTimeNowMs 获取当前毫秒时间戳
-func nativeTimeNowMs(args []bytecode.Value) bytecode.Value {
-	return bytecode.NewInt(time.Now().UnixMilli())
+func (r *Runtime) nativeTimeNowMs(args []bytecode.Value) bytecode.Value {
+	return bytecode.NewInt(r.now().UnixMilli())
 }
 
 // nativeTimeNowNano 获取当前纳秒时间戳
-func nativeTimeNowNano(args []bytecode.Value) bytecode.Value {
-	return bytecode.NewInt(time.Now().UnixNano())
+func (r *Runtime) nativeTimeNowNano(args []bytecode.Value) bytecode.Value {
+	return bytecode.NewInt(r.now().UnixNano())
 }
 
-// nativeTimeSleep 休眠指定毫秒
-func nativeTimeSleep(args []bytecode.Value) bytecode.Value {
+// timeSleep 休眠指定毫秒，截止时间或取消先到时提前结束并终止执行
+func (r *Runtime) timeSleep(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
 		return bytecode.NullValue
 	}
 	ms := args[0].AsInt()
-	time.Sleep(time.Duration(ms) * time.Millisecond)
+	r.vm.Sleep(time.Duration(ms) * time.Millisecond)
 	return bytecode.NullValue
 }
 
@@ -86,9 +86,9 @@ func nativeTimeFormat(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeYear 获取年份
-func nativeTimeYear(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeYear(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Year()))
+		return bytecode.NewInt(int64(r.now().Year()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -96,9 +96,9 @@ func nativeTimeYear(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeMonth 获取月份
-func nativeTimeMonth(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeMonth(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Month()))
+		return bytecode.NewInt(int64(r.now().Month()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -106,9 +106,9 @@ func nativeTimeMonth(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeDay 获取日
-func nativeTimeDay(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeDay(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Day()))
+		return bytecode.NewInt(int64(r.now().Day()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -116,9 +116,9 @@ func nativeTimeDay(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeHour 获取时
-func nativeTimeHour(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeHour(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Hour()))
+		return bytecode.NewInt(int64(r.now().Hour()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -126,9 +126,9 @@ func nativeTimeHour(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeMinute 获取分
-func nativeTimeMinute(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeMinute(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Minute()))
+		return bytecode.NewInt(int64(r.now().Minute()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -136,9 +136,9 @@ func nativeTimeMinute(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeSecond 获取秒
-func nativeTimeSecond(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeSecond(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Second()))
+		return bytecode.NewInt(int64(r.now().Second()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -146,9 +146,9 @@ func nativeTimeSecond(args []bytecode.Value) bytecode.Value {
 }
 
 // nativeTimeWeekday 获取星期几（0=周日，1=周一...）
-func nativeTimeWeekday(args []bytecode.Value) bytecode.Value {
+func (r *Runtime) nativeTimeWeekday(args []bytecode.Value) bytecode.Value {
 	if len(args) == 0 {
-		return bytecode.NewInt(int64(time.Now().Weekday()))
+		return bytecode.NewInt(int64(r.now().Weekday()))
 	}
 	timestamp := args[0].AsInt()
 	t := time.Unix(timestamp, 0)
@@ -0,0 +1,121 @@
+package runtime
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// Native 弱引用函数 (仅供标准库使用)
+// ============================================================================
+//
+// sola.lang.WeakRef 和 sola.collections.WeakHashMap 包装这里创建的弱引用值和
+// 弱键映射值。只有对象可以被弱引用；对象只被弱引用持有时，下一次 gc_collect()
+// 之后弱引用返回 null，弱键映射中以它为键的条目消失 (沙箱没有授予 GC 能力时，
+// 要等到 Go 下一次自动回收之后)。
+
+// weakTarget 返回参数中的对象，不是对象时返回 ArgumentException
+func weakTarget(args []bytecode.Value, i int) (*bytecode.Object, bytecode.Value) {
+	if len(args) <= i || args[i].AsObject() == nil {
+		return nil, bytecode.NewException("ArgumentException", "weak reference target must be an object", 0)
+	}
+	return args[i].AsObject(), bytecode.NullValue
+}
+
+// nativeWeakRefNew 创建对象的弱引用
+func nativeWeakRefNew(args []bytecode.Value) bytecode.Value {
+	obj, ex := weakTarget(args, 0)
+	if obj == nil {
+		return ex
+	}
+	return bytecode.NewWeakRefValue(bytecode.NewWeakRef(obj))
+}
+
+// nativeWeakRefGet 返回弱引用的对象，对象已被回收时返回 null
+func nativeWeakRefGet(args []bytecode.Value) bytecode.Value {
+	if len(args) == 0 || !args[0].IsWeakRef() {
+		return bytecode.NullValue
+	}
+	if obj := args[0].AsWeakRef().Get(); obj != nil {
+		return bytecode.NewObject(obj)
+	}
+	return bytecode.NullValue
+}
+
+// nativeWeakMapNew 创建空的弱键映射
+func nativeWeakMapNew(args []bytecode.Value) bytecode.Value {
+	return bytecode.NewWeakMapValue(bytecode.NewWeakMap())
+}
+
+// nativeWeakMapSet 设置键对应的值
+// 参数: map, key, value
+func nativeWeakMapSet(args []bytecode.Value) bytecode.Value {
+	if len(args) < 3 || !args[0].IsWeakMap() {
+		return bytecode.NullValue
+	}
+	key, ex := weakTarget(args, 1)
+	if key == nil {
+		return ex
+	}
+	args[0].AsWeakMap().Set(key, args[2])
+	return bytecode.NullValue
+}
+
+// nativeWeakMapGet 返回键对应的值，键不存在时返回 null
+// 参数: map, key
+func nativeWeakMapGet(args []bytecode.Value) bytecode.Value {
+	if len(args) < 2 || !args[0].IsWeakMap() || args[1].AsObject() == nil {
+		return bytecode.NullValue
+	}
+	if v, ok := args[0].AsWeakMap().Get(args[1].AsObject()); ok {
+		return v
+	}
+	return bytecode.NullValue
+}
+
+// nativeWeakMapHas 检查键是否存在
+// 参数: map, key
+func nativeWeakMapHas(args []bytecode.Value) bytecode.Value {
+	if len(args) < 2 || !args[0].IsWeakMap() || args[1].AsObject() == nil {
+		return bytecode.FalseValue
+	}
+	_, ok := args[0].AsWeakMap().Get(args[1].AsObject())
+	return bytecode.NewBool(ok)
+}
+
+// nativeWeakMapRemove 删除键，键存在时返回 true
+// 参数: map, key
+func nativeWeakMapRemove(args []bytecode.Value) bytecode.Value {
+	if len(args) < 2 || !args[0].IsWeakMap() || args[1].AsObject() == nil {
+		return bytecode.FalseValue
+	}
+	return bytecode.NewBool(args[0].AsWeakMap().Delete(args[1].AsObject()))
+}
+
+// nativeWeakMapSize 返回存活的条目数
+func nativeWeakMapSize(args []bytecode.Value) bytecode.Value {
+	if len(args) == 0 || !args[0].IsWeakMap() {
+		return bytecode.NewInt(0)
+	}
+	return bytecode.NewInt(int64(args[0].AsWeakMap().Len()))
+}
+
+// nativeWeakMapKeys 返回存活的键组成的数组
+func nativeWeakMapKeys(args []bytecode.Value) bytecode.Value {
+	if len(args) == 0 || !args[0].IsWeakMap() {
+		return bytecode.NewArray(nil)
+	}
+	var keys []bytecode.Value
+	args[0].AsWeakMap().Range(func(key *bytecode.Object, _ bytecode.Value) bool {
+		keys = append(keys, bytecode.NewObject(key))
+		return true
+	})
+	return bytecode.NewArray(keys)
+}
+
+// nativeWeakMapClear 删除所有条目
+func nativeWeakMapClear(args []bytecode.Value) bytecode.Value {
+	if len(args) > 0 && args[0].IsWeakMap() {
+		args[0].AsWeakMap().Clear()
+	}
+	return bytecode.NullValue
+}
@@ -0,0 +1,170 @@
+package runtime
+
+import (
+	goruntime "runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+// numGC 返回 Go 已完成的垃圾回收次数
+func numGC() uint32 {
+	var ms goruntime.MemStats
+	goruntime.ReadMemStats(&ms)
+	return ms.NumGC
+}
+
+func TestGCCollectRequiresSandboxCapability(t *testing.T) {
+	// 关闭自动回收，回收次数只因 gc_collect 变化
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+
+	source := `
+class Collect {
+    public static function run(): int {
+        for ($i := 0; $i < 10; $i++) {
+            $garbage := [$i, $i + 1];
+        }
+        return gc_collect();
+    }
+}
+`
+	tests := []struct {
+		name    string
+		sandbox *Sandbox
+		forced  bool
+	}{
+		{"unrestricted", nil, true},
+		{"sandboxed", &Sandbox{}, false},
+		{"granted", &Sandbox{GC: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, classes := loadTest(t, Options{Sandbox: tt.sandbox}, "Collect.sola", source)
+			before := numGC()
+			if _, err := r.VM().CallStatic(classes["Collect"], "run", nil); err != nil {
+				t.Fatal(err)
+			}
+			if forced := numGC() != before; forced != tt.forced {
+				t.Fatalf("gc_collect ran a Go GC = %v, want %v", forced, tt.forced)
+			}
+			if r.VM().MemoryStats().Collections == 0 {
+				t.Fatal("gc_collect did not recount the VM heap")
+			}
+		})
+	}
+}
+
+func TestWeakRefClearedAfterCollect(t *testing.T) {
+	r, classes := loadTest(t, Options{}, "Refs.sola", `
+use sola.lang.WeakRef;
+
+class Node {
+    public int $v = 0;
+}
+class Refs {
+    public static function run(): bool[] {
+        $kept := new Node();
+        $dropped := new Node();
+        $a := new WeakRef<Node>($kept);
+        $b := new WeakRef<Node>($dropped);
+        bool[] $before = new bool[] { $a->isAlive(), $b->isAlive(), $a->get() == $kept };
+        unset($dropped);
+        gc_collect();
+        return new bool[] { $before[0], $before[1], $before[2], $a->isAlive(), $b->isAlive(), $b->get() == null, $kept->v == 0 };
+    }
+}
+`)
+	result, err := r.VM().CallStatic(classes["Refs"], "run", nil)
+	// 回收前两个引用都有效；回收后只有仍被 $kept 引用的对象存活
+	if want := "[true, true, true, true, false, true, true]"; err != nil || result.String() != want {
+		t.Fatalf("run = %v, %v; want %s", result, err, want)
+	}
+}
+
+func TestWeakHashMapEntriesDisappearAfterCollect(t *testing.T) {
+	r, classes := loadTest(t, Options{}, "Cache.sola", `
+use sola.collections.WeakHashMap;
+
+class Node {
+    public int $v = 0;
+}
+class Cache {
+    public static function fill(WeakHashMap<Node, dynamic> $cache, int $n): void {
+        for (int $i = 0; $i < $n; $i++) {
+            $cache->put(new Node(), [$i, $i, $i, $i]);
+        }
+    }
+    public static function run(): int[] {
+        $cache := new WeakHashMap<Node, dynamic>();
+        $kept := new Node();
+        $cache->put($kept, "kept");
+        Cache::fill($cache, 100);
+        $before := $cache->size();
+        gc_collect();
+        $hit := $cache->get($kept) == "kept" && $cache->containsKey($kept);
+        return new int[] { $before, $cache->size(), $hit ? 1 : 0 };
+    }
+}
+`)
+	result, err := r.VM().CallStatic(classes["Cache"], "run", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[101, 1, 1]"; result.String() != want {
+		t.Fatalf("run = %v, want %s", result, want)
+	}
+
+	// 键已被回收的条目的值不再计入存活字节数
+	if live := r.VM().MemoryStats().LiveBytes; live > 16<<10 {
+		t.Errorf("expected dropped entries to be freed, got %d live bytes", live)
+	}
+}
+
+func TestWeakHashMapPutGetOnlyFreesValues(t *testing.T) {
+	r, classes := loadTest(t, Options{}, "Cache.sola", `
+use sola.collections.WeakHashMap;
+use sola.lang.WeakRef;
+
+class Node {
+    public int $v = 0;
+}
+class Cache {
+    public static WeakHashMap<Node, Node> $cache = new WeakHashMap<Node, Node>();
+    public static Node $kept = new Node();
+
+    public static function fill(): WeakRef<Node> {
+        $value := new Node();
+        Cache::$cache->put(new Node(), $value);
+        Cache::$cache->put(Cache::$kept, new Node());
+        return new WeakRef<Node>($value);
+    }
+    public static function hit(): bool {
+        return Cache::$cache->get(Cache::$kept) != null;
+    }
+}
+`)
+	ref, err := r.VM().CallStatic(classes["Cache"], "fill", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alive := func() bool {
+		v, err := r.VM().CallMethod(ref, "isAlive", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.AsBool()
+	}
+
+	// 缓存只调用 put 和 get，也不运行 VM 的内存统计 (它会顺带清除失效条目)：
+	// 键被 Go 回收后 cleanup 删除条目，值在之后的一次回收中释放
+	for i := 0; alive(); i++ {
+		if i == 100 {
+			t.Fatal("value of a collected key is still reachable")
+		}
+		goruntime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if hit, err := r.VM().CallStatic(classes["Cache"], "hit", nil); err != nil || !hit.AsBool() {
+		t.Fatalf("entry of a live key was dropped: %v, %v", hit, err)
+	}
+}
@@ -1,7 +1,9 @@
 package runtime
 
 import (
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/tangzhangming/nova/internal/ast"
 	"github.com/tangzhangming/nova/internal/bytecode"
@@ -16,10 +18,21 @@ import (
 type Runtime struct {
 	vm          *vm.VM
 	builtins    map[string]BuiltinFunc
+	natives     map[string]BuiltinFunc // 宿主通过 RegisterBuiltin 注册的内置函数
 	loader      *loader.Loader
 	classes     map[string]*bytecode.Class
 	enums       map[string]*bytecode.Enum
 	symbolTable *compiler.SymbolTable // 共享符号表
+	libDir      string                // 标准库目录 (为空时按可执行文件位置查找)
+	sandbox     *Sandbox              // 原生函数的能力策略 (nil 表示不限制)
+	image       *Image                // 由映像创建时为该映像
+	leakLog     io.Writer             // 原生句柄泄漏警告的输出位置
+	determinism Determinism           // 时间、随机数和调度顺序的来源 (nil 表示真实来源)
+
+	// 打开的原生句柄，属于这个运行时
+	streams   handleTable[*fileStream]
+	conns     handleTable[*tcpConnection]
+	listeners handleTable[*tcpListener]
 }
 
 // BuiltinFunc 内置函数类型
@@ -27,7 +40,32 @@ type BuiltinFunc func(args []bytecode.Value) bytecode.Value
 
 // Options 运行时选项
 type Options struct {
-	// 预留扩展字段
+	// LibDir 标准库目录，为空时使用可执行文件上一级的 src/ 目录
+	LibDir string
+
+	// Limits 每次运行的执行限制 (指令数、执行时间、分配次数、取消)，零值表示不限制
+	// 超过限制时 Run 返回 *vm.LimitError
+	Limits vm.Limits
+
+	// Sandbox 文件系统、网络、反射和 native_panic 的能力策略，nil 表示不限制
+	// 被拒绝的访问抛出 SecurityException
+	Sandbox *Sandbox
+
+	// MaxHeapBytes 堆上限 (估算字节数)，0 表示不限制
+	// 超过时抛出 OutOfMemoryError，与执行限制不同，它可以被 catch 捕获
+	MaxHeapBytes uint64
+
+	// ResourceLeakLog 原生句柄 (文件流、TCP 连接等) 未关闭即被回收时警告的输出位置，
+	// nil 表示标准错误
+	ResourceLeakLog io.Writer
+
+	// Determinism 时间、随机数和协程调度顺序的来源，nil 表示使用真实的时间和随机数
+	// 见 Seeded (确定性运行)、Record (记录) 和 Replay (回放)
+	Determinism Determinism
+
+	// Hooks 安装到虚拟机的执行钩子 (调试器、性能分析器)，按顺序调用
+	// 钩子在执行脚本的 goroutine 上同步调用，不要在并发使用的运行时 (如 Pool) 之间共享
+	Hooks []vm.Hook
 }
 
 // DefaultOptions 返回默认选项
@@ -42,14 +80,24 @@ func New() *Runtime {
 
 // NewWithOptions 创建带选项的运行时
 func NewWithOptions(opts Options) *Runtime {
-	_ = opts // 预留扩展
-
 	r := &Runtime{
 		vm:          vm.New(),
 		builtins:    make(map[string]BuiltinFunc),
+		natives:     make(map[string]BuiltinFunc),
 		classes:     make(map[string]*bytecode.Class),
 		enums:       make(map[string]*bytecode.Enum),
 		symbolTable: compiler.NewSymbolTable(),
+		libDir:      opts.LibDir,
+		sandbox:     opts.Sandbox,
+		leakLog:     leakWriter(opts.ResourceLeakLog),
+	}
+	r.vm.SetLimits(opts.Limits)
+	r.vm.SetHeapLimit(opts.MaxHeapBytes)
+	if opts.Determinism != nil {
+		r.applyDeterminism(opts.Determinism)
+	}
+	for _, h := range opts.Hooks {
+		r.vm.AddHook(h)
 	}
 	r.registerBuiltins()
 	// 异常类现在通过 lib/lang/*.sola 文件定义，不再在这里内置
@@ -59,72 +107,32 @@ func NewWithOptions(opts Options) *Runtime {
 // Run 运行源代码
 func (r *Runtime) Run(source, filename string) error {
 	// 创建加载器
-	var err error
-	r.loader, err = loader.New(filename)
-	if err != nil {
-		return fmt.Errorf(i18n.T(i18n.ErrFailedCreateLoader, err))
+	if err := r.newLoader(filename); err != nil {
+		return err
 	}
 
 	// 解析入口文件
-	p := parser.New(source, filename)
-	file := p.Parse()
-
-	if p.HasErrors() {
-		for _, e := range p.Errors() {
-			fmt.Printf(i18n.T(i18n.ErrParseError, e) + "\n")
-		}
-		return fmt.Errorf(i18n.T(i18n.ErrParseFailed))
+	file, err := parseSource(source, filename)
+	if err != nil {
+		return reportCompileError(err)
 	}
 
 	// 处理 use 声明，加载依赖（使用共享符号表）
 	for _, use := range file.Uses {
 		if err := r.loadDependency(use.Path); err != nil {
-			return fmt.Errorf(i18n.T(i18n.ErrLoadFailed, use.Path, err))
+			return errors.New(i18n.T(i18n.ErrLoadFailed, use.Path, reportCompileError(err)))
 		}
 	}
 
 	// 编译入口文件（使用共享符号表，以便识别导入的类）
-	c := compiler.NewWithSymbolTable(r.symbolTable)
-	_, errs := c.Compile(file)
-
-	if len(errs) > 0 {
-		for _, e := range errs {
-			fmt.Printf(i18n.T(i18n.ErrCompileError, e) + "\n")
-		}
-		return fmt.Errorf(i18n.T(i18n.ErrCompileFailed))
-	}
-
-	// 注册编译的类
-	classes := c.Classes()
-	for name, class := range classes {
-		// 使用完整路径作为类名（命名空间.类名）
-		fullName := name
-		if file.Namespace != nil {
-			fullName = file.Namespace.Name + "." + name
-		}
-		r.classes[fullName] = class
-		r.classes[name] = class // 也用短名注册
-		r.vm.DefineClass(class)
-	}
-
-	// 解析父类引用（包括导入的类）
-	for _, class := range r.classes {
-		if class.ParentName != "" && class.Parent == nil {
-			if parent, ok := r.classes[class.ParentName]; ok {
-				class.Parent = parent
-			}
-		}
+	c, _, err := r.compileFile(file, "")
+	if err != nil {
+		return reportCompileError(err)
 	}
 
-	// 构建 VTable（为所有类构建接口虚表）
-	bytecode.BuildAllVTables(r.classes)
-
-	// 注册编译的枚举
-	enums := c.Enums()
-	for name, enum := range enums {
-		r.enums[name] = enum
-		r.vm.DefineEnum(enum)
-	}
+	// 注册编译的类和枚举，解析父类引用并构建虚表
+	r.defineCompiled(c, file)
+	r.linkClasses()
 
 	// 注册内置函数
 	r.registerBuiltinsToVM()
@@ -140,20 +148,30 @@ func (r *Runtime) Run(source, filename string) error {
 	}
 	
 	if !ok {
-		return fmt.Errorf(i18n.T(i18n.ErrMainMethodRequired))
+		return errors.New(i18n.T(i18n.ErrMainMethodRequired))
 	}
 
 	// 查找静态 main 方法
 	mainMethod := r.findMainMethod(entryClass)
 	if mainMethod == nil {
-		return fmt.Errorf(i18n.T(i18n.ErrMainMethodRequired))
+		return errors.New(i18n.T(i18n.ErrMainMethodRequired))
 	}
 
-	// 调用 main 方法
+	return r.callMain(entryClass)
+}
+
+// callMain 调用入口类的静态 main() 方法
+func (r *Runtime) callMain(entryClass *bytecode.Class) error {
 	result := r.vm.CallStaticMethod(entryClass, "main", nil)
+	if err := r.finishDeterminism(); err != nil {
+		return err
+	}
 	if result != vm.InterpretOK {
-		// VM 已经打印了详细的错误信息，这里返回空错误表示执行失败
-		return fmt.Errorf("")
+		if e := r.vm.LimitExceeded(); e != nil {
+			return e
+		}
+		// 运行时错误或未捕获的异常，错误信息包含 Sola 调用栈
+		return errors.New(r.vm.GetError())
 	}
 
 	return nil
@@ -212,17 +230,14 @@ func (r *Runtime) loadDependency(importPath string) error {
 	// 加载文件内容
 	source, err := r.loader.LoadFile(filePath)
 	if err != nil {
-		return fmt.Errorf(i18n.T(i18n.ErrReadFailed, filePath, err))
+		return errors.New(i18n.T(i18n.ErrReadFailed, filePath, err))
 	}
 
 	// 解析（使用完整路径以便错误信息显示准确位置）
-	p := parser.New(source, filePath)
-	file := p.Parse()
-	if p.HasErrors() {
-		for _, e := range p.Errors() {
-			fmt.Printf(i18n.T(i18n.ErrParseError, e) + "\n")
-		}
-		return fmt.Errorf(i18n.T(i18n.ErrParseFailedFor, importPath))
+	file, err := parseSource(source, filePath)
+	if err != nil {
+		err.(*CompileError).Import = importPath
+		return err
 	}
 
 	// 递归加载依赖
@@ -233,36 +248,74 @@ func (r *Runtime) loadDependency(importPath string) error {
 	}
 
 	// 编译（使用共享符号表）
-	c := compiler.NewWithSymbolTable(r.symbolTable)
-	_, errs := c.Compile(file)
-	if len(errs) > 0 {
-		for _, e := range errs {
-			fmt.Printf(i18n.T(i18n.ErrCompileError, e) + "\n")
-		}
-		return fmt.Errorf(i18n.T(i18n.ErrCompileFailedFor, importPath))
+	c, _, err := r.compileFile(file, importPath)
+	if err != nil {
+		return err
 	}
 
-	// 注册类
+	// 注册类和枚举
+	r.defineCompiled(c, file)
+	return nil
+}
+
+// defineCompiled 注册编译出的类和枚举
+// 类同时以完整路径 (命名空间.类名) 和短名注册
+func (r *Runtime) defineCompiled(c *compiler.Compiler, file *ast.File) {
 	for name, class := range c.Classes() {
-		// 使用完整路径作为类名（命名空间.类名）
 		fullName := name
 		if file.Namespace != nil {
 			fullName = file.Namespace.Name + "." + name
 		}
 		r.classes[fullName] = class
-		r.classes[name] = class // 也用短名注册
+		r.classes[name] = class
 		r.vm.DefineClass(class)
 	}
-
-	// 注册枚举
 	for name, enum := range c.Enums() {
 		r.enums[name] = enum
 		r.vm.DefineEnum(enum)
 	}
+}
+
+// linkClasses 解析父类引用（包括导入的类）并为所有类构建接口虚表
+func (r *Runtime) linkClasses() {
+	for _, class := range r.classes {
+		if class.ParentName != "" && class.Parent == nil {
+			if parent, ok := r.classes[class.ParentName]; ok {
+				class.Parent = parent
+			}
+		}
+	}
+	bytecode.BuildAllVTables(r.classes)
+}
 
+// newLoader 为入口文件创建包加载器
+func (r *Runtime) newLoader(filename string) error {
+	l, err := loader.New(filename)
+	if err != nil {
+		return errors.New(i18n.T(i18n.ErrFailedCreateLoader, err))
+	}
+	if r.libDir != "" {
+		l.SetLibDir(r.libDir)
+	}
+	r.loader = l
 	return nil
 }
 
+// SetLimits 设置之后每次运行的执行限制
+func (r *Runtime) SetLimits(l vm.Limits) {
+	r.vm.SetLimits(l)
+}
+
+// Stats 返回虚拟机的执行统计信息
+func (r *Runtime) Stats() vm.VMStats {
+	return r.vm.Stats()
+}
+
+// MemoryStats 返回虚拟机的内存统计信息
+func (r *Runtime) MemoryStats() vm.MemoryStats {
+	return r.vm.MemoryStats()
+}
+
 // RunFile 运行文件
 func (r *Runtime) RunFile(filename string) error {
 	// 由调用者读取文件内容
@@ -275,14 +328,14 @@ func (r *Runtime) CompileOnly(source, filename string) (*bytecode.Function, erro
 	file := p.Parse()
 
 	if p.HasErrors() {
-		return nil, fmt.Errorf(i18n.T(i18n.ErrParseFailed))
+		return nil, errors.New(i18n.T(i18n.ErrParseFailed))
 	}
 
 	c := compiler.New()
 	fn, errs := c.Compile(file)
 
 	if len(errs) > 0 {
-		return nil, fmt.Errorf(i18n.T(i18n.ErrCompileFailed))
+		return nil, errors.New(i18n.T(i18n.ErrCompileFailed))
 	}
 
 	return fn, nil
@@ -294,7 +347,7 @@ func (r *Runtime) ParseOnly(source, filename string) (*ast.File, error) {
 	file := p.Parse()
 
 	if p.HasErrors() {
-		return nil, fmt.Errorf(i18n.T(i18n.ErrParseFailed))
+		return nil, errors.New(i18n.T(i18n.ErrParseFailed))
 	}
 
 	return file, nil
@@ -303,39 +356,27 @@ func (r *Runtime) ParseOnly(source, filename string) (*ast.File, error) {
 // CompileToCompiledFile 编译为 CompiledFile（用于 build 命令）
 func (r *Runtime) CompileToCompiledFile(source, filename string) (*bytecode.CompiledFile, error) {
 	// 创建加载器
-	var err error
-	r.loader, err = loader.New(filename)
-	if err != nil {
-		return nil, fmt.Errorf(i18n.T(i18n.ErrFailedCreateLoader, err))
+	if err := r.newLoader(filename); err != nil {
+		return nil, err
 	}
 
 	// 解析入口文件
-	p := parser.New(source, filename)
-	file := p.Parse()
-
-	if p.HasErrors() {
-		for _, e := range p.Errors() {
-			fmt.Printf(i18n.T(i18n.ErrParseError, e) + "\n")
-		}
-		return nil, fmt.Errorf(i18n.T(i18n.ErrParseFailed))
+	file, err := parseSource(source, filename)
+	if err != nil {
+		return nil, reportCompileError(err)
 	}
 
 	// 处理 use 声明，加载依赖（使用共享符号表）
 	for _, use := range file.Uses {
 		if err := r.loadDependency(use.Path); err != nil {
-			return nil, fmt.Errorf(i18n.T(i18n.ErrLoadFailed, use.Path, err))
+			return nil, errors.New(i18n.T(i18n.ErrLoadFailed, use.Path, reportCompileError(err)))
 		}
 	}
 
 	// 编译入口文件（使用共享符号表，以便识别导入的类）
-	c := compiler.NewWithSymbolTable(r.symbolTable)
-	fn, errs := c.Compile(file)
-
-	if len(errs) > 0 {
-		for _, e := range errs {
-			fmt.Printf(i18n.T(i18n.ErrCompileError, e) + "\n")
-		}
-		return nil, fmt.Errorf(i18n.T(i18n.ErrCompileFailed))
+	c, fn, err := r.compileFile(file, "")
+	if err != nil {
+		return nil, reportCompileError(err)
 	}
 
 	// 收集所有类和枚举（包括依赖项）
@@ -391,8 +432,11 @@ func (r *Runtime) RunCompiled(cf *bytecode.CompiledFile) error {
 
 	// 运行
 	_ = r.vm.Run(cf.MainFunction)
+	if err := r.finishDeterminism(); err != nil {
+		return err
+	}
 	if r.vm.HasError() {
-		return fmt.Errorf("")
+		return errors.New(r.vm.GetError())
 	}
 
 	return nil
@@ -408,34 +452,27 @@ func (r *Runtime) RunREPL(source, filename string) error {
 
 	if p.HasErrors() {
 		for _, e := range p.Errors() {
-			fmt.Printf(i18n.T(i18n.ErrParseError, e) + "\n")
+			fmt.Println(i18n.T(i18n.ErrParseError, e))
 		}
-		return fmt.Errorf(i18n.T(i18n.ErrParseFailed))
+		return errors.New(i18n.T(i18n.ErrParseFailed))
 	}
 
 	// 处理 use 声明
 	for _, use := range file.Uses {
 		if r.loader == nil {
-			var err error
-			r.loader, err = loader.New(filename)
-			if err != nil {
-				return fmt.Errorf(i18n.T(i18n.ErrFailedCreateLoader, err))
+			if err := r.newLoader(filename); err != nil {
+				return err
 			}
 		}
 		if err := r.loadDependency(use.Path); err != nil {
-			return fmt.Errorf(i18n.T(i18n.ErrLoadFailed, use.Path, err))
+			return errors.New(i18n.T(i18n.ErrLoadFailed, use.Path, reportCompileError(err)))
 		}
 	}
 
 	// 编译（使用共享符号表保持状态）
-	c := compiler.NewWithSymbolTable(r.symbolTable)
-	fn, errs := c.Compile(file)
-
-	if len(errs) > 0 {
-		for _, e := range errs {
-			fmt.Printf(i18n.T(i18n.ErrCompileError, e) + "\n")
-		}
-		return fmt.Errorf(i18n.T(i18n.ErrCompileFailed))
+	c, fn, err := r.compileFile(file, "")
+	if err != nil {
+		return reportCompileError(err)
 	}
 
 	// 注册新的类
@@ -456,7 +493,7 @@ func (r *Runtime) RunREPL(source, filename string) error {
 	// 运行
 	_ = r.vm.Run(fn)
 	if r.vm.HasError() {
-		return fmt.Errorf("")
+		return errors.New(r.vm.GetError())
 	}
 
 	return nil
@@ -468,14 +505,14 @@ func (r *Runtime) Disassemble(source, filename string) (string, error) {
 	p := parser.New(source, filename)
 	file := p.Parse()
 	if p.HasErrors() {
-		return "", fmt.Errorf(i18n.T(i18n.ErrParseFailed))
+		return "", errors.New(i18n.T(i18n.ErrParseFailed))
 	}
 
 	// 编译
 	c := compiler.New()
 	fn, errs := c.Compile(file)
 	if len(errs) > 0 {
-		return "", fmt.Errorf(i18n.T(i18n.ErrCompileFailed))
+		return "", errors.New(i18n.T(i18n.ErrCompileFailed))
 	}
 
 	result := fn.Chunk.Disassemble(filename)
@@ -592,66 +629,66 @@ func (r *Runtime) registerBuiltins() {
 	r.builtins["native_str_to_float"] = nativeStrToFloat
 
 	// Native TCP 函数 - 连接管理 (仅供标准库使用)
-	r.builtins["native_tcp_connect"] = nativeTcpConnect
-	r.builtins["native_tcp_connect_timeout"] = nativeTcpConnectTimeout
-	r.builtins["native_tcp_close"] = nativeTcpClose
-	r.builtins["native_tcp_is_connected"] = nativeTcpIsConnected
+	r.builtins["native_tcp_connect"] = r.nativeTcpConnect
+	r.builtins["native_tcp_connect_timeout"] = r.nativeTcpConnectTimeout
+	r.builtins["native_tcp_close"] = r.nativeTcpClose
+	r.builtins["native_tcp_is_connected"] = r.nativeTcpIsConnected
 
 	// Native TCP 函数 - 数据读写
-	r.builtins["native_tcp_write"] = nativeTcpWrite
-	r.builtins["native_tcp_write_bytes"] = nativeTcpWriteBytes
-	r.builtins["native_tcp_read"] = nativeTcpRead
-	r.builtins["native_tcp_read_bytes"] = nativeTcpReadBytes
-	r.builtins["native_tcp_read_exact"] = nativeTcpReadExact
-	r.builtins["native_tcp_read_line"] = nativeTcpReadLine
-	r.builtins["native_tcp_read_until"] = nativeTcpReadUntil
-	r.builtins["native_tcp_available"] = nativeTcpAvailable
-	r.builtins["native_tcp_flush"] = nativeTcpFlush
+	r.builtins["native_tcp_write"] = r.nativeTcpWrite
+	r.builtins["native_tcp_write_bytes"] = r.nativeTcpWriteBytes
+	r.builtins["native_tcp_read"] = r.nativeTcpRead
+	r.builtins["native_tcp_read_bytes"] = r.nativeTcpReadBytes
+	r.builtins["native_tcp_read_exact"] = r.nativeTcpReadExact
+	r.builtins["native_tcp_read_line"] = r.nativeTcpReadLine
+	r.builtins["native_tcp_read_until"] = r.nativeTcpReadUntil
+	r.builtins["native_tcp_available"] = r.nativeTcpAvailable
+	r.builtins["native_tcp_flush"] = r.nativeTcpFlush
 
 	// Native TCP 函数 - 超时配置
-	r.builtins["native_tcp_set_timeout"] = nativeTcpSetTimeout
-	r.builtins["native_tcp_set_timeout_ms"] = nativeTcpSetTimeoutMs
-	r.builtins["native_tcp_set_read_timeout"] = nativeTcpSetReadTimeout
-	r.builtins["native_tcp_set_write_timeout"] = nativeTcpSetWriteTimeout
-	r.builtins["native_tcp_clear_timeout"] = nativeTcpClearTimeout
+	r.builtins["native_tcp_set_timeout"] = r.nativeTcpSetTimeout
+	r.builtins["native_tcp_set_timeout_ms"] = r.nativeTcpSetTimeoutMs
+	r.builtins["native_tcp_set_read_timeout"] = r.nativeTcpSetReadTimeout
+	r.builtins["native_tcp_set_write_timeout"] = r.nativeTcpSetWriteTimeout
+	r.builtins["native_tcp_clear_timeout"] = r.nativeTcpClearTimeout
 
 	// Native TCP 函数 - Socket选项
-	r.builtins["native_tcp_set_keepalive"] = nativeTcpSetKeepAlive
-	r.builtins["native_tcp_set_nodelay"] = nativeTcpSetNoDelay
-	r.builtins["native_tcp_set_linger"] = nativeTcpSetLinger
-	r.builtins["native_tcp_set_read_buffer"] = nativeTcpSetReadBuffer
-	r.builtins["native_tcp_set_write_buffer"] = nativeTcpSetWriteBuffer
+	r.builtins["native_tcp_set_keepalive"] = r.nativeTcpSetKeepAlive
+	r.builtins["native_tcp_set_nodelay"] = r.nativeTcpSetNoDelay
+	r.builtins["native_tcp_set_linger"] = r.nativeTcpSetLinger
+	r.builtins["native_tcp_set_read_buffer"] = r.nativeTcpSetReadBuffer
+	r.builtins["native_tcp_set_write_buffer"] = r.nativeTcpSetWriteBuffer
 
 	// Native TCP 函数 - 地址信息
-	r.builtins["native_tcp_get_local_addr"] = nativeTcpGetLocalAddr
-	r.builtins["native_tcp_get_remote_addr"] = nativeTcpGetRemoteAddr
-	r.builtins["native_tcp_get_local_host"] = nativeTcpGetLocalHost
-	r.builtins["native_tcp_get_local_port"] = nativeTcpGetLocalPort
-	r.builtins["native_tcp_get_remote_host"] = nativeTcpGetRemoteHost
-	r.builtins["native_tcp_get_remote_port"] = nativeTcpGetRemotePort
-	r.builtins["native_tcp_is_tls"] = nativeTcpIsTLS
+	r.builtins["native_tcp_get_local_addr"] = r.nativeTcpGetLocalAddr
+	r.builtins["native_tcp_get_remote_addr"] = r.nativeTcpGetRemoteAddr
+	r.builtins["native_tcp_get_local_host"] = r.nativeTcpGetLocalHost
+	r.builtins["native_tcp_get_local_port"] = r.nativeTcpGetLocalPort
+	r.builtins["native_tcp_get_remote_host"] = r.nativeTcpGetRemoteHost
+	r.builtins["native_tcp_get_remote_port"] = r.nativeTcpGetRemotePort
+	r.builtins["native_tcp_is_tls"] = r.nativeTcpIsTLS
 
 	// Native TLS 函数 - SSL/TLS客户端支持
-	r.builtins["native_tls_connect"] = nativeTlsConnect
-	r.builtins["native_tls_connect_insecure"] = nativeTlsConnectInsecure
-	r.builtins["native_tls_upgrade"] = nativeTlsUpgrade
-	r.builtins["native_tls_get_version"] = nativeTlsGetVersion
-	r.builtins["native_tls_get_cipher_suite"] = nativeTlsGetCipherSuite
-	r.builtins["native_tls_get_server_name"] = nativeTlsGetServerName
+	r.builtins["native_tls_connect"] = r.nativeTlsConnect
+	r.builtins["native_tls_connect_insecure"] = r.nativeTlsConnectInsecure
+	r.builtins["native_tls_upgrade"] = r.nativeTlsUpgrade
+	r.builtins["native_tls_get_version"] = r.nativeTlsGetVersion
+	r.builtins["native_tls_get_cipher_suite"] = r.nativeTlsGetCipherSuite
+	r.builtins["native_tls_get_server_name"] = r.nativeTlsGetServerName
 
 	// Native TCP 函数 - 服务端监听
-	r.builtins["native_tcp_listen"] = nativeTcpListen
-	r.builtins["native_tcp_accept"] = nativeTcpAccept
-	r.builtins["native_tcp_accept_timeout"] = nativeTcpAcceptTimeout
-	r.builtins["native_tcp_stop_listen"] = nativeTcpStopListen
-	r.builtins["native_tcp_listener_addr"] = nativeTcpListenerAddr
-	r.builtins["native_tcp_listener_host"] = nativeTcpListenerHost
-	r.builtins["native_tcp_listener_port"] = nativeTcpListenerPort
-	r.builtins["native_tcp_listener_is_listening"] = nativeTcpListenerIsListening
+	r.builtins["native_tcp_listen"] = r.nativeTcpListen
+	r.builtins["native_tcp_accept"] = r.nativeTcpAccept
+	r.builtins["native_tcp_accept_timeout"] = r.nativeTcpAcceptTimeout
+	r.builtins["native_tcp_stop_listen"] = r.nativeTcpStopListen
+	r.builtins["native_tcp_listener_addr"] = r.nativeTcpListenerAddr
+	r.builtins["native_tcp_listener_host"] = r.nativeTcpListenerHost
+	r.builtins["native_tcp_listener_port"] = r.nativeTcpListenerPort
+	r.builtins["native_tcp_listener_is_listening"] = r.nativeTcpListenerIsListening
 
 	// Native TLS 函数 - SSL/TLS服务端支持
-	r.builtins["native_tls_listen"] = nativeTlsListen
-	r.builtins["native_tls_listener_is_tls"] = nativeTlsListenerIsTLS
+	r.builtins["native_tls_listen"] = r.nativeTlsListen
+	r.builtins["native_tls_listener_is_tls"] = r.nativeTlsListenerIsTLS
 
 	// Native 文件操作函数 (仅供标准库使用)
 	r.builtins["native_file_read"] = nativeFileRead
@@ -693,6 +730,21 @@ func (r *Runtime) registerBuiltins() {
 	r.builtins["native_stream_flush"] = r.nativeStreamFlush
 	r.builtins["native_stream_close"] = r.nativeStreamClose
 
+	// Native 句柄泄漏检测 (仅供标准库使用)
+	r.builtins["native_resource_track"] = r.nativeResourceTrack
+
+	// Native 弱引用函数 (仅供标准库使用)
+	r.builtins["native_weakref_new"] = nativeWeakRefNew
+	r.builtins["native_weakref_get"] = nativeWeakRefGet
+	r.builtins["native_weakmap_new"] = nativeWeakMapNew
+	r.builtins["native_weakmap_set"] = nativeWeakMapSet
+	r.builtins["native_weakmap_get"] = nativeWeakMapGet
+	r.builtins["native_weakmap_has"] = nativeWeakMapHas
+	r.builtins["native_weakmap_remove"] = nativeWeakMapRemove
+	r.builtins["native_weakmap_size"] = nativeWeakMapSize
+	r.builtins["native_weakmap_keys"] = nativeWeakMapKeys
+	r.builtins["native_weakmap_clear"] = nativeWeakMapClear
+
 	// Native 正则表达式函数 (仅供标准库使用)
 	r.builtins["native_regex_match"] = nativeRegexMatch
 	r.builtins["native_regex_find"] = nativeRegexFind
@@ -706,19 +758,21 @@ func (r *Runtime) registerBuiltins() {
 	r.builtins["native_regex_escape"] = nativeRegexEscape
 
 	// Native 时间函数 (仅供标准库使用)
-	r.builtins["native_time_now"] = nativeTimeNow
-	r.builtins["native_time_now_ms"] = nativeTimeNowMs
-	r.builtins["native_time_now_nano"] = nativeTimeNowNano
-	r.builtins["native_time_sleep"] = nativeTimeSleep
+	r.builtins["native_time_now"] = r.nativeTimeNow
+	r.builtins["native_time_now_ms"] = r.nativeTimeNowMs
+	r.builtins["native_time_now_nano"] = r.nativeTimeNowNano
+	r.builtins["native_time_sleep"] = func(args []bytecode.Value) bytecode.Value {
+		return r.timeSleep(args)
+	}
 	r.builtins["native_time_parse"] = nativeTimeParse
 	r.builtins["native_time_format"] = nativeTimeFormat
-	r.builtins["native_time_year"] = nativeTimeYear
-	r.builtins["native_time_month"] = nativeTimeMonth
-	r.builtins["native_time_day"] = nativeTimeDay
-	r.builtins["native_time_hour"] = nativeTimeHour
-	r.builtins["native_time_minute"] = nativeTimeMinute
-	r.builtins["native_time_second"] = nativeTimeSecond
-	r.builtins["native_time_weekday"] = nativeTimeWeekday
+	r.builtins["native_time_year"] = r.nativeTimeYear
+	r.builtins["native_time_month"] = r.nativeTimeMonth
+	r.builtins["native_time_day"] = r.nativeTimeDay
+	r.builtins["native_time_hour"] = r.nativeTimeHour
+	r.builtins["native_time_minute"] = r.nativeTimeMinute
+	r.builtins["native_time_second"] = r.nativeTimeSecond
+	r.builtins["native_time_weekday"] = r.nativeTimeWeekday
 	r.builtins["native_time_make"] = nativeTimeMake
 
 	// Native JSON 函数 (仅供标准库使用)
@@ -811,10 +865,10 @@ func (r *Runtime) registerBuiltins() {
 	r.builtins["native_crypto_argon2i"] = nativeCryptoArgon2i
 
 	// Native Crypto 随机数函数
-	r.builtins["native_crypto_random_bytes"] = nativeCryptoRandomBytes
-	r.builtins["native_crypto_random_int"] = nativeCryptoRandomInt
-	r.builtins["native_crypto_random_hex"] = nativeCryptoRandomHex
-	r.builtins["native_crypto_random_uuid"] = nativeCryptoRandomUuid
+	r.builtins["native_crypto_random_bytes"] = r.nativeCryptoRandomBytes
+	r.builtins["native_crypto_random_int"] = r.nativeCryptoRandomInt
+	r.builtins["native_crypto_random_hex"] = r.nativeCryptoRandomHex
+	r.builtins["native_crypto_random_uuid"] = r.nativeCryptoRandomUuid
 
 	// Native Crypto Hex函数
 	r.builtins["native_crypto_hex_encode"] = nativeCryptoHexEncode
@@ -882,33 +936,62 @@ func (r *Runtime) registerBuiltins() {
 		panic(msg)
 	}
 
-	// GC 控制函数 (暂时使用 stub 实现)
+	// GC 控制函数：VM 的内存统计 (见 vm.MemoryStats)，值由 Go 的垃圾回收器释放
+	// 沙箱未授予 GC 能力时 gc_collect 只重新统计本 VM 的存活字节数，不触发进程范围的 Go 垃圾回收
 	r.builtins["gc_collect"] = func(args []bytecode.Value) bytecode.Value {
-		// GC 尚未实现
-		return bytecode.NewInt(0)
+		if r.sandbox != nil && !r.sandbox.GC {
+			return bytecode.NewInt(int64(r.vm.Collect()))
+		}
+		return bytecode.NewInt(int64(r.vm.CollectAll()))
 	}
 	r.builtins["gc_enable"] = func(args []bytecode.Value) bytecode.Value {
-		// GC 尚未实现
+		r.vm.SetGCEnabled(true)
 		return bytecode.NullValue
 	}
 	r.builtins["gc_disable"] = func(args []bytecode.Value) bytecode.Value {
-		// GC 尚未实现
+		r.vm.SetGCEnabled(false)
 		return bytecode.NullValue
 	}
 	r.builtins["gc_stats"] = func(args []bytecode.Value) bytecode.Value {
-		// GC 尚未实现，返回空统计
-		m := make(map[bytecode.Value]bytecode.Value)
-		m[bytecode.NewString("heap_size")] = bytecode.NewInt(0)
-		m[bytecode.NewString("total_allocations")] = bytecode.NewInt(0)
-		m[bytecode.NewString("total_collections")] = bytecode.NewInt(0)
-		m[bytecode.NewString("total_freed")] = bytecode.NewInt(0)
-		m[bytecode.NewString("next_threshold")] = bytecode.NewInt(0)
-		return bytecode.NewMap(m)
+		return memoryStatsValue(r.vm.MemoryStats())
 	}
 	r.builtins["gc_set_threshold"] = func(args []bytecode.Value) bytecode.Value {
-		// GC 尚未实现
+		if len(args) > 0 && args[0].AsInt() >= 0 {
+			r.vm.SetGCThreshold(uint64(args[0].AsInt()))
+		}
 		return bytecode.NullValue
 	}
+
+	if r.sandbox != nil {
+		r.applySandbox()
+	}
+}
+
+// memoryStatsValue 把内存统计转换为 gc_stats() 返回的 Map
+// types 按值的种类给出分配次数 (count) 和估算字节数 (bytes)
+func memoryStatsValue(s vm.MemoryStats) bytecode.Value {
+	entry := func(m map[bytecode.Key]bytecode.Value, key string, n uint64) {
+		m[bytecode.MapKey(bytecode.NewString(key))] = bytecode.NewInt(int64(n))
+	}
+	types := make(map[bytecode.Key]bytecode.Value, len(s.ByKind))
+	for kind, a := range s.ByKind {
+		t := make(map[bytecode.Key]bytecode.Value, 2)
+		entry(t, "count", a.Count)
+		entry(t, "bytes", a.Bytes)
+		types[bytecode.MapKey(bytecode.NewString(vm.AllocKind(kind).String()))] = bytecode.NewMap(t)
+	}
+
+	m := make(map[bytecode.Key]bytecode.Value)
+	entry(m, "heap_size", s.HeapSize)
+	entry(m, "live_bytes", s.LiveBytes)
+	entry(m, "heap_limit", s.HeapLimit)
+	entry(m, "total_allocations", s.Allocations)
+	entry(m, "total_bytes", s.AllocatedBytes)
+	entry(m, "total_collections", s.Collections)
+	entry(m, "total_freed", s.FreedBytes)
+	entry(m, "next_threshold", s.NextThreshold)
+	m[bytecode.MapKey(bytecode.NewString("types"))] = bytecode.NewMap(types)
+	return bytecode.NewMap(m)
 }
 
 func (r *Runtime) registerBuiltinsToVM() {
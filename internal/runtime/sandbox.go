@@ -0,0 +1,285 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 沙箱
+// ============================================================================
+//
+// Sandbox 是原生函数的能力策略。设置后，访问文件系统、网络、反射和 native_panic
+// 的原生函数在执行前检查策略，未被授予的访问抛出 SecurityException：
+//   - 文件系统：路径必须位于某个授予了相应权限 (读/写) 的根目录之下，
+//     路径中的符号链接先解析再比较，不能借助链接逃出根目录
+//   - 网络：连接和监听的 host:port 必须在允许列表中
+//   - 反射：native_reflect_* 和注解查询函数
+//   - GC：未授予时 gc_collect 不抛出异常，但只重新统计 VM 的存活字节数，
+//     不运行暂停整个进程的 Go 垃圾回收 (弱引用因此不会立即失效)
+//
+// 读写句柄的函数不再检查：句柄表属于各个运行时，句柄是随机生成的，运行时只能使用
+// 它自己通过受检查的函数打开的连接和流，池中的其他运行时和宿主持有的句柄不可见。
+// 未设置沙箱 (nil) 时不做任何限制。
+
+// Sandbox 原生函数的能力策略，未授予的能力一律拒绝
+type Sandbox struct {
+	FileSystem []FSRoot `json:"filesystem"` // 可访问的文件系统根目录
+	Network    []string `json:"network"`    // 允许连接和监听的地址 "host:port"，host 或 port 可为 "*"，"*" 允许全部
+	Reflection bool     `json:"reflection"` // 允许反射和注解查询
+	Panic      bool     `json:"panic"`      // 允许 native_panic
+	GC         bool     `json:"gc"`         // 允许 gc_collect 运行进程范围的 Go 垃圾回收
+}
+
+// FSRoot 文件系统根目录及其权限
+type FSRoot struct {
+	Path  string `json:"path"`
+	Read  bool   `json:"read"`
+	Write bool   `json:"write"`
+}
+
+// LoadSandbox 从 JSON 文件读取沙箱策略，相对路径的根目录相对于策略文件所在目录
+func LoadSandbox(filename string) (*Sandbox, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var s Sandbox
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for i := range s.FileSystem {
+		if p := s.FileSystem[i].Path; !filepath.IsAbs(p) {
+			s.FileSystem[i].Path = filepath.Join(filepath.Dir(filename), p)
+		}
+	}
+	return &s, nil
+}
+
+// 文件系统访问方式
+const (
+	accessRead  = 1 << iota // 读取内容或元数据
+	accessWrite             // 创建、修改、删除
+)
+
+// fsArg 原生函数中的路径参数
+type fsArg struct {
+	index  int // 参数位置
+	access int
+}
+
+// fsNatives 访问文件系统的原生函数及其路径参数
+var fsNatives = map[string][]fsArg{
+	"native_file_read":      {{0, accessRead}},
+	"native_file_write":     {{0, accessWrite}},
+	"native_file_append":    {{0, accessWrite}},
+	"native_file_exists":    {{0, accessRead}},
+	"native_file_delete":    {{0, accessWrite}},
+	"native_file_copy":      {{0, accessRead}, {1, accessWrite}},
+	"native_file_rename":    {{0, accessWrite}, {1, accessWrite}},
+	"native_is_file":        {{0, accessRead}},
+	"native_dir_create":     {{0, accessWrite}},
+	"native_dir_create_all": {{0, accessWrite}},
+	"native_dir_delete":     {{0, accessWrite}},
+	"native_dir_delete_all": {{0, accessWrite}},
+	"native_dir_list":       {{0, accessRead}},
+	"native_is_dir":         {{0, accessRead}},
+	"native_file_size":      {{0, accessRead}},
+	"native_file_mtime":     {{0, accessRead}},
+	"native_file_atime":     {{0, accessRead}},
+	"native_file_ctime":     {{0, accessRead}},
+	"native_file_perms":     {{0, accessRead}},
+	"native_is_readable":    {{0, accessRead}},
+	"native_is_writable":    {{0, accessRead}},
+	"native_is_executable":  {{0, accessRead}},
+	"native_is_link":        {{0, accessRead}},
+	"native_tls_listen":     {{2, accessRead}, {3, accessRead}}, // 证书和私钥文件
+}
+
+// netNatives 建立连接或监听端口的原生函数，参数 0、1 为 host、port
+var netNatives = []string{
+	"native_tcp_connect",
+	"native_tcp_connect_timeout",
+	"native_tls_connect",
+	"native_tls_connect_insecure",
+	"native_tcp_listen",
+	"native_tls_listen",
+}
+
+// reflectionNatives 反射和注解查询函数 (另有 native_reflect_* 前缀的全部函数)
+var reflectionNatives = []string{
+	"get_class_annotations",
+	"get_method_annotations",
+	"has_annotation",
+}
+
+// applySandbox 为受策略约束的原生函数加上检查
+func (r *Runtime) applySandbox() {
+	s := r.sandbox
+	for name, params := range fsNatives {
+		r.guard(name, func(args []bytecode.Value) error {
+			for _, p := range params {
+				if p.index < len(args) {
+					if err := s.checkPath(args[p.index].AsString(), p.access); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+	r.guard("native_stream_open", func(args []bytecode.Value) error {
+		if len(args) < 2 {
+			return nil
+		}
+		return s.checkPath(args[0].AsString(), streamAccess(args[1].AsString()))
+	})
+	for _, name := range netNatives {
+		r.guard(name, func(args []bytecode.Value) error {
+			if len(args) < 2 {
+				return nil
+			}
+			return s.checkAddr(args[0].AsString(), args[1].AsInt())
+		})
+	}
+
+	denyReflection := func([]bytecode.Value) error {
+		if !s.Reflection {
+			return fmt.Errorf("reflection is not allowed by the sandbox policy")
+		}
+		return nil
+	}
+	for name := range r.builtins {
+		if strings.HasPrefix(name, "native_reflect_") {
+			r.guard(name, denyReflection)
+		}
+	}
+	for _, name := range reflectionNatives {
+		r.guard(name, denyReflection)
+	}
+	r.guard("native_panic", func([]bytecode.Value) error {
+		if !s.Panic {
+			return fmt.Errorf("not allowed by the sandbox policy")
+		}
+		return nil
+	})
+}
+
+// guard 在内置函数执行前运行 check，check 返回错误时抛出 SecurityException
+func (r *Runtime) guard(name string, check func(args []bytecode.Value) error) {
+	fn, ok := r.builtins[name]
+	if !ok {
+		return
+	}
+	r.builtins[name] = func(args []bytecode.Value) bytecode.Value {
+		if err := check(args); err != nil {
+			return bytecode.NewException("SecurityException", name+": "+err.Error(), 0)
+		}
+		return fn(args)
+	}
+}
+
+// streamAccess 返回打开模式需要的访问方式
+func streamAccess(mode string) int {
+	switch mode {
+	case "r":
+		return accessRead
+	case "r+", "w+", "a+":
+		return accessRead | accessWrite
+	}
+	return accessWrite
+}
+
+// checkPath 检查路径是否位于授予了 access 权限的根目录之下
+func (s *Sandbox) checkPath(path string, access int) error {
+	resolved, err := resolvePath(path)
+	if err == nil {
+		for _, root := range s.FileSystem {
+			if (access&accessRead != 0 && !root.Read) || (access&accessWrite != 0 && !root.Write) {
+				continue
+			}
+			rootPath, err := resolvePath(root.Path)
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(rootPath, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil
+			}
+		}
+	}
+	kind := "read"
+	switch access {
+	case accessWrite:
+		kind = "write"
+	case accessRead | accessWrite:
+		kind = "read/write"
+	}
+	return fmt.Errorf("%s access to %q denied", kind, path)
+}
+
+// resolvePath 返回解析了符号链接的绝对路径
+// 路径不做词法上的化简 ("link/.." 按链接目标的上级目录解析)；路径的后段尚不存在时
+// 解析已存在的最长前缀，其余部分原样拼接，不存在的部分不能含有 ".."
+func resolvePath(path string) (string, error) {
+	sep := string(filepath.Separator)
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path = wd + sep + path
+	}
+	dir, rest := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		dir = strings.TrimRight(dir, sep)
+		i := strings.LastIndex(dir, sep)
+		if i < 0 {
+			return "", err
+		}
+		name := dir[i+1:]
+		if name == ".." {
+			return "", fmt.Errorf("%s: cannot resolve %q", path, name)
+		}
+		rest = filepath.Join(name, rest)
+		dir = dir[:i+1]
+	}
+}
+
+// checkAddr 检查 host:port 是否在网络允许列表中
+func (s *Sandbox) checkAddr(host string, port int64) error {
+	for _, allowed := range s.Network {
+		if allowed == "*" {
+			return nil
+		}
+		i := strings.LastIndex(allowed, ":") // IPv6 地址本身含有冒号，以最后一个冒号分隔端口
+		if i < 0 {
+			continue
+		}
+		h, p := strings.Trim(allowed[:i], "[]"), allowed[i+1:]
+		if (h == "*" || strings.EqualFold(h, host)) && (p == "*" || p == strconv.FormatInt(port, 10)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("network access to %s denied", netAddr(host, port))
+}
+
+// netAddr 格式化 host:port
+func netAddr(host string, port int64) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]:" + strconv.FormatInt(port, 10)
+	}
+	return host + ":" + strconv.FormatInt(port, 10)
+}
@@ -189,6 +189,7 @@ const (
 	CATCH   // catch
 	FINALLY // finally
 	THROW   // throw
+	USING   // using (自动关闭资源)
 
 	// ----------------------------------------------------------
 	// 关键字 - 其他
@@ -361,6 +362,7 @@ var tokenNames = map[TokenType]string{
 	CATCH:   "catch",
 	FINALLY: "finally",
 	THROW:   "throw",
+	USING:   "using",
 
 	// 其他关键字
 	NEW:       "new",
@@ -459,6 +461,7 @@ var keywords = map[string]TokenType{
 	"catch":   CATCH,
 	"finally": FINALLY,
 	"throw":   THROW,
+	"using":   USING,
 
 	// 其他关键字
 	"new":       NEW,
@@ -584,7 +587,7 @@ func LookupIdent(ident string) TokenType {
 		}
 
 	case 5:
-		// 五字符关键字：while, break, catch, throw, class, const, final, float, false, match
+		// 五字符关键字：while, break, catch, throw, using, class, const, final, float, false, match
 		// 注意：value、where 是上下文关键字，不在这里匹配
 		switch ident {
 		case "while":
@@ -595,6 +598,8 @@ func LookupIdent(ident string) TokenType {
 			return CATCH
 		case "throw":
 			return THROW
+		case "using":
+			return USING
 		case "class":
 			return CLASS
 		case "const":
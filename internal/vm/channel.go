@@ -0,0 +1,530 @@
+package vm
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 通道
+// ============================================================================
+//
+// 通道在单线程调度器上实现，不需要锁。阻塞的协程以 chanWaiter 登记在通道的
+// 发送/接收队列中；另一端到达时直接把值交给等待者并唤醒它。
+//
+// 与 await 一样，阻塞的指令在恢复后重新执行。协程的 wait 记录了唤醒结果，
+// 重新执行时直接取结果而不再操作通道。select 为每个 case 登记一个等待者，
+// 它们共享同一个 selectGroup，先被触发的 case 生效，其余等待者随之失效。
+
+// Channel 通道对象
+type Channel struct {
+	capacity int
+	buffer   []bytecode.Value
+	closed   bool
+	recvq    []*chanWaiter
+	sendq    []*chanWaiter
+}
+
+// selectGroup 一次阻塞等待 (send/receive/select) 的结果
+type selectGroup struct {
+	fired  bool           // 已被唤醒
+	index  int            // 生效的 case
+	value  bytecode.Value // 接收到的值
+	closed bool           // 因通道关闭而唤醒
+}
+
+// chanWaiter 通道等待队列中的一项
+type chanWaiter struct {
+	co    *coroutine
+	group *selectGroup
+	index int            // 在 select 中的 case 序号
+	value bytecode.Value // 待发送的值
+}
+
+// selectCase select 的一个分支
+type selectCase struct {
+	ch     *Channel
+	isRecv bool
+	value  bytecode.Value // 发送的值
+	offset int            // 分支体相对 select 表末尾的偏移
+}
+
+// NewChannel 创建通道，capacity 为 0 时为无缓冲通道
+func NewChannel(capacity int) *Channel {
+	return &Channel{capacity: capacity}
+}
+
+// Len 缓冲区中的元素数量
+func (ch *Channel) Len() int {
+	return len(ch.buffer)
+}
+
+// Cap 缓冲区容量
+func (ch *Channel) Cap() int {
+	return ch.capacity
+}
+
+// IsClosed 是否已关闭
+func (ch *Channel) IsClosed() bool {
+	return ch.closed
+}
+
+// alive 等待者是否仍然有效 (所在的 select 未被其他 case 触发，协程未结束)
+func (w *chanWaiter) alive() bool {
+	return !w.group.fired && w.co.state != coDone
+}
+
+// dequeue 取出队列中第一个有效的等待者
+func dequeue(q *[]*chanWaiter) *chanWaiter {
+	for len(*q) > 0 {
+		w := (*q)[0]
+		(*q)[0] = nil
+		*q = (*q)[1:]
+		if w.alive() {
+			return w
+		}
+	}
+	return nil
+}
+
+// hasWaiter 队列中是否有有效的等待者 (顺带清理失效项)
+func hasWaiter(q *[]*chanWaiter) bool {
+	for len(*q) > 0 {
+		if (*q)[0].alive() {
+			return true
+		}
+		(*q)[0] = nil
+		*q = (*q)[1:]
+	}
+	return false
+}
+
+// canRecv 接收是否可以立即完成 (包括通道已关闭)
+func (ch *Channel) canRecv() bool {
+	return len(ch.buffer) > 0 || ch.closed || hasWaiter(&ch.sendq)
+}
+
+// canSend 发送是否可以立即完成 (包括通道已关闭)
+func (ch *Channel) canSend() bool {
+	return ch.closed || len(ch.buffer) < ch.capacity || hasWaiter(&ch.recvq)
+}
+
+// ============================================================================
+// 非阻塞操作
+// ============================================================================
+
+// tryRecv 尝试接收，ok 为 false 时 closed 表示通道已关闭且为空
+func (vm *VM) tryRecv(ch *Channel) (v bytecode.Value, ok bool, closed bool) {
+	if len(ch.buffer) > 0 {
+		v = ch.buffer[0]
+		ch.buffer[0] = bytecode.NullValue
+		ch.buffer = ch.buffer[1:]
+		// 缓冲区腾出空间，接纳一个阻塞的发送者
+		if s := dequeue(&ch.sendq); s != nil {
+			ch.buffer = append(ch.buffer, s.value)
+			vm.wakeWaiter(s, bytecode.NullValue, false)
+		}
+		return v, true, false
+	}
+	if s := dequeue(&ch.sendq); s != nil {
+		vm.wakeWaiter(s, bytecode.NullValue, false)
+		return s.value, true, false
+	}
+	return bytecode.NullValue, false, ch.closed
+}
+
+// trySend 尝试发送，ok 为 false 时 closed 表示通道已关闭
+func (vm *VM) trySend(ch *Channel, v bytecode.Value) (ok bool, closed bool) {
+	if ch.closed {
+		return false, true
+	}
+	if r := dequeue(&ch.recvq); r != nil {
+		vm.wakeWaiter(r, v, false)
+		return true, false
+	}
+	if len(ch.buffer) < ch.capacity {
+		ch.buffer = append(ch.buffer, v)
+		return true, false
+	}
+	return false, false
+}
+
+// closeChannel 关闭通道，唤醒所有等待者
+func (vm *VM) closeChannel(ch *Channel) bool {
+	if ch.closed {
+		return false
+	}
+	ch.closed = true
+	for r := dequeue(&ch.recvq); r != nil; r = dequeue(&ch.recvq) {
+		vm.wakeWaiter(r, bytecode.NullValue, true)
+	}
+	for s := dequeue(&ch.sendq); s != nil; s = dequeue(&ch.sendq) {
+		vm.wakeWaiter(s, bytecode.NullValue, true)
+	}
+	return true
+}
+
+// wakeWaiter 完成等待者所在的等待并唤醒其协程
+func (vm *VM) wakeWaiter(w *chanWaiter, v bytecode.Value, closed bool) {
+	g := w.group
+	g.fired = true
+	g.index = w.index
+	g.value = v
+	g.closed = closed
+	if w.co.state == coWaiting {
+		vm.makeReady(w.co)
+	}
+}
+
+// ============================================================================
+// 阻塞
+// ============================================================================
+
+// resumed 当前协程是否刚从通道等待中被唤醒，是则取出等待结果
+func (vm *VM) resumed() *selectGroup {
+	cur := vm.sched.current
+	if cur == nil || cur.wait == nil || !cur.wait.fired {
+		return nil
+	}
+	g := cur.wait
+	cur.wait = nil
+	return g
+}
+
+// park 在 cases 涉及的通道上登记等待并挂起当前协程
+// 指令指针回退到 opStart，唤醒后重新执行该指令
+func (vm *VM) park(opStart int, reason string, cases []selectCase) {
+	s := vm.ensureScheduler()
+	cur := s.current
+
+	g := &selectGroup{}
+	cur.wait = g
+	cur.waitCases = cases
+	for i, c := range cases {
+		w := &chanWaiter{co: cur, group: g, index: i, value: c.value}
+		if c.isRecv {
+			c.ch.recvq = append(c.ch.recvq, w)
+		} else {
+			c.ch.sendq = append(c.ch.sendq, w)
+		}
+	}
+	vm.currentFrame().ip = opStart
+	vm.block(reason, func() bool { return g.fired })
+}
+
+// block 挂起当前协程直到 done 返回 true
+// 嵌套执行中无法挂起，就地运行其他协程；没有协程能继续时报告死锁
+func (vm *VM) block(reason string, done func() bool) {
+	cur := vm.sched.current
+	cur.waitReason = reason
+	if vm.canSwitch() {
+		cur.state = coWaiting
+		vm.suspend()
+		return
+	}
+	if !vm.driveUntil(done, time.Time{}) && !vm.hasError {
+		vm.deadlock()
+		return
+	}
+	cur.waitReason = ""
+	cur.waitCases = nil
+}
+
+// ============================================================================
+// 死锁检测
+// ============================================================================
+
+// deadlock 所有协程都在等待且没有定时器，终止执行并报告每个阻塞协程的位置
+func (vm *VM) deadlock() {
+	if vm.hookEvents&HookDeadlock != 0 {
+		vm.hookDeadlock()
+	}
+
+	s := &vm.sched
+	var blocked []*coroutine
+	if s.main != nil && s.main.waitReason != "" {
+		blocked = append(blocked, s.main)
+	}
+	for _, co := range s.live {
+		if co.kind == coTask && co.state != coDone && co.waitReason != "" {
+			blocked = append(blocked, co)
+		}
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].obj.ID < blocked[j].obj.ID })
+
+	var sb strings.Builder
+	sb.WriteString("all coroutines are asleep - deadlock!")
+	for _, co := range blocked {
+		sb.WriteString("\n\ncoroutine ")
+		sb.WriteString(bytecode.NewInt(co.obj.ID).String())
+		if co == s.main {
+			sb.WriteString(" (main)")
+		}
+		sb.WriteString(" [" + co.waitReason + "]:\n")
+
+		frames, fp := co.frames, co.fp
+		if co == s.current {
+			frames, fp = vm.frames, vm.fp
+		}
+		sb.WriteString(formatStackTrace(stackTrace(frames, fp)))
+	}
+
+	vm.hasError = true
+	vm.errorMsg = sb.String()
+}
+
+// ============================================================================
+// 通道操作码
+// ============================================================================
+
+// channelArg 从操作数中取通道，失败时报告运行时错误
+func (vm *VM) channelArg(v bytecode.Value, op string) *Channel {
+	if ch, ok := v.AsChannel().(*Channel); ok && ch != nil {
+		return ch
+	}
+	vm.runtimeError("%s: expected channel, got %s", op, v.String())
+	return nil
+}
+
+// opChanMake 创建通道 [capacity -> channel]
+func opChanMake(vm *VM) {
+	capacity := vm.pop().AsInt()
+	if capacity < 0 {
+		vm.throwError("ArgumentException", "channel capacity must not be negative: %d", capacity)
+		return
+	}
+	vm.push(bytecode.NewChannelValue(NewChannel(int(capacity))))
+}
+
+// opChanSend 发送，缓冲区满或无接收者时阻塞 [channel, value -> ]
+func opChanSend(vm *VM) {
+	opStart := vm.currentFrame().ip - 1
+	ch := vm.channelArg(vm.peek(1), "send")
+	if ch == nil {
+		return
+	}
+
+	if g := vm.resumed(); g != nil {
+		vm.popN(2)
+		if g.closed {
+			vm.throwError("ChannelClosedException", "send on closed channel")
+		}
+		return
+	}
+
+	value := vm.peek(0)
+	ok, closed := vm.trySend(ch, value)
+	switch {
+	case ok:
+		vm.popN(2)
+	case closed:
+		vm.popN(2)
+		vm.throwError("ChannelClosedException", "send on closed channel")
+	default:
+		vm.park(opStart, "chan send", []selectCase{{ch: ch, value: value}})
+	}
+}
+
+// opChanRecv 接收，通道为空时阻塞 [channel -> value]
+func opChanRecv(vm *VM) {
+	opStart := vm.currentFrame().ip - 1
+	ch := vm.channelArg(vm.peek(0), "receive")
+	if ch == nil {
+		return
+	}
+
+	if g := vm.resumed(); g != nil {
+		vm.pop()
+		if g.closed {
+			vm.throwError("ChannelClosedException", "receive from closed channel")
+			return
+		}
+		vm.push(g.value)
+		return
+	}
+
+	v, ok, closed := vm.tryRecv(ch)
+	switch {
+	case ok:
+		vm.pop()
+		vm.push(v)
+	case closed:
+		vm.pop()
+		vm.throwError("ChannelClosedException", "receive from closed channel")
+	default:
+		vm.park(opStart, "chan receive", []selectCase{{ch: ch, isRecv: true}})
+	}
+}
+
+// opChanTrySend 非阻塞发送 [channel, value -> bool]
+// 通道已关闭时返回 false
+func opChanTrySend(vm *VM) {
+	value := vm.pop()
+	ch := vm.channelArg(vm.pop(), "trySend")
+	if ch == nil {
+		return
+	}
+	ok, _ := vm.trySend(ch, value)
+	vm.push(bytecode.NewBool(ok))
+}
+
+// opChanTryRecv 非阻塞接收，没有数据时返回 null [channel -> value|null]
+func opChanTryRecv(vm *VM) {
+	ch := vm.channelArg(vm.pop(), "tryReceive")
+	if ch == nil {
+		return
+	}
+	v, _, _ := vm.tryRecv(ch)
+	vm.push(v)
+}
+
+// opChanClose 关闭通道 [channel -> ]
+func opChanClose(vm *VM) {
+	ch := vm.channelArg(vm.pop(), "close")
+	if ch == nil {
+		return
+	}
+	if !vm.closeChannel(ch) {
+		vm.throwError("ChannelClosedException", "close of closed channel")
+	}
+}
+
+// opChanLen 缓冲区中的元素数量 [channel -> int]
+func opChanLen(vm *VM) {
+	if ch := vm.channelArg(vm.pop(), "length"); ch != nil {
+		vm.push(bytecode.NewInt(int64(ch.Len())))
+	}
+}
+
+// opChanCap 缓冲区容量 [channel -> int]
+func opChanCap(vm *VM) {
+	if ch := vm.channelArg(vm.pop(), "capacity"); ch != nil {
+		vm.push(bytecode.NewInt(int64(ch.Cap())))
+	}
+}
+
+// opChanIsClosed 是否已关闭 [channel -> bool]
+func opChanIsClosed(vm *VM) {
+	if ch := vm.channelArg(vm.pop(), "isClosed"); ch != nil {
+		vm.push(bytecode.NewBool(ch.IsClosed()))
+	}
+}
+
+// ============================================================================
+// select
+// ============================================================================
+
+// opSelectStart 执行 select 语句
+//
+// 格式:
+//
+//	<各 case 的操作数: 接收为 channel，发送为 channel, value>
+//	OpSelectStart caseCount:u8
+//	OpSelectCase isRecv:u8 offset:i16   (每个 case 一条)
+//	OpSelectDefault offset:i16          (可选)
+//	OpSelectWait
+//
+// OpSelectCase/OpSelectDefault/OpSelectWait 是 select 表，不单独执行；
+// offset 相对 OpSelectWait 之后的位置。选中接收分支时压入接收到的值。
+// 多个 case 同时就绪时随机选择，没有就绪的 case 时执行 default 或阻塞。
+func opSelectStart(vm *VM) {
+	frame := vm.currentFrame()
+	opStart := frame.ip - 1
+	code := frame.chunk.Code
+
+	count := int(vm.readByte())
+	cases := make([]selectCase, count)
+	operands := 0
+	for i := range cases {
+		if bytecode.OpCode(code[frame.ip]) != bytecode.OpSelectCase {
+			vm.runtimeError("malformed select table")
+			return
+		}
+		cases[i].isRecv = code[frame.ip+1] == 1
+		cases[i].offset = int(int16(vm.readShortAt(frame, frame.ip+2)))
+		frame.ip += 4
+		if cases[i].isRecv {
+			operands++
+		} else {
+			operands += 2
+		}
+	}
+	defaultOffset := -1
+	if bytecode.OpCode(code[frame.ip]) == bytecode.OpSelectDefault {
+		defaultOffset = int(int16(vm.readShortAt(frame, frame.ip+1)))
+		frame.ip += 3
+	}
+	frame.ip++ // OpSelectWait
+	tableEnd := frame.ip
+
+	// 从栈上取各 case 的通道和发送值
+	base := vm.sp - operands
+	slot := base
+	for i := range cases {
+		ch := vm.channelArg(vm.stack[slot], "select")
+		if ch == nil {
+			return
+		}
+		cases[i].ch = ch
+		slot++
+		if !cases[i].isRecv {
+			cases[i].value = vm.stack[slot]
+			slot++
+		}
+	}
+
+	finish := func(i int, v bytecode.Value, closed bool) {
+		vm.sp = base
+		frame.ip = tableEnd + cases[i].offset
+		switch {
+		case closed && cases[i].isRecv:
+			vm.throwError("ChannelClosedException", "receive from closed channel")
+		case closed:
+			vm.throwError("ChannelClosedException", "send on closed channel")
+		case cases[i].isRecv:
+			vm.push(v)
+		}
+	}
+
+	if g := vm.resumed(); g != nil {
+		finish(g.index, g.value, g.closed)
+		return
+	}
+
+	// 收集就绪的 case，随机选择一个
+	var ready []int
+	for i, c := range cases {
+		if (c.isRecv && c.ch.canRecv()) || (!c.isRecv && c.ch.canSend()) {
+			ready = append(ready, i)
+		}
+	}
+	if len(ready) > 0 {
+		i := ready[vm.sched.random(len(ready))]
+		c := cases[i]
+		if c.isRecv {
+			v, _, closed := vm.tryRecv(c.ch)
+			finish(i, v, closed)
+		} else {
+			_, closed := vm.trySend(c.ch, c.value)
+			finish(i, bytecode.NullValue, closed)
+		}
+		return
+	}
+
+	if defaultOffset >= 0 {
+		vm.sp = base
+		frame.ip = tableEnd + defaultOffset
+		return
+	}
+	if count == 0 {
+		// 空 select 永远阻塞
+		vm.ensureScheduler()
+		frame.ip = opStart
+		vm.block("select (no cases)", func() bool { return false })
+		return
+	}
+	vm.park(opStart, "select", cases)
+}
@@ -0,0 +1,438 @@
+package vm
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 类型化容器
+// ============================================================================
+//
+// 编译器根据静态类型为 map[K]V、byte[] 和原生定长数组生成专用指令，
+// 直接操作 bytecode 中对应的值表示，避免通用索引指令的类型分派：
+//
+//   map[K]V      -> OpMapGet / OpMapSet / OpMapHas / OpMapLen
+//   byte[]       -> OpBytesGet / OpBytesSet / OpBytesLen / OpBytesSlice / OpBytesConcat
+//   int[N] 等    -> OpNativeArrayGet / OpNativeArraySet / OpNativeArrayLen
+//
+// 静态类型相同的值在运行时可能仍是旧的表示 (例如 int{1, 2} 创建的 Value 数组)，
+// 专用指令遇到这种情况时回退到通用的 OpArrayGet/OpArraySet/OpArrayLen。
+
+// throwIndexOutOfBounds 抛出数组越界异常
+func (vm *VM) throwIndexOutOfBounds(index int64, length int) {
+	vm.throwError("ArrayIndexOutOfBoundsException", "index %d out of bounds for length %d", index, length)
+}
+
+// elementTypeName 返回值类型在错误信息中的名称
+func elementTypeName(t bytecode.ValueType) string {
+	switch t {
+	case bytecode.ValNull:
+		return "null"
+	case bytecode.ValBool:
+		return "bool"
+	case bytecode.ValInt:
+		return "int"
+	case bytecode.ValFloat:
+		return "float"
+	case bytecode.ValString:
+		return "string"
+	case bytecode.ValObject:
+		return "object"
+	case bytecode.ValBytes:
+		return "byte[]"
+	case bytecode.ValMap:
+		return "map"
+	case bytecode.ValSuperArray:
+		return "SuperArray"
+	default:
+		return "array"
+	}
+}
+
+// coerceElement 将值转换为类型化数组的元素类型
+// int 与 float 之间按数值转换，其余类型必须精确匹配，失败时抛出 InvalidCastException
+func (vm *VM) coerceElement(elemType bytecode.ValueType, v bytecode.Value) (bytecode.Value, bool) {
+	t := v.Type()
+	switch elemType {
+	case bytecode.ValInt:
+		switch t {
+		case bytecode.ValInt:
+			return v, true
+		case bytecode.ValFloat:
+			return bytecode.NewInt(int64(v.AsFloat())), true
+		}
+	case bytecode.ValFloat:
+		switch t {
+		case bytecode.ValFloat:
+			return v, true
+		case bytecode.ValInt:
+			return bytecode.NewFloat(float64(v.AsInt())), true
+		}
+	case bytecode.ValBool, bytecode.ValString:
+		if t == elemType {
+			return v, true
+		}
+	case bytecode.ValObject:
+		if t == bytecode.ValObject || t == bytecode.ValNull {
+			return v, true
+		}
+	default:
+		return v, true
+	}
+	vm.throwError("InvalidCastException", "cannot store %s in %s[]", elementTypeName(t), elementTypeName(elemType))
+	return bytecode.NullValue, false
+}
+
+// ============================================================================
+// 原生定长数组
+// ============================================================================
+
+// opNewFixedArray 创建定长数组
+// 字节码格式: OpNewFixedArray + capacity(u16) + initLength(u16)
+func opNewFixedArray(vm *VM) {
+	capacity := int(vm.readShort())
+	initLen := int(vm.readShort())
+
+	elements := make([]bytecode.Value, initLen)
+	for i := initLen - 1; i >= 0; i-- {
+		elements[i] = vm.pop()
+	}
+	if !vm.allocate(AllocArray, sizeOfArray(capacity)) {
+		return
+	}
+	vm.push(bytecode.NewFixedArrayWithElements(elements, capacity))
+}
+
+// opNativeArrayNew 创建元素为默认值的原生数组
+// 字节码格式: OpNativeArrayNew + elemType(u8) + length(u16)
+func opNativeArrayNew(vm *VM) {
+	elemType := bytecode.ValueType(vm.readByte())
+	length := int(vm.readShort())
+	if !vm.allocate(AllocArray, sliceHeader+length*bytecode.NativeArrayElementSize) {
+		return
+	}
+	vm.push(bytecode.NewNativeArrayValue(bytecode.NewNativeArray(elemType, length)))
+}
+
+// opNativeArrayInit 用栈上的元素创建原生数组
+// 字节码格式: OpNativeArrayInit + elemType(u8) + count(u16)
+func opNativeArrayInit(vm *VM) {
+	elemType := bytecode.ValueType(vm.readByte())
+	count := int(vm.readShort())
+
+	arr := bytecode.NewNativeArray(elemType, count)
+	base := vm.sp - count
+	for i := 0; i < count; i++ {
+		v, ok := vm.coerceElement(elemType, vm.stack[base+i])
+		if !ok {
+			return
+		}
+		arr.Set(i, v)
+	}
+	vm.sp = base
+	if !vm.allocate(AllocArray, sliceHeader+count*bytecode.NativeArrayElementSize) {
+		return
+	}
+	vm.push(bytecode.NewNativeArrayValue(arr))
+}
+
+// opNativeArrayGet 读取原生数组元素
+// 栈: [arr, idx] -> [value]
+func opNativeArrayGet(vm *VM) {
+	arr := vm.peek(1).AsNativeArray()
+	if arr == nil {
+		opArrayGet(vm)
+		return
+	}
+	idx := vm.pop().AsInt()
+	if idx < 0 || idx >= int64(arr.Length) {
+		vm.throwIndexOutOfBounds(idx, arr.Len())
+		return
+	}
+	vm.stack[vm.sp-1] = arr.Get(int(idx))
+}
+
+// opNativeArraySet 写入原生数组元素，值按元素类型转换
+// 栈: [arr, idx, value] -> [value]
+func opNativeArraySet(vm *VM) {
+	arr := vm.peek(2).AsNativeArray()
+	if arr == nil {
+		opArraySet(vm)
+		return
+	}
+	idx := vm.peek(1).AsInt()
+	if idx < 0 || idx >= int64(arr.Length) {
+		vm.throwIndexOutOfBounds(idx, arr.Len())
+		return
+	}
+	val, ok := vm.coerceElement(arr.ElementType, vm.peek(0))
+	if !ok {
+		return
+	}
+	arr.Set(int(idx), val)
+	vm.sp -= 3
+	vm.push(val)
+}
+
+// opNativeArrayLen 原生数组长度
+// 栈: [arr] -> [length]
+func opNativeArrayLen(vm *VM) {
+	arr := vm.peek(0).AsNativeArray()
+	if arr == nil {
+		opArrayLen(vm)
+		return
+	}
+	vm.stack[vm.sp-1] = bytecode.NewInt(int64(arr.Length))
+}
+
+// opArrayGetUnchecked 在已验证边界的循环中读取数组元素
+// 只跳过 VM 层面的检查，越界访问仍由宿主语言保证安全
+func opArrayGetUnchecked(vm *VM) {
+	idx := vm.peek(0).AsInt()
+	switch container := vm.peek(1); container.Type() {
+	case bytecode.ValArray:
+		vm.sp--
+		vm.stack[vm.sp-1] = container.AsArray()[idx]
+	case bytecode.ValNativeArray:
+		vm.sp--
+		vm.stack[vm.sp-1] = container.AsNativeArray().Get(int(idx))
+	default:
+		opArrayGet(vm)
+	}
+}
+
+// opArraySetUnchecked 在已验证边界的循环中写入数组元素
+func opArraySetUnchecked(vm *VM) {
+	if container := vm.peek(2); container.Type() == bytecode.ValArray {
+		val := vm.peek(0)
+		container.AsArray()[vm.peek(1).AsInt()] = val
+		vm.sp -= 3
+		vm.push(val)
+		return
+	}
+	opArraySet(vm)
+}
+
+// opArrayPush 向数组末尾追加元素，返回新长度
+// 栈: [arr, value] -> [length]
+func opArrayPush(vm *VM) {
+	val := vm.pop()
+	container := vm.pop()
+
+	switch container.Type() {
+	case bytecode.ValArray:
+		if !vm.grow(valueSize) {
+			return
+		}
+		vm.push(bytecode.NewInt(int64(container.AppendArray(val))))
+	case bytecode.ValSuperArray:
+		if !vm.grow(2 * valueSize) {
+			return
+		}
+		sa := container.AsSuperArray()
+		sa.Push(val)
+		vm.push(bytecode.NewInt(int64(sa.Len())))
+	case bytecode.ValNativeArray, bytecode.ValFixedArray:
+		vm.throwError("InvalidOperationException", "cannot push to a fixed-length array")
+	default:
+		vm.runtimeError("cannot push to %s", elementTypeName(container.Type()))
+	}
+}
+
+// opArrayHas 检查数组索引是否有效
+// 栈: [arr, idx] -> [bool]
+func opArrayHas(vm *VM) {
+	opMapHas(vm)
+}
+
+// ============================================================================
+// Map
+// ============================================================================
+
+// opNewMap 从栈上的键值对创建 Map
+// 字节码格式: OpNewMap + count(u16)
+// 栈: [k1, v1, ..., kn, vn] -> [map]
+func opNewMap(vm *VM) {
+	count := int(vm.readShort())
+
+	m := make(map[bytecode.Key]bytecode.Value, count)
+	base := vm.sp - count*2
+	for i := base; i < vm.sp; i += 2 {
+		m[bytecode.MapKey(vm.stack[i])] = vm.stack[i+1]
+	}
+	vm.sp = base
+	if !vm.allocate(AllocMap, mapHeader+count*(2*valueSize+mapEntryOverhead)) {
+		return
+	}
+	vm.push(bytecode.NewMap(m))
+}
+
+// opMapGet 读取 Map 值，键不存在时返回 null
+// 栈: [map, key] -> [value]
+func opMapGet(vm *VM) {
+	m := vm.peek(1).AsMap()
+	if m == nil {
+		opArrayGet(vm)
+		return
+	}
+	key := vm.pop()
+	vm.stack[vm.sp-1] = m[bytecode.MapKey(key)]
+}
+
+// opMapSet 写入 Map 值
+// 栈: [map, key, value] -> [value]
+func opMapSet(vm *VM) {
+	m := vm.peek(2).AsMap()
+	if m == nil {
+		opArraySet(vm)
+		return
+	}
+	key, val := bytecode.MapKey(vm.peek(1)), vm.peek(0)
+	if _, ok := m[key]; !ok {
+		if !vm.grow(2*valueSize + mapEntryOverhead) {
+			return
+		}
+	}
+	m[key] = val
+	vm.sp -= 3
+	vm.push(val)
+}
+
+// opMapHas 检查键是否存在，数组类容器检查索引是否有效
+// 栈: [container, key] -> [bool]
+func opMapHas(vm *VM) {
+	key := vm.pop()
+	container := vm.pop()
+
+	var has bool
+	switch container.Type() {
+	case bytecode.ValMap:
+		_, has = container.AsMap()[bytecode.MapKey(key)]
+	case bytecode.ValSuperArray:
+		has = container.AsSuperArray().HasKey(key)
+	default:
+		idx := key.AsInt()
+		has = key.IsInt() && idx >= 0 && idx < int64(containerLen(container))
+	}
+	vm.push(bytecode.NewBool(has))
+}
+
+// opMapLen Map 大小
+// 栈: [map] -> [length]
+func opMapLen(vm *VM) {
+	v := vm.peek(0)
+	if v.Type() != bytecode.ValMap {
+		opArrayLen(vm)
+		return
+	}
+	vm.stack[vm.sp-1] = bytecode.NewInt(int64(len(v.AsMap())))
+}
+
+// ============================================================================
+// 字节数组
+// ============================================================================
+
+// opNewBytes 用栈上的整数创建字节数组，每个值截断为低 8 位
+// 字节码格式: OpNewBytes + count(u16)
+func opNewBytes(vm *VM) {
+	count := int(vm.readShort())
+
+	b := make([]byte, count)
+	base := vm.sp - count
+	for i := range b {
+		b[i] = byte(vm.stack[base+i].AsInt())
+	}
+	vm.sp = base
+	if !vm.allocate(AllocBytes, sliceHeader+count) {
+		return
+	}
+	vm.push(bytecode.NewBytes(b))
+}
+
+// opBytesGet 读取字节
+// 栈: [bytes, idx] -> [int]
+func opBytesGet(vm *VM) {
+	if !vm.peek(1).IsBytesValue() {
+		opArrayGet(vm)
+		return
+	}
+	b := vm.peek(1).AsBytes()
+	idx := vm.pop().AsInt()
+	if idx < 0 || idx >= int64(len(b)) {
+		vm.throwIndexOutOfBounds(idx, len(b))
+		return
+	}
+	vm.stack[vm.sp-1] = bytecode.NewInt(int64(b[idx]))
+}
+
+// opBytesSet 写入字节，值截断为低 8 位
+// 栈: [bytes, idx, value] -> [value]
+func opBytesSet(vm *VM) {
+	if !vm.peek(2).IsBytesValue() {
+		opArraySet(vm)
+		return
+	}
+	b := vm.peek(2).AsBytes()
+	idx := vm.peek(1).AsInt()
+	if idx < 0 || idx >= int64(len(b)) {
+		vm.throwIndexOutOfBounds(idx, len(b))
+		return
+	}
+	val, ok := vm.coerceElement(bytecode.ValInt, vm.peek(0))
+	if !ok {
+		return
+	}
+	b[idx] = byte(val.AsInt())
+	vm.sp -= 3
+	vm.push(bytecode.NewInt(int64(b[idx])))
+}
+
+// opBytesLen 字节数组长度
+// 栈: [bytes] -> [length]
+func opBytesLen(vm *VM) {
+	if !vm.peek(0).IsBytesValue() {
+		opArrayLen(vm)
+		return
+	}
+	vm.stack[vm.sp-1] = bytecode.NewInt(int64(len(vm.peek(0).AsBytes())))
+}
+
+// opBytesSlice 复制字节数组的 [start, end) 区间，end 为 null 时截取到末尾
+// 栈: [bytes, start, end] -> [bytes]
+func opBytesSlice(vm *VM) {
+	endVal := vm.pop()
+	start := vm.pop().AsInt()
+	b := vm.pop().AsBytes()
+
+	end := int64(len(b))
+	if !endVal.IsNull() {
+		end = endVal.AsInt()
+	}
+	if start < 0 || start > int64(len(b)) {
+		vm.throwIndexOutOfBounds(start, len(b))
+		return
+	}
+	if end < start || end > int64(len(b)) {
+		vm.throwIndexOutOfBounds(end, len(b))
+		return
+	}
+	if !vm.allocate(AllocBytes, sliceHeader+int(end-start)) {
+		return
+	}
+	vm.push(bytecode.NewBytes(append([]byte(nil), b[start:end]...)))
+}
+
+// opBytesConcat 拼接两个字节数组为新数组
+// 栈: [a, b] -> [bytes]
+func opBytesConcat(vm *VM) {
+	b := vm.pop().AsBytes()
+	a := vm.pop().AsBytes()
+
+	if !vm.allocate(AllocBytes, sliceHeader+len(a)+len(b)) {
+		return
+	}
+	result := make([]byte, 0, len(a)+len(b))
+	result = append(result, a...)
+	result = append(result, b...)
+	vm.push(bytecode.NewBytes(result))
+}
@@ -0,0 +1,974 @@
+package vm
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 协程调度器
+// ============================================================================
+//
+// 协作式 M:1 调度：所有协程在同一个 Go 协程上交替执行，只在 yield、await
+// 等挂起点切换。每个协程拥有独立的操作数栈和调用栈，切换时 VM 直接换入
+// 对应的栈切片，不复制数据。
+//
+// 挂起的协程保存的 ip 指向挂起它的指令，恢复后重新执行该指令，
+// 因此 await 等操作无需额外的恢复逻辑。
+//
+// 切换只发生在最外层执行循环中。内置函数回调等嵌套执行中遇到阻塞操作时，
+// 调度器在当前 Go 调用栈上就地运行其他协程，直到等待的条件满足。
+
+// coState 协程调度状态
+type coState uint8
+
+const (
+	coReady   coState = iota // 在就绪队列中
+	coRunning                // 正在执行
+	coWaiting                // 等待其他协程结束或定时器到期
+	coDone                   // 已结束 (完成、失败或取消)
+)
+
+// coKind 协程类型
+type coKind uint8
+
+const (
+	coTask  coKind = iota // 执行 Sola 代码的协程
+	coTimer               // Coroutine::delay 定时器，没有执行上下文
+	coAll                 // Coroutine::all 组合
+	coAny                 // Coroutine::any 组合
+	coRace                // Coroutine::race 组合
+)
+
+// coroutine 协程
+type coroutine struct {
+	obj   *bytecode.CoroutineObject // 暴露给 Sola 代码的协程对象
+	kind  coKind
+	state coState
+
+	// 执行上下文 (仅 coTask)
+	stack  []bytecode.Value
+	frames []CallFrame
+	sp     int
+	fp     int
+	base   int
+
+	// 等待状态
+	waitReason string       // 阻塞原因 (死锁报告用)，未阻塞时为空
+	wait       *selectGroup // 通道等待 (send/receive/select) 的结果
+	waitCases  []selectCase // 阻塞的通道操作 (调试器查看)
+	waitingOn *coroutine   // 正在 await 的协程
+	wakeAt    time.Time    // 定时器到期或 await 超时的时间 (零值表示没有)
+	timedOut  bool         // await 因超时被唤醒
+	waiters   []*coroutine // 等待本协程结束的协程和组合
+
+	children []*coroutine // 组合的子协程
+
+	started bool // 已开始运行 (用于报告进入入口函数)
+
+	// 创建处 (调试器以此命名协程)
+	spawnFn   *bytecode.Function
+	spawnLine int
+}
+
+// scheduler 协程调度器
+type scheduler struct {
+	nextID  int64
+	main    *coroutine   // 主协程 (VM 初始执行上下文)，调度器启用后才创建
+	current *coroutine   // 当前运行的协程
+	ready   []*coroutine // 就绪队列 (FIFO)
+	timers  []*coroutine // 设置了 wakeAt 的协程
+	live    map[*bytecode.CoroutineObject]*coroutine
+
+	now   func() time.Time    // 时钟 (测试中可替换)
+	sleep func(time.Duration) // 无协程可运行时等待定时器
+	rand  func(n int) int     // select 随机选择就绪分支
+
+	virtual bool // 虚拟时钟：sleep 只推进时钟，不受执行时间限制约束
+	shuffle bool // 就绪协程按 rand 选择的顺序运行 (否则先进先出)
+}
+
+// random 返回 [0, n) 内的随机数
+func (s *scheduler) random(n int) int {
+	if s.rand == nil {
+		return rand.Intn(n)
+	}
+	return s.rand(n)
+}
+
+// ============================================================================
+// 调度器生命周期
+// ============================================================================
+
+// ensureScheduler 首次创建协程时启用调度器，把当前执行上下文登记为主协程
+func (vm *VM) ensureScheduler() *scheduler {
+	s := &vm.sched
+	if s.main != nil {
+		return s
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.sleep == nil {
+		s.sleep = time.Sleep
+	}
+	s.live = make(map[*bytecode.CoroutineObject]*coroutine)
+	s.main = &coroutine{
+		obj:     bytecode.NewCoroutineObject(0),
+		kind:    coTask,
+		state:   coRunning,
+		started: true,
+	}
+	s.main.obj.Status = bytecode.CoroutineRunning
+	s.current = s.main
+	s.nextID = 1
+	return s
+}
+
+// resetScheduler 丢弃所有协程并换回主协程的执行上下文
+// 未结束的协程标记为已取消，之后的宿主调用 await 它们时抛出 CancellationException
+func (vm *VM) resetScheduler() {
+	s := &vm.sched
+	if s.main != nil && s.current != s.main {
+		vm.loadContext(s.main)
+	}
+	for obj := range s.live {
+		if !obj.IsCompleted() {
+			obj.Cancel()
+		}
+	}
+	vm.sched = scheduler{now: s.now, sleep: s.sleep, rand: s.rand, virtual: s.virtual, shuffle: s.shuffle}
+}
+
+// SetClock 设置协程定时器和 sleep 使用的时钟，now 为 nil 时使用真实时间
+// 虚拟时钟 (virtual) 的 sleep 只推进时钟并立即返回，等待定时器时不受执行时间限制约束
+func (vm *VM) SetClock(now func() time.Time, sleep func(time.Duration), virtual bool) {
+	s := &vm.sched
+	if now == nil {
+		now, sleep, virtual = time.Now, time.Sleep, false
+	}
+	s.now, s.sleep, s.virtual = now, sleep, virtual
+}
+
+// SetRandom 设置调度器的随机源，rand(n) 返回 [0, n) 内的数，nil 表示使用 math/rand
+// select 用它在多个就绪分支中选择；shuffle 为 true 时就绪协程也按它选择的顺序运行，
+// 否则按先进先出的顺序运行
+func (vm *VM) SetRandom(rand func(n int) int, shuffle bool) {
+	vm.sched.rand = rand
+	vm.sched.shuffle = shuffle && rand != nil
+}
+
+// canSwitch 当前是否可以切换协程
+// 只有最外层执行循环可以切换，嵌套执行 (内置函数回调) 的 Go 调用栈无法挂起
+func (vm *VM) canSwitch() bool {
+	return vm.runDepth == 1 && vm.sched.current != nil
+}
+
+// ============================================================================
+// 协程创建
+// ============================================================================
+
+// newCoroutine 创建协程并登记
+func (vm *VM) newCoroutine(kind coKind) *coroutine {
+	s := vm.ensureScheduler()
+	co := &coroutine{
+		obj:  bytecode.NewCoroutineObject(s.nextID),
+		kind: kind,
+	}
+	s.nextID++
+	s.live[co.obj] = co
+	if kind == coTask {
+		// 与主协程一样从初始大小开始按需扩容，操作数栈由创建者按入口帧的需要分配
+		co.frames = make([]CallFrame, InitialCallStackSize)
+		if vm.fp > 0 {
+			frame := vm.currentFrame()
+			co.spawnFn = frame.function
+			co.spawnLine = LineAt(frame.chunk, frame.ip-1)
+		}
+	}
+	return co
+}
+
+// spawnCall 创建调用 callee(args...) 的协程并加入就绪队列
+func (vm *VM) spawnCall(callee bytecode.Value, args []bytecode.Value) (*coroutine, bool) {
+	var fn *bytecode.Function
+	var closure *bytecode.Closure
+	switch {
+	case callee.IsClosure():
+		closure = callee.AsClosure()
+		if closure != nil {
+			fn = closure.Function
+		}
+	case callee.IsFunc():
+		fn = callee.AsFunc()
+	}
+	if fn == nil || fn.IsBuiltin {
+		return nil, false
+	}
+
+	co := vm.newCoroutine(coTask)
+	co.stack = newStack(frameReserve(fn) + len(args))
+	co.stack[0] = callee
+	co.sp = 1
+	for _, arg := range args {
+		co.stack[co.sp] = arg
+		co.sp++
+	}
+	// 缺省参数
+	for i := len(args); i < fn.Arity; i++ {
+		v := bytecode.NullValue
+		if defIdx := i - fn.MinArity; defIdx >= 0 && defIdx < len(fn.DefaultValues) {
+			v = fn.DefaultValues[defIdx]
+		}
+		co.stack[co.sp] = v
+		co.sp++
+	}
+
+	frame := &co.frames[0]
+	frame.function = fn
+	frame.closure = closure
+	frame.chunk = fn.Chunk
+	frame.ip = 0
+	frame.bp = 0 // slot 0 为被调用者
+	co.fp = 1
+
+	vm.makeReady(co)
+	return co, true
+}
+
+// spawnSnapshot 为 go 语句创建协程
+// 协程从当前帧的 ip 处开始，在局部变量快照上执行紧随 OpGo 的调用表达式，
+// 执行到编译器生成的 OpReturn 时结束
+func (vm *VM) spawnSnapshot(frame *CallFrame, ip int) *coroutine {
+	co := vm.newCoroutine(coTask)
+	co.stack = newStack(frameReserve(frame.function))
+	co.sp = copy(co.stack, vm.stack[frame.bp:vm.sp])
+
+	f := &co.frames[0]
+	f.function = frame.function
+	f.closure = frame.closure
+	f.chunk = frame.chunk
+	f.ip = ip
+	f.bp = 0
+	f.isStaticCall = frame.isStaticCall
+	co.fp = 1
+
+	vm.makeReady(co)
+	return co
+}
+
+// ============================================================================
+// 上下文切换
+// ============================================================================
+
+// saveContext 保存 VM 当前执行上下文到协程
+func (vm *VM) saveContext(co *coroutine) {
+	co.stack = vm.stack
+	co.frames = vm.frames
+	co.sp = vm.sp
+	co.fp = vm.fp
+	co.base = vm.baseFP
+}
+
+// loadContext 换入协程的执行上下文
+func (vm *VM) loadContext(co *coroutine) {
+	from := vm.sched.current
+	vm.stack = co.stack
+	vm.frames = co.frames
+	vm.sp = co.sp
+	vm.fp = co.fp
+	vm.baseFP = co.base
+	vm.sched.current = co
+	co.state = coRunning
+	co.obj.Status = bytecode.CoroutineRunning
+	if vm.hookEvents&(HookCall|HookCoroutine) != 0 || !co.started {
+		vm.hookSwitch(from, co)
+	}
+}
+
+// makeReady 加入就绪队列
+func (vm *VM) makeReady(co *coroutine) {
+	co.state = coReady
+	co.waitReason = ""
+	co.waitCases = nil
+	vm.sched.ready = append(vm.sched.ready, co)
+}
+
+// popReady 取出下一个可运行的协程
+// skipMain 为 true 时跳过主协程 (嵌套执行中不能把主协程运行到结束)
+func (vm *VM) popReady(skipMain bool) *coroutine {
+	s := &vm.sched
+	if s.shuffle {
+		return vm.popRandom(skipMain)
+	}
+	for i := 0; i < len(s.ready); i++ {
+		co := s.ready[i]
+		if co.state != coReady {
+			// 已取消或已被唤醒过的重复项
+			s.ready = append(s.ready[:i], s.ready[i+1:]...)
+			i--
+			continue
+		}
+		if skipMain && co == s.main {
+			continue
+		}
+		s.ready = append(s.ready[:i], s.ready[i+1:]...)
+		return co
+	}
+	return nil
+}
+
+// popRandom 按调度器的随机源取出一个可运行的协程 (确定性运行的调度顺序)
+func (vm *VM) popRandom(skipMain bool) *coroutine {
+	s := &vm.sched
+	ready := s.ready[:0]
+	var candidates []int
+	for _, co := range s.ready {
+		if co.state != coReady {
+			continue
+		}
+		if !skipMain || co != s.main {
+			candidates = append(candidates, len(ready))
+		}
+		ready = append(ready, co)
+	}
+	clear(s.ready[len(ready):])
+	s.ready = ready
+	if len(candidates) == 0 {
+		return nil
+	}
+	i := candidates[s.random(len(candidates))]
+	co := s.ready[i]
+	s.ready = append(s.ready[:i], s.ready[i+1:]...)
+	return co
+}
+
+// suspend 挂起当前协程并切换到下一个可运行的协程
+// 调用者已设置好当前协程的状态 (就绪或等待) 和恢复位置
+func (vm *VM) suspend() {
+	cur := vm.sched.current
+	vm.saveContext(cur)
+	if cur.state == coRunning {
+		cur.state = coReady
+	}
+	vm.switchNext()
+}
+
+// switchNext 换入下一个可运行的协程，必要时等待定时器
+// 所有协程都在等待且没有定时器时，向一个等待中的协程抛出死锁异常
+func (vm *VM) switchNext() {
+	s := &vm.sched
+	for {
+		vm.fireTimers()
+		if next := vm.popReady(false); next != nil {
+			vm.loadContext(next)
+			return
+		}
+		wake := vm.nextWake()
+		if wake.IsZero() {
+			vm.deadlock()
+			return
+		}
+		if !vm.idle(wake.Sub(s.now())) {
+			return
+		}
+	}
+}
+
+// coroutineExited 执行循环离开入口帧时调用
+// 如果结束的是最外层循环中的子协程，记录结果并切换到下一个协程，返回 true
+func (vm *VM) coroutineExited() bool {
+	s := &vm.sched
+	cur := s.current
+	if cur == nil || cur == s.main || vm.hasError || !vm.canSwitch() {
+		return false
+	}
+	vm.finishTask(cur)
+	vm.switchNext()
+	return true
+}
+
+// finishTask 协程执行完毕，从栈上取出结果或挂起的异常
+func (vm *VM) finishTask(co *coroutine) {
+	if ex := vm.pendingException; ex != nil {
+		vm.pendingException = nil
+		vm.completeCoroutine(co, bytecode.NullValue, ex)
+	} else {
+		result := bytecode.NullValue
+		if vm.sp > 0 {
+			result = vm.pop()
+		}
+		vm.completeCoroutine(co, result, nil)
+	}
+}
+
+// completeCoroutine 标记协程结束并唤醒等待者
+// 已取消的协程保持取消状态，忽略结果
+func (vm *VM) completeCoroutine(co *coroutine, result bytecode.Value, ex *bytecode.Exception) {
+	if !co.obj.IsCompleted() {
+		if ex != nil {
+			co.obj.Fail(exceptionValue(ex))
+		} else {
+			co.obj.Complete(result)
+		}
+	}
+	vm.retire(co)
+}
+
+// retire 释放协程资源并通知等待者
+func (vm *VM) retire(co *coroutine) {
+	if co.state == coDone {
+		return
+	}
+	co.state = coDone
+	co.stack = nil
+	co.frames = nil
+	co.waitingOn = nil
+	co.waitCases = nil
+	vm.clearTimer(co)
+	delete(vm.sched.live, co.obj)
+
+	waiters := co.waiters
+	co.waiters = nil
+	for _, w := range waiters {
+		vm.notify(w, co)
+	}
+}
+
+// notify 被等待的协程结束
+func (vm *VM) notify(w *coroutine, done *coroutine) {
+	switch w.kind {
+	case coTask:
+		if w.state == coWaiting && w.waitingOn == done {
+			w.waitingOn = nil
+			vm.clearTimer(w)
+			vm.makeReady(w)
+		}
+	case coAll, coAny, coRace:
+		vm.settleCombinator(w)
+	}
+}
+
+// ============================================================================
+// 定时器
+// ============================================================================
+
+// addTimer 设置协程的唤醒时间
+func (vm *VM) addTimer(co *coroutine, at time.Time) {
+	co.wakeAt = at
+	vm.sched.timers = append(vm.sched.timers, co)
+}
+
+// clearTimer 取消协程的唤醒时间
+func (vm *VM) clearTimer(co *coroutine) {
+	if co.wakeAt.IsZero() {
+		return
+	}
+	co.wakeAt = time.Time{}
+	timers := vm.sched.timers
+	for i, t := range timers {
+		if t == co {
+			vm.sched.timers = append(timers[:i], timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// nextWake 最早的唤醒时间
+func (vm *VM) nextWake() time.Time {
+	var wake time.Time
+	for _, t := range vm.sched.timers {
+		if wake.IsZero() || t.wakeAt.Before(wake) {
+			wake = t.wakeAt
+		}
+	}
+	return wake
+}
+
+// fireTimers 处理已到期的定时器 (按到期时间先后)
+func (vm *VM) fireTimers() {
+	s := &vm.sched
+	if len(s.timers) == 0 {
+		return
+	}
+	now := s.now()
+	for {
+		var due *coroutine
+		for _, t := range s.timers {
+			if !t.wakeAt.After(now) && (due == nil || t.wakeAt.Before(due.wakeAt)) {
+				due = t
+			}
+		}
+		if due == nil {
+			return
+		}
+		vm.clearTimer(due)
+		switch due.kind {
+		case coTimer:
+			vm.completeCoroutine(due, bytecode.NullValue, nil)
+		case coTask:
+			// await 超时：恢复后重新执行 await 指令时抛出 TimeoutException
+			if due.state == coWaiting {
+				due.waitingOn = nil
+				due.timedOut = true
+				vm.makeReady(due)
+			}
+		}
+	}
+}
+
+// ============================================================================
+// 嵌套执行中的等待
+// ============================================================================
+
+// driveUntil 在无法切换协程时就地运行其他协程，直到 done 返回 true
+// 超过 deadline (非零时) 或死锁时返回 false
+func (vm *VM) driveUntil(done func() bool, deadline time.Time) bool {
+	s := &vm.sched
+	for !done() {
+		if !deadline.IsZero() && !s.now().Before(deadline) {
+			return false
+		}
+		vm.fireTimers()
+		next := vm.popReady(true)
+		if next == nil {
+			wake := vm.nextWake()
+			if !deadline.IsZero() && (wake.IsZero() || deadline.Before(wake)) {
+				wake = deadline
+			}
+			if wake.IsZero() {
+				return false
+			}
+			if !vm.idle(wake.Sub(s.now())) {
+				return false
+			}
+			continue
+		}
+		vm.runNested(next)
+		if vm.hasError {
+			return false
+		}
+	}
+	return true
+}
+
+// runNested 在嵌套执行循环中把协程运行到结束
+func (vm *VM) runNested(co *coroutine) {
+	cur := vm.sched.current
+	vm.saveContext(cur)
+	vm.loadContext(co)
+
+	result := vm.execute(co.base)
+	if !vm.hasError {
+		if ex := vm.pendingException; ex != nil {
+			vm.pendingException = nil
+			vm.completeCoroutine(co, bytecode.NullValue, ex)
+		} else {
+			vm.completeCoroutine(co, result, nil)
+		}
+	}
+
+	if !vm.hasError {
+		vm.loadContext(cur)
+	}
+}
+
+// ============================================================================
+// 组合与取消
+// ============================================================================
+
+// spawnCombinator 创建 all/any/race 组合协程
+func (vm *VM) spawnCombinator(kind coKind, tasks []*bytecode.CoroutineObject) *coroutine {
+	c := vm.newCoroutine(kind)
+	c.state = coWaiting
+	for _, obj := range tasks {
+		child := vm.sched.live[obj]
+		if child == nil {
+			// 已结束的协程：用占位记录其最终状态
+			child = &coroutine{obj: obj, state: coDone}
+		} else {
+			child.waiters = append(child.waiters, c)
+		}
+		c.children = append(c.children, child)
+	}
+	vm.settleCombinator(c)
+	return c
+}
+
+// settleCombinator 检查组合协程是否可以结束
+func (vm *VM) settleCombinator(c *coroutine) {
+	if c.state == coDone {
+		return
+	}
+
+	switch c.kind {
+	case coAll:
+		results := make([]bytecode.Value, len(c.children))
+		for i, child := range c.children {
+			switch {
+			case child.obj.IsFailed():
+				vm.failCombinator(c, child.obj.Exception)
+				return
+			case child.obj.IsCancelled():
+				vm.failCombinator(c, vm.cancellationError(child.obj))
+				return
+			case !child.obj.IsCompleted():
+				return
+			}
+			results[i] = child.obj.Result
+		}
+		vm.completeCoroutine(c, bytecode.NewArray(results), nil)
+
+	case coAny:
+		pending := false
+		for _, child := range c.children {
+			if child.obj.IsSucceeded() {
+				vm.completeCoroutine(c, child.obj.Result, nil)
+				vm.cancelOthers(c, child)
+				return
+			}
+			if !child.obj.IsCompleted() {
+				pending = true
+			}
+		}
+		if !pending {
+			vm.failCombinator(c, exceptionValue(vm.newException("AggregateException", "all coroutines failed")))
+		}
+
+	case coRace:
+		for _, child := range c.children {
+			if !child.obj.IsCompleted() {
+				continue
+			}
+			switch {
+			case child.obj.IsSucceeded():
+				vm.completeCoroutine(c, child.obj.Result, nil)
+			case child.obj.IsFailed():
+				vm.failCombinator(c, child.obj.Exception)
+			default:
+				vm.failCombinator(c, vm.cancellationError(child.obj))
+			}
+			// 组合已结束，取消其余子协程不会再触发结算
+			vm.cancelOthers(c, child)
+			return
+		}
+	}
+}
+
+// failCombinator 组合协程以指定的异常值失败
+func (vm *VM) failCombinator(c *coroutine, exception bytecode.Value) {
+	c.obj.Fail(exception)
+	vm.retire(c)
+}
+
+// cancelOthers 组合结束后取消其余仍在运行的子协程
+func (vm *VM) cancelOthers(c *coroutine, winner *coroutine) {
+	for _, child := range c.children {
+		if child != winner && !child.obj.IsCompleted() {
+			vm.cancelCoroutine(child)
+		}
+	}
+}
+
+// cancelCoroutine 取消协程
+// 未运行的协程立即结束；正在运行的协程继续执行到下一个挂起点后结束
+func (vm *VM) cancelCoroutine(co *coroutine) bool {
+	if co.obj.IsCompleted() {
+		return false
+	}
+	co.obj.Cancel()
+	if co.state == coRunning {
+		// 正在执行 (或在嵌套执行中等待) 的协程在下一个挂起点结束
+		return true
+	}
+	vm.retire(co)
+	return true
+}
+
+// cancellationError 创建协程被取消的异常值
+func (vm *VM) cancellationError(obj *bytecode.CoroutineObject) bytecode.Value {
+	ex := vm.newException("CancellationException", "coroutine "+bytecode.NewInt(obj.ID).String()+" was cancelled")
+	return exceptionValue(ex)
+}
+
+// coroutineProperty 读取协程属性 ($task->isCompleted 等)
+func (vm *VM) coroutineProperty(obj *bytecode.CoroutineObject, name string) {
+	switch name {
+	case "id":
+		vm.push(bytecode.NewInt(obj.ID))
+	case "isCompleted":
+		vm.push(bytecode.NewBool(obj.IsCompleted()))
+	case "isSucceeded":
+		vm.push(bytecode.NewBool(obj.IsSucceeded()))
+	case "isFailed":
+		vm.push(bytecode.NewBool(obj.IsFailed()))
+	case "isCancelled":
+		vm.push(bytecode.NewBool(obj.IsCancelled()))
+	case "isRunning":
+		vm.push(bytecode.NewBool(obj.Status == bytecode.CoroutineRunning))
+	case "exception":
+		if obj.IsFailed() {
+			vm.push(obj.Exception)
+		} else {
+			vm.push(bytecode.NullValue)
+		}
+	default:
+		vm.runtimeError("undefined coroutine property: %s", name)
+	}
+}
+
+// coroutineArg 从操作数中取协程对象，失败时报告运行时错误
+func (vm *VM) coroutineArg(v bytecode.Value, op string) *bytecode.CoroutineObject {
+	co := v.AsCoroutine()
+	if co == nil {
+		vm.runtimeError("%s: expected coroutine, got %s", op, v.String())
+	}
+	return co
+}
+
+// coroutineList 从数组值中取协程列表
+func (vm *VM) coroutineList(v bytecode.Value, op string) ([]*bytecode.CoroutineObject, bool) {
+	var elems []bytecode.Value
+	switch {
+	case v.IsArray():
+		elems = v.AsArray()
+	case v.IsSuperArray():
+		elems = v.AsSuperArray().Values()
+	default:
+		vm.runtimeError("%s: expected coroutine array, got %s", op, v.String())
+		return nil, false
+	}
+	tasks := make([]*bytecode.CoroutineObject, len(elems))
+	for i, e := range elems {
+		if tasks[i] = vm.coroutineArg(e, op); tasks[i] == nil {
+			return nil, false
+		}
+	}
+	return tasks, true
+}
+
+// ============================================================================
+// 协程操作码
+// ============================================================================
+
+// opGo 启动协程执行紧随其后的调用表达式 (go 语句)
+// 格式: OpGo skip:u16 <调用表达式> OpReturn
+func opGo(vm *VM) {
+	skip := int(vm.readShort())
+	frame := vm.currentFrame()
+	start := frame.ip
+	frame.ip += skip
+
+	co := vm.spawnSnapshot(frame, start)
+	vm.push(bytecode.NewCoroutineValue(co.obj))
+}
+
+// opYield 让出执行权
+func opYield(vm *VM) {
+	if !vm.canSwitch() {
+		return
+	}
+	cur := vm.sched.current
+	if cur.obj.IsCancelled() && cur != vm.sched.main {
+		vm.saveContext(cur)
+		vm.retire(cur)
+		vm.switchNext()
+		return
+	}
+	vm.fireTimers()
+	if len(vm.sched.ready) == 0 {
+		return
+	}
+	vm.makeReady(cur)
+	vm.suspend()
+}
+
+// opCoroutineSpawn 创建协程 [callable -> coroutine]
+func opCoroutineSpawn(vm *VM) {
+	callee := vm.pop()
+	co, ok := vm.spawnCall(callee, nil)
+	if !ok {
+		vm.runtimeError("Coroutine::spawn: expected function or closure, got %s", callee.String())
+		return
+	}
+	vm.push(bytecode.NewCoroutineValue(co.obj))
+}
+
+// opCoroutineAwait 等待协程完成 [coroutine [, timeoutMs] -> result]
+// 格式: OpCoroutineAwait hasTimeout:u8
+func opCoroutineAwait(vm *VM) {
+	frame := vm.currentFrame()
+	opStart := frame.ip - 1
+	hasTimeout := vm.readByte() == 1
+
+	operands := 1
+	if hasTimeout {
+		operands = 2
+	}
+	target := vm.coroutineArg(vm.peek(operands-1), "await")
+	if target == nil {
+		return
+	}
+
+	if !target.IsCompleted() {
+		s := &vm.sched
+		if s.live[target] == nil {
+			// 其他 VM 创建的协程，不会在这里结束
+			vm.popN(operands)
+			vm.throwError("InvalidOperationException", "await: coroutine %d does not belong to this VM", target.ID)
+			return
+		}
+		if vm.canSwitch() {
+			cur := s.current
+			if cur.timedOut {
+				cur.timedOut = false
+				vm.popN(operands)
+				vm.throwError("TimeoutException", "await timed out")
+				return
+			}
+			if cur.obj.IsCancelled() && cur != s.main {
+				vm.retire(cur)
+				vm.switchNext()
+				return
+			}
+
+			// 挂起，被唤醒后重新执行本指令
+			frame.ip = opStart
+			cur.state = coWaiting
+			cur.waitReason = "await"
+			cur.waitingOn = s.live[target]
+			cur.waitingOn.waiters = append(cur.waitingOn.waiters, cur)
+			if hasTimeout {
+				vm.addTimer(cur, s.now().Add(time.Duration(vm.peek(0).AsInt())*time.Millisecond))
+			}
+			vm.suspend()
+			return
+		}
+
+		// 嵌套执行：就地运行其他协程
+		var deadline time.Time
+		if hasTimeout {
+			deadline = s.now().Add(time.Duration(vm.peek(0).AsInt()) * time.Millisecond)
+		}
+		s.current.waitReason = "await"
+		completed := vm.driveUntil(target.IsCompleted, deadline)
+		s.current.waitReason = ""
+		if !completed {
+			switch {
+			case vm.hasError:
+			case hasTimeout && !s.now().Before(deadline):
+				vm.popN(operands)
+				vm.throwError("TimeoutException", "await timed out")
+			default:
+				s.current.waitReason = "await"
+				vm.deadlock()
+			}
+			return
+		}
+	}
+
+	vm.popN(operands)
+	switch {
+	case target.IsSucceeded():
+		vm.push(target.Result)
+	case target.IsFailed():
+		vm.throwValue(target.Exception)
+	default:
+		vm.throwValue(vm.cancellationError(target))
+	}
+}
+
+// opCoroutineCancel 取消协程 [coroutine -> bool]
+func opCoroutineCancel(vm *VM) {
+	obj := vm.coroutineArg(vm.pop(), "cancel")
+	if obj == nil {
+		return
+	}
+	cancelled := false
+	if co := vm.sched.live[obj]; co != nil {
+		cancelled = vm.cancelCoroutine(co)
+	}
+	vm.push(bytecode.NewBool(cancelled))
+}
+
+// opCoroutineIsCompleted 检查协程是否已结束 [coroutine -> bool]
+func opCoroutineIsCompleted(vm *VM) {
+	if obj := vm.coroutineArg(vm.pop(), "isCompleted"); obj != nil {
+		vm.push(bytecode.NewBool(obj.IsCompleted()))
+	}
+}
+
+// opCoroutineIsCancelled 检查协程是否已取消 [coroutine -> bool]
+func opCoroutineIsCancelled(vm *VM) {
+	if obj := vm.coroutineArg(vm.pop(), "isCancelled"); obj != nil {
+		vm.push(bytecode.NewBool(obj.IsCancelled()))
+	}
+}
+
+// opCoroutineGetResult 获取结果，未成功完成时返回 null [coroutine -> value]
+func opCoroutineGetResult(vm *VM) {
+	if obj := vm.coroutineArg(vm.pop(), "getResult"); obj != nil {
+		if obj.IsSucceeded() {
+			vm.push(obj.Result)
+		} else {
+			vm.push(bytecode.NullValue)
+		}
+	}
+}
+
+// opCoroutineGetException 获取失败原因 [coroutine -> exception|null]
+func opCoroutineGetException(vm *VM) {
+	if obj := vm.coroutineArg(vm.pop(), "getException"); obj != nil {
+		if obj.IsFailed() {
+			vm.push(obj.Exception)
+		} else {
+			vm.push(bytecode.NullValue)
+		}
+	}
+}
+
+// opCoroutineGetID 获取协程 ID [coroutine -> int]
+func opCoroutineGetID(vm *VM) {
+	if obj := vm.coroutineArg(vm.pop(), "id"); obj != nil {
+		vm.push(bytecode.NewInt(obj.ID))
+	}
+}
+
+// opCoroutineAll 等待所有协程 [coroutine[] -> coroutine<result[]>]
+func opCoroutineAll(vm *VM) {
+	vm.combinator(coAll, "Coroutine::all")
+}
+
+// opCoroutineAny 等待第一个成功的协程 [coroutine[] -> coroutine]
+func opCoroutineAny(vm *VM) {
+	vm.combinator(coAny, "Coroutine::any")
+}
+
+// opCoroutineRace 等待第一个结束的协程 [coroutine[] -> coroutine]
+func opCoroutineRace(vm *VM) {
+	vm.combinator(coRace, "Coroutine::race")
+}
+
+func (vm *VM) combinator(kind coKind, op string) {
+	tasks, ok := vm.coroutineList(vm.pop(), op)
+	if !ok {
+		return
+	}
+	if len(tasks) == 0 && kind != coAll {
+		vm.throwError("ArgumentException", "%s requires at least one coroutine", op)
+		return
+	}
+	c := vm.spawnCombinator(kind, tasks)
+	vm.push(bytecode.NewCoroutineValue(c.obj))
+}
+
+// opCoroutineDelay 创建在指定毫秒后完成的协程 [ms -> coroutine<void>]
+func opCoroutineDelay(vm *VM) {
+	ms := vm.pop().AsInt()
+	co := vm.newCoroutine(coTimer)
+	co.state = coWaiting
+	vm.addTimer(co, vm.sched.now().Add(time.Duration(ms)*time.Millisecond))
+	vm.push(bytecode.NewCoroutineValue(co.obj))
+}
@@ -24,6 +24,7 @@ func init() {
 	dispatchTable[bytecode.OpPush] = opPush
 	dispatchTable[bytecode.OpPop] = opPop
 	dispatchTable[bytecode.OpDup] = opDup
+	dispatchTable[bytecode.OpSwap] = opSwap
 
 	// 常量加载
 	dispatchTable[bytecode.OpNull] = opNull
@@ -79,6 +80,13 @@ func init() {
 	// 函数调用
 	dispatchTable[bytecode.OpCall] = opCall
 	dispatchTable[bytecode.OpCallStatic] = opCallStatic
+	dispatchTable[bytecode.OpTailCall] = opTailCall
+	dispatchTable[bytecode.OpTailCallStatic] = opTailCallStatic
+	dispatchTable[bytecode.OpTailCallMethod] = opTailCallMethod
+
+	// 快速指令 (算术和比较指令内联在执行循环中)
+	dispatchTable[bytecode.OpArrayGetList] = opArrayGetList
+	dispatchTable[bytecode.OpGetFieldMono] = opGetFieldMono
 	dispatchTable[bytecode.OpReturn] = opReturn
 	dispatchTable[bytecode.OpReturnNull] = opReturnNull
 	dispatchTable[bytecode.OpClosure] = opClosure
@@ -89,11 +97,42 @@ func init() {
 	dispatchTable[bytecode.OpSetField] = opSetField
 	dispatchTable[bytecode.OpCallMethod] = opInvoke
 
+	// 静态成员
+	dispatchTable[bytecode.OpGetStatic] = opGetStatic
+	dispatchTable[bytecode.OpSetStatic] = opSetStatic
+
 	// 数组操作
 	dispatchTable[bytecode.OpNewArray] = opNewArray
 	dispatchTable[bytecode.OpArrayGet] = opArrayGet
 	dispatchTable[bytecode.OpArraySet] = opArraySet
 	dispatchTable[bytecode.OpArrayLen] = opArrayLen
+	dispatchTable[bytecode.OpNewFixedArray] = opNewFixedArray
+	dispatchTable[bytecode.OpArrayGetUnchecked] = opArrayGetUnchecked
+	dispatchTable[bytecode.OpArraySetUnchecked] = opArraySetUnchecked
+	dispatchTable[bytecode.OpArrayPush] = opArrayPush
+	dispatchTable[bytecode.OpArrayHas] = opArrayHas
+
+	// 原生数组操作
+	dispatchTable[bytecode.OpNativeArrayNew] = opNativeArrayNew
+	dispatchTable[bytecode.OpNativeArrayInit] = opNativeArrayInit
+	dispatchTable[bytecode.OpNativeArrayGet] = opNativeArrayGet
+	dispatchTable[bytecode.OpNativeArraySet] = opNativeArraySet
+	dispatchTable[bytecode.OpNativeArrayLen] = opNativeArrayLen
+
+	// Map 操作
+	dispatchTable[bytecode.OpNewMap] = opNewMap
+	dispatchTable[bytecode.OpMapGet] = opMapGet
+	dispatchTable[bytecode.OpMapSet] = opMapSet
+	dispatchTable[bytecode.OpMapHas] = opMapHas
+	dispatchTable[bytecode.OpMapLen] = opMapLen
+
+	// 字节数组操作
+	dispatchTable[bytecode.OpNewBytes] = opNewBytes
+	dispatchTable[bytecode.OpBytesGet] = opBytesGet
+	dispatchTable[bytecode.OpBytesSet] = opBytesSet
+	dispatchTable[bytecode.OpBytesLen] = opBytesLen
+	dispatchTable[bytecode.OpBytesSlice] = opBytesSlice
+	dispatchTable[bytecode.OpBytesConcat] = opBytesConcat
 
 	// SuperArray 操作
 	dispatchTable[bytecode.OpSuperArrayNew] = opSuperArrayNew
@@ -110,6 +149,46 @@ func init() {
 	dispatchTable[bytecode.OpCast] = opCast
 	dispatchTable[bytecode.OpCastSafe] = opCastSafe
 
+	// 异常处理
+	dispatchTable[bytecode.OpThrow] = opThrow
+	dispatchTable[bytecode.OpEnterTry] = opEnterTry
+	dispatchTable[bytecode.OpLeaveTry] = opLeaveTry
+	dispatchTable[bytecode.OpEnterCatch] = opEnterCatch
+	dispatchTable[bytecode.OpEnterFinally] = opEnterFinally
+	dispatchTable[bytecode.OpLeaveFinally] = opLeaveFinally
+	dispatchTable[bytecode.OpRethrow] = opRethrow
+
+	// 协程
+	dispatchTable[bytecode.OpGo] = opGo
+	dispatchTable[bytecode.OpYield] = opYield
+	dispatchTable[bytecode.OpCoroutineSpawn] = opCoroutineSpawn
+	dispatchTable[bytecode.OpCoroutineAwait] = opCoroutineAwait
+	dispatchTable[bytecode.OpCoroutineCancel] = opCoroutineCancel
+	dispatchTable[bytecode.OpCoroutineIsCompleted] = opCoroutineIsCompleted
+	dispatchTable[bytecode.OpCoroutineIsCancelled] = opCoroutineIsCancelled
+	dispatchTable[bytecode.OpCoroutineGetResult] = opCoroutineGetResult
+	dispatchTable[bytecode.OpCoroutineGetException] = opCoroutineGetException
+	dispatchTable[bytecode.OpCoroutineGetID] = opCoroutineGetID
+	dispatchTable[bytecode.OpCoroutineAll] = opCoroutineAll
+	dispatchTable[bytecode.OpCoroutineAny] = opCoroutineAny
+	dispatchTable[bytecode.OpCoroutineRace] = opCoroutineRace
+	dispatchTable[bytecode.OpCoroutineDelay] = opCoroutineDelay
+
+	// 通道
+	dispatchTable[bytecode.OpChanMake] = opChanMake
+	dispatchTable[bytecode.OpChanSend] = opChanSend
+	dispatchTable[bytecode.OpChanRecv] = opChanRecv
+	dispatchTable[bytecode.OpChanClose] = opChanClose
+	dispatchTable[bytecode.OpChanTrySend] = opChanTrySend
+	dispatchTable[bytecode.OpChanTryRecv] = opChanTryRecv
+	dispatchTable[bytecode.OpChanLen] = opChanLen
+	dispatchTable[bytecode.OpChanCap] = opChanCap
+	dispatchTable[bytecode.OpChanIsClosed] = opChanIsClosed
+	dispatchTable[bytecode.OpSelectStart] = opSelectStart
+
+	// 对象销毁
+	dispatchTable[bytecode.OpUnset] = opUnset
+
 	// 其他
 	dispatchTable[bytecode.OpDebugPrint] = opPrint
 	dispatchTable[bytecode.OpHalt] = opHalt
@@ -121,8 +200,14 @@ func init() {
 
 // Run 执行函数
 func (vm *VM) Run(fn *bytecode.Function) bytecode.Value {
+	if vm.runDepth == 0 {
+		vm.beginRun()
+	}
+
 	// 设置初始帧
-	vm.pushFrame(fn, 0)
+	if !vm.pushFrame(fn, 0) {
+		return vm.abortEntry()
+	}
 
 	// 使用优化的执行循环
 	return vm.runLoopOptimized()
@@ -130,13 +215,22 @@ func (vm *VM) Run(fn *bytecode.Function) bytecode.Value {
 
 // RunClosure 执行闭包
 func (vm *VM) RunClosure(closure *bytecode.Closure, args []bytecode.Value) bytecode.Value {
+	if vm.runDepth == 0 {
+		vm.beginRun()
+	}
+
+	// 被调用者槽位，返回时由帧弹出
+	vm.push(bytecode.NewClosure(closure))
+
 	// 设置参数
 	for _, arg := range args {
 		vm.push(arg)
 	}
 
 	// 设置闭包帧
-	vm.pushClosureFrame(closure, vm.sp-len(args))
+	if !vm.pushClosureFrame(closure, vm.sp-len(args)-1) {
+		return vm.abortEntry()
+	}
 
 	// 执行
 	return vm.runLoop()
@@ -147,14 +241,40 @@ func (vm *VM) runLoop() bytecode.Value {
 	return vm.runLoopOptimized()
 }
 
-// runLoopOptimized 优化的执行循环
-// 使用 switch 语句替代分派表调用，内联高频操作
+// runLoopOptimized 优化的执行循环，以当前帧为入口帧
 func (vm *VM) runLoopOptimized() bytecode.Value {
-	stack := &vm.stack
+	return vm.execute(vm.fp - 1)
+}
+
+// execute 执行直到帧数回落到 base
+// 使用 switch 语句替代分派表调用，内联高频操作
+func (vm *VM) execute(base int) bytecode.Value {
+	// 记录入口帧：入口帧返回时结束本次执行，异常也只展开到这里
+	savedBase := vm.baseFP
+	vm.baseFP = base
+	vm.runDepth++
+	defer func() {
+		vm.baseFP = savedBase
+		vm.runDepth--
+	}()
+
 	sp := vm.sp // 本地化栈指针，减少内存访问
 
 mainLoop:
 	for {
+		// 协程切换会替换栈和入口帧，每次迭代重新获取
+		base := vm.baseFP
+		if vm.fp <= base || vm.hasError {
+			// 入口帧已返回、发生错误或异常越过了入口帧
+			vm.sp = sp
+			if vm.coroutineExited() {
+				sp = vm.sp
+				continue
+			}
+			return vm.finishRun()
+		}
+		stack := vm.stack
+
 		// 每次迭代获取当前frame（因为函数调用/返回会改变它）
 		frame := &vm.frames[vm.fp-1]
 		code := frame.chunk.Code
@@ -163,8 +283,17 @@ mainLoop:
 
 		// 内部循环 - 在同一个frame内执行，避免重复获取frame
 		for frame.ip < len(code) {
+			if vm.hookEvents&HookLine != 0 {
+				vm.sp = sp
+				if vm.traceLine(frame) {
+					// 钩子可能修改了局部变量或在 VM 上嵌套执行，重新获取执行状态
+					sp = vm.sp
+					continue mainLoop
+				}
+			}
 			op := bytecode.OpCode(code[frame.ip])
 			frame.ip++
+			vm.stats.InstructionsExecuted++
 
 			// 使用 switch 替代分派表，Go 编译器可以更好地优化
 			switch op {
@@ -187,9 +316,18 @@ mainLoop:
 				// 内联加法（整数快速路径，完全内联类型检查）
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					// 完全内联整数加法
 					stack[sp-2] = bytecode.NewInt(int64(a.Raw()) + int64(b.Raw()))
+				} else if a.Type() == bytecode.ValString || b.Type() == bytecode.ValString {
+					// 字符串拼接计入分配
+					vm.sp = sp
+					opAdd(vm)
+					sp = vm.sp
+					continue mainLoop
 				} else {
 					stack[sp-2] = Helper_Add(a, b)
 				}
@@ -199,6 +337,9 @@ mainLoop:
 				// 内联减法（整数快速路径）
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					stack[sp-2] = bytecode.NewInt(int64(a.Raw()) - int64(b.Raw()))
 				} else {
@@ -210,6 +351,9 @@ mainLoop:
 				// 内联乘法（整数快速路径）
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					stack[sp-2] = bytecode.NewInt(int64(a.Raw()) * int64(b.Raw()))
 				} else {
@@ -223,11 +367,13 @@ mainLoop:
 				a := stack[sp-2]
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					bv := int64(b.Raw())
-					if bv != 0 {
-						stack[sp-2] = bytecode.NewInt(int64(a.Raw()) / bv)
-					} else {
-						stack[sp-2] = bytecode.NullValue
+					if bv == 0 {
+						vm.sp = sp
+						vm.throwError("DivideByZeroException", "division by zero")
+						sp = vm.sp
+						continue mainLoop
 					}
+					stack[sp-2] = bytecode.NewInt(int64(a.Raw()) / bv)
 				} else {
 					stack[sp-2] = Helper_Div(a, b)
 				}
@@ -237,18 +383,23 @@ mainLoop:
 				// 内联取模
 				b := stack[sp-1]
 				a := stack[sp-2]
-				bi := int64(b.Raw())
-				if bi != 0 {
-					stack[sp-2] = bytecode.NewInt(int64(a.Raw()) % bi)
-				} else {
-					stack[sp-2] = bytecode.NullValue
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt || b.Raw() == 0 {
+					// 非整数或除零交给分派表处理
+					vm.sp = sp
+					opMod(vm)
+					sp = vm.sp
+					continue mainLoop
 				}
+				stack[sp-2] = bytecode.NewInt(int64(a.Raw()) % int64(b.Raw()))
 				sp--
 
 			case bytecode.OpLt:
 				// 内联小于比较（整数快速路径）
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					stack[sp-2] = bytecode.NewBool(int64(a.Raw()) < int64(b.Raw()))
 				} else {
@@ -260,6 +411,9 @@ mainLoop:
 				// 内联小于等于比较
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					stack[sp-2] = bytecode.NewBool(int64(a.Raw()) <= int64(b.Raw()))
 				} else {
@@ -271,6 +425,9 @@ mainLoop:
 				// 内联大于比较
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					stack[sp-2] = bytecode.NewBool(int64(a.Raw()) > int64(b.Raw()))
 				} else {
@@ -282,6 +439,9 @@ mainLoop:
 				// 内联大于等于比较
 				b := stack[sp-1]
 				a := stack[sp-2]
+				if vm.quickening {
+					vm.observe(frame.ip-1, binaryType(a, b))
+				}
 				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
 					stack[sp-2] = bytecode.NewBool(int64(a.Raw()) >= int64(b.Raw()))
 				} else {
@@ -289,6 +449,237 @@ mainLoop:
 				}
 				sp--
 
+			// ===== 快速指令 (quickening 改写得到，守卫失败时去优化并重新执行) =====
+
+			case bytecode.OpAddInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewInt(int64(a.Raw()) + int64(b.Raw()))
+				sp--
+
+			case bytecode.OpAddFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewFloat(a.AsFloat() + b.AsFloat())
+				sp--
+
+			case bytecode.OpAddString:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValString || b.Type() != bytecode.ValString {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				vm.sp = sp - 2
+				vm.pushString(bytecode.NewString(a.AsString() + b.AsString()))
+				sp = vm.sp
+				continue mainLoop
+
+			case bytecode.OpSubInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewInt(int64(a.Raw()) - int64(b.Raw()))
+				sp--
+
+			case bytecode.OpSubFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewFloat(a.AsFloat() - b.AsFloat())
+				sp--
+
+			case bytecode.OpMulInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewInt(int64(a.Raw()) * int64(b.Raw()))
+				sp--
+
+			case bytecode.OpMulFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewFloat(a.AsFloat() * b.AsFloat())
+				sp--
+
+			case bytecode.OpLtInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(int64(a.Raw()) < int64(b.Raw()))
+				sp--
+
+			case bytecode.OpLtFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(a.AsFloat() < b.AsFloat())
+				sp--
+
+			case bytecode.OpLeInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(int64(a.Raw()) <= int64(b.Raw()))
+				sp--
+
+			case bytecode.OpLeFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(a.AsFloat() <= b.AsFloat())
+				sp--
+
+			case bytecode.OpGtInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(int64(a.Raw()) > int64(b.Raw()))
+				sp--
+
+			case bytecode.OpGtFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(a.AsFloat() > b.AsFloat())
+				sp--
+
+			case bytecode.OpGeInt:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValInt || b.Type() != bytecode.ValInt {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(int64(a.Raw()) >= int64(b.Raw()))
+				sp--
+
+			case bytecode.OpGeFloat:
+				b := stack[sp-1]
+				a := stack[sp-2]
+				if a.Type() != bytecode.ValFloat || b.Type() != bytecode.ValFloat {
+					vm.deoptimize(frame.ip - 1)
+					continue
+				}
+				stack[sp-2] = bytecode.NewBool(a.AsFloat() >= b.AsFloat())
+				sp--
+
+			// ===== 超级指令 =====
+			// 操作数从原序列中读取，frame.ip 指向第一条 LOAD_LOCAL 的操作数。
+			// 守卫失败时只执行第一条 LOAD_LOCAL，随后逐条执行原序列
+
+			case bytecode.OpAddLocals:
+				// LOAD_LOCAL a; LOAD_LOCAL b; ADD
+				a := stack[bp+(int(code[frame.ip])<<8|int(code[frame.ip+1]))]
+				b := stack[bp+(int(code[frame.ip+3])<<8|int(code[frame.ip+4]))]
+				if a.Type() == bytecode.ValInt && b.Type() == bytecode.ValInt {
+					stack[sp] = bytecode.NewInt(int64(a.Raw()) + int64(b.Raw()))
+					frame.ip += 6
+				} else {
+					stack[sp] = a
+					frame.ip += 2
+				}
+				sp++
+
+			case bytecode.OpLtLocalConstJump:
+				// LOAD_LOCAL a; PUSH k; LT; JUMP_IF_FALSE offset (条件值留在栈上)
+				a := stack[bp+(int(code[frame.ip])<<8|int(code[frame.ip+1]))]
+				k := constants[int(code[frame.ip+3])<<8|int(code[frame.ip+4])]
+				if a.Type() == bytecode.ValInt && k.Type() == bytecode.ValInt {
+					lt := int64(a.Raw()) < int64(k.Raw())
+					stack[sp] = bytecode.NewBool(lt)
+					offset := int(code[frame.ip+7])<<8 | int(code[frame.ip+8])
+					frame.ip += 9
+					if !lt {
+						frame.ip += offset
+					}
+				} else {
+					stack[sp] = a
+					frame.ip += 2
+				}
+				sp++
+
+			case bytecode.OpGetLocalField:
+				// LOAD_LOCAL a; GET_FIELD name
+				// 字段读取交给序列中的字段指令 (可能已被 quickening 改写为单态版本)
+				stack[sp] = stack[bp+(int(code[frame.ip])<<8|int(code[frame.ip+1]))]
+				sp++
+				getOp := code[frame.ip+2]
+				frame.ip += 3
+				vm.sp = sp
+				dispatchTable[getOp](vm)
+				sp = vm.sp
+				continue mainLoop
+
+			case bytecode.OpIncLocal:
+				// LOAD_LOCAL a; ONE|PUSH k; ADD; STORE_LOCAL a; POP
+				slot := bp + (int(code[frame.ip])<<8 | int(code[frame.ip+1]))
+				a := stack[slot]
+				k, n := bytecode.OneValue, 8
+				if bytecode.OpCode(code[frame.ip+2]) == bytecode.OpPush {
+					k, n = constants[int(code[frame.ip+3])<<8|int(code[frame.ip+4])], 10
+				}
+				if a.Type() == bytecode.ValInt && k.Type() == bytecode.ValInt {
+					stack[slot] = bytecode.NewInt(int64(a.Raw()) + int64(k.Raw()))
+					frame.ip += n
+				} else {
+					stack[sp] = a
+					sp++
+					frame.ip += 2
+				}
+
+			case bytecode.OpIncLocalPost:
+				// LOAD_LOCAL a; DUP; ONE; ADD; STORE_LOCAL a; POP; POP
+				slot := bp + (int(code[frame.ip])<<8 | int(code[frame.ip+1]))
+				a := stack[slot]
+				if a.Type() == bytecode.ValInt {
+					stack[slot] = bytecode.NewInt(int64(a.Raw()) + 1)
+					frame.ip += 10
+				} else {
+					stack[sp] = a
+					sp++
+					frame.ip += 2
+				}
+
 			case bytecode.OpPush:
 				// 内联常量加载 (Big Endian)
 				idx := int(code[frame.ip])<<8 | int(code[frame.ip+1])
@@ -331,6 +722,9 @@ mainLoop:
 				offset := int(code[frame.ip])<<8 | int(code[frame.ip+1])
 				frame.ip += 2
 				frame.ip -= offset
+				if vm.limits.enabled && !vm.checkpoint() {
+					continue mainLoop
+				}
 
 			case bytecode.OpZero:
 				// 内联压入0
@@ -403,23 +797,23 @@ mainLoop:
 						}
 						// 保存当前sp到vm
 						vm.sp = sp
-						// 压入新帧
-						newBp := sp - argCount
+						// 压入新帧 (栈可能扩容，栈溢出时异常已展开)
+						newBp := sp - argCount - 1 // slot 0 为被调用者
 						vm.pushFrame(fn, newBp)
+						sp = vm.sp
 						// 跳转到外层循环获取新frame
 						continue mainLoop
 					}
 					// 内置函数处理
 					if fn != nil && fn.IsBuiltin && fn.BuiltinFn != nil {
-						args := make([]bytecode.Value, argCount)
-						for i := argCount - 1; i >= 0; i-- {
-							sp--
-							args[i] = stack[sp]
+						fp := vm.fp
+						vm.sp = sp
+						vm.callBuiltin(fn, argCount)
+						sp = vm.sp
+						if vm.fp != fp || vm.hasError || &vm.stack[0] != &stack[0] {
+							// 内置函数抛出的异常越过了当前帧，或嵌套执行替换了栈
+							continue mainLoop
 						}
-						sp-- // 弹出函数本身
-						result := fn.BuiltinFn(args)
-						stack[sp] = result
-						sp++
 						continue
 					}
 				}
@@ -429,63 +823,46 @@ mainLoop:
 				vm.sp = sp
 				dispatchTable[byte(op)](vm)
 				sp = vm.sp
-				if vm.hasError {
-					return bytecode.NullValue
-				}
 				continue mainLoop
 
-			case bytecode.OpReturn:
+			case bytecode.OpReturn, bytecode.OpReturnNull:
 				// 内联函数返回
-				result := stack[sp-1]
-				sp--
+				result := bytecode.NullValue
+				if op == bytecode.OpReturn {
+					result = stack[sp-1]
+					sp--
+				}
+
+				if len(frame.handlers) > 0 {
+					// 途经 try/catch：可能需要先执行 finally
+					vm.sp = sp
+					vm.returnValue(result)
+					sp = vm.sp
+					continue mainLoop
+				}
 
 				// 弹出当前帧
-				vm.fp--
-				if vm.fp == 0 {
-					// 主函数返回
+				if vm.hookEvents&HookCall != 0 {
 					vm.sp = sp
-					return result
+					vm.hookExit(frame.function)
 				}
+				vm.fp--
 
-				// 清理栈上的局部变量和参数
+				// 清理栈上的局部变量、参数和被调用者
 				sp = bp
-				// 弹出被调用者
-				if !frame.isStaticCall && sp > 0 {
-					sp--
-				}
-				// 压入返回值
+
+				// 压入返回值 (入口帧返回时由 finishRun 取出)
 				stack[sp] = result
 				sp++
 				// 跳转到外层循环获取新frame
 				continue mainLoop
 
-			case bytecode.OpReturnNull:
-				// 内联null返回
-				vm.fp--
-				if vm.fp == 0 {
-					vm.sp = sp
-					return bytecode.NullValue
-				}
-				sp = bp
-				if !frame.isStaticCall && sp > 0 {
-					sp--
-				}
-				stack[sp] = bytecode.NullValue
-				sp++
-				continue mainLoop
-
 			// ===== 其他操作使用分派表 =====
 			default:
 				vm.sp = sp
 				dispatchTable[byte(op)](vm)
 				sp = vm.sp
-				if vm.hasError {
-					return bytecode.NullValue
-				}
-				// 检查是否需要重新获取frame (函数调用/返回会改变)
-				if vm.fp == 0 {
-					break mainLoop
-				}
+				// 检查是否需要重新获取frame (函数调用/返回/异常会改变)
 				continue mainLoop
 			}
 		}
@@ -501,6 +878,26 @@ mainLoop:
 	return bytecode.NullValue
 }
 
+// finishRun 执行循环因返回、错误或异常离开入口帧时收尾
+func (vm *VM) finishRun() bytecode.Value {
+	if vm.hasError {
+		return bytecode.NullValue
+	}
+	if ex := vm.pendingException; ex != nil {
+		// 最外层执行循环：异常未被捕获，终止执行
+		// 嵌套执行时保留给外层（如内置函数回调）继续展开
+		if vm.runDepth == 1 {
+			vm.pendingException = nil
+			vm.reportUncaught(ex)
+		}
+		return bytecode.NullValue
+	}
+	if vm.sp > 0 {
+		return vm.pop()
+	}
+	return bytecode.NullValue
+}
+
 // ============================================================================
 // 辅助方法
 // ============================================================================
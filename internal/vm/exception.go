@@ -0,0 +1,530 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 异常处理器
+// ============================================================================
+//
+// 编译器为 try 语句生成如下布局:
+//
+//	ENTER_TRY catchCount finallyOffset [typeIdx catchOffset]*
+//	  <try 体>
+//	LEAVE_TRY
+//	JUMP -> finally/结束
+//	ENTER_CATCH typeIdx  <catch 体>  JUMP -> finally/结束     (每个 catch 一段)
+//	ENTER_FINALLY <finally 体> LEAVE_FINALLY                   (可选)
+//
+// 所有偏移量都相对于 ENTER_TRY 指令自身的位置。
+// 每个调用帧维护自己的处理器栈，异常沿处理器栈和调用栈逐级展开。
+
+// handlerKind 处理器记录类型
+type handlerKind uint8
+
+const (
+	handlerTry     handlerKind = iota // 正在执行 try 体
+	handlerCatch                      // 正在执行 catch 体 (仅当存在 finally 时保留)
+	handlerFinally                    // 正在执行 finally 体 (记录挂起的动作)
+)
+
+// pendingAction finally 块结束后需要继续执行的动作
+type pendingAction uint8
+
+const (
+	pendingNone   pendingAction = iota // 正常流程
+	pendingThrow                       // 继续抛出异常
+	pendingReturn                      // 继续函数返回
+)
+
+// tryHandler 异常处理器记录
+type tryHandler struct {
+	kind      handlerKind
+	tryIP     int // ENTER_TRY 指令位置
+	finallyIP int // ENTER_FINALLY 指令位置 (-1 表示没有 finally)
+	sp        int // 进入 try 时的栈高度
+
+	// 以下字段仅用于 finally 记录
+	pending   pendingAction
+	exception *bytecode.Exception
+	result    bytecode.Value
+}
+
+// ============================================================================
+// 异常操作码
+// ============================================================================
+
+// opThrow 抛出异常
+func opThrow(vm *VM) {
+	v := vm.pop()
+	vm.throwValue(v)
+}
+
+// opEnterTry 进入 try 块，注册处理器
+// 格式: OpEnterTry catchCount:u8 finallyOffset:i16 [typeIdx:u16 catchOffset:i16]*
+func opEnterTry(vm *VM) {
+	frame := vm.currentFrame()
+	tryIP := frame.ip - 1
+	catchCount := int(frame.chunk.Code[frame.ip])
+	finallyOffset := int(int16(vm.readShortAt(frame, frame.ip+1)))
+
+	finallyIP := -1
+	if finallyOffset >= 0 {
+		finallyIP = tryIP + finallyOffset
+	}
+
+	frame.handlers = append(frame.handlers, tryHandler{
+		kind:      handlerTry,
+		tryIP:     tryIP,
+		finallyIP: finallyIP,
+		sp:        vm.sp,
+	})
+
+	// 跳过 catch 表
+	frame.ip += 3 + catchCount*4
+}
+
+// opLeaveTry 离开 try 块
+// 弹出当前帧最内层的处理器记录（正常离开 try 体，或 break/continue 穿越 try 时由编译器生成）
+func opLeaveTry(vm *VM) {
+	frame := vm.currentFrame()
+	if n := len(frame.handlers); n > 0 {
+		frame.handlers = frame.handlers[:n-1]
+	}
+}
+
+// opEnterCatch 进入 catch 块
+// 类型匹配已在展开时完成，异常值已压栈
+func opEnterCatch(vm *VM) {
+	vm.readShort()
+}
+
+// opEnterFinally 正常流程进入 finally 块
+func opEnterFinally(vm *VM) {
+	frame := vm.currentFrame()
+	ip := frame.ip - 1
+
+	// catch 体正常结束时，同一 try 的处理器仍处于 catch 状态，此时移除
+	if n := len(frame.handlers); n > 0 {
+		h := &frame.handlers[n-1]
+		if h.kind == handlerCatch && h.finallyIP == ip {
+			frame.handlers = frame.handlers[:n-1]
+		}
+	}
+
+	frame.handlers = append(frame.handlers, tryHandler{
+		kind:      handlerFinally,
+		finallyIP: ip,
+		sp:        vm.sp,
+	})
+}
+
+// opLeaveFinally 离开 finally 块，继续执行挂起的动作
+func opLeaveFinally(vm *VM) {
+	frame := vm.currentFrame()
+	n := len(frame.handlers)
+	if n == 0 || frame.handlers[n-1].kind != handlerFinally {
+		vm.runtimeError("unbalanced finally block")
+		return
+	}
+	h := frame.handlers[n-1]
+	frame.handlers = frame.handlers[:n-1]
+
+	switch h.pending {
+	case pendingThrow:
+		vm.unwind(h.exception)
+	case pendingReturn:
+		vm.returnValue(h.result)
+	}
+}
+
+// opRethrow 重新抛出挂起的异常
+func opRethrow(vm *VM) {
+	frame := vm.currentFrame()
+	for i := len(frame.handlers) - 1; i >= 0; i-- {
+		h := &frame.handlers[i]
+		if h.kind == handlerFinally && h.pending == pendingThrow {
+			ex := h.exception
+			frame.handlers = frame.handlers[:i]
+			vm.unwind(ex)
+			return
+		}
+	}
+	if vm.currentException != nil {
+		vm.unwind(vm.currentException)
+		return
+	}
+	vm.runtimeError("no exception to rethrow")
+}
+
+// ============================================================================
+// 抛出与展开
+// ============================================================================
+
+// throwValue 抛出任意 Sola 值
+// 对象异常会在首次抛出时记录调用栈，字符串等普通值包装为 RuntimeException
+func (vm *VM) throwValue(v bytecode.Value) {
+	var ex *bytecode.Exception
+
+	switch {
+	case v.IsObject():
+		obj := v.AsObject()
+		ex = bytecode.NewExceptionFromObject(obj).AsException()
+		if trace, ok := obj.GetField("stackTrace"); ok && len(trace.AsArray()) > 0 {
+			// 重新抛出：保留首次抛出时的调用栈
+			file, _ := obj.GetField("file")
+			line, _ := obj.GetField("line")
+			ex.File = file.AsString()
+			ex.Line = int(line.AsInt())
+		} else {
+			ex.SetStackFrames(vm.captureStackTrace())
+		}
+	case v.IsException():
+		ex = v.AsException()
+		if len(ex.StackFrames) == 0 {
+			ex.SetStackFrames(vm.captureStackTrace())
+		}
+	case v.IsNull():
+		ex = vm.newException("NullReferenceException", "cannot throw null")
+	default:
+		ex = vm.newException("RuntimeException", v.String())
+	}
+
+	vm.raise(ex)
+}
+
+// throwError 抛出由 VM 自身产生的异常（如除零）
+func (vm *VM) throwError(typeName, format string, args ...interface{}) {
+	vm.raise(vm.newException(typeName, fmt.Sprintf(format, args...)))
+}
+
+// newException 创建指定类型的异常
+// 如果已加载同名的异常类，则实例化该类，使 catch 块能调用 getMessage() 等方法
+func (vm *VM) newException(typeName, message string) *bytecode.Exception {
+	var ex *bytecode.Exception
+	if class := vm.GetClass(typeName); class != nil {
+		obj := vm.instantiate(class)
+		obj.SetField("message", bytecode.NewString(message))
+		ex = bytecode.NewExceptionFromObject(obj).AsException()
+	} else {
+		ex = bytecode.NewException(typeName, message, 0).AsException()
+	}
+	ex.SetStackFrames(vm.captureStackTrace())
+	return ex
+}
+
+// unwind 沿处理器栈和调用栈展开，直到找到能处理异常的 catch/finally
+// 异常越过当前执行循环的入口帧时，记录为 pendingException 交给外层处理
+func (vm *VM) unwind(ex *bytecode.Exception) {
+	for vm.fp > vm.baseFP {
+		frame := &vm.frames[vm.fp-1]
+
+		for len(frame.handlers) > 0 {
+			n := len(frame.handlers)
+			h := frame.handlers[n-1]
+			frame.handlers = frame.handlers[:n-1]
+
+			switch h.kind {
+			case handlerTry:
+				if catchIP, ok := vm.findCatch(frame, h.tryIP, ex); ok {
+					// 有 finally 时保留处理器，使 catch 体中的异常仍能执行 finally
+					if h.finallyIP >= 0 {
+						h.kind = handlerCatch
+						frame.handlers = append(frame.handlers, h)
+					}
+					vm.sp = h.sp
+					vm.push(exceptionValue(ex))
+					vm.currentException = ex
+					frame.ip = catchIP
+					return
+				}
+				if h.finallyIP >= 0 {
+					vm.enterFinally(frame, h, pendingThrow, ex, bytecode.NullValue)
+					return
+				}
+			case handlerCatch:
+				vm.enterFinally(frame, h, pendingThrow, ex, bytecode.NullValue)
+				return
+			case handlerFinally:
+				// finally 体中抛出的异常取代挂起的动作
+			}
+		}
+
+		// 当前帧无法处理，弹出调用帧
+		if vm.hookEvents&HookCall != 0 {
+			vm.hookExit(frame.function)
+		}
+		vm.fp--
+		vm.sp = frame.bp
+	}
+
+	vm.pendingException = ex
+}
+
+// findCatch 在 try 的 catch 表中查找与异常匹配的处理器，返回 catch 块入口
+func (vm *VM) findCatch(frame *CallFrame, tryIP int, ex *bytecode.Exception) (int, bool) {
+	code := frame.chunk.Code
+	catchCount := int(code[tryIP+1])
+	pos := tryIP + 4
+	for i := 0; i < catchCount; i++ {
+		typeIdx := vm.readShortAt(frame, pos)
+		catchOffset := int(int16(vm.readShortAt(frame, pos+2)))
+		pos += 4
+
+		typeName := frame.chunk.Constants[typeIdx].AsString()
+		if exceptionMatches(ex, typeName) {
+			return tryIP + catchOffset, true
+		}
+	}
+	return 0, false
+}
+
+// enterFinally 带着挂起的动作跳转到 finally 块
+func (vm *VM) enterFinally(frame *CallFrame, h tryHandler, action pendingAction, ex *bytecode.Exception, result bytecode.Value) {
+	vm.sp = h.sp
+	frame.handlers = append(frame.handlers, tryHandler{
+		kind:      handlerFinally,
+		finallyIP: h.finallyIP,
+		sp:        h.sp,
+		pending:   action,
+		exception: ex,
+		result:    result,
+	})
+	// 跳过 ENTER_FINALLY 指令，记录已经压入
+	frame.ip = h.finallyIP + 1
+}
+
+// returnValue 从当前帧返回，途经的 finally 块会先执行
+// 返回 true 表示帧已弹出
+func (vm *VM) returnValue(result bytecode.Value) bool {
+	frame := vm.currentFrame()
+	for len(frame.handlers) > 0 {
+		n := len(frame.handlers)
+		h := frame.handlers[n-1]
+		frame.handlers = frame.handlers[:n-1]
+		if (h.kind == handlerTry || h.kind == handlerCatch) && h.finallyIP >= 0 {
+			vm.enterFinally(frame, h, pendingReturn, nil, result)
+			return false
+		}
+	}
+
+	if vm.hookEvents&HookCall != 0 {
+		vm.hookExit(frame.function)
+	}
+	vm.fp--
+	vm.sp = frame.bp
+	vm.push(result)
+	return true
+}
+
+// exceptionValue 返回异常在 Sola 代码中的表示：对象异常为对象本身
+func exceptionValue(ex *bytecode.Exception) bytecode.Value {
+	if ex.Object != nil {
+		return bytecode.NewObject(ex.Object)
+	}
+	return bytecode.NewExceptionValue(ex)
+}
+
+// exceptionMatches 检查异常是否匹配 catch 声明的类型（包括父类和接口）
+func exceptionMatches(ex *bytecode.Exception, typeName string) bool {
+	// 支持完整限定名: sola.lang.Exception -> Exception
+	shortName := typeName
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		shortName = typeName[i+1:]
+	}
+	if shortName == "Throwable" || shortName == "dynamic" {
+		return true
+	}
+
+	if ex.Object != nil {
+		for c := ex.Object.Class; c != nil; c = c.Parent {
+			if c.Name == shortName || c.FullName() == typeName {
+				return true
+			}
+			for _, iface := range c.Implements {
+				if iface == shortName || iface == typeName {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return ex.IsExceptionOfType(shortName)
+}
+
+// ============================================================================
+// 调用栈
+// ============================================================================
+
+// captureStackTrace 捕获当前 Sola 调用栈（最内层在前）
+func (vm *VM) captureStackTrace() []bytecode.StackFrame {
+	return stackTrace(vm.frames, vm.fp)
+}
+
+// 调用栈截断时保留的最内层和最外层帧数
+const (
+	traceHeadFrames = 48
+	traceTailFrames = 16
+)
+
+// stackTrace 由调用帧生成调用栈 (栈顶在前)
+// 连续相同的帧 (如直接递归) 合并为一帧，合并后仍然过长时只保留最内层和最外层的帧
+func stackTrace(callFrames []CallFrame, fp int) []bytecode.StackFrame {
+	frames := make([]bytecode.StackFrame, 0, min(fp, traceHeadFrames+traceTailFrames))
+	for i := fp - 1; i >= 0; i-- {
+		frame := &callFrames[i]
+		if frame.function == nil {
+			continue
+		}
+		line := 0
+		if frame.chunk != nil && frame.ip > 0 && frame.ip-1 < len(frame.chunk.Lines) {
+			line = frame.chunk.Lines[frame.ip-1]
+		}
+		f := bytecode.StackFrame{
+			FunctionName: frame.function.Name,
+			FileName:     frame.function.SourceFile,
+			LineNumber:   line,
+			ClassName:    frame.function.ClassName,
+		}
+		if n := len(frames); n > 0 && sameFrame(frames[n-1], f) {
+			frames[n-1].Repeated++
+			continue
+		}
+		frames = append(frames, f)
+	}
+
+	if len(frames) > traceHeadFrames+traceTailFrames {
+		omitted := len(frames) - traceHeadFrames - traceTailFrames
+		frames[traceHeadFrames-1].Omitted = omitted
+		frames = append(frames[:traceHeadFrames], frames[traceHeadFrames+omitted:]...)
+	}
+	return frames
+}
+
+// sameFrame 判断两个调用栈帧是否指向同一位置
+func sameFrame(a, b bytecode.StackFrame) bool {
+	return a.FunctionName == b.FunctionName && a.ClassName == b.ClassName &&
+		a.FileName == b.FileName && a.LineNumber == b.LineNumber
+}
+
+// formatStackTrace 格式化调用栈
+func formatStackTrace(frames []bytecode.StackFrame) string {
+	var sb strings.Builder
+	for i, f := range frames {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		name := f.FunctionName
+		if f.ClassName != "" {
+			name = f.ClassName + "." + name
+		}
+		if f.FileName != "" {
+			fmt.Fprintf(&sb, "    at %s (%s:%d)", name, f.FileName, f.LineNumber)
+		} else {
+			fmt.Fprintf(&sb, "    at %s (line %d)", name, f.LineNumber)
+		}
+		for _, note := range f.Elisions() {
+			sb.WriteString("\n    ")
+			sb.WriteString(note)
+		}
+	}
+	return sb.String()
+}
+
+// reportUncaught 将未捕获的异常转为运行时错误
+func (vm *VM) reportUncaught(ex *bytecode.Exception) {
+	vm.uncaught = ex
+	vm.hasError = true
+	vm.errorMsg = uncaughtMessage(ex)
+}
+
+// uncaughtMessage 格式化未捕获的异常：类型、消息、调用栈和异常链
+func uncaughtMessage(ex *bytecode.Exception) string {
+	message := ex.Message
+	if ex.Object != nil {
+		if msgVal, ok := ex.Object.GetField("message"); ok {
+			message = msgVal.AsString()
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Uncaught %s: %s", ex.Type, message)
+	if ex.Object != nil && len(ex.StackFrames) == 0 {
+		// 重新抛出的对象异常：使用对象上记录的调用栈
+		trace, _ := ex.Object.GetField("stackTrace")
+		for _, frame := range trace.AsArray() {
+			sb.WriteString("\n    at ")
+			sb.WriteString(frame.AsString())
+		}
+	} else if len(ex.StackFrames) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(formatStackTrace(ex.StackFrames))
+	}
+	for cause := ex.Cause; cause != nil; cause = cause.Cause {
+		fmt.Fprintf(&sb, "\nCaused by: %s: %s", cause.Type, cause.Message)
+	}
+	return sb.String()
+}
+
+// ============================================================================
+// 内置函数调用
+// ============================================================================
+
+// callBuiltin 调用内置函数
+// 内置函数返回异常值、发生 panic 或其内部回调 VM 时抛出的异常都会转为 Sola 异常
+func (vm *VM) callBuiltin(fn *bytecode.Function, argCount int) {
+	args := make([]bytecode.Value, argCount)
+	copy(args, vm.stack[vm.sp-argCount:vm.sp])
+	vm.sp -= argCount + 1 // 参数和函数本身
+
+	result, panicked := vm.invokeBuiltin(fn, args)
+	if panicked != nil {
+		vm.raise(panicked)
+		return
+	}
+	if ex := vm.pendingException; ex != nil {
+		vm.pendingException = nil
+		vm.unwind(ex)
+		return
+	}
+	if result.IsException() {
+		ex := result.AsException()
+		if len(ex.StackFrames) == 0 {
+			ex.SetStackFrames(vm.captureStackTrace())
+		}
+		vm.raise(ex)
+		return
+	}
+	vm.push(result)
+}
+
+// invokeBuiltin 执行内置函数并将 Go panic 转为异常
+func (vm *VM) invokeBuiltin(fn *bytecode.Function, args []bytecode.Value) (result bytecode.Value, ex *bytecode.Exception) {
+	defer func() {
+		if r := recover(); r != nil {
+			ex = vm.newException("RuntimeException", fmt.Sprint(r))
+		}
+	}()
+	return fn.BuiltinFn(args), nil
+}
+
+// readShortAt 读取指定位置的 u16 (不移动 ip)
+func (vm *VM) readShortAt(frame *CallFrame, pos int) uint16 {
+	code := frame.chunk.Code
+	return uint16(code[pos])<<8 | uint16(code[pos+1])
+}
+
+// instantiate 创建类实例，属性默认值按继承链由类的字段布局确定
+// 只记录分配而不检查堆上限：VM 抛出的异常 (包括 OutOfMemoryError 本身) 也经由这里创建
+func (vm *VM) instantiate(class *bytecode.Class) *bytecode.Object {
+	obj := bytecode.NewObjectInstance(class)
+	size := sizeOfObject(obj)
+	vm.record(AllocObject, size)
+	vm.mem.sinceGC += uint64(size)
+	return obj
+}
@@ -0,0 +1,287 @@
+package vm
+
+import (
+	"time"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 执行钩子
+// ============================================================================
+//
+// 调试器和性能分析器通过钩子观察执行：行号变化、函数进入和退出、异常抛出、
+// 分配、协程切换、死锁以及字段和全局变量的读写。每个观察点先检查 hookEvents 位掩码，
+// 没有钩子关心该事件时不做其他工作，未安装钩子的执行只多一次位运算。
+//
+// 钩子在执行循环所在的 Go 协程上同步调用，可以阻塞 (调试器在断点处暂停)。
+// 行号钩子返回后执行循环重新读取栈和帧，钩子可以修改局部变量或在 VM 上嵌套
+// 执行代码；其他钩子不能改变调用栈。
+
+// HookEvent 钩子关心的事件 (位掩码)
+type HookEvent uint8
+
+const (
+	// HookLine 即将执行新的一行：进入函数后的第一行，或行号与上一条执行的指令不同
+	// (循环每次迭代回到条件所在的行；整个循环写在一行时只报告一次)
+	HookLine HookEvent = 1 << iota
+	// HookCall 函数进入和退出 (返回、异常展开和尾调用都会退出帧)
+	HookCall
+	// HookException 抛出异常，在展开之前调用，调用栈仍是抛出处的调用栈
+	HookException
+	// HookAllocation 脚本分配值
+	HookAllocation
+	// HookCoroutine 协程切换
+	HookCoroutine
+	// HookDeadlock 所有协程都在等待，在报告死锁之前调用，各协程仍停在阻塞处
+	HookDeadlock
+	// HookAccess 读写对象字段 (OpGetField/OpSetField) 和全局变量 (OpLoadGlobal/OpStoreGlobal)，
+	// 在访问完成之后调用；安装后单态字段读取的快速指令回退为通用指令
+	HookAccess
+)
+
+// Access 一次字段或全局变量访问
+type Access struct {
+	Object *bytecode.Object // 字段所属的对象，访问全局变量时为 nil
+	Field  string           // 字段名
+	Global int              // 全局变量的索引
+	Write  bool
+	Value  bytecode.Value // 读到或写入的值
+}
+
+// Hook 执行钩子，只有 Events 中包含的事件会被调用
+type Hook interface {
+	Events() HookEvent
+	OnLine(fn *bytecode.Function, line int)
+	OnEnter(fn *bytecode.Function)
+	OnExit(fn *bytecode.Function)
+	OnThrow(ex *bytecode.Exception)
+	OnAllocation(kind AllocKind, size int)
+	OnCoroutineSwitch(from, to int64)
+	OnDeadlock()
+	OnAccess(a Access)
+}
+
+// BaseHook 所有方法都为空的钩子，嵌入后只需实现关心的方法
+type BaseHook struct{}
+
+func (BaseHook) Events() HookEvent                { return 0 }
+func (BaseHook) OnLine(*bytecode.Function, int)   {}
+func (BaseHook) OnEnter(*bytecode.Function)       {}
+func (BaseHook) OnExit(*bytecode.Function)        {}
+func (BaseHook) OnThrow(*bytecode.Exception)      {}
+func (BaseHook) OnAllocation(AllocKind, int)      {}
+func (BaseHook) OnCoroutineSwitch(from, to int64) {}
+func (BaseHook) OnDeadlock()                      {}
+func (BaseHook) OnAccess(Access)                  {}
+
+// installedHook 已安装的钩子和安装时取得的事件
+type installedHook struct {
+	hook   Hook
+	events HookEvent
+}
+
+// AddHook 安装钩子，Events 在安装时读取一次
+func (vm *VM) AddHook(h Hook) {
+	vm.hooks = append(vm.hooks, installedHook{hook: h, events: h.Events()})
+	vm.hookEvents |= h.Events()
+}
+
+// RemoveHook 移除钩子
+func (vm *VM) RemoveHook(h Hook) {
+	vm.hookEvents = 0
+	hooks := vm.hooks[:0]
+	for _, ih := range vm.hooks {
+		if ih.hook != h {
+			hooks = append(hooks, ih)
+			vm.hookEvents |= ih.events
+		}
+	}
+	clear(vm.hooks[len(hooks):])
+	vm.hooks = hooks
+}
+
+// FunctionName 返回函数在调用栈中显示的名称 (方法为 类名.方法名)
+func FunctionName(fn *bytecode.Function) string {
+	if fn.ClassName != "" {
+		return fn.ClassName + "." + fn.Name
+	}
+	return fn.Name
+}
+
+// LineAt 返回字节码块中指令偏移对应的源码行号，有调试信息时使用调试信息，未知时为 0
+func LineAt(chunk *bytecode.Chunk, ip int) int {
+	if chunk.Debug != nil {
+		return chunk.Debug.GetLine(ip)
+	}
+	if ip >= 0 && ip < len(chunk.Lines) {
+		return chunk.Lines[ip]
+	}
+	return 0
+}
+
+// traceLine 在执行 frame.ip 处的指令之前检查是否进入了新的一行
+// 帧的第一条指令和行号与上一条执行的指令不同时调用行号钩子并返回 true；
+// 钩子返回后执行循环从同一位置重新进入，此时不会再次报告
+func (vm *VM) traceLine(frame *CallFrame) bool {
+	ip := frame.ip
+	last := frame.traceIP - 1
+	frame.traceIP = ip + 1
+	line := LineAt(frame.chunk, ip)
+	if line <= 0 || (last >= 0 && LineAt(frame.chunk, last) == line) {
+		return false
+	}
+	fn := frame.function
+	vm.tracing = true
+	for _, ih := range vm.hooks {
+		if ih.events&HookLine != 0 {
+			ih.hook.OnLine(fn, line)
+		}
+	}
+	vm.tracing = false
+	return true
+}
+
+// hookEnter 报告进入函数 (调用方已检查 HookCall)
+func (vm *VM) hookEnter(fn *bytecode.Function) {
+	for _, ih := range vm.hooks {
+		if ih.events&HookCall != 0 {
+			ih.hook.OnEnter(fn)
+		}
+	}
+}
+
+// hookExit 报告退出函数 (调用方已检查 HookCall)
+func (vm *VM) hookExit(fn *bytecode.Function) {
+	for _, ih := range vm.hooks {
+		if ih.events&HookCall != 0 {
+			ih.hook.OnExit(fn)
+		}
+	}
+}
+
+// raise 报告异常抛出后沿调用栈展开
+// 用于异常产生的位置；重新抛出和越过嵌套执行边界的继续展开直接调用 unwind
+func (vm *VM) raise(ex *bytecode.Exception) {
+	if vm.hookEvents&HookException != 0 {
+		for _, ih := range vm.hooks {
+			if ih.events&HookException != 0 {
+				ih.hook.OnThrow(ex)
+			}
+		}
+	}
+	vm.unwind(ex)
+}
+
+// hookAllocation 报告一次分配 (调用方已检查 HookAllocation)
+func (vm *VM) hookAllocation(kind AllocKind, size int) {
+	for _, ih := range vm.hooks {
+		if ih.events&HookAllocation != 0 {
+			ih.hook.OnAllocation(kind, size)
+		}
+	}
+}
+
+// hookSwitch 报告换入协程，协程第一次运行时报告进入其入口函数
+func (vm *VM) hookSwitch(from, to *coroutine) {
+	if vm.hookEvents&HookCoroutine != 0 && from != nil && from != to {
+		for _, ih := range vm.hooks {
+			if ih.events&HookCoroutine != 0 {
+				ih.hook.OnCoroutineSwitch(from.obj.ID, to.obj.ID)
+			}
+		}
+	}
+	if !to.started {
+		to.started = true
+		if vm.hookEvents&HookCall != 0 && to.fp > 0 {
+			vm.hookEnter(to.frames[0].function)
+		}
+	}
+}
+
+// hookDeadlock 报告死锁 (调用方已检查 HookDeadlock)
+func (vm *VM) hookDeadlock() {
+	for _, ih := range vm.hooks {
+		if ih.events&HookDeadlock != 0 {
+			ih.hook.OnDeadlock()
+		}
+	}
+}
+
+// hookAccess 报告字段或全局变量访问 (调用方已检查 HookAccess)
+func (vm *VM) hookAccess(a Access) {
+	for _, ih := range vm.hooks {
+		if ih.events&HookAccess != 0 {
+			ih.hook.OnAccess(a)
+		}
+	}
+}
+
+// ============================================================================
+// 性能分析钩子
+// ============================================================================
+
+// CallRecorder 接收函数调用和返回的记录，profiler.Profiler 实现了该接口
+type CallRecorder interface {
+	RecordFunctionCall(funcName string, file string, line int)
+	RecordFunctionReturn(funcName string, duration time.Duration)
+}
+
+// AllocationRecorder 接收每次分配的记录，profiler.MemoryProfiler 和 profiler.Profiler 实现了该接口
+type AllocationRecorder interface {
+	RecordAllocation(typeName string, size int64)
+}
+
+// profilerHook 把函数调用和分配转交给性能分析器
+// 调用时间按协程分别计时，协程挂起期间的时间计入挂起它的调用
+type profilerHook struct {
+	BaseHook
+	calls   CallRecorder
+	allocs  AllocationRecorder
+	current int64                 // 当前协程
+	starts  map[int64][]time.Time // 各协程尚未返回的调用的开始时间
+}
+
+// NewProfilerHook 返回把函数调用交给 calls、把分配交给 allocs 的钩子，两者都可以为 nil
+func NewProfilerHook(calls CallRecorder, allocs AllocationRecorder) Hook {
+	return &profilerHook{calls: calls, allocs: allocs, starts: make(map[int64][]time.Time)}
+}
+
+func (p *profilerHook) Events() HookEvent {
+	var events HookEvent
+	if p.calls != nil {
+		events |= HookCall | HookCoroutine
+	}
+	if p.allocs != nil {
+		events |= HookAllocation
+	}
+	return events
+}
+
+func (p *profilerHook) OnEnter(fn *bytecode.Function) {
+	p.starts[p.current] = append(p.starts[p.current], time.Now())
+	p.calls.RecordFunctionCall(FunctionName(fn), fn.SourceFile, LineAt(fn.Chunk, 0))
+}
+
+func (p *profilerHook) OnExit(fn *bytecode.Function) {
+	starts := p.starts[p.current]
+	if len(starts) == 0 {
+		// 安装钩子之前进入的函数
+		return
+	}
+	start := starts[len(starts)-1]
+	p.starts[p.current] = starts[:len(starts)-1]
+	// 时长为 0 时分析器会用自己的调用栈计时，协程交替执行时那并不准确
+	p.calls.RecordFunctionReturn(FunctionName(fn), max(time.Since(start), 1))
+}
+
+func (p *profilerHook) OnAllocation(kind AllocKind, size int) {
+	p.allocs.RecordAllocation(kind.String(), int64(size))
+}
+
+func (p *profilerHook) OnCoroutineSwitch(from, to int64) {
+	if len(p.starts[from]) == 0 {
+		delete(p.starts, from)
+	}
+	p.current = to
+}
@@ -0,0 +1,183 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 宿主调用
+// ============================================================================
+//
+// 宿主 (嵌入 VM 的 Go 代码) 通过 CallValue / CallStatic / CallMethod / NewInstance
+// 调用 Sola 代码，参数和返回值都是 bytecode.Value，调用失败时返回 *CallError
+// (超过执行限制时返回 *LimitError) 而不是输出到终端。
+//
+// 每次宿主调用以当前帧为入口帧运行嵌套执行循环：
+//   - 最外层调用 (没有正在运行的执行循环) 开始前清除上一次调用遗留的错误状态，
+//     未捕获的异常按未捕获异常报告 (UncaughtException / GetError 可用)
+//   - 在内置函数中发起的调用 (Go 回调 Sola) 不影响外层执行，未捕获的异常只作为
+//     错误返回；内置函数把该错误原样返回时，异常继续在外层 Sola 代码中展开
+
+// CallError 宿主调用中未被捕获的异常或运行时错误
+type CallError struct {
+	Exception *bytecode.Exception // 未捕获的异常 (运行时错误时为 nil)
+	Message   string              // 错误消息，含调用栈
+}
+
+func (e *CallError) Error() string {
+	return e.Message
+}
+
+// CallValue 调用函数或闭包
+func (vm *VM) CallValue(callee bytecode.Value, args []bytecode.Value) (bytecode.Value, error) {
+	return vm.hostCall(len(args)+1, func() bool {
+		vm.push(callee)
+		vm.pushArgs(args)
+		vm.callValue(len(args))
+		return true
+	})
+}
+
+// CallStatic 调用类的静态方法，调用前确保类已完成静态初始化
+func (vm *VM) CallStatic(class *bytecode.Class, name string, args []bytecode.Value) (bytecode.Value, error) {
+	method := class.GetMethodByArity(name, len(args))
+	if method == nil || !method.IsStatic {
+		return bytecode.NullValue, &CallError{Message: fmt.Sprintf("undefined static method: %s::%s", class.Name, name)}
+	}
+	if len(args) < method.MinArity {
+		return bytecode.NullValue, &CallError{Message: fmt.Sprintf("%s::%s expects at least %d arguments, got %d", class.Name, name, method.MinArity, len(args))}
+	}
+	return vm.hostCall(max(len(args), method.Arity), func() bool {
+		if !vm.initClass(class) {
+			return false
+		}
+		vm.pushArgs(args)
+		vm.pushDefaults(len(args), method.MinArity, method.Arity, method.DefaultValues)
+		argCount := max(len(args), method.Arity)
+		// 静态方法没有被调用者槽位
+		return vm.pushStaticFrame(methodFunction(method), vm.sp-argCount)
+	})
+}
+
+// CallMethod 在接收者对象上调用实例方法
+func (vm *VM) CallMethod(receiver bytecode.Value, name string, args []bytecode.Value) (bytecode.Value, error) {
+	return vm.hostCall(len(args)+1, func() bool {
+		vm.push(receiver)
+		vm.pushArgs(args)
+		vm.invoke(name, len(args))
+		return true
+	})
+}
+
+// NewInstance 创建类实例并执行构造函数
+func (vm *VM) NewInstance(class *bytecode.Class, args []bytecode.Value) (bytecode.Value, error) {
+	var obj *bytecode.Object
+	_, err := vm.hostCall(len(args)+1, func() bool {
+		if !vm.initClass(class) {
+			return false
+		}
+		obj = vm.instantiate(class)
+		vm.push(bytecode.NewObject(obj))
+		vm.pushArgs(args)
+		vm.invokeMethod(class.GetMethodByArity("__construct", len(args)), "__construct", len(args))
+		return true
+	})
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	return bytecode.NewObject(obj), nil
+}
+
+// hostCall 在宿主调用边界内执行 call
+// call 把被调用者和参数压栈并发起调用：Sola 函数压入调用帧，内置函数直接把结果压栈；
+// call 返回 false 表示调用未能发起 (异常已记录)。n 为 call 最多压入的值的个数
+func (vm *VM) hostCall(n int, call func() bool) (bytecode.Value, error) {
+	if vm.runDepth == 0 {
+		vm.clearHostState()
+		vm.beginRun()
+		defer vm.clearResidue()
+	}
+	vm.ensureStack(n + frameSlack)
+
+	fp, sp := vm.fp, vm.sp
+	savedBase := vm.baseFP
+	vm.baseFP = fp // 发起调用时抛出的异常不越过宿主调用边界
+
+	result := bytecode.NullValue
+	if call() && !vm.hasError {
+		if vm.fp > fp {
+			result = vm.execute(fp)
+		} else if vm.pendingException == nil && vm.sp > sp {
+			result = vm.pop()
+		}
+	}
+	vm.baseFP = savedBase
+
+	if vm.hasError {
+		if e := vm.limits.exceeded; e != nil {
+			return bytecode.NullValue, e
+		}
+		return bytecode.NullValue, &CallError{Exception: vm.uncaught, Message: vm.errorMsg}
+	}
+	if ex := vm.pendingException; ex != nil {
+		vm.pendingException = nil
+		vm.sp = sp
+		if vm.runDepth == 0 {
+			vm.reportUncaught(ex)
+		}
+		return bytecode.NullValue, &CallError{Exception: ex, Message: uncaughtMessage(ex)}
+	}
+	vm.sp = sp
+	return result, nil
+}
+
+// clearHostState 清除上一次最外层调用遗留的栈和错误状态，静态存储和统计信息保持不变
+func (vm *VM) clearHostState() {
+	vm.sp = 0
+	vm.fp = 0
+	vm.baseFP = 0
+	vm.hasError = false
+	vm.errorMsg = ""
+	vm.pendingException = nil
+	vm.currentException = nil
+	vm.uncaught = nil
+	vm.resetScheduler()
+}
+
+// clearResidue 清除栈顶以上和已返回的调用帧中残留的引用
+// 最外层调用返回后脚本丢弃的对象不再被 VM 引用，可以被 Go 回收 (原生句柄的泄漏检测依赖其终结器)
+func (vm *VM) clearResidue() {
+	clear(vm.stack[vm.sp:])
+	clear(vm.frames[vm.fp:])
+}
+
+// ensureStack 确保操作数栈在栈顶之上还有 n 个空位
+func (vm *VM) ensureStack(n int) {
+	if vm.sp+n <= len(vm.stack) {
+		return
+	}
+	stack := make([]bytecode.Value, max(2*len(vm.stack), vm.sp+n))
+	copy(stack, vm.stack[:vm.sp])
+	vm.stack = stack
+}
+
+// pushArgs 按顺序压入参数
+func (vm *VM) pushArgs(args []bytecode.Value) {
+	for _, arg := range args {
+		vm.push(arg)
+	}
+}
+
+// pushDefaults 为省略的参数压入默认值，没有默认值的参数压入 null
+func (vm *VM) pushDefaults(argCount, minArity, arity int, defaults []bytecode.Value) {
+	for i := argCount; i < arity; i++ {
+		defIdx := i - minArity
+		if defIdx >= 0 && defIdx < len(defaults) {
+			vm.push(defaults[defIdx])
+		} else {
+			vm.push(bytecode.NullValue)
+		}
+	}
+}
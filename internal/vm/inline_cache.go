@@ -0,0 +1,148 @@
+package vm
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 内联缓存
+// ============================================================================
+//
+// 实例方法调用 (OpCallMethod / OpTailCallMethod) 和字段访问 (OpGetField / OpSetField)
+// 按调用点 (字节码块 + 指令偏移) 缓存接收者类的解析结果：方法调用点缓存
+// *bytecode.Method，字段访问点缓存字段槽位 (-1 表示类中未声明的动态属性)。
+//
+// 每个调用点最多缓存 icMaxEntries 个接收者类 (单态/多态)，再出现新的类时
+// 标记为超多态，此后总是按名称沿继承链查找。类被重新定义时 VM 的类版本号
+// 递增，版本号不一致的调用点在下次执行时清空重建。
+//
+// 缓存表按字节码块保存在 VM 上 (chunkSites)，调用帧在首次访问时取得所属块的
+// 缓存表，同一帧内的后续访问直接按指令偏移索引。
+
+// icMaxEntries 单个调用点缓存的接收者类数量上限
+const icMaxEntries = 4
+
+// icEntry 接收者类到解析结果的缓存项
+type icEntry struct {
+	class  *bytecode.Class
+	method *bytecode.Method // 方法调用点：解析出的方法
+	slot   int              // 字段访问点：字段槽位，-1 表示动态属性
+}
+
+// inlineCache 单个调用点的内联缓存
+type inlineCache struct {
+	epoch       uint64 // 建立缓存时的类版本号
+	n           int    // 有效缓存项数量
+	megamorphic bool   // 接收者类超过 icMaxEntries 个
+	entries     [icMaxEntries]icEntry
+}
+
+// chunkSites 字节码块的调用点附加信息，按指令偏移索引
+type chunkSites struct {
+	caches  []*inlineCache // 内联缓存
+	quicken []*quickenSite // quickening 的类型观测 (见 quicken.go)
+}
+
+// chunkSites 返回字节码块的调用点附加信息，首次访问时创建
+func (vm *VM) chunkSites(chunk *bytecode.Chunk) *chunkSites {
+	sites, ok := vm.sites[chunk]
+	if !ok {
+		if vm.sites == nil {
+			vm.sites = make(map[*bytecode.Chunk]*chunkSites)
+		}
+		sites = &chunkSites{}
+		vm.sites[chunk] = sites
+	}
+	return sites
+}
+
+// frameSites 返回当前帧所属字节码块的调用点附加信息
+func (vm *VM) frameSites() *chunkSites {
+	frame := vm.currentFrame()
+	if frame.sites == nil {
+		frame.sites = vm.chunkSites(frame.chunk)
+	}
+	return frame.sites
+}
+
+// cacheAt 返回当前帧中位于 site 的指令的内联缓存
+func (vm *VM) cacheAt(site int) *inlineCache {
+	sites := vm.frameSites()
+	if sites.caches == nil {
+		sites.caches = make([]*inlineCache, len(vm.currentFrame().chunk.Code))
+	}
+	ic := sites.caches[site]
+	if ic == nil {
+		ic = &inlineCache{epoch: vm.classEpoch}
+		sites.caches[site] = ic
+	} else if ic.epoch != vm.classEpoch {
+		*ic = inlineCache{epoch: vm.classEpoch}
+	}
+	return ic
+}
+
+// lookup 查找接收者类的缓存项
+func (ic *inlineCache) lookup(class *bytecode.Class) *icEntry {
+	for i := 0; i < ic.n; i++ {
+		if ic.entries[i].class == class {
+			return &ic.entries[i]
+		}
+	}
+	return nil
+}
+
+// addCacheEntry 记录接收者类的解析结果，调用点的类数量超过上限时转为超多态
+func (vm *VM) addCacheEntry(ic *inlineCache, entry icEntry) {
+	if ic.n == icMaxEntries {
+		ic.megamorphic = true
+		ic.n = 0
+		ic.entries = [icMaxEntries]icEntry{}
+		vm.stats.MegamorphicSites++
+		return
+	}
+	ic.entries[ic.n] = entry
+	ic.n++
+}
+
+// cachedMethod 通过 site 处的内联缓存解析接收者类上的实例方法
+// 方法不存在时返回 nil (同样缓存，例如没有显式构造函数的类的构造调用)
+func (vm *VM) cachedMethod(site int, class *bytecode.Class, name string, argCount int) *bytecode.Method {
+	ic := vm.cacheAt(site)
+	if e := ic.lookup(class); e != nil {
+		vm.stats.InlineCacheHits++
+		return e.method
+	}
+	vm.stats.InlineCacheMisses++
+	method := class.GetMethodByArity(name, argCount)
+	if !ic.megamorphic {
+		vm.addCacheEntry(ic, icEntry{class: class, method: method})
+	}
+	return method
+}
+
+// cachedFieldSlot 通过 site 处的内联缓存解析接收者类上的字段槽位
+// 返回 -1 表示该字段不是类中声明的属性
+func (vm *VM) cachedFieldSlot(site int, class *bytecode.Class, name string) int {
+	if class == nil {
+		return -1
+	}
+	ic := vm.cacheAt(site)
+	if e := ic.lookup(class); e != nil {
+		vm.stats.InlineCacheHits++
+		return e.slot
+	}
+	vm.stats.InlineCacheMisses++
+	slot, ok := class.Layout().Index[name]
+	if !ok {
+		slot = -1
+	}
+	if !ic.megamorphic {
+		vm.addCacheEntry(ic, icEntry{class: class, slot: slot})
+	}
+	return slot
+}
+
+// invalidateInlineCaches 使所有调用点的内联缓存失效 (类被重新定义时调用)
+func (vm *VM) invalidateInlineCaches() {
+	vm.classEpoch++
+}
@@ -0,0 +1,361 @@
+package vm
+
+import (
+	"sort"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 调试查看
+// ============================================================================
+//
+// 调试器在钩子中查看暂停处的调用栈和变量。局部变量按字节码块调试信息中的
+// 变量槽位从帧的栈上读取，只返回在当前指令处可见且已经初始化的变量。
+// 挂起的协程保存着自己的栈和调用帧，它们的帧同样可以查看。
+// 这些方法读取虚拟机的执行状态，只能在执行脚本的 goroutine 上调用 (通常在钩子中)。
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Function  *bytecode.Function
+	PC        int // 最内层帧为暂停处的指令偏移，外层帧为正在进行的调用指令
+	Line      int
+	Coroutine int64 // 所在协程的 ID，主协程为 0
+
+	co    *coroutine // 所在协程，调度器启用之前为 nil
+	index int        // 在调用帧数组中的下标
+}
+
+// Variable 调试器看到的变量
+type Variable struct {
+	Name   string
+	Type   string // 声明的类型，未声明时为空
+	Slot   int    // 局部变量槽位，全局变量为 -1
+	Global int    // 全局变量在全局表中的索引
+	Value  bytecode.Value
+}
+
+// Depth 返回当前协程的调用深度
+func (vm *VM) Depth() int {
+	return vm.fp
+}
+
+// Frames 返回当前协程的调用栈 (最内层在前)
+func (vm *VM) Frames() []Frame {
+	return vm.framesOf(vm.sched.current)
+}
+
+// CoroutineFrames 返回协程的调用栈 (最内层在前)，协程不存在或已结束时返回 nil
+func (vm *VM) CoroutineFrames(id int64) []Frame {
+	co, ok := vm.coroutineByID(id)
+	if !ok {
+		return nil
+	}
+	return vm.framesOf(co)
+}
+
+// framesOf 返回协程的调用栈
+func (vm *VM) framesOf(co *coroutine) []Frame {
+	_, callFrames, _, fp := vm.contextOf(co)
+	current := co == vm.sched.current
+	var id int64
+	if co != nil {
+		id = co.obj.ID
+	}
+	frames := make([]Frame, 0, fp)
+	for i := fp - 1; i >= 0; i-- {
+		frame := &callFrames[i]
+		if frame.function == nil {
+			continue
+		}
+		// 行号钩子中最内层帧停在将要执行的指令上，阻塞的协程停在恢复后重新执行的指令上，
+		// 其他情况下 ip 已越过当前指令
+		pc := frame.ip - 1
+		if i == fp-1 && ((current && vm.tracing) || (!current && co.waitReason != "")) {
+			pc = frame.ip
+		}
+		pc = max(pc, 0)
+		frames = append(frames, Frame{
+			Function:  frame.function,
+			PC:        pc,
+			Line:      LineAt(frame.chunk, pc),
+			Coroutine: id,
+			co:        co,
+			index:     i,
+		})
+	}
+	return frames
+}
+
+// contextOf 返回协程的栈和调用帧，当前协程的执行上下文在虚拟机上
+func (vm *VM) contextOf(co *coroutine) (stack []bytecode.Value, frames []CallFrame, sp, fp int) {
+	if co == nil || co == vm.sched.current {
+		return vm.stack, vm.frames, vm.sp, vm.fp
+	}
+	if co.state == coDone {
+		return nil, nil, 0, 0
+	}
+	return co.stack, co.frames, co.sp, co.fp
+}
+
+// Locals 返回帧中在暂停处可见的局部变量，按声明顺序排列
+// 内层作用域的同名变量遮蔽外层的变量
+func (vm *VM) Locals(f Frame) []Variable {
+	frame, limit := vm.frameAt(f)
+	if frame == nil || frame.chunk.Debug == nil {
+		return nil
+	}
+	stack, _, _, _ := vm.contextOf(f.co)
+	var vars []Variable
+	index := make(map[string]int)
+	for _, info := range frame.chunk.Debug.GetVariablesInScope(f.PC) {
+		if info.Slot < 0 || frame.bp+info.Slot >= limit {
+			continue
+		}
+		v := Variable{Name: info.Name, Type: info.Type, Slot: info.Slot, Value: stack[frame.bp+info.Slot]}
+		if i, ok := index[info.Name]; ok {
+			vars[i] = v
+			continue
+		}
+		index[info.Name] = len(vars)
+		vars = append(vars, v)
+	}
+	return vars
+}
+
+// This 返回方法帧的 $this，静态方法、函数和闭包的帧返回 false
+func (vm *VM) This(f Frame) (bytecode.Value, bool) {
+	frame, limit := vm.frameAt(f)
+	if frame == nil || frame.function.ClassName == "" || frame.isStaticCall || frame.closure != nil || frame.bp >= limit {
+		return bytecode.NullValue, false
+	}
+	stack, _, _, _ := vm.contextOf(f.co)
+	this := stack[frame.bp]
+	return this, this.IsObject()
+}
+
+// SetLocal 修改帧中的局部变量，帧已失效或槽位越界时返回 false
+func (vm *VM) SetLocal(f Frame, slot int, v bytecode.Value) bool {
+	frame, limit := vm.frameAt(f)
+	if frame == nil || slot < 0 || frame.bp+slot >= limit {
+		return false
+	}
+	stack, _, _, _ := vm.contextOf(f.co)
+	stack[frame.bp+slot] = v
+	return true
+}
+
+// Eval 在当前执行状态之上调用调试器编译的求值函数 (compiler.CompileEval)
+// this 放在 slot 0，args 依次作为参数；返回后栈和调用帧恢复原状，
+// 求值期间不切换协程 (嵌套执行)，
+// 未捕获的异常作为 *CallError 返回，不影响暂停处的执行
+func (vm *VM) Eval(fn *bytecode.Function, this bytecode.Value, args []bytecode.Value) (bytecode.Value, error) {
+	return vm.hostCall(len(args)+1, func() bool {
+		vm.push(this)
+		vm.pushArgs(args)
+		return vm.pushFrame(fn, vm.sp-len(args)-1)
+	})
+}
+
+// Globals 返回已加载的代码声明的全局变量
+func (vm *VM) Globals() []Variable {
+	var vars []Variable
+	seen := make(map[string]bool)
+	for _, fn := range vm.Functions() {
+		if fn.Chunk.Debug == nil {
+			continue
+		}
+		for _, info := range fn.Chunk.Debug.Variables {
+			if info.Slot >= 0 || seen[info.Name] {
+				continue
+			}
+			seen[info.Name] = true
+			vars = append(vars, Variable{Name: info.Name, Type: info.Type, Slot: -1, Global: info.Global, Value: vm.GetGlobal(info.Global)})
+		}
+	}
+	return vars
+}
+
+// Functions 返回已加载的脚本函数：注册的函数、类的方法和静态初始化器，以及其中定义的函数和闭包
+// 方法返回调用时使用的同一个 Function
+func (vm *VM) Functions() []*bytecode.Function {
+	var fns []*bytecode.Function
+	seen := make(map[*bytecode.Chunk]bool)
+	var add func(fn *bytecode.Function)
+	add = func(fn *bytecode.Function) {
+		if fn == nil || fn.IsBuiltin || fn.Chunk == nil || seen[fn.Chunk] {
+			return
+		}
+		seen[fn.Chunk] = true
+		fns = append(fns, fn)
+		for _, c := range fn.Chunk.Constants {
+			if c.IsFunc() {
+				add(c.AsFunc())
+			}
+		}
+	}
+	for _, fn := range vm.functions {
+		add(fn)
+	}
+	for _, class := range vm.classes {
+		if class.StaticInit != nil {
+			add(methodFunction(class.StaticInit))
+		}
+		for _, methods := range class.Methods {
+			for _, m := range methods {
+				if m.Chunk != nil {
+					add(methodFunction(m))
+				}
+			}
+		}
+	}
+	return fns
+}
+
+// frameAt 返回 f 对应的调用帧和帧中变量可用的栈上界 (不含)
+// 调用栈已经变化 (f 不再有效) 时返回 nil
+func (vm *VM) frameAt(f Frame) (*CallFrame, int) {
+	_, frames, sp, fp := vm.contextOf(f.co)
+	if f.index < 0 || f.index >= fp || frames[f.index].function != f.Function {
+		return nil, 0
+	}
+	limit := sp
+	if f.index+1 < fp {
+		limit = frames[f.index+1].bp
+	}
+	return &frames[f.index], limit
+}
+
+// IsCaught 报告调用栈上是否有 catch 会处理该异常
+// 在 OnThrow 钩子中 (展开之前) 调用；越过的 finally 不影响结果
+func (vm *VM) IsCaught(ex *bytecode.Exception) bool {
+	for i := vm.fp - 1; i >= 0; i-- {
+		frame := &vm.frames[i]
+		for j := len(frame.handlers) - 1; j >= 0; j-- {
+			h := frame.handlers[j]
+			if h.kind != handlerTry {
+				continue
+			}
+			if _, ok := vm.findCatch(frame, h.tryIP, ex); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExceptionIs 报告异常是否为指定类型或其子类，匹配规则与 catch 相同
+func ExceptionIs(ex *bytecode.Exception, typeName string) bool {
+	return exceptionMatches(ex, typeName)
+}
+
+// ============================================================================
+// 协程查看
+// ============================================================================
+
+// CoroutineInfo 调试器看到的协程 (只包括执行 Sola 代码的协程)
+type CoroutineInfo struct {
+	ID        int64
+	Main      bool
+	State     string             // running、ready 或 waiting
+	Wait      string             // 阻塞原因 (chan send、chan receive、select、await 等)，未阻塞时为空
+	Channels  []ChannelWait      // 阻塞的通道操作
+	Depth     int                // 调用深度
+	SpawnFunc *bytecode.Function // 创建协程的函数，主协程为 nil
+	SpawnLine int                // 创建协程的行
+}
+
+// ChannelWait 协程阻塞在通道上的一个操作 (select 的每个 case 一项)
+type ChannelWait struct {
+	Channel *Channel
+	Send    bool
+	Value   bytecode.Value // 待发送的值
+}
+
+// CurrentCoroutine 返回正在执行的协程的 ID，主协程为 0
+func (vm *VM) CurrentCoroutine() int64 {
+	if cur := vm.sched.current; cur != nil {
+		return cur.obj.ID
+	}
+	return 0
+}
+
+// Coroutines 返回未结束的协程，按 ID 排列，主协程在最前
+func (vm *VM) Coroutines() []CoroutineInfo {
+	s := &vm.sched
+	if s.main == nil {
+		// 调度器未启用，只有主协程
+		return []CoroutineInfo{{Main: true, State: "running", Depth: vm.fp}}
+	}
+	coroutines := []*coroutine{s.main}
+	for _, co := range s.live {
+		if co.kind == coTask {
+			coroutines = append(coroutines, co)
+		}
+	}
+	sort.Slice(coroutines, func(i, j int) bool { return coroutines[i].obj.ID < coroutines[j].obj.ID })
+
+	infos := make([]CoroutineInfo, 0, len(coroutines))
+	for _, co := range coroutines {
+		if co.state == coDone {
+			continue
+		}
+		_, _, _, fp := vm.contextOf(co)
+		info := CoroutineInfo{
+			ID:        co.obj.ID,
+			Main:      co == s.main,
+			State:     co.stateName(),
+			Wait:      co.waitReason,
+			Depth:     fp,
+			SpawnFunc: co.spawnFn,
+			SpawnLine: co.spawnLine,
+		}
+		for _, c := range co.waitCases {
+			info.Channels = append(info.Channels, ChannelWait{Channel: c.ch, Send: !c.isRecv, Value: c.value})
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// CoroutineState 返回协程的状态：running、ready、waiting，不存在或已结束时为 done
+func (vm *VM) CoroutineState(id int64) string {
+	co, ok := vm.coroutineByID(id)
+	switch {
+	case !ok:
+		return "done"
+	case co == nil:
+		return "running"
+	}
+	return co.stateName()
+}
+
+// coroutineByID 查找未结束的协程，调度器未启用时 ID 0 为主协程 (返回 nil, true)
+func (vm *VM) coroutineByID(id int64) (*coroutine, bool) {
+	s := &vm.sched
+	if s.main == nil {
+		return nil, id == 0
+	}
+	if id == 0 {
+		return s.main, s.main.state != coDone
+	}
+	for _, co := range s.live {
+		if co.obj.ID == id && co.kind == coTask {
+			return co, true
+		}
+	}
+	return nil, false
+}
+
+// stateName 调度状态的名称
+func (co *coroutine) stateName() string {
+	switch co.state {
+	case coRunning:
+		return "running"
+	case coReady:
+		return "ready"
+	case coWaiting:
+		return "waiting"
+	}
+	return "done"
+}
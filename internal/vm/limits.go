@@ -0,0 +1,216 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 执行限制
+// ============================================================================
+//
+// 运行不受信任的脚本时，宿主可以为每次运行设置指令数、执行时间和分配次数的上限，
+// 并通过 context.Context 随时取消执行。每次最外层运行 (Run / CallStaticMethod /
+// 宿主调用) 开始时重新计数和计时。
+//
+// 指令数、截止时间和取消在检查点检查：向后跳转 (循环) 和压入调用帧，因此任何
+// 不终止的执行都会经过检查点。时钟和上下文每 limitPollInterval 个检查点才查询一次；
+// 协程全部在等待定时器时，等待会在截止时间或取消时提前结束。分配次数在每次分配时检查。
+//
+// 超过限制时执行立即终止：与运行时错误一样不能被 try/catch 捕获，也不执行 finally，
+// 宿主得到标明触发限制的 *LimitError。
+
+// limitPollInterval 查询时钟和上下文的检查点间隔
+const limitPollInterval = 1024
+
+// Limits 执行限制，零值字段表示不限制
+type Limits struct {
+	MaxInstructions uint64          // 每次运行执行的指令数上限
+	MaxAllocations  uint64          // 每次运行分配对象、数组、Map、字节数组和闭包的次数上限
+	Timeout         time.Duration   // 每次运行的最长时间
+	Deadline        time.Time       // 截止时间 (与 Timeout 同时设置时以先到者为准)
+	Context         context.Context // 取消或到期时终止执行
+}
+
+// LimitKind 触发的限制类型
+type LimitKind int
+
+const (
+	LimitInstructions LimitKind = iota + 1 // 指令数
+	LimitDeadline                          // 执行时间 (Timeout / Deadline)
+	LimitCanceled                          // 上下文被取消或到期
+	LimitAllocations                       // 分配次数
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitInstructions:
+		return "instruction limit"
+	case LimitDeadline:
+		return "deadline"
+	case LimitCanceled:
+		return "context canceled"
+	case LimitAllocations:
+		return "allocation limit"
+	}
+	return "limit"
+}
+
+// LimitError 执行因超过限制而终止
+type LimitError struct {
+	Kind    LimitKind
+	Message string                // 错误消息 (不含调用栈)
+	Stack   []bytecode.StackFrame // 终止时的调用栈 (最内层在前)
+	Err     error                 // 截止时间为 context.DeadlineExceeded，取消时为上下文的错误
+}
+
+func (e *LimitError) Error() string {
+	if len(e.Stack) == 0 {
+		return e.Message
+	}
+	return e.Message + "\n" + formatStackTrace(e.Stack)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// limitState 当前运行的限制和计数起点
+type limitState struct {
+	Limits
+	enabled    bool
+	deadline   time.Time       // Timeout 与 Deadline 中较早者
+	done       <-chan struct{} // 上下文的 Done 通道
+	instStart  uint64          // 运行开始时的指令计数
+	allocStart uint64          // 运行开始时的分配计数
+	poll       int             // 距下次查询时钟和上下文的检查点数
+	exceeded   *LimitError     // 导致本次运行终止的限制
+}
+
+// SetLimits 设置执行限制，从下一次最外层运行开始生效
+func (vm *VM) SetLimits(l Limits) {
+	vm.limits = limitState{Limits: l}
+	vm.limits.enabled = l.MaxInstructions > 0 || l.MaxAllocations > 0 ||
+		l.Timeout > 0 || !l.Deadline.IsZero() || l.Context != nil
+}
+
+// Limits 返回当前的执行限制
+func (vm *VM) Limits() Limits {
+	return vm.limits.Limits
+}
+
+// LimitExceeded 返回导致最近一次运行终止的限制 (没有则返回 nil)
+func (vm *VM) LimitExceeded() *LimitError {
+	return vm.limits.exceeded
+}
+
+// beginRun 最外层运行开始：重新计数和计时
+func (vm *VM) beginRun() {
+	l := &vm.limits
+	l.exceeded = nil
+	if !l.enabled {
+		return
+	}
+	l.instStart = vm.stats.InstructionsExecuted
+	l.allocStart = vm.stats.Allocations
+	l.poll = 0 // 第一个检查点即查询时钟和上下文
+	l.deadline = l.Deadline
+	if l.Timeout > 0 {
+		if d := time.Now().Add(l.Timeout); l.deadline.IsZero() || d.Before(l.deadline) {
+			l.deadline = d
+		}
+	}
+	l.done = nil
+	if l.Context != nil {
+		l.done = l.Context.Done()
+	}
+}
+
+// checkpoint 在向后跳转和压入调用帧时检查限制，超过限制时终止执行并返回 false
+func (vm *VM) checkpoint() bool {
+	l := &vm.limits
+	if l.MaxInstructions > 0 && vm.stats.InstructionsExecuted-l.instStart > l.MaxInstructions {
+		vm.terminate(LimitInstructions, nil, "instruction limit of %d exceeded", l.MaxInstructions)
+		return false
+	}
+	if l.poll--; l.poll > 0 {
+		return true
+	}
+	l.poll = limitPollInterval
+	return vm.checkClock()
+}
+
+// checkClock 检查截止时间和上下文，超过限制时终止执行并返回 false
+func (vm *VM) checkClock() bool {
+	l := &vm.limits
+	if l.done != nil {
+		select {
+		case <-l.done:
+			vm.terminate(LimitCanceled, l.Context.Err(), "execution canceled: %v", l.Context.Err())
+			return false
+		default:
+		}
+	}
+	if !l.deadline.IsZero() && !time.Now().Before(l.deadline) {
+		vm.terminate(LimitDeadline, context.DeadlineExceeded, "execution deadline exceeded")
+		return false
+	}
+	return true
+}
+
+// idle 没有协程可运行时等待 d
+// 设置了截止时间或上下文时等待会提前结束；超过限制时终止执行并返回 false
+func (vm *VM) idle(d time.Duration) bool {
+	if l := &vm.limits; vm.sched.virtual || l.done == nil && l.deadline.IsZero() {
+		vm.sched.sleep(d)
+		return true
+	}
+	return vm.wait(d)
+}
+
+// Sleep 供内置函数休眠使用，遵守截止时间和上下文
+// 设置了虚拟时钟 (见 SetClock) 时只推进时钟；超过限制时终止执行并返回 false
+func (vm *VM) Sleep(d time.Duration) bool {
+	if vm.sched.virtual {
+		vm.sched.sleep(d)
+		return true
+	}
+	if l := &vm.limits; l.done == nil && l.deadline.IsZero() {
+		time.Sleep(d)
+		return true
+	}
+	return vm.wait(d)
+}
+
+// wait 等待 d，截止时间或上下文取消先到时提前结束并终止执行
+func (vm *VM) wait(d time.Duration) bool {
+	l := &vm.limits
+	if !l.deadline.IsZero() {
+		d = min(d, time.Until(l.deadline))
+	}
+	if d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-l.done:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	return vm.checkClock()
+}
+
+// terminate 因超过限制终止执行，不能被捕获
+func (vm *VM) terminate(kind LimitKind, err error, format string, args ...interface{}) {
+	e := &LimitError{
+		Kind:    kind,
+		Message: "execution terminated: " + fmt.Sprintf(format, args...),
+		Stack:   vm.captureStackTrace(),
+		Err:     err,
+	}
+	vm.limits.exceeded = e
+	vm.hasError = true
+	vm.errorMsg = e.Error()
+}
@@ -0,0 +1,462 @@
+package vm
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 内存统计与堆上限
+// ============================================================================
+//
+// Sola 的值由 Go 的垃圾回收器管理，VM 不单独释放内存，但为每个 VM 记录脚本
+// 分配的对象、数组、Map、SuperArray、字符串、闭包和字节数组的次数和估算字节数，
+// 数组追加元素和 Map 新增键也计入堆大小。
+//
+// 存活字节数由"回收"统计：从根 (操作数栈、调用帧、协程、全局变量、静态变量、
+// 挂起的异常) 遍历可达的值并累加其估算大小，不可达的部分计为已释放。
+// 自上次回收以来分配的字节数使估算的堆大小 (存活 + 新分配) 达到阈值时自动回收，
+// 之后阈值设为存活字节数的两倍 (不低于基础阈值)，与 Go 的 GOGC 策略相同。
+//
+// 设置了堆上限时，分配使估算的堆大小超过上限会先回收一次，仍超过时抛出
+// OutOfMemoryError。它和 StackOverflowError 一样可以被捕获，捕获后丢弃引用即可继续分配。
+//
+// 统计的是估算值：宿主 (Go) 持有的值不是根，内置函数内部分配的值在返回给脚本
+// 之前不计入。
+
+// DefaultGCThreshold 默认的基础回收阈值 (估算字节数)
+const DefaultGCThreshold = 4 << 20
+
+// valueSize 一个 Value 的大小
+const valueSize = int(unsafe.Sizeof(bytecode.Value{}))
+
+// AllocKind 分配的值的种类
+type AllocKind uint8
+
+const (
+	AllocObject     AllocKind = iota // 对象 (含异常对象)
+	AllocArray                       // 数组 (含定长数组和原生数组)
+	AllocMap                         // Map
+	AllocSuperArray                  // SuperArray
+	AllocString                      // 字符串
+	AllocClosure                     // 闭包
+	AllocBytes                       // 字节数组
+	numAllocKinds
+)
+
+func (k AllocKind) String() string {
+	switch k {
+	case AllocObject:
+		return "object"
+	case AllocArray:
+		return "array"
+	case AllocMap:
+		return "map"
+	case AllocSuperArray:
+		return "superarray"
+	case AllocString:
+		return "string"
+	case AllocClosure:
+		return "closure"
+	case AllocBytes:
+		return "bytes"
+	}
+	return "unknown"
+}
+
+// AllocStats 一种值的分配统计
+type AllocStats struct {
+	Count uint64 // 分配次数
+	Bytes uint64 // 分配的估算字节数
+}
+
+// MemoryStats 内存统计，字节数均为估算值
+type MemoryStats struct {
+	Allocations    uint64                    // 分配次数 (同 VMStats.Allocations)
+	AllocatedBytes uint64                    // 累计分配字节数
+	HeapSize       uint64                    // 堆大小：最近一次回收时的存活字节数 + 此后分配的字节数
+	LiveBytes      uint64                    // 最近一次回收时的存活字节数
+	Collections    uint64                    // 回收次数
+	FreedBytes     uint64                    // 回收统计出的累计释放字节数
+	NextThreshold  uint64                    // 下一次自动回收的堆大小
+	HeapLimit      uint64                    // 堆上限 (0 表示不限制)
+	ByKind         [numAllocKinds]AllocStats // 按种类 (AllocKind) 的分配统计
+}
+
+// memoryState VM 的内存统计和回收设置
+type memoryState struct {
+	byKind      [numAllocKinds]AllocStats
+	total       uint64 // 累计分配字节数
+	live        uint64 // 最近一次回收时的存活字节数
+	sinceGC     uint64 // 最近一次回收后分配的字节数
+	collections uint64
+	freed       uint64
+
+	threshold uint64 // 基础回收阈值
+	nextGC    uint64 // 下一次自动回收的堆大小
+	limit     uint64 // 堆上限
+	disabled  bool   // 是否关闭自动回收 (达到堆上限时仍会回收)
+}
+
+// resetMemory 清空统计，保留阈值和堆上限
+func (vm *VM) resetMemory() {
+	m := &vm.mem
+	*m = memoryState{threshold: m.threshold, limit: m.limit, disabled: m.disabled}
+	m.nextGC = m.baseThreshold()
+}
+
+// baseThreshold 返回基础回收阈值
+func (m *memoryState) baseThreshold() uint64 {
+	if m.threshold == 0 {
+		return DefaultGCThreshold
+	}
+	return m.threshold
+}
+
+// SetHeapLimit 设置堆上限 (估算字节数)，0 表示不限制
+// 超过上限时抛出 OutOfMemoryError
+func (vm *VM) SetHeapLimit(bytes uint64) {
+	vm.mem.limit = bytes
+}
+
+// HeapLimit 返回堆上限
+func (vm *VM) HeapLimit() uint64 {
+	return vm.mem.limit
+}
+
+// SetGCThreshold 设置基础回收阈值 (估算字节数)，0 表示使用默认值
+func (vm *VM) SetGCThreshold(bytes uint64) {
+	m := &vm.mem
+	m.threshold = bytes
+	m.nextGC = max(2*m.live, m.baseThreshold())
+}
+
+// SetGCEnabled 打开或关闭自动回收
+func (vm *VM) SetGCEnabled(enabled bool) {
+	vm.mem.disabled = !enabled
+}
+
+// MemoryStats 返回内存统计
+func (vm *VM) MemoryStats() MemoryStats {
+	m := &vm.mem
+	return MemoryStats{
+		Allocations:    vm.stats.Allocations,
+		AllocatedBytes: m.total,
+		HeapSize:       m.live + m.sinceGC,
+		LiveBytes:      m.live,
+		Collections:    m.collections,
+		FreedBytes:     m.freed,
+		NextThreshold:  m.nextGC,
+		HeapLimit:      m.limit,
+		ByKind:         m.byKind,
+	}
+}
+
+// Collect 立即回收：重新统计存活字节数，返回释放的估算字节数
+// 与自动回收不同，它先清空栈顶以上残留的值。只统计本 VM 的值，不触发 Go 的垃圾回收，
+// 已不可达但尚未被 Go 回收的对象的弱引用仍然有效
+func (vm *VM) Collect() uint64 {
+	m := &vm.mem
+	before := m.live + m.sinceGC
+	clear(vm.stack[vm.sp:])
+	vm.collect()
+	return before - min(before, m.live)
+}
+
+// CollectAll 与 Collect 相同，但在统计之前运行一次 Go 的垃圾回收，
+// 之后不可达对象的弱引用 (WeakRef、WeakMap 的键) 都已失效，失效条目的值不再计入存活字节数
+// Go 的垃圾回收作用于整个进程，由宿主决定是否允许脚本触发它 (见 runtime.Sandbox.GC)
+func (vm *VM) CollectAll() uint64 {
+	m := &vm.mem
+	before := m.live + m.sinceGC
+	clear(vm.stack[vm.sp:])
+	runtime.GC()
+	vm.collect()
+	return before - min(before, m.live)
+}
+
+// ============================================================================
+// 分配记录
+// ============================================================================
+
+// allocate 记录一次分配，超过分配次数上限时终止执行，超过堆上限时抛出 OutOfMemoryError
+// 返回 false 表示执行已终止或异常已展开，调用方不应再压入分配的值
+func (vm *VM) allocate(kind AllocKind, size int) bool {
+	vm.record(kind, size)
+	if vm.hasError {
+		return false
+	}
+	return vm.grow(size)
+}
+
+// grow 记录堆增长 (新分配的值或已有容器增加的元素)，达到阈值时回收
+// 超过堆上限时抛出 OutOfMemoryError 并返回 false
+func (vm *VM) grow(size int) bool {
+	m := &vm.mem
+	n := uint64(size)
+	heap := m.live + m.sinceGC + n
+	if (!m.disabled && heap >= m.nextGC) || (m.limit > 0 && heap > m.limit) {
+		vm.collect()
+		heap = m.live + n
+	}
+	m.sinceGC += n
+	if m.limit > 0 && heap > m.limit {
+		// 新分配的值或新元素不会被使用，不计入堆
+		m.sinceGC -= n
+		vm.throwError("OutOfMemoryError", "out of memory: heap size %d exceeds limit of %d bytes", heap, m.limit)
+		return false
+	}
+	return true
+}
+
+// record 记录一次分配的次数和字节数 (不检查堆上限)
+func (vm *VM) record(kind AllocKind, size int) {
+	m := &vm.mem
+	s := &m.byKind[kind]
+	s.Count++
+	s.Bytes += uint64(size)
+	m.total += uint64(size)
+	if vm.hookEvents&HookAllocation != 0 {
+		vm.hookAllocation(kind, size)
+	}
+
+	vm.stats.Allocations++
+	if l := &vm.limits; l.MaxAllocations > 0 && vm.stats.Allocations-l.allocStart > l.MaxAllocations && !vm.hasError {
+		vm.terminate(LimitAllocations, nil, "allocation limit of %d exceeded", l.MaxAllocations)
+	}
+}
+
+// ============================================================================
+// 大小估算
+// ============================================================================
+
+// 各种值头部的估算大小
+const (
+	objectHeader     = 64
+	sliceHeader      = 24
+	mapHeader        = 48
+	mapEntryOverhead = 8
+	superArrayHeader = 96
+	stringHeader     = 16
+	closureHeader    = 32
+	upvalueSize      = 48
+	weakPointerSize  = 8
+)
+
+// sizeOfArray 返回 n 个元素的数组的估算大小
+func sizeOfArray(n int) int {
+	return sliceHeader + n*valueSize
+}
+
+// sizeOfString 返回长度为 n 的字符串的估算大小
+func sizeOfString(n int) int {
+	return stringHeader + n
+}
+
+// sizeOf 返回值本身 (不含引用的其他值) 的估算大小，非引用类型返回 0
+func sizeOf(v bytecode.Value) int {
+	switch v.Type() {
+	case bytecode.ValString:
+		return sizeOfString(len(v.AsString()))
+	case bytecode.ValArray:
+		return sizeOfArray(len(v.AsArray()))
+	case bytecode.ValFixedArray:
+		return sizeOfArray(len(v.AsFixedArray().Elements)) + 8
+	case bytecode.ValNativeArray:
+		return sliceHeader + v.AsNativeArray().Len()*bytecode.NativeArrayElementSize
+	case bytecode.ValBytes:
+		return sliceHeader + len(v.AsBytes())
+	case bytecode.ValMap:
+		return mapHeader + len(v.AsMap())*(2*valueSize+mapEntryOverhead)
+	case bytecode.ValSuperArray:
+		return superArrayHeader + v.AsSuperArray().Len()*2*valueSize
+	case bytecode.ValObject:
+		return sizeOfObject(v.AsObject())
+	case bytecode.ValClosure:
+		return closureHeader + len(v.AsClosure().Upvalues)*upvalueSize
+	case bytecode.ValStringBuilder:
+		return sliceHeader + v.AsStringBuilder().Len
+	case bytecode.ValWeakRef:
+		return weakPointerSize
+	case bytecode.ValWeakMap:
+		return mapHeader + v.AsWeakMap().Len()*(weakPointerSize+valueSize+mapEntryOverhead)
+	}
+	return 0
+}
+
+// sizeOfObject 返回对象的估算大小
+func sizeOfObject(obj *bytecode.Object) int {
+	return objectHeader + len(obj.Slots)*valueSize + len(obj.Fields)*(stringHeader+valueSize+mapEntryOverhead)
+}
+
+// ============================================================================
+// 回收
+// ============================================================================
+
+// collect 从根遍历可达的值，重新统计存活字节数并设置下一次自动回收的阈值
+func (vm *VM) collect() {
+	m := &vm.mem
+	before := m.live + m.sinceGC
+
+	t := heapTracer{seen: make(map[unsafe.Pointer]struct{})}
+	vm.traceRoots(&t)
+	t.drain()
+
+	m.live = t.bytes
+	m.sinceGC = 0
+	m.collections++
+	m.freed += before - min(before, m.live)
+	m.nextGC = max(2*m.live, m.baseThreshold())
+}
+
+// traceRoots 把所有根加入遍历
+func (vm *VM) traceRoots(t *heapTracer) {
+	t.values(vm.stack[:vm.sp])
+	t.frames(vm.frames[:vm.fp])
+	t.values(vm.globals)
+	for _, values := range vm.statics {
+		for _, v := range values {
+			t.add(v)
+		}
+	}
+	t.exception(vm.pendingException)
+	t.exception(vm.currentException)
+	t.exception(vm.uncaught)
+
+	// 未运行的协程的执行上下文保存在协程中
+	s := &vm.sched
+	trace := func(co *coroutine) {
+		t.add(co.obj.Result)
+		t.add(co.obj.Exception)
+		if co == s.current || co.kind != coTask {
+			return
+		}
+		t.values(co.stack[:co.sp])
+		t.frames(co.frames[:co.fp])
+	}
+	if s.main != nil {
+		trace(s.main)
+	}
+	for _, co := range s.live {
+		trace(co)
+	}
+}
+
+// heapTracer 遍历可达的值并累加估算大小
+type heapTracer struct {
+	seen  map[unsafe.Pointer]struct{}
+	work  []bytecode.Value
+	bytes uint64
+}
+
+// add 把值加入遍历，已访问过的值只计一次
+func (t *heapTracer) add(v bytecode.Value) {
+	p := v.Identity()
+	if p == nil {
+		return
+	}
+	if _, ok := t.seen[p]; ok {
+		return
+	}
+	t.seen[p] = struct{}{}
+	t.bytes += uint64(sizeOf(v))
+	t.work = append(t.work, v)
+}
+
+// values 把一组值加入遍历
+func (t *heapTracer) values(vs []bytecode.Value) {
+	for _, v := range vs {
+		t.add(v)
+	}
+}
+
+// frames 把调用帧引用的闭包和 finally 挂起的值加入遍历
+func (t *heapTracer) frames(frames []CallFrame) {
+	for i := range frames {
+		f := &frames[i]
+		if f.closure != nil {
+			t.add(bytecode.NewClosure(f.closure))
+		}
+		for _, h := range f.handlers {
+			t.add(h.result)
+			t.exception(h.exception)
+		}
+	}
+}
+
+// exception 把异常对象加入遍历
+func (t *heapTracer) exception(ex *bytecode.Exception) {
+	for ; ex != nil; ex = ex.Cause {
+		if ex.Object != nil {
+			t.add(bytecode.NewObject(ex.Object))
+		}
+	}
+}
+
+// drain 遍历工作队列中的值引用的所有值
+func (t *heapTracer) drain() {
+	for len(t.work) > 0 {
+		v := t.work[len(t.work)-1]
+		t.work = t.work[:len(t.work)-1]
+
+		switch v.Type() {
+		case bytecode.ValArray:
+			t.values(v.AsArray())
+		case bytecode.ValFixedArray:
+			t.values(v.AsFixedArray().Elements)
+		case bytecode.ValNativeArray:
+			arr := v.AsNativeArray()
+			if arr.ElementType == bytecode.ValString || arr.ElementType == bytecode.ValObject {
+				for i := 0; i < arr.Len(); i++ {
+					t.add(arr.Get(i))
+				}
+			}
+		case bytecode.ValMap:
+			for k, val := range v.AsMap() {
+				t.add(k.Value())
+				t.add(val)
+			}
+		case bytecode.ValSuperArray:
+			sa := v.AsSuperArray()
+			t.values(sa.Keys())
+			t.values(sa.Values())
+		case bytecode.ValObject:
+			v.AsObject().RangeFields(func(_ string, val bytecode.Value) bool {
+				t.add(val)
+				return true
+			})
+		case bytecode.ValClosure:
+			for _, uv := range v.AsClosure().Upvalues {
+				if uv != nil && uv.IsClosed {
+					t.add(uv.Closed)
+				}
+			}
+		case bytecode.ValEnum:
+			if ev := v.AsEnumValue(); ev != nil {
+				t.add(ev.Value)
+			}
+		case bytecode.ValException:
+			t.exception(v.AsException())
+		case bytecode.ValIterator:
+			// 迭代的容器本身在栈上，这里只有数组的快照
+			t.values(v.AsIterator().Array)
+		case bytecode.ValGoroutine:
+			if co := v.AsCoroutine(); co != nil {
+				t.add(co.Result)
+				t.add(co.Exception)
+			}
+		case bytecode.ValChannel:
+			if ch, ok := v.AsChannel().(*Channel); ok && ch != nil {
+				t.values(ch.buffer)
+				for _, w := range ch.sendq {
+					t.add(w.value)
+				}
+			}
+		case bytecode.ValWeakMap:
+			// 弱引用不阻止回收，不遍历被引用的对象；弱键映射只遍历值
+			v.AsWeakMap().Values(t.add)
+		}
+	}
+}
@@ -26,6 +26,11 @@ func opDup(vm *VM) {
 	vm.push(vm.peek(0))
 }
 
+// opSwap 交换栈顶两个元素
+func opSwap(vm *VM) {
+	vm.stack[vm.sp-1], vm.stack[vm.sp-2] = vm.stack[vm.sp-2], vm.stack[vm.sp-1]
+}
+
 // ============================================================================
 // 常量加载
 // ============================================================================
@@ -78,7 +83,7 @@ func opAdd(vm *VM) {
 
 	// 字符串拼接
 	if a.IsString() || b.IsString() {
-		vm.push(Helper_StringConcat(a, b))
+		vm.pushString(Helper_StringConcat(a, b))
 		return
 	}
 
@@ -90,7 +95,14 @@ func opAdd(vm *VM) {
 func opConcat(vm *VM) {
 	b := vm.pop()
 	a := vm.pop()
-	vm.push(Helper_StringConcat(a, b))
+	vm.pushString(Helper_StringConcat(a, b))
+}
+
+// pushString 压入新创建的字符串，超过堆上限时抛出 OutOfMemoryError 而不压入
+func (vm *VM) pushString(s bytecode.Value) {
+	if vm.allocate(AllocString, sizeOf(s)) {
+		vm.push(s)
+	}
 }
 
 // opSub 减法
@@ -142,7 +154,7 @@ func opDiv(vm *VM) {
 	if a.IsInt() && b.IsInt() {
 		bv := b.AsInt()
 		if bv == 0 {
-			vm.runtimeError("division by zero")
+			vm.throwError("DivideByZeroException", "division by zero")
 			return
 		}
 		vm.push(bytecode.NewInt(a.AsInt() / bv))
@@ -152,7 +164,7 @@ func opDiv(vm *VM) {
 	// 浮点数除法
 	bf := b.AsFloat()
 	if bf == 0 {
-		vm.runtimeError("division by zero")
+		vm.throwError("DivideByZeroException", "division by zero")
 		return
 	}
 	vm.push(bytecode.NewFloat(a.AsFloat() / bf))
@@ -166,7 +178,7 @@ func opMod(vm *VM) {
 	if a.IsInt() && b.IsInt() {
 		bv := b.AsInt()
 		if bv == 0 {
-			vm.runtimeError("modulo by zero")
+			vm.throwError("DivideByZeroException", "modulo by zero")
 			return
 		}
 		vm.push(bytecode.NewInt(a.AsInt() % bv))
@@ -383,12 +395,18 @@ func opLoadGlobal(vm *VM) {
 	// 回退到按索引查找全局变量
 	val := vm.GetGlobal(index)
 	vm.push(val)
+	if vm.hookEvents&HookAccess != 0 {
+		vm.hookAccess(Access{Global: index, Value: val})
+	}
 }
 
 // opStoreGlobal 存储全局变量
 func opStoreGlobal(vm *VM) {
 	index := int(vm.readShort())
 	vm.SetGlobal(index, vm.peek(0))
+	if vm.hookEvents&HookAccess != 0 {
+		vm.hookAccess(Access{Global: index, Write: true, Value: vm.peek(0)})
+	}
 }
 
 
@@ -426,6 +444,9 @@ func opLoop(vm *VM) {
 	offset := int(vm.readShort())
 	frame := vm.currentFrame()
 	frame.ip -= offset
+	if vm.limits.enabled {
+		vm.checkpoint()
+	}
 }
 
 // ============================================================================
@@ -434,8 +455,11 @@ func opLoop(vm *VM) {
 
 // opCall 调用函数
 func opCall(vm *VM) {
-	argCount := int(vm.readByte())
+	vm.callValue(int(vm.readByte()))
+}
 
+// callValue 调用栈上参数之下的函数或闭包
+func (vm *VM) callValue(argCount int) {
 	// 获取被调用者
 	callee := vm.stack[vm.sp-argCount-1]
 
@@ -465,17 +489,7 @@ func opCall(vm *VM) {
 func (vm *VM) callFunction(fn *bytecode.Function, argCount int) {
 	// 处理内置函数
 	if fn.IsBuiltin && fn.BuiltinFn != nil {
-		// 收集参数
-		args := make([]bytecode.Value, argCount)
-		for i := argCount - 1; i >= 0; i-- {
-			args[i] = vm.pop()
-		}
-		// 弹出函数本身
-		vm.pop()
-		// 调用内置函数
-		result := fn.BuiltinFn(args)
-		// 压入结果
-		vm.push(result)
+		vm.callBuiltin(fn, argCount)
 		return
 	}
 
@@ -493,8 +507,8 @@ func (vm *VM) callFunction(fn *bytecode.Function, argCount int) {
 		argCount = fn.Arity
 	}
 
-	// 计算基指针
-	bp := vm.sp - argCount
+	// 计算基指针：slot 0 为被调用者本身
+	bp := vm.sp - argCount - 1
 
 	// 压入调用帧
 	vm.pushFrame(fn, bp)
@@ -517,7 +531,7 @@ func (vm *VM) callClosure(closure *bytecode.Closure, argCount int) {
 		argCount = fn.Arity
 	}
 
-	bp := vm.sp - argCount
+	bp := vm.sp - argCount - 1 // slot 0 为闭包本身
 	vm.pushClosureFrame(closure, bp)
 }
 
@@ -529,15 +543,9 @@ func opReturn(vm *VM) {
 	// 弹出当前帧
 	frame := vm.popFrame()
 
-	// 清理栈上的局部变量和参数
+	// 清理栈上的局部变量、参数和被调用者 (bp 指向被调用者槽位，静态调用没有该槽位)
 	vm.sp = frame.bp
 
-	// 弹出被调用者 (如果有)
-	// 注意：静态方法调用没有被调用者，不需要弹出
-	if !frame.isStaticCall && vm.sp > 0 {
-		vm.sp--
-	}
-
 	// 压入返回值
 	vm.push(result)
 }
@@ -547,15 +555,9 @@ func opReturnNull(vm *VM) {
 	// 弹出当前帧
 	frame := vm.popFrame()
 
-	// 清理栈上的局部变量和参数
+	// 清理栈上的局部变量、参数和被调用者 (bp 指向被调用者槽位，静态调用没有该槽位)
 	vm.sp = frame.bp
 
-	// 弹出被调用者 (如果有)
-	// 注意：静态方法调用没有被调用者，不需要弹出
-	if !frame.isStaticCall && vm.sp > 0 {
-		vm.sp--
-	}
-
 	// 压入 null 作为返回值
 	vm.push(bytecode.NullValue)
 }
@@ -568,30 +570,45 @@ func opCallStatic(vm *VM) {
 	methodIndex := vm.readShort()
 	argCount := int(vm.readByte())
 
+	method := vm.resolveStaticMethod(classIndex, methodIndex)
+	if method == nil {
+		return
+	}
+
+	// 计算基指针（参数已经在栈上了）
+	bp := vm.sp - argCount
+
+	// 直接使用缓存的 Function
+	vm.pushStaticFrame(methodFunction(method), bp)
+}
+
+// resolveStaticMethod 按常量池中的类名和方法名查找静态方法并确保类已初始化
+// 失败时返回 nil，错误已报告或类初始化异常已展开
+func (vm *VM) resolveStaticMethod(classIndex, methodIndex uint16) *bytecode.Method {
 	frame := vm.currentFrame()
 	
 	if int(classIndex) >= len(frame.chunk.Constants) {
 		vm.runtimeError("invalid class index: %d", classIndex)
-		return
+		return nil
 	}
 	
 	classNameVal := frame.chunk.Constants[classIndex]
 	if !classNameVal.IsString() {
-		vm.runtimeError("class name must be string, got %s", classNameVal.Type())
-		return
+		vm.runtimeError("class name must be string, got %v", classNameVal.Type())
+		return nil
 	}
 	
 	className := classNameVal.AsString()
 	
 	if int(methodIndex) >= len(frame.chunk.Constants) {
 		vm.runtimeError("invalid method index: %d", methodIndex)
-		return
+		return nil
 	}
 	
 	methodNameVal := frame.chunk.Constants[methodIndex]
 	if !methodNameVal.IsString() {
 		vm.runtimeError("method name must be string")
-		return
+		return nil
 	}
 	
 	methodName := methodNameVal.AsString()
@@ -600,20 +617,25 @@ func opCallStatic(vm *VM) {
 	class := vm.GetClass(className)
 	if class == nil {
 		vm.runtimeError("undefined class: %s", className)
-		return
+		return nil
+	}
+	if !vm.initClass(class) {
+		return nil
 	}
 	
 	// 查找方法
 	method := class.GetMethod(methodName)
 	if method == nil {
 		vm.runtimeError("undefined method: %s.%s", className, methodName)
-		return
+		return nil
 	}
-	
-	// 使用方法上的缓存 Function
+	return method
+}
+
+// methodFunction 获取方法的 Function 包装（首次访问时创建并缓存）
+func methodFunction(method *bytecode.Method) *bytecode.Function {
 	fn := method.CachedFunction
 	if fn == nil {
-		// 首次访问，创建并缓存
 		fn = &bytecode.Function{
 			Name:       method.Name,
 			ClassName:  method.ClassName,
@@ -625,12 +647,7 @@ func opCallStatic(vm *VM) {
 		}
 		method.CachedFunction = fn
 	}
-	
-	// 计算基指针（参数已经在栈上了）
-	bp := vm.sp - argCount
-	
-	// 直接使用缓存的 Function
-	vm.pushStaticFrame(fn, bp)
+	return fn
 }
 
 // opClosure 创建闭包
@@ -669,6 +686,9 @@ func opClosure(vm *VM) {
 		}
 	}
 
+	if !vm.allocate(AllocClosure, closureHeader+fn.UpvalueCount*upvalueSize) {
+		return
+	}
 	vm.push(bytecode.NewClosure(closure))
 }
 
@@ -686,37 +706,58 @@ func opNewInstance(vm *VM) {
 		vm.runtimeError("unknown class: %s", className)
 		return
 	}
+	if !vm.initClass(class) {
+		return
+	}
 
 	obj := bytecode.NewObjectInstance(class)
+	if !vm.allocate(AllocObject, sizeOfObject(obj)) {
+		return
+	}
 	vm.push(bytecode.NewObject(obj))
 }
 
 // opGetField 获取字段
 func opGetField(vm *VM) {
+	site := vm.currentFrame().ip - 1
 	fieldNameVal := vm.readConstant()
 	fieldName := fieldNameVal.AsString()
 
 	objVal := vm.pop()
+	if vm.quickening {
+		vm.observe(site, objVal.Type())
+	}
+	if objVal.IsCoroutine() {
+		vm.coroutineProperty(objVal.AsCoroutine(), fieldName)
+		return
+	}
 	if !objVal.IsObject() {
 		vm.runtimeError("cannot get field of non-object")
 		return
 	}
 
 	obj := objVal.AsObject()
-	if val, ok := obj.GetField(fieldName); ok {
+	if slot := vm.cachedFieldSlot(site, obj.Class, fieldName); slot >= 0 && slot < len(obj.Slots) {
+		vm.push(obj.Slots[slot])
+	} else if val, ok := obj.Fields[fieldName]; ok {
 		vm.push(val)
 	} else {
 		vm.push(bytecode.NullValue)
 	}
+	if vm.hookEvents&HookAccess != 0 {
+		vm.hookAccess(Access{Object: obj, Field: fieldName, Value: vm.peek(0)})
+	}
 }
 
 // opSetField 设置字段
 func opSetField(vm *VM) {
+	site := vm.currentFrame().ip - 1
 	fieldNameVal := vm.readConstant()
 	fieldName := fieldNameVal.AsString()
 
-	val := vm.pop()
+	// 编译器先计算右值再计算目标对象: [value, object]
 	objVal := vm.pop()
+	val := vm.pop()
 
 	if !objVal.IsObject() {
 		vm.runtimeError("cannot set field of non-object")
@@ -724,16 +765,35 @@ func opSetField(vm *VM) {
 	}
 
 	obj := objVal.AsObject()
-	obj.SetField(fieldName, val)
+	if slot := vm.cachedFieldSlot(site, obj.Class, fieldName); slot >= 0 && slot < len(obj.Slots) {
+		obj.Slots[slot] = val
+	} else {
+		obj.SetDynamicField(fieldName, val)
+	}
 	vm.push(val)
+	if vm.hookEvents&HookAccess != 0 {
+		vm.hookAccess(Access{Object: obj, Field: fieldName, Write: true, Value: val})
+	}
 }
 
 // opInvoke 调用方法
 func opInvoke(vm *VM) {
+	site := vm.currentFrame().ip - 1
 	methodNameVal := vm.readConstant()
 	methodName := methodNameVal.AsString()
 	argCount := int(vm.readByte())
 
+	receiver := vm.stack[vm.sp-argCount-1]
+	if receiver.IsObject() {
+		method := vm.cachedMethod(site, receiver.AsObject().Class, methodName, argCount)
+		vm.invokeMethod(method, methodName, argCount)
+		return
+	}
+	vm.invoke(methodName, argCount)
+}
+
+// invoke 在栈上参数之下的接收者上调用方法
+func (vm *VM) invoke(methodName string, argCount int) {
 	// 获取对象
 	receiver := vm.stack[vm.sp-argCount-1]
 	if !receiver.IsObject() {
@@ -742,8 +802,18 @@ func opInvoke(vm *VM) {
 	}
 
 	obj := receiver.AsObject()
-	method := obj.Class.GetMethodByArity(methodName, argCount)
+	vm.invokeMethod(obj.Class.GetMethodByArity(methodName, argCount), methodName, argCount)
+}
+
+// invokeMethod 调用已解析的方法，method 为 nil 表示接收者上没有该方法
+func (vm *VM) invokeMethod(method *bytecode.Method, methodName string, argCount int) {
 	if method == nil {
+		// 没有显式构造函数的类：new 表达式的构造调用视为空操作
+		if methodName == "__construct" {
+			vm.popN(argCount + 1)
+			vm.push(bytecode.NullValue)
+			return
+		}
 		vm.runtimeError("undefined method: %s", methodName)
 		return
 	}
@@ -769,16 +839,24 @@ func (vm *VM) callMethod(method *bytecode.Method, argCount int) {
 
 	bp := vm.sp - argCount - 1 // -1 for receiver
 
-	// 创建一个临时函数包装方法
-	fn := &bytecode.Function{
-		Name:       method.Name,
-		Arity:      method.Arity,
-		MinArity:   method.MinArity,
-		Chunk:      method.Chunk,
-		LocalCount: method.LocalCount,
-	}
+	vm.pushFrame(methodFunction(method), bp)
+}
 
-	vm.pushFrame(fn, bp)
+// opUnset 销毁栈顶的值
+// 对象有无参的 __destruct 时调用它 (每个对象只调用一次)，析构函数的返回值替换栈顶；
+// 其他值替换为 null
+func opUnset(vm *VM) {
+	val := vm.peek(0)
+	if val.IsObject() {
+		obj := val.AsObject()
+		if method := obj.Class.GetMethodByArity("__destruct", 0); method != nil && !obj.Destroyed {
+			obj.Destroyed = true
+			vm.callMethod(method, 0)
+			return
+		}
+	}
+	vm.pop()
+	vm.push(bytecode.NullValue)
 }
 
 // ============================================================================
@@ -794,58 +872,129 @@ func opNewArray(vm *VM) {
 		arr[i] = vm.pop()
 	}
 
+	if !vm.allocate(AllocArray, sizeOfArray(count)) {
+		return
+	}
 	vm.push(bytecode.NewArray(arr))
 }
 
-// opArrayGet 数组取值
+// opArrayGet 通用索引取值
+// 按运行时表示分派到数组、Map、字节数组或 SuperArray，数组越界抛出 ArrayIndexOutOfBoundsException
 func opArrayGet(vm *VM) {
-	index := vm.pop()
-	arrVal := vm.pop()
-
-	arr := arrVal.AsArray()
-	if arr == nil {
-		vm.runtimeError("cannot index non-array")
-		return
+	if vm.quickening {
+		vm.observe(vm.currentFrame().ip-1, vm.peek(1).Type())
 	}
-
-	idx := int(index.AsInt())
-	if idx < 0 || idx >= len(arr) {
-		vm.push(bytecode.NullValue)
+	switch vm.peek(1).Type() {
+	case bytecode.ValNativeArray:
+		opNativeArrayGet(vm)
+		return
+	case bytecode.ValBytes:
+		opBytesGet(vm)
+		return
+	case bytecode.ValMap:
+		opMapGet(vm)
 		return
 	}
 
-	vm.push(arr[idx])
+	index := vm.pop()
+	container := vm.pop()
+
+	switch container.Type() {
+	case bytecode.ValArray:
+		arr := container.AsArray()
+		idx := index.AsInt()
+		if idx < 0 || idx >= int64(len(arr)) {
+			vm.throwIndexOutOfBounds(idx, len(arr))
+			return
+		}
+		vm.push(arr[idx])
+	case bytecode.ValFixedArray:
+		fa := container.AsFixedArray()
+		idx := index.AsInt()
+		if idx < 0 || idx >= int64(fa.Capacity) {
+			vm.throwIndexOutOfBounds(idx, fa.Capacity)
+			return
+		}
+		vm.push(fa.Elements[idx])
+	case bytecode.ValSuperArray:
+		val, _ := container.AsSuperArray().Get(index)
+		vm.push(val)
+	default:
+		vm.runtimeError("cannot index %s", elementTypeName(container.Type()))
+	}
 }
 
-// opArraySet 数组赋值
+// opArraySet 通用索引赋值
+// 栈: [container, index, value] -> [value]
 func opArraySet(vm *VM) {
-	val := vm.pop()
-	index := vm.pop()
-	arrVal := vm.pop()
-
-	arr := arrVal.AsArray()
-	if arr == nil {
-		vm.runtimeError("cannot index non-array")
+	switch vm.peek(2).Type() {
+	case bytecode.ValNativeArray:
+		opNativeArraySet(vm)
+		return
+	case bytecode.ValBytes:
+		opBytesSet(vm)
+		return
+	case bytecode.ValMap:
+		opMapSet(vm)
 		return
 	}
 
-	idx := int(index.AsInt())
-	if idx >= 0 && idx < len(arr) {
+	val := vm.pop()
+	index := vm.pop()
+	container := vm.pop()
+
+	switch container.Type() {
+	case bytecode.ValArray:
+		arr := container.AsArray()
+		idx := index.AsInt()
+		if idx < 0 || idx >= int64(len(arr)) {
+			vm.throwIndexOutOfBounds(idx, len(arr))
+			return
+		}
 		arr[idx] = val
+	case bytecode.ValFixedArray:
+		fa := container.AsFixedArray()
+		idx := index.AsInt()
+		if idx < 0 || idx >= int64(fa.Capacity) {
+			vm.throwIndexOutOfBounds(idx, fa.Capacity)
+			return
+		}
+		fa.Elements[idx] = val
+	case bytecode.ValSuperArray:
+		container.AsSuperArray().Set(index, val)
+	default:
+		vm.runtimeError("cannot index %s", elementTypeName(container.Type()))
+		return
 	}
 
 	vm.push(val)
 }
 
-// opArrayLen 数组长度
+// opArrayLen 通用长度
 func opArrayLen(vm *VM) {
-	arrVal := vm.pop()
-	arr := arrVal.AsArray()
-	if arr == nil {
-		vm.push(bytecode.ZeroValue)
-		return
+	vm.push(bytecode.NewInt(int64(containerLen(vm.pop()))))
+}
+
+// containerLen 返回容器的元素个数，字符串返回字节长度，其他值为 0
+func containerLen(v bytecode.Value) int {
+	switch v.Type() {
+	case bytecode.ValArray:
+		return len(v.AsArray())
+	case bytecode.ValFixedArray:
+		return v.AsFixedArray().Capacity
+	case bytecode.ValNativeArray:
+		return v.AsNativeArray().Len()
+	case bytecode.ValBytes:
+		return len(v.AsBytes())
+	case bytecode.ValMap:
+		return len(v.AsMap())
+	case bytecode.ValSuperArray:
+		return v.AsSuperArray().Len()
+	case bytecode.ValString:
+		return len(v.AsString())
+	default:
+		return 0
 	}
-	vm.push(bytecode.NewInt(int64(len(arr))))
 }
 
 // ============================================================================
@@ -856,15 +1005,33 @@ func opArrayLen(vm *VM) {
 func opSuperArrayNew(vm *VM) {
 	count := int(vm.readShort())
 
-	sa := bytecode.NewSuperArray()
+	// 每个元素为 [value, 0] 或 [key, value, 1]，从栈顶倒序取出
+	type entry struct {
+		key, value bytecode.Value
+		hasKey     bool
+	}
+	entries := make([]entry, count)
+	for i := count - 1; i >= 0; i-- {
+		hasKey := vm.pop().AsInt() == 1
+		entries[i].value = vm.pop()
+		if hasKey {
+			entries[i].key = vm.pop()
+			entries[i].hasKey = true
+		}
+	}
 
-	// 读取键值对
-	for i := 0; i < count; i++ {
-		val := vm.pop()
-		key := vm.pop()
-		sa.Set(key, val)
+	sa := bytecode.NewSuperArray()
+	for _, e := range entries {
+		if e.hasKey {
+			sa.Set(e.key, e.value)
+		} else {
+			sa.Push(e.value)
+		}
 	}
 
+	if !vm.allocate(AllocSuperArray, superArrayHeader+count*2*valueSize) {
+		return
+	}
 	vm.push(bytecode.NewSuperArrayValue(sa))
 }
 
@@ -958,7 +1125,7 @@ func opCast(vm *VM) {
 
 	result, ok := castValue(val, typeName)
 	if !ok {
-		vm.runtimeError("cannot cast %s to %s", val.Type(), typeName)
+		vm.runtimeError("cannot cast %v to %s", val.Type(), typeName)
 		return
 	}
 	vm.push(result)
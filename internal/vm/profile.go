@@ -19,7 +19,7 @@ type TypeProfile struct {
 	BoolCount   int64 // 布尔类型次数
 	NullCount   int64 // null 类型次数
 	ObjectCount int64 // 对象类型次数
-	ArrayCount  int64 // 数组类型次数
+	ArrayCount  int64 // 动态数组类型次数
 	OtherCount  int64 // 其他类型次数
 }
 
@@ -53,6 +53,12 @@ func (tp *TypeProfile) DominantType() bytecode.ValueType {
 	if tp.BoolCount >= threshold {
 		return bytecode.ValBool
 	}
+	if tp.ObjectCount >= threshold {
+		return bytecode.ValObject
+	}
+	if tp.ArrayCount >= threshold {
+		return bytecode.ValArray
+	}
 
 	// 混合类型返回一个特殊值 (使用 255 表示未知)
 	return bytecode.ValueType(255)
@@ -79,7 +85,7 @@ func (tp *TypeProfile) RecordType(t bytecode.ValueType) {
 		atomic.AddInt64(&tp.NullCount, 1)
 	case bytecode.ValObject:
 		atomic.AddInt64(&tp.ObjectCount, 1)
-	case bytecode.ValArray, bytecode.ValSuperArray:
+	case bytecode.ValArray:
 		atomic.AddInt64(&tp.ArrayCount, 1)
 	default:
 		atomic.AddInt64(&tp.OtherCount, 1)
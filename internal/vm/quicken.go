@@ -0,0 +1,155 @@
+package vm
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// Quickening
+// ============================================================================
+//
+// 通用的算术、比较、索引和字段访问指令在执行时把操作数类型记录到所属函数的
+// TypeProfile (profile.go) 中。某个指令位置的观测次数达到 quickenThreshold 后：
+//   - 类型单态时，把指令就地改写为对应的快速指令 (bytecode.OpAddInt 等)
+//   - 否则保持通用指令，此后不再观测
+//
+// 快速指令执行前检查类型守卫，守卫失败时改写回通用指令 (去优化) 并重新执行，
+// 去优化的位置不会再次改写。改写与去优化的次数记录在 VMStats 中。
+
+// quickenThreshold 指令位置改写前需要的类型观测次数
+const quickenThreshold = 64
+
+// mixedTypes 二元运算两侧类型不同时记录的类型 (与 TypeProfile.DominantType 的混合类型一致)
+const mixedTypes = bytecode.ValueType(255)
+
+// quickVariants 通用指令 -> 主导类型 -> 快速指令
+var quickVariants = map[bytecode.OpCode]map[bytecode.ValueType]bytecode.OpCode{
+	bytecode.OpAdd: {bytecode.ValInt: bytecode.OpAddInt, bytecode.ValFloat: bytecode.OpAddFloat, bytecode.ValString: bytecode.OpAddString},
+	bytecode.OpSub: {bytecode.ValInt: bytecode.OpSubInt, bytecode.ValFloat: bytecode.OpSubFloat},
+	bytecode.OpMul: {bytecode.ValInt: bytecode.OpMulInt, bytecode.ValFloat: bytecode.OpMulFloat},
+	bytecode.OpLt:  {bytecode.ValInt: bytecode.OpLtInt, bytecode.ValFloat: bytecode.OpLtFloat},
+	bytecode.OpLe:  {bytecode.ValInt: bytecode.OpLeInt, bytecode.ValFloat: bytecode.OpLeFloat},
+	bytecode.OpGt:  {bytecode.ValInt: bytecode.OpGtInt, bytecode.ValFloat: bytecode.OpGtFloat},
+	bytecode.OpGe:  {bytecode.ValInt: bytecode.OpGeInt, bytecode.ValFloat: bytecode.OpGeFloat},
+
+	bytecode.OpArrayGet: {bytecode.ValArray: bytecode.OpArrayGetList},
+	bytecode.OpGetField: {bytecode.ValObject: bytecode.OpGetFieldMono},
+}
+
+// quickenSite 单个指令位置的类型观测
+type quickenSite struct {
+	types *TypeProfile // 该位置的类型 profile (属于所属函数的 FunctionProfile)
+	done  bool         // 已改写或判定为多态，不再观测
+}
+
+// SetQuickening 启用或禁用 quickening (默认启用)
+// 禁用后已改写的指令仍然有效，但不会再改写新的指令
+func (vm *VM) SetQuickening(enabled bool) {
+	vm.quickening = enabled
+}
+
+// binaryType 返回二元运算的操作数类型，两侧不同时返回 mixedTypes
+func binaryType(a, b bytecode.Value) bytecode.ValueType {
+	if t := a.Type(); t == b.Type() {
+		return t
+	}
+	return mixedTypes
+}
+
+// observe 记录当前帧 site 处通用指令的操作数类型，观测足够后尝试改写
+func (vm *VM) observe(site int, t bytecode.ValueType) {
+	sites := vm.frameSites()
+	if sites.quicken == nil {
+		sites.quicken = make([]*quickenSite, len(vm.currentFrame().chunk.Code))
+	}
+	qs := sites.quicken[site]
+	if qs == nil {
+		qs = &quickenSite{types: GetProfile(vm.currentFrame().function).GetTypeProfile(site)}
+		sites.quicken[site] = qs
+	}
+	if qs.done {
+		return
+	}
+	qs.types.RecordType(t)
+	if qs.types.Total() < quickenThreshold {
+		return
+	}
+	qs.done = true
+	vm.quicken(site, qs.types)
+}
+
+// quicken 按类型 profile 把 site 处的通用指令改写为快速指令
+func (vm *VM) quicken(site int, tp *TypeProfile) {
+	if !tp.IsMonomorphic() {
+		return
+	}
+	code := vm.currentFrame().chunk.Code
+	op := bytecode.OpCode(code[site])
+	quick, ok := quickVariants[op][tp.DominantType()]
+	if !ok {
+		return
+	}
+	// 字段访问还要求内联缓存是单态的
+	if op == bytecode.OpGetField {
+		ic := vm.cacheAt(site)
+		if ic.megamorphic || ic.n != 1 || ic.entries[0].slot < 0 {
+			return
+		}
+	}
+	code[site] = byte(quick)
+	vm.stats.QuickenedSites++
+}
+
+// deoptimize 快速指令的类型守卫失败：把 site 处的指令改写回通用指令，
+// 并让当前帧从该指令重新执行
+func (vm *VM) deoptimize(site int) {
+	frame := vm.currentFrame()
+	frame.chunk.Code[site] = byte(bytecode.OpCode(frame.chunk.Code[site]).Generic())
+	frame.ip = site
+	vm.stats.DeoptimizedSites++
+}
+
+// ============================================================================
+// 快速指令 (分派表部分，算术和比较指令内联在执行循环中)
+// ============================================================================
+
+// opArrayGetList 动态数组的整数索引读取
+// 守卫: 容器是动态数组且索引是整数
+func opArrayGetList(vm *VM) {
+	index := vm.peek(0)
+	container := vm.peek(1)
+	if container.Type() != bytecode.ValArray || index.Type() != bytecode.ValInt {
+		vm.deoptimize(vm.currentFrame().ip - 1)
+		return
+	}
+	arr := container.AsArray()
+	idx := index.AsInt()
+	if idx < 0 || idx >= int64(len(arr)) {
+		vm.popN(2)
+		vm.throwIndexOutOfBounds(idx, len(arr))
+		return
+	}
+	vm.popN(2)
+	vm.push(arr[idx])
+}
+
+// opGetFieldMono 单态字段读取
+// 守卫: 接收者的类与调用点内联缓存中唯一的类相同，且缓存仍然有效；
+// 安装了访问钩子时回退为通用指令，由它报告读取
+func opGetFieldMono(vm *VM) {
+	site := vm.currentFrame().ip - 1
+	vm.currentFrame().ip += 2
+	objVal := vm.peek(0)
+	if objVal.IsObject() && vm.hookEvents&HookAccess == 0 {
+		obj := objVal.AsObject()
+		ic := vm.cacheAt(site)
+		if ic.n == 1 && ic.entries[0].class == obj.Class {
+			if slot := ic.entries[0].slot; slot >= 0 && slot < len(obj.Slots) {
+				vm.stats.InlineCacheHits++
+				vm.stack[vm.sp-1] = obj.Slots[slot]
+				return
+			}
+		}
+	}
+	vm.deoptimize(site)
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 多个 VM 共享的类定义
+// ============================================================================
+//
+// 类、方法和函数定义在首次执行时才填充两项缓存：方法的 Function 包装和调用帧
+// 需要的栈空间。多个 VM 在不同 goroutine 中同时执行同一组定义之前，先用
+// PrepareClass / PrepareFunction 填好这些缓存，执行期间定义不再被写入。
+//
+// 静态变量、枚举注册表、内联缓存都属于各个 VM。quickening 会改写共享的字节码，
+// 共享定义的 VM 需要用 SetQuickening(false) 禁用。
+
+// PrepareClass 预先填充类中所有方法 (包括静态初始化器和方法内的闭包) 的执行缓存
+func PrepareClass(class *bytecode.Class) {
+	for _, methods := range class.Methods {
+		for _, method := range methods {
+			PrepareFunction(methodFunction(method))
+		}
+	}
+	if class.StaticInit != nil {
+		PrepareFunction(methodFunction(class.StaticInit))
+	}
+}
+
+// PrepareFunction 预先计算函数及其常量池中嵌套函数的调用帧空间
+func PrepareFunction(fn *bytecode.Function) {
+	if fn.MaxStack != 0 {
+		return
+	}
+	frameReserve(fn)
+	if fn.Chunk == nil {
+		return
+	}
+	for _, c := range fn.Chunk.Constants {
+		if inner := c.AsFunc(); inner != nil {
+			PrepareFunction(inner)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 可增长的操作数栈与调用栈
+// ============================================================================
+//
+// 操作数栈和调用栈都从较小的初始大小开始。压入调用帧前检查帧槽位和该帧
+// 需要的栈空间 (局部变量与操作数的最大深度)，不足时按倍数扩容并复制已用部分，
+// 因此帧内执行的指令无需逐条检查越界。扩容后执行循环重新获取栈和帧。
+//
+// 达到 VM 配置的上限时抛出 StackOverflowError，它和其他异常一样可以被捕获。
+
+// frameSlack 帧空间的额外余量，覆盖内置函数调用时补齐的默认参数等临时值
+const frameSlack = 32
+
+// frameReserve 返回函数调用帧需要的栈空间，首次调用时计算并缓存
+func frameReserve(fn *bytecode.Function) int {
+	if fn.MaxStack == 0 {
+		depth := 0
+		if fn.Chunk != nil {
+			depth = bytecode.CheckChunk(fn.Chunk, 0).MaxDepth
+		}
+		fn.MaxStack = fn.Arity + 1 + fn.LocalCount + depth + frameSlack
+	}
+	return fn.MaxStack
+}
+
+// reserveFrame 确保能以 bp 为基址压入 fn 的调用帧，空间不足时扩容
+// 超过上限时抛出 StackOverflowError 并返回 false；超过执行限制时终止执行并返回 false
+func (vm *VM) reserveFrame(fn *bytecode.Function, bp int) bool {
+	if vm.limits.enabled && !vm.checkpoint() {
+		return false
+	}
+	need := bp + frameReserve(fn)
+	if vm.fp < len(vm.frames) && need <= len(vm.stack) {
+		return true
+	}
+	if vm.fp >= vm.maxCallDepth {
+		vm.throwError("StackOverflowError", "stack overflow: call depth exceeded %d frames", vm.maxCallDepth)
+		return false
+	}
+	if need > vm.maxStackSize {
+		vm.throwError("StackOverflowError", "stack overflow: operand stack exceeded %d values", vm.maxStackSize)
+		return false
+	}
+
+	if vm.fp >= len(vm.frames) {
+		frames := make([]CallFrame, min(2*len(vm.frames), vm.maxCallDepth))
+		copy(frames, vm.frames[:vm.fp])
+		vm.frames = frames
+	}
+	if need > len(vm.stack) {
+		stack := make([]bytecode.Value, min(max(2*len(vm.stack), need), vm.maxStackSize))
+		copy(stack, vm.stack[:vm.sp])
+		vm.stack = stack
+	}
+	return true
+}
+
+// newStack 创建至少能容纳 n 个值的操作数栈
+func newStack(n int) []bytecode.Value {
+	return make([]bytecode.Value, max(n, InitialStackSize))
+}
+
+// abortEntry 入口帧因栈溢出无法压入时结束执行
+// 异常没有可用的处理器：最外层调用时直接作为未捕获异常报告
+func (vm *VM) abortEntry() bytecode.Value {
+	if ex := vm.pendingException; ex != nil && vm.runDepth == 0 {
+		vm.pendingException = nil
+		vm.reportUncaught(ex)
+	}
+	return bytecode.NullValue
+}
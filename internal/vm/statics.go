@@ -0,0 +1,182 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 静态成员与类初始化
+// ============================================================================
+//
+// 类在首次使用时初始化 (访问静态成员、调用静态方法、创建实例)：
+//   1. 先初始化父类
+//   2. 将编译期的静态默认值复制到运行时静态存储
+//   3. 执行静态初始化器，计算非常量的静态初始值
+//
+// 每个类只初始化一次。初始化器执行期间再次使用该类 (递归初始化) 时直接使用
+// 已就绪的静态存储，与 JVM 的类初始化语义一致。
+// 初始化器抛出异常的类不会重试，之后每次使用都抛出 NoClassDefFoundError
+// (原因为初始化器抛出的异常)，不会读到只初始化了一部分的静态值。
+//
+// 静态存储和初始化状态属于 VM 而不是类，同一个类定义可以被多个 VM 同时使用，
+// 各自拥有独立的静态变量。
+
+// initClass 确保类已完成静态初始化
+// 静态初始化器抛出异常时返回 false，异常已沿调用栈展开
+func (vm *VM) initClass(class *bytecode.Class) bool {
+	if cause, failed := vm.failedClasses[class]; failed {
+		ex := vm.newException("NoClassDefFoundError", fmt.Sprintf("could not initialize class %s", class.FullName()))
+		ex.Cause = cause
+		vm.raise(ex)
+		return false
+	}
+	if vm.statics[class] != nil {
+		return true
+	}
+	if class.Parent != nil && !vm.initClass(class.Parent) {
+		return false
+	}
+
+	// 存储先于初始化器就绪，递归初始化时直接使用
+	values := make(map[string]bytecode.Value, len(class.StaticVars))
+	for name, v := range class.StaticVars {
+		values[name] = v
+	}
+	if vm.statics == nil {
+		vm.statics = make(map[*bytecode.Class]map[string]bytecode.Value)
+	}
+	vm.statics[class] = values
+
+	if class.StaticInit != nil {
+		// 在嵌套执行循环中运行初始化器，返回时弹出其 null 返回值
+		if !vm.pushStaticFrame(methodFunction(class.StaticInit), vm.sp) {
+			// 栈溢出异常已在调用方展开
+			return false
+		}
+		vm.execute(vm.fp - 1)
+	}
+
+	if vm.hasError {
+		vm.markClassFailed(class, nil)
+		return false
+	}
+	if ex := vm.pendingException; ex != nil {
+		vm.pendingException = nil
+		vm.markClassFailed(class, ex)
+		vm.unwind(ex)
+		return false
+	}
+	return true
+}
+
+// markClassFailed 记录静态初始化失败的类，cause 为初始化器抛出的异常 (运行时错误时为 nil)
+func (vm *VM) markClassFailed(class *bytecode.Class, cause *bytecode.Exception) {
+	if vm.failedClasses == nil {
+		vm.failedClasses = make(map[*bytecode.Class]*bytecode.Exception)
+	}
+	vm.failedClasses[class] = cause
+}
+
+// resetStatics 丢弃所有类的运行时静态存储和初始化失败记录，下次使用类时重新初始化
+func (vm *VM) resetStatics() {
+	vm.statics = nil
+	vm.failedClasses = nil
+}
+
+// resolveStatic 查找静态变量所在的类并确保其已初始化
+// 继承的静态变量与父类共享同一存储
+func (vm *VM) resolveStatic(className, name string) (*bytecode.Class, bool) {
+	class := vm.GetClass(className)
+	if class == nil {
+		vm.runtimeError("undefined class: %s", className)
+		return nil, false
+	}
+	owner := class.StaticOwner(name)
+	if owner == nil {
+		vm.runtimeError("undefined static property: %s::$%s", className, name)
+		return nil, false
+	}
+	if !vm.initClass(class) {
+		return nil, false
+	}
+	return owner, true
+}
+
+// opGetStatic 读取静态变量、类常量或枚举成员
+// 字节码格式: OpGetStatic + classIdx(u16) + nameIdx(u16)
+func opGetStatic(vm *VM) {
+	className := vm.readConstant().AsString()
+	name := vm.readConstant().AsString()
+
+	class := vm.GetClass(className)
+	if class == nil {
+		if enum := vm.GetEnum(className); enum != nil {
+			if v, ok := enum.Cases[name]; ok {
+				vm.push(bytecode.NewEnumValue(enum.Name, name, v))
+				return
+			}
+			vm.runtimeError("undefined enum case: %s::%s", className, name)
+			return
+		}
+		vm.runtimeError("undefined class: %s", className)
+		return
+	}
+
+	// 类常量在编译期求值，不触发类初始化
+	if class.StaticOwner(name) == nil {
+		if v, ok := class.LookupConstant(name); ok {
+			vm.push(v)
+			return
+		}
+	}
+
+	owner, ok := vm.resolveStatic(className, name)
+	if !ok {
+		return
+	}
+	vm.push(vm.statics[owner][name])
+}
+
+// opSetStatic 写入静态变量
+// 字节码格式: OpSetStatic + classIdx(u16) + nameIdx(u16)
+// 栈: [value] -> [value]
+func opSetStatic(vm *VM) {
+	className := vm.readConstant().AsString()
+	name := vm.readConstant().AsString()
+
+	owner, ok := vm.resolveStatic(className, name)
+	if !ok {
+		return
+	}
+	val := vm.peek(0)
+	vm.statics[owner][name] = val
+}
+
+// ============================================================================
+// 宿主访问 (构建堆快照映像)
+// ============================================================================
+
+// InitClass 执行类 (及其父类) 的静态初始化，初始化器抛出的异常作为 *CallError 返回
+func (vm *VM) InitClass(class *bytecode.Class) error {
+	_, err := vm.hostCall(0, func() bool {
+		return vm.initClass(class)
+	})
+	return err
+}
+
+// Statics 返回类的运行时静态存储，类在该 VM 中尚未初始化时返回 nil
+// 返回的 map 与 VM 共享，调用方不应修改
+func (vm *VM) Statics(class *bytecode.Class) map[string]bytecode.Value {
+	return vm.statics[class]
+}
+
+// RestoreStatics 把类标记为已初始化并使用给定的静态存储，之后使用该类时不再执行静态初始化器
+// 用于从映像恢复构建时初始化好的状态，父类的静态存储需要一并恢复
+func (vm *VM) RestoreStatics(class *bytecode.Class, values map[string]bytecode.Value) {
+	if vm.statics == nil {
+		vm.statics = make(map[*bytecode.Class]map[string]bytecode.Value)
+	}
+	vm.statics[class] = values
+}
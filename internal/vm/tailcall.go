@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// 尾调用
+// ============================================================================
+//
+// 尾调用复用当前栈帧：被调用者 (或接收者) 与参数移动到当前帧的 bp 处，
+// 弹出当前帧后按普通调用压入新帧。新帧占用同一个帧槽位和同一段栈空间，
+// 返回值仍落在原帧的 bp 处，因此任意深度的尾递归只占用常量的帧和栈空间。
+//
+// 以下情况退化为普通调用，由编译器在尾调用指令之后发出的 OpReturn 返回结果：
+//   - 当前帧存在活动的异常处理器 (返回前需要执行 finally)
+//   - 被调用者是内置函数 (不压入帧)
+//   - 方法或静态方法解析失败 (由普通调用路径报告错误)
+
+// reuseFrame 为尾调用释放当前帧：把栈顶 n 个值移动到当前帧的 bp 处并弹出当前帧
+// 当前帧不能复用时返回 false，栈和帧保持不变
+func (vm *VM) reuseFrame(n int) bool {
+	frame := vm.currentFrame()
+	if len(frame.handlers) > 0 {
+		return false
+	}
+	copy(vm.stack[frame.bp:], vm.stack[vm.sp-n:vm.sp])
+	vm.sp = frame.bp + n
+	if vm.hookEvents&HookCall != 0 {
+		vm.hookExit(frame.function)
+	}
+	vm.fp--
+	vm.stats.TailCalls++
+	return true
+}
+
+// opTailCall 尾调用函数或闭包
+// 字节码格式: OpTailCall + argCount(u8)
+func opTailCall(vm *VM) {
+	argCount := int(vm.readByte())
+	callee := vm.stack[vm.sp-argCount-1]
+
+	switch callee.Type() {
+	case bytecode.ValFunc:
+		if fn := callee.AsFunc(); fn != nil && !fn.IsBuiltin && vm.reuseFrame(argCount+1) {
+			vm.callFunction(fn, argCount)
+			return
+		}
+	case bytecode.ValClosure:
+		if closure := callee.AsClosure(); closure != nil && vm.reuseFrame(argCount+1) {
+			vm.callClosure(closure, argCount)
+			return
+		}
+	}
+	vm.callValue(argCount)
+}
+
+// opTailCallStatic 尾调用静态方法
+// 字节码格式: OpTailCallStatic + classIdx(u16) + methodIdx(u16) + argCount(u8)
+func opTailCallStatic(vm *VM) {
+	classIndex := vm.readShort()
+	methodIndex := vm.readShort()
+	argCount := int(vm.readByte())
+
+	// 在复用帧之前解析，解析错误和类初始化异常归属于调用方
+	method := vm.resolveStaticMethod(classIndex, methodIndex)
+	if method == nil {
+		return
+	}
+	vm.reuseFrame(argCount)
+	vm.pushStaticFrame(methodFunction(method), vm.sp-argCount)
+}
+
+// opTailCallMethod 尾调用实例方法
+// 字节码格式: OpTailCallMethod + nameIdx(u16) + argCount(u8)
+func opTailCallMethod(vm *VM) {
+	site := vm.currentFrame().ip - 1
+	methodName := vm.readConstant().AsString()
+	argCount := int(vm.readByte())
+
+	receiver := vm.stack[vm.sp-argCount-1]
+	if !receiver.IsObject() {
+		vm.invoke(methodName, argCount)
+		return
+	}
+	method := vm.cachedMethod(site, receiver.AsObject().Class, methodName, argCount)
+	if method != nil && vm.reuseFrame(argCount+1) {
+		vm.callMethod(method, argCount)
+		return
+	}
+	vm.invokeMethod(method, methodName, argCount)
+}
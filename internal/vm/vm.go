@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"fmt"
+
 	"github.com/tangzhangming/nova/internal/bytecode"
 )
 
@@ -8,25 +10,31 @@ import (
 // VM 核心结构
 // ============================================================================
 
-// StackSize 默认操作数栈大小
-const StackSize = 16384
-
-// CallStackSize 默认调用栈大小
-const CallStackSize = 4096
+// 操作数栈和调用栈从较小的初始大小开始，按需倍增，直到 VM 配置的上限
+const (
+	InitialStackSize     = 256     // 操作数栈初始大小 (值的个数)
+	InitialCallStackSize = 64      // 调用栈初始大小 (帧数)
+	DefaultMaxStackSize  = 1 << 22 // 操作数栈默认上限
+	DefaultMaxCallDepth  = 100000  // 调用栈默认上限
+)
 
 // GlobalsSize 默认全局变量数量
 const GlobalsSize = 1024
 
 // VM 虚拟机
 type VM struct {
-	// 操作数栈
-	stack [StackSize]bytecode.Value
+	// 操作数栈 (属于当前运行的协程，切换协程时整体替换)
+	stack []bytecode.Value
 	sp    int // 栈指针 (指向下一个空位)
 
 	// 调用栈
-	frames [CallStackSize]CallFrame
+	frames []CallFrame
 	fp     int // 帧指针 (当前帧索引)
 
+	// 栈上限，超过时抛出 StackOverflowError
+	maxStackSize int
+	maxCallDepth int
+
 	// 全局变量
 	globals []bytecode.Value
 
@@ -34,17 +42,49 @@ type VM struct {
 	chunk *bytecode.Chunk // 当前字节码
 	ip    int             // 指令指针
 
-	// 类和函数注册表
+	// 类、枚举和函数注册表
 	classes   map[string]*bytecode.Class
+	enums     map[string]*bytecode.Enum
 	functions map[string]*bytecode.Function
 
+	// 类的运行时静态存储 (类定义可以被多个 VM 共享，静态变量属于各个 VM)
+	statics map[*bytecode.Class]map[string]bytecode.Value
+	// 静态初始化失败的类及初始化器抛出的异常，之后使用这些类时抛出 NoClassDefFoundError
+	failedClasses map[*bytecode.Class]*bytecode.Exception
+
+	// 调用点附加信息 (内联缓存、quickening)：字节码块 -> 按指令偏移索引的表
+	sites      map[*bytecode.Chunk]*chunkSites
+	classEpoch uint64 // 类版本号，类被重新定义时递增
+	quickening bool   // 是否按类型 profile 改写热点指令
+
 	// 错误处理
 	hasError bool
 	errorMsg string
 
+	// 异常处理
+	baseFP           int                 // 当前执行循环的入口帧 (嵌套执行时用于界定展开范围)
+	runDepth         int                 // 执行循环嵌套深度
+	pendingException *bytecode.Exception // 越过当前执行循环边界、尚未处理的异常
+	currentException *bytecode.Exception // 最近一次被 catch 捕获的异常 (用于 OpRethrow)
+	uncaught         *bytecode.Exception // 未捕获的异常 (执行终止原因)
+
+	// 协程调度
+	sched scheduler
+
+	// 执行限制
+	limits limitState
+
+	// 内存统计和堆上限
+	mem memoryState
+
 	// 统计信息
 	stats VMStats
 
+	// 执行钩子 (调试器、性能分析器)，hookEvents 为所有钩子关心的事件的并集
+	hooks      []installedHook
+	hookEvents HookEvent
+	tracing    bool // 正在调用行号钩子 (当前帧停在将要执行的指令上)
+
 	// Profile 配置
 	profilingEnabled bool
 	currentFunction  *bytecode.Function
@@ -57,14 +97,23 @@ type CallFrame struct {
 	ip           int                // 返回地址
 	bp           int                // 基指针 (栈基址)
 	chunk        *bytecode.Chunk    // 函数的字节码
-	isStaticCall bool               // 是否是静态方法调用（不需要弹出被调用者）
+	isStaticCall bool               // 是否是静态方法调用（栈上没有被调用者/$this 槽位）
+	handlers     []tryHandler       // 异常处理器栈 (try/catch/finally)
+	sites        *chunkSites        // 所属字节码块的调用点附加信息 (首次使用时获取)
+	traceIP      int                // 行号钩子最近检查的指令偏移 + 1 (0 表示尚未检查)
 }
 
 // VMStats 虚拟机统计信息
 type VMStats struct {
-	InstructionsExecuted uint64 // 执行的指令数
+	InstructionsExecuted uint64 // 执行的指令数 (超级指令计为一条)
 	HotFunctionsDetected int    // 检测到的热点函数数
 	FunctionCalls        uint64 // 函数调用次数
+	TailCalls            uint64 // 复用栈帧的尾调用次数
+	InlineCacheHits      uint64 // 内联缓存命中次数
+	InlineCacheMisses    uint64 // 内联缓存未命中次数 (含超多态调用点的查找)
+	MegamorphicSites     int    // 转为超多态的调用点数
+	QuickenedSites       int    // 改写为快速指令的指令位置数
+	DeoptimizedSites     int    // 类型守卫失败后改写回通用指令的位置数
 	Allocations          uint64 // 分配次数
 }
 
@@ -75,14 +124,36 @@ type VMStats struct {
 // New 创建新的虚拟机
 func New() *VM {
 	vm := &VM{
+		stack:   make([]bytecode.Value, InitialStackSize),
+		frames:  make([]CallFrame, InitialCallStackSize),
 		globals: make([]bytecode.Value, GlobalsSize),
 		classes: make(map[string]*bytecode.Class),
+		enums:   make(map[string]*bytecode.Enum),
 		functions: make(map[string]*bytecode.Function),
+		maxStackSize: DefaultMaxStackSize,
+		maxCallDepth: DefaultMaxCallDepth,
+		quickening:   true,
 	}
+	vm.resetMemory()
 	return vm
 }
 
+// SetStackLimits 设置操作数栈 (值的个数) 和调用栈 (帧数) 的上限，非正数表示使用默认值
+// 上限对主协程和所有子协程分别生效
+func (vm *VM) SetStackLimits(maxStackSize, maxCallDepth int) {
+	if maxStackSize <= 0 {
+		maxStackSize = DefaultMaxStackSize
+	}
+	if maxCallDepth <= 0 {
+		maxCallDepth = DefaultMaxCallDepth
+	}
+	vm.maxStackSize = maxStackSize
+	vm.maxCallDepth = maxCallDepth
+}
+
 // Reset 重置虚拟机状态 (用于复用)
+// 丢弃协程、全局变量、类的静态存储和栈上的值，保留已注册的类、函数、执行限制、堆上限
+// 和调用点缓存，之后可以安全地执行下一个请求
 func (vm *VM) Reset() {
 	vm.sp = 0
 	vm.fp = 0
@@ -90,7 +161,32 @@ func (vm *VM) Reset() {
 	vm.chunk = nil
 	vm.hasError = false
 	vm.errorMsg = ""
+	vm.baseFP = 0
+	vm.runDepth = 0
+	vm.pendingException = nil
+	vm.currentException = nil
+	vm.uncaught = nil
+	vm.limits.exceeded = nil
+	vm.resetScheduler()
+	vm.resetStatics()
+	vm.resetStacks()
+	clear(vm.globals)
 	vm.stats = VMStats{}
+	vm.resetMemory()
+}
+
+// resetStacks 清除栈上残留的值，扩容过的栈缩回初始大小
+func (vm *VM) resetStacks() {
+	if len(vm.stack) > InitialStackSize {
+		vm.stack = make([]bytecode.Value, InitialStackSize)
+	} else {
+		clear(vm.stack)
+	}
+	if len(vm.frames) > InitialCallStackSize {
+		vm.frames = make([]CallFrame, InitialCallStackSize)
+	} else {
+		clear(vm.frames)
+	}
 }
 
 // ============================================================================
@@ -124,7 +220,11 @@ func (vm *VM) popN(n int) {
 // ============================================================================
 
 // pushFrame 压入调用帧
-func (vm *VM) pushFrame(fn *bytecode.Function, bp int) {
+// 栈空间不足且已达上限时抛出 StackOverflowError 并返回 false
+func (vm *VM) pushFrame(fn *bytecode.Function, bp int) bool {
+	if !vm.reserveFrame(fn, bp) {
+		return false
+	}
 	frame := &vm.frames[vm.fp]
 	frame.function = fn
 	frame.closure = nil
@@ -132,12 +232,22 @@ func (vm *VM) pushFrame(fn *bytecode.Function, bp int) {
 	frame.ip = 0
 	frame.bp = bp
 	frame.isStaticCall = false // 默认不是静态调用
+	frame.handlers = frame.handlers[:0]
+	frame.sites = nil
+	frame.traceIP = 0
 	vm.fp++
 	vm.stats.FunctionCalls++
+	if vm.hookEvents&HookCall != 0 {
+		vm.hookEnter(frame.function)
+	}
+	return true
 }
 
 // pushStaticFrame 压入静态方法调用帧
-func (vm *VM) pushStaticFrame(fn *bytecode.Function, bp int) {
+func (vm *VM) pushStaticFrame(fn *bytecode.Function, bp int) bool {
+	if !vm.reserveFrame(fn, bp) {
+		return false
+	}
 	frame := &vm.frames[vm.fp]
 	frame.function = fn
 	frame.closure = nil
@@ -145,12 +255,22 @@ func (vm *VM) pushStaticFrame(fn *bytecode.Function, bp int) {
 	frame.ip = 0
 	frame.bp = bp
 	frame.isStaticCall = true // 标记为静态调用
+	frame.handlers = frame.handlers[:0]
+	frame.sites = nil
+	frame.traceIP = 0
 	vm.fp++
 	vm.stats.FunctionCalls++
+	if vm.hookEvents&HookCall != 0 {
+		vm.hookEnter(frame.function)
+	}
+	return true
 }
 
 // pushClosureFrame 压入闭包调用帧
-func (vm *VM) pushClosureFrame(closure *bytecode.Closure, bp int) {
+func (vm *VM) pushClosureFrame(closure *bytecode.Closure, bp int) bool {
+	if !vm.reserveFrame(closure.Function, bp) {
+		return false
+	}
 	frame := &vm.frames[vm.fp]
 	frame.function = closure.Function
 	frame.closure = closure
@@ -158,12 +278,22 @@ func (vm *VM) pushClosureFrame(closure *bytecode.Closure, bp int) {
 	frame.chunk = closure.Function.Chunk
 	frame.ip = 0
 	frame.bp = bp
+	frame.handlers = frame.handlers[:0]
+	frame.sites = nil
+	frame.traceIP = 0
 	vm.fp++
 	vm.stats.FunctionCalls++
+	if vm.hookEvents&HookCall != 0 {
+		vm.hookEnter(frame.function)
+	}
+	return true
 }
 
 // popFrame 弹出调用帧
 func (vm *VM) popFrame() *CallFrame {
+	if vm.hookEvents&HookCall != 0 {
+		vm.hookExit(vm.frames[vm.fp-1].function)
+	}
 	vm.fp--
 	return &vm.frames[vm.fp]
 }
@@ -224,6 +354,10 @@ const (
 
 // RegisterClass 注册类
 func (vm *VM) RegisterClass(class *bytecode.Class) {
+	// 重新定义已注册的类：调用点缓存的方法和字段槽位可能已经过期
+	if old, ok := vm.classes[class.FullName()]; ok && old != class {
+		vm.invalidateInlineCaches()
+	}
 	// 注册完整名称
 	vm.classes[class.FullName()] = class
 	// 同时注册短名（用于简单引用）
@@ -270,17 +404,14 @@ func (vm *VM) RegisterBuiltin(name string, fn *bytecode.Function) {
 // 枚举注册
 // ============================================================================
 
-// enums 枚举注册表（在 VM 结构体外定义，简化结构）
-var vmEnums = make(map[string]*bytecode.Enum)
-
 // DefineEnum 定义枚举
 func (vm *VM) DefineEnum(enum *bytecode.Enum) {
-	vmEnums[enum.Name] = enum
+	vm.enums[enum.Name] = enum
 }
 
 // GetEnum 获取枚举
 func (vm *VM) GetEnum(name string) *bytecode.Enum {
-	return vmEnums[name]
+	return vm.enums[name]
 }
 
 // ============================================================================
@@ -295,6 +426,10 @@ func (vm *VM) CallStaticMethod(class *bytecode.Class, methodName string, args []
 		return InterpretRuntimeError
 	}
 
+	if vm.runDepth == 0 {
+		vm.beginRun()
+	}
+
 	// 设置参数到栈上
 	argCount := 0
 	if args != nil {
@@ -304,15 +439,11 @@ func (vm *VM) CallStaticMethod(class *bytecode.Class, methodName string, args []
 		argCount = len(args)
 	}
 
-	// 创建临时 Function 包装 Method 的 Chunk
-	fn := &bytecode.Function{
-		Name:  method.Name,
-		Arity: method.Arity,
-		Chunk: method.Chunk,
+	// 静态方法没有被调用者槽位
+	if !vm.pushStaticFrame(methodFunction(method), vm.sp-argCount) {
+		vm.abortEntry()
+		return InterpretRuntimeError
 	}
-
-	// 压入调用帧并执行
-	vm.pushFrame(fn, vm.sp-argCount)
 	result := vm.runLoop()
 
 	// 检查执行结果
@@ -332,7 +463,6 @@ func (vm *VM) readByte() byte {
 	frame := vm.currentFrame()
 	b := frame.chunk.Code[frame.ip]
 	frame.ip++
-	vm.stats.InstructionsExecuted++
 	return b
 }
 
@@ -359,8 +489,10 @@ func (vm *VM) readConstant() bytecode.Value {
 // runtimeError 设置运行时错误
 func (vm *VM) runtimeError(format string, args ...interface{}) {
 	vm.hasError = true
-	vm.errorMsg = format
-	// TODO: 格式化错误消息
+	vm.errorMsg = fmt.Sprintf(format, args...)
+	if vm.fp > 0 {
+		vm.errorMsg += "\n" + formatStackTrace(vm.captureStackTrace())
+	}
 }
 
 // HasError 检查是否有错误
@@ -373,6 +505,11 @@ func (vm *VM) GetError() string {
 	return vm.errorMsg
 }
 
+// UncaughtException 获取导致执行终止的未捕获异常 (没有则返回 nil)
+func (vm *VM) UncaughtException() *bytecode.Exception {
+	return vm.uncaught
+}
+
 // ============================================================================
 // 统计信息
 // ============================================================================
@@ -265,3 +265,131 @@ func BenchmarkTypeSwitch(b *testing.B) {
 		}
 	}
 }
+
+// ============================================================================
+// 类型化容器 vs SuperArray 基准测试
+// 索引读写经由 VM 指令，对比专用指令与 SuperArray 的通用路径
+// ============================================================================
+
+// benchIndexGetSet 通过指定的读写指令对容器执行 arr[k] = arr[k] + 1
+func benchIndexGetSet(b *testing.B, container bytecode.Value, keys []bytecode.Value, get, set OpHandler) {
+	vm := New()
+	vm.quickening = false // 直接调用指令处理函数时没有调用帧，无法记录类型 profile
+	one := bytecode.NewInt(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		vm.push(container)
+		vm.push(key)
+		vm.push(container)
+		vm.push(key)
+		get(vm)
+		vm.push(one)
+		opAdd(vm)
+		set(vm)
+		vm.pop()
+	}
+}
+
+func intKeys(n int) []bytecode.Value {
+	keys := make([]bytecode.Value, n)
+	for i := range keys {
+		keys[i] = bytecode.NewInt(int64(i))
+	}
+	return keys
+}
+
+// stringKeys 模拟字符串字面量键
+func stringKeys(n int) []bytecode.Value {
+	keys := make([]bytecode.Value, n)
+	for i := range keys {
+		keys[i] = bytecode.NewString("key" + string(rune('a'+i%26)) + string(rune('a'+i/26)))
+	}
+	return keys
+}
+
+func BenchmarkNativeArray_IndexGetSet(b *testing.B) {
+	arr := bytecode.NewNativeArrayValue(bytecode.NewNativeArray(bytecode.ValInt, 64))
+	benchIndexGetSet(b, arr, intKeys(64), opNativeArrayGet, opNativeArraySet)
+}
+
+func BenchmarkSuperArray_IndexGetSet(b *testing.B) {
+	sa := bytecode.NewSuperArray()
+	for i := 0; i < 64; i++ {
+		sa.Push(bytecode.NewInt(0))
+	}
+	benchIndexGetSet(b, bytecode.NewSuperArrayValue(sa), intKeys(64), opArrayGet, opArraySet)
+}
+
+func BenchmarkBytes_IndexGetSet(b *testing.B) {
+	benchIndexGetSet(b, bytecode.NewBytes(make([]byte, 64)), intKeys(64), opBytesGet, opBytesSet)
+}
+
+func BenchmarkMap_StringKeyGetSet(b *testing.B) {
+	keys := stringKeys(64)
+	m := make(map[bytecode.Key]bytecode.Value, len(keys))
+	for _, k := range keys {
+		m[bytecode.MapKey(k)] = bytecode.NewInt(0)
+	}
+	benchIndexGetSet(b, bytecode.NewMap(m), keys, opMapGet, opMapSet)
+}
+
+func BenchmarkSuperArray_StringKeyGetSet(b *testing.B) {
+	keys := stringKeys(64)
+	sa := bytecode.NewSuperArray()
+	for _, k := range keys {
+		sa.Set(k, bytecode.NewInt(0))
+	}
+	benchIndexGetSet(b, bytecode.NewSuperArrayValue(sa), keys, opArrayGet, opArraySet)
+}
+
+// sparseIntKeys 不连续的整数键，SuperArray 需要走哈希路径
+func sparseIntKeys(n int) []bytecode.Value {
+	keys := make([]bytecode.Value, n)
+	for i := range keys {
+		keys[i] = bytecode.NewInt(int64(i) * 1000)
+	}
+	return keys
+}
+
+func BenchmarkMap_SparseIntKeyGetSet(b *testing.B) {
+	keys := sparseIntKeys(64)
+	m := make(map[bytecode.Key]bytecode.Value, len(keys))
+	for _, k := range keys {
+		m[bytecode.MapKey(k)] = bytecode.NewInt(0)
+	}
+	benchIndexGetSet(b, bytecode.NewMap(m), keys, opMapGet, opMapSet)
+}
+
+func BenchmarkSuperArray_SparseIntKeyGetSet(b *testing.B) {
+	keys := sparseIntKeys(64)
+	sa := bytecode.NewSuperArray()
+	for _, k := range keys {
+		sa.Set(k, bytecode.NewInt(0))
+	}
+	benchIndexGetSet(b, bytecode.NewSuperArrayValue(sa), keys, opArrayGet, opArraySet)
+}
+
+func BenchmarkNativeArray_Len(b *testing.B) {
+	vm := New()
+	arr := bytecode.NewNativeArrayValue(bytecode.NewNativeArray(bytecode.ValInt, 64))
+	for i := 0; i < b.N; i++ {
+		vm.push(arr)
+		opNativeArrayLen(vm)
+		vm.pop()
+	}
+}
+
+func BenchmarkSuperArray_Len(b *testing.B) {
+	vm := New()
+	sa := bytecode.NewSuperArray()
+	for i := 0; i < 64; i++ {
+		sa.Push(bytecode.NewInt(int64(i)))
+	}
+	v := bytecode.NewSuperArrayValue(sa)
+	for i := 0; i < b.N; i++ {
+		vm.push(v)
+		opArrayLen(vm)
+		vm.pop()
+	}
+}
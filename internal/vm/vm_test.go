@@ -1,9 +1,18 @@
 package vm
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/compiler"
+	"github.com/tangzhangming/nova/internal/parser"
+	"github.com/tangzhangming/nova/internal/profiler"
 )
 
 // ============================================================================
@@ -205,22 +214,22 @@ func TestHelperStringConcat(t *testing.T) {
 
 func TestHelperSuperArray(t *testing.T) {
 	sa := Helper_SA_New()
-	
+
 	// 设置值
 	Helper_SA_SetInt(sa, 0, bytecode.NewString("zero"))
 	Helper_SA_SetString(sa, "key", bytecode.NewInt(42))
-	
+
 	// 获取值
 	v0 := Helper_SA_GetInt(sa, 0)
 	if v0.AsString() != "zero" {
 		t.Errorf("Expected 'zero', got '%s'", v0.AsString())
 	}
-	
+
 	vKey := Helper_SA_GetString(sa, "key")
 	if vKey.AsInt() != 42 {
 		t.Errorf("Expected 42, got %d", vKey.AsInt())
 	}
-	
+
 	// 长度
 	if Helper_SA_Len(sa) != 2 {
 		t.Errorf("Expected len=2, got %d", Helper_SA_Len(sa))
@@ -275,3 +284,2482 @@ func TestValueEquality(t *testing.T) {
 		})
 	}
 }
+
+// ============================================================================
+// 异常处理测试
+// ============================================================================
+
+// runSola 编译并执行源码中 main 类的 main 方法，返回 print 的输出和 VM
+func runSola(t *testing.T, source string, setup ...func(*VM)) ([]string, *VM) {
+	t.Helper()
+
+	p := parser.New(source, "test.sola")
+	file := p.Parse()
+	if p.HasErrors() {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	c := compiler.New()
+	if _, errs := c.Compile(file); len(errs) > 0 {
+		t.Fatalf("compile errors: %v", errs)
+	}
+
+	vm := New()
+	classes := c.Classes()
+	for _, class := range classes {
+		if parent, ok := classes[class.ParentName]; ok {
+			class.Parent = parent
+		}
+		vm.DefineClass(class)
+	}
+
+	var output []string
+	vm.RegisterBuiltin("print", &bytecode.Function{
+		Name:      "print",
+		IsBuiltin: true,
+		BuiltinFn: func(args []bytecode.Value) bytecode.Value {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = arg.String()
+			}
+			output = append(output, strings.Join(parts, " "))
+			return bytecode.NullValue
+		},
+	})
+
+	for _, fn := range setup {
+		fn(vm)
+	}
+	vm.CallStaticMethod(vm.GetClass("main"), "main", nil)
+	if vm.hasError {
+		output = append(output, "error: "+vm.GetError())
+	}
+	return output, vm
+}
+
+// exceptionClasses 测试用的异常类层次
+const exceptionClasses = `
+class Exception {
+    public string $message = "";
+    public function __construct(string $message) { $this->message = $message; }
+    public function getMessage(): string { return $this->message; }
+}
+class IOException extends Exception {}
+class FileNotFoundException extends IOException {}
+class ParseException extends Exception {}
+`
+
+func expectOutput(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output mismatch\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class main {
+    public static function main(): void {
+        try {
+            print("before");
+            throw new Exception("boom");
+            print("unreachable");
+        } catch (Exception $e) {
+            print("caught", $e->getMessage());
+        }
+        print("after");
+    }
+}`)
+	if vm.HasError() {
+		t.Fatalf("unexpected error: %s", vm.GetError())
+	}
+	expectOutput(t, out, "before", "caught boom", "after")
+}
+
+func TestCatchMatchesByType(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class main {
+    public static function raise(int $kind): void {
+        if ($kind == 1) {
+            throw new ParseException("parse");
+        }
+        if ($kind == 2) {
+            throw new FileNotFoundException("missing");
+        }
+        throw new Exception("generic");
+    }
+    public static function main(): void {
+        for ($i := 1; $i <= 3; $i++) {
+            try {
+                main::raise($i);
+            } catch (IOException $e) {
+                print("io", $e->getMessage());
+            } catch (ParseException $e) {
+                print("parse", $e->getMessage());
+            } catch (Exception $e) {
+                print("other", $e->getMessage());
+            }
+        }
+    }
+}`)
+	if vm.HasError() {
+		t.Fatalf("unexpected error: %s", vm.GetError())
+	}
+	expectOutput(t, out, "parse parse", "io missing", "other generic")
+}
+
+func TestFinally(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class main {
+    public static function normal(): void {
+        try {
+            print("try");
+        } finally {
+            print("finally");
+        }
+    }
+    public static function early(): int {
+        try {
+            return 1;
+        } finally {
+            print("finally on return");
+        }
+        return 2;
+    }
+    public static function propagate(): void {
+        try {
+            throw new IOException("io");
+        } finally {
+            print("finally on throw");
+        }
+    }
+    public static function main(): void {
+        main::normal();
+        print(main::early());
+        try {
+            main::propagate();
+        } catch (IOException $e) {
+            print("outer", $e->getMessage());
+        }
+    }
+}`)
+	if vm.HasError() {
+		t.Fatalf("unexpected error: %s", vm.GetError())
+	}
+	expectOutput(t, out, "try", "finally", "finally on return", "1", "finally on throw", "outer io")
+}
+
+func TestFinallyOnBreakAndRethrow(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class main {
+    public static function main(): void {
+        for ($i := 0; $i < 3; $i++) {
+            try {
+                if ($i == 1) {
+                    break;
+                }
+                print("body", $i);
+            } finally {
+                print("finally", $i);
+            }
+        }
+        try {
+            try {
+                throw new ParseException("inner");
+            } catch (ParseException $e) {
+                print("rethrowing");
+                throw $e;
+            } finally {
+                print("inner finally");
+            }
+        } catch (Exception $e) {
+            print("outer", $e->getMessage());
+        }
+    }
+}`)
+	if vm.HasError() {
+		t.Fatalf("unexpected error: %s", vm.GetError())
+	}
+	expectOutput(t, out, "body 0", "finally 0", "finally 1", "rethrowing", "inner finally", "outer inner")
+}
+
+func TestDivideByZeroIsCatchable(t *testing.T) {
+	out, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        $zero := 0;
+        try {
+            print(10 / $zero);
+        } catch (DivideByZeroException $e) {
+            print("caught");
+        }
+    }
+}`)
+	if vm.HasError() {
+		t.Fatalf("unexpected error: %s", vm.GetError())
+	}
+	expectOutput(t, out, "caught")
+}
+
+func TestUncaughtException(t *testing.T) {
+	_, vm := runSola(t, exceptionClasses+`
+class main {
+    public static function fail(): void {
+        throw new IOException("disk full");
+    }
+    public static function main(): void {
+        main::fail();
+    }
+}`)
+	if !vm.HasError() {
+		t.Fatal("expected uncaught exception")
+	}
+	ex := vm.UncaughtException()
+	if ex == nil || ex.Type != "IOException" {
+		t.Fatalf("expected IOException, got %v", ex)
+	}
+	msg := vm.GetError()
+	for _, want := range []string{"Uncaught IOException: disk full", "main.fail", "main.main"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error message %q does not contain %q", msg, want)
+		}
+	}
+}
+
+// ============================================================================
+// 编译和指令回归测试
+// ============================================================================
+
+func TestSuperArrayLiteral(t *testing.T) {
+	output, vm := runSola(t, `class main {
+    public static function main(): void {
+        $a := ["name" => "sola", "version" => 1];
+        print($a);
+    }
+}`)
+	if vm.HasError() {
+		t.Fatalf("unexpected error: %s", vm.GetError())
+	}
+	expectOutput(t, output, "[name => sola, version => 1]")
+}
+
+func TestArrowFunction(t *testing.T) {
+	// compiler.New 创建的编译器缓存编译的函数
+	output, _ := runSola(t, `class main {
+    public static function main(): void {
+        $f := (): int => 42;
+        print($f());
+    }
+}`)
+	expectOutput(t, output, "42")
+}
+
+func TestArrowFunctionInBlock(t *testing.T) {
+	// 箭头函数之后，所在块的作用域深度和局部变量不受影响
+	output, _ := runSola(t, `class main {
+    public static function main(): void {
+        $n := 1;
+        if ($n > 0) {
+            $f := (): int => 42;
+            $b := 10;
+            print($f() + $b);
+        }
+        $b := "s";
+        print($b);
+    }
+}`)
+	expectOutput(t, output, "52", "s")
+}
+
+// ============================================================================
+// 协程测试
+// ============================================================================
+
+// fakeClock 使协程定时器的测试与真实时间无关：sleep 直接推进时钟
+func fakeClock(vm *VM) {
+	now := time.Unix(0, 0)
+	vm.sched.now = func() time.Time { return now }
+	vm.sched.sleep = func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCoroutineSpawnAwait(t *testing.T) {
+	out, _ := runSola(t, `
+class W {
+    public static function square(int $n): int { return $n * $n; }
+}
+class main {
+    public static function main(): void {
+        Coroutine<int> $task = Coroutine::spawn((): int => W::square(7));
+        print("spawned", $task->isCompleted);
+        print($task->await());
+        print("done", $task->isCompleted, $task->tryGetResult());
+    }
+}`)
+	expectOutput(t, out, "spawned false", "49", "done true 49")
+}
+
+func TestCoroutineYieldInterleaves(t *testing.T) {
+	out, _ := runSola(t, `
+class W {
+    public static function worker(string $name, int $n): int {
+        for (int $i = 0; $i < $n; $i++) {
+            print($name, $i);
+            Coroutine::yield();
+        }
+        return $n;
+    }
+}
+class main {
+    public static function main(): void {
+        go W::worker("a", 3);
+        Coroutine<int> $b = Coroutine::spawn((): int => W::worker("b", 2));
+        print("main", $b->await());
+    }
+}`)
+	expectOutput(t, out, "a 0", "b 0", "a 1", "b 1", "a 2", "main 2")
+}
+
+func TestCoroutineExceptionPropagates(t *testing.T) {
+	out, _ := runSola(t, exceptionClasses+`
+class W {
+    public static function fail(): int { throw new IOException("disk"); }
+}
+class main {
+    public static function main(): void {
+        Coroutine<int> $task = Coroutine::spawn((): int => W::fail());
+        try {
+            $task->await();
+        } catch (IOException $e) {
+            print("caught", $e->getMessage());
+        }
+        print($task->isFailed, $task->exception->getMessage());
+    }
+}`)
+	expectOutput(t, out, "caught disk", "true disk")
+}
+
+func TestCoroutineCancel(t *testing.T) {
+	out, _ := runSola(t, exceptionClasses+`
+class CancellationException extends Exception {}
+class W {
+    public static function loop(): int {
+        while (true) {
+            print("tick");
+            Coroutine::yield();
+        }
+        return 0;
+    }
+}
+class main {
+    public static function main(): void {
+        Coroutine<int> $task = Coroutine::spawn((): int => W::loop());
+        Coroutine::yield();
+        Coroutine::yield();
+        print($task->cancel(), $task->cancel());
+        try {
+            $task->await();
+        } catch (CancellationException $e) {
+            print("cancelled", $task->isCancelled);
+        }
+    }
+}`)
+	expectOutput(t, out, "tick", "tick", "true false", "cancelled true")
+}
+
+func TestCoroutineAwaitTimeout(t *testing.T) {
+	out, _ := runSola(t, exceptionClasses+`
+class TimeoutException extends Exception {}
+class W {
+    public static function slow(): int {
+        Coroutine::delay(500)->await();
+        return 1;
+    }
+}
+class main {
+    public static function main(): void {
+        Coroutine<int> $task = Coroutine::spawn((): int => W::slow());
+        try {
+            $task->await(100);
+        } catch (TimeoutException $e) {
+            print("timeout", $task->isCompleted);
+        }
+        print($task->await(1000));
+    }
+}`, fakeClock)
+	expectOutput(t, out, "timeout false", "1")
+}
+
+func TestCoroutineCombinators(t *testing.T) {
+	out, _ := runSola(t, exceptionClasses+`
+class W {
+    public static function after(int $ms, int $value): int {
+        Coroutine::delay($ms)->await();
+        return $value;
+    }
+    public static function failAfter(int $ms): int {
+        Coroutine::delay($ms)->await();
+        throw new IOException("failed");
+    }
+}
+class main {
+    public static function main(): void {
+        dynamic $all = Coroutine::all([
+            Coroutine::spawn((): int => W::after(30, 1)),
+            Coroutine::spawn((): int => W::after(10, 2)),
+            Coroutine::spawn((): int => W::after(20, 3)),
+        ])->await();
+        print($all[0], $all[1], $all[2]);
+
+        print(Coroutine::race([
+            Coroutine::spawn((): int => W::after(30, 1)),
+            Coroutine::spawn((): int => W::after(10, 2)),
+        ])->await());
+
+        print(Coroutine::any([
+            Coroutine::spawn((): int => W::failAfter(5)),
+            Coroutine::spawn((): int => W::after(20, 4)),
+        ])->await());
+
+        try {
+            Coroutine::all([
+                Coroutine::spawn((): int => W::after(10, 1)),
+                Coroutine::spawn((): int => W::failAfter(5)),
+            ])->await();
+        } catch (IOException $e) {
+            print("all failed", $e->getMessage());
+        }
+    }
+}`, fakeClock)
+	expectOutput(t, out, "1 2 3", "2", "4", "all failed failed")
+}
+
+func TestCoroutineDelay(t *testing.T) {
+	out, _ := runSola(t, `
+class main {
+    public static function main(): void {
+        Coroutine::delay(10)->await();
+        print("slept");
+        dynamic $empty = Coroutine::all([])->await();
+        print("empty all", $empty);
+    }
+}`, fakeClock)
+	expectOutput(t, out, "slept", "empty all []")
+}
+
+// ============================================================================
+// 通道测试
+// ============================================================================
+
+func TestChannelUnbufferedPingPong(t *testing.T) {
+	out, _ := runSola(t, `
+class W {
+    public static function pong(Channel<int> $ping, Channel<int> $pong): void {
+        for (int $i = 0; $i < 3; $i++) {
+            int $v = $ping->receive();
+            $pong->send($v * 10);
+        }
+    }
+}
+class main {
+    public static function main(): void {
+        Channel<int> $ping = new Channel<int>();
+        Channel<int> $pong = new Channel<int>();
+        go W::pong($ping, $pong);
+        for (int $i = 1; $i <= 3; $i++) {
+            $ping->send($i);
+            print("got", $pong->receive());
+        }
+    }
+}`)
+	expectOutput(t, out, "got 10", "got 20", "got 30")
+}
+
+func TestChannelBuffered(t *testing.T) {
+	out, _ := runSola(t, `
+class main {
+    public static function main(): void {
+        Channel<int> $ch = new Channel<int>(2);
+        $ch->send(1);
+        $ch->send(2);
+        print($ch->length(), $ch->capacity(), $ch->trySend(3));
+        print($ch->receive(), $ch->tryReceive(), $ch->tryReceive());
+        print($ch->length());
+    }
+}`)
+	expectOutput(t, out, "2 2 false", "1 2 null", "0")
+}
+
+func TestChannelClose(t *testing.T) {
+	out, _ := runSola(t, exceptionClasses+`
+class ChannelClosedException extends Exception {}
+class W {
+    public static function produce(Channel<int> $ch): void {
+        for (int $i = 0; $i < 3; $i++) {
+            $ch->send($i);
+        }
+        $ch->close();
+    }
+}
+class main {
+    public static function main(): void {
+        Channel<int> $ch = new Channel<int>(1);
+        go W::produce($ch);
+        try {
+            while (true) {
+                print("recv", $ch->receive());
+            }
+        } catch (ChannelClosedException $e) {
+            print("closed", $e->getMessage(), $ch->isClosed());
+        }
+        try {
+            $ch->send(9);
+        } catch (ChannelClosedException $e) {
+            print($e->getMessage());
+        }
+        try {
+            $ch->close();
+        } catch (ChannelClosedException $e) {
+            print($e->getMessage());
+        }
+    }
+}`)
+	expectOutput(t, out, "recv 0", "recv 1", "recv 2",
+		"closed receive from closed channel true",
+		"send on closed channel", "close of closed channel")
+}
+
+func TestSelect(t *testing.T) {
+	out, _ := runSola(t, `
+class W {
+    public static function send(Channel<string> $ch, string $v): void {
+        $ch->send($v);
+    }
+}
+class main {
+    public static function main(): void {
+        Channel<int> $a = new Channel<int>(1);
+        Channel<string> $b = new Channel<string>();
+        select {
+            case $v := $a->receive():
+                print("a", $v);
+            default:
+                print("nothing ready");
+        }
+        $a->send(7);
+        select {
+            case $v := $a->receive():
+                print("a", $v);
+            case $b->send("x"):
+                print("sent");
+        }
+        go W::send($b, "hello");
+        select {
+            case $v := $a->receive():
+                print("a", $v);
+            case $s := $b->receive():
+                print("b", $s);
+        }
+        print("done");
+    }
+}`)
+	expectOutput(t, out, "nothing ready", "a 7", "b hello", "done")
+}
+
+func TestSelectIsFair(t *testing.T) {
+	out, _ := runSola(t, `
+class main {
+    public static function main(): void {
+        Channel<int> $a = new Channel<int>(1);
+        Channel<int> $b = new Channel<int>(1);
+        int $na = 0;
+        int $nb = 0;
+        for (int $i = 0; $i < 200; $i++) {
+            $a->trySend(1);
+            $b->trySend(2);
+            select {
+                case $a->receive():
+                    $na++;
+                case $b->receive():
+                    $nb++;
+            }
+        }
+        print($na > 50, $nb > 50, $na + $nb);
+    }
+}`)
+	expectOutput(t, out, "true true 200")
+}
+
+func TestDeadlockReport(t *testing.T) {
+	out, vm := runSola(t, `
+class W {
+    public static function stuck(Channel<int> $ch): void {
+        $ch->receive();
+    }
+}
+class main {
+    public static function main(): void {
+        Channel<int> $ch = new Channel<int>();
+        go W::stuck($ch);
+        $ch->receive();
+    }
+}`)
+	if !vm.hasError {
+		t.Fatalf("expected deadlock, got %q", out)
+	}
+	msg := vm.GetError()
+	for _, want := range []string{
+		"all coroutines are asleep - deadlock!",
+		"(main) [chan receive]",
+		"[chan receive]",
+		"at W.stuck (test.sola:4)",
+		"at main.main (test.sola:11)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("deadlock report missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+// ============================================================================
+// 静态成员测试
+// ============================================================================
+
+func TestStaticFields(t *testing.T) {
+	out, _ := runSola(t, `
+class Counter {
+    const int STEP = 2;
+    public static int $count = 0;
+
+    public static function next(): int {
+        self::$count += self::STEP;
+        return self::$count;
+    }
+}
+class main {
+    public static function main(): void {
+        Counter::next();
+        Counter::next();
+        Counter::$count = Counter::$count + 1;
+        print(Counter::$count, Counter::STEP);
+    }
+}`)
+	expectOutput(t, out, "5 2")
+}
+
+func TestStaticInitRunsOnce(t *testing.T) {
+	out, _ := runSola(t, `
+class Registry {
+    public static int $base = Registry::compute();
+    public static string $name = "reg";
+
+    public static function compute(): int {
+        print("init");
+        return 40;
+    }
+}
+class main {
+    public static function main(): void {
+        print("start");
+        print(Registry::$base + 1, Registry::$name);
+        Registry::$base = 1;
+        print(Registry::$base);
+    }
+}`)
+	expectOutput(t, out, "start", "init", "41 reg", "1")
+}
+
+func TestStaticInheritance(t *testing.T) {
+	out, _ := runSola(t, `
+class Base {
+    public static int $instances = W::log("Base", 10);
+
+    public static function make(): int {
+        self::$instances++;
+        return self::$instances;
+    }
+}
+class Child extends Base {
+    public static int $own = W::log("Child", 1);
+
+    public static function both(): int {
+        return parent::make() + self::$own;
+    }
+}
+class W {
+    public static function log(string $cls, int $v): int {
+        print("init", $cls);
+        return $v;
+    }
+}
+class main {
+    public static function main(): void {
+        print(Child::both());
+        print(Base::$instances, Child::$instances);
+    }
+}`)
+	expectOutput(t, out, "init Base", "init Child", "12", "11 11")
+}
+
+func TestStaticInitException(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class Config {
+    public static int $port = Config::load();
+
+    public static function load(): int {
+        throw new IOException("missing config");
+    }
+}
+class main {
+    public static function main(): void {
+        try {
+            print(Config::$port);
+        } catch (IOException $e) {
+            print("caught", $e->getMessage());
+        }
+        print(Config::$port);
+    }
+}`)
+	if len(out) == 0 || out[0] != "caught missing config" {
+		t.Errorf("unexpected output: %q", out)
+	}
+	ex := vm.UncaughtException()
+	if ex == nil || ex.Type != "NoClassDefFoundError" || ex.Message != "could not initialize class Config" {
+		t.Fatalf("expected uncaught NoClassDefFoundError, got %v", ex)
+	}
+	if ex.Cause == nil || ex.Cause.Type != "IOException" {
+		t.Errorf("cause should be the initializer's exception, got %v", ex.Cause)
+	}
+}
+
+func TestFailedStaticInitRethrowsOnEveryUse(t *testing.T) {
+	// 初始化失败后不会读到未初始化的静态值，子类和实例化同样失败
+	out, _ := runSola(t, exceptionClasses+`
+class NoClassDefFoundError extends Exception {}
+class C {
+    public static int $x = C::boom();
+    public static int $y = 1;
+
+    public static function boom(): int {
+        throw new IOException("boom");
+    }
+}
+class D extends C {
+}
+class main {
+    public static function main(): void {
+        try {
+            print(C::$x);
+        } catch (IOException $e) {
+            print("first", $e->getMessage());
+        }
+        try {
+            print(C::$x);
+        } catch (NoClassDefFoundError $e) {
+            print("second", $e->getMessage());
+        }
+        try {
+            C::$y = 2;
+        } catch (NoClassDefFoundError $e) {
+            print("write", $e->getMessage());
+        }
+        try {
+            $d := new D();
+        } catch (NoClassDefFoundError $e) {
+            print("subclass", $e->getMessage());
+        }
+    }
+}`)
+	expectOutput(t, out,
+		"first boom",
+		"second could not initialize class C",
+		"write could not initialize class C",
+		"subclass could not initialize class C")
+}
+
+// roundTripClasses 将已注册的类序列化再反序列化，模拟从 .solac 文件加载
+func roundTripClasses(t *testing.T) func(*VM) {
+	return func(vm *VM) {
+		script := &bytecode.Function{Name: "script", Chunk: bytecode.NewChunk()}
+		script.Chunk.WriteOp(bytecode.OpReturnNull, 1)
+		data, err := bytecode.NewSerializer().Serialize(script, vm.classes, nil)
+		if err != nil {
+			t.Fatalf("serialize: %v", err)
+		}
+		cf, err := bytecode.NewDeserializer(data).Deserialize()
+		if err != nil {
+			t.Fatalf("deserialize: %v", err)
+		}
+		vm.classes = make(map[string]*bytecode.Class)
+		for _, class := range cf.Classes {
+			if parent, ok := cf.Classes[class.ParentName]; ok {
+				class.Parent = parent
+			}
+			vm.DefineClass(class)
+		}
+	}
+}
+
+func TestStaticsSurviveSerialization(t *testing.T) {
+	out, _ := runSola(t, `
+class Defaults {
+    const string NAME = "svc";
+    public static int $retries = 3;
+    public static string $host = "localhost";
+    public static string[] $tags = string{"a", "b"};
+    public static int $timeout = Defaults::$retries * 1000;
+}
+class main {
+    public static function main(): void {
+        print(Defaults::NAME, Defaults::$retries, Defaults::$host, Defaults::$timeout);
+        print(Defaults::$tags[1]);
+    }
+}`, roundTripClasses(t))
+	expectOutput(t, out, "svc 3 localhost 3000", "b")
+}
+
+// snapshotImage 初始化所有类后编码为映像，再以解码出的类和静态存储替换 VM 的状态，
+// 模拟 sola build --image 之后 sola run app.image
+func snapshotImage(t *testing.T) func(*VM) {
+	return func(vm *VM) {
+		bytecode.BuildAllVTables(vm.classes)
+		statics := make(map[*bytecode.Class]map[string]bytecode.Value)
+		for _, class := range vm.classes {
+			if err := vm.InitClass(class); err != nil {
+				t.Fatalf("init %s: %v", class.Name, err)
+			}
+			for c := class; c != nil; c = c.Parent {
+				statics[c] = vm.Statics(c)
+			}
+		}
+		data, err := bytecode.EncodeImage(&bytecode.ImageFile{
+			SourceFile: "test.sola",
+			Classes:    vm.classes,
+			Entry:      vm.GetClass("main"),
+			Statics:    statics,
+		})
+		if err != nil {
+			t.Fatalf("encode image: %v", err)
+		}
+		img, err := bytecode.DecodeImage(data)
+		if err != nil {
+			t.Fatalf("decode image: %v", err)
+		}
+		vm.classes = make(map[string]*bytecode.Class)
+		vm.resetStatics()
+		for _, class := range img.Classes {
+			vm.DefineClass(class)
+		}
+		for class, values := range img.Statics {
+			vm.RestoreStatics(class, values)
+		}
+		if img.Entry != vm.GetClass("main") {
+			t.Errorf("image entry = %v, want main", img.Entry)
+		}
+	}
+}
+
+func TestImageRestoresHeap(t *testing.T) {
+	out, vm := runSola(t, `
+interface Shape {
+    public function area(): int;
+}
+class Square implements Shape {
+    public int $side = 0;
+    public function area(): int { return $this->side * $this->side; }
+}
+class Node {
+    public int $id = 0;
+    public dynamic $next = null;
+}
+class Base {
+    public static int $created = 0;
+}
+class Registry extends Base {
+    public static int[] $ids = new int[] { 1, 2, 3 };
+    public static map[string]int $ages = map[string]int{ "alice": 25 };
+    public static dynamic $ring = Registry::ring();
+    public static dynamic $shape = Registry::square(3);
+
+    public static function ring(): dynamic {
+        print("init");
+        Node $a = new Node();
+        Node $b = new Node();
+        $a->id = 1;
+        $b->id = 2;
+        $a->next = $b;
+        $b->next = $a;
+        return $a;
+    }
+
+    public static function square(int $n): dynamic {
+        Square $s = new Square();
+        $s->side = $n;
+        Base::$created++;
+        return $s;
+    }
+}
+class main {
+    public static function main(): void {
+        print(Registry::$ring->next->next->id, Registry::$ring->next->id);
+        print(Registry::$ids[2], Registry::$ages["alice"], Base::$created);
+        print(Registry::$shape->area());
+        Registry::$ages["bob"] = 30;
+        print(Registry::$ages["bob"], Registry::$ages["alice"]);
+    }
+}`, snapshotImage(t))
+	// 静态初始化器只在构建映像时执行
+	expectOutput(t, out, "init", "1 2", "3 25 1", "9", "30 25")
+
+	vt := vm.GetClass("Square").VTables["Shape"]
+	if vt == nil || vt.Lookup(0) != vm.GetClass("Square").GetMethod("area") {
+		t.Errorf("Square vtable for Shape not restored: %+v", vt)
+	}
+}
+
+func TestImageRejectsStale(t *testing.T) {
+	class := bytecode.NewClass("main")
+	data, err := bytecode.EncodeImage(&bytecode.ImageFile{
+		Classes: map[string]*bytecode.Class{"main": class},
+		Entry:   class,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bytecode.DecodeImage(data); err != nil {
+		t.Fatalf("decode fresh image: %v", err)
+	}
+
+	// 字节码版本、映像格式版本、指令集指纹
+	for _, offset := range []int{5, 7, 11} {
+		stale := append([]byte(nil), data...)
+		stale[offset]++
+		_, err := bytecode.DecodeImage(stale)
+		if err == nil || !strings.Contains(err.Error(), "stale image") {
+			t.Errorf("byte %d changed: err = %v, want stale image", offset, err)
+		}
+	}
+}
+
+func TestImageRejectsClosure(t *testing.T) {
+	class := bytecode.NewClass("main")
+	closure := bytecode.NewClosure(&bytecode.Closure{Function: bytecode.NewFunction("f")})
+	_, err := bytecode.EncodeImage(&bytecode.ImageFile{
+		Classes: map[string]*bytecode.Class{"main": class},
+		Statics: map[*bytecode.Class]map[string]bytecode.Value{
+			class: {"handlers": bytecode.NewArray([]bytecode.Value{closure})},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "main::$handlers") || !strings.Contains(err.Error(), "closure") {
+		t.Errorf("err = %v, want closure in main::$handlers rejected", err)
+	}
+}
+
+func TestStaticsPerVM(t *testing.T) {
+	var other []string
+	out, vm := runSola(t, `
+class Counter {
+    public static int $count = Counter::start();
+
+    public static function start(): int {
+        print("init");
+        return 10;
+    }
+}
+class main {
+    public static function main(): void {
+        Counter::$count++;
+        print(Counter::$count);
+    }
+}`, func(vm *VM) { vm.SetGlobal(0, bytecode.NewInt(7)) })
+	expectOutput(t, out, "init", "11")
+
+	// 另一个 VM 共享同一组类定义，静态变量各自独立
+	vm2 := New()
+	for _, class := range vm.classes {
+		vm2.DefineClass(class)
+	}
+	vm2.RegisterBuiltin("print", &bytecode.Function{
+		Name:      "print",
+		IsBuiltin: true,
+		BuiltinFn: func(args []bytecode.Value) bytecode.Value {
+			other = append(other, args[0].String())
+			return bytecode.NullValue
+		},
+	})
+	vm2.CallStaticMethod(vm2.GetClass("main"), "main", nil)
+	vm2.CallStaticMethod(vm2.GetClass("main"), "main", nil)
+	expectOutput(t, other, "init", "11", "12")
+
+	// Reset 丢弃静态存储和全局变量，类重新初始化
+	vm.Reset()
+	if g := vm.GetGlobal(0); !g.IsNull() {
+		t.Errorf("global after Reset = %v, want null", g)
+	}
+	other = nil
+	vm.RegisterBuiltin("print", vm2.GetFunction("print"))
+	vm.CallStaticMethod(vm.GetClass("main"), "main", nil)
+	expectOutput(t, other, "init", "11")
+}
+
+// ============================================================================
+// 类型化容器
+// ============================================================================
+
+// collectionClasses 测试用的容器异常类
+const collectionClasses = exceptionClasses + `
+class ArrayIndexOutOfBoundsException extends Exception {}
+class InvalidCastException extends Exception {}
+class InvalidOperationException extends Exception {}
+`
+
+// expectOpcodes 检查 main::main 的字节码中包含指定指令
+func expectOpcodes(t *testing.T, ops ...bytecode.OpCode) func(*VM) {
+	return func(vm *VM) {
+		t.Helper()
+		code := vm.GetClass("main").Methods["main"][0].Chunk.Disassemble("main")
+		for _, op := range ops {
+			if !strings.Contains(code, op.String()) {
+				t.Errorf("expected %s in main::main:\n%s", op, code)
+			}
+		}
+	}
+}
+
+func TestNativeArray(t *testing.T) {
+	out, _ := runSola(t, `
+class main {
+    public static function main(): void {
+        int[] $a = new int[3];
+        $a[0] = 7;
+        $a[2] = $a[0] * 2;
+        print($a[0], $a[1], $a[2], $a.length);
+
+        float[] $f = new float[] { 1, 2.5 };
+        $f[0] = $f[0] + 0.25;
+        print($f[0], $f[1], $f.length);
+
+        int[4] $fixed = { 1, 2 };
+        $fixed[3] = 9;
+        print($fixed[0], $fixed[1], $fixed[2], $fixed[3], $fixed.length);
+
+        string[] $s = new string[2];
+        $s[1] = "x";
+        print($s[0] == "", $s[1]);
+    }
+}`, expectOpcodes(t, bytecode.OpNativeArrayNew, bytecode.OpNativeArrayInit,
+		bytecode.OpNativeArrayGet, bytecode.OpNativeArraySet, bytecode.OpNativeArrayLen))
+	expectOutput(t, out, "7 0 14 3", "1.25 2.5 2", "1 2 0 9 4", "true x")
+}
+
+func TestArrayIndexOutOfBounds(t *testing.T) {
+	out, _ := runSola(t, collectionClasses+`
+class main {
+    public static function main(): void {
+        int[] $a = new int[] { 1, 2, 3 };
+        try {
+            print($a[3]);
+        } catch (ArrayIndexOutOfBoundsException $e) {
+            print("get", $e->getMessage());
+        }
+        try {
+            $a[-1] = 5;
+        } catch (ArrayIndexOutOfBoundsException $e) {
+            print("set", $e->getMessage());
+        }
+        string[] $names = string{"a", "b"};
+        try {
+            print($names[2]);
+        } catch (ArrayIndexOutOfBoundsException $e) {
+            print("typed", $e->getMessage());
+        }
+    }
+}`)
+	expectOutput(t, out,
+		"get index 3 out of bounds for length 3",
+		"set index -1 out of bounds for length 3",
+		"typed index 2 out of bounds for length 2")
+}
+
+func TestMapOps(t *testing.T) {
+	out, _ := runSola(t, `
+class main {
+    public static function main(): void {
+        map[string]int $ages = map[string]int{ "alice": 25, "bob": 30 };
+        $ages["carol"] = 41;
+        $ages["alice"] = $ages["alice"] + 1;
+        string $key = "bo" + "b";
+        print($ages["alice"], $ages[$key], $ages["carol"], $ages.length);
+        print($ages.has("bob"), $ages.has("dave"));
+    }
+}`, expectOpcodes(t, bytecode.OpNewMap, bytecode.OpMapGet, bytecode.OpMapSet, bytecode.OpMapHas, bytecode.OpMapLen))
+	expectOutput(t, out, "26 30 41 3", "true false")
+}
+
+func TestMapStringKeysCompareByContent(t *testing.T) {
+	// Go 代码构造的 Map，键与脚本中的字符串不是同一个实例
+	meta := func(vm *VM) {
+		vm.RegisterBuiltin("meta", &bytecode.Function{
+			Name:      "meta",
+			IsBuiltin: true,
+			BuiltinFn: func(args []bytecode.Value) bytecode.Value {
+				return bytecode.NewMap(map[bytecode.Key]bytecode.Value{
+					bytecode.MapKey(bytecode.NewString("name")): bytecode.NewString("Route"),
+				})
+			},
+		})
+	}
+	out, _ := runSola(t, `
+class main {
+    public static dynamic $m = null;
+    public static function main(): void {
+        main::$m = meta();
+        string $key = "na" + "me";
+        print(main::$m["name"], main::$m[$key]);
+    }
+}`, meta)
+	expectOutput(t, out, "Route Route")
+}
+
+func TestBytesOps(t *testing.T) {
+	out, _ := runSola(t, collectionClasses+`
+class main {
+    public static function main(): void {
+        byte[] $b = { 1, 2, 3, 255 };
+        $b[0] = 256 + 7;
+        print($b[0], $b[3], $b.length);
+        byte[] $tail = $b.slice(1);
+        byte[] $mid = $b.slice(1, 3);
+        byte[] $all = $mid.concat($tail);
+        print($tail.length, $mid[1], $all.length, $all[4]);
+        try {
+            print($b[4]);
+        } catch (ArrayIndexOutOfBoundsException $e) {
+            print("caught", $e->getMessage());
+        }
+    }
+}`, expectOpcodes(t, bytecode.OpNewBytes, bytecode.OpBytesGet, bytecode.OpBytesSet,
+		bytecode.OpBytesLen, bytecode.OpBytesSlice, bytecode.OpBytesConcat))
+	expectOutput(t, out, "7 255 4", "3 3 5 255", "caught index 4 out of bounds for length 4")
+}
+
+func TestArrayPush(t *testing.T) {
+	out, _ := runSola(t, collectionClasses+`
+class main {
+    public static function main(): void {
+        string[] $names = string{"a"};
+        $names.push("b");
+        print($names.length, $names[1]);
+        int[] $fixed = new int[2];
+        try {
+            $fixed.push(3);
+        } catch (InvalidOperationException $e) {
+            print("caught", $e->getMessage());
+        }
+    }
+}`)
+	expectOutput(t, out, "2 b", "caught cannot push to a fixed-length array")
+}
+
+// ============================================================================
+// 尾调用测试
+// ============================================================================
+
+// recordDepth 注册 depth() 内置函数，记录每次调用时的帧深度
+func recordDepth(depths *[]int) func(*VM) {
+	return func(vm *VM) {
+		vm.RegisterBuiltin("depth", &bytecode.Function{
+			Name:      "depth",
+			IsBuiltin: true,
+			BuiltinFn: func(args []bytecode.Value) bytecode.Value {
+				*depths = append(*depths, vm.fp)
+				return bytecode.NullValue
+			},
+		})
+	}
+}
+
+func TestTailRecursionConstantFrames(t *testing.T) {
+	var depths []int
+	out, vm := runSola(t, `
+class R {
+    public static function count(int $n, int $acc): int {
+        if ($n == 0) {
+            depth();
+            return $acc;
+        }
+        return R::count($n - 1, $acc + 1);
+    }
+}
+class main {
+    public static function main(): void {
+        depth();
+        print(R::count(10000000, 0));
+    }
+}`, recordDepth(&depths))
+	expectOutput(t, out, "10000000")
+	if len(depths) != 2 || depths[1] != depths[0]+1 {
+		t.Errorf("tail recursion should run in a single frame, depths: %v", depths)
+	}
+	if got := vm.Stats().TailCalls; got < 10000000 {
+		t.Errorf("expected 10000000 tail calls, got %d", got)
+	}
+}
+
+func TestTailCallPositions(t *testing.T) {
+	var depths []int
+	out, _ := runSola(t, exceptionClasses+`
+class Counter {
+    public function down(int $n): int {
+        if ($n == 0) {
+            depth();
+            return 0;
+        }
+        return $this->down($n - 1);
+    }
+}
+class R {
+    public static function isEven(int $n): bool {
+        return $n == 0 ? true : R::isOdd($n - 1);
+    }
+    public static function isOdd(int $n): bool {
+        return $n == 0 ? false : R::isEven($n - 1);
+    }
+    public static function steps(int $n, int $acc): int {
+        return match ($n) {
+            0 => $acc,
+            1 => R::steps(0, $acc + 1),
+            _ => R::steps($n - 2, $acc + 1)
+        };
+    }
+    public static function apply(func(int): int $f, int $n): int {
+        return $f($n);
+    }
+    public static function guarded(int $n): int {
+        if ($n == 0) {
+            return 0;
+        }
+        try {
+            return R::guarded($n - 1);
+        } finally {
+            depth();
+        }
+    }
+}
+class main {
+    public static function main(): void {
+        depth();
+        Counter $c = new Counter();
+        print($c->down(100000));
+        print(R::isEven(100001), R::steps(100001, 0));
+        print(R::apply((int $n): int => R::steps($n, 0), 100000));
+        print(R::guarded(3));
+    }
+}`, recordDepth(&depths))
+	expectOutput(t, out, "0", "false 50001", "50000", "0")
+	if len(depths) != 5 {
+		t.Fatalf("unexpected depth records: %v", depths)
+	}
+	if depths[1] != depths[0]+1 {
+		t.Errorf("method tail recursion should reuse its frame, depths: %v", depths)
+	}
+	// try 内的调用不能复用帧：finally 依次在每一层执行
+	if depths[2] != depths[0]+3 || depths[4] != depths[0]+1 {
+		t.Errorf("calls inside try must keep their frames, depths: %v", depths)
+	}
+}
+
+// ============================================================================
+// 栈增长与栈溢出测试
+// ============================================================================
+
+const overflowClasses = exceptionClasses + `
+class StackOverflowError extends Exception {}
+class R {
+    public static function sum(int $n): int {
+        if ($n == 0) {
+            return 0;
+        }
+        return $n + R::sum($n - 1);
+    }
+    public static function forever(int $n): int {
+        return 1 + R::forever($n + 1);
+    }
+    public static function ping(int $n): int {
+        return 1 + R::pong($n + 1);
+    }
+    public static function pong(int $n): int {
+        return 1 + R::ping($n + 1);
+    }
+}
+`
+
+func TestStacksStartSmall(t *testing.T) {
+	vm := New()
+	if len(vm.stack) != InitialStackSize || len(vm.frames) != InitialCallStackSize {
+		t.Errorf("expected initial stacks of %d values and %d frames, got %d and %d",
+			InitialStackSize, InitialCallStackSize, len(vm.stack), len(vm.frames))
+	}
+}
+
+func TestDeepRecursionGrowsStacks(t *testing.T) {
+	out, vm := runSola(t, overflowClasses+`
+class main {
+    public static function main(): void {
+        print(R::sum(50000));
+    }
+}`)
+	expectOutput(t, out, "1250025000")
+	if len(vm.frames) <= 50000 {
+		t.Errorf("call stack should have grown past 50000 frames, got %d", len(vm.frames))
+	}
+}
+
+func TestStackOverflowIsCatchable(t *testing.T) {
+	out, _ := runSola(t, overflowClasses+`
+class main {
+    public static function main(): void {
+        try {
+            R::forever(0);
+        } catch (StackOverflowError $e) {
+            print("caught", $e->getMessage());
+        }
+        print(R::sum(100));
+    }
+}`, func(vm *VM) { vm.SetStackLimits(0, 1000) })
+	expectOutput(t, out, "caught stack overflow: call depth exceeded 1000 frames", "5050")
+}
+
+func TestStackOverflowOperandLimit(t *testing.T) {
+	out, _ := runSola(t, overflowClasses+`
+class main {
+    public static function main(): void {
+        try {
+            R::forever(0);
+        } catch (StackOverflowError $e) {
+            print("caught", $e->getMessage());
+        }
+    }
+}`, func(vm *VM) { vm.SetStackLimits(4096, 0) })
+	expectOutput(t, out, "caught stack overflow: operand stack exceeded 4096 values")
+}
+
+func TestStackOverflowTraceIsCompacted(t *testing.T) {
+	_, vm := runSola(t, overflowClasses+`
+class main {
+    public static function main(): void {
+        R::forever(0);
+    }
+}`, func(vm *VM) { vm.SetStackLimits(0, 1000) })
+	ex := vm.UncaughtException()
+	if ex == nil || ex.Type != "StackOverflowError" {
+		t.Fatalf("expected uncaught StackOverflowError, got %v", ex)
+	}
+	// main 之上的 999 层 forever 合并为一帧
+	frames := ex.StackFrames
+	if len(frames) != 2 || frames[0].FunctionName != "forever" || frames[0].Repeated != 998 {
+		t.Errorf("recursive frames should be deduplicated: %+v", frames)
+	}
+	if !strings.Contains(vm.GetError(), "... repeated 998 more times") {
+		t.Errorf("report should mention repeated frames:\n%s", vm.GetError())
+	}
+
+	// 交替递归无法合并，截断为最内层和最外层的帧
+	_, vm = runSola(t, overflowClasses+`
+class main {
+    public static function main(): void {
+        R::ping(0);
+    }
+}`, func(vm *VM) { vm.SetStackLimits(0, 1000) })
+	frames = vm.UncaughtException().StackFrames
+	if len(frames) != traceHeadFrames+traceTailFrames {
+		t.Fatalf("expected truncated trace of %d frames, got %d", traceHeadFrames+traceTailFrames, len(frames))
+	}
+	if omitted := frames[traceHeadFrames-1].Omitted; omitted != 1000-len(frames) {
+		t.Errorf("expected %d omitted frames, got %d", 1000-len(frames), omitted)
+	}
+	if last := frames[len(frames)-1]; last.FunctionName != "main" {
+		t.Errorf("outermost frame should be kept, got %s", last.FunctionName)
+	}
+}
+
+// ============================================================================
+// 内联缓存
+// ============================================================================
+
+// shapeClasses 测试用的多态类层次
+const shapeClasses = `
+class Shape {
+    public int $sides = 0;
+    public function name(): string { return "shape"; }
+}
+class Triangle extends Shape {
+    public int $sides = 3;
+    public function name(): string { return "triangle"; }
+}
+class Square extends Shape {
+    public int $sides = 4;
+    public function name(): string { return "square"; }
+}
+class Pentagon extends Shape {
+    public int $sides = 5;
+    public function name(): string { return "pentagon"; }
+}
+class Hexagon extends Shape {
+    public int $sides = 6;
+    public function name(): string { return "hexagon"; }
+}
+class R {
+    public static function describe(Shape $s): void {
+        print($s->name(), $s->sides);
+    }
+}
+`
+
+func TestInlineCacheMonomorphic(t *testing.T) {
+	out, vm := runSola(t, `
+class Point {
+    public int $x = 0;
+    public function bump(): int {
+        $this->x = $this->x + 1;
+        return $this->x;
+    }
+}
+class main {
+    public static function main(): void {
+        $p := new Point();
+        $sum := 0;
+        for ($i := 0; $i < 1000; $i++) {
+            $sum = $sum + $p->bump();
+        }
+        print($sum, $p->x);
+    }
+}`)
+	expectOutput(t, out, "500500 1000")
+
+	stats := vm.Stats()
+	// 每个调用点只在首次执行时未命中
+	if stats.InlineCacheMisses > 10 || stats.InlineCacheHits < 3000 {
+		t.Errorf("expected monomorphic sites to hit, got %d hits / %d misses", stats.InlineCacheHits, stats.InlineCacheMisses)
+	}
+	if stats.MegamorphicSites != 0 {
+		t.Errorf("expected no megamorphic sites, got %d", stats.MegamorphicSites)
+	}
+}
+
+func TestInlineCachePolymorphic(t *testing.T) {
+	out, vm := runSola(t, shapeClasses+`
+class main {
+    public static function main(): void {
+        for ($i := 0; $i < 3; $i++) {
+            R::describe(new Triangle());
+            R::describe(new Square());
+        }
+    }
+}`)
+	expectOutput(t, out, "triangle 3", "square 4", "triangle 3", "square 4", "triangle 3", "square 4")
+
+	stats := vm.Stats()
+	// name() 和 sides 两个调用点各缓存两个类，另有两个构造调用点各未命中一次
+	if stats.InlineCacheMisses != 2*2+2 || stats.MegamorphicSites != 0 {
+		t.Errorf("expected 6 misses and no megamorphic sites, got %d misses / %d megamorphic",
+			stats.InlineCacheMisses, stats.MegamorphicSites)
+	}
+}
+
+func TestInlineCacheMegamorphic(t *testing.T) {
+	out, vm := runSola(t, shapeClasses+`
+class main {
+    public static function main(): void {
+        for ($i := 0; $i < 2; $i++) {
+            R::describe(new Shape());
+            R::describe(new Triangle());
+            R::describe(new Square());
+            R::describe(new Pentagon());
+            R::describe(new Hexagon());
+        }
+    }
+}`)
+	shapes := []string{"shape 0", "triangle 3", "square 4", "pentagon 5", "hexagon 6"}
+	expectOutput(t, out, append(shapes, shapes...)...)
+
+	// 第五个类使 describe 的两个调用点转为超多态，此后总是走慢速路径
+	// (main 中五个构造调用点各未命中一次)
+	stats := vm.Stats()
+	if stats.MegamorphicSites != 2 {
+		t.Errorf("expected 2 megamorphic sites, got %d", stats.MegamorphicSites)
+	}
+	if stats.InlineCacheMisses != 2*(4+1+5)+5 {
+		t.Errorf("expected megamorphic lookups to miss, got %d misses", stats.InlineCacheMisses)
+	}
+}
+
+func TestInlineCacheDynamicFields(t *testing.T) {
+	out, _ := runSola(t, `
+class Bag {
+    public int $size = 1;
+}
+class main {
+    public static function main(): void {
+        $b := new Bag();
+        $b->extra = "dynamic";
+        $b->size = 2;
+        print($b->size, $b->extra, $b->missing);
+    }
+}`)
+	expectOutput(t, out, "2 dynamic null")
+}
+
+func TestInlineCacheInvalidatedOnRedefinition(t *testing.T) {
+	source := `
+class Base {
+    public function hello(): string { return "VERSION"; }
+}
+class Child extends Base {}
+class main {
+    public static function main(): void {
+        $c := new Child();
+        print($c->hello());
+    }
+}`
+	out, vm := runSola(t, strings.ReplaceAll(source, "VERSION", "v1"))
+	expectOutput(t, out, "v1")
+
+	// 重新定义父类：Child 仍是同一个类，调用点缓存的是旧父类的方法
+	p := parser.New(strings.ReplaceAll(source, "VERSION", "v2"), "test.sola")
+	c := compiler.New()
+	if _, errs := c.Compile(p.Parse()); len(errs) > 0 {
+		t.Fatalf("compile errors: %v", errs)
+	}
+	base := c.Classes()["Base"]
+	vm.GetClass("Child").Parent = base
+	vm.DefineClass(base)
+
+	out = nil
+	vm.RegisterBuiltin("print", &bytecode.Function{
+		Name:      "print",
+		IsBuiltin: true,
+		BuiltinFn: func(args []bytecode.Value) bytecode.Value {
+			out = append(out, args[0].String())
+			return bytecode.NullValue
+		},
+	})
+	vm.CallStaticMethod(vm.GetClass("main"), "main", nil)
+	expectOutput(t, out, "v2")
+}
+
+// ============================================================================
+// Quickening
+// ============================================================================
+
+// chunkOps 返回类方法字节码中出现的指令名
+func chunkOps(vm *VM, class, method string) string {
+	return vm.GetClass(class).Methods[method][0].Chunk.Disassemble(method)
+}
+
+func TestQuickeningRewritesHotSites(t *testing.T) {
+	out, vm := runSola(t, `
+class Point { public float $x = 0.5; }
+class main {
+    public static function main(): void {
+        $p := new Point();
+        $sum := 0.0;
+        for ($i := 0; $i < 100; $i++) {
+            $sum = $sum + $p->x;
+        }
+        print($sum);
+    }
+}`)
+	expectOutput(t, out, "50")
+
+	stats := vm.Stats()
+	// $p->x, $sum + ...; $i < 100 和 $i++ 已融合为超级指令，不经过通用指令
+	if stats.QuickenedSites != 2 || stats.DeoptimizedSites != 0 {
+		t.Errorf("expected 2 quickened sites and no deoptimization, got %d / %d", stats.QuickenedSites, stats.DeoptimizedSites)
+	}
+	code := chunkOps(vm, "main", "main")
+	for _, op := range []string{"GET_FIELD_MONO", "ADD_FLOAT", "LT_LOCAL_CONST_JUMP", "INC_LOCAL_POST"} {
+		if !strings.Contains(code, op) {
+			t.Errorf("expected %s in quickened code:\n%s", op, code)
+		}
+	}
+}
+
+func TestQuickeningColdSitesStayGeneric(t *testing.T) {
+	_, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        $sum := 0;
+        for ($i := 0; $i < 10; $i++) {
+            $sum = $sum + $i;
+        }
+        print($sum);
+    }
+}`)
+	if n := vm.Stats().QuickenedSites; n != 0 {
+		t.Errorf("sites below the threshold should not be quickened, got %d", n)
+	}
+}
+
+func TestQuickeningDeoptimizesOnGuardFailure(t *testing.T) {
+	out, vm := runSola(t, `
+class R {
+    public static function sub(dynamic $a, dynamic $b): dynamic {
+        return $a - $b;
+    }
+}
+class main {
+    public static function main(): void {
+        dynamic $sum = 0;
+        for ($i := 0; $i < 100; $i++) {
+            $sum = R::sub($sum, -1);
+        }
+        print($sum, R::sub(0.75, 0.5), R::sub(5, 3));
+    }
+}`)
+	expectOutput(t, out, "100 0.25 2")
+
+	stats := vm.Stats()
+	if stats.DeoptimizedSites != 1 {
+		t.Errorf("expected the int subtraction to deoptimize once, got %d", stats.DeoptimizedSites)
+	}
+	// 去优化的位置保持通用指令，不再改写
+	if code := chunkOps(vm, "R", "sub"); !strings.Contains(code, "SUB\n") || strings.Contains(code, "SUB_") {
+		t.Errorf("deoptimized site should stay generic:\n%s", code)
+	}
+}
+
+func TestQuickeningFieldGuard(t *testing.T) {
+	out, vm := runSola(t, shapeClasses+`
+class S {
+    public static function sides(Shape $s): int {
+        return $s->sides;
+    }
+}
+class main {
+    public static function main(): void {
+        dynamic $sum = 0;
+        for ($i := 0; $i < 100; $i++) {
+            $sum = $sum + S::sides(new Triangle());
+        }
+        print($sum, S::sides(new Square()), S::sides(new Triangle()));
+    }
+}`)
+	expectOutput(t, out, "300 4 3")
+	if code := chunkOps(vm, "S", "sides"); strings.Contains(code, "GET_FIELD_MONO") {
+		t.Errorf("field access should deoptimize for a second receiver class:\n%s", code)
+	}
+	if vm.Stats().DeoptimizedSites != 1 {
+		t.Errorf("expected one deoptimized site, got %d", vm.Stats().DeoptimizedSites)
+	}
+}
+
+func TestQuickeningListIndex(t *testing.T) {
+	out, vm := runSola(t, `
+class R {
+    public static function list(): dynamic {
+        return int{1, 2, 3};
+    }
+}
+class main {
+    public static function main(): void {
+        $l := R::list();
+        dynamic $sum = 0;
+        for ($i := 0; $i < 99; $i++) {
+            $sum = $sum + $l[$i % 3];
+        }
+        print($sum);
+    }
+}`)
+	expectOutput(t, out, "198")
+	if code := chunkOps(vm, "main", "main"); !strings.Contains(code, "ARRAY_GET_LIST") {
+		t.Errorf("expected list indexing to be quickened:\n%s", code)
+	}
+}
+
+func TestQuickeningDisabled(t *testing.T) {
+	out, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        $sum := 0;
+        for ($i := 0; $i < 1000; $i++) {
+            $sum = $sum + $i;
+        }
+        print($sum);
+    }
+}`, func(vm *VM) { vm.SetQuickening(false) })
+	expectOutput(t, out, "499500")
+	if n := vm.Stats().QuickenedSites; n != 0 {
+		t.Errorf("quickening disabled, got %d quickened sites", n)
+	}
+}
+
+// ============================================================================
+// 超级指令
+// ============================================================================
+
+func TestSuperinstructionsFused(t *testing.T) {
+	out, vm := runSola(t, `
+class Point { public int $x = 3; }
+class main {
+    public static function main(): void {
+        $p := new Point();
+        $sum := 0;
+        $n := 0;
+        $fields := 0;
+        for ($i := 0; $i < 100; $i++) {
+            $sum = $sum + $i;
+            $n = $n + 1;
+            $n += 2;
+            $fields = $fields + $p->x;
+        }
+        print($sum, $n, $fields);
+    }
+}`, func(vm *VM) {
+		// 执行前验证：运行时 quickening 会改写序列内部的指令
+		if err := bytecode.VerifyChunk(vm.GetClass("main").Methods["main"][0].Chunk); err != nil {
+			t.Errorf("fused code should verify: %v", err)
+		}
+	})
+	expectOutput(t, out, "4950 300 300")
+
+	code := chunkOps(vm, "main", "main")
+	for _, op := range []string{"ADD_LOCALS", "LT_LOCAL_CONST_JUMP", "GET_LOCAL_FIELD", "INC_LOCAL ", "INC_LOCAL_POST"} {
+		if !strings.Contains(code, op) {
+			t.Errorf("expected %s in compiled code:\n%s", op, code)
+		}
+	}
+}
+
+func TestSuperinstructionGuardFallback(t *testing.T) {
+	out, _ := runSola(t, `
+class main {
+    public static function main(): void {
+        dynamic $f = 0.5;
+        dynamic $g = 0.25;
+        dynamic $s = "a";
+        dynamic $t = "b";
+        while ($f < 3) {
+            $f++;
+            $f += 1;
+        }
+        print($f, $f + $g, $s + $t);
+    }
+}`)
+	expectOutput(t, out, "4.5 4.75 ab")
+}
+
+func TestSuperinstructionJumpIntoSequence(t *testing.T) {
+	// 三元表达式的 then 分支跳到 LOAD_LOCAL $c，它位于 else 分支开始的 ADD_LOCALS 序列内部
+	out, vm := runSola(t, `
+class R {
+    public static function pick(bool $flag, int $a, int $b, int $c): int {
+        return ($flag ? $a : $b) + $c;
+    }
+}
+class main {
+    public static function main(): void {
+        print(R::pick(true, 1, 10, 100), R::pick(false, 1, 10, 100));
+    }
+}`)
+	expectOutput(t, out, "101 110")
+	if code := chunkOps(vm, "R", "pick"); !strings.Contains(code, "ADD_LOCALS") {
+		t.Errorf("expected the else branch to be fused:\n%s", code)
+	}
+}
+
+func TestVerifierRejectsMismatchedSuperinstruction(t *testing.T) {
+	chunk := bytecode.NewChunk()
+	chunk.WriteOp(bytecode.OpAddLocals, 1)
+	chunk.WriteU16(0, 1)
+	chunk.WriteOp(bytecode.OpLoadLocal, 1)
+	chunk.WriteU16(1, 1)
+	chunk.WriteOp(bytecode.OpSub, 1)
+	chunk.WriteOp(bytecode.OpReturn, 1)
+	if err := bytecode.VerifyChunk(chunk); err == nil {
+		t.Error("expected ADD_LOCALS followed by SUB to be rejected")
+	}
+	chunk.Code[6] = byte(bytecode.OpAdd)
+	if err := bytecode.VerifyChunk(chunk); err != nil {
+		t.Errorf("expected well-formed ADD_LOCALS to verify: %v", err)
+	}
+}
+
+// ============================================================================
+// 执行限制测试
+// ============================================================================
+
+// limited 返回设置执行限制的 runSola 配置函数
+func limited(l Limits) func(*VM) {
+	return func(vm *VM) { vm.SetLimits(l) }
+}
+
+// expectLimit 检查执行因指定的限制而终止
+func expectLimit(t *testing.T, vm *VM, kind LimitKind) *LimitError {
+	t.Helper()
+	e := vm.LimitExceeded()
+	if e == nil {
+		t.Fatalf("expected %s to terminate execution, error: %q", kind, vm.GetError())
+	}
+	if e.Kind != kind {
+		t.Fatalf("terminated by %s, want %s: %s", e.Kind, kind, e.Message)
+	}
+	if !vm.HasError() || vm.UncaughtException() != nil {
+		t.Fatalf("limit should end execution as an uncatchable error")
+	}
+	return e
+}
+
+func TestInstructionLimit(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class main {
+    public static function main(): void {
+        print("start");
+        try {
+            int $i = 0;
+            while (true) {
+                $i++;
+            }
+        } catch (Exception $e) {
+            print("caught");
+        } finally {
+            print("finally");
+        }
+    }
+}`, limited(Limits{MaxInstructions: 10000}))
+	e := expectLimit(t, vm, LimitInstructions)
+	if len(out) != 2 || out[0] != "start" || !strings.Contains(out[1], "instruction limit of 10000 exceeded") {
+		t.Errorf("unexpected output: %q", out)
+	}
+	if len(e.Stack) == 0 || e.Stack[0].FunctionName != "main" {
+		t.Errorf("expected the stack to point into main, got %+v", e.Stack)
+	}
+	if n := vm.Stats().InstructionsExecuted; n < 10000 || n > 10100 {
+		t.Errorf("executed %d instructions, want just over the limit", n)
+	}
+}
+
+func TestInstructionLimitRecursion(t *testing.T) {
+	// 没有循环的递归在压入调用帧时检查
+	_, vm := runSola(t, `
+class R {
+    public static function down(int $n): int {
+        if ($n == 0) { return 0; }
+        return R::down($n - 1) + 1;
+    }
+}
+class main {
+    public static function main(): void {
+        print(R::down(5000));
+    }
+}`, limited(Limits{MaxInstructions: 1000}))
+	expectLimit(t, vm, LimitInstructions)
+}
+
+func TestLimitsApplyPerRun(t *testing.T) {
+	out, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        int $sum = 0;
+        for (int $i = 0; $i < 100; $i++) {
+            $sum += $i;
+        }
+        print($sum);
+    }
+}`, limited(Limits{MaxInstructions: 2000}))
+	expectOutput(t, out, "4950")
+	for i := 0; i < 3; i++ {
+		if _, err := vm.CallStatic(vm.GetClass("main"), "main", nil); err != nil {
+			t.Fatalf("run %d: %v", i+2, err)
+		}
+	}
+	if n := vm.Stats().InstructionsExecuted; n <= 2000 {
+		t.Errorf("expected the runs together to exceed the per-run limit, executed %d", n)
+	}
+}
+
+func TestTimeoutLimit(t *testing.T) {
+	start := time.Now()
+	_, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        while (true) {
+        }
+    }
+}`, limited(Limits{Timeout: 20 * time.Millisecond}))
+	e := expectLimit(t, vm, LimitDeadline)
+	if !errors.Is(e, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap context.DeadlineExceeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("termination took %v", elapsed)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, vm := runSola(t, `
+class main {
+    public static function spin(int $n): int {
+        return $n + 1;
+    }
+    public static function main(): void {
+        int $n = 0;
+        while (true) {
+            $n = main::spin($n);
+        }
+    }
+}`, limited(Limits{Context: ctx}))
+	e := expectLimit(t, vm, LimitCanceled)
+	if !errors.Is(e, context.Canceled) {
+		t.Errorf("expected the error to wrap context.Canceled")
+	}
+}
+
+func TestDeadlineInterruptsCoroutineWait(t *testing.T) {
+	start := time.Now()
+	_, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        Coroutine::delay(60000)->await();
+        print("woke");
+    }
+}`, limited(Limits{Timeout: 20 * time.Millisecond}))
+	expectLimit(t, vm, LimitDeadline)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("waiting for the timer took %v", elapsed)
+	}
+}
+
+// ============================================================================
+// 确定性调度测试
+// ============================================================================
+
+const interleavedWorkers = `
+class W {
+    public static function worker(string $name): int {
+        for (int $i = 0; $i < 3; $i++) {
+            print($name, $i);
+            Coroutine::yield();
+        }
+        return 3;
+    }
+}
+class main {
+    public static function main(): void {
+        Coroutine<int> $a = Coroutine::spawn((): int => W::worker("a"));
+        Coroutine<int> $b = Coroutine::spawn((): int => W::worker("b"));
+        Coroutine<int> $c = Coroutine::spawn((): int => W::worker("c"));
+        $a->await();
+        $b->await();
+        $c->await();
+        print("done");
+    }
+}`
+
+// seededSchedule 就绪协程按种子决定的顺序运行
+func seededSchedule(seed int64) func(*VM) {
+	return func(vm *VM) {
+		r := rand.New(rand.NewSource(seed))
+		vm.SetRandom(r.Intn, true)
+	}
+}
+
+func TestSeededScheduleIsReproducible(t *testing.T) {
+	first, _ := runSola(t, interleavedWorkers, seededSchedule(7))
+	second, _ := runSola(t, interleavedWorkers, seededSchedule(7))
+	if strings.Join(first, "|") != strings.Join(second, "|") {
+		t.Errorf("same seed produced different schedules:\n%v\n%v", first, second)
+	}
+	if len(first) != 10 || first[9] != "done" {
+		t.Errorf("unexpected output %v", first)
+	}
+
+	fifo, _ := runSola(t, interleavedWorkers)
+	for seed := int64(1); seed <= 20; seed++ {
+		out, _ := runSola(t, interleavedWorkers, seededSchedule(seed))
+		if strings.Join(out, "|") != strings.Join(fifo, "|") {
+			return
+		}
+	}
+	t.Errorf("no seed changed the first-in first-out order %v", fifo)
+}
+
+func TestVirtualClockIgnoresDeadline(t *testing.T) {
+	now := time.Unix(0, 0)
+	start := time.Now()
+	out, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        Coroutine::delay(60000)->await();
+        print("woke");
+    }
+}`, limited(Limits{Timeout: time.Second}), func(vm *VM) {
+		vm.SetClock(func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }, true)
+	})
+	expectOutput(t, out, "woke")
+	if vm.LimitExceeded() != nil {
+		t.Errorf("virtual sleep hit the deadline: %v", vm.LimitExceeded())
+	}
+	if got := now.Sub(time.Unix(0, 0)); got < time.Minute {
+		t.Errorf("virtual clock advanced %v, want at least 1m", got)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("virtual sleep took %v of real time", elapsed)
+	}
+}
+
+func TestAllocationLimit(t *testing.T) {
+	out, vm := runSola(t, `
+class Node {
+    public int $v = 0;
+}
+class main {
+    public static function main(): void {
+        for (int $i = 0; $i < 5; $i++) {
+            Node $n = new Node();
+            dynamic $a = [$i];
+        }
+        print("small ok");
+        for (int $i = 0; $i < 1000; $i++) {
+            Node $n = new Node();
+        }
+        print("unreachable");
+    }
+}`, limited(Limits{MaxAllocations: 100}))
+	expectLimit(t, vm, LimitAllocations)
+	if len(out) == 0 || out[0] != "small ok" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+// ============================================================================
+// 内存统计与堆上限测试
+// ============================================================================
+
+func TestMemoryStatsByKind(t *testing.T) {
+	prof := profiler.NewMemoryProfiler(profiler.DefaultConfig())
+	prof.Start()
+	out, vm := runSola(t, `
+class Node {
+    public int $v = 0;
+}
+class main {
+    public static function main(): void {
+        string $s = "";
+        for (int $i = 0; $i < 3; $i++) {
+            Node $n = new Node();
+            int[] $a = new int[] { $i, $i };
+            $s = $s + "x";
+        }
+        dynamic $m = {"k": 1};
+        print($s);
+    }
+}`, func(vm *VM) { vm.AddHook(NewProfilerHook(nil, prof)) })
+	expectOutput(t, out, "xxx")
+
+	stats := vm.MemoryStats()
+	want := map[AllocKind]uint64{AllocObject: 3, AllocArray: 3, AllocString: 3, AllocMap: 1}
+	for kind, n := range want {
+		if got := stats.ByKind[kind].Count; got != n {
+			t.Errorf("%s allocations = %d, want %d", kind, got, n)
+		}
+		if stats.ByKind[kind].Bytes == 0 {
+			t.Errorf("%s allocations have no size estimate", kind)
+		}
+	}
+	if stats.Allocations != 10 || vm.Stats().Allocations != 10 {
+		t.Errorf("total allocations = %d / %d, want 10", stats.Allocations, vm.Stats().Allocations)
+	}
+	if prof.AllocationCount() != 10 || uint64(prof.TotalBytes()) != stats.AllocatedBytes {
+		t.Errorf("profiler recorded %d allocations of %d bytes, want 10 of %d",
+			prof.AllocationCount(), prof.TotalBytes(), stats.AllocatedBytes)
+	}
+	if stats.AllocatedBytes == 0 || stats.HeapSize != stats.AllocatedBytes {
+		t.Errorf("heap size %d should equal allocated bytes %d before any collection", stats.HeapSize, stats.AllocatedBytes)
+	}
+}
+
+func TestCollectFreesUnreachable(t *testing.T) {
+	out, vm := runSola(t, `
+class Node {
+    public int $v = 0;
+    public dynamic $next = null;
+}
+class main {
+    public static dynamic $kept = null;
+    public static function main(): void {
+        for (int $i = 0; $i < 2000; $i++) {
+            Node $n = new Node();
+        }
+        main::$kept = new Node();
+        print("done");
+    }
+}`, func(vm *VM) {
+		vm.SetGCThreshold(4096)
+		vm.SetHeapLimit(8192)
+	})
+	expectOutput(t, out, "done")
+
+	stats := vm.MemoryStats()
+	if stats.Collections == 0 || stats.FreedBytes == 0 {
+		t.Fatalf("expected automatic collections to free garbage, got %+v", stats)
+	}
+	if stats.HeapSize > 8192 || stats.AllocatedBytes < 2000*uint64(sizeOfObject(&bytecode.Object{Slots: make([]bytecode.Value, 2)})) {
+		t.Errorf("unexpected heap accounting: %+v", stats)
+	}
+
+	freed := vm.Collect()
+	after := vm.MemoryStats()
+	if after.LiveBytes == 0 || after.LiveBytes > 1024 {
+		t.Errorf("only the kept node should be live, got %d bytes (freed %d)", after.LiveBytes, freed)
+	}
+}
+
+func TestOutOfMemoryIsCatchable(t *testing.T) {
+	out, vm := runSola(t, exceptionClasses+`
+class OutOfMemoryError extends Exception {}
+class main {
+    public static function main(): void {
+        dynamic $keep = [];
+        string $s = "item";
+        try {
+            for (int $i = 0; $i < 100000; $i++) {
+                $keep->push($s + $s);
+            }
+            print("unreachable");
+        } catch (OutOfMemoryError $e) {
+            print("caught", $e->getMessage());
+        }
+        $keep = null;
+        dynamic $again = [1, 2, 3];
+        print("recovered", $again[2]);
+    }
+}`, func(vm *VM) { vm.SetHeapLimit(64 << 10) })
+	if len(out) != 2 || !strings.HasPrefix(out[0], "caught out of memory: heap size ") ||
+		!strings.HasSuffix(out[0], "exceeds limit of 65536 bytes") || out[1] != "recovered 3" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if live := vm.MemoryStats().LiveBytes; live > 64<<10 {
+		t.Errorf("live bytes %d exceed the limit", live)
+	}
+}
+
+func TestResetClearsMemoryStats(t *testing.T) {
+	_, vm := runSola(t, `
+class main {
+    public static function main(): void {
+        dynamic $a = [1, 2, 3];
+    }
+}`, func(vm *VM) { vm.SetHeapLimit(1 << 20) })
+	if vm.MemoryStats().Allocations == 0 {
+		t.Fatal("expected allocations to be recorded")
+	}
+	vm.Reset()
+	stats := vm.MemoryStats()
+	if stats.Allocations != 0 || stats.HeapSize != 0 || stats.HeapLimit != 1<<20 {
+		t.Errorf("Reset should clear statistics and keep the heap limit, got %+v", stats)
+	}
+}
+
+// ============================================================================
+// 析构函数与 using 语句测试
+// ============================================================================
+
+const closeableClasses = `
+interface AutoCloseable {
+    public function close(): bool;
+}
+class Res implements AutoCloseable {
+    public string $name;
+    public function __construct(string $name) { $this->name = $name; print("open", $name); }
+    public function close(): bool { print("close", $this->name); return true; }
+    public function __destruct(): void { print("destruct", $this->name); }
+}
+`
+
+func TestUnsetCallsDestructorOnce(t *testing.T) {
+	out, _ := runSola(t, closeableClasses+`
+class Holder {
+    public Res $res;
+    public function __construct() { $this->res = new Res("field"); }
+    public function release(): void { unset($this->res); }
+}
+class main {
+    public static function main(): void {
+        Res $a = new Res("a");
+        Res $alias = $a;
+        unset($a);
+        print($a == null);
+        unset($alias);
+        $h := new Holder();
+        $h->release();
+        print($h->res == null);
+        unset(42);
+    }
+}`)
+	expectOutput(t, out,
+		"open a", "destruct a", "true",
+		"open field", "destruct field", "true")
+}
+
+func TestUsingClosesInReverseOrder(t *testing.T) {
+	out, _ := runSola(t, closeableClasses+`
+class main {
+    public static function work(): int {
+        using (Res $a = new Res("a"), $b := new Res("b")) {
+            print("body");
+            return 7;
+        }
+    }
+    public static function main(): void {
+        print(main::work());
+    }
+}`)
+	expectOutput(t, out, "open a", "open b", "body", "close b", "close a", "7")
+}
+
+func TestUsingClosesOnException(t *testing.T) {
+	out, _ := runSola(t, exceptionClasses+closeableClasses+`
+class main {
+    public static function fail(): Res {
+        throw new Exception("init failed");
+    }
+    public static function main(): void {
+        try {
+            using (Res $a = new Res("a")) {
+                throw new Exception("boom");
+            }
+        } catch (Exception $e) {
+            print("caught", $e->getMessage());
+        }
+        try {
+            using (Res $b = new Res("b"), Res $c = main::fail()) {
+                print("unreachable");
+            }
+        } catch (Exception $e) {
+            print("caught", $e->getMessage());
+        }
+    }
+}`)
+	expectOutput(t, out,
+		"open a", "close a", "caught boom",
+		"open b", "close b", "caught init failed")
+}
+
+func TestUsingRejectsResourceWithoutAutoCloseable(t *testing.T) {
+	tests := []struct {
+		name string
+		decl string
+	}{
+		{"primitive", "int $n = 1"},
+		{"close without interface", "Handle $h = new Handle()"},
+		{"inferred", "$h := new Handle()"},
+		{"unknown class", "Missing $m = null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(closeableClasses+`
+class Handle {
+    public function close(): bool { return true; }
+}
+class main {
+    public static function main(): void {
+        using (`+tt.decl+`) {}
+    }
+}`, "test.sola")
+			file := p.Parse()
+			if p.HasErrors() {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+			_, errs := compiler.New().Compile(file)
+			found := false
+			for _, err := range errs {
+				found = found || strings.Contains(err.Error(), "AutoCloseable")
+			}
+			if !found {
+				t.Fatalf("expected a using resource error, got %v", errs)
+			}
+		})
+	}
+}
+
+// Closer 继承 AutoCloseable，实现 Closer 的子类同样可以用于 using
+func TestUsingAcceptsInheritedAutoCloseable(t *testing.T) {
+	out, _ := runSola(t, closeableClasses+`
+interface Closer extends AutoCloseable {
+    public function flush(): void;
+}
+class Base implements Closer {
+    public function flush(): void {}
+    public function close(): bool { print("close base"); return true; }
+}
+class Derived extends Base {
+}
+class main {
+    public static function main(): void {
+        using (Derived $d = new Derived(), $r := new Res("r")) {
+            print("body");
+        }
+    }
+}`)
+	expectOutput(t, out, "open r", "body", "close r", "close base")
+}
+
+// ============================================================================
+// 执行钩子测试
+// ============================================================================
+
+// traceHook 以文本记录收到的事件
+type traceHook struct {
+	BaseHook
+	events HookEvent
+	log    []string
+}
+
+func (h *traceHook) Events() HookEvent { return h.events }
+
+func (h *traceHook) OnLine(fn *bytecode.Function, line int) {
+	h.log = append(h.log, fmt.Sprintf("line %s %d", FunctionName(fn), line))
+}
+func (h *traceHook) OnEnter(fn *bytecode.Function) {
+	h.log = append(h.log, "enter "+FunctionName(fn))
+}
+func (h *traceHook) OnExit(fn *bytecode.Function) {
+	h.log = append(h.log, "exit "+FunctionName(fn))
+}
+func (h *traceHook) OnThrow(ex *bytecode.Exception) {
+	h.log = append(h.log, "throw "+ex.Message)
+}
+func (h *traceHook) OnCoroutineSwitch(from, to int64) {
+	h.log = append(h.log, fmt.Sprintf("switch %d %d", from, to))
+}
+
+func hooked(h Hook) func(*VM) {
+	return func(vm *VM) { vm.AddHook(h) }
+}
+
+func TestLineHook(t *testing.T) {
+	h := &traceHook{events: HookLine}
+	runSola(t, `
+class main {
+    public static function twice(int $n): int {
+        return $n * 2;
+    }
+    public static function main(): void {
+        int $sum = 0;
+        for (int $i = 0; $i < 2; $i++) {
+            $sum += main::twice($i);
+        }
+        print($sum);
+    }
+}`, hooked(h))
+	want := []string{
+		"line main.main 7", "line main.main 8",
+		"line main.main 9", "line main.twice 4", "line main.main 8",
+		"line main.main 9", "line main.twice 4", "line main.main 8",
+		"line main.main 11",
+	}
+	if strings.Join(h.log, "\n") != strings.Join(want, "\n") {
+		t.Errorf("line events:\n%s\nwant:\n%s", strings.Join(h.log, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCallHookBalancedThroughExceptions(t *testing.T) {
+	h := &traceHook{events: HookCall | HookException}
+	out, _ := runSola(t, exceptionClasses+`
+class main {
+    public static function inner(int $n): int {
+        if ($n == 0) { throw new Exception("bottom"); }
+        return main::inner($n - 1);
+    }
+    public static function main(): void {
+        try {
+            main::inner(2);
+        } catch (Exception $e) {
+            print($e->getMessage());
+        }
+        int $x = 1 / 0;
+    }
+}`, hooked(h))
+	if len(out) != 2 || out[0] != "bottom" || !strings.Contains(out[1], "DivideByZeroException") {
+		t.Errorf("unexpected output: %v", out)
+	}
+
+	depth := 0
+	var throws []string
+	for _, e := range h.log {
+		switch {
+		case strings.HasPrefix(e, "enter "):
+			depth++
+		case strings.HasPrefix(e, "exit "):
+			depth--
+		case strings.HasPrefix(e, "throw "):
+			throws = append(throws, e)
+		}
+		if depth < 0 {
+			t.Fatalf("exit without enter: %v", h.log)
+		}
+	}
+	if depth != 0 {
+		t.Errorf("unbalanced enter/exit (depth %d): %v", depth, h.log)
+	}
+	if strings.Join(throws, ",") != "throw bottom,throw division by zero" {
+		t.Errorf("throw events: %v", throws)
+	}
+}
+
+func TestCoroutineHooks(t *testing.T) {
+	h := &traceHook{events: HookCall | HookCoroutine}
+	runSola(t, `
+class W {
+    public static function worker(int $n): int {
+        Coroutine::yield();
+        return $n;
+    }
+}
+class main {
+    public static function main(): void {
+        Coroutine<int> $c = Coroutine::spawn((): int => W::worker(1));
+        print($c->await());
+    }
+}`, hooked(h))
+	log := strings.Join(h.log, "\n")
+	for _, want := range []string{"switch 0 1", "switch 1 0", "enter W.worker", "exit W.worker"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("missing %q in events:\n%s", want, log)
+		}
+	}
+}
+
+func TestProfilerHook(t *testing.T) {
+	prof := profiler.NewProfiler()
+	prof.Enable()
+	if err := prof.Start(profiler.ProfileCPU, profiler.ProfileMemory); err != nil {
+		t.Fatal(err)
+	}
+	runSola(t, `
+class main {
+    public static function fib(int $n): int {
+        if ($n < 2) { return $n; }
+        return main::fib($n - 1) + main::fib($n - 2);
+    }
+    public static function main(): void {
+        for (int $i = 0; $i < 3; $i++) {
+            $a := new main();
+        }
+        print(main::fib(10));
+    }
+}`, hooked(NewProfilerHook(prof, prof)))
+	prof.Stop()
+
+	calls := map[string]int64{}
+	for _, f := range prof.GetCPUProfile().Functions {
+		calls[f.Name] = f.CallCount
+	}
+	if calls["main.fib"] != 177 || calls["main.main"] != 1 {
+		t.Errorf("call counts: %v", calls)
+	}
+	if n := prof.GetMemoryProfile().TotalAllocations; n < 3 {
+		t.Errorf("memory profiler saw %d allocations, want at least 3", n)
+	}
+}
@@ -0,0 +1,296 @@
+package sola
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+)
+
+// ============================================================================
+// Go 值与 Sola 值的转换
+// ============================================================================
+//
+//   Go                                  Sola
+//   nil                                 null
+//   bool                                bool
+//   int*, uint*                         int
+//   float32, float64                    float
+//   string                              string
+//   []byte                              bytes
+//   其他切片和数组                       动态数组
+//   map                                 map
+//   已注册 Go 支持类的实例 (见 RegisterClass)  该类的对象
+//   Value                               原样传递
+//
+// Sola 到 Go 的方向由 FromValue 按值的运行时类型转换，Go 函数的参数则按形参类型转换。
+
+// Value Sola 值
+type Value = bytecode.Value
+
+var (
+	valueType = reflect.TypeOf(bytecode.Value{})
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToValue 把 Go 值转为 Sola 值
+func (e *Engine) ToValue(x any) (Value, error) {
+	if x == nil {
+		return bytecode.NullValue, nil
+	}
+	return e.toValue(reflect.ValueOf(x))
+}
+
+func (e *Engine) toValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return bytecode.NullValue, nil
+	}
+	if rv.Type() == valueType {
+		return rv.Interface().(Value), nil
+	}
+	if gc, ok := e.goClasses[rv.Type()]; ok {
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return bytecode.NullValue, nil
+		}
+		obj := bytecode.NewObjectInstance(gc.class)
+		obj.Native = rv.Interface()
+		return bytecode.NewObject(obj), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return bytecode.NewBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return bytecode.NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return bytecode.NewInt(int64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return bytecode.NewFloat(rv.Float()), nil
+	case reflect.String:
+		return bytecode.NewString(rv.String()), nil
+	case reflect.Interface, reflect.Pointer:
+		if rv.IsNil() {
+			return bytecode.NullValue, nil
+		}
+		return e.toValue(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return bytecode.NullValue, nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return bytecode.NewBytes(b), nil
+		}
+		arr := make([]Value, rv.Len())
+		for i := range arr {
+			v, err := e.toValue(rv.Index(i))
+			if err != nil {
+				return bytecode.NullValue, err
+			}
+			arr[i] = v
+		}
+		return bytecode.NewArray(arr), nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return bytecode.NullValue, nil
+		}
+		m := make(map[bytecode.Key]Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := e.toValue(iter.Key())
+			if err != nil {
+				return bytecode.NullValue, err
+			}
+			v, err := e.toValue(iter.Value())
+			if err != nil {
+				return bytecode.NullValue, err
+			}
+			m[bytecode.MapKey(k)] = v
+		}
+		return bytecode.NewMap(m), nil
+	}
+	return bytecode.NullValue, fmt.Errorf("sola: cannot convert Go value of type %s", rv.Type())
+}
+
+// FromValue 按运行时类型把 Sola 值转为 Go 值
+//
+// null 为 nil，int 为 int64，float 为 float64，动态数组为 []any，
+// 键全部是字符串的 map 为 map[string]any，其他 map 为 map[any]any，
+// Go 支持类的对象为注册时的 Go 值，其他对象和值保持为 Value
+func FromValue(v Value) any {
+	switch v.Type() {
+	case bytecode.ValNull:
+		return nil
+	case bytecode.ValBool:
+		return v.AsBool()
+	case bytecode.ValInt:
+		return v.AsInt()
+	case bytecode.ValFloat:
+		return v.AsFloat()
+	case bytecode.ValString:
+		return v.AsString()
+	case bytecode.ValBytes:
+		return v.AsBytes()
+	case bytecode.ValArray:
+		arr := v.AsArray()
+		out := make([]any, len(arr))
+		for i, elem := range arr {
+			out[i] = FromValue(elem)
+		}
+		return out
+	case bytecode.ValMap:
+		m := v.AsMap()
+		strKeys := make(map[string]any, len(m))
+		for k, elem := range m {
+			key := k.Value()
+			if !key.IsString() {
+				out := make(map[any]any, len(m))
+				for k, elem := range m {
+					out[FromValue(k.Value())] = FromValue(elem)
+				}
+				return out
+			}
+			strKeys[key.AsString()] = FromValue(elem)
+		}
+		return strKeys
+	case bytecode.ValObject:
+		if obj := v.AsObject(); obj != nil && obj.Native != nil {
+			return obj.Native
+		}
+	}
+	return v
+}
+
+// fromValue 按 Go 类型 t 转换 Sola 值 (Go 函数的参数)
+func (e *Engine) fromValue(v Value, t reflect.Type) (reflect.Value, error) {
+	if t == valueType {
+		return reflect.ValueOf(v), nil
+	}
+	if _, ok := e.goClasses[t]; ok {
+		if v.IsNull() {
+			return reflect.Zero(t), nil
+		}
+		if obj := v.AsObject(); v.IsObject() && obj.Native != nil {
+			if nv := reflect.ValueOf(obj.Native); nv.Type().AssignableTo(t) {
+				return nv, nil
+			}
+		}
+		return reflect.Value{}, typeMismatch(v, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Type() == bytecode.ValBool {
+			return reflect.ValueOf(v.AsBool()).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == bytecode.ValInt {
+			return reflect.ValueOf(v.AsInt()).Convert(t), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Type() == bytecode.ValInt && v.AsInt() >= 0 {
+			return reflect.ValueOf(v.AsInt()).Convert(t), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.Type() == bytecode.ValInt || v.Type() == bytecode.ValFloat {
+			return reflect.ValueOf(v.AsFloat()).Convert(t), nil
+		}
+	case reflect.String:
+		if v.IsString() {
+			return reflect.ValueOf(v.AsString()).Convert(t), nil
+		}
+	case reflect.Interface:
+		x := FromValue(v)
+		if x == nil {
+			return reflect.Zero(t), nil
+		}
+		if xv := reflect.ValueOf(x); xv.Type().AssignableTo(t) {
+			return xv, nil
+		}
+	case reflect.Slice:
+		if v.IsNull() {
+			return reflect.Zero(t), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && v.Type() == bytecode.ValBytes {
+			return reflect.ValueOf(v.AsBytes()).Convert(t), nil
+		}
+		if v.Type() == bytecode.ValArray {
+			arr := v.AsArray()
+			out := reflect.MakeSlice(t, len(arr), len(arr))
+			for i, elem := range arr {
+				ev, err := e.fromValue(elem, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				out.Index(i).Set(ev)
+			}
+			return out, nil
+		}
+	case reflect.Map:
+		if v.IsNull() {
+			return reflect.Zero(t), nil
+		}
+		if v.Type() == bytecode.ValMap {
+			m := v.AsMap()
+			out := reflect.MakeMapWithSize(t, len(m))
+			for k, elem := range m {
+				kv, err := e.fromValue(k.Value(), t.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				ev, err := e.fromValue(elem, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				out.SetMapIndex(kv, ev)
+			}
+			return out, nil
+		}
+	}
+	return reflect.Value{}, typeMismatch(v, t)
+}
+
+// valueTypeNames 错误消息中使用的 Sola 值类型名
+var valueTypeNames = map[bytecode.ValueType]string{
+	bytecode.ValNull:    "null",
+	bytecode.ValBool:    "bool",
+	bytecode.ValInt:     "int",
+	bytecode.ValFloat:   "float",
+	bytecode.ValString:  "string",
+	bytecode.ValArray:   "array",
+	bytecode.ValBytes:   "bytes",
+	bytecode.ValMap:     "map",
+	bytecode.ValObject:  "object",
+	bytecode.ValFunc:    "function",
+	bytecode.ValClosure: "closure",
+}
+
+// typeMismatch 参数类型不匹配的错误
+func typeMismatch(v Value, t reflect.Type) error {
+	name, ok := valueTypeNames[v.Type()]
+	if !ok {
+		name = "value"
+	}
+	return fmt.Errorf("cannot use %s value as Go %s", name, t)
+}
+
+// solaType 返回 Go 类型在符号表中对应的 Sola 类型名
+// 没有精确对应的类型使用 dynamic，编译器不对其做类型检查
+func (e *Engine) solaType(t reflect.Type) string {
+	if gc, ok := e.goClasses[t]; ok {
+		return gc.class.Name
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	}
+	return "dynamic"
+}
@@ -0,0 +1,303 @@
+// Package sola 在 Go 程序中嵌入 Sola 运行时
+//
+// Engine 编译并缓存脚本、求值表达式、以 Go 参数调用 Sola 函数和方法，
+// 并可以把 Go 函数和 Go 类型注册为 Sola 的内置函数和类：
+//
+//	engine := sola.New()
+//	engine.RegisterFunc("greet", func(name string) string { return "hello " + name })
+//	script, err := engine.Compile("Main.sola", source)
+//	...
+//	result, err := engine.Call("Main", "run", 42)
+//
+// 所有方法都返回结构化的错误 (*CompileError / *ScriptError)，不向终端输出。
+// Engine 不是并发安全的，同一时刻只能在一个 goroutine 中使用；
+// 并发执行同一程序时从 Pool 借出 Isolate。
+package sola
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/runtime"
+	"github.com/tangzhangming/nova/internal/vm"
+)
+
+// ============================================================================
+// Engine
+// ============================================================================
+
+// Options 引擎选项
+type Options struct {
+	// LibDir 标准库目录，为空时使用可执行文件上一级的 src/ 目录
+	LibDir string
+
+	// Limits 每次调用 (Run / Call / CallMethod / CallValue / NewObject / Eval) 的执行限制
+	Limits Limits
+
+	// Sandbox 文件系统、网络、反射和 native_panic 的能力策略，nil 表示不限制
+	// 被拒绝的访问在 Sola 中抛出 SecurityException
+	Sandbox *Sandbox
+
+	// MaxHeapBytes 堆上限 (估算字节数)，0 表示不限制
+	// 超过时在 Sola 中抛出可以被捕获的 OutOfMemoryError
+	MaxHeapBytes uint64
+
+	// Funcs 由 NewPool 注册为 Sola 全局函数的 Go 函数 (名称 -> 函数)，规则同 Engine.RegisterFunc
+	// 池中所有 Isolate 共享这些函数，函数可能在多个 goroutine 中被同时调用
+	Funcs map[string]any
+
+	// Classes 由 NewPool 注册为 Sola 类的 Go 类型 (类名 -> 构造函数)，规则同 Engine.RegisterClass
+	// Engine 在创建后通过 RegisterFunc 和 RegisterClass 注册，不使用 Funcs 和 Classes
+	Classes map[string]any
+}
+
+// Limits 执行限制：指令数、分配次数、执行时间和取消，零值字段表示不限制
+type Limits = vm.Limits
+
+// Sandbox 原生函数的能力策略，未授予的能力一律拒绝
+type Sandbox = runtime.Sandbox
+
+// MemoryStats 内存统计：分配次数、估算字节数和回收情况
+type MemoryStats = vm.MemoryStats
+
+// FSRoot 沙箱中可访问的文件系统根目录及其权限
+type FSRoot = runtime.FSRoot
+
+// Engine 嵌入式 Sola 引擎
+type Engine struct {
+	rt        *runtime.Runtime
+	vm        *vm.VM
+	scripts   map[string]*Script        // 脚本名 -> 已加载的脚本
+	evals     *evalCache                // 最近求值的表达式 -> 编译后的函数
+	goClasses map[reflect.Type]*goClass // Go 类型 -> Go 支持类
+}
+
+// New 创建引擎
+func New() *Engine {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions 创建带选项的引擎
+func NewWithOptions(opts Options) *Engine {
+	rt := runtime.NewWithOptions(runtimeOptions(opts))
+	return &Engine{
+		rt:        rt,
+		vm:        rt.VM(),
+		scripts:   make(map[string]*Script),
+		evals:     newEvalCache(evalCacheSize),
+		goClasses: make(map[reflect.Type]*goClass),
+	}
+}
+
+// runtimeOptions 转换为运行时选项
+func runtimeOptions(opts Options) runtime.Options {
+	return runtime.Options{
+		LibDir:       opts.LibDir,
+		Limits:       opts.Limits,
+		Sandbox:      opts.Sandbox,
+		MaxHeapBytes: opts.MaxHeapBytes,
+	}
+}
+
+// SetLimits 设置之后每次调用的执行限制
+// 在 Go 回调中发起的嵌套调用计入外层调用的限制
+func (e *Engine) SetLimits(l Limits) {
+	e.vm.SetLimits(l)
+}
+
+// MemoryStats 返回引擎中脚本的内存统计
+func (e *Engine) MemoryStats() MemoryStats {
+	return e.vm.MemoryStats()
+}
+
+// ============================================================================
+// 脚本
+// ============================================================================
+
+// Script 已编译并加载到引擎中的脚本
+type Script struct {
+	engine  *Engine
+	name    string
+	hash    [sha256.Size]byte
+	classes map[string]*bytecode.Class
+}
+
+// Compile 编译脚本并加载其中的类及其 use 的依赖
+// 同名且源码未变的脚本直接返回缓存；源码变化时重新编译，脚本中的类被新定义替换
+func (e *Engine) Compile(name, source string) (*Script, error) {
+	hash := sha256.Sum256([]byte(source))
+	if s, ok := e.scripts[name]; ok && s.hash == hash {
+		return s, nil
+	}
+	classes, err := e.rt.Load(source, name)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{engine: e, name: name, hash: hash, classes: classes}
+	e.scripts[name] = s
+	return s, nil
+}
+
+// Name 返回脚本名
+func (s *Script) Name() string {
+	return s.name
+}
+
+// Classes 返回脚本中定义的类名
+func (s *Script) Classes() []string {
+	names := make([]string, 0, len(s.classes))
+	for name := range s.classes {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Run 执行脚本的入口：与脚本文件同名的类的静态 main() 方法
+func (s *Script) Run() error {
+	base := filepath.Base(s.name)
+	entry := strings.TrimSuffix(base, filepath.Ext(base))
+	class, ok := s.classes[entry]
+	if !ok {
+		return fmt.Errorf("sola: script %s has no class %s", s.name, entry)
+	}
+	_, err := s.engine.vm.CallStatic(class, "main", nil)
+	return scriptError(err)
+}
+
+// ============================================================================
+// 调用
+// ============================================================================
+
+// Call 以 Go 参数调用类的静态方法
+func (e *Engine) Call(className, method string, args ...any) (Value, error) {
+	class, err := e.class(className)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	values, err := e.values(args)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	result, err := e.vm.CallStatic(class, method, values)
+	return result, scriptError(err)
+}
+
+// CallMethod 以 Go 参数调用对象的实例方法
+func (e *Engine) CallMethod(obj Value, method string, args ...any) (Value, error) {
+	if !obj.IsObject() {
+		return bytecode.NullValue, fmt.Errorf("sola: CallMethod %s: receiver is not an object", method)
+	}
+	values, err := e.values(args)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	result, err := e.vm.CallMethod(obj, method, values)
+	return result, scriptError(err)
+}
+
+// CallValue 以 Go 参数调用 Sola 函数或闭包 (例如脚本传给 Go 函数的回调)
+func (e *Engine) CallValue(fn Value, args ...any) (Value, error) {
+	values, err := e.values(args)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	result, err := e.vm.CallValue(fn, values)
+	return result, scriptError(err)
+}
+
+// NewObject 创建类实例并以 Go 参数执行构造函数
+func (e *Engine) NewObject(className string, args ...any) (Value, error) {
+	class, err := e.class(className)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	values, err := e.values(args)
+	if err != nil {
+		return bytecode.NullValue, err
+	}
+	result, err := e.vm.NewInstance(class, values)
+	return result, scriptError(err)
+}
+
+// Eval 求值表达式
+// 表达式可以引用已加载的类和已注册的函数；表达式编译为不注册到运行时的函数，
+// 最近使用的 evalCacheSize 个表达式的编译结果被缓存
+func (e *Engine) Eval(expr string) (Value, error) {
+	fn, ok := e.evals.get(expr)
+	if !ok {
+		var err error
+		fn, err = e.rt.CompileExpression(expr)
+		if err != nil {
+			return bytecode.NullValue, err
+		}
+		e.evals.put(expr, fn)
+	}
+	result, err := e.vm.Eval(fn, bytecode.NullValue, nil)
+	if err != nil {
+		return bytecode.NullValue, scriptError(err)
+	}
+	return result.AsArray()[0], nil
+}
+
+// evalCacheSize Eval 缓存的表达式个数
+const evalCacheSize = 64
+
+// evalCache 按最近使用淘汰的表达式缓存
+type evalCache struct {
+	size  int
+	order *list.List               // 最近使用的在前
+	items map[string]*list.Element // 表达式 -> order 中的 *evalEntry
+}
+
+type evalEntry struct {
+	expr string
+	fn   *bytecode.Function
+}
+
+func newEvalCache(size int) *evalCache {
+	return &evalCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *evalCache) get(expr string) (*bytecode.Function, bool) {
+	el, ok := c.items[expr]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*evalEntry).fn, true
+}
+
+func (c *evalCache) put(expr string, fn *bytecode.Function) {
+	c.items[expr] = c.order.PushFront(&evalEntry{expr: expr, fn: fn})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*evalEntry).expr)
+	}
+}
+
+// class 查找已加载的类
+func (e *Engine) class(name string) (*bytecode.Class, error) {
+	if class := e.rt.GetClass(name); class != nil {
+		return class, nil
+	}
+	return nil, fmt.Errorf("sola: undefined class %s", name)
+}
+
+// values 把 Go 参数转为 Sola 值
+func (e *Engine) values(args []any) ([]Value, error) {
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		v, err := e.ToValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("sola: argument %d: %w", i+1, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
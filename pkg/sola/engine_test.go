@@ -0,0 +1,740 @@
+package sola
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tangzhangming/nova/internal/vm"
+)
+
+// counter Go 支持类的测试类型
+type counter struct {
+	n int
+}
+
+func newCounter(start int) *counter { return &counter{n: start} }
+
+func (c *counter) Add(delta int) int { c.n += delta; return c.n }
+func (c *counter) Get() int          { return c.n }
+
+func (c *counter) Fail(msg string) error { return errors.New(msg) }
+
+// exceptionClasses 测试用的异常类
+const exceptionClasses = `
+class Exception {
+    public string $message = "";
+    public function __construct(string $message) { $this->message = $message; }
+    public function getMessage(): string { return $this->message; }
+}
+class RuntimeException extends Exception {}
+`
+
+func compile(t *testing.T, e *Engine, name, source string) *Script {
+	t.Helper()
+	s, err := e.Compile(name, source)
+	if err != nil {
+		t.Fatalf("compile %s: %v", name, err)
+	}
+	return s
+}
+
+func TestCallStaticMethod(t *testing.T) {
+	e := New()
+	compile(t, e, "Calc.sola", `
+class Calc {
+    public static function add(int $a, int $b): int {
+        return $a + $b;
+    }
+    public static function greet(string $name, string $greeting = "hello"): string {
+        return $greeting + " " + $name;
+    }
+    public static function count(dynamic $values): int {
+        return $values.length as int;
+    }
+}
+`)
+
+	result, err := e.Call("Calc", "add", 2, 40)
+	if err != nil || result.AsInt() != 42 {
+		t.Fatalf("add = %v, %v", result, err)
+	}
+	result, err = e.Call("Calc", "greet", "sola")
+	if err != nil || result.AsString() != "hello sola" {
+		t.Fatalf("greet = %v, %v", result, err)
+	}
+	result, err = e.Call("Calc", "count", []int{1, 2, 3, 4})
+	if err != nil || FromValue(result) != int64(4) {
+		t.Fatalf("count = %v, %v", result, err)
+	}
+	if _, err := e.Call("Missing", "x"); err == nil {
+		t.Fatal("calling an undefined class succeeded")
+	}
+}
+
+func TestCompileErrorIsStructured(t *testing.T) {
+	e := New()
+	_, err := e.Compile("Broken.sola", `
+class Broken {
+    public static function main(): void {
+        int $x = (1 + ;
+    }
+}
+`)
+	var ce *CompileError
+	if !errors.As(err, &ce) {
+		t.Fatalf("err = %v, want *CompileError", err)
+	}
+	if !ce.Parse || len(ce.Diagnostics) == 0 || ce.Diagnostics[0].Pos.Line != 4 {
+		t.Fatalf("diagnostics = %+v", ce.Diagnostics)
+	}
+}
+
+func TestUncaughtExceptionIsScriptError(t *testing.T) {
+	e := New()
+	compile(t, e, "Thrower.sola", exceptionClasses+`
+class Thrower {
+    public static function fail(string $msg): void {
+        throw new Exception($msg);
+    }
+    public static function ok(): int {
+        return 1;
+    }
+}
+`)
+	_, err := e.Call("Thrower", "fail", "boom")
+	var se *ScriptError
+	if !errors.As(err, &se) {
+		t.Fatalf("err = %v, want *ScriptError", err)
+	}
+	if se.Type != "Exception" || se.Message != "boom" || len(se.Stack) == 0 {
+		t.Fatalf("script error = %+v", se)
+	}
+
+	// 失败的调用不影响后续调用
+	result, err := e.Call("Thrower", "ok")
+	if err != nil || result.AsInt() != 1 {
+		t.Fatalf("ok = %v, %v", result, err)
+	}
+}
+
+func TestEval(t *testing.T) {
+	e := New()
+	compile(t, e, "Consts.sola", `
+class Consts {
+    public static function answer(): int {
+        return 42;
+    }
+}
+`)
+	result, err := e.Eval("Consts::answer() * 2")
+	if err != nil || result.AsInt() != 84 {
+		t.Fatalf("eval = %v, %v", result, err)
+	}
+	result, err = e.Eval(`"a" + "b"`)
+	if err != nil || result.AsString() != "ab" {
+		t.Fatalf("eval = %v, %v", result, err)
+	}
+
+	_, err = e.Eval("1 +")
+	var ce *CompileError
+	if !errors.As(err, &ce) || ce.Diagnostics[0].Pos.Column > 4 {
+		t.Fatalf("eval error = %v", err)
+	}
+
+	// 不同的表达式不注册类，缓存有上限
+	for i := 0; i < evalCacheSize*2; i++ {
+		result, err = e.Eval(fmt.Sprintf("Consts::answer() + %d", i))
+		if err != nil || result.AsInt() != int64(42+i) {
+			t.Fatalf("eval %d = %v, %v", i, result, err)
+		}
+	}
+	if e.rt.GetClass("__Eval1") != nil {
+		t.Fatal("eval registered a class")
+	}
+	if len(e.evals.items) != evalCacheSize || e.evals.order.Len() != evalCacheSize {
+		t.Fatalf("eval cache size = %d", len(e.evals.items))
+	}
+	result, err = e.Eval("Consts::answer() * 2")
+	if err != nil || result.AsInt() != 84 {
+		t.Fatalf("eval after eviction = %v, %v", result, err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	e := New()
+	var logged []string
+	if err := e.RegisterFunc("hostLog", func(parts ...string) { logged = append(logged, strings.Join(parts, ",")) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RegisterFunc("hostDiv", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	compile(t, e, "UseHost.sola", exceptionClasses+`
+class UseHost {
+    public static function run(int $a, int $b): string {
+        hostLog("x", "y");
+        try {
+            return "ok " + (hostDiv($a, $b) as string);
+        } catch (Exception $e) {
+            return "caught " + $e.getMessage();
+        }
+    }
+}
+`)
+	result, err := e.Call("UseHost", "run", 10, 2)
+	if err != nil || result.AsString() != "ok 5" {
+		t.Fatalf("run = %v, %v", result, err)
+	}
+	result, err = e.Call("UseHost", "run", 1, 0)
+	if err != nil || result.AsString() != "caught division by zero" {
+		t.Fatalf("run = %v, %v", result, err)
+	}
+	if !reflect.DeepEqual(logged, []string{"x,y", "x,y"}) {
+		t.Fatalf("logged = %v", logged)
+	}
+}
+
+func TestRegisterClass(t *testing.T) {
+	e := New()
+	if err := e.RegisterClass("Counter", newCounter); err != nil {
+		t.Fatal(err)
+	}
+	compile(t, e, "UseCounter.sola", `
+class UseCounter {
+    public static function run(): int {
+        $c := new Counter(10);
+        $c.add(5);
+        return $c.add(1);
+    }
+    public static function make(): Counter {
+        return new Counter(7);
+    }
+}
+`)
+	result, err := e.Call("UseCounter", "run")
+	if err != nil || result.AsInt() != 16 {
+		t.Fatalf("run = %v, %v", result, err)
+	}
+
+	obj, err := e.Call("UseCounter", "make")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := FromValue(obj).(*counter)
+	if !ok || c.n != 7 {
+		t.Fatalf("make = %#v", FromValue(obj))
+	}
+	if result, err := e.CallMethod(obj, "add", 3); err != nil || result.AsInt() != 10 || c.n != 10 {
+		t.Fatalf("add = %v, %v", result, err)
+	}
+
+	_, err = e.CallMethod(obj, "fail", "bad state")
+	var se *ScriptError
+	if !errors.As(err, &se) || se.Type != "RuntimeException" || se.Message != "bad state" {
+		t.Fatalf("fail = %v", err)
+	}
+}
+
+func TestCallbackIntoSola(t *testing.T) {
+	e := New()
+	if err := e.RegisterFunc("apply", func(fn Value, x int) (Value, error) {
+		return e.CallValue(fn, x)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	compile(t, e, "Callback.sola", exceptionClasses+`
+class Callback {
+    public static function run(): int {
+        return apply(function(int $x): int { return $x * 3; }, 14) as int;
+    }
+    public static function propagate(): string {
+        try {
+            apply(function(int $x): int { throw new Exception("from closure"); }, 1);
+        } catch (Exception $e) {
+            return $e.getMessage();
+        }
+        return "not thrown";
+    }
+}
+`)
+	result, err := e.Call("Callback", "run")
+	if err != nil || result.AsInt() != 42 {
+		t.Fatalf("run = %v, %v", result, err)
+	}
+	result, err = e.Call("Callback", "propagate")
+	if err != nil || result.AsString() != "from closure" {
+		t.Fatalf("propagate = %v, %v", result, err)
+	}
+}
+
+func TestAwaitCoroutineFromEarlierCall(t *testing.T) {
+	e := New()
+	compile(t, e, "T.sola", exceptionClasses+`
+class CancellationException extends Exception {}
+class T {
+    public static function loop(): int {
+        while (true) {
+            Coroutine::yield();
+        }
+        return 0;
+    }
+    public static function start(): Coroutine<int> {
+        return Coroutine::spawn((): int => T::loop());
+    }
+    public static function finish(Coroutine<int> $co): int {
+        Coroutine<int> $other = Coroutine::spawn((): int => 1);
+        return $co->await() + $other->await();
+    }
+}
+`)
+
+	// 调用结束时丢弃的协程视为已取消
+	co, err := e.Call("T", "start")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	_, err = e.Call("T", "finish", co)
+	var se *ScriptError
+	if !errors.As(err, &se) || se.Type != "CancellationException" {
+		t.Fatalf("finish err = %v, want CancellationException", err)
+	}
+
+	// 其他引擎创建的协程不能在这里等待
+	other := New()
+	compile(t, other, "T.sola", exceptionClasses+`
+class InvalidOperationException extends Exception {}
+class T {
+    public static function finish(Coroutine<int> $co): int {
+        return $co->await();
+    }
+}
+`)
+	if co, err = e.Call("T", "start"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	_, err = other.Call("T", "finish", co)
+	if !errors.As(err, &se) || se.Type != "InvalidOperationException" {
+		t.Fatalf("finish on another engine err = %v, want InvalidOperationException", err)
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	e := New()
+	src := `
+class Cached {
+    public static function main(): void {
+    }
+    public static function v(): int {
+        return 1;
+    }
+}
+`
+	a := compile(t, e, "Cached.sola", src)
+	b := compile(t, e, "Cached.sola", src)
+	if a != b {
+		t.Fatal("identical source was recompiled")
+	}
+	if err := a.Run(); err != nil {
+		t.Fatal(err)
+	}
+	compile(t, e, "Cached.sola", strings.Replace(src, "return 1", "return 2", 1))
+	if result, err := e.Call("Cached", "v"); err != nil || result.AsInt() != 2 {
+		t.Fatalf("v = %v, %v", result, err)
+	}
+}
+
+func TestValueConversion(t *testing.T) {
+	e := New()
+	v, err := e.ToValue(map[string]any{"a": []int{1, 2}, "b": "x", "c": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := FromValue(v)
+	want := map[string]any{"a": []any{int64(1), int64(2)}, "b": "x", "c": nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip = %#v", got)
+	}
+	if _, err := e.ToValue(make(chan int)); err == nil {
+		t.Fatal("converting a channel succeeded")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	e := NewWithOptions(Options{Limits: Limits{MaxInstructions: 5000}})
+	if err := e.RegisterFunc("apply", func(fn Value) (Value, error) {
+		return e.CallValue(fn)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	compile(t, e, "Spin.sola", exceptionClasses+`
+class Spin {
+    public static function forever(): void {
+        while (true) {
+        }
+    }
+    public static function nested(): string {
+        try {
+            apply(function(): void { Spin::forever(); });
+        } catch (Exception $e) {
+            return "caught";
+        }
+        return "returned";
+    }
+    public static function quick(): int {
+        return 1;
+    }
+}
+`)
+	for _, method := range []string{"forever", "nested"} {
+		_, err := e.Call("Spin", method)
+		var le *LimitError
+		if !errors.As(err, &le) || le.Kind != LimitInstructions {
+			t.Fatalf("%s: err = %v, want instruction limit", method, err)
+		}
+	}
+	// 限制按调用计数，终止后引擎仍可使用
+	if result, err := e.Call("Spin", "quick"); err != nil || result.AsInt() != 1 {
+		t.Fatalf("quick = %v, %v", result, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.SetLimits(Limits{Context: ctx})
+	if _, err := e.Call("Spin", "forever"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled call: err = %v", err)
+	}
+}
+
+func TestMaxHeapBytes(t *testing.T) {
+	e := NewWithOptions(Options{MaxHeapBytes: 32 << 10})
+	compile(t, e, "Hog.sola", exceptionClasses+`
+class OutOfMemoryError extends Exception {}
+class Hog {
+    public static function fill(): string {
+        dynamic $keep = [];
+        try {
+            while (true) {
+                $keep->push([1, 2, 3, 4]);
+            }
+        } catch (OutOfMemoryError $e) {
+            return "caught";
+        }
+        return "returned";
+    }
+}
+`)
+	if result, err := e.Call("Hog", "fill"); err != nil || result.AsString() != "caught" {
+		t.Fatalf("fill = %v, %v", result, err)
+	}
+	stats := e.MemoryStats()
+	if stats.HeapLimit != 32<<10 || stats.Collections == 0 || stats.ByKind[vm.AllocSuperArray].Count == 0 {
+		t.Errorf("unexpected memory stats: %+v", stats)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "allowed.txt"), []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewWithOptions(Options{Sandbox: &Sandbox{
+		FileSystem: []FSRoot{{Path: dir, Read: true}},
+		Network:    []string{"localhost:8080"},
+	}})
+	// 原生函数只能在标准库命名空间中调用
+	compile(t, e, "Plugin.sola", `namespace sola.sandboxtest
+`+exceptionClasses+`
+class Plugin {
+    public static function read(string $path): string {
+        return native_file_read($path) as string;
+    }
+    public static function write(string $path): string {
+        try {
+            native_file_write($path, "x");
+        } catch (RuntimeException $e) {
+            return "denied";
+        }
+        return "written";
+    }
+    public static function connect(string $host, int $port): void {
+        native_tcp_connect($host, $port);
+    }
+    public static function panic(): void {
+        native_panic("boom");
+    }
+}
+`)
+	if result, err := e.Call("Plugin", "read", filepath.Join(dir, "allowed.txt")); err != nil || result.AsString() != "ok" {
+		t.Fatalf("read inside root = %v, %v", result, err)
+	}
+	if result, err := e.Call("Plugin", "write", filepath.Join(dir, "new.txt")); err != nil || result.AsString() != "denied" {
+		t.Fatalf("write to read-only root = %v, %v", result, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(filepath.Dir(outside), link); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		outside,
+		filepath.Join(dir, "..", "secret.txt"),
+		filepath.Join(link, "secret.txt"),
+		link + "/../secret.txt",
+	} {
+		_, err := e.Call("Plugin", "read", path)
+		var se *ScriptError
+		if !errors.As(err, &se) || se.Type != "SecurityException" || !strings.Contains(se.Message, "read access") {
+			t.Errorf("read %s: err = %v, want SecurityException", path, err)
+		}
+	}
+
+	_, err := e.Call("Plugin", "connect", "example.com", 80)
+	var se *ScriptError
+	if !errors.As(err, &se) || se.Type != "SecurityException" || !strings.Contains(se.Message, "example.com:80") {
+		t.Errorf("connect: err = %v, want SecurityException", err)
+	}
+	if _, err := e.Call("Plugin", "panic"); !errors.As(err, &se) || se.Type != "SecurityException" {
+		t.Errorf("panic: err = %v, want SecurityException", err)
+	}
+}
+
+// poolScript 池测试脚本：每次请求修改静态变量、使用枚举、异常和协程
+// 枚举总是 public 的，脚本以枚举命名
+const poolScript = exceptionClasses + `
+class ChannelClosedException extends Exception {}
+enum Level: int {
+    case LOW = 1;
+    case HIGH = 2;
+}
+class Registry {
+    public static int $hits = 0;
+    public static int $base = Registry::compute();
+    public static function compute(): int {
+        return 100;
+    }
+}
+class Handler {
+    public static function produce(Channel<int> $ch, int $n): void {
+        for (int $i = 1; $i <= $n; $i++) {
+            $ch->send($i);
+        }
+        $ch->close();
+    }
+    public static function handle(int $n): string {
+        Registry::$hits = Registry::$hits + 1;
+        Registry::$base = Registry::$base + $n;
+        Channel<int> $ch = new Channel<int>(1);
+        go Handler::produce($ch, $n);
+        int $sum = 0;
+        try {
+            while (true) {
+                $sum += $ch->receive();
+            }
+        } catch (ChannelClosedException $e) {
+        }
+        try {
+            throw new RuntimeException("request " + ($n as string));
+        } catch (Exception $e) {
+            return (Registry::$hits as string) + " " + (Registry::$base as string) + " " + ($sum as string) + " " + $e->getMessage();
+        }
+    }
+    public static function level(int $n): dynamic {
+        if ($n % 2 == 0) {
+            return Level::HIGH;
+        }
+        return Level::LOW;
+    }
+}
+`
+
+func TestPool(t *testing.T) {
+	pool, err := NewPool("Level.sola", poolScript, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, requests = 200, 5
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			for i := 0; i < requests; i++ {
+				n := w + i
+				iso := pool.Get()
+				result, err := iso.Call("Handler", "handle", n)
+				level, levelErr := iso.Call("Handler", "level", n)
+				pool.Put(iso)
+				// 归还时重置：每个请求都从初始的静态变量开始
+				want := fmt.Sprintf("1 %d %d request %d", 100+n, n*(n+1)/2, n)
+				if err != nil || result.AsString() != want {
+					errs <- fmt.Errorf("handle(%d) = %v, %v; want %q", n, result, err, want)
+					return
+				}
+				wantLevel := "Level::LOW"
+				if n%2 == 0 {
+					wantLevel = "Level::HIGH"
+				}
+				if levelErr != nil || level.String() != wantLevel {
+					errs <- fmt.Errorf("level(%d) = %v, %v; want %s", n, level, levelErr, wantLevel)
+					return
+				}
+			}
+			errs <- nil
+		}(w)
+	}
+	for w := 0; w < workers; w++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iso := pool.Get()
+	defer pool.Put(iso)
+	if err := iso.Run(); err == nil {
+		t.Fatal("running a script without main() succeeded")
+	}
+	if _, err := iso.Call("Handler", "missing"); err == nil {
+		t.Fatal("calling an undefined method succeeded")
+	}
+}
+
+func TestPoolLimits(t *testing.T) {
+	pool, err := NewPool("Spin.sola", `
+class Spin {
+    public static function forever(): void {
+        while (true) {
+        }
+    }
+    public static function quick(): int {
+        return 1;
+    }
+    public static function main(): void {
+        Spin::quick();
+    }
+}
+`, Options{Limits: Limits{MaxInstructions: 10000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iso := pool.Get()
+	if err := iso.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	pool.Put(iso)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 100; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			iso := pool.Get()
+			defer pool.Put(iso)
+			if w%2 == 0 {
+				// 请求级的限制在归还后恢复为池的设置
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				iso.SetLimits(Limits{Context: ctx})
+				if _, err := iso.Call("Spin", "quick"); !errors.Is(err, context.Canceled) {
+					t.Errorf("canceled quick: err = %v", err)
+				}
+				return
+			}
+			var le *LimitError
+			if _, err := iso.Call("Spin", "forever"); !errors.As(err, &le) || le.Kind != LimitInstructions {
+				t.Errorf("forever: err = %v, want instruction limit", err)
+			}
+			if result, err := iso.Call("Spin", "quick"); err != nil || result.AsInt() != 1 {
+				t.Errorf("quick = %v, %v", result, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestPoolNatives(t *testing.T) {
+	var calls atomic.Int64
+	pool, err := NewPool("Tally.sola", `
+class Tally {
+    public static function run(int $n): int {
+        $c := new Counter($n);
+        $c.add(hostDouble($n));
+        return $c.get();
+    }
+    public static function make(int $n): Counter {
+        return new Counter(hostDouble($n));
+    }
+    public static function add(Counter $c, int $delta): int {
+        return $c.add($delta);
+    }
+    public static function fail(): void {
+        hostDouble(-1);
+    }
+}
+`, Options{
+		Funcs: map[string]any{"hostDouble": func(n int) (int, error) {
+			calls.Add(1)
+			if n < 0 {
+				return 0, fmt.Errorf("negative %d", n)
+			}
+			return n * 2, nil
+		}},
+		Classes: map[string]any{"Counter": newCounter},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, requests = 50, 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < requests; i++ {
+				n := w*requests + i
+				iso := pool.Get()
+				result, err := iso.Call("Tally", "run", n)
+				if err != nil || result.AsInt() != int64(3*n) {
+					t.Errorf("run(%d) = %v, %v", n, result, err)
+				}
+				// Go 支持类的对象在 Go 和 Sola 之间往返
+				obj, err := iso.Call("Tally", "make", n)
+				c, ok := FromValue(obj).(*counter)
+				if err != nil || !ok || c.n != 2*n {
+					t.Errorf("make(%d) = %v, %v", n, obj, err)
+				} else if result, err := iso.Call("Tally", "add", c, 1); err != nil || result.AsInt() != int64(2*n+1) {
+					t.Errorf("add(%d) = %v, %v", n, result, err)
+				}
+				pool.Put(iso)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if got := calls.Load(); got != 2*workers*requests {
+		t.Fatalf("hostDouble called %d times, want %d", got, 2*workers*requests)
+	}
+
+	iso := pool.Get()
+	defer pool.Put(iso)
+	var se *ScriptError
+	if _, err := iso.Call("Tally", "fail"); !errors.As(err, &se) || !strings.Contains(se.Error(), "negative -1") {
+		t.Fatalf("fail: err = %v", err)
+	}
+
+	if _, err := NewPool("Bad.sola", "class Bad {}", Options{Funcs: map[string]any{"notFunc": 1}}); err == nil {
+		t.Fatal("NewPool accepted a non-function")
+	}
+}
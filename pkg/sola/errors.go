@@ -0,0 +1,102 @@
+package sola
+
+import (
+	"errors"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/runtime"
+	"github.com/tangzhangming/nova/internal/vm"
+)
+
+// ============================================================================
+// 错误
+// ============================================================================
+//
+// Engine 的方法不向终端输出任何内容，失败时返回以下结构化错误：
+//   - *CompileError: 脚本 (或其依赖) 解析、编译失败，Diagnostics 记录每条错误的位置
+//   - *ScriptError:  Sola 代码抛出未捕获的异常或发生运行时错误
+//   - *LimitError:   执行超过 Limits 而被终止，Kind 标明触发的限制
+
+// CompileError 脚本解析或编译失败
+type CompileError = runtime.CompileError
+
+// Diagnostic 单条解析或编译错误
+type Diagnostic = runtime.Diagnostic
+
+// LimitError 执行因超过限制而终止
+// 截止时间触发时 errors.Is(err, context.DeadlineExceeded) 成立，取消时包装上下文的错误
+type LimitError = vm.LimitError
+
+// LimitKind 触发的限制类型
+type LimitKind = vm.LimitKind
+
+// 限制类型
+const (
+	LimitInstructions = vm.LimitInstructions
+	LimitDeadline     = vm.LimitDeadline
+	LimitCanceled     = vm.LimitCanceled
+	LimitAllocations  = vm.LimitAllocations
+)
+
+// StackFrame Sola 调用栈帧
+type StackFrame = bytecode.StackFrame
+
+// ScriptError Sola 代码中未捕获的异常或运行时错误
+type ScriptError struct {
+	Type    string       // 异常类型 (如 "RuntimeException")，运行时错误为空
+	Message string       // 异常消息
+	Stack   []StackFrame // 抛出异常时的调用栈 (最内层在前)
+
+	exception *bytecode.Exception
+	text      string
+}
+
+func (e *ScriptError) Error() string {
+	return e.text
+}
+
+// Exception 返回原始异常 (运行时错误时为 nil)
+func (e *ScriptError) Exception() *bytecode.Exception {
+	return e.exception
+}
+
+// scriptError 把宿主调用的错误转为 *ScriptError，其他错误原样返回
+func scriptError(err error) error {
+	var ce *vm.CallError
+	if !errors.As(err, &ce) {
+		return err
+	}
+	se := &ScriptError{Message: ce.Message, exception: ce.Exception, text: ce.Message}
+	if ex := ce.Exception; ex != nil {
+		se.Type = ex.Type
+		se.Message = exceptionMessage(ex)
+		se.Stack = ex.StackFrames
+	}
+	return se
+}
+
+// exceptionMessage 返回异常消息，对象异常以其 message 属性为准
+func exceptionMessage(ex *bytecode.Exception) string {
+	if ex.Object != nil {
+		if msg, ok := ex.Object.GetField("message"); ok {
+			return msg.AsString()
+		}
+	}
+	return ex.Message
+}
+
+// errorValue 把 Go 函数返回的错误转为 Sola 异常值
+// 来自嵌套调用的 *ScriptError 继续抛出原始异常，其他错误抛出 RuntimeException
+// (已加载 RuntimeException 类时为该类的实例，使 catch 块能调用 getMessage() 等方法)
+func (e *Engine) errorValue(err error) bytecode.Value {
+	var se *ScriptError
+	if errors.As(err, &se) && se.exception != nil {
+		return bytecode.NewExceptionValue(se.exception)
+	}
+	if class := e.rt.GetClass("RuntimeException"); class != nil {
+		obj := bytecode.NewObjectInstance(class)
+		obj.SetField("message", bytecode.NewString(err.Error()))
+		return bytecode.NewExceptionFromObject(obj)
+	}
+	return bytecode.NewException("RuntimeException", err.Error(), 0)
+}
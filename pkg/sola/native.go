@@ -0,0 +1,296 @@
+package sola
+
+import (
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tangzhangming/nova/internal/bytecode"
+	"github.com/tangzhangming/nova/internal/compiler"
+)
+
+// ============================================================================
+// Go 函数与 Go 支持类
+// ============================================================================
+//
+// RegisterFunc 把 Go 函数注册为全局内置函数，RegisterClass 把 Go 类型注册为
+// Sola 类。两者都会在符号表中登记签名，之后编译的脚本才能调用它们。
+//
+// Go 函数的参数按形参类型从 Sola 值转换 (见 convert.go)，返回值可以是
+// 无、单个值、error 或 (值, error)。返回非 nil 的 error 时在 Sola 中抛出
+// RuntimeException；该 error 来自嵌套调用的 *ScriptError 时继续抛出原始异常。
+//
+// Go 支持类的方法与标准库包装 native_ 函数的 Sola 方法相同：方法体把 $this
+// 和参数原样转发给一个内置函数，因此它们和普通方法一样参与继承、内联缓存和调用栈。
+// 对象对应的 Go 值保存在 Object.Native 中。
+
+// goClass 已注册的 Go 支持类
+type goClass struct {
+	class *bytecode.Class
+	typ   reflect.Type
+}
+
+// goFunc 通过反射调用的 Go 函数
+type goFunc struct {
+	name     string
+	fn       reflect.Value
+	recv     reflect.Type   // 方法接收者类型，普通函数为 nil
+	params   []reflect.Type // 形参类型 (不含接收者)
+	spread   bool           // 多余的 Sola 参数收集到最后的可变参数中
+	hasValue bool           // 是否有非 error 的返回值
+	hasError bool           // 最后一个返回值是否为 error
+}
+
+// newGoFunc 检查 Go 函数的签名
+// recv 为 true 时第一个形参是方法接收者
+func newGoFunc(name string, fn reflect.Value, recv bool) (*goFunc, error) {
+	t := fn.Type()
+	g := &goFunc{name: name, fn: fn}
+	first := 0
+	if recv {
+		g.recv = t.In(0)
+		first = 1
+	}
+	for i := first; i < t.NumIn(); i++ {
+		g.params = append(g.params, t.In(i))
+	}
+
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) == errorType {
+			g.hasError = true
+		} else {
+			g.hasValue = true
+		}
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, fmt.Errorf("sola: %s: second result must be error", name)
+		}
+		g.hasValue, g.hasError = true, true
+	default:
+		return nil, fmt.Errorf("sola: %s: too many results", name)
+	}
+	return g, nil
+}
+
+// arity 返回 Sola 侧的参数数量，可变参数不计入
+func (g *goFunc) arity() int {
+	if g.spread {
+		return len(g.params) - 1
+	}
+	return len(g.params)
+}
+
+// paramType 返回第 i 个 Sola 参数对应的 Go 类型
+func (g *goFunc) paramType(i int) reflect.Type {
+	if g.spread && i >= len(g.params)-1 {
+		return g.params[len(g.params)-1].Elem()
+	}
+	return g.params[i]
+}
+
+// call 转换参数并调用 Go 函数，返回非 error 的结果 (没有时无效)
+// 方法调用时 args[0] 为 $this
+func (e *Engine) call(g *goFunc, args []Value) (reflect.Value, error) {
+	in := make([]reflect.Value, 0, len(args))
+	if g.recv != nil {
+		this := args[0]
+		args = args[1:]
+		var native any
+		if this.IsObject() {
+			native = this.AsObject().Native
+		}
+		rv := reflect.ValueOf(native)
+		if native == nil || !rv.Type().AssignableTo(g.recv) {
+			return reflect.Value{}, fmt.Errorf("%s: receiver is not a constructed %s", g.name, g.recv)
+		}
+		in = append(in, rv)
+	}
+
+	if len(args) < g.arity() || (!g.spread && len(args) > g.arity()) {
+		return reflect.Value{}, fmt.Errorf("%s expects %d arguments, got %d", g.name, g.arity(), len(args))
+	}
+	for i, arg := range args {
+		rv, err := e.fromValue(arg, g.paramType(i))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: argument %d: %v", g.name, i+1, err)
+		}
+		in = append(in, rv)
+	}
+
+	var out []reflect.Value
+	if g.fn.Type().IsVariadic() && !g.spread {
+		// 方法的可变参数以 Sola 数组整体传入
+		out = g.fn.CallSlice(in)
+	} else {
+		out = g.fn.Call(in)
+	}
+
+	if g.hasError {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	if g.hasValue {
+		return out[0], nil
+	}
+	return reflect.Value{}, nil
+}
+
+// builtin 把 Go 函数包装为 Sola 内置函数：结果转为 Sola 值，错误转为异常
+func (e *Engine) builtin(g *goFunc) func(args []Value) Value {
+	return func(args []Value) Value {
+		result, err := e.call(g, args)
+		if err != nil {
+			return e.errorValue(err)
+		}
+		v, err := e.toValue(result)
+		if err != nil {
+			return e.errorValue(fmt.Errorf("%s: %v", g.name, err))
+		}
+		return v
+	}
+}
+
+// signature 返回 Go 函数在符号表中的参数和返回类型
+func (e *Engine) signature(g *goFunc) ([]string, string) {
+	params := make([]string, len(g.params))
+	for i := range g.params {
+		params[i] = e.solaType(g.paramType(i))
+	}
+	ret := "void"
+	if g.hasValue {
+		ret = e.solaType(g.fn.Type().Out(0))
+	}
+	return params, ret
+}
+
+// RegisterFunc 把 Go 函数注册为 Sola 全局函数
+// 可变参数函数在 Sola 中同样接受任意个数的额外参数
+func (e *Engine) RegisterFunc(name string, fn any) error {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func {
+		return fmt.Errorf("sola: RegisterFunc %s: not a function", name)
+	}
+	g, err := newGoFunc(name, rv, false)
+	if err != nil {
+		return err
+	}
+	g.spread = rv.Type().IsVariadic()
+
+	params, ret := e.signature(g)
+	e.rt.SymbolTable().RegisterFunction(&compiler.FunctionSignature{
+		Name:       name,
+		ParamTypes: params,
+		ReturnType: ret,
+		MinArity:   g.arity(),
+		IsVariadic: g.spread,
+	})
+	e.rt.RegisterBuiltin(name, e.builtin(g))
+	return nil
+}
+
+// RegisterClass 把 Go 类型注册为 Sola 类
+//
+// ctor 是构造函数，返回 T 或 (T, error)，`new Name(...)` 的参数传给 ctor。
+// T 的导出方法成为实例方法，方法名首字母小写 (Add -> add)。
+// 返回 T 的 Go 函数把值作为该类的对象交给 Sola，接受 T 的参数则取回对象对应的 Go 值。
+func (e *Engine) RegisterClass(name string, ctor any) error {
+	cv := reflect.ValueOf(ctor)
+	if cv.Kind() != reflect.Func || cv.Type().NumOut() == 0 || cv.Type().Out(0) == errorType {
+		return fmt.Errorf("sola: RegisterClass %s: constructor must be a function returning the Go value", name)
+	}
+	t := cv.Type().Out(0)
+	if t.Kind() == reflect.Interface {
+		return fmt.Errorf("sola: RegisterClass %s: constructor must return a concrete type, got %s", name, t)
+	}
+	if _, ok := e.goClasses[t]; ok {
+		return fmt.Errorf("sola: RegisterClass %s: Go type %s is already registered", name, t)
+	}
+	construct, err := newGoFunc(name+"::__construct", cv, false)
+	if err != nil {
+		return err
+	}
+
+	class := bytecode.NewClass(name)
+	e.goClasses[t] = &goClass{class: class, typ: t}
+	st := e.rt.SymbolTable()
+
+	// 构造函数：调用 ctor 并把结果保存到 $this
+	params, _ := e.signature(construct)
+	st.RegisterMethod(&compiler.MethodSignature{
+		ClassName:  name,
+		MethodName: "__construct",
+		ParamTypes: params,
+		ReturnType: "void",
+		MinArity:   construct.arity(),
+	})
+	class.AddMethod(forwardingMethod(name, "__construct", construct.arity(), func(args []Value) Value {
+		result, err := e.call(construct, args[1:])
+		if err != nil {
+			return e.errorValue(err)
+		}
+		args[0].AsObject().Native = result.Interface()
+		return bytecode.NullValue
+	}))
+
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		methodName := lowerFirst(m.Name)
+		g, err := newGoFunc(name+"::"+methodName, m.Func, true)
+		if err != nil {
+			delete(e.goClasses, t)
+			return err
+		}
+		params, ret := e.signature(g)
+		st.RegisterMethod(&compiler.MethodSignature{
+			ClassName:  name,
+			MethodName: methodName,
+			ParamTypes: params,
+			ReturnType: ret,
+			MinArity:   g.arity(),
+		})
+		class.AddMethod(forwardingMethod(name, methodName, g.arity(), e.builtin(g)))
+	}
+
+	e.rt.DefineClass(class)
+	return nil
+}
+
+// forwardingMethod 创建把 $this 和参数转发给内置函数 fn 的实例方法
+// 方法体: PUSH fn; LOAD_LOCAL 0..arity; CALL arity+1; RETURN
+func forwardingMethod(className, name string, arity int, fn func(args []Value) Value) *bytecode.Method {
+	native := bytecode.NewFunction(className + "::" + name)
+	native.Arity = arity + 1
+	native.MinArity = arity + 1
+	native.IsBuiltin = true
+	native.BuiltinFn = fn
+
+	chunk := bytecode.NewChunk()
+	chunk.WriteOp(bytecode.OpPush, 0)
+	chunk.WriteU16(chunk.AddConstant(bytecode.NewFunc(native)), 0)
+	for slot := 0; slot <= arity; slot++ {
+		chunk.WriteOp(bytecode.OpLoadLocal, 0)
+		chunk.WriteU16(uint16(slot), 0)
+	}
+	chunk.WriteOp(bytecode.OpCall, 0)
+	chunk.WriteU8(uint8(arity+1), 0)
+	chunk.WriteOp(bytecode.OpReturn, 0)
+
+	return &bytecode.Method{
+		Name:       name,
+		ClassName:  className,
+		Arity:      arity,
+		MinArity:   arity,
+		Chunk:      chunk,
+		LocalCount: arity + 1,
+	}
+}
+
+// lowerFirst 把 Go 方法名的首字母转为小写
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -0,0 +1,127 @@
+package sola
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tangzhangming/nova/internal/runtime"
+)
+
+// ============================================================================
+// 隔离池
+// ============================================================================
+//
+// NewPool 把脚本及其依赖编译一次，得到不可变的程序映像。每个请求从池中借出
+// 一个 Isolate：Isolate 有自己的 VM (栈、全局变量、类的静态变量、协程)，
+// 与其他 Isolate 共享编译好的类、函数和枚举，因此可以在多个 goroutine 中同时执行：
+//
+//	pool, err := sola.NewPool("Handler.sola", source, sola.Options{})
+//	...
+//	iso := pool.Get()
+//	defer pool.Put(iso)
+//	result, err := iso.Call("Handler", "handle", path)
+//
+// Put 重置 Isolate 后放回池中，下一个请求看不到上一个请求留下的全局变量和静态变量。
+// 脚本使用的 Go 函数和 Go 支持类通过 Options.Funcs 和 Options.Classes 传给 NewPool，
+// 它们随程序映像注册到池中的每个 Isolate。
+
+// Pool 执行同一脚本的 Isolate 池，可以在多个 goroutine 中并发使用
+type Pool struct {
+	pool      *runtime.Pool
+	name      string
+	goClasses map[reflect.Type]*goClass // 由 opts.Classes 注册的 Go 支持类，创建后只读
+}
+
+// NewPool 编译脚本并创建 Isolate 池，编译失败时返回 *CompileError
+// opts 的执行限制和沙箱对池中每个 Isolate 生效；opts.Funcs 和 opts.Classes
+// 在编译前注册，脚本可以调用它们，池中每个 Isolate 都可以使用
+func NewPool(name, source string, opts Options) (*Pool, error) {
+	e := NewWithOptions(opts)
+	for _, className := range sortedKeys(opts.Classes) {
+		if err := e.RegisterClass(className, opts.Classes[className]); err != nil {
+			return nil, err
+		}
+	}
+	for _, funcName := range sortedKeys(opts.Funcs) {
+		if err := e.RegisterFunc(funcName, opts.Funcs[funcName]); err != nil {
+			return nil, err
+		}
+	}
+	img, err := e.rt.CompileImage(source, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Pool{pool: runtime.NewPool(img, runtimeOptions(opts)), name: name, goClasses: e.goClasses}, nil
+}
+
+// sortedKeys 按名称排序，使注册顺序与 map 的遍历顺序无关
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get 借出一个 Isolate，用完后必须通过 Put 归还
+func (p *Pool) Get() *Isolate {
+	rt := p.pool.Get()
+	return &Isolate{pool: p, rt: rt, engine: &Engine{rt: rt, vm: rt.VM(), goClasses: p.goClasses}}
+}
+
+// Put 重置 Isolate 并归还到池中，之后不能再使用该 Isolate
+func (p *Pool) Put(iso *Isolate) {
+	if iso == nil || iso.pool != p || iso.rt == nil {
+		return
+	}
+	p.pool.Put(iso.rt)
+	iso.rt, iso.engine = nil, nil
+}
+
+// Isolate 从池中借出的执行环境，同一时刻只能在一个 goroutine 中使用
+type Isolate struct {
+	pool   *Pool
+	rt     *runtime.Runtime
+	engine *Engine
+}
+
+// SetLimits 设置本次借出期间每次调用的执行限制 (例如绑定请求的 Context)
+// 归还时恢复为池的设置
+func (iso *Isolate) SetLimits(l Limits) {
+	iso.engine.SetLimits(l)
+}
+
+// Run 执行脚本的入口：与脚本文件同名的类的静态 main() 方法
+func (iso *Isolate) Run() error {
+	class := iso.pool.pool.Image().Entry()
+	if class == nil {
+		base := filepath.Base(iso.pool.name)
+		return fmt.Errorf("sola: script %s has no class %s with a static main()", iso.pool.name, strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+	_, err := iso.engine.vm.CallStatic(class, "main", nil)
+	return scriptError(err)
+}
+
+// Call 以 Go 参数调用类的静态方法
+func (iso *Isolate) Call(className, method string, args ...any) (Value, error) {
+	return iso.engine.Call(className, method, args...)
+}
+
+// CallMethod 以 Go 参数调用对象的实例方法
+func (iso *Isolate) CallMethod(obj Value, method string, args ...any) (Value, error) {
+	return iso.engine.CallMethod(obj, method, args...)
+}
+
+// CallValue 以 Go 参数调用 Sola 函数或闭包
+func (iso *Isolate) CallValue(fn Value, args ...any) (Value, error) {
+	return iso.engine.CallValue(fn, args...)
+}
+
+// NewObject 创建类实例并以 Go 参数执行构造函数
+func (iso *Isolate) NewObject(className string, args ...any) (Value, error) {
+	return iso.engine.NewObject(className, args...)
+}